@@ -7,20 +7,16 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	gochatbot "go.rumenx.com/chatbot"
-)
-
-// contextKey is a custom type for context keys to avoid collisions
-type contextKey string
-
-const (
-	chatbotContextKey contextKey = "chatbot"
+	"go.rumenx.com/chatbot/chaterrors"
 )
 
 // ChiAdapter wraps a chatbot for use with the Chi framework
 type ChiAdapter struct {
 	chatbot *gochatbot.Chatbot
 	timeout time.Duration
+	marshal gochatbot.ResponseMarshaler
 }
 
 // NewChiAdapter creates a new Chi adapter for the chatbot
@@ -28,6 +24,7 @@ func NewChiAdapter(chatbot *gochatbot.Chatbot) *ChiAdapter {
 	return &ChiAdapter{
 		chatbot: chatbot,
 		timeout: 30 * time.Second,
+		marshal: chatbot.GetResponseMarshaler(),
 	}
 }
 
@@ -37,6 +34,13 @@ func (adapter *ChiAdapter) WithTimeout(timeout time.Duration) *ChiAdapter {
 	return adapter
 }
 
+// WithResponseMarshaler sets how chat responses are encoded, overriding the
+// chatbot's ResponseMarshaler.
+func (adapter *ChiAdapter) WithResponseMarshaler(marshal gochatbot.ResponseMarshaler) *ChiAdapter {
+	adapter.marshal = marshal
+	return adapter
+}
+
 // ChatHandler returns a Chi handler for chat requests
 func (adapter *ChiAdapter) ChatHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -45,60 +49,76 @@ func (adapter *ChiAdapter) ChatHandler() http.HandlerFunc {
 
 		var req ChatRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			response := ChatResponse{
+			adapter.respond(w, http.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Invalid JSON",
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(response)
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
 			return
 		}
 
-		if req.Message == "" {
-			response := ChatResponse{
+		if req.Message == "" && len(req.Messages) == 0 {
+			adapter.respond(w, http.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Message is required",
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(response)
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
 			return
 		}
 
-		chatResponse, err := adapter.chatbot.Ask(ctx, req.Message)
+		message, askOptions, err := req.resolve()
+		if err != nil {
+			adapter.respond(w, http.StatusBadRequest, ChatResponse{
+				Success: false,
+				Error:   err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
+			return
+		}
+
+		chatResponse, err := adapter.chatbot.AskResponse(ctx, message, askOptions...)
 		if err != nil {
 			// Check if it's a timeout error
 			if ctx.Err() == context.DeadlineExceeded {
-				response := ChatResponse{
+				adapter.respond(w, http.StatusRequestTimeout, ChatResponse{
 					Success: false,
 					Error:   "Request timeout",
-				}
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusRequestTimeout)
-				json.NewEncoder(w).Encode(response)
+				})
 				return
 			}
 
-			response := ChatResponse{
-				Success: false,
-				Error:   err.Error(),
+			statusCode := chaterrors.Status(err)
+			if headers, ok := rateLimitHeaders(err); ok {
+				for name, value := range headers {
+					w.Header().Set(name, value)
+				}
 			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(response)
+
+			adapter.respond(w, statusCode, errorResponse(err))
 			return
 		}
 
-		response := ChatResponse{
-			Success:  true,
-			Response: chatResponse,
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		adapter.respond(w, http.StatusOK, ChatResponse{
+			Success:        true,
+			Response:       chatResponse.Reply,
+			ConversationID: chatResponse.ConversationID,
+			reply:          chatResponse,
+		})
 	}
 }
 
+// respond writes a chat response encoded with the adapter's marshaler.
+func (adapter *ChiAdapter) respond(w http.ResponseWriter, statusCode int, response ChatResponse) {
+	body, err := marshalResponse(adapter.marshal, response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
 // HealthHandler returns a Chi handler for health checks
 func (adapter *ChiAdapter) HealthHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -110,6 +130,7 @@ func (adapter *ChiAdapter) HealthHandler() http.HandlerFunc {
 			Model:     model.Name(),
 			Timestamp: time.Now().Unix(),
 		}
+		response.reportMaintenance(adapter.chatbot)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
@@ -141,18 +162,46 @@ func (adapter *ChiAdapter) SetupRoutesWithPrefix(r chi.Router, prefix string) {
 	})
 }
 
-// Middleware returns Chi middleware that adds the chatbot to the request context
+// Middleware returns Chi middleware that adds the chatbot to the request context,
+// where FromContext finds it
 func (adapter *ChiAdapter) Middleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := context.WithValue(r.Context(), chatbotContextKey, adapter.chatbot)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), adapter.chatbot)))
+		})
+	}
+}
+
+// LoggingMiddleware returns Chi middleware that logs requests with logger
+func (adapter *ChiAdapter) LoggingMiddleware(logger *gochatbot.RequestLogger) func(http.Handler) http.Handler {
+	return logger.Middleware
+}
+
+// RecoveryMiddleware returns Chi middleware that turns panics into 500 JSON
+// errors with a reference, recorded with the chatbot's HandlePanic
+func (adapter *ChiAdapter) RecoveryMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writer := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+				err := adapter.chatbot.HandlePanic(value)
+				if writer.Status() == 0 && writer.BytesWritten() == 0 {
+					adapter.respond(w, http.StatusInternalServerError, panicResponse(err))
+				}
+			}()
+			next.ServeHTTP(writer, r)
 		})
 	}
 }
 
 // GetChatbotFromChiContext retrieves the chatbot from a Chi request context
 func GetChatbotFromChiContext(r *http.Request) (*gochatbot.Chatbot, bool) {
-	chatbot, ok := r.Context().Value(chatbotContextKey).(*gochatbot.Chatbot)
-	return chatbot, ok
+	return FromContext(r.Context())
 }
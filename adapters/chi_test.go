@@ -12,6 +12,9 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/config"
 )
 
 func TestNewChiAdapter(t *testing.T) {
@@ -229,7 +232,7 @@ func TestGetChatbotFromChiContext(t *testing.T) {
 	r := chi.NewRouter()
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := context.WithValue(r.Context(), chatbotContextKey, bot)
+			ctx := NewContext(r.Context(), bot)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
@@ -267,7 +270,7 @@ func TestGetChatbotFromChiContext(t *testing.T) {
 	r3 := chi.NewRouter()
 	r3.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := context.WithValue(r.Context(), chatbotContextKey, "not a chatbot")
+			ctx := context.WithValue(r.Context(), ChatbotContextKey, "not a chatbot")
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
@@ -312,3 +315,29 @@ func TestChiAdapter_ChatHandler_ContextTimeout(t *testing.T) {
 	// We just verify it doesn't crash
 	assert.True(t, rr.Code == http.StatusOK || rr.Code == http.StatusRequestTimeout)
 }
+
+func TestChiAdapter_ChatHandler_RateLimited(t *testing.T) {
+	bot, err := gochatbot.New(&config.Config{
+		Model:     "free",
+		RateLimit: config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute},
+	})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Post("/chat", NewChiAdapter(bot).ChatHandler())
+
+	var rr *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		body, _ := json.Marshal(ChatRequest{Message: "Hello"})
+		req := httptest.NewRequest("POST", "/chat", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, rr.Header().Get("X-RateLimit-Reset"))
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
@@ -0,0 +1,28 @@
+package adapters
+
+import (
+	"context"
+
+	gochatbot "go.rumenx.com/chatbot"
+)
+
+// ContextKey is the type of the context keys set by the adapters. Keys of
+// other packages cannot collide with it, even if they have the same name.
+type ContextKey string
+
+// ChatbotContextKey is the request context key under which every adapter's
+// Middleware stores the chatbot.
+const ChatbotContextKey ContextKey = "chatbot"
+
+// NewContext returns a context carrying bot.
+func NewContext(ctx context.Context, bot *gochatbot.Chatbot) context.Context {
+	return context.WithValue(ctx, ChatbotContextKey, bot)
+}
+
+// FromContext returns the chatbot stored with NewContext, such as by an
+// adapter's Middleware, so code below the handlers can reach it from any
+// framework.
+func FromContext(ctx context.Context) (*gochatbot.Chatbot, bool) {
+	bot, ok := ctx.Value(ChatbotContextKey).(*gochatbot.Chatbot)
+	return bot, ok
+}
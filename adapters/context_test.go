@@ -0,0 +1,22 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	bot := setupTestBot()
+
+	retrievedBot, exists := FromContext(NewContext(context.Background(), bot))
+	assert.True(t, exists)
+	assert.Equal(t, bot, retrievedBot)
+
+	// A plain string key does not collide with the adapters' key
+	ctx := context.WithValue(context.Background(), "chatbot", bot)
+	retrievedBot, exists = FromContext(ctx)
+	assert.False(t, exists)
+	assert.Nil(t, retrievedBot)
+}
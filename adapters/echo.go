@@ -8,12 +8,14 @@ import (
 	"github.com/labstack/echo/v4"
 
 	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/chaterrors"
 )
 
 // EchoAdapter provides Echo framework integration for go-chatbot.
 type EchoAdapter struct {
 	chatbot *gochatbot.Chatbot
 	timeout time.Duration
+	marshal gochatbot.ResponseMarshaler
 }
 
 // NewEchoAdapter creates a new Echo adapter with the provided chatbot instance.
@@ -21,6 +23,7 @@ func NewEchoAdapter(bot *gochatbot.Chatbot) *EchoAdapter {
 	return &EchoAdapter{
 		chatbot: bot,
 		timeout: 30 * time.Second,
+		marshal: bot.GetResponseMarshaler(),
 	}
 }
 
@@ -30,6 +33,13 @@ func (a *EchoAdapter) WithTimeout(timeout time.Duration) *EchoAdapter {
 	return a
 }
 
+// WithResponseMarshaler sets how chat responses are encoded, overriding the
+// chatbot's ResponseMarshaler.
+func (a *EchoAdapter) WithResponseMarshaler(marshal gochatbot.ResponseMarshaler) *EchoAdapter {
+	a.marshal = marshal
+	return a
+}
+
 // ChatHandler returns an Echo handler function for chat endpoints.
 func (a *EchoAdapter) ChatHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -38,49 +48,64 @@ func (a *EchoAdapter) ChatHandler() echo.HandlerFunc {
 
 		var req ChatRequest
 		if err := c.Bind(&req); err != nil {
-			return c.JSON(http.StatusBadRequest, ChatResponse{
+			return a.respond(c, http.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Invalid request format: " + err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
 			})
 		}
 
 		// Validate required fields
-		if req.Message == "" {
-			return c.JSON(http.StatusBadRequest, ChatResponse{
+		if req.Message == "" && len(req.Messages) == 0 {
+			return a.respond(c, http.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Message is required",
+				Code:    chaterrors.ErrInvalidInput.Code,
 			})
 		}
 
-		// Convert context map to AskOptions
-		var askOptions []gochatbot.AskOption
-		if req.Context != nil {
-			for key, value := range req.Context {
-				askOptions = append(askOptions, gochatbot.WithContext(key, value))
-			}
+		message, askOptions, err := req.resolve()
+		if err != nil {
+			return a.respond(c, http.StatusBadRequest, ChatResponse{
+				Success: false,
+				Error:   err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
 		}
 
-		response, err := a.chatbot.Ask(ctx, req.Message, askOptions...)
+		response, err := a.chatbot.AskResponse(ctx, message, askOptions...)
 		if err != nil {
-			statusCode := http.StatusInternalServerError
+			statusCode := chaterrors.Status(err)
 			// Check for specific error types
 			if ctx.Err() == context.DeadlineExceeded {
 				statusCode = http.StatusRequestTimeout
+			} else if headers, ok := rateLimitHeaders(err); ok {
+				for name, value := range headers {
+					c.Response().Header().Set(name, value)
+				}
 			}
 
-			return c.JSON(statusCode, ChatResponse{
-				Success: false,
-				Error:   err.Error(),
-			})
+			return a.respond(c, statusCode, errorResponse(err))
 		}
 
-		return c.JSON(http.StatusOK, ChatResponse{
-			Response: response,
-			Success:  true,
+		return a.respond(c, http.StatusOK, ChatResponse{
+			Response:       response.Reply,
+			Success:        true,
+			ConversationID: response.ConversationID,
+			reply:          response,
 		})
 	}
 }
 
+// respond writes a chat response encoded with the adapter's marshaler.
+func (a *EchoAdapter) respond(c echo.Context, statusCode int, response ChatResponse) error {
+	body, err := marshalResponse(a.marshal, response)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to encode response")
+	}
+	return c.Blob(statusCode, echo.MIMEApplicationJSONCharsetUTF8, body)
+}
+
 // HealthHandler returns an Echo handler function for health check endpoints.
 func (a *EchoAdapter) HealthHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -102,6 +127,7 @@ func (a *EchoAdapter) HealthHandler() echo.HandlerFunc {
 		}
 
 		response.Status = healthStatusHealthy
+		response.reportMaintenance(a.chatbot)
 		return c.JSON(http.StatusOK, response)
 	}
 }
@@ -132,23 +158,47 @@ func (a *EchoAdapter) SetupRoutesWithPrefix(e *echo.Echo, prefix string) {
 	chatGroup.GET("/health", a.HealthHandler())
 }
 
-// Middleware returns an Echo middleware that adds chatbot functionality to the context.
+// Middleware returns an Echo middleware that adds the chatbot to the request
+// context, where FromContext finds it.
 func (a *EchoAdapter) Middleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			c.Set("chatbot", a.chatbot)
+			c.SetRequest(c.Request().WithContext(NewContext(c.Request().Context(), a.chatbot)))
 			return next(c)
 		}
 	}
 }
 
-// GetChatbotFromEchoContext extracts the chatbot instance from Echo context.
-func GetChatbotFromEchoContext(c echo.Context) (*gochatbot.Chatbot, bool) {
-	bot := c.Get("chatbot")
-	if bot == nil {
-		return nil, false
+// LoggingMiddleware returns an Echo middleware that logs requests with
+// logger.
+func (a *EchoAdapter) LoggingMiddleware(logger *gochatbot.RequestLogger) echo.MiddlewareFunc {
+	return echo.WrapMiddleware(logger.Middleware)
+}
+
+// RecoveryMiddleware returns an Echo middleware that turns panics into 500
+// JSON errors with a reference, recorded with the chatbot's HandlePanic.
+func (a *EchoAdapter) RecoveryMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+				panicErr := a.chatbot.HandlePanic(value)
+				if !c.Response().Committed {
+					err = a.respond(c, http.StatusInternalServerError, panicResponse(panicErr))
+				}
+			}()
+			return next(c)
+		}
 	}
+}
 
-	chatbotInstance, ok := bot.(*gochatbot.Chatbot)
-	return chatbotInstance, ok
+// GetChatbotFromEchoContext extracts the chatbot instance from the request context.
+func GetChatbotFromEchoContext(c echo.Context) (*gochatbot.Chatbot, bool) {
+	return FromContext(c.Request().Context())
 }
@@ -218,8 +218,8 @@ func TestGetChatbotFromEchoContext(t *testing.T) {
 
 	// Test with chatbot in context
 	e := echo.New()
-	c := e.NewContext(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder())
-	c.Set("chatbot", bot)
+	req := httptest.NewRequest("GET", "/", nil)
+	c := e.NewContext(req.WithContext(NewContext(req.Context(), bot)), httptest.NewRecorder())
 
 	retrievedBot, exists := GetChatbotFromEchoContext(c)
 	assert.True(t, exists)
@@ -233,8 +233,8 @@ func TestGetChatbotFromEchoContext(t *testing.T) {
 	assert.Nil(t, retrievedBot2)
 
 	// Test with wrong type in context
-	c3 := e.NewContext(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder())
-	c3.Set("chatbot", "not a chatbot")
+	req3 := httptest.NewRequest("GET", "/", nil)
+	c3 := e.NewContext(req3.WithContext(context.WithValue(req3.Context(), ChatbotContextKey, "not a chatbot")), httptest.NewRecorder())
 
 	retrievedBot3, exists3 := GetChatbotFromEchoContext(c3)
 	assert.False(t, exists3)
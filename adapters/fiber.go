@@ -1,18 +1,24 @@
 package adapters
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"net/http"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/chaterrors"
 )
 
 // FiberAdapter provides Fiber framework integration for go-chatbot.
 type FiberAdapter struct {
 	chatbot *gochatbot.Chatbot
 	timeout time.Duration
+	marshal gochatbot.ResponseMarshaler
 }
 
 // NewFiberAdapter creates a new Fiber adapter with the provided chatbot instance.
@@ -20,6 +26,7 @@ func NewFiberAdapter(bot *gochatbot.Chatbot) *FiberAdapter {
 	return &FiberAdapter{
 		chatbot: bot,
 		timeout: 30 * time.Second,
+		marshal: bot.GetResponseMarshaler(),
 	}
 }
 
@@ -29,57 +36,80 @@ func (a *FiberAdapter) WithTimeout(timeout time.Duration) *FiberAdapter {
 	return a
 }
 
+// WithResponseMarshaler sets how chat responses are encoded, overriding the
+// chatbot's ResponseMarshaler.
+func (a *FiberAdapter) WithResponseMarshaler(marshal gochatbot.ResponseMarshaler) *FiberAdapter {
+	a.marshal = marshal
+	return a
+}
+
 // ChatHandler returns a Fiber handler function for chat endpoints.
 func (a *FiberAdapter) ChatHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		ctx, cancel := context.WithTimeout(c.Context(), a.timeout)
+		ctx, cancel := context.WithTimeout(c.UserContext(), a.timeout)
 		defer cancel()
 
 		var req ChatRequest
 		if err := c.BodyParser(&req); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(ChatResponse{
+			return a.respond(c, fiber.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Invalid request format: " + err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
 			})
 		}
 
 		// Validate required fields
-		if req.Message == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(ChatResponse{
+		if req.Message == "" && len(req.Messages) == 0 {
+			return a.respond(c, fiber.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Message is required",
+				Code:    chaterrors.ErrInvalidInput.Code,
 			})
 		}
 
-		// Convert context map to AskOptions
-		var askOptions []gochatbot.AskOption
-		if req.Context != nil {
-			for key, value := range req.Context {
-				askOptions = append(askOptions, gochatbot.WithContext(key, value))
-			}
+		message, askOptions, err := req.resolve()
+		if err != nil {
+			return a.respond(c, fiber.StatusBadRequest, ChatResponse{
+				Success: false,
+				Error:   err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
 		}
 
-		response, err := a.chatbot.Ask(ctx, req.Message, askOptions...)
+		response, err := a.chatbot.AskResponse(ctx, message, askOptions...)
 		if err != nil {
-			statusCode := fiber.StatusInternalServerError
+			statusCode := chaterrors.Status(err)
 			// Check for specific error types
 			if ctx.Err() == context.DeadlineExceeded {
 				statusCode = fiber.StatusRequestTimeout
+			} else if headers, ok := rateLimitHeaders(err); ok {
+				for name, value := range headers {
+					c.Set(name, value)
+				}
 			}
 
-			return c.Status(statusCode).JSON(ChatResponse{
-				Success: false,
-				Error:   err.Error(),
-			})
+			return a.respond(c, statusCode, errorResponse(err))
 		}
 
-		return c.Status(fiber.StatusOK).JSON(ChatResponse{
-			Response: response,
-			Success:  true,
+		return a.respond(c, fiber.StatusOK, ChatResponse{
+			Response:       response.Reply,
+			Success:        true,
+			ConversationID: response.ConversationID,
+			reply:          response,
 		})
 	}
 }
 
+// respond writes a chat response encoded with the adapter's marshaler.
+func (a *FiberAdapter) respond(c *fiber.Ctx, statusCode int, response ChatResponse) error {
+	body, err := marshalResponse(a.marshal, response)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to encode response")
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(statusCode).Send(body)
+}
+
 // HealthHandler returns a Fiber handler function for health check endpoints.
 func (a *FiberAdapter) HealthHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -101,6 +131,7 @@ func (a *FiberAdapter) HealthHandler() fiber.Handler {
 		}
 
 		response.Status = healthStatusHealthy
+		response.reportMaintenance(a.chatbot)
 		return c.Status(fiber.StatusOK).JSON(response)
 	}
 }
@@ -131,21 +162,58 @@ func (a *FiberAdapter) SetupRoutesWithPrefix(app *fiber.App, prefix string) {
 	chatGroup.Get("/health", a.HealthHandler())
 }
 
-// Middleware returns a Fiber middleware that adds chatbot functionality to the context.
+// Middleware returns a Fiber middleware that adds the chatbot to the user
+// context, where FromContext finds it.
 func (a *FiberAdapter) Middleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		c.Locals("chatbot", a.chatbot)
+		c.SetUserContext(NewContext(c.UserContext(), a.chatbot))
 		return c.Next()
 	}
 }
 
-// GetChatbotFromFiberContext extracts the chatbot instance from Fiber context.
-func GetChatbotFromFiberContext(c *fiber.Ctx) (*gochatbot.Chatbot, bool) {
-	bot := c.Locals("chatbot")
-	if bot == nil {
-		return nil, false
+// LoggingMiddleware returns a Fiber middleware that logs requests with
+// logger. Streamed response bodies are not logged.
+func (a *FiberAdapter) LoggingMiddleware(logger *gochatbot.RequestLogger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, err := http.NewRequestWithContext(c.UserContext(), c.Method(), c.OriginalURL(), bytes.NewReader(c.Body()))
+		if err != nil {
+			return fmt.Errorf("failed to log request: %w", err)
+		}
+		req, record := logger.Begin(req)
+		// Fiber has already read the body; reading it here records it
+		_, _ = io.Copy(io.Discard, req.Body)
+		c.SetUserContext(req.Context())
+
+		err = c.Next()
+		if err != nil {
+			// Let the app's error handler write the response
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+			err = nil
+		}
+		if !c.Response().IsBodyStream() {
+			record.Write(c.Response().Body())
+		}
+		record.Finish(c.Response().StatusCode())
+		return err
 	}
+}
 
-	chatbotInstance, ok := bot.(*gochatbot.Chatbot)
-	return chatbotInstance, ok
+// RecoveryMiddleware returns a Fiber middleware that turns panics into 500
+// JSON errors with a reference, recorded with the chatbot's HandlePanic.
+func (a *FiberAdapter) RecoveryMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				err = a.respond(c, fiber.StatusInternalServerError, panicResponse(a.chatbot.HandlePanic(value)))
+			}
+		}()
+		return c.Next()
+	}
+}
+
+// GetChatbotFromFiberContext extracts the chatbot instance from the user context.
+func GetChatbotFromFiberContext(c *fiber.Ctx) (*gochatbot.Chatbot, bool) {
+	return FromContext(c.UserContext())
 }
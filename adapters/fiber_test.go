@@ -12,6 +12,8 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	gochatbot "go.rumenx.com/chatbot"
 )
 
 func TestNewFiberAdapter(t *testing.T) {
@@ -109,6 +111,52 @@ func TestFiberAdapter_ChatHandler(t *testing.T) {
 	}
 }
 
+func TestFiberAdapter_LoggingMiddleware(t *testing.T) {
+	var entries []gochatbot.RequestLogEntry
+	logger := gochatbot.NewRequestLogger(gochatbot.RequestLogOptions{
+		Log:    func(entry gochatbot.RequestLogEntry) { entries = append(entries, entry) },
+		Bodies: true,
+	})
+	adapter := NewFiberAdapter(setupTestBot())
+	app := fiber.New()
+	app.Use(adapter.LoggingMiddleware(logger))
+	app.Post("/chat", adapter.ChatHandler())
+
+	req, err := http.NewRequest("POST", "/chat", bytes.NewBufferString(`{"message": "Hello"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, entries, 1)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, "free-model", entries[0].Model)
+	assert.Contains(t, entries[0].RequestBody, `"message":"[REDACTED]"`)
+	assert.Contains(t, entries[0].ResponseBody, `"response":"[REDACTED]"`)
+}
+
+func TestFiberAdapter_RecoveryMiddleware(t *testing.T) {
+	bot := setupTestBot()
+	adapter := NewFiberAdapter(bot)
+	app := fiber.New()
+	app.Use(adapter.RecoveryMiddleware())
+	app.Get("/panic", func(c *fiber.Ctx) error { panic("handler failure") })
+
+	req, err := http.NewRequest("GET", "/panic", nil)
+	require.NoError(t, err)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	var response ChatResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.NotEmpty(t, response.Reference)
+	assert.Equal(t, uint64(1), bot.Panics())
+}
+
 func TestFiberAdapter_HealthHandler(t *testing.T) {
 	bot := setupTestBot()
 	adapter := NewFiberAdapter(bot)
@@ -242,7 +290,7 @@ func TestGetChatbotFromFiberContext(t *testing.T) {
 
 	// Test with chatbot in context via middleware
 	app.Use(func(c *fiber.Ctx) error {
-		c.Locals("chatbot", bot)
+		c.SetUserContext(NewContext(c.UserContext(), bot))
 		retrievedBot, exists := GetChatbotFromFiberContext(c)
 		assert.True(t, exists)
 		assert.Equal(t, bot, retrievedBot)
@@ -275,7 +323,7 @@ func TestGetChatbotFromFiberContext(t *testing.T) {
 	// Test with wrong type in context
 	app3 := fiber.New()
 	app3.Use(func(c *fiber.Ctx) error {
-		c.Locals("chatbot", "not a chatbot")
+		c.SetUserContext(context.WithValue(c.UserContext(), ChatbotContextKey, "not a chatbot"))
 		retrievedBot, exists := GetChatbotFromFiberContext(c)
 		assert.False(t, exists)
 		assert.Nil(t, retrievedBot)
@@ -3,24 +3,31 @@ package adapters
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/middleware"
 )
 
 // Health status constants
 const (
-	healthStatusHealthy   = "healthy"
-	healthStatusUnhealthy = "unhealthy"
+	healthStatusHealthy     = "healthy"
+	healthStatusUnhealthy   = "unhealthy"
+	healthStatusMaintenance = "maintenance"
 )
 
 // GinAdapter provides Gin framework integration for go-chatbot.
 type GinAdapter struct {
 	chatbot *gochatbot.Chatbot
 	timeout time.Duration
+	marshal gochatbot.ResponseMarshaler
 }
 
 // NewGinAdapter creates a new Gin adapter with the provided chatbot instance.
@@ -28,6 +35,7 @@ func NewGinAdapter(bot *gochatbot.Chatbot) *GinAdapter {
 	return &GinAdapter{
 		chatbot: bot,
 		timeout: 30 * time.Second,
+		marshal: bot.GetResponseMarshaler(),
 	}
 }
 
@@ -37,10 +45,72 @@ func (a *GinAdapter) WithTimeout(timeout time.Duration) *GinAdapter {
 	return a
 }
 
+// WithResponseMarshaler sets how chat responses are encoded, overriding the
+// chatbot's ResponseMarshaler.
+func (a *GinAdapter) WithResponseMarshaler(marshal gochatbot.ResponseMarshaler) *GinAdapter {
+	a.marshal = marshal
+	return a
+}
+
 // ChatRequest represents the expected request format for chat endpoints.
 type ChatRequest struct {
-	Message string                 `json:"message" binding:"required"`
-	Context map[string]interface{} `json:"context,omitempty"`
+	Message string `json:"message"`
+	// Messages replaces Message for clients that send the history
+	// themselves; see gochatbot.ResolveMessages.
+	Messages []gochatbot.ChatMessage `json:"messages,omitempty"`
+	Context  map[string]interface{}  `json:"context,omitempty"`
+	// ConversationID continues a conversation kept with
+	// gochatbot.WithConversations; without one a new conversation starts.
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// reservedContextKeys are the context keys the chatbot and its models read
+// or set themselves, which clients must not set in a request's context.
+var reservedContextKeys = map[string]bool{
+	// Identity, rate limits, tool permissions and conversations
+	"user_id":         true,
+	"user_attributes": true,
+	"persona":         true,
+	"conversation_id": true,
+	"history":         true,
+	// Instructions to the model, including the guard instructions
+	"prompt":              true,
+	"system":              true,
+	"system_instructions": true,
+	// Ollama's raw mode skips the system prompt; "context" is its state
+	"raw":     true,
+	"context": true,
+	// Message filter and sentiment results, which drive de-escalation and
+	// handoff
+	"sentiment":           true,
+	"sentiment_score":     true,
+	"emotions":            true,
+	"aggression_detected": true,
+	"links_filtered":      true,
+	"deescalate":          true,
+	// Experiment assignments, recorded with analytics events
+	"experiment": true,
+	"variant":    true,
+}
+
+// resolve returns the message to ask and the AskOptions of the request's
+// messages, context and conversation ID. It fails if the context sets a
+// reserved key.
+func (req ChatRequest) resolve() (string, []gochatbot.AskOption, error) {
+	message, options, err := gochatbot.ResolveMessages(req.Message, req.Messages)
+	if err != nil {
+		return "", nil, err
+	}
+	for key, value := range req.Context {
+		if reservedContextKeys[key] {
+			return "", nil, fmt.Errorf("context key %q is reserved", key)
+		}
+		options = append(options, gochatbot.WithContext(key, value))
+	}
+	if req.ConversationID != "" {
+		options = append(options, gochatbot.WithConversationID(req.ConversationID))
+	}
+	return message, options, nil
 }
 
 // ChatResponse represents the response format for chat endpoints.
@@ -48,6 +118,48 @@ type ChatResponse struct {
 	Response string `json:"response"`
 	Success  bool   `json:"success"`
 	Error    string `json:"error,omitempty"`
+	// ConversationID is the conversation to send back to continue it.
+	ConversationID string `json:"conversation_id,omitempty"`
+	// Code identifies the kind of error, such as "rate_limited"; see the
+	// chaterrors package.
+	Code string `json:"code,omitempty"`
+	// Reference identifies the logged panic behind an internal error.
+	Reference string `json:"reference,omitempty"`
+
+	// reply is the chatbot's response, passed to a ResponseMarshaler.
+	reply *gochatbot.ChatResponse
+}
+
+// marshalResponse encodes response with marshal, or as JSON without one.
+// marshal gets the chatbot's response, or one with only Error set.
+func marshalResponse(marshal gochatbot.ResponseMarshaler, response ChatResponse) ([]byte, error) {
+	if marshal == nil {
+		return json.Marshal(response)
+	}
+	if response.reply == nil {
+		return marshal(&gochatbot.ChatResponse{Error: response.Error, Code: response.Code, Reference: response.Reference})
+	}
+	return marshal(response.reply)
+}
+
+// errorResponse is the response to an error of the chatbot, described as
+// the chat endpoints describe it (see chaterrors.Message).
+func errorResponse(err error) ChatResponse {
+	return ChatResponse{
+		Success: false,
+		Error:   chaterrors.Message(err),
+		Code:    chaterrors.Code(err),
+	}
+}
+
+// panicResponse is the response to a request whose handler panicked.
+func panicResponse(err *gochatbot.PanicError) ChatResponse {
+	return ChatResponse{
+		Success:   false,
+		Error:     "Internal server error",
+		Code:      chaterrors.CodeInternal,
+		Reference: err.Reference,
+	}
 }
 
 // HealthResponse represents the response format for health check endpoints.
@@ -57,6 +169,27 @@ type HealthResponse struct {
 	Model     string `json:"model"`
 	Timestamp int64  `json:"timestamp"`
 	Error     string `json:"error,omitempty"`
+	// Maintenance is set while the chatbot is in maintenance mode.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// reportMaintenance marks the response of a healthy chatbot that is in
+// maintenance mode.
+func (r *HealthResponse) reportMaintenance(bot *gochatbot.Chatbot) {
+	if bot.Maintenance().Enabled {
+		r.Status = healthStatusMaintenance
+		r.Maintenance = true
+	}
+}
+
+// rateLimitHeaders returns the X-RateLimit-* and Retry-After headers to send
+// with a 429 response if err is a rate limiter rejection.
+func rateLimitHeaders(err error) (map[string]string, bool) {
+	var limited *middleware.RateLimitError
+	if !errors.As(err, &limited) {
+		return nil, false
+	}
+	return limited.Headers(), true
 }
 
 // ChatHandler returns a Gin handler function for chat endpoints.
@@ -67,43 +200,67 @@ func (a *GinAdapter) ChatHandler() gin.HandlerFunc {
 
 		var req ChatRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ChatResponse{
+			a.respond(c, http.StatusBadRequest, ChatResponse{
 				Success: false,
 				Error:   "Invalid request format: " + err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
 			})
 			return
 		}
 
-		// Convert context map to AskOptions
-		var askOptions []gochatbot.AskOption
-		if req.Context != nil {
-			for key, value := range req.Context {
-				askOptions = append(askOptions, gochatbot.WithContext(key, value))
-			}
+		if req.Message == "" && len(req.Messages) == 0 {
+			a.respond(c, http.StatusBadRequest, ChatResponse{
+				Success: false,
+				Error:   "Message is required",
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
+			return
+		}
+		message, askOptions, err := req.resolve()
+		if err != nil {
+			a.respond(c, http.StatusBadRequest, ChatResponse{
+				Success: false,
+				Error:   err.Error(),
+				Code:    chaterrors.ErrInvalidInput.Code,
+			})
+			return
 		}
 
-		response, err := a.chatbot.Ask(ctx, req.Message, askOptions...)
+		response, err := a.chatbot.AskResponse(ctx, message, askOptions...)
 		if err != nil {
-			statusCode := http.StatusInternalServerError
+			statusCode := chaterrors.Status(err)
 			// Check for specific error types
 			if ctx.Err() == context.DeadlineExceeded {
 				statusCode = http.StatusRequestTimeout
+			} else if headers, ok := rateLimitHeaders(err); ok {
+				for name, value := range headers {
+					c.Header(name, value)
+				}
 			}
 
-			c.JSON(statusCode, ChatResponse{
-				Success: false,
-				Error:   err.Error(),
-			})
+			a.respond(c, statusCode, errorResponse(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, ChatResponse{
-			Response: response,
-			Success:  true,
+		a.respond(c, http.StatusOK, ChatResponse{
+			Response:       response.Reply,
+			Success:        true,
+			ConversationID: response.ConversationID,
+			reply:          response,
 		})
 	}
 }
 
+// respond writes a chat response encoded with the adapter's marshaler.
+func (a *GinAdapter) respond(c *gin.Context, statusCode int, response ChatResponse) {
+	body, err := marshalResponse(a.marshal, response)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	c.Data(statusCode, "application/json; charset=utf-8", body)
+}
+
 // HealthHandler returns a Gin handler function for health check endpoints.
 func (a *GinAdapter) HealthHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -126,6 +283,7 @@ func (a *GinAdapter) HealthHandler() gin.HandlerFunc {
 		}
 
 		response.Status = healthStatusHealthy
+		response.reportMaintenance(a.chatbot)
 		c.JSON(http.StatusOK, response)
 	}
 }
@@ -160,21 +318,68 @@ func (a *GinAdapter) SetupRoutesWithPrefix(router gin.IRouter, prefix string) {
 	}
 }
 
-// Middleware returns a Gin middleware that adds chatbot functionality to the context.
+// Middleware returns a Gin middleware that adds the chatbot to the request
+// context, where FromContext finds it.
 func (a *GinAdapter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Set("chatbot", a.chatbot)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), a.chatbot))
+		c.Next()
+	}
+}
+
+// LoggingMiddleware returns a Gin middleware that logs requests with logger.
+func (a *GinAdapter) LoggingMiddleware(logger *gochatbot.RequestLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, record := logger.Begin(c.Request)
+		c.Request = req
+		c.Writer = &ginLoggingWriter{ResponseWriter: c.Writer, record: record}
+		c.Next()
+		record.Finish(c.Writer.Status())
+	}
+}
+
+// ginLoggingWriter passes the response body to a request record.
+type ginLoggingWriter struct {
+	gin.ResponseWriter
+	record *gochatbot.RequestRecord
+}
+
+func (w *ginLoggingWriter) Write(p []byte) (int, error) {
+	w.record.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *ginLoggingWriter) WriteString(s string) (int, error) {
+	w.record.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// RecoveryMiddleware returns a Gin middleware that turns panics into 500
+// JSON errors with a reference, recorded with the chatbot's HandlePanic.
+func (a *GinAdapter) RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			err := a.chatbot.HandlePanic(value)
+			if !c.Writer.Written() {
+				a.respond(c, http.StatusInternalServerError, panicResponse(err))
+			}
+			c.Abort()
+		}()
 		c.Next()
 	}
 }
 
-// GetChatbotFromContext extracts the chatbot instance from Gin context.
+// GetChatbotFromContext extracts the chatbot instance from the request context.
 func GetChatbotFromContext(c *gin.Context) (*gochatbot.Chatbot, bool) {
-	bot, exists := c.Get("chatbot")
-	if !exists {
+	if c.Request == nil {
 		return nil, false
 	}
-
-	chatbotInstance, ok := bot.(*gochatbot.Chatbot)
-	return chatbotInstance, ok
+	return FromContext(c.Request.Context())
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -88,6 +89,15 @@ func TestGinAdapter_ChatHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectSuccess:  false,
 		},
+		{
+			name: "reserved context key",
+			requestBody: ChatRequest{
+				Message: "Hello",
+				Context: map[string]interface{}{"user_id": "bob"},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectSuccess:  false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -121,11 +131,223 @@ func TestGinAdapter_ChatHandler(t *testing.T) {
 				assert.Empty(t, response.Error)
 			} else {
 				assert.NotEmpty(t, response.Error)
+				assert.Equal(t, "invalid_input", response.Code)
 			}
 		})
 	}
 }
 
+func TestGinAdapter_ChatHandler_ReservedContextKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/chat", NewGinAdapter(setupTestBot()).ChatHandler())
+
+	keys := []string{
+		"user_id", "user_attributes", "persona", "conversation_id", "history",
+		"prompt", "system", "system_instructions", "raw", "context",
+		"sentiment", "sentiment_score", "emotions", "aggression_detected",
+		"links_filtered", "deescalate", "experiment", "variant",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			body, err := json.Marshal(ChatRequest{
+				Message: "Hello",
+				Context: map[string]interface{}{key: "client value"},
+			})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/chat", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response ChatResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "invalid_input", response.Code)
+			assert.Contains(t, response.Error, key)
+		})
+	}
+}
+
+func TestGinAdapter_ChatHandler_ConversationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	adapter := NewGinAdapter(setupTestBot())
+	router := gin.New()
+	router.POST("/chat", adapter.ChatHandler())
+
+	body, _ := json.Marshal(ChatRequest{Message: "Hello", ConversationID: "conv-1"})
+	req := httptest.NewRequest("POST", "/chat", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response ChatResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, "conv-1", response.ConversationID)
+}
+
+func TestGinAdapter_ChatHandler_Messages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	adapter := NewGinAdapter(setupTestBot())
+	router := gin.New()
+	router.POST("/chat", adapter.ChatHandler())
+
+	tests := []struct {
+		name           string
+		messages       []gochatbot.ChatMessage
+		expectedStatus int
+	}{
+		{
+			name: "history",
+			messages: []gochatbot.ChatMessage{
+				{Role: "user", Content: "Hi"},
+				{Role: "assistant", Content: "Hello!"},
+				{Role: "user", Content: "Thanks"},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "last message from assistant",
+			messages:       []gochatbot.ChatMessage{{Role: "assistant", Content: "Hello!"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ChatRequest{Messages: tt.messages})
+			req := httptest.NewRequest("POST", "/chat", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestGinAdapter_ChatHandler_ResponseMarshaler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	envelope := func(response *gochatbot.ChatResponse) ([]byte, error) {
+		return json.Marshal(map[string]interface{}{
+			"data":  map[string]string{"content": response.Reply},
+			"error": response.Error,
+		})
+	}
+	adapter := NewGinAdapter(setupTestBot()).WithResponseMarshaler(envelope)
+	router := gin.New()
+	router.POST("/chat", adapter.ChatHandler())
+
+	for _, tt := range []struct {
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{body: `{"message": "Hello"}`, expectedStatus: http.StatusOK},
+		{body: `{}`, expectedStatus: http.StatusBadRequest, expectedError: "Message is required"},
+	} {
+		req := httptest.NewRequest("POST", "/chat", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, tt.expectedStatus, w.Code)
+		var response struct {
+			Data  map[string]string `json:"data"`
+			Error string            `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, tt.expectedError, response.Error)
+		assert.Equal(t, tt.expectedError == "", response.Data["content"] != "")
+	}
+}
+
+// failingModel fails with an error carrying internal details.
+type failingModel struct{}
+
+func (failingModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return "", errors.New("dial tcp 10.0.0.7:5432: connection refused")
+}
+
+func (failingModel) Name() string     { return "failing" }
+func (failingModel) Provider() string { return "test" }
+
+func TestGinAdapter_ChatHandlerHidesErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bot, err := gochatbot.New(config.Default(), gochatbot.WithModel(failingModel{}))
+	require.NoError(t, err)
+	router := gin.New()
+	router.POST("/chat", NewGinAdapter(bot).ChatHandler())
+
+	req := httptest.NewRequest("POST", "/chat", bytes.NewBufferString(`{"message": "Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	var response ChatResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Failed to process request", response.Error)
+	assert.Equal(t, "internal_error", response.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.7")
+}
+
+func TestGinAdapter_LoggingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var entries []gochatbot.RequestLogEntry
+	logger := gochatbot.NewRequestLogger(gochatbot.RequestLogOptions{
+		Log:    func(entry gochatbot.RequestLogEntry) { entries = append(entries, entry) },
+		Bodies: true,
+	})
+	adapter := NewGinAdapter(setupTestBot())
+	router := gin.New()
+	router.Use(adapter.LoggingMiddleware(logger))
+	router.POST("/chat", adapter.ChatHandler())
+
+	req := httptest.NewRequest("POST", "/chat", bytes.NewBufferString(`{"message": "Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, entries, 1)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, "/chat", entries[0].Path)
+	assert.Equal(t, "free-model", entries[0].Model)
+	assert.Contains(t, entries[0].RequestBody, `"message":"[REDACTED]"`)
+	assert.Contains(t, entries[0].ResponseBody, `"response":"[REDACTED]"`)
+}
+
+func TestGinAdapter_RecoveryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bot := setupTestBot()
+	adapter := NewGinAdapter(bot)
+	router := gin.New()
+	router.Use(adapter.RecoveryMiddleware())
+	router.GET("/panic", func(c *gin.Context) { panic("handler failure") })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	var response ChatResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, "Internal server error", response.Error)
+	assert.NotEmpty(t, response.Reference)
+	assert.Equal(t, uint64(1), bot.Panics())
+}
+
 func TestGinAdapter_HealthHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -252,7 +474,8 @@ func TestGetChatbotFromContext(t *testing.T) {
 
 	// Test with chatbot in context
 	c, _ := gin.CreateTestContext(httptest.NewRecorder())
-	c.Set("chatbot", bot)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request = c.Request.WithContext(NewContext(c.Request.Context(), bot))
 
 	retrievedBot, exists := GetChatbotFromContext(c)
 	assert.True(t, exists)
@@ -267,7 +490,8 @@ func TestGetChatbotFromContext(t *testing.T) {
 
 	// Test with wrong type in context
 	c3, _ := gin.CreateTestContext(httptest.NewRecorder())
-	c3.Set("chatbot", "not a chatbot")
+	c3.Request = httptest.NewRequest("GET", "/", nil)
+	c3.Request = c3.Request.WithContext(context.WithValue(c3.Request.Context(), ChatbotContextKey, "not a chatbot"))
 
 	retrievedBot3, exists3 := GetChatbotFromContext(c3)
 	assert.False(t, exists3)
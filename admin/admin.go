@@ -0,0 +1,178 @@
+// Package admin computes aggregate statistics for admin dashboards: messages
+// per day, active users, average reply latency, estimated token spend by
+// model and moderation hit counts.
+//
+// A Collector counts chat events and guardrails decisions as they happen.
+// Register it as the chatbot's event publisher and audit logger, then serve
+// its statistics with a Manager, which takes message and user counts from
+// the conversation store when one is given:
+//
+//	collector := admin.NewCollector()
+//	guard, _ := guardrails.New(policy, guardrails.WithAuditLogger(collector))
+//	bot, _ := gochatbot.New(cfg, gochatbot.WithEventPublisher(collector), gochatbot.WithGuardrails(guard))
+//	http.Handle("/admin/", http.StripPrefix("/admin", admin.NewManager(collector, admin.WithStore(store)).Handler()))
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Report holds the statistics of a range of days.
+type Report struct {
+	// From and To are the first and last day covered, as YYYY-MM-DD.
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	Messages    int `json:"messages"`
+	ActiveUsers int `json:"active_users"`
+	Replies     int `json:"replies"`
+	// AverageLatencyMs is the mean time the model took to reply.
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+	// Models holds estimated token spend by model name.
+	Models map[string]ModelUsage `json:"models"`
+	// Moderation counts messages changed or flagged by the message filter,
+	// by reason, such as "content_filtered" or "aggression_detected".
+	Moderation map[string]int `json:"moderation"`
+	// Guardrails counts guardrails rule matches by rule name.
+	Guardrails map[string]int `json:"guardrails"`
+
+	// Days holds the statistics of each day with activity, oldest first.
+	Days []Day `json:"days"`
+}
+
+// Day holds one day's statistics.
+type Day struct {
+	Date             string  `json:"date"`
+	Messages         int     `json:"messages"`
+	ActiveUsers      int     `json:"active_users"`
+	Replies          int     `json:"replies"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+}
+
+// ModelUsage is the estimated token spend of a model.
+type ModelUsage struct {
+	Replies          int `json:"replies"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithStore takes message and active user counts from store instead of the
+// collector, so they include messages stored before the process started or
+// by other instances.
+func WithStore(store database.ActivityReporter) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+// Manager computes reports from a Collector and, optionally, a store.
+type Manager struct {
+	collector *Collector
+	store     database.ActivityReporter
+}
+
+// NewManager creates a manager reporting the statistics of collector.
+func NewManager(collector *Collector, opts ...Option) *Manager {
+	m := &Manager{collector: collector}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Stats returns the statistics of the UTC days from from through to.
+func (m *Manager) Stats(ctx context.Context, from, to time.Time) (*Report, error) {
+	from = startOfDay(from)
+	to = startOfDay(to)
+	if to.Before(from) {
+		return nil, errors.New("the range ends before it starts")
+	}
+
+	report := &Report{
+		From:       from.Format(database.DayFormat),
+		To:         to.Format(database.DayFormat),
+		Models:     make(map[string]ModelUsage),
+		Moderation: make(map[string]int),
+		Guardrails: make(map[string]int),
+	}
+	days := m.collector.days(report.From, report.To)
+
+	if m.store != nil {
+		activity, err := m.store.DailyActivity(ctx, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get activity: %w", err)
+		}
+		// The store's counts replace the collector's
+		for _, day := range days {
+			day.messages = 0
+			day.users = nil
+		}
+		for _, day := range activity {
+			stats, ok := days[day.Date]
+			if !ok {
+				stats = newDayStats()
+				days[day.Date] = stats
+			}
+			stats.messages = day.Messages
+			stats.users = make(map[string]bool, len(day.UserIDs))
+			for _, userID := range day.UserIDs {
+				stats.users[userID] = true
+			}
+		}
+	}
+
+	users := make(map[string]bool)
+	var latency int64
+	for date, stats := range days {
+		day := Day{Date: date, Messages: stats.messages, ActiveUsers: len(stats.users), Replies: stats.replies}
+		if stats.replies > 0 {
+			day.AverageLatencyMs = float64(stats.latency) / float64(stats.replies)
+		}
+		report.Days = append(report.Days, day)
+
+		report.Messages += stats.messages
+		report.Replies += stats.replies
+		latency += stats.latency
+		for userID := range stats.users {
+			users[userID] = true
+		}
+		for model, usage := range stats.models {
+			total := report.Models[model]
+			total.Replies += usage.Replies
+			total.PromptTokens += usage.PromptTokens
+			total.CompletionTokens += usage.CompletionTokens
+			total.TotalTokens += usage.TotalTokens
+			report.Models[model] = total
+		}
+		for reason, count := range stats.moderation {
+			report.Moderation[reason] += count
+		}
+		for rule, count := range stats.guardrails {
+			report.Guardrails[rule] += count
+		}
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Date < report.Days[j].Date
+	})
+	report.ActiveUsers = len(users)
+	if report.Replies > 0 {
+		report.AverageLatencyMs = float64(latency) / float64(report.Replies)
+	}
+	return report, nil
+}
+
+// startOfDay returns the start of t's UTC day.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
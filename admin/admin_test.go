@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/guardrails"
+)
+
+var today = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// newTestCollector returns a collector with two days of activity.
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+	collector := NewCollector()
+	collector.now = func() time.Time { return today }
+	ctx := context.Background()
+	yesterday := today.AddDate(0, 0, -1)
+
+	publish := func(eventType events.Type, at time.Time, userID string, data map[string]interface{}) {
+		event := events.New(eventType, data)
+		event.Time = at
+		event.UserID = userID
+		if err := collector.Publish(ctx, event); err != nil {
+			t.Fatalf("failed to publish: %v", err)
+		}
+	}
+	publish(events.MessageReceived, yesterday, "u1", nil)
+	publish(events.ReplyGenerated, yesterday, "u1", map[string]interface{}{"model": "gpt-4o", "latency_ms": int64(300), "prompt_tokens": 10, "completion_tokens": 20})
+	publish(events.MessageReceived, today, "u1", nil)
+	publish(events.MessageReceived, today, "u2", nil)
+	publish(events.ModerationFlagged, today, "u2", map[string]interface{}{"reasons": []string{"content_filtered", "links_filtered"}})
+	// Decoded from JSON, e.g. replayed from a broker
+	publish(events.ReplyGenerated, today, "u1", map[string]interface{}{"model": "gpt-4o", "latency_ms": float64(100), "prompt_tokens": float64(5), "completion_tokens": float64(5)})
+	publish(events.ReplyGenerated, today, "u2", map[string]interface{}{"model": "claude", "latency_ms": int64(200), "prompt_tokens": 1, "completion_tokens": 2})
+	collector.Log(ctx, guardrails.AuditEntry{Time: today, Rule: "pii"})
+	collector.Log(ctx, guardrails.AuditEntry{Time: today, Rule: "pii"})
+	return collector
+}
+
+func TestStats(t *testing.T) {
+	manager := NewManager(newTestCollector(t))
+
+	report, err := manager.Stats(context.Background(), today.AddDate(0, 0, -6), today)
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	if report.From != "2026-03-04" || report.To != "2026-03-10" {
+		t.Errorf("unexpected range %s to %s", report.From, report.To)
+	}
+	if report.Messages != 3 || report.ActiveUsers != 2 || report.Replies != 3 || report.AverageLatencyMs != 200 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	if usage := report.Models["gpt-4o"]; usage.Replies != 2 || usage.PromptTokens != 15 || usage.TotalTokens != 40 {
+		t.Errorf("unexpected gpt-4o usage %+v", usage)
+	}
+	if report.Moderation["content_filtered"] != 1 || report.Moderation["links_filtered"] != 1 || report.Guardrails["pii"] != 2 {
+		t.Errorf("unexpected moderation %v, guardrails %v", report.Moderation, report.Guardrails)
+	}
+	if len(report.Days) != 2 || report.Days[0].Date != "2026-03-09" || report.Days[1].ActiveUsers != 2 || report.Days[1].AverageLatencyMs != 150 {
+		t.Errorf("unexpected days %+v", report.Days)
+	}
+
+	report, err = manager.Stats(context.Background(), today, today)
+	if err != nil || report.Messages != 2 || len(report.Days) != 1 {
+		t.Errorf("expected only today, got %+v, %v", report, err)
+	}
+
+	if _, err := manager.Stats(context.Background(), today, today.AddDate(0, 0, -1)); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+}
+
+func TestStats_WithStore(t *testing.T) {
+	store := chatbottest.NewStore()
+	ctx := context.Background()
+	_ = store.CreateConversation(ctx, &database.Conversation{ID: "c1", UserID: "u3"})
+	_ = store.AddMessage(ctx, &database.Message{ID: "m1", ConversationID: "c1", Role: "user", Content: "Hi"})
+	_ = store.AddMessage(ctx, &database.Message{ID: "m2", ConversationID: "c1", Role: "assistant", Content: "Hello"})
+
+	collector := newTestCollector(t)
+	now := time.Now()
+	report, err := NewManager(collector, WithStore(store)).Stats(ctx, today.AddDate(0, 0, -1), now)
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	// Messages and users come from the store, the rest from the collector
+	if report.Messages != 2 || report.ActiveUsers != 1 || report.Replies != 3 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+
+	store.FailNext(context.DeadlineExceeded)
+	if _, err := NewManager(collector, WithStore(store)).Stats(ctx, now, now); err == nil {
+		t.Error("expected the store error")
+	}
+}
+
+func TestCollectorRetention(t *testing.T) {
+	collector := NewCollector(WithRetention(2))
+	collector.now = func() time.Time { return today }
+	ctx := context.Background()
+
+	for _, at := range []time.Time{today.AddDate(0, 0, -3), today.AddDate(0, 0, -1), today} {
+		event := events.New(events.MessageReceived, nil)
+		event.Time = at
+		_ = collector.Publish(ctx, event)
+	}
+	if days := collector.days("2000-01-01", "2100-01-01"); len(days) != 2 {
+		t.Errorf("expected 2 days kept, got %d", len(days))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := NewManager(newTestCollector(t)).Handler()
+
+	tests := []struct {
+		query  string
+		status int
+		days   int
+	}{
+		{"", http.StatusOK, 2},
+		{"?days=1", http.StatusOK, 1},
+		{"?from=2026-03-09&to=2026-03-09", http.StatusOK, 1},
+		{"?days=0", http.StatusBadRequest, 0},
+		{"?from=yesterday", http.StatusBadRequest, 0},
+		{"?from=2026-03-10&to=2026-03-01", http.StatusBadRequest, 0},
+		{"?from=2020-01-01", http.StatusBadRequest, 0},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats"+test.query, nil))
+		if rec.Code != test.status {
+			t.Errorf("%q: expected %d, got %d: %s", test.query, test.status, rec.Code, rec.Body)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(report.Days) != test.days {
+			t.Errorf("%q: expected %d days, got %+v", test.query, test.days, report.Days)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/guardrails"
+)
+
+// DefaultRetention is how many days a Collector keeps by default.
+const DefaultRetention = 90
+
+// CollectorOption configures a Collector.
+type CollectorOption func(*Collector)
+
+// WithRetention sets how many days of statistics a Collector keeps.
+func WithRetention(days int) CollectorOption {
+	return func(c *Collector) {
+		if days > 0 {
+			c.retention = days
+		}
+	}
+}
+
+// Collector counts chat events and guardrails decisions per UTC day. It is
+// an events.Publisher and a guardrails.AuditLogger, so it can be passed to
+// WithEventPublisher and guardrails.WithAuditLogger directly. To also send
+// events to a broker, publish to both from an events.PublisherFunc.
+//
+// Counts are kept in memory, so they start from zero when the process
+// starts and cover only this instance.
+type Collector struct {
+	retention int
+	now       func() time.Time
+
+	mutex sync.Mutex
+	stats map[string]*dayStats
+}
+
+var (
+	_ events.Publisher       = (*Collector)(nil)
+	_ guardrails.AuditLogger = (*Collector)(nil)
+)
+
+// dayStats holds one day's counts.
+type dayStats struct {
+	messages   int
+	users      map[string]bool
+	replies    int
+	latency    int64
+	models     map[string]ModelUsage
+	moderation map[string]int
+	guardrails map[string]int
+}
+
+func newDayStats() *dayStats {
+	return &dayStats{
+		users:      make(map[string]bool),
+		models:     make(map[string]ModelUsage),
+		moderation: make(map[string]int),
+		guardrails: make(map[string]int),
+	}
+}
+
+// NewCollector creates an empty collector.
+func NewCollector(opts ...CollectorOption) *Collector {
+	c := &Collector{
+		retention: DefaultRetention,
+		now:       time.Now,
+		stats:     make(map[string]*dayStats),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Publish implements events.Publisher by counting the event.
+func (c *Collector) Publish(ctx context.Context, event events.Event) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.day(event.Time)
+	switch event.Type {
+	case events.MessageReceived:
+		stats.messages++
+		if event.UserID != "" {
+			stats.users[event.UserID] = true
+		}
+	case events.ReplyGenerated:
+		stats.replies++
+		stats.latency += number(event.Data["latency_ms"])
+		model, _ := event.Data["model"].(string)
+		usage := stats.models[model]
+		usage.Replies++
+		usage.PromptTokens += int(number(event.Data["prompt_tokens"]))
+		usage.CompletionTokens += int(number(event.Data["completion_tokens"]))
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		stats.models[model] = usage
+	case events.ModerationFlagged:
+		switch reasons := event.Data["reasons"].(type) {
+		case []string:
+			for _, reason := range reasons {
+				stats.moderation[reason]++
+			}
+		case []interface{}:
+			for _, reason := range reasons {
+				if reason, ok := reason.(string); ok {
+					stats.moderation[reason]++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements events.Publisher.
+func (c *Collector) Close() error {
+	return nil
+}
+
+// Log implements guardrails.AuditLogger by counting the entry's rule.
+func (c *Collector) Log(ctx context.Context, entry guardrails.AuditEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.day(entry.Time).guardrails[entry.Rule]++
+}
+
+// day returns the stats of t's day, creating them and dropping days past
+// the retention if needed. The caller must hold the mutex.
+func (c *Collector) day(t time.Time) *dayStats {
+	if t.IsZero() {
+		t = c.now()
+	}
+	date := t.UTC().Format(database.DayFormat)
+	stats, ok := c.stats[date]
+	if ok {
+		return stats
+	}
+
+	stats = newDayStats()
+	c.stats[date] = stats
+	oldest := startOfDay(c.now()).AddDate(0, 0, 1-c.retention).Format(database.DayFormat)
+	for date := range c.stats {
+		if date < oldest {
+			delete(c.stats, date)
+		}
+	}
+	return stats
+}
+
+// days returns copies of the stats of the days from from through to, both
+// formatted as YYYY-MM-DD.
+func (c *Collector) days(from, to string) map[string]*dayStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	days := make(map[string]*dayStats)
+	for date, stats := range c.stats {
+		if date < from || date > to {
+			continue
+		}
+		day := newDayStats()
+		day.messages = stats.messages
+		day.replies = stats.replies
+		day.latency = stats.latency
+		for userID := range stats.users {
+			day.users[userID] = true
+		}
+		for model, usage := range stats.models {
+			day.models[model] = usage
+		}
+		for reason, count := range stats.moderation {
+			day.moderation[reason] = count
+		}
+		for rule, count := range stats.guardrails {
+			day.guardrails[rule] = count
+		}
+		days[date] = day
+	}
+	return days
+}
+
+// number reads an event value that may have been decoded from JSON.
+func number(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
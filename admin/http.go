@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// DefaultDays is how many days, ending today, a stats request covers by
+// default.
+const DefaultDays = 7
+
+// maxDays limits the range of a stats request.
+const maxDays = 366
+
+// Handler returns the statistics API. Mount it under a prefix with
+// http.StripPrefix and put it behind your own authentication:
+//
+//	GET /stats                           the last 7 days, including today
+//	GET /stats?days=30                   the last 30 days
+//	GET /stats?from=2025-01-01&to=2025-01-31
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", m.handleStats)
+	return mux
+}
+
+func (m *Manager) handleStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	to := m.collector.now()
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse(database.DayFormat, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+
+	days := DefaultDays
+	if value := query.Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDays {
+			writeError(w, http.StatusBadRequest, "Invalid days")
+			return
+		}
+		days = parsed
+	}
+	from := to.AddDate(0, 0, 1-days)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse(database.DayFormat, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if startOfDay(to).Before(startOfDay(from)) {
+		writeError(w, http.StatusBadRequest, "The range ends before it starts")
+		return
+	}
+	if to.Sub(from) >= maxDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, "Date range is too long")
+		return
+	}
+
+	report, err := m.Stats(r.Context(), from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,92 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.rumenx.com/chatbot/agents"
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// RunAgent runs task as a multi-step agent that may call the tools in
+// registry. The task goes through rate limiting and message filtering like
+// Ask, the configured prompt is passed as agent instructions, and every tool
+// call is published as an events.ToolCalled event. Use agents.WithStepHandler
+// to stream intermediate steps. A panic in a tool is recovered and returned
+// as a *PanicError. Tool arguments are validated and repaired as counted
+// by RepairStats. Tool calls are made for the user asking, with the role
+// from their "role" attribute, so a registry's tools.Authorizer can check
+// them. When the caller set with tools.WithCaller names a conversation and
+// the conversation store implements StateConversations, tools can keep
+// state in it with tools.StateFromContext; the state is saved after the
+// run if it changed.
+func (c *Chatbot) RunAgent(ctx context.Context, task string, registry *tools.Registry, opts ...agents.Option) (result *agents.Result, err error) {
+	if task == "" {
+		return nil, fmt.Errorf("%w: task cannot be empty", chaterrors.ErrInvalidInput)
+	}
+	defer func() {
+		if value := recover(); value != nil {
+			result, err = nil, c.HandlePanic(value)
+		}
+	}()
+
+	// Turn new runs away during maintenance
+	if err := c.checkMaintenance(ctx); err != nil {
+		return nil, err
+	}
+
+	// Identify the user set with NewUserContext for rate limiting and events
+	askContext := make(map[string]interface{})
+	ctx = identify(ctx, askContext)
+	ctx = c.scopeRateLimit(ctx, config.RateLimitRouteAgent, askContext)
+	ctx = identifyCaller(ctx, askContext)
+
+	// Apply rate limiting
+	if c.rateLimit != nil {
+		if err := c.rateLimit.Allow(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit exceeded: %w", err)
+		}
+	}
+
+	// Apply message filtering
+	filtered, err := c.filter.Handle(ctx, task)
+	if err != nil {
+		return nil, fmt.Errorf("message filtering failed: %w", err)
+	}
+	c.publishReceived(ctx, task, filtered, askContext)
+
+	defaults := []agents.Option{agents.WithRepairCounter(&c.toolArgumentStats)}
+	if cfg := c.GetConfig(); cfg != nil && cfg.Prompt != "" {
+		defaults = append(defaults, agents.WithInstructions(cfg.Prompt))
+	}
+	if c.publisher != nil {
+		defaults = append(defaults, agents.WithStepHandler(func(step agents.Step) {
+			if step.Tool == "" {
+				return
+			}
+			c.publishEvent(ctx, events.ToolCalled, askContext, map[string]interface{}{
+				"tool":        step.Tool,
+				"input":       string(step.Input),
+				"observation": step.Observation,
+				"step":        step.Index,
+			})
+		}))
+	}
+
+	// Let tools keep state in the conversation across turns
+	ctx, saveState, err := c.loadState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := agents.New(c.model, registry, append(defaults, opts...)...)
+	result, err = agent.Run(ctx, filtered.Message)
+	if saveErr := saveState(context.WithoutCancel(ctx)); saveErr != nil {
+		err = errors.Join(err, saveErr)
+	}
+	return result, err
+}
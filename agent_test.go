@@ -0,0 +1,97 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/agents"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// scriptedModel returns its replies in order.
+type scriptedModel struct {
+	replies []string
+	prompts []string
+}
+
+func (m *scriptedModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	prompt, _ := context["prompt"].(string)
+	m.prompts = append(m.prompts, prompt)
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return reply, nil
+}
+
+func (m *scriptedModel) Name() string     { return "scripted" }
+func (m *scriptedModel) Provider() string { return "test" }
+
+func TestRunAgent(t *testing.T) {
+	model := &scriptedModel{replies: []string{
+		`{"thought":"check the clock","action":"time","action_input":{}}`,
+		`{"final_answer":"It is noon."}`,
+	}}
+	clock := tools.New("time", "Current time", nil, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "12:00", nil
+	})
+
+	cfg := config.Default()
+	cfg.Prompt = "You are a concise assistant."
+	publisher := &recordingPublisher{}
+	bot, err := New(cfg, WithModel(model), WithEventPublisher(publisher))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	var steps int
+	result, err := bot.RunAgent(context.Background(), "What time is it?", tools.NewRegistry(clock),
+		agents.WithStepHandler(func(agents.Step) { steps++ }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Answer != "It is noon." || steps != 2 {
+		t.Errorf("unexpected result %+v after %d streamed steps", result, steps)
+	}
+
+	types := publisher.types()
+	if len(types) != 2 || types[0] != events.MessageReceived || types[1] != events.ToolCalled {
+		t.Errorf("unexpected events: %v", types)
+	}
+	if len(model.prompts) == 0 || !strings.Contains(model.prompts[0], "You are a concise assistant.") {
+		t.Error("expected the configured prompt in the agent instructions")
+	}
+
+	if _, err := bot.RunAgent(context.Background(), "", nil); err == nil {
+		t.Error("expected error for empty task")
+	}
+}
+
+func TestRunAgentToolPermissions(t *testing.T) {
+	model := &scriptedModel{replies: []string{
+		`{"action":"refund","action_input":{}}`,
+		`{"final_answer":"Refunds need a staff member."}`,
+	}}
+	refund := tools.New("refund", "Refunds an order", nil, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "refunded", nil
+	})
+	registry := tools.NewRegistry(refund)
+	var denied tools.Caller
+	registry.SetAuthorizer(tools.Permissions{Roles: map[string][]string{"staff": {"refund"}}},
+		func(ctx context.Context, caller tools.Caller, tool string, err error) { denied = caller })
+
+	bot, err := New(config.Default(), WithModel(model))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	ctx := NewUserContext(context.Background(), User{ID: "u1", Attributes: map[string]interface{}{"role": "customer"}})
+	result, err := bot.RunAgent(ctx, "Refund order 1234", registry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(result.Steps[0].Observation, "Error: permission denied") || denied.Role != "customer" || denied.UserID != "u1" {
+		t.Errorf("expected the customer's call to be denied, got %q for %+v", result.Steps[0].Observation, denied)
+	}
+}
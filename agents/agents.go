@@ -0,0 +1,303 @@
+// Package agents runs ReAct-style agent loops: the model plans, calls a tool,
+// observes the result and iterates until it can give a final answer. Runs
+// are bounded by a step budget, an optional cost budget and an allow-list of
+// tools, and every step can be streamed to the caller as it completes.
+//
+//	agent := agents.New(model, registry, agents.WithMaxSteps(5),
+//		agents.WithStepHandler(func(step agents.Step) { log.Printf("%+v", step) }))
+//	result, err := agent.Run(ctx, "What is the status of order 1234?")
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/schema"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// ErrStepBudget is returned when the agent has not answered within the
+// maximum number of steps.
+var ErrStepBudget = errors.New("agent step budget exhausted")
+
+// ErrCostBudget is returned when the agent has spent more than its maximum cost.
+var ErrCostBudget = errors.New("agent cost budget exhausted")
+
+// maxObservation bounds the tool output fed back to the model.
+const maxObservation = 4000
+
+// Step is one iteration of the agent loop.
+type Step struct {
+	// Index is the 1-based step number.
+	Index int `json:"index"`
+	// Thought is the model's reasoning for this step.
+	Thought string `json:"thought,omitempty"`
+	// Tool and Input describe the action taken, if any.
+	Tool  string          `json:"tool,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// Observation is the tool result or the error reported to the model.
+	Observation string `json:"observation,omitempty"`
+	// Answer is set on the final step.
+	Answer string `json:"answer,omitempty"`
+	// Cost is the cost of the step's model call.
+	Cost float64 `json:"cost"`
+}
+
+// Result is the outcome of an agent run.
+type Result struct {
+	Answer string  `json:"answer"`
+	Steps  []Step  `json:"steps"`
+	Cost   float64 `json:"cost"`
+}
+
+// Option configures an Agent.
+type Option func(*Agent)
+
+// WithMaxSteps sets the step budget (default 8).
+func WithMaxSteps(steps int) Option {
+	return func(a *Agent) {
+		a.maxSteps = steps
+	}
+}
+
+// WithAllowedTools restricts the agent to the named tools. By default every
+// tool in the registry may be used.
+func WithAllowedTools(names ...string) Option {
+	return func(a *Agent) {
+		a.allowed = make(map[string]bool, len(names))
+		for _, name := range names {
+			a.allowed[name] = true
+		}
+	}
+}
+
+// WithMaxCost stops the run once the accumulated cost exceeds max. Zero
+// means no limit.
+func WithMaxCost(max float64) Option {
+	return func(a *Agent) {
+		a.maxCost = max
+	}
+}
+
+// WithCostFunc sets how the cost of a model call is computed from its prompt
+// and response. By default cost is the estimated number of tokens (about four
+// characters each).
+func WithCostFunc(fn func(prompt, response string) float64) Option {
+	return func(a *Agent) {
+		a.cost = fn
+	}
+}
+
+// WithStepHandler calls fn after every step, e.g. to stream progress to a
+// client. Several handlers may be added.
+func WithStepHandler(fn func(step Step)) Option {
+	return func(a *Agent) {
+		a.handlers = append(a.handlers, fn)
+	}
+}
+
+// WithMaxRepairs sets how many times in a row the model may be asked to
+// fix tool arguments that do not match the tool's schema before the run
+// fails (default 2).
+func WithMaxRepairs(repairs int) Option {
+	return func(a *Agent) {
+		a.maxRepairs = repairs
+	}
+}
+
+// WithRepairCounter counts the tool calls validated and repaired in
+// counter, for metrics. One counter can be shared by several agents.
+func WithRepairCounter(counter *schema.Counter) Option {
+	return func(a *Agent) {
+		a.repairs = counter
+	}
+}
+
+// WithInstructions adds instructions to the agent's system prompt, such as
+// the persona or domain rules.
+func WithInstructions(instructions string) Option {
+	return func(a *Agent) {
+		a.instructions = instructions
+	}
+}
+
+// Agent runs tasks with a model and a set of tools.
+type Agent struct {
+	model        models.Model
+	tools        *tools.Registry
+	maxSteps     int
+	maxCost      float64
+	allowed      map[string]bool
+	cost         func(prompt, response string) float64
+	handlers     []func(Step)
+	instructions string
+	maxRepairs   int
+	repairs      *schema.Counter
+}
+
+// New creates an agent. registry may be nil for an agent without tools.
+func New(model models.Model, registry *tools.Registry, opts ...Option) *Agent {
+	if registry == nil {
+		registry = tools.NewRegistry()
+	}
+	a := &Agent{
+		model:      model,
+		tools:      registry,
+		maxSteps:   8,
+		cost:       estimateTokens,
+		maxRepairs: 2,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Run executes the agent loop for task. When a budget runs out, the partial
+// result is returned together with ErrStepBudget or ErrCostBudget. Tool
+// arguments are checked against the tool's schema, and the problems are
+// sent back to the model to fix; when the repairs run out, the partial
+// result is returned with an error matching schema.ErrInvalid.
+func (a *Agent) Run(ctx context.Context, task string) (*Result, error) {
+	result := &Result{}
+	system := a.systemPrompt(ctx)
+
+	// invalid counts the tool calls in a row with invalid arguments
+	invalid := 0
+	record := func(valid bool) {
+		if a.repairs != nil {
+			a.repairs.Record(min(invalid, a.maxRepairs), valid)
+		}
+		invalid = 0
+	}
+	defer func() {
+		if invalid > 0 {
+			record(false)
+		}
+	}()
+
+	for index := 1; index <= a.maxSteps; index++ {
+		prompt := a.prompt(task, result.Steps)
+		response, err := a.model.Ask(ctx, prompt, map[string]interface{}{"prompt": system})
+		if err != nil {
+			return result, fmt.Errorf("agent step %d failed: %w", index, err)
+		}
+
+		step := Step{Index: index, Cost: a.cost(system+prompt, response)}
+		result.Cost += step.Cost
+
+		decision, err := parseDecision(response)
+		switch {
+		case err != nil:
+			step.Thought = response
+			step.Observation = "Error: " + err.Error()
+		case decision.FinalAnswer != "":
+			step.Thought = decision.Thought
+			step.Answer = decision.FinalAnswer
+			result.Answer = decision.FinalAnswer
+		default:
+			step.Thought = decision.Thought
+			step.Tool = decision.Action
+			step.Input = decision.ActionInput
+			if err := a.checkArguments(ctx, decision.Action, decision.ActionInput); err != nil {
+				invalid++
+				step.Observation = fmt.Sprintf("Error: invalid arguments: %v. Call the tool again with arguments matching its schema.", err)
+				if invalid > a.maxRepairs {
+					result.Steps = append(result.Steps, step)
+					for _, handler := range a.handlers {
+						handler(step)
+					}
+					return result, fmt.Errorf("agent step %d: invalid arguments for tool %q: %w", index, decision.Action, err)
+				}
+				break
+			}
+			if invalid > 0 {
+				record(true)
+			} else if a.repairs != nil {
+				a.repairs.Record(0, true)
+			}
+			step.Observation = a.callTool(ctx, decision.Action, decision.ActionInput)
+		}
+
+		result.Steps = append(result.Steps, step)
+		for _, handler := range a.handlers {
+			handler(step)
+		}
+
+		if step.Answer != "" {
+			return result, nil
+		}
+		if a.maxCost > 0 && result.Cost > a.maxCost {
+			return result, ErrCostBudget
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+	return result, ErrStepBudget
+}
+
+// callTool runs a tool and returns the observation for the model. Failures
+// are reported to the model so it can recover.
+func (a *Agent) callTool(ctx context.Context, name string, input json.RawMessage) string {
+	tool, ok := a.tool(name)
+	if !ok {
+		return fmt.Sprintf("Error: tool %q is not available", name)
+	}
+
+	output, err := tool.Call(ctx, arguments(input))
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	if len(output) > maxObservation {
+		output = output[:maxObservation] + "... (truncated)"
+	}
+	return output
+}
+
+// checkArguments validates the arguments of a tool call against the tool's
+// schema. Unavailable and denied tools are left to callTool.
+func (a *Agent) checkArguments(ctx context.Context, name string, input json.RawMessage) error {
+	tool, ok := a.tool(name)
+	if !ok || a.tools.Authorize(ctx, name) != nil {
+		return nil
+	}
+	return schema.ValidateJSON(tool.Parameters(), arguments(input))
+}
+
+// arguments returns the arguments of a tool call, which models may omit
+// for tools without any.
+func arguments(input json.RawMessage) json.RawMessage {
+	if len(input) == 0 || string(input) == "null" {
+		return json.RawMessage("{}")
+	}
+	return input
+}
+
+// tool returns a registered tool the agent is allowed to use.
+func (a *Agent) tool(name string) (tools.Tool, bool) {
+	if a.allowed != nil && !a.allowed[name] {
+		return nil, false
+	}
+	return a.tools.Get(name)
+}
+
+// availableTools lists the tools the agent may use, leaving out those the
+// registry does not permit the caller of ctx.
+func (a *Agent) availableTools(ctx context.Context) []tools.Tool {
+	var available []tools.Tool
+	for _, tool := range a.tools.Permitted(ctx) {
+		if a.allowed == nil || a.allowed[tool.Name()] {
+			available = append(available, tool)
+		}
+	}
+	return available
+}
+
+// estimateTokens approximates the token count of a model call.
+func estimateTokens(prompt, response string) float64 {
+	return float64(len(prompt)+len(response)) / 4
+}
@@ -0,0 +1,209 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/schema"
+	"go.rumenx.com/chatbot/tools"
+)
+
+func orderTool(calls *int) tools.Tool {
+	return tools.New("order_status", "Looks up an order",
+		tools.Object(map[string]interface{}{"id": tools.String("Order ID")}, "id"),
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			*calls++
+			var in struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", err
+			}
+			if in.ID != "1234" {
+				return "", errors.New("order not found")
+			}
+			return "shipped", nil
+		})
+}
+
+func TestRunCallsToolsAndAnswers(t *testing.T) {
+	var calls int
+	model := chatbottest.NewMockModel(
+		`{"thought":"Look up the order","action":"order_status","action_input":{"id":"1234"}}`,
+		"```json\n{\"thought\":\"It shipped\",\"final_answer\":\"Your order has shipped.\"}\n```",
+	)
+
+	var streamed []Step
+	agent := New(model, tools.NewRegistry(orderTool(&calls)),
+		WithStepHandler(func(step Step) { streamed = append(streamed, step) }))
+
+	result, err := agent.Run(context.Background(), "Where is order 1234?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Answer != "Your order has shipped." || calls != 1 {
+		t.Errorf("unexpected result %+v after %d tool calls", result, calls)
+	}
+	if len(streamed) != 2 || streamed[0].Tool != "order_status" || streamed[0].Observation != "shipped" {
+		t.Errorf("unexpected streamed steps: %+v", streamed)
+	}
+	if result.Cost <= 0 || result.Cost != streamed[0].Cost+streamed[1].Cost {
+		t.Errorf("expected step costs to add up, got %v", result.Cost)
+	}
+
+	// The second call sees the observation and the tool list
+	calls2 := model.Calls()
+	if !strings.Contains(calls2[1].Message, "Observation: shipped") {
+		t.Errorf("expected observation in follow-up prompt: %q", calls2[1].Message)
+	}
+	if system, _ := calls2[0].Context["prompt"].(string); !strings.Contains(system, "order_status: Looks up an order") {
+		t.Errorf("expected tools in system prompt: %q", system)
+	}
+}
+
+func TestRunReportsToolErrorsToModel(t *testing.T) {
+	var calls int
+	model := chatbottest.NewMockModel(
+		`{"action":"order_status","action_input":{"id":"9"}}`,
+		`{"action":"refund","action_input":{}}`,
+		`{"thought": oops}`,
+		`{"final_answer":"I could not find that order."}`,
+	)
+
+	result, err := New(model, tools.NewRegistry(orderTool(&calls))).Run(context.Background(), "Where is order 9?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	observations := []string{"Error: order not found", `Error: tool "refund" is not available`, "Error: "}
+	for i, want := range observations {
+		if !strings.HasPrefix(result.Steps[i].Observation, want) {
+			t.Errorf("step %d: expected observation %q, got %q", i+1, want, result.Steps[i].Observation)
+		}
+	}
+	if result.Answer != "I could not find that order." {
+		t.Errorf("unexpected answer %q", result.Answer)
+	}
+}
+
+func TestRunPlainTextIsFinalAnswer(t *testing.T) {
+	result, err := New(chatbottest.NewMockModel("Paris."), nil).Run(context.Background(), "Capital of France?")
+	if err != nil || result.Answer != "Paris." || len(result.Steps) != 1 {
+		t.Errorf("unexpected result %+v, %v", result, err)
+	}
+}
+
+func TestRunGuardrails(t *testing.T) {
+	action := `{"action":"order_status","action_input":{"id":"1234"}}`
+
+	t.Run("step budget", func(t *testing.T) {
+		var calls int
+		model := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: action})
+		result, err := New(model, tools.NewRegistry(orderTool(&calls)), WithMaxSteps(3)).Run(context.Background(), "loop")
+		if !errors.Is(err, ErrStepBudget) || len(result.Steps) != 3 {
+			t.Errorf("expected step budget error after 3 steps, got %v with %d steps", err, len(result.Steps))
+		}
+	})
+
+	t.Run("cost budget", func(t *testing.T) {
+		var calls int
+		model := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: action})
+		agent := New(model, tools.NewRegistry(orderTool(&calls)),
+			WithCostFunc(func(prompt, response string) float64 { return 1 }), WithMaxCost(1.5))
+		result, err := agent.Run(context.Background(), "loop")
+		if !errors.Is(err, ErrCostBudget) || result.Cost != 2 {
+			t.Errorf("expected cost budget error at cost 2, got %v at %v", err, result.Cost)
+		}
+	})
+
+	t.Run("allowed tools", func(t *testing.T) {
+		var calls int
+		model := chatbottest.NewMockModel(action, `{"final_answer":"done"}`)
+		agent := New(model, tools.NewRegistry(orderTool(&calls)), WithAllowedTools("search"))
+		result, err := agent.Run(context.Background(), "task")
+		if err != nil || calls != 0 || !strings.Contains(result.Steps[0].Observation, "not available") {
+			t.Errorf("expected disallowed tool not to run, got %+v, %v", result, err)
+		}
+		first, _ := model.Calls()[0].Context["prompt"].(string)
+		if strings.Contains(first, "order_status") {
+			t.Error("expected disallowed tool to be hidden from the prompt")
+		}
+	})
+}
+
+func TestRunModelError(t *testing.T) {
+	model := chatbottest.NewMockModel()
+	model.Fail(errors.New("provider down"))
+	_, err := New(model, nil).Run(context.Background(), "task")
+	if err == nil || !strings.Contains(err.Error(), "provider down") {
+		t.Errorf("expected model error, got %v", err)
+	}
+}
+
+func TestRunRepairsToolArguments(t *testing.T) {
+	var calls int
+	var counter schema.Counter
+	model := chatbottest.NewMockModel(
+		`{"action":"order_status","action_input":{"order":"1234"}}`,
+		`{"action":"order_status","action_input":{"id":"1234"}}`,
+		`{"final_answer":"Your order has shipped."}`,
+	)
+
+	result, err := New(model, tools.NewRegistry(orderTool(&calls)), WithRepairCounter(&counter)).
+		Run(context.Background(), "Where is order 1234?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 || result.Answer != "Your order has shipped." {
+		t.Errorf("expected the repaired call to run, got %+v after %d calls", result, calls)
+	}
+	if want := "Error: invalid arguments: does not match the schema: $.id: is required"; !strings.HasPrefix(result.Steps[0].Observation, want) {
+		t.Errorf("expected the problems in the observation, got %q", result.Steps[0].Observation)
+	}
+	if stats := counter.Stats(); stats != (schema.Stats{Outputs: 1, Repairs: 1, Repaired: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRunRepairsRunOut(t *testing.T) {
+	var calls int
+	var counter schema.Counter
+	invalid := `{"action":"order_status","action_input":{"id":1234}}`
+	model := chatbottest.NewMockModel(invalid, invalid)
+
+	result, err := New(model, tools.NewRegistry(orderTool(&calls)), WithMaxRepairs(1), WithRepairCounter(&counter)).
+		Run(context.Background(), "Where is order 1234?")
+	if !errors.Is(err, schema.ErrInvalid) || calls != 0 || len(result.Steps) != 2 {
+		t.Errorf("expected the run to fail after one repair, got %v with %+v", err, result)
+	}
+	if stats := counter.Stats(); stats != (schema.Stats{Outputs: 1, Repairs: 1, Failed: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRunToolPermissions(t *testing.T) {
+	var calls int
+	registry := tools.NewRegistry(orderTool(&calls), tools.New("refund", "Refunds an order", nil,
+		func(ctx context.Context, args json.RawMessage) (string, error) { return "refunded", nil }))
+	registry.SetAuthorizer(tools.Permissions{Roles: map[string][]string{"customer": {"order_status"}}}, nil)
+	model := chatbottest.NewMockModel(
+		`{"action":"refund","action_input":{}}`,
+		`{"final_answer":"I cannot refund orders."}`,
+	)
+
+	ctx := tools.WithCaller(context.Background(), tools.Caller{Role: "customer"})
+	result, err := New(model, registry).Run(ctx, "Refund order 1234")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(result.Steps[0].Observation, "Error: permission denied") {
+		t.Errorf("expected the denial as a tool error, got %q", result.Steps[0].Observation)
+	}
+	if system, _ := model.Calls()[0].Context["prompt"].(string); strings.Contains(system, "refund:") || !strings.Contains(system, "order_status:") {
+		t.Errorf("expected only permitted tools in the system prompt: %q", system)
+	}
+}
@@ -0,0 +1,91 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// decision is the model's choice for one step.
+type decision struct {
+	Thought     string          `json:"thought"`
+	Action      string          `json:"action"`
+	ActionInput json.RawMessage `json:"action_input"`
+	FinalAnswer string          `json:"final_answer"`
+}
+
+// systemPrompt describes the response format and the available tools.
+func (a *Agent) systemPrompt(ctx context.Context) string {
+	var b strings.Builder
+	b.WriteString("You are an agent that solves tasks step by step. ")
+	if a.instructions != "" {
+		b.WriteString(a.instructions)
+		b.WriteString(" ")
+	}
+
+	available := a.availableTools(ctx)
+	if len(available) > 0 {
+		b.WriteString("\n\nYou can use these tools:\n")
+		for _, tool := range available {
+			schema, _ := json.Marshal(tool.Parameters())
+			fmt.Fprintf(&b, "- %s: %s Arguments: %s\n", tool.Name(), tool.Description(), schema)
+		}
+		b.WriteString(`
+Respond with a single JSON object and nothing else. To use a tool:
+{"thought": "why this tool helps", "action": "<tool name>", "action_input": {<arguments>}}
+When you know the answer:
+{"thought": "how you got the answer", "final_answer": "<answer for the user>"}`)
+	} else {
+		b.WriteString(`
+Respond with a single JSON object and nothing else:
+{"thought": "how you got the answer", "final_answer": "<answer for the user>"}`)
+	}
+	return b.String()
+}
+
+// prompt renders the task and the steps taken so far.
+func (a *Agent) prompt(task string, steps []Step) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task: %s\n", task)
+	for _, step := range steps {
+		fmt.Fprintf(&b, "\nStep %d\n", step.Index)
+		if step.Thought != "" {
+			fmt.Fprintf(&b, "Thought: %s\n", step.Thought)
+		}
+		if step.Tool != "" {
+			fmt.Fprintf(&b, "Action: %s %s\n", step.Tool, step.Input)
+		}
+		if step.Observation != "" {
+			fmt.Fprintf(&b, "Observation: %s\n", step.Observation)
+		}
+	}
+	if len(steps) > 0 {
+		b.WriteString("\nContinue with the next step.")
+	}
+	return b.String()
+}
+
+// parseDecision extracts the JSON decision from a model response. Models
+// often wrap JSON in prose or code fences, so the outermost object is used.
+// A response without any JSON object is taken as the final answer.
+func parseDecision(response string) (*decision, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		answer := strings.TrimSpace(response)
+		if answer == "" {
+			return nil, fmt.Errorf("empty response")
+		}
+		return &decision{FinalAnswer: answer}, nil
+	}
+
+	var d decision
+	if err := json.Unmarshal([]byte(response[start:end+1]), &d); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %v", err)
+	}
+	if d.FinalAnswer == "" && d.Action == "" {
+		return nil, fmt.Errorf("response has neither an action nor a final_answer")
+	}
+	return &d, nil
+}
@@ -0,0 +1,113 @@
+package gochatbot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/models"
+)
+
+// analyticsTimeout bounds exporting an analytics event.
+const analyticsTimeout = 10 * time.Second
+
+// WithAnalytics exports the chatbot's analytics events (see the analytics
+// package) to exporter, in the background so requests never wait on it.
+// Failures are logged. Without it, the exporters enabled in the
+// configuration's analytics section are used.
+func WithAnalytics(exporter analytics.Exporter) Option {
+	return func(c *Chatbot) {
+		c.analytics = exporter
+	}
+}
+
+// tracking reports whether analytics events are wanted.
+func (c *Chatbot) tracking() bool {
+	return c.analytics != nil || c.hooks.OnAnalytics != nil
+}
+
+// track reports an analytics event to Hooks.OnAnalytics and the exporter,
+// filling in the user and conversation from the request context and the
+// chatbot's name as the "bot" property.
+func (c *Chatbot) track(ctx context.Context, name string, askContext map[string]interface{}, properties map[string]interface{}) {
+	if !c.tracking() {
+		return
+	}
+
+	if c.name != "" {
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties["bot"] = c.name
+	}
+	event := analytics.New(name, properties)
+	event.UserID, _ = askContext["user_id"].(string)
+	event.ConversationID, _ = askContext["conversation_id"].(string)
+
+	if c.hooks.OnAnalytics != nil {
+		c.hooks.OnAnalytics(event)
+	}
+	if c.analytics == nil {
+		return
+	}
+	// Export even if the request itself was cancelled or timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), analyticsTimeout)
+	go func() {
+		defer cancel()
+		defer c.recoverBackground()
+		if err := c.analytics.Export(ctx, event); err != nil {
+			c.logf("analytics: failed to export %s event: %v", event.Name, err)
+		}
+	}()
+}
+
+// trackChatStarted tracks a chat request accepted on route.
+func (c *Chatbot) trackChatStarted(ctx context.Context, route, message string, askContext map[string]interface{}) {
+	c.track(ctx, analytics.ChatStarted, askContext, map[string]interface{}{
+		"route":          route,
+		"message_length": len(message),
+	})
+}
+
+// trackFallback tracks answering other than with the chatbot's model.
+func (c *Chatbot) trackFallback(ctx context.Context, reason string, model models.Model, askContext map[string]interface{}) {
+	properties := map[string]interface{}{"reason": reason}
+	if model != nil {
+		properties["model"] = model.Name()
+	}
+	c.track(ctx, analytics.FallbackUsed, askContext, properties)
+}
+
+// Feedback is a user's rating of a reply.
+type Feedback struct {
+	ConversationID string
+	// MessageID identifies the rated reply, if the client knows it.
+	MessageID string
+	// Score rates the reply, e.g. 1 for a thumbs up and -1 for a thumbs
+	// down.
+	Score float64
+	// Comment is the user's explanation, if any.
+	Comment string
+}
+
+// RecordFeedback tracks a user's rating of a reply as a feedback_given
+// analytics event. The user is the one set on ctx with NewUserContext.
+// Without analytics it does nothing.
+func (c *Chatbot) RecordFeedback(ctx context.Context, feedback Feedback) error {
+	if feedback.ConversationID == "" && feedback.MessageID == "" {
+		return fmt.Errorf("%w: feedback needs a conversation or message ID", chaterrors.ErrInvalidInput)
+	}
+	askContext := map[string]interface{}{"conversation_id": feedback.ConversationID}
+	identify(ctx, askContext)
+	properties := map[string]interface{}{"score": feedback.Score}
+	if feedback.MessageID != "" {
+		properties["message_id"] = feedback.MessageID
+	}
+	if feedback.Comment != "" {
+		properties["comment"] = feedback.Comment
+	}
+	c.track(ctx, analytics.FeedbackGiven, askContext, properties)
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package analytics defines the chatbot's product analytics events and
+// exports them to analytics services. The schema is stable: events and
+// their properties are only ever added, so dashboards built on them keep
+// working. Events carry no message content, only sizes and outcomes.
+//
+// NewSegment, NewPostHog and NewHTTP export to Segment, PostHog and any
+// HTTP endpoint without extra dependencies; FromConfig builds the ones
+// enabled in the configuration:
+//
+//	exporter, _ := analytics.FromConfig(cfg.Analytics)
+//	bot, _ := gochatbot.New(cfg, gochatbot.WithAnalytics(exporter))
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the version of the event schema.
+const SchemaVersion = 1
+
+// Events tracked by the chatbot.
+const (
+	// ChatStarted is tracked when a chat request is accepted, before the
+	// model answers. Properties: route ("chat" or "stream") and
+	// message_length.
+	ChatStarted = "chat_started"
+	// ReplyGenerated is tracked when the model has answered. Properties:
+	// model, provider, latency_ms, reply_length, prompt_tokens and
+	// completion_tokens (estimated), and experiment and variant for
+	// replies from A/B tests.
+	ReplyGenerated = "reply_generated"
+	// FeedbackGiven is tracked when a user rates a reply. Properties:
+	// score, and message_id and comment if given.
+	FeedbackGiven = "feedback_given"
+	// FallbackUsed is tracked when the chatbot answers other than with its
+	// model. Properties: reason, one of the Fallback* reasons, and model,
+	// faq_id and score for FAQ answers, and score for cached answers.
+	FallbackUsed = "fallback_used"
+)
+
+// Reasons of FallbackUsed events.
+const (
+	// FallbackFastModel means the fast model answered because the deadline
+	// budget ran low.
+	FallbackFastModel = "fast_model"
+	// FallbackRefusal means a guardrail refused the message with a canned
+	// reply.
+	FallbackRefusal = "guardrail_refusal"
+	// FallbackFAQ means the message matched an FAQ entry and was answered
+	// with its curated answer.
+	FallbackFAQ = "faq"
+	// FallbackAnswerCache means the message was answered with the cached
+	// answer to a similar question.
+	FallbackAnswerCache = "answer_cache"
+)
+
+// Event is an analytics event.
+type Event struct {
+	// ID is unique per event, so exporters can deduplicate retries.
+	ID             string                 `json:"id"`
+	Name           string                 `json:"event"`
+	SchemaVersion  int                    `json:"schema_version"`
+	Time           time.Time              `json:"time"`
+	UserID         string                 `json:"user_id,omitempty"`
+	ConversationID string                 `json:"conversation_id,omitempty"`
+	Properties     map[string]interface{} `json:"properties,omitempty"`
+}
+
+// New creates an event with a unique ID and the current time.
+func New(name string, properties map[string]interface{}) Event {
+	return Event{
+		ID:            uuid.New().String(),
+		Name:          name,
+		SchemaVersion: SchemaVersion,
+		Time:          time.Now().UTC(),
+		Properties:    properties,
+	}
+}
+
+// DistinctID returns who the event is attributed to: the user, falling
+// back to the conversation and then the event itself.
+func (e Event) DistinctID() string {
+	switch {
+	case e.UserID != "":
+		return e.UserID
+	case e.ConversationID != "":
+		return e.ConversationID
+	default:
+		return e.ID
+	}
+}
+
+// properties returns the event's properties with the schema version and
+// conversation, for services without fields of their own for them.
+func (e Event) properties() map[string]interface{} {
+	properties := make(map[string]interface{}, len(e.Properties)+2)
+	for key, value := range e.Properties {
+		properties[key] = value
+	}
+	properties["schema_version"] = e.SchemaVersion
+	if e.ConversationID != "" {
+		properties["conversation_id"] = e.ConversationID
+	}
+	return properties
+}
+
+// Exporter sends events to an analytics service.
+type Exporter interface {
+	// Export sends a single event.
+	Export(ctx context.Context, event Event) error
+
+	// Close flushes pending events and releases resources.
+	Close() error
+}
+
+// ExporterFunc adapts a function to the Exporter interface.
+type ExporterFunc func(ctx context.Context, event Event) error
+
+// Export implements Exporter.
+func (f ExporterFunc) Export(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Close implements Exporter.
+func (f ExporterFunc) Close() error {
+	return nil
+}
+
+// Multi returns an exporter sending each event to every exporter. Failures
+// are joined into one error; Close closes them all.
+func Multi(exporters ...Exporter) Exporter {
+	return multi(exporters)
+}
+
+type multi []Exporter
+
+// Export implements Exporter.
+func (m multi) Export(ctx context.Context, event Event) error {
+	var errs []error
+	for _, exporter := range m {
+		if err := exporter.Export(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements Exporter.
+func (m multi) Close() error {
+	var errs []error
+	for _, exporter := range m {
+		if err := exporter.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postJSON posts payload and expects a 2xx response.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}, authorize func(*http.Request)) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorize != nil {
+		authorize(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("analytics endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// defaultClient returns client, or a client with a 10s timeout.
+func defaultClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return client
+}
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// captureServer records the path, headers and JSON body of the last request.
+func captureServer(t *testing.T, status int) (*httptest.Server, *http.Request, map[string]interface{}) {
+	t.Helper()
+	var request http.Request
+	body := map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = *r
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &request, body
+}
+
+func testEvent() Event {
+	event := New(ReplyGenerated, map[string]interface{}{"model": "gpt-4o"})
+	event.ConversationID = "c1"
+	return event
+}
+
+func TestSegment(t *testing.T) {
+	server, request, body := captureServer(t, http.StatusOK)
+	segment, err := NewSegment(SegmentConfig{WriteKey: "wk", Endpoint: server.URL + "/v1/track"})
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	event := testEvent()
+	event.UserID = "u1"
+	if err := segment.Export(context.Background(), event); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if user, _, ok := request.BasicAuth(); !ok || user != "wk" {
+		t.Errorf("expected basic auth with the write key, got %q", request.Header.Get("Authorization"))
+	}
+	properties, _ := body["properties"].(map[string]interface{})
+	if body["event"] != ReplyGenerated || body["userId"] != "u1" || body["messageId"] != event.ID {
+		t.Errorf("unexpected payload: %v", body)
+	}
+	if properties["model"] != "gpt-4o" || properties["conversation_id"] != "c1" || properties["schema_version"] != float64(SchemaVersion) {
+		t.Errorf("unexpected properties: %v", properties)
+	}
+}
+
+func TestPostHog(t *testing.T) {
+	server, request, body := captureServer(t, http.StatusOK)
+	posthog, err := NewPostHog(PostHogConfig{APIKey: "phc", Host: server.URL + "/"})
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := posthog.Export(context.Background(), testEvent()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	properties, _ := body["properties"].(map[string]interface{})
+	if request.URL.Path != "/capture/" || body["api_key"] != "phc" || body["distinct_id"] != "c1" {
+		t.Errorf("unexpected request to %s: %v", request.URL.Path, body)
+	}
+	if properties["$process_person_profile"] != false {
+		t.Errorf("expected anonymous events not to create profiles, got %v", properties)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	server, request, body := captureServer(t, http.StatusAccepted)
+	sink, err := NewHTTP(HTTPConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := sink.Export(context.Background(), testEvent()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if request.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected the configured headers, got %v", request.Header)
+	}
+	if body["event"] != ReplyGenerated || body["conversation_id"] != "c1" || body["schema_version"] != float64(SchemaVersion) {
+		t.Errorf("expected the event as is, got %v", body)
+	}
+
+	failing, _, _ := captureServer(t, http.StatusBadRequest)
+	sink, _ = NewHTTP(HTTPConfig{URL: failing.URL})
+	if err := sink.Export(context.Background(), testEvent()); err == nil {
+		t.Error("expected an error for a failed export")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	exporter, err := FromConfig(config.AnalyticsConfig{Segment: config.SegmentAnalyticsConfig{WriteKey: "wk"}})
+	if err != nil || exporter != nil {
+		t.Errorf("expected no exporter when disabled, got %v, %v", exporter, err)
+	}
+
+	exporter, err = FromConfig(config.AnalyticsConfig{Enabled: true, PostHog: config.PostHogAnalyticsConfig{APIKey: "phc"}})
+	if _, ok := exporter.(*PostHog); err != nil || !ok {
+		t.Errorf("expected a PostHog exporter, got %T, %v", exporter, err)
+	}
+
+	exporter, err = FromConfig(config.AnalyticsConfig{
+		Enabled: true,
+		Segment: config.SegmentAnalyticsConfig{WriteKey: "wk"},
+		HTTP:    config.HTTPAnalyticsConfig{URL: "http://localhost/events"},
+	})
+	if exporters, ok := exporter.(multi); err != nil || !ok || len(exporters) != 2 {
+		t.Errorf("expected two exporters, got %T, %v", exporter, err)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	var exported int
+	ok := ExporterFunc(func(ctx context.Context, event Event) error {
+		exported++
+		return nil
+	})
+	failing := ExporterFunc(func(ctx context.Context, event Event) error {
+		return errors.New("unavailable")
+	})
+
+	if err := Multi(failing, ok).Export(context.Background(), testEvent()); err == nil || exported != 1 {
+		t.Errorf("expected every exporter to run and the failure reported, got %v after %d", err, exported)
+	}
+}
@@ -0,0 +1,194 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// Default endpoints of the services.
+const (
+	DefaultSegmentEndpoint = "https://api.segment.io/v1/track"
+	DefaultPostHogHost     = "https://us.i.posthog.com"
+)
+
+// SegmentConfig configures exporting to Segment.
+type SegmentConfig struct {
+	// WriteKey is the source's write key.
+	WriteKey string
+	// Endpoint is the tracking API URL (default DefaultSegmentEndpoint).
+	Endpoint string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// Segment exports events through Segment's tracking API. Events of users
+// are tracked with their user ID, others with an anonymous ID.
+type Segment struct {
+	config SegmentConfig
+	client *http.Client
+}
+
+// NewSegment creates a Segment exporter.
+func NewSegment(cfg SegmentConfig) (*Segment, error) {
+	if cfg.WriteKey == "" {
+		return nil, fmt.Errorf("segment write key is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultSegmentEndpoint
+	}
+	return &Segment{config: cfg, client: defaultClient(cfg.HTTPClient)}, nil
+}
+
+// Export implements Exporter.
+func (s *Segment) Export(ctx context.Context, event Event) error {
+	payload := map[string]interface{}{
+		"event":      event.Name,
+		"messageId":  event.ID,
+		"timestamp":  event.Time.Format(time.RFC3339Nano),
+		"properties": event.properties(),
+		"context":    map[string]interface{}{"library": map[string]string{"name": "go-chatbot"}},
+	}
+	if event.UserID != "" {
+		payload["userId"] = event.UserID
+	} else {
+		payload["anonymousId"] = event.DistinctID()
+	}
+	return postJSON(ctx, s.client, s.config.Endpoint, nil, payload, func(req *http.Request) {
+		req.SetBasicAuth(s.config.WriteKey, "")
+	})
+}
+
+// Close implements Exporter.
+func (s *Segment) Close() error {
+	return nil
+}
+
+// PostHogConfig configures exporting to PostHog.
+type PostHogConfig struct {
+	// APIKey is the project API key.
+	APIKey string
+	// Host is the PostHog instance (default DefaultPostHogHost).
+	Host string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// PostHog exports events through PostHog's capture API. Events without a
+// user do not create person profiles.
+type PostHog struct {
+	config PostHogConfig
+	client *http.Client
+}
+
+// NewPostHog creates a PostHog exporter.
+func NewPostHog(cfg PostHogConfig) (*PostHog, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("posthog API key is required")
+	}
+	if cfg.Host == "" {
+		cfg.Host = DefaultPostHogHost
+	}
+	return &PostHog{config: cfg, client: defaultClient(cfg.HTTPClient)}, nil
+}
+
+// Export implements Exporter.
+func (p *PostHog) Export(ctx context.Context, event Event) error {
+	properties := event.properties()
+	if event.UserID == "" {
+		properties["$process_person_profile"] = false
+	}
+	payload := map[string]interface{}{
+		"api_key":     p.config.APIKey,
+		"event":       event.Name,
+		"uuid":        event.ID,
+		"distinct_id": event.DistinctID(),
+		"timestamp":   event.Time.Format(time.RFC3339Nano),
+		"properties":  properties,
+	}
+	return postJSON(ctx, p.client, strings.TrimRight(p.config.Host, "/")+"/capture/", nil, payload, nil)
+}
+
+// Close implements Exporter.
+func (p *PostHog) Close() error {
+	return nil
+}
+
+// HTTPConfig configures exporting to a generic HTTP sink.
+type HTTPConfig struct {
+	// URL receives a POST with each Event as JSON.
+	URL string
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// HTTP exports events as JSON to any endpoint, such as a data warehouse
+// collector.
+type HTTP struct {
+	config HTTPConfig
+	client *http.Client
+}
+
+// NewHTTP creates an HTTP exporter.
+func NewHTTP(cfg HTTPConfig) (*HTTP, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("analytics URL is required")
+	}
+	return &HTTP{config: cfg, client: defaultClient(cfg.HTTPClient)}, nil
+}
+
+// Export implements Exporter.
+func (h *HTTP) Export(ctx context.Context, event Event) error {
+	return postJSON(ctx, h.client, h.config.URL, h.config.Headers, event, nil)
+}
+
+// Close implements Exporter.
+func (h *HTTP) Close() error {
+	return nil
+}
+
+// FromConfig returns the exporters cfg enables, combined with Multi, or nil
+// if analytics is disabled or none has its key or URL.
+func FromConfig(cfg config.AnalyticsConfig) (Exporter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var exporters []Exporter
+	if cfg.Segment.WriteKey != "" {
+		segment, err := NewSegment(SegmentConfig{WriteKey: cfg.Segment.WriteKey, Endpoint: cfg.Segment.Endpoint})
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, segment)
+	}
+	if cfg.PostHog.APIKey != "" {
+		posthog, err := NewPostHog(PostHogConfig{APIKey: cfg.PostHog.APIKey, Host: cfg.PostHog.Host})
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, posthog)
+	}
+	if cfg.HTTP.URL != "" {
+		sink, err := NewHTTP(HTTPConfig{URL: cfg.HTTP.URL, Headers: cfg.HTTP.Headers})
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, sink)
+	}
+
+	switch len(exporters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exporters[0], nil
+	default:
+		return Multi(exporters...), nil
+	}
+}
@@ -0,0 +1,134 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/budget"
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/models"
+)
+
+// recordingExporter collects exported analytics events.
+type recordingExporter struct {
+	mutex  sync.Mutex
+	events []analytics.Event
+	done   chan struct{}
+}
+
+func newRecordingExporter() *recordingExporter {
+	return &recordingExporter{done: make(chan struct{}, 16)}
+}
+
+func (e *recordingExporter) Export(ctx context.Context, event analytics.Event) error {
+	e.mutex.Lock()
+	e.events = append(e.events, event)
+	e.mutex.Unlock()
+	e.done <- struct{}{}
+	return nil
+}
+
+func (e *recordingExporter) Close() error { return nil }
+
+// wait waits for n exports.
+func (e *recordingExporter) wait(t *testing.T, n int) []analytics.Event {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-e.done:
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d exported events, got %d", n, i)
+		}
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]analytics.Event(nil), e.events...)
+}
+
+func TestAskTracksAnalytics(t *testing.T) {
+	exporter := newRecordingExporter()
+	var hooked []string
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()), WithAnalytics(exporter),
+		WithHooks(Hooks{OnAnalytics: func(event analytics.Event) { hooked = append(hooked, event.Name) }}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if _, err := bot.Ask(context.Background(), "Hello there", WithUserID("u1"), WithContext("conversation_id", "c1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(hooked) != 2 || hooked[0] != analytics.ChatStarted || hooked[1] != analytics.ReplyGenerated {
+		t.Errorf("expected the hook to see chat_started and reply_generated, got %v", hooked)
+	}
+	for _, event := range exporter.wait(t, 2) {
+		if event.UserID != "u1" || event.ConversationID != "c1" || event.SchemaVersion != analytics.SchemaVersion {
+			t.Errorf("unexpected event: %+v", event)
+		}
+		switch event.Name {
+		case analytics.ChatStarted:
+			if event.Properties["route"] != config.RateLimitRouteChat || event.Properties["message_length"] != len("Hello there") {
+				t.Errorf("unexpected chat_started properties: %v", event.Properties)
+			}
+		case analytics.ReplyGenerated:
+			if _, ok := event.Properties["reply"]; ok {
+				t.Errorf("expected no reply text in analytics, got %v", event.Properties)
+			}
+			if event.Properties["provider"] != "local" || event.Properties["reply_length"] == 0 {
+				t.Errorf("unexpected reply_generated properties: %v", event.Properties)
+			}
+		default:
+			t.Errorf("unexpected event %s", event.Name)
+		}
+	}
+}
+
+func TestFastModelTracksFallback(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	var fallback analytics.Event
+	bot, err := New(cfg, WithModel(&slowModel{name: "main"}), WithTimeout(time.Second),
+		WithBudget(budget.DefaultPlan), WithFastModel(&slowModel{name: "fast"}, 2*time.Second),
+		WithHooks(Hooks{OnAnalytics: func(event analytics.Event) {
+			if event.Name == analytics.FallbackUsed {
+				fallback = event
+			}
+		}}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if _, err := bot.Ask(context.Background(), "hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fallback.Properties["reason"] != analytics.FallbackFastModel || fallback.Properties["model"] != "fast" {
+		t.Errorf("expected a fast model fallback, got %+v", fallback)
+	}
+}
+
+func TestRecordFeedback(t *testing.T) {
+	exporter := newRecordingExporter()
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()), WithAnalytics(exporter))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	ctx := NewUserContext(context.Background(), User{ID: "u1"})
+	if err := bot.RecordFeedback(ctx, Feedback{ConversationID: "c1", MessageID: "m1", Score: -1, Comment: "Wrong answer"}); err != nil {
+		t.Fatalf("failed to record feedback: %v", err)
+	}
+	event := exporter.wait(t, 1)[0]
+	if event.Name != analytics.FeedbackGiven || event.UserID != "u1" || event.ConversationID != "c1" {
+		t.Errorf("unexpected feedback event: %+v", event)
+	}
+	if event.Properties["score"] != -1.0 || event.Properties["message_id"] != "m1" || event.Properties["comment"] != "Wrong answer" {
+		t.Errorf("unexpected feedback properties: %v", event.Properties)
+	}
+
+	if err := bot.RecordFeedback(ctx, Feedback{Score: 1}); !errors.Is(err, chaterrors.ErrInvalidInput) {
+		t.Errorf("expected feedback without IDs to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package gochatbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/answercache"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// answerCacheKey is the request context key of the answer cache lookup.
+const answerCacheKey = "answer_cache"
+
+// VersionedRetriever is a Retriever that knows the version of its
+// knowledge, so answers built from it are cached until it changes.
+type VersionedRetriever interface {
+	Retriever
+	// Version returns a value that changes whenever the knowledge
+	// searched for ctx does.
+	Version(ctx context.Context) string
+}
+
+// WithAnswerCache answers questions similar enough to one answered before
+// with the cached answer, skipping retrieval and the model call. Answers
+// are only reused under the same instructions and, with a
+// VersionedRetriever like KnowledgeRetriever, the same version of the
+// knowledge, so reindexing or refreshing it retires them. Requests with
+// history or recalled user memories are neither answered from nor stored
+// in the cache, since their answers are personal. AskStream serves cached
+// answers but only stores replies it does not stream.
+func WithAnswerCache(cache *answercache.Cache) Option {
+	return func(c *Chatbot) {
+		c.answers = cache
+	}
+}
+
+// cachedAnswer returns the cached answer to message, if any. On a miss the
+// lookup is kept in askContext for cacheAnswer. Cache failures are logged
+// and leave the message to the model.
+func (c *Chatbot) cachedAnswer(ctx context.Context, message string, askContext map[string]interface{}) (string, bool) {
+	if c.answers == nil || !c.shareable(askContext) {
+		return "", false
+	}
+	if collection, _ := askContext[collectionKey].(string); collection != "" {
+		ctx = embeddings.WithCollection(ctx, collection)
+	}
+	prompt, _ := askContext["prompt"].(string)
+	scope := sha256.Sum256([]byte(embeddings.CollectionFromContext(ctx) + "\x00" + prompt))
+	key := answercache.Key{Scope: hex.EncodeToString(scope[:])}
+	if versioned, ok := c.retriever.(VersionedRetriever); ok {
+		key.Version = versioned.Version(ctx)
+	}
+
+	lookup, err := c.answers.Lookup(ctx, message, key)
+	if err != nil {
+		c.logf("answer cache: %v", err)
+		return "", false
+	}
+	if !lookup.Hit {
+		askContext[answerCacheKey] = lookup
+		return "", false
+	}
+	c.track(ctx, analytics.FallbackUsed, askContext, map[string]interface{}{
+		"reason": analytics.FallbackAnswerCache, "score": lookup.Score,
+	})
+	return lookup.Answer, true
+}
+
+// shareable reports whether the request's answer may be shared: it has no
+// history and no memories of the user are recalled for it.
+func (c *Chatbot) shareable(askContext map[string]interface{}) bool {
+	if _, ok := askContext["history"]; ok {
+		return false
+	}
+	userID, _ := askContext["user_id"].(string)
+	return c.memory == nil || userID == ""
+}
+
+// cacheAnswer stores reply for the request's missed lookup.
+func (c *Chatbot) cacheAnswer(askContext map[string]interface{}, reply string) {
+	if lookup, _ := askContext[answerCacheKey].(*answercache.Lookup); lookup != nil {
+		c.answers.Store(lookup, reply)
+	}
+}
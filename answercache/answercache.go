@@ -0,0 +1,208 @@
+// Package answercache caches generated answers by the meaning of the
+// question, so a question asked again in other words is answered without
+// retrieval or a model call. Each answer is stored with the version of the
+// knowledge it was built from and is only reused while that version holds.
+//
+//	cache := answercache.New(provider, answercache.WithThreshold(0.95), answercache.WithTTL(24*time.Hour))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithRetriever(retriever, 5), gochatbot.WithAnswerCache(cache))
+package answercache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// Defaults of a Cache.
+const (
+	// DefaultThreshold is the similarity a question needs to reuse the
+	// answer to another. It is high, since questions a little apart often
+	// want different answers.
+	DefaultThreshold = 0.95
+	DefaultTTL       = 24 * time.Hour
+	DefaultMaxSize   = 1000
+)
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithThreshold sets the similarity a question needs to reuse an answer.
+func WithThreshold(threshold float64) Option {
+	return func(c *Cache) {
+		c.threshold = threshold
+	}
+}
+
+// WithTTL sets how long answers are reused.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxSize sets how many answers are kept; the least recently used give
+// way to new ones.
+func WithMaxSize(size int) Option {
+	return func(c *Cache) {
+		c.maxSize = size
+	}
+}
+
+// Key says which answers a question may reuse: those stored under the same
+// scope and version.
+type Key struct {
+	// Scope separates answers that must not mix, such as those written
+	// for different instructions or knowledge collections.
+	Scope string
+	// Version is the version of the knowledge behind the answers; answers
+	// of other versions in the scope are out of date and dropped.
+	Version string
+}
+
+// Lookup is the result of looking up a question. Pass it to Store with the
+// generated answer on a miss.
+type Lookup struct {
+	Key      Key
+	Question string
+	// Hit reports whether Answer is a cached answer, to Question, similar
+	// to the question by Score.
+	Hit    bool
+	Answer string
+	Score  float64
+
+	vector embeddings.Vector
+}
+
+// entry is a cached answer.
+type entry struct {
+	key      Key
+	question string
+	answer   string
+	vector   embeddings.Vector
+	norm     float64
+	stored   time.Time
+	used     time.Time
+}
+
+// Cache holds answers by question embedding. It is safe for concurrent use.
+type Cache struct {
+	provider  embeddings.EmbeddingProvider
+	threshold float64
+	ttl       time.Duration
+	maxSize   int
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries []*entry
+}
+
+// New creates a cache embedding questions with provider.
+func New(provider embeddings.EmbeddingProvider, opts ...Option) *Cache {
+	c := &Cache{
+		provider:  provider,
+		threshold: DefaultThreshold,
+		ttl:       DefaultTTL,
+		maxSize:   DefaultMaxSize,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Lookup finds the answer to the question most similar to question under
+// key, if any is similar enough.
+func (c *Cache) Lookup(ctx context.Context, question string, key Key) (*Lookup, error) {
+	vector, err := c.provider.EmbedSingle(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+	lookup := &Lookup{Key: key, Question: question, vector: vector}
+	norm := embeddings.Norm(vector)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.prune(key, now)
+	var best *entry
+	for _, e := range c.entries {
+		if e.key != key || e.norm == 0 || norm == 0 || len(e.vector) != len(vector) {
+			continue
+		}
+		if score := embeddings.DotProduct(vector, e.vector) / (norm * e.norm); score >= c.threshold && score > lookup.Score {
+			best, lookup.Score = e, score
+		}
+	}
+	if best != nil {
+		best.used = now
+		lookup.Hit, lookup.Answer = true, best.answer
+	}
+	return lookup, nil
+}
+
+// Store caches answer for the question of a missed lookup.
+func (c *Cache) Store(lookup *Lookup, answer string) {
+	if lookup == nil || lookup.Hit || answer == "" || c.maxSize <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.prune(lookup.Key, now)
+	for len(c.entries) >= c.maxSize {
+		c.evict()
+	}
+	c.entries = append(c.entries, &entry{
+		key:      lookup.Key,
+		question: lookup.Question,
+		answer:   answer,
+		vector:   lookup.vector,
+		norm:     embeddings.Norm(lookup.vector),
+		stored:   now,
+		used:     now,
+	})
+}
+
+// Invalidate drops every answer, e.g. after changing the instructions or
+// the knowledge in a way versions do not capture.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// Len returns the number of answers cached.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// prune drops expired answers and those of key's scope built from another
+// version. The caller holds the lock.
+func (c *Cache) prune(key Key, now time.Time) {
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if (c.ttl > 0 && now.Sub(e.stored) >= c.ttl) || (e.key.Scope == key.Scope && e.key.Version != key.Version) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	clear(c.entries[len(kept):])
+	c.entries = kept
+}
+
+// evict drops the least recently used answer. The caller holds the lock.
+func (c *Cache) evict() {
+	oldest := 0
+	for i, e := range c.entries {
+		if e.used.Before(c.entries[oldest].used) {
+			oldest = i
+		}
+	}
+	c.entries = append(c.entries[:oldest], c.entries[oldest+1:]...)
+}
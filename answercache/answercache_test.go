@@ -0,0 +1,135 @@
+package answercache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// keywordEmbeddings embeds texts as keyword counts so similarity is predictable.
+type keywordEmbeddings struct{ err error }
+
+var keywords = []string{"refund", "shipping", "password"}
+
+func (k keywordEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = make(embeddings.Vector, len(keywords))
+		for j, keyword := range keywords {
+			vectors[i][j] = float64(strings.Count(strings.ToLower(text), keyword))
+		}
+	}
+	return vectors, nil
+}
+
+func (k keywordEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	vectors, err := k.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (keywordEmbeddings) Dimensions() int  { return len(keywords) }
+func (keywordEmbeddings) Model() string    { return "keywords" }
+func (keywordEmbeddings) Provider() string { return "test" }
+
+func store(t *testing.T, cache *Cache, question, answer string, key Key) {
+	t.Helper()
+	lookup, err := cache.Lookup(context.Background(), question, key)
+	if err != nil || lookup.Hit {
+		t.Fatalf("expected a miss for %q, got %+v, %v", question, lookup, err)
+	}
+	cache.Store(lookup, answer)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	cache := New(keywordEmbeddings{})
+	key := Key{Scope: "support", Version: "1"}
+	store(t, cache, "How long does a refund take?", "Five days.", key)
+
+	lookup, err := cache.Lookup(ctx, "When will my refund arrive?", key)
+	if err != nil || !lookup.Hit || lookup.Answer != "Five days." || lookup.Score < 0.99 {
+		t.Fatalf("expected the cached answer, got %+v, %v", lookup, err)
+	}
+	if lookup, _ := cache.Lookup(ctx, "Do you offer free shipping?", key); lookup.Hit {
+		t.Errorf("expected dissimilar questions to miss, got %+v", lookup)
+	}
+	if lookup, _ := cache.Lookup(ctx, "When will my refund arrive?", Key{Scope: "sales", Version: "1"}); lookup.Hit {
+		t.Errorf("expected other scopes to miss, got %+v", lookup)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected other scopes to keep the answer, got %d answer(s)", cache.Len())
+	}
+
+	if lookup, _ := cache.Lookup(ctx, "When will my refund arrive?", Key{Scope: "support", Version: "2"}); lookup.Hit {
+		t.Errorf("expected a new version to miss, got %+v", lookup)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected answers of the old version to be dropped, got %d answer(s)", cache.Len())
+	}
+
+	store(t, cache, "How do I reset my password?", "Use the reset link.", key)
+	cache.Invalidate()
+	if cache.Len() != 0 {
+		t.Errorf("expected Invalidate to drop every answer, got %d answer(s)", cache.Len())
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	cache := New(keywordEmbeddings{}, WithTTL(time.Hour))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	store(t, cache, "How long does a refund take?", "Five days.", Key{})
+
+	now = now.Add(59 * time.Minute)
+	if lookup, _ := cache.Lookup(ctx, "Refund time?", Key{}); !lookup.Hit {
+		t.Errorf("expected a hit within the TTL, got %+v", lookup)
+	}
+	now = now.Add(time.Minute)
+	if lookup, _ := cache.Lookup(ctx, "Refund time?", Key{}); lookup.Hit || cache.Len() != 0 {
+		t.Errorf("expected the answer to expire, got %+v with %d answer(s)", lookup, cache.Len())
+	}
+}
+
+func TestCache_MaxSize(t *testing.T) {
+	ctx := context.Background()
+	cache := New(keywordEmbeddings{}, WithMaxSize(2))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	store(t, cache, "Refund?", "Five days.", Key{})
+	store(t, cache, "Shipping?", "Free over 50.", Key{})
+	if lookup, _ := cache.Lookup(ctx, "refund", Key{}); !lookup.Hit {
+		t.Fatalf("expected a hit, got %+v", lookup)
+	}
+	store(t, cache, "Password?", "Use the reset link.", Key{})
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 answers, got %d", cache.Len())
+	}
+	if lookup, _ := cache.Lookup(ctx, "shipping", Key{}); lookup.Hit {
+		t.Errorf("expected the least recently used answer to be evicted, got %+v", lookup)
+	}
+	if lookup, _ := cache.Lookup(ctx, "refund", Key{}); !lookup.Hit {
+		t.Errorf("expected the recently used answer to be kept, got %+v", lookup)
+	}
+}
+
+func TestCache_EmbeddingError(t *testing.T) {
+	cache := New(keywordEmbeddings{err: errors.New("unavailable")})
+	if _, err := cache.Lookup(context.Background(), "Refund?", Key{}); err == nil {
+		t.Error("expected the embedding error")
+	}
+}
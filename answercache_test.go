@@ -0,0 +1,66 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/answercache"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+func TestAnswerCache(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	store := embeddings.NewVectorStore(refundEmbeddings{})
+	if err := store.AddTexts(ctx, []string{"Refunds take five days."}, []map[string]interface{}{{"text": "Refunds take five days."}}); err != nil {
+		t.Fatalf("failed to add knowledge: %v", err)
+	}
+	model := &scriptedModel{replies: []string{"Five days.", "Hi there.", "Three days.", "As I said, three days."}}
+	var fallback analytics.Event
+	bot, err := New(cfg, WithModel(model), WithRetriever(KnowledgeRetriever(store), 3),
+		WithAnswerCache(answercache.New(refundEmbeddings{})),
+		WithHooks(Hooks{OnAnalytics: func(event analytics.Event) {
+			if event.Name == analytics.FallbackUsed {
+				fallback = event
+			}
+		}}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	ask := func(message string, options ...AskOption) string {
+		t.Helper()
+		reply, err := bot.Ask(ctx, message, options...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return reply
+	}
+	ask("How long do refunds take?")
+	if reply := ask("When is my refund due?"); reply != "Five days." || len(model.prompts) != 1 {
+		t.Errorf("expected the cached answer without a model call, got %q after %d call(s)", reply, len(model.prompts))
+	}
+	if fallback.Properties["reason"] != analytics.FallbackAnswerCache {
+		t.Errorf("expected an answer cache fallback, got %+v", fallback)
+	}
+	if reply := ask("Hello"); reply != "Hi there." {
+		t.Errorf("expected other questions to reach the model, got %q", reply)
+	}
+
+	// New knowledge retires the answers built from the old
+	if err := store.AddTexts(ctx, []string{"Refunds now take three days."}, []map[string]interface{}{{"text": "Refunds now take three days."}}); err != nil {
+		t.Fatalf("failed to add knowledge: %v", err)
+	}
+	if reply := ask("When is my refund due?"); reply != "Three days." || !strings.Contains(model.prompts[2], "three days") {
+		t.Errorf("expected a fresh answer from the new knowledge, got %q", reply)
+	}
+
+	history := []map[string]interface{}{{"role": "user", "content": "I returned the shoes."}}
+	if reply := ask("And my refund?", WithContext("history", history)); reply != "As I said, three days." {
+		t.Errorf("expected requests with history to skip the cache, got %q", reply)
+	}
+}
@@ -0,0 +1,389 @@
+// Package archive writes the transcripts of closed conversations to object
+// storage, such as S3 or Google Cloud Storage, for compliance and long-term
+// analysis. A conversation is closed once it has been idle for a while. An
+// Archiver periodically writes closed conversations as gzip-compressed JSON
+// Lines, one conversation with its messages per line, and records where
+// each went in the conversation's metadata:
+//
+//	archiver, err := archive.New(store, archive.BlobFunc(func(ctx context.Context, key string, body io.Reader) error {
+//		_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: body})
+//		return err
+//	}), archive.WithIdle(24*time.Hour))
+//	defer archiver.Close()
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Blob stores objects, such as a bucket of S3 or Google Cloud Storage.
+type Blob interface {
+	// Put writes the object at key, replacing any object there.
+	Put(ctx context.Context, key string, body io.Reader) error
+}
+
+// BlobFunc adapts a function, such as one calling an S3 or GCS client, to
+// the Blob interface.
+type BlobFunc func(ctx context.Context, key string, body io.Reader) error
+
+// Put implements Blob.
+func (f BlobFunc) Put(ctx context.Context, key string, body io.Reader) error {
+	return f(ctx, key, body)
+}
+
+// DirBlob is a Blob writing objects as files under a directory, for local
+// use and tests. Keys are slash-separated paths.
+type DirBlob string
+
+// Put implements Blob.
+func (d DirBlob) Put(ctx context.Context, key string, body io.Reader) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create object: %w", err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return file.Close()
+}
+
+// Defaults of an Archiver.
+const (
+	DefaultIdle      = 24 * time.Hour
+	DefaultInterval  = time.Hour
+	DefaultBatchSize = 500
+)
+
+// MetadataKey is the conversation metadata key of its Record.
+const MetadataKey = "archive"
+
+// Transcript is a line of an archive object.
+type Transcript struct {
+	Conversation *database.Conversation `json:"conversation"`
+	Messages     []*database.Message    `json:"messages"`
+}
+
+// Record tells where a conversation was archived. It is stored in the
+// conversation's metadata under MetadataKey.
+type Record struct {
+	// Key is the key of the object holding the transcript.
+	Key        string
+	ArchivedAt time.Time
+	// Messages is the number of messages archived, and LastMessageAt the
+	// time of the last one. A conversation with messages after it is
+	// archived again, in full.
+	Messages      int
+	LastMessageAt time.Time
+}
+
+// RecordOf returns the archive record of a conversation, if it has one.
+func RecordOf(conv *database.Conversation) (Record, bool) {
+	values, ok := conv.Metadata[MetadataKey].(map[string]interface{})
+	if !ok {
+		return Record{}, false
+	}
+	record := Record{}
+	record.Key, _ = values["key"].(string)
+	if archivedAt, ok := values["archived_at"].(string); ok {
+		record.ArchivedAt, _ = time.Parse(time.RFC3339Nano, archivedAt)
+	}
+	switch messages := values["messages"].(type) {
+	case int:
+		record.Messages = messages
+	case float64:
+		record.Messages = int(messages)
+	}
+	if last, ok := values["last_message_at"].(string); ok {
+		record.LastMessageAt, _ = time.Parse(time.RFC3339Nano, last)
+	}
+	return record, record.Key != ""
+}
+
+// metadata returns the record as conversation metadata.
+func (r Record) metadata() map[string]interface{} {
+	return map[string]interface{}{
+		"key":             r.Key,
+		"archived_at":     r.ArchivedAt.UTC().Format(time.RFC3339Nano),
+		"messages":        r.Messages,
+		"last_message_at": r.LastMessageAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// Option configures an Archiver.
+type Option func(*Archiver)
+
+// WithIdle sets how long a conversation has to be idle to be closed and
+// archived.
+func WithIdle(idle time.Duration) Option {
+	return func(a *Archiver) {
+		a.idle = idle
+	}
+}
+
+// WithInterval sets how often closed conversations are archived in the
+// background. A zero interval archives only when Archive is called, such
+// as from a cron job.
+func WithInterval(interval time.Duration) Option {
+	return func(a *Archiver) {
+		a.interval = interval
+	}
+}
+
+// WithBatchSize sets how many conversations an object holds at most.
+func WithBatchSize(size int) Option {
+	return func(a *Archiver) {
+		a.batchSize = size
+	}
+}
+
+// WithPrefix sets the prefix of object keys, such as "transcripts/". Keys
+// continue with the date and time of the archive, like
+// "2025/01/31/20250131T020000.000000000Z-1.jsonl.gz".
+func WithPrefix(prefix string) Option {
+	return func(a *Archiver) {
+		a.prefix = prefix
+	}
+}
+
+// WithErrorHandler sets a function called when a background archive fails.
+// By default errors are discarded; the conversations are archived by a
+// later run either way.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(a *Archiver) {
+		a.onError = fn
+	}
+}
+
+// Archiver archives closed conversations of a store to a Blob. Archived
+// conversations stay in the store, with their Record in the metadata.
+// Each run looks at the conversations updated since the previous run's
+// cutoff, so a process restart looks at every conversation once more;
+// those without new messages are skipped. Conversations are archived at
+// least once: if recording an archive fails, the conversation is archived
+// again.
+type Archiver struct {
+	store     database.ConversationStore
+	scanner   database.ConversationScanner
+	blob      Blob
+	idle      time.Duration
+	interval  time.Duration
+	batchSize int
+	prefix    string
+	onError   func(err error)
+	now       func() time.Time
+
+	archiving sync.Mutex
+	watermark time.Time // conversations updated before were looked at
+	objects   int
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates an Archiver of store, which must be a
+// database.ConversationScanner, such as a SQLConversationStore, and starts
+// archiving in the background unless the interval is zero.
+func New(store database.ConversationStore, blob Blob, opts ...Option) (*Archiver, error) {
+	scanner, ok := store.(database.ConversationScanner)
+	if !ok {
+		return nil, fmt.Errorf("%T does not scan conversations", store)
+	}
+	a := &Archiver{
+		store:     store,
+		scanner:   scanner,
+		blob:      blob,
+		idle:      DefaultIdle,
+		interval:  DefaultInterval,
+		batchSize: DefaultBatchSize,
+		onError:   func(error) {},
+		now:       time.Now,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.batchSize < 1 {
+		a.batchSize = 1
+	}
+
+	if a.interval > 0 {
+		go a.run()
+	} else {
+		close(a.done)
+	}
+	return a, nil
+}
+
+// Archive writes the conversations closed since the last run, returning how
+// many were archived.
+func (a *Archiver) Archive(ctx context.Context) (int, error) {
+	a.archiving.Lock()
+	defer a.archiving.Unlock()
+
+	cutoff := a.now().Add(-a.idle)
+	var batch []Transcript
+	var after *database.Conversation
+	archived := 0
+	for {
+		page, err := a.scanner.ScanConversations(ctx, a.watermark, cutoff, after, a.batchSize)
+		if err != nil {
+			return archived, err
+		}
+		for _, conv := range page {
+			messages, err := a.newMessages(ctx, conv)
+			if err != nil {
+				return archived, err
+			}
+			if messages == nil {
+				continue
+			}
+			batch = append(batch, Transcript{Conversation: conv, Messages: messages})
+			if len(batch) == a.batchSize {
+				if err := a.write(ctx, batch); err != nil {
+					return archived, err
+				}
+				archived += len(batch)
+				batch = batch[:0]
+			}
+		}
+		if len(page) < a.batchSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if len(batch) > 0 {
+		if err := a.write(ctx, batch); err != nil {
+			return archived, err
+		}
+		archived += len(batch)
+	}
+
+	a.watermark = cutoff
+	return archived, nil
+}
+
+// newMessages returns the messages of a conversation to archive, nil if it
+// has none or none since it was last archived.
+func (a *Archiver) newMessages(ctx context.Context, conv *database.Conversation) ([]*database.Message, error) {
+	record, archived := RecordOf(conv)
+	// Check the last message before reading them all
+	if querier, ok := a.store.(database.MessageQuerier); ok && archived {
+		last, err := querier.QueryMessages(ctx, conv.ID, database.MessageQuery{Limit: 1, Descending: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get messages: %w", err)
+		}
+		if len(last) == 0 || !last[0].CreatedAt.After(record.LastMessageAt) {
+			return nil, nil
+		}
+	}
+
+	messages, err := a.store.GetConversationHistory(ctx, conv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if len(messages) == 0 || (archived && !messages[len(messages)-1].CreatedAt.After(record.LastMessageAt)) {
+		return nil, nil
+	}
+	return messages, nil
+}
+
+// write puts a batch in a new object and records it in the conversations.
+func (a *Archiver) write(ctx context.Context, batch []Transcript) error {
+	var body bytes.Buffer
+	compressor := gzip.NewWriter(&body)
+	encoder := json.NewEncoder(compressor)
+	for _, transcript := range batch {
+		if err := encoder.Encode(transcript); err != nil {
+			return fmt.Errorf("failed to encode transcript: %w", err)
+		}
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("failed to compress transcripts: %w", err)
+	}
+
+	now := a.now().UTC()
+	a.objects++
+	key := fmt.Sprintf("%s%s/%s-%d.jsonl.gz", a.prefix, now.Format("2006/01/02"), now.Format("20060102T150405.000000000Z"), a.objects)
+	if err := a.blob.Put(ctx, key, &body); err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+
+	var errs []error
+	for _, transcript := range batch {
+		record := Record{
+			Key:           key,
+			ArchivedAt:    now,
+			Messages:      len(transcript.Messages),
+			LastMessageAt: transcript.Messages[len(transcript.Messages)-1].CreatedAt,
+		}
+		if err := a.record(ctx, transcript.Conversation.ID, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// record stores the archive record in the conversation's metadata, reading
+// the conversation again to keep metadata set meanwhile.
+func (a *Archiver) record(ctx context.Context, conversationID string, record Record) error {
+	conv, err := a.store.GetConversation(ctx, conversationID)
+	if errors.Is(err, database.ErrConversationNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if conv.Metadata == nil {
+		conv.Metadata = make(map[string]interface{})
+	}
+	conv.Metadata[MetadataKey] = record.metadata()
+	if err := a.store.UpdateConversation(ctx, conv); err != nil {
+		return fmt.Errorf("failed to record archive of %s: %w", conversationID, err)
+	}
+	return nil
+}
+
+// Close stops archiving in the background.
+func (a *Archiver) Close() error {
+	a.once.Do(func() {
+		close(a.stop)
+	})
+	<-a.done
+	return nil
+}
+
+// run archives every interval until Close.
+func (a *Archiver) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := a.Archive(context.Background()); err != nil {
+			a.onError(err)
+		}
+	}
+}
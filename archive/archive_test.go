@@ -0,0 +1,185 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/database"
+)
+
+// readTranscripts reads the transcripts of every object under dir.
+func readTranscripts(t *testing.T, dir string) map[string][]Transcript {
+	t.Helper()
+	objects := make(map[string][]Transcript)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		key, _ := filepath.Rel(dir, path)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			var transcript Transcript
+			if err := json.Unmarshal(scanner.Bytes(), &transcript); err != nil {
+				return err
+			}
+			objects[filepath.ToSlash(key)] = append(objects[filepath.ToSlash(key)], transcript)
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		t.Fatalf("failed to read objects: %v", err)
+	}
+	return objects
+}
+
+func TestArchiver(t *testing.T) {
+	ctx := context.Background()
+	store := chatbottest.NewStore()
+	manager := database.NewConversationManager(store)
+	var ids []string
+	for _, title := range []string{"Billing", "Shipping", "Returns"} {
+		conv, _, err := manager.CreateConversationWithMessage(ctx, "u1", title, "About "+title)
+		if err != nil {
+			t.Fatalf("failed to create conversation: %v", err)
+		}
+		ids = append(ids, conv.ID)
+	}
+	if _, err := manager.AddAssistantMessage(ctx, ids[0], "Your invoice is attached."); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	if err := store.CreateConversation(ctx, &database.Conversation{ID: "empty", UserID: "u1"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	dir := t.TempDir()
+	archiver, err := New(store, DirBlob(dir), WithInterval(0), WithBatchSize(2), WithPrefix("transcripts/"))
+	if err != nil {
+		t.Fatalf("failed to create archiver: %v", err)
+	}
+	defer archiver.Close()
+
+	// Nothing has been idle for a day yet
+	if archived, err := archiver.Archive(ctx); err != nil || archived != 0 {
+		t.Fatalf("expected nothing to archive, got %d, %v", archived, err)
+	}
+
+	archiver.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	archived, err := archiver.Archive(ctx)
+	if err != nil || archived != 3 {
+		t.Fatalf("expected 3 conversations archived, got %d, %v", archived, err)
+	}
+	objects := readTranscripts(t, dir)
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects of at most 2 conversations, got %v", objects)
+	}
+	for key, transcripts := range objects {
+		if !strings.HasPrefix(key, "transcripts/") || !strings.HasSuffix(key, ".jsonl.gz") {
+			t.Errorf("unexpected key %q", key)
+		}
+		for _, transcript := range transcripts {
+			if transcript.Conversation.ID == ids[0] && len(transcript.Messages) != 2 {
+				t.Errorf("expected both messages of the conversation, got %v", transcript.Messages)
+			}
+		}
+	}
+
+	conv, err := store.GetConversation(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("failed to get conversation: %v", err)
+	}
+	record, ok := RecordOf(conv)
+	if !ok || record.Messages != 2 || objects[record.Key] == nil || record.LastMessageAt.IsZero() {
+		t.Errorf("expected the archive recorded in the conversation, got %+v", record)
+	}
+	if _, ok := RecordOf(mustGet(t, store, "empty")); ok {
+		t.Error("expected the empty conversation not to be archived")
+	}
+
+	// A restarted archiver only archives conversations with new messages
+	if _, err := manager.AddUserMessage(ctx, ids[1], "Where is my parcel?"); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	restarted, err := New(store, DirBlob(dir), WithInterval(0))
+	if err != nil {
+		t.Fatalf("failed to create archiver: %v", err)
+	}
+	defer restarted.Close()
+	restarted.now = archiver.now
+	if archived, err := restarted.Archive(ctx); err != nil || archived != 1 {
+		t.Fatalf("expected the updated conversation archived again, got %d, %v", archived, err)
+	}
+	if record, _ := RecordOf(mustGet(t, store, ids[1])); record.Messages != 2 {
+		t.Errorf("expected the new archive of both messages, got %+v", record)
+	}
+}
+
+func TestArchiver_Errors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := New(struct{ database.ConversationStore }{}, DirBlob(t.TempDir())); err == nil {
+		t.Error("expected an error for a store that does not scan conversations")
+	}
+
+	store := chatbottest.NewStore()
+	manager := database.NewConversationManager(store)
+	conv, _, err := manager.CreateConversationWithMessage(ctx, "u1", "Billing", "Hi")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	failing := true
+	blob := BlobFunc(func(ctx context.Context, key string, body io.Reader) error {
+		if failing {
+			return errors.New("bucket unavailable")
+		}
+		_, err := io.Copy(io.Discard, body)
+		return err
+	})
+	archiver, err := New(store, blob, WithIdle(0), WithInterval(0))
+	if err != nil {
+		t.Fatalf("failed to create archiver: %v", err)
+	}
+	defer archiver.Close()
+	archiver.now = func() time.Time { return time.Now().Add(time.Second) }
+
+	if _, err := archiver.Archive(ctx); err == nil || !strings.Contains(err.Error(), "bucket unavailable") {
+		t.Fatalf("expected the put error, got %v", err)
+	}
+	if _, ok := RecordOf(mustGet(t, store, conv.ID)); ok {
+		t.Fatal("expected no record after a failed put")
+	}
+
+	// The failed conversations are tried again
+	failing = false
+	if archived, err := archiver.Archive(ctx); err != nil || archived != 1 {
+		t.Errorf("expected the conversation archived on the next run, got %d, %v", archived, err)
+	}
+}
+
+// mustGet returns a stored conversation.
+func mustGet(t *testing.T, store database.ConversationStore, id string) *database.Conversation {
+	t.Helper()
+	conv, err := store.GetConversation(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get conversation %s: %v", id, err)
+	}
+	return conv
+}
@@ -0,0 +1,166 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.rumenx.com/chatbot/speech"
+)
+
+// AudioResponse is the JSON response of the audio endpoint.
+type AudioResponse struct {
+	Transcript string `json:"transcript"`
+	Reply      string `json:"reply"`
+	// Audio is the base64-encoded spoken reply, when requested.
+	Audio            string `json:"audio,omitempty"`
+	AudioContentType string `json:"audio_content_type,omitempty"`
+	Error            string `json:"error,omitempty"`
+	Handoff          bool   `json:"handoff,omitempty"`
+}
+
+// WithSpeech enables voice conversations through HandleAudio. The
+// synthesizer is optional; without it replies are returned as text only.
+func WithSpeech(stt speech.Transcriber, tts speech.Synthesizer) Option {
+	return func(c *Chatbot) {
+		c.stt = stt
+		c.tts = tts
+	}
+}
+
+// Transcribe converts speech to text with the configured transcriber.
+func (c *Chatbot) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	if c.stt == nil {
+		return "", fmt.Errorf("speech-to-text is not configured")
+	}
+	return c.stt.Transcribe(ctx, audio, filename)
+}
+
+// Synthesize converts text to speech with the configured synthesizer.
+func (c *Chatbot) Synthesize(ctx context.Context, text string) (*speech.Audio, error) {
+	if c.tts == nil {
+		return nil, fmt.Errorf("text-to-speech is not configured")
+	}
+	return c.tts.Synthesize(ctx, text)
+}
+
+// HandleAudio handles voice chat requests. It accepts a multipart/form-data
+// POST with the recording in the "audio" field and an optional
+// "conversation_id" field, transcribes it and asks the chatbot.
+//
+// The reply is returned as an AudioResponse. If the request sets the
+// "speak" field to "true" it includes the spoken reply, base64-encoded; if
+// the Accept header asks for audio/*, the spoken reply is returned as the
+// response body instead.
+func (h *HTTPHandler) HandleAudio(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.writeAudioError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.chatbot.stt == nil {
+		h.writeAudioError(w, http.StatusNotImplemented, "Speech-to-text is not configured")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, speech.MaxAudioSize+1<<20)
+	file, header, err := r.FormFile("audio")
+	if err != nil {
+		h.writeAudioError(w, http.StatusBadRequest, "Missing audio file")
+		return
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(io.LimitReader(file, speech.MaxAudioSize+1))
+	if err != nil {
+		h.writeAudioError(w, http.StatusBadRequest, "Failed to read audio file")
+		return
+	}
+	if len(audio) > speech.MaxAudioSize {
+		h.writeAudioError(w, http.StatusRequestEntityTooLarge, "Audio file is too large")
+		return
+	}
+
+	wantsAudio := strings.HasPrefix(r.Header.Get("Accept"), "audio/")
+	speak := wantsAudio || r.FormValue("speak") == "true"
+	if speak && h.chatbot.tts == nil {
+		h.writeAudioError(w, http.StatusNotImplemented, "Text-to-speech is not configured")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), clientIPContextKey, h.getClientIP(r))
+	if h.chatbot.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.chatbot.timeout)
+		defer cancel()
+	}
+
+	transcript, err := h.chatbot.Transcribe(ctx, audio, header.Filename)
+	if err != nil {
+		h.writeAudioError(w, http.StatusBadGateway, "Failed to transcribe audio")
+		return
+	}
+	if strings.TrimSpace(transcript) == "" {
+		h.writeAudioError(w, http.StatusUnprocessableEntity, "No speech detected")
+		return
+	}
+
+	response := AudioResponse{Transcript: transcript}
+	options := conversationOptions(ChatRequest{ConversationID: r.FormValue("conversation_id")})
+	response.Reply, err = h.chatbot.Ask(ctx, transcript, options...)
+	switch {
+	case errors.Is(err, ErrNeedsHuman):
+		response.Handoff = true
+		h.writeAudio(w, http.StatusOK, response)
+		return
+	case err != nil:
+		response.Error = "Internal server error"
+		h.writeAudio(w, http.StatusInternalServerError, response)
+		return
+	}
+
+	if speak {
+		spoken, err := h.chatbot.Synthesize(ctx, response.Reply)
+		if err != nil {
+			response.Error = "Failed to synthesize reply"
+			h.writeAudio(w, http.StatusBadGateway, response)
+			return
+		}
+		if wantsAudio {
+			w.Header().Set("Content-Type", spoken.ContentType)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(spoken.Data)
+			return
+		}
+		response.Audio = base64.StdEncoding.EncodeToString(spoken.Data)
+		response.AudioContentType = spoken.ContentType
+	}
+	h.writeAudio(w, http.StatusOK, response)
+}
+
+// HandleAudio is a convenience method to create and handle voice chat requests.
+func (c *Chatbot) HandleAudio(w http.ResponseWriter, r *http.Request) {
+	handler := NewHTTPHandler(c)
+	handler.HandleAudio(w, r)
+}
+
+func (h *HTTPHandler) writeAudio(w http.ResponseWriter, statusCode int, response AudioResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (h *HTTPHandler) writeAudioError(w http.ResponseWriter, statusCode int, message string) {
+	h.writeAudio(w, statusCode, AudioResponse{Error: message})
+}
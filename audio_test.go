@@ -0,0 +1,104 @@
+package gochatbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/speech"
+)
+
+type fakeTranscriber struct{ text string }
+
+func (f fakeTranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	return f.text, nil
+}
+
+type fakeSynthesizer struct{}
+
+func (fakeSynthesizer) Synthesize(ctx context.Context, text string) (*speech.Audio, error) {
+	return &speech.Audio{Data: []byte("spoken:" + text), ContentType: "audio/mpeg"}, nil
+}
+
+func audioRequest(t *testing.T, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("audio", "question.webm")
+	_, _ = part.Write([]byte("fake audio"))
+	for key, value := range fields {
+		_ = form.WriteField(key, value)
+	}
+	_ = form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/audio", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestHandleAudio(t *testing.T) {
+	model := &contextModel{}
+	bot, err := New(config.Default(), WithModel(model),
+		WithSpeech(fakeTranscriber{text: "What time is it?"}, fakeSynthesizer{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	bot.HandleAudio(w, audioRequest(t, map[string]string{"conversation_id": "c1", "speak": "true"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response AudioResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	audio, _ := base64.StdEncoding.DecodeString(response.Audio)
+	if response.Transcript != "What time is it?" || response.Reply != "ok" || string(audio) != "spoken:ok" {
+		t.Errorf("unexpected response: %+v", response)
+	}
+	if model.context["conversation_id"] != "c1" {
+		t.Errorf("expected conversation ID in context, got %v", model.context)
+	}
+
+	req := audioRequest(t, nil)
+	req.Header.Set("Accept", "audio/mpeg")
+	w = httptest.NewRecorder()
+	bot.HandleAudio(w, req)
+	if w.Header().Get("Content-Type") != "audio/mpeg" || w.Body.String() != "spoken:ok" {
+		t.Errorf("expected raw audio, got %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+}
+
+func TestHandleAudioErrors(t *testing.T) {
+	bot, _ := New(config.Default(), WithModel(&contextModel{}))
+	w := httptest.NewRecorder()
+	bot.HandleAudio(w, audioRequest(t, nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without a transcriber, got %d", w.Code)
+	}
+
+	bot, _ = New(config.Default(), WithModel(&contextModel{}), WithSpeech(fakeTranscriber{text: "Hi"}, nil))
+	w = httptest.NewRecorder()
+	bot.HandleAudio(w, audioRequest(t, map[string]string{"speak": "true"}))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without a synthesizer, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	bot.HandleAudio(w, httptest.NewRequest(http.MethodPost, "/audio", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without audio, got %d", w.Code)
+	}
+
+	bot, _ = New(config.Default(), WithModel(&contextModel{}), WithSpeech(fakeTranscriber{text: " "}, nil))
+	w = httptest.NewRecorder()
+	bot.HandleAudio(w, audioRequest(t, nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for silence, got %d", w.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package gochatbot
+
+import (
+	"context"
+	"time"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/budget"
+	"go.rumenx.com/chatbot/models"
+)
+
+// WithBudget splits the request timeout across the pipeline stages
+// (filtering, retrieval, the model call and post-processing) according to
+// plan, instead of letting any stage use up the whole timeout. Retrieval
+// depth shrinks when earlier stages ran late; see WithFastModel for
+// switching models. It has no effect without a timeout.
+func WithBudget(plan budget.Plan) Option {
+	return func(c *Chatbot) {
+		c.budget = plan
+	}
+}
+
+// WithFastModel answers with model instead of the main model when the
+// budget leaves the model call less than min. It requires WithBudget.
+func WithFastModel(model models.Model, min time.Duration) Option {
+	return func(c *Chatbot) {
+		c.fastModel = model
+		c.fastBelow = min
+	}
+}
+
+// newBudget starts the request's budget, if one is configured. The budget
+// is also added to the context, so models and retrievers can consult it.
+func (c *Chatbot) newBudget(ctx context.Context) (context.Context, *budget.Budget) {
+	deadline, ok := ctx.Deadline()
+	if c.budget == nil || !ok {
+		return ctx, nil
+	}
+	b := budget.New(deadline, c.budget)
+	return budget.NewContext(ctx, b), b
+}
+
+// stage returns the context for a pipeline stage and the function ending it.
+func stage(ctx context.Context, b *budget.Budget, s budget.Stage) (context.Context, context.CancelFunc) {
+	if b == nil {
+		return ctx, func() {}
+	}
+	return b.Start(ctx, s)
+}
+
+// modelFor returns the model to answer with given the budget left,
+// tracking a fallback to the fast model.
+func (c *Chatbot) modelFor(ctx context.Context, b *budget.Budget, askContext map[string]interface{}) models.Model {
+	if b != nil && c.fastModel != nil && b.Tight(budget.StageModel, c.fastBelow) {
+		c.trackFallback(ctx, analytics.FallbackFastModel, c.fastModel, askContext)
+		return c.fastModel
+	}
+	return c.model
+}
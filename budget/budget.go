@@ -0,0 +1,175 @@
+// Package budget splits a request's deadline across the stages of the chat
+// pipeline. Instead of one opaque timeout, each stage gets its share of the
+// time that is left, so a slow stage eats into its own share first and later
+// stages can adapt, for example by retrieving fewer documents or switching
+// to a faster model:
+//
+//	b := budget.New(deadline, budget.DefaultPlan)
+//	ctx, done := b.Start(ctx, budget.StageRetrieval)
+//	results, err := store.Search(ctx, query, b.Depth(budget.StageRetrieval, 5))
+//	done()
+package budget
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stage is a step of the request pipeline.
+type Stage string
+
+// Pipeline stages, in order.
+const (
+	StageFilter      Stage = "filter"
+	StageRetrieval   Stage = "retrieval"
+	StageModel       Stage = "model"
+	StagePostProcess Stage = "post_process"
+)
+
+// stages is the pipeline order.
+var stages = []Stage{StageFilter, StageRetrieval, StageModel, StagePostProcess}
+
+// Plan assigns each stage a share of the total time. Shares are relative,
+// so they need not add up to one; stages without a share are not limited
+// beyond the overall deadline.
+type Plan map[Stage]float64
+
+// DefaultPlan gives most of the time to the model call.
+var DefaultPlan = Plan{
+	StageFilter:      0.05,
+	StageRetrieval:   0.15,
+	StageModel:       0.7,
+	StagePostProcess: 0.1,
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying b.
+func NewContext(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the budget carried by ctx, if any.
+func FromContext(ctx context.Context) (*Budget, bool) {
+	b, ok := ctx.Value(contextKey{}).(*Budget)
+	return b, ok
+}
+
+// Budget tracks the time left for a request.
+type Budget struct {
+	deadline time.Time
+	total    time.Duration
+	plan     Plan
+
+	mutex sync.Mutex
+	spent map[Stage]time.Duration
+}
+
+// New creates a budget for a request that must finish by deadline.
+func New(deadline time.Time, plan Plan) *Budget {
+	if plan == nil {
+		plan = DefaultPlan
+	}
+	return &Budget{
+		deadline: deadline,
+		total:    time.Until(deadline),
+		plan:     plan,
+		spent:    make(map[Stage]time.Duration),
+	}
+}
+
+// Remaining returns the time left before the deadline.
+func (b *Budget) Remaining() time.Duration {
+	return max(time.Until(b.deadline), 0)
+}
+
+// Planned returns the time the plan gives stage out of the whole budget.
+func (b *Budget) Planned(stage Stage) time.Duration {
+	share, ok := b.plan[stage]
+	sum := b.sum(stages)
+	if !ok || sum <= 0 {
+		return b.total
+	}
+	return time.Duration(float64(b.total) * share / sum)
+}
+
+// Allot returns the time stage may take now: its share of the remaining
+// time, relative to the stages that still follow it.
+func (b *Budget) Allot(stage Stage) time.Duration {
+	remaining := b.Remaining()
+	share, ok := b.plan[stage]
+	if !ok {
+		return remaining
+	}
+
+	rest := b.sum(stages)
+	for i, s := range stages {
+		if s == stage {
+			rest = b.sum(stages[i:])
+			break
+		}
+	}
+	if rest <= 0 {
+		return remaining
+	}
+	return time.Duration(float64(remaining) * share / rest)
+}
+
+// Pressure returns how the time stage may take now compares to its plan:
+// 1 or more means on schedule, 0.5 means the stage has half the time it was
+// planned to get.
+func (b *Budget) Pressure(stage Stage) float64 {
+	planned := b.Planned(stage)
+	if planned <= 0 {
+		return 0
+	}
+	return min(float64(b.Allot(stage))/float64(planned), 1)
+}
+
+// Tight reports whether stage has less than minimum to run.
+func (b *Budget) Tight(stage Stage, minimum time.Duration) bool {
+	return b.Allot(stage) < minimum
+}
+
+// Depth scales a result count, such as the number of documents to retrieve,
+// by the stage's pressure. It never returns less than 1 for a positive full.
+func (b *Budget) Depth(stage Stage, full int) int {
+	if full <= 0 {
+		return full
+	}
+	return max(int(float64(full)*b.Pressure(stage)), 1)
+}
+
+// Start returns a context whose deadline is the stage's allotment, and a
+// function that ends the stage and records the time it took.
+func (b *Budget) Start(ctx context.Context, stage Stage) (context.Context, context.CancelFunc) {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, b.Allot(stage))
+	return ctx, func() {
+		cancel()
+		b.mutex.Lock()
+		b.spent[stage] += time.Since(started)
+		b.mutex.Unlock()
+	}
+}
+
+// Spent returns the time each started stage took.
+func (b *Budget) Spent() map[Stage]time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	spent := make(map[Stage]time.Duration, len(b.spent))
+	for stage, d := range b.spent {
+		spent[stage] = d
+	}
+	return spent
+}
+
+func (b *Budget) sum(stages []Stage) float64 {
+	var sum float64
+	for _, stage := range stages {
+		sum += b.plan[stage]
+	}
+	return sum
+}
@@ -0,0 +1,79 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func within(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -20*time.Millisecond && diff < 20*time.Millisecond
+}
+
+func TestAllot(t *testing.T) {
+	b := New(time.Now().Add(time.Second), Plan{
+		StageFilter:      0.1,
+		StageRetrieval:   0.2,
+		StageModel:       0.6,
+		StagePostProcess: 0.1,
+	})
+
+	if got := b.Allot(StageFilter); !within(got, 100*time.Millisecond) {
+		t.Errorf("expected about 100ms for filtering, got %v", got)
+	}
+	// Later stages share what is left among themselves
+	if got := b.Allot(StageModel); !within(got, 857*time.Millisecond) {
+		t.Errorf("expected about 857ms for the model, got %v", got)
+	}
+	if got := b.Planned(StageModel); !within(got, 600*time.Millisecond) {
+		t.Errorf("expected 600ms planned for the model, got %v", got)
+	}
+	if got := b.Allot("unknown"); !within(got, time.Second) {
+		t.Errorf("expected stages without a share to get the rest, got %v", got)
+	}
+}
+
+func TestPressureAndDepth(t *testing.T) {
+	b := New(time.Now().Add(200*time.Millisecond), Plan{StageFilter: 0.5, StageRetrieval: 0.25, StageModel: 0.25})
+	if b.Pressure(StageRetrieval) != 1 || b.Depth(StageRetrieval, 8) != 8 {
+		t.Errorf("expected no pressure on schedule, got %v", b.Pressure(StageRetrieval))
+	}
+
+	// A filter that overruns its 100ms leaves retrieval about half its
+	// planned time
+	_, done := b.Start(context.Background(), StageFilter)
+	time.Sleep(150 * time.Millisecond)
+	done()
+
+	if pressure := b.Pressure(StageRetrieval); pressure < 0.3 || pressure > 0.7 {
+		t.Errorf("expected a pressure around 0.5, got %v", pressure)
+	}
+	if depth := b.Depth(StageRetrieval, 8); depth < 2 || depth > 6 {
+		t.Errorf("expected a reduced depth, got %d", depth)
+	}
+	if !b.Tight(StageModel, 100*time.Millisecond) {
+		t.Error("expected the model stage to be tight")
+	}
+	if spent := b.Spent()[StageFilter]; spent < 150*time.Millisecond {
+		t.Errorf("expected the filter time to be recorded, got %v", spent)
+	}
+}
+
+func TestStart(t *testing.T) {
+	b := New(time.Now().Add(time.Second), Plan{StageFilter: 0.1, StageModel: 0.9})
+	ctx, done := b.Start(context.Background(), StageFilter)
+	defer done()
+
+	deadline, ok := ctx.Deadline()
+	if !ok || !within(time.Until(deadline), 100*time.Millisecond) {
+		t.Errorf("expected a 100ms stage deadline, got %v", time.Until(deadline))
+	}
+
+	if got, ok := FromContext(NewContext(context.Background(), b)); !ok || got != b {
+		t.Error("expected the budget from the context")
+	}
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("expected no budget")
+	}
+}
@@ -0,0 +1,120 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/budget"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+// slowModel answers after a delay.
+type slowModel struct {
+	name  string
+	delay time.Duration
+}
+
+func (m *slowModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	select {
+	case <-time.After(m.delay):
+		return m.name, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (m *slowModel) Name() string     { return m.name }
+func (m *slowModel) Provider() string { return "test" }
+
+// sentimentFunc runs a function as a sentiment analyzer that finds nothing.
+type sentimentFunc func(ctx context.Context, text string)
+
+func (f sentimentFunc) Analyze(ctx context.Context, text string) (*middleware.Sentiment, error) {
+	f(ctx, text)
+	return nil, errors.New("no sentiment")
+}
+
+func TestRetriever(t *testing.T) {
+	cfg := config.Default()
+	cfg.Prompt = "You are helpful."
+	cfg.MessageFiltering.Enabled = false
+	var limit int
+	retriever := RetrieverFunc(func(ctx context.Context, query string, n int) ([]string, error) {
+		limit = n
+		return []string{"We open at nine.", "We close at five."}, nil
+	})
+	model := &contextModel{}
+	bot, err := New(cfg, WithModel(model), WithRetriever(retriever, 4))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "When do you open?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prompt, _ := model.context["prompt"].(string)
+	if limit != 4 || !strings.HasPrefix(prompt, "You are helpful.") || !strings.Contains(prompt, "We close at five.") {
+		t.Errorf("unexpected limit %d or prompt %q", limit, prompt)
+	}
+}
+
+func TestBudgetShrinksRetrieval(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	var limit int
+	retriever := RetrieverFunc(func(ctx context.Context, query string, n int) ([]string, error) {
+		limit = n
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	// A slow sentiment check runs before retrieval and eats into its time
+	slow := sentimentFunc(func(ctx context.Context, text string) {
+		time.Sleep(150 * time.Millisecond)
+	})
+	bot, err := New(cfg, WithModel(&contextModel{}), WithTimeout(300*time.Millisecond),
+		WithBudget(budget.Plan{budget.StageRetrieval: 0.5, budget.StageModel: 0.5}),
+		WithRetriever(retriever, 10), WithSentimentAnalyzer(slow))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	reply, err := bot.Ask(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("a retrieval timeout should not fail the request: %v", err)
+	}
+	if reply != "ok" {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+	if limit < 1 || limit > 7 {
+		t.Errorf("expected a reduced retrieval depth, got %d", limit)
+	}
+}
+
+func TestFastModel(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	main := &slowModel{name: "main", delay: time.Millisecond}
+	fast := &slowModel{name: "fast", delay: time.Millisecond}
+
+	bot, err := New(cfg, WithModel(main), WithTimeout(time.Second),
+		WithBudget(budget.DefaultPlan), WithFastModel(fast, 500*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if reply, _ := bot.Ask(context.Background(), "hello"); reply != "main" {
+		t.Errorf("expected the main model with time to spare, got %q", reply)
+	}
+
+	bot, err = New(cfg, WithModel(main), WithTimeout(time.Second),
+		WithBudget(budget.DefaultPlan), WithFastModel(fast, 2*time.Second))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if reply, _ := bot.Ask(context.Background(), "hello"); reply != "fast" {
+		t.Errorf("expected the fast model when the budget is tight, got %q", reply)
+	}
+}
@@ -0,0 +1,197 @@
+// Package chains composes multi-stage flows (prompt → model → parser →
+// another model or tool) from typed steps, so pipelines such as
+// classify → route → answer need no bespoke glue code.
+//
+//	classify := chains.Then(chains.Then(
+//		chains.Prompt[string]("Classify as billing or technical: {{.}}"),
+//		chains.Model(model)),
+//		chains.Choice("billing", "technical"))
+//
+//	answer := chains.Route(classify, map[string]chains.Step[string, string]{
+//		"billing":   billingChain,
+//		"technical": technicalChain,
+//	}, generalChain)
+//
+//	reply, err := answer.Run(ctx, "Why was I charged twice?")
+package chains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Step transforms an input into an output.
+type Step[In, Out any] interface {
+	Run(ctx context.Context, in In) (Out, error)
+}
+
+// Func adapts a function to the Step interface.
+type Func[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// Run implements Step.
+func (f Func[In, Out]) Run(ctx context.Context, in In) (Out, error) {
+	return f(ctx, in)
+}
+
+// StepError identifies the named step that failed.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %q failed: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Map lifts a plain function into a step.
+func Map[In, Out any](fn func(In) Out) Step[In, Out] {
+	return Func[In, Out](func(ctx context.Context, in In) (Out, error) {
+		return fn(in), nil
+	})
+}
+
+// Then runs first and feeds its output to second.
+func Then[A, B, C any](first Step[A, B], second Step[B, C]) Step[A, C] {
+	return Func[A, C](func(ctx context.Context, in A) (C, error) {
+		mid, err := first.Run(ctx, in)
+		if err != nil {
+			var zero C
+			return zero, err
+		}
+		return second.Run(ctx, mid)
+	})
+}
+
+// Named wraps errors from step in a *StepError with the given name, so
+// failures in long chains are easy to locate.
+func Named[In, Out any](name string, step Step[In, Out]) Step[In, Out] {
+	return Func[In, Out](func(ctx context.Context, in In) (Out, error) {
+		out, err := step.Run(ctx, in)
+		if err != nil {
+			return out, &StepError{Step: name, Err: err}
+		}
+		return out, nil
+	})
+}
+
+// Parallel runs every step on the same input concurrently and returns their
+// outputs in order. The first failure cancels the remaining steps; a panic
+// in a step fails it like an error.
+func Parallel[In, Out any](steps ...Step[In, Out]) Step[In, []Out] {
+	return Func[In, []Out](func(ctx context.Context, in In) ([]Out, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		outputs := make([]Out, len(steps))
+		var wg sync.WaitGroup
+		var once sync.Once
+		var firstErr error
+		fail := func(err error) {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+
+		for i, step := range steps {
+			wg.Add(1)
+			go func(i int, step Step[In, Out]) {
+				defer wg.Done()
+				// A panic would crash the process from this goroutine
+				defer func() {
+					if r := recover(); r != nil {
+						fail(fmt.Errorf("parallel step %d panicked: %v", i, r))
+					}
+				}()
+				out, err := step.Run(ctx, in)
+				if err != nil {
+					fail(err)
+					return
+				}
+				outputs[i] = out
+			}(i, step)
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return outputs, nil
+	})
+}
+
+// Route runs selector on the input and continues with the step registered
+// under the resulting key. Unknown keys go to fallback, or fail if it is nil.
+func Route[In, Out any](selector Step[In, string], routes map[string]Step[In, Out], fallback Step[In, Out]) Step[In, Out] {
+	return Func[In, Out](func(ctx context.Context, in In) (Out, error) {
+		key, err := selector.Run(ctx, in)
+		if err != nil {
+			var zero Out
+			return zero, err
+		}
+
+		step, ok := routes[key]
+		if !ok {
+			if fallback == nil {
+				var zero Out
+				return zero, fmt.Errorf("no route for %q", key)
+			}
+			step = fallback
+		}
+		return step.Run(ctx, in)
+	})
+}
+
+// Fallback runs secondary when primary fails.
+func Fallback[In, Out any](primary, secondary Step[In, Out]) Step[In, Out] {
+	return Func[In, Out](func(ctx context.Context, in In) (Out, error) {
+		out, err := primary.Run(ctx, in)
+		if err == nil || ctx.Err() != nil {
+			return out, err
+		}
+
+		out, fallbackErr := secondary.Run(ctx, in)
+		if fallbackErr != nil {
+			return out, errors.Join(err, fallbackErr)
+		}
+		return out, nil
+	})
+}
+
+// Retry runs step up to attempts times, waiting backoff between attempts.
+func Retry[In, Out any](step Step[In, Out], attempts int, backoff time.Duration) Step[In, Out] {
+	return Func[In, Out](func(ctx context.Context, in In) (Out, error) {
+		var out Out
+		var err error
+		for attempt := 1; ; attempt++ {
+			out, err = step.Run(ctx, in)
+			if err == nil || attempt >= attempts || ctx.Err() != nil {
+				return out, err
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return out, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	})
+}
+
+// Timeout bounds the time step may take.
+func Timeout[In, Out any](step Step[In, Out], timeout time.Duration) Step[In, Out] {
+	return Func[In, Out](func(ctx context.Context, in In) (Out, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return step.Run(ctx, in)
+	})
+}
@@ -0,0 +1,170 @@
+package chains
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/tools"
+)
+
+func TestClassifyRouteAnswer(t *testing.T) {
+	model := chatbottest.NewMockModel().ReplyFunc(func(message string, context map[string]interface{}) (string, error) {
+		switch {
+		case strings.HasPrefix(message, "Classify"):
+			return "Billing.", nil
+		case context["prompt"] == "billing":
+			return "Refund issued for: " + message, nil
+		default:
+			return "general answer", nil
+		}
+	})
+
+	classify := Then(Then(Prompt[string]("Classify as billing or technical: {{.}}"), Model(model)), Choice("billing", "technical"))
+	answer := Route(classify, map[string]Step[string, string]{
+		"billing":   Model(model, WithSystemPrompt("billing")),
+		"technical": Model(model, WithSystemPrompt("technical")),
+	}, nil)
+
+	reply, err := answer.Run(context.Background(), "charged twice")
+	if err != nil || reply != "Refund issued for: charged twice" {
+		t.Errorf("unexpected reply %q, %v", reply, err)
+	}
+}
+
+func TestRouteFallback(t *testing.T) {
+	selector := Map(func(in string) string { return "unknown" })
+	fallback := Map(func(in string) string { return "fallback:" + in })
+
+	if out, err := Route(selector, nil, fallback).Run(context.Background(), "x"); err != nil || out != "fallback:x" {
+		t.Errorf("unexpected result %q, %v", out, err)
+	}
+	if _, err := Route[string, string](selector, nil, nil).Run(context.Background(), "x"); err == nil {
+		t.Error("expected error without a matching route")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type intent struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+	}
+	parse := JSON[intent]()
+
+	out, err := parse.Run(context.Background(), "Sure!\n```json\n{\"label\":\"billing\",\"confidence\":0.9}\n```")
+	if err != nil || out.Label != "billing" || out.Confidence != 0.9 {
+		t.Errorf("unexpected result %+v, %v", out, err)
+	}
+	if _, err := parse.Run(context.Background(), "no json here"); err == nil {
+		t.Error("expected error without JSON")
+	}
+
+	list, err := JSON[[]string]().Run(context.Background(), `["a","b"]`)
+	if err != nil || len(list) != 2 {
+		t.Errorf("unexpected list %v, %v", list, err)
+	}
+}
+
+func TestPromptWithStruct(t *testing.T) {
+	type input struct{ Name, Topic string }
+	out, err := Prompt[input]("Hi {{.Name}}, about {{.Topic}}").Run(context.Background(), input{"Ada", "refunds"})
+	if err != nil || out != "Hi Ada, about refunds" {
+		t.Errorf("unexpected prompt %q, %v", out, err)
+	}
+
+	_, err = Prompt[map[string]string]("{{.missing}}").Run(context.Background(), map[string]string{})
+	if err == nil {
+		t.Error("expected error for missing key")
+	}
+}
+
+func TestParallel(t *testing.T) {
+	upper := Map(strings.ToUpper)
+	length := Map(func(s string) string { return strings.Repeat("*", len(s)) })
+
+	out, err := Parallel(upper, length).Run(context.Background(), "abc")
+	if err != nil || out[0] != "ABC" || out[1] != "***" {
+		t.Errorf("unexpected outputs %v, %v", out, err)
+	}
+
+	failure := errors.New("boom")
+	var cancelled atomic.Bool
+	slow := Func[string, string](func(ctx context.Context, in string) (string, error) {
+		select {
+		case <-ctx.Done():
+			cancelled.Store(true)
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+			return in, nil
+		}
+	})
+	failing := Func[string, string](func(ctx context.Context, in string) (string, error) { return "", failure })
+
+	if _, err := Parallel(slow, failing).Run(context.Background(), "x"); !errors.Is(err, failure) {
+		t.Errorf("expected first failure, got %v", err)
+	}
+	if !cancelled.Load() {
+		t.Error("expected remaining steps to be cancelled")
+	}
+
+	panicking := Func[string, string](func(ctx context.Context, in string) (string, error) { panic("boom") })
+	if _, err := Parallel(upper, panicking).Run(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	failure := errors.New("provider down")
+	var attempts int
+	flaky := Func[string, string](func(ctx context.Context, in string) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", failure
+		}
+		return "ok", nil
+	})
+
+	if out, err := Retry(flaky, 3, time.Millisecond).Run(context.Background(), "x"); err != nil || out != "ok" {
+		t.Errorf("expected retry to succeed, got %q, %v", out, err)
+	}
+
+	broken := Func[string, string](func(ctx context.Context, in string) (string, error) { return "", failure })
+	if out, err := Fallback(broken, Map(strings.ToUpper)).Run(context.Background(), "x"); err != nil || out != "X" {
+		t.Errorf("expected fallback result, got %q, %v", out, err)
+	}
+
+	_, err := Then(Named("classify", broken), Map(strings.ToUpper)).Run(context.Background(), "x")
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "classify" || !errors.Is(err, failure) {
+		t.Errorf("expected named step error, got %v", err)
+	}
+
+	slow := Func[string, string](func(ctx context.Context, in string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if _, err := Timeout(slow, 10*time.Millisecond).Run(context.Background(), "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+}
+
+func TestTool(t *testing.T) {
+	type args struct {
+		City string `json:"city"`
+	}
+	weather := tools.New("weather", "Weather for a city", nil, func(ctx context.Context, raw json.RawMessage) (string, error) {
+		var in args
+		_ = json.Unmarshal(raw, &in)
+		return "sunny in " + in.City, nil
+	})
+
+	out, err := Tool[args](weather).Run(context.Background(), args{City: "Sofia"})
+	if err != nil || out != "sunny in Sofia" {
+		t.Errorf("unexpected tool output %q, %v", out, err)
+	}
+}
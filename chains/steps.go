@@ -0,0 +1,109 @@
+package chains
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// Prompt renders a text/template with the step input as its data. It panics
+// if the template does not parse, like template.Must.
+func Prompt[In any](text string) Step[In, string] {
+	tmpl := template.Must(template.New("prompt").Option("missingkey=error").Parse(text))
+	return Func[In, string](func(ctx context.Context, in In) (string, error) {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, in); err != nil {
+			return "", fmt.Errorf("failed to render prompt: %w", err)
+		}
+		return b.String(), nil
+	})
+}
+
+// ModelOption configures a Model step.
+type ModelOption func(map[string]interface{})
+
+// WithSystemPrompt sets the system prompt for the model call.
+func WithSystemPrompt(prompt string) ModelOption {
+	return func(context map[string]interface{}) {
+		context["prompt"] = prompt
+	}
+}
+
+// WithModelContext passes a context value to the model, e.g. "temperature".
+func WithModelContext(key string, value interface{}) ModelOption {
+	return func(context map[string]interface{}) {
+		context[key] = value
+	}
+}
+
+// Model sends the input to model and returns its reply. Use
+// Chatbot.GetModel to reuse the chatbot's provider.
+func Model(model models.Model, opts ...ModelOption) Step[string, string] {
+	return Func[string, string](func(ctx context.Context, in string) (string, error) {
+		context := make(map[string]interface{}, len(opts))
+		for _, opt := range opts {
+			opt(context)
+		}
+		return model.Ask(ctx, in, context)
+	})
+}
+
+// JSON parses the input as JSON into Out. Models often wrap JSON in prose or
+// code fences, so the outermost object or array is used.
+func JSON[Out any]() Step[string, Out] {
+	return Func[string, Out](func(ctx context.Context, in string) (Out, error) {
+		var out Out
+		start := strings.IndexAny(in, "{[")
+		end := strings.LastIndexAny(in, "}]")
+		if start < 0 || end < start {
+			return out, fmt.Errorf("no JSON found in %q", truncate(in, 100))
+		}
+		if err := json.Unmarshal([]byte(in[start:end+1]), &out); err != nil {
+			return out, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return out, nil
+	})
+}
+
+// Choice normalizes a model reply to one of the given labels, matching case
+// insensitively. It fails if the reply names none of them, which makes it a
+// natural selector for Route.
+func Choice(labels ...string) Step[string, string] {
+	return Func[string, string](func(ctx context.Context, in string) (string, error) {
+		reply := strings.ToLower(strings.TrimSpace(in))
+		for _, label := range labels {
+			if reply == strings.ToLower(label) {
+				return label, nil
+			}
+		}
+		for _, label := range labels {
+			if strings.Contains(reply, strings.ToLower(label)) {
+				return label, nil
+			}
+		}
+		return "", fmt.Errorf("reply %q matches none of %v", truncate(in, 100), labels)
+	})
+}
+
+// Tool calls tool with the input marshaled as its JSON arguments.
+func Tool[In any](tool tools.Tool) Step[In, string] {
+	return Func[In, string](func(ctx context.Context, in In) (string, error) {
+		args, err := json.Marshal(in)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s arguments: %w", tool.Name(), err)
+		}
+		return tool.Call(ctx, args)
+	})
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
@@ -0,0 +1,208 @@
+// Package channels connects the chatbot to external chat surfaces such as
+// Microsoft Teams, WhatsApp or any system speaking signed webhooks. Every surface implements the Channel
+// interface, so a new one only has to parse inbound webhooks and send
+// replies; Handler takes care of the rest.
+//
+//	teams := channels.NewTeams(channels.TeamsConfig{AppID: id, AppPassword: secret})
+//	http.Handle("/channels/teams", channels.Handler(bot, teams))
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	gochatbot "go.rumenx.com/chatbot"
+)
+
+// ErrUnauthorized is returned by Channel.ParseInbound when a webhook fails
+// signature or token verification.
+var ErrUnauthorized = errors.New("unauthorized webhook request")
+
+// ErrIgnored is returned by Channel.ParseInbound for webhooks that carry no
+// user message (typing indicators, delivery receipts, ...).
+var ErrIgnored = errors.New("webhook ignored")
+
+// InboundMessage is a user message received from a channel.
+type InboundMessage struct {
+	// Channel is the name of the channel the message came from.
+	Channel string
+	// ID is the channel's identifier for the message.
+	ID string
+	// ConversationID identifies the conversation or chat on the channel.
+	ConversationID string
+	// ExternalUserID is the sender's identity on the channel.
+	ExternalUserID string
+	// UserName is the sender's display name, if known.
+	UserName string
+	// UserID is the application identity of the sender, set by the IdentityMapper.
+	UserID string
+	// Text is the message content.
+	Text string
+	// Raw holds channel-specific data needed to send the reply.
+	Raw interface{}
+}
+
+// Channel is a chat surface the chatbot can be reached on.
+type Channel interface {
+	// Name returns the channel name, e.g. "teams" or "whatsapp".
+	Name() string
+
+	// ParseInbound verifies and decodes a webhook request. It returns
+	// ErrUnauthorized for requests that fail verification and ErrIgnored for
+	// events that need no reply.
+	ParseInbound(r *http.Request) (*InboundMessage, error)
+
+	// SendReply delivers the chatbot's reply to the sender of msg.
+	SendReply(ctx context.Context, msg *InboundMessage, reply string) error
+
+	// Acknowledge writes the immediate HTTP response to the webhook.
+	Acknowledge(w http.ResponseWriter)
+}
+
+// IdentityMapper maps channel identities to application user IDs, e.g. to
+// link a Teams user and a WhatsApp number to the same account.
+type IdentityMapper interface {
+	MapIdentity(ctx context.Context, msg *InboundMessage) (string, error)
+}
+
+// IdentityMapperFunc adapts a function to the IdentityMapper interface.
+type IdentityMapperFunc func(ctx context.Context, msg *InboundMessage) (string, error)
+
+// MapIdentity implements IdentityMapper.
+func (f IdentityMapperFunc) MapIdentity(ctx context.Context, msg *InboundMessage) (string, error) {
+	return f(ctx, msg)
+}
+
+// defaultIdentity namespaces the external ID with the channel name.
+func defaultIdentity(_ context.Context, msg *InboundMessage) (string, error) {
+	return msg.Channel + ":" + msg.ExternalUserID, nil
+}
+
+// HandlerOption configures Handler.
+type HandlerOption func(*handler)
+
+// WithIdentityMapper sets how channel identities map to application users.
+// By default the user ID is "<channel>:<external id>".
+func WithIdentityMapper(mapper IdentityMapper) HandlerOption {
+	return func(h *handler) {
+		h.mapper = mapper
+	}
+}
+
+// WithErrorHandler sets a function called with errors that happen after the
+// webhook was acknowledged. By default they are logged.
+func WithErrorHandler(fn func(msg *InboundMessage, err error)) HandlerOption {
+	return func(h *handler) {
+		h.onError = fn
+	}
+}
+
+// WithReplyTimeout bounds the time spent answering one message (default 60s).
+func WithReplyTimeout(timeout time.Duration) HandlerOption {
+	return func(h *handler) {
+		h.timeout = timeout
+	}
+}
+
+// WithSynchronousReplies answers messages before acknowledging the webhook.
+// Channels expect a quick acknowledgement, so this is mainly useful in tests.
+func WithSynchronousReplies() HandlerOption {
+	return func(h *handler) {
+		h.sync = true
+	}
+}
+
+// handler serves a channel's webhook.
+type handler struct {
+	bot     *gochatbot.Chatbot
+	channel Channel
+	mapper  IdentityMapper
+	onError func(msg *InboundMessage, err error)
+	timeout time.Duration
+	sync    bool
+}
+
+// Handler returns an http.Handler for the channel's webhook. Inbound messages
+// are acknowledged immediately and answered in the background.
+func Handler(bot *gochatbot.Chatbot, channel Channel, opts ...HandlerOption) http.Handler {
+	h := &handler{
+		bot:     bot,
+		channel: channel,
+		mapper:  IdentityMapperFunc(defaultIdentity),
+		timeout: 60 * time.Second,
+		onError: func(msg *InboundMessage, err error) {
+			log.Printf("channels: %s: %v", channel.Name(), err)
+		},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg, err := h.channel.ParseInbound(r)
+	switch {
+	case errors.Is(err, ErrIgnored):
+		h.channel.Acknowledge(w)
+		return
+	case errors.Is(err, ErrUnauthorized):
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// The reply outlives the webhook request unless answering synchronously
+	ctx := context.WithoutCancel(r.Context())
+	if h.sync {
+		h.reply(ctx, msg)
+		h.channel.Acknowledge(w)
+		return
+	}
+
+	h.channel.Acknowledge(w)
+	go h.reply(ctx, msg)
+}
+
+// reply asks the chatbot and sends the answer back over the channel.
+func (h *handler) reply(ctx context.Context, msg *InboundMessage) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	userID, err := h.mapper.MapIdentity(ctx, msg)
+	if err != nil {
+		h.onError(msg, fmt.Errorf("identity mapping failed: %w", err))
+		return
+	}
+	msg.UserID = userID
+
+	answer, err := h.bot.Ask(ctx, msg.Text,
+		gochatbot.WithUserID(msg.UserID),
+		gochatbot.WithContext("channel", msg.Channel),
+		gochatbot.WithContext("conversation_id", msg.ConversationID),
+	)
+	if errors.Is(err, gochatbot.ErrNeedsHuman) {
+		// An operator answers through the conversation store
+		return
+	}
+	if err != nil {
+		h.onError(msg, fmt.Errorf("chatbot request failed: %w", err))
+		return
+	}
+
+	if err := h.channel.SendReply(ctx, msg, answer); err != nil {
+		h.onError(msg, fmt.Errorf("failed to send reply: %w", err))
+	}
+}
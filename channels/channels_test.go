@@ -0,0 +1,132 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/config"
+)
+
+// fakeChannel records replies and returns a fixed parse result.
+type fakeChannel struct {
+	msg     *InboundMessage
+	err     error
+	replies []string
+}
+
+func (f *fakeChannel) Name() string { return "fake" }
+
+func (f *fakeChannel) ParseInbound(r *http.Request) (*InboundMessage, error) {
+	return f.msg, f.err
+}
+
+func (f *fakeChannel) SendReply(ctx context.Context, msg *InboundMessage, reply string) error {
+	f.replies = append(f.replies, reply)
+	return nil
+}
+
+func (f *fakeChannel) Acknowledge(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func newTestBot(t *testing.T, model *chatbottest.MockModel) *gochatbot.Chatbot {
+	t.Helper()
+	bot, err := gochatbot.New(config.Default(), gochatbot.WithModel(model))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	return bot
+}
+
+func TestHandlerRepliesWithMappedIdentity(t *testing.T) {
+	model := chatbottest.NewMockModel("hi there")
+	channel := &fakeChannel{msg: &InboundMessage{Channel: "fake", ExternalUserID: "u1", Text: "hello"}}
+	mapper := IdentityMapperFunc(func(ctx context.Context, msg *InboundMessage) (string, error) {
+		return "account-42", nil
+	})
+	handler := Handler(newTestBot(t, model), channel, WithIdentityMapper(mapper), WithSynchronousReplies())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected channel acknowledgement, got %d", rec.Code)
+	}
+	if len(channel.replies) != 1 || channel.replies[0] != "hi there" {
+		t.Fatalf("unexpected replies: %v", channel.replies)
+	}
+	call, _ := model.LastCall()
+	if call.Message != "hello" || call.Context["user_id"] != "account-42" || call.Context["channel"] != "fake" {
+		t.Errorf("unexpected model call: %+v", call)
+	}
+}
+
+func TestHandlerDefaultIdentity(t *testing.T) {
+	model := chatbottest.NewMockModel()
+	channel := &fakeChannel{msg: &InboundMessage{Channel: "fake", ExternalUserID: "u1", Text: "hello"}}
+	handler := Handler(newTestBot(t, model), channel, WithSynchronousReplies())
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	call, _ := model.LastCall()
+	if got := call.Context["user_id"]; got != "fake:u1" {
+		t.Errorf("expected namespaced user ID, got %v", got)
+	}
+}
+
+func TestHandlerParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		status int
+	}{
+		{"method not allowed", http.MethodGet, nil, http.StatusMethodNotAllowed},
+		{"ignored", http.MethodPost, ErrIgnored, http.StatusAccepted},
+		{"unauthorized", http.MethodPost, ErrUnauthorized, http.StatusUnauthorized},
+		{"bad request", http.MethodPost, errors.New("boom"), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := chatbottest.NewMockModel()
+			channel := &fakeChannel{err: tt.err}
+			handler := Handler(newTestBot(t, model), channel, WithSynchronousReplies())
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, rec.Code)
+			}
+			if len(model.Calls()) != 0 {
+				t.Error("expected the model not to be called")
+			}
+		})
+	}
+}
+
+func TestHandlerReportsModelErrors(t *testing.T) {
+	model := chatbottest.NewMockModel()
+	model.Fail(errors.New("provider down"))
+	channel := &fakeChannel{msg: &InboundMessage{Channel: "fake", Text: "hello"}}
+
+	var reported error
+	handler := Handler(newTestBot(t, model), channel, WithSynchronousReplies(),
+		WithErrorHandler(func(msg *InboundMessage, err error) { reported = err }))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	if reported == nil || !strings.Contains(reported.Error(), "provider down") {
+		t.Errorf("expected model error to be reported, got %v", reported)
+	}
+	if len(channel.replies) != 0 {
+		t.Errorf("expected no reply, got %v", channel.replies)
+	}
+}
@@ -0,0 +1,209 @@
+package channels
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtVerifier validates RS256 tokens against keys published via OpenID
+// discovery. Keys are cached and refreshed when an unknown key ID appears.
+type jwtVerifier struct {
+	client    *http.Client
+	configURL string
+	issuer    string
+	audience  string
+	now       func() time.Time
+
+	mutex     sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// jwtKeyRefresh is how often the key set is refreshed at most.
+const jwtKeyRefresh = 5 * time.Minute
+
+func newJWTVerifier(client *http.Client, configURL, issuer, audience string) *jwtVerifier {
+	return &jwtVerifier{
+		client:    client,
+		configURL: configURL,
+		issuer:    issuer,
+		audience:  audience,
+		now:       time.Now,
+	}
+}
+
+// Verify checks the token's signature, issuer, audience and validity period.
+func (v *jwtVerifier) Verify(ctx context.Context, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("invalid token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return errors.New("token signature mismatch")
+	}
+
+	var claims struct {
+		Issuer    string      `json:"iss"`
+		Audience  interface{} `json:"aud"`
+		ExpiresAt int64       `json:"exp"`
+		NotBefore int64       `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	// Allow a little clock skew, as the Bot Framework recommends
+	now := v.now()
+	const skew = 5 * time.Minute
+	if claims.Issuer != v.issuer {
+		return fmt.Errorf("unexpected token issuer %q", claims.Issuer)
+	}
+	if !audienceMatches(claims.Audience, v.audience) {
+		return errors.New("token audience mismatch")
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(skew)) {
+		return errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(skew).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token not yet valid")
+	}
+	return nil
+}
+
+// key returns the public key with the given ID, refreshing the key set if needed.
+func (v *jwtVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if v.keys != nil && v.now().Sub(v.fetchedAt) < jwtKeyRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = v.now()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetchKeys downloads the JSON Web Key Set named by the OpenID configuration.
+func (v *jwtVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	var openID struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, v.configURL, &openID); err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenID configuration: %w", err)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, openID.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func (v *jwtVerifier) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// decodeSegment decodes a base64url JSON token segment.
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// audienceMatches reports whether the aud claim (string or array) contains want.
+func audienceMatches(aud interface{}, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bot Framework endpoints.
+const (
+	teamsTokenURL     = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	teamsTokenScope   = "https://api.botframework.com/.default"
+	teamsOpenIDConfig = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	teamsIssuer       = "https://api.botframework.com"
+)
+
+// TeamsConfig configures the Microsoft Teams channel.
+type TeamsConfig struct {
+	// AppID is the Microsoft App ID of the bot registration.
+	AppID string
+	// AppPassword is the client secret of the bot registration.
+	AppPassword string
+	// SkipVerification disables inbound token verification. Only use it
+	// with the Bot Framework Emulator during local development.
+	SkipVerification bool
+
+	// TokenURL, OpenIDConfigURL and HTTPClient override the Bot Framework
+	// defaults, mainly for testing.
+	TokenURL        string
+	OpenIDConfigURL string
+	HTTPClient      *http.Client
+}
+
+// Teams is a Channel for Microsoft Teams via the Bot Framework.
+type Teams struct {
+	config   TeamsConfig
+	client   *http.Client
+	verifier *jwtVerifier
+
+	mutex       sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewTeams creates a Teams channel.
+func NewTeams(cfg TeamsConfig) *Teams {
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = teamsTokenURL
+	}
+	if cfg.OpenIDConfigURL == "" {
+		cfg.OpenIDConfigURL = teamsOpenIDConfig
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &Teams{
+		config:   cfg,
+		client:   client,
+		verifier: newJWTVerifier(client, cfg.OpenIDConfigURL, teamsIssuer, cfg.AppID),
+	}
+}
+
+// teamsAccount identifies a user or bot in an activity.
+type teamsAccount struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	AADObjectID string `json:"aadObjectId,omitempty"`
+}
+
+// teamsActivity is the subset of a Bot Framework activity used by the channel.
+type teamsActivity struct {
+	Type         string       `json:"type"`
+	ID           string       `json:"id,omitempty"`
+	Text         string       `json:"text"`
+	ServiceURL   string       `json:"serviceUrl,omitempty"`
+	ChannelID    string       `json:"channelId,omitempty"`
+	From         teamsAccount `json:"from"`
+	Recipient    teamsAccount `json:"recipient"`
+	Conversation struct {
+		ID string `json:"id"`
+	} `json:"conversation"`
+	ReplyToID string `json:"replyToId,omitempty"`
+}
+
+// Name implements Channel.
+func (t *Teams) Name() string {
+	return "teams"
+}
+
+// ParseInbound implements Channel. Requests must carry a valid Bot Framework
+// bearer token unless verification is disabled.
+func (t *Teams) ParseInbound(r *http.Request) (*InboundMessage, error) {
+	if !t.config.SkipVerification {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return nil, ErrUnauthorized
+		}
+		if err := t.verifier.Verify(r.Context(), token); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		}
+	}
+
+	var activity teamsActivity
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&activity); err != nil {
+		return nil, fmt.Errorf("invalid activity: %w", err)
+	}
+	if activity.Type != "message" || strings.TrimSpace(activity.Text) == "" {
+		return nil, ErrIgnored
+	}
+
+	externalID := activity.From.AADObjectID
+	if externalID == "" {
+		externalID = activity.From.ID
+	}
+
+	return &InboundMessage{
+		Channel:        t.Name(),
+		ID:             activity.ID,
+		ConversationID: activity.Conversation.ID,
+		ExternalUserID: externalID,
+		UserName:       activity.From.Name,
+		Text:           strings.TrimSpace(activity.Text),
+		Raw:            &activity,
+	}, nil
+}
+
+// SendReply implements Channel by posting a reply activity to the
+// conversation's service URL.
+func (t *Teams) SendReply(ctx context.Context, msg *InboundMessage, reply string) error {
+	activity, ok := msg.Raw.(*teamsActivity)
+	if !ok {
+		return fmt.Errorf("message was not received from Teams")
+	}
+
+	token, err := t.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":         "message",
+		"text":         reply,
+		"from":         activity.Recipient,
+		"recipient":    activity.From,
+		"conversation": activity.Conversation,
+		"replyToId":    activity.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v3/conversations/%s/activities/%s",
+		strings.TrimRight(activity.ServiceURL, "/"),
+		url.PathEscape(activity.Conversation.ID),
+		url.PathEscape(activity.ID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return doRequest(t.client, req)
+}
+
+// Acknowledge implements Channel.
+func (t *Teams) Acknowledge(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// accessToken returns a cached Bot Framework token, requesting a new one
+// shortly before it expires.
+func (t *Teams) accessToken(ctx context.Context) (string, error) {
+	if t.config.AppID == "" {
+		// Unauthenticated replies are accepted by the Bot Framework Emulator
+		return "", nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.token != "" && time.Now().Before(t.tokenExpiry) {
+		return t.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.config.AppID},
+		"client_secret": {t.config.AppPassword},
+		"scope":         {teamsTokenScope},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	t.token = token.AccessToken
+	t.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
+
+// doRequest sends req and turns non-2xx responses into errors.
+func doRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package channels
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+)
+
+// botFramework fakes the Bot Framework login, key and connector endpoints.
+type botFramework struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mutex      sync.Mutex
+	tokenCalls int
+	replies    []map[string]interface{}
+	authHeader string
+	replyPath  string
+}
+
+func newBotFramework(t *testing.T) *botFramework {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	bf := &botFramework{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openid", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": bf.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		bf.mutex.Lock()
+		bf.tokenCalls++
+		bf.mutex.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "outbound-token", "expires_in": 3600})
+	})
+	mux.HandleFunc("/v3/conversations/", func(w http.ResponseWriter, r *http.Request) {
+		var activity map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&activity)
+		bf.mutex.Lock()
+		bf.replies = append(bf.replies, activity)
+		bf.authHeader = r.Header.Get("Authorization")
+		bf.replyPath = r.URL.EscapedPath()
+		bf.mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	})
+	bf.server = httptest.NewServer(mux)
+	t.Cleanup(bf.server.Close)
+	return bf
+}
+
+// sign creates an RS256 token with the given claims.
+func (bf *botFramework) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, bf.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (bf *botFramework) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": teamsIssuer,
+		"aud": "app-id",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func (bf *botFramework) channel() *Teams {
+	return NewTeams(TeamsConfig{
+		AppID:           "app-id",
+		AppPassword:     "secret",
+		TokenURL:        bf.server.URL + "/token",
+		OpenIDConfigURL: bf.server.URL + "/openid",
+	})
+}
+
+func (bf *botFramework) activity(activityType, text string) string {
+	activity, _ := json.Marshal(map[string]interface{}{
+		"type":         activityType,
+		"id":           "activity-1",
+		"text":         text,
+		"serviceUrl":   bf.server.URL,
+		"from":         map[string]string{"id": "29:user", "name": "Ada", "aadObjectId": "aad-user"},
+		"recipient":    map[string]string{"id": "28:bot", "name": "Bot"},
+		"conversation": map[string]string{"id": "a:conv/1"},
+	})
+	return string(activity)
+}
+
+func teamsRequest(body, token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/channels/teams", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestTeamsReply(t *testing.T) {
+	bf := newBotFramework(t)
+	model := chatbottest.NewMockModel("Hello Ada")
+	handler := Handler(newTestBot(t, model), bf.channel(), WithSynchronousReplies())
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, teamsRequest(bf.activity("message", " hi "), bf.sign(t, bf.validClaims())))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	call, _ := model.LastCall()
+	if call.Message != "hi" || call.Context["user_id"] != "teams:aad-user" {
+		t.Errorf("unexpected model call: %+v", call)
+	}
+
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+	if len(bf.replies) != 2 {
+		t.Fatalf("expected 2 replies, got %d", len(bf.replies))
+	}
+	if bf.replies[0]["text"] != "Hello Ada" || bf.replies[0]["replyToId"] != "activity-1" {
+		t.Errorf("unexpected reply activity: %v", bf.replies[0])
+	}
+	if bf.replyPath != "/v3/conversations/a:conv%2F1/activities/activity-1" {
+		t.Errorf("unexpected reply path %q", bf.replyPath)
+	}
+	if bf.authHeader != "Bearer outbound-token" {
+		t.Errorf("unexpected reply authorization %q", bf.authHeader)
+	}
+	if bf.tokenCalls != 1 {
+		t.Errorf("expected the outbound token to be cached, got %d token requests", bf.tokenCalls)
+	}
+}
+
+func TestTeamsRejectsInvalidTokens(t *testing.T) {
+	bf := newBotFramework(t)
+
+	claims := func(key string, value interface{}) map[string]interface{} {
+		c := bf.validClaims()
+		c[key] = value
+		return c
+	}
+	tampered := bf.sign(t, bf.validClaims())
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := map[string]string{
+		"missing":      "",
+		"malformed":    "not-a-token",
+		"tampered":     tampered,
+		"wrong issuer": bf.sign(t, claims("iss", "https://evil.example")),
+		"wrong aud":    bf.sign(t, claims("aud", "other-app")),
+		"expired":      bf.sign(t, claims("exp", time.Now().Add(-time.Hour).Unix())),
+		"not yet":      bf.sign(t, claims("nbf", time.Now().Add(time.Hour).Unix())),
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bf.channel().ParseInbound(teamsRequest(bf.activity("message", "hi"), token))
+			if !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("expected unauthorized error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTeamsIgnoresNonMessageActivities(t *testing.T) {
+	bf := newBotFramework(t)
+	_, err := bf.channel().ParseInbound(teamsRequest(bf.activity("conversationUpdate", ""), bf.sign(t, bf.validClaims())))
+	if err != ErrIgnored {
+		t.Errorf("expected ErrIgnored, got %v", err)
+	}
+}
+
+func TestTeamsSkipVerification(t *testing.T) {
+	bf := newBotFramework(t)
+	teams := NewTeams(TeamsConfig{SkipVerification: true})
+
+	msg, err := teams.ParseInbound(teamsRequest(bf.activity("message", "hi"), ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := teams.SendReply(t.Context(), msg, "hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+	if bf.authHeader != "" || bf.tokenCalls != 0 {
+		t.Error("expected unauthenticated reply without app credentials")
+	}
+}
+
+func TestTeamsSendReplyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		http.Error(w, "conversation not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	teams := NewTeams(TeamsConfig{SkipVerification: true})
+	msg := &InboundMessage{Raw: &teamsActivity{ID: "1", ServiceURL: server.URL}}
+	err := teams.SendReply(t.Context(), msg, "hello")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
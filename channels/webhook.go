@@ -0,0 +1,213 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.rumenx.com/chatbot/models"
+)
+
+// Headers used by the webhook channel.
+const (
+	WebhookSignatureHeader = "X-Chatbot-Signature"
+	WebhookTimestampHeader = "X-Chatbot-Timestamp"
+	WebhookDeliveryHeader  = "X-Chatbot-Delivery"
+)
+
+// WebhookConfig configures the generic webhook channel.
+type WebhookConfig struct {
+	// Secret signs inbound and outbound requests. Inbound requests with a
+	// missing or invalid signature are rejected.
+	Secret string
+	// CallbackURL receives the replies.
+	CallbackURL string
+	// MaxSkew is how far an inbound timestamp may be from the current time
+	// (default 5 minutes). It limits replay of captured requests.
+	MaxSkew time.Duration
+	// Retry controls callback retries (default models.DefaultRetryPolicy).
+	Retry *models.RetryPolicy
+	// Headers are added to every callback request, e.g. an API key.
+	Headers map[string]string
+	// HTTPClient overrides the client used for callbacks.
+	HTTPClient *http.Client
+}
+
+// WebhookMessage is the JSON body of an inbound webhook request.
+type WebhookMessage struct {
+	ID             string                 `json:"id,omitempty"`
+	ConversationID string                 `json:"conversation_id,omitempty"`
+	UserID         string                 `json:"user_id"`
+	UserName       string                 `json:"user_name,omitempty"`
+	Text           string                 `json:"text"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// WebhookReply is the JSON body posted to the callback URL.
+type WebhookReply struct {
+	ID             string                 `json:"id"`
+	InReplyTo      string                 `json:"in_reply_to,omitempty"`
+	ConversationID string                 `json:"conversation_id,omitempty"`
+	UserID         string                 `json:"user_id"`
+	Text           string                 `json:"text"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+}
+
+// Webhook is a Channel that connects arbitrary systems: messages arrive as
+// signed JSON webhooks and replies are posted, signed, to a callback URL.
+//
+// Requests in both directions carry a Unix timestamp in X-Chatbot-Timestamp
+// and an X-Chatbot-Signature of "sha256=" followed by the hex HMAC-SHA256 of
+// the timestamp, a dot and the body. See WebhookSignature.
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWebhook creates a webhook channel.
+func NewWebhook(cfg WebhookConfig) *Webhook {
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+	policy := models.DefaultRetryPolicy()
+	if cfg.Retry != nil {
+		policy = *cfg.Retry
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	if cfg.HTTPClient != nil {
+		copied := *cfg.HTTPClient
+		client = &copied
+	}
+	client.Transport = policy.Transport(client.Transport)
+
+	return &Webhook{config: cfg, client: client, now: time.Now}
+}
+
+// WebhookSignature returns the signature header value for a body sent at timestamp.
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Name implements Channel.
+func (wh *Webhook) Name() string {
+	return "webhook"
+}
+
+// ParseInbound implements Channel.
+func (wh *Webhook) ParseInbound(r *http.Request) (*InboundMessage, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook: %w", err)
+	}
+
+	if err := wh.verify(r.Header, body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	var message WebhookMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("invalid webhook message: %w", err)
+	}
+	if strings.TrimSpace(message.Text) == "" {
+		return nil, ErrIgnored
+	}
+	if message.UserID == "" {
+		return nil, fmt.Errorf("webhook message has no user_id")
+	}
+
+	return &InboundMessage{
+		Channel:        wh.Name(),
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		ExternalUserID: message.UserID,
+		UserName:       message.UserName,
+		Text:           strings.TrimSpace(message.Text),
+		Raw:            &message,
+	}, nil
+}
+
+// SendReply implements Channel by posting a signed WebhookReply to the
+// callback URL. Failed deliveries are retried with the same delivery ID so
+// the receiver can drop duplicates.
+func (wh *Webhook) SendReply(ctx context.Context, msg *InboundMessage, reply string) error {
+	if wh.config.CallbackURL == "" {
+		return fmt.Errorf("webhook callback URL is not configured")
+	}
+
+	out := WebhookReply{
+		ID:             uuid.New().String(),
+		InReplyTo:      msg.ID,
+		ConversationID: msg.ConversationID,
+		UserID:         msg.ExternalUserID,
+		Text:           reply,
+		Timestamp:      wh.now().UTC(),
+	}
+	if in, ok := msg.Raw.(*WebhookMessage); ok {
+		out.Metadata = in.Metadata
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.config.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range wh.config.Headers {
+		req.Header.Set(key, value)
+	}
+	timestamp := out.Timestamp.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookDeliveryHeader, out.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(wh.config.Secret, timestamp, body))
+
+	return doRequest(wh.client, req)
+}
+
+// Acknowledge implements Channel.
+func (wh *Webhook) Acknowledge(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify checks the timestamp and signature headers of an inbound request.
+func (wh *Webhook) verify(header http.Header, body []byte) error {
+	if wh.config.Secret == "" {
+		return fmt.Errorf("webhook secret is not configured")
+	}
+
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid timestamp")
+	}
+	skew := wh.now().Sub(time.Unix(timestamp, 0))
+	if skew > wh.config.MaxSkew || skew < -wh.config.MaxSkew {
+		return fmt.Errorf("timestamp outside the allowed window")
+	}
+
+	expected := WebhookSignature(wh.config.Secret, timestamp, body)
+	if subtle.ConstantTimeCompare([]byte(header.Get(WebhookSignatureHeader)), []byte(expected)) != 1 {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
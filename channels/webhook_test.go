@@ -0,0 +1,141 @@
+package channels
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/models"
+)
+
+func signedWebhookRequest(secret string, timestamp int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/channels/webhook", strings.NewReader(body))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(secret, timestamp, []byte(body)))
+	return req
+}
+
+func TestWebhookRoundTrip(t *testing.T) {
+	var mutex sync.Mutex
+	var attempts int
+	var deliveries []string
+	var received WebhookReply
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		attempts++
+		deliveries = append(deliveries, r.Header.Get(WebhookDeliveryHeader))
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(WebhookSignatureHeader) != WebhookSignature("s3cret", timestamp, body) {
+			t.Error("callback signature mismatch")
+		}
+		if r.Header.Get("X-Api-Key") != "key" {
+			t.Error("expected configured callback header")
+		}
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer callback.Close()
+
+	webhook := NewWebhook(WebhookConfig{
+		Secret:      "s3cret",
+		CallbackURL: callback.URL,
+		Headers:     map[string]string{"X-Api-Key": "key"},
+		Retry:       &models.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	model := chatbottest.NewMockModel("pong")
+	handler := Handler(newTestBot(t, model), webhook, WithSynchronousReplies())
+
+	body := `{"id":"m1","conversation_id":"c1","user_id":"u1","text":"ping","metadata":{"ticket":7}}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedWebhookRequest("s3cret", time.Now().Unix(), body))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if attempts != 2 || deliveries[0] == "" || deliveries[0] != deliveries[1] {
+		t.Errorf("expected one retry with a stable delivery ID, got %v", deliveries)
+	}
+	if received.Text != "pong" || received.InReplyTo != "m1" || received.ConversationID != "c1" || received.UserID != "u1" {
+		t.Errorf("unexpected reply: %+v", received)
+	}
+	if received.Metadata["ticket"] != float64(7) {
+		t.Errorf("expected metadata to be echoed, got %v", received.Metadata)
+	}
+}
+
+func TestWebhookRejectsInvalidRequests(t *testing.T) {
+	webhook := NewWebhook(WebhookConfig{Secret: "s3cret"})
+	body := `{"user_id":"u1","text":"ping"}`
+	now := time.Now().Unix()
+
+	tampered := signedWebhookRequest("s3cret", now, body)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"user_id":"admin","text":"ping"}`))
+
+	tests := map[string]*http.Request{
+		"unsigned":  httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)),
+		"wrong key": signedWebhookRequest("other", now, body),
+		"tampered":  tampered,
+		"stale":     signedWebhookRequest("s3cret", now-3600, body),
+		"future":    signedWebhookRequest("s3cret", now+3600, body),
+		"no secret": signedWebhookRequest("", now, body),
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			wh := webhook
+			if name == "no secret" {
+				wh = NewWebhook(WebhookConfig{})
+			}
+			if _, err := wh.ParseInbound(req); !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("expected unauthorized error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookSendReplyGivesUp(t *testing.T) {
+	var mutex sync.Mutex
+	var attempts int
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		attempts++
+		mutex.Unlock()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer callback.Close()
+
+	webhook := NewWebhook(WebhookConfig{
+		Secret:      "s3cret",
+		CallbackURL: callback.URL,
+		Retry:       &models.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+	})
+	err := webhook.SendReply(t.Context(), &InboundMessage{ExternalUserID: "u1"}, "pong")
+
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected status error, got %v", err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
@@ -0,0 +1,175 @@
+package channels
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- Twilio signs webhooks with HMAC-SHA1
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const twilioAPIURL = "https://api.twilio.com"
+
+// WhatsAppConfig configures the WhatsApp channel via Twilio.
+type WhatsAppConfig struct {
+	// AccountSID and AuthToken are the Twilio account credentials. The auth
+	// token also verifies inbound webhook signatures.
+	AccountSID string
+	AuthToken  string
+	// From is the sender number, e.g. "whatsapp:+14155238886". It defaults
+	// to the number the inbound message was sent to.
+	From string
+	// WebhookURL is the public URL Twilio posts to. Set it when the server
+	// runs behind a proxy, since signatures cover the URL Twilio called.
+	WebhookURL string
+	// SkipVerification disables webhook signature checks. Only use it for
+	// local development.
+	SkipVerification bool
+
+	// APIURL and HTTPClient override the Twilio defaults, mainly for testing.
+	APIURL     string
+	HTTPClient *http.Client
+}
+
+// WhatsApp is a Channel for WhatsApp via the Twilio Messaging API.
+type WhatsApp struct {
+	config WhatsAppConfig
+	client *http.Client
+}
+
+// NewWhatsApp creates a WhatsApp channel.
+func NewWhatsApp(cfg WhatsAppConfig) *WhatsApp {
+	if cfg.APIURL == "" {
+		cfg.APIURL = twilioAPIURL
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &WhatsApp{config: cfg, client: client}
+}
+
+// Name implements Channel.
+func (w *WhatsApp) Name() string {
+	return "whatsapp"
+}
+
+// ParseInbound implements Channel. Requests must carry a valid
+// X-Twilio-Signature unless verification is disabled.
+func (w *WhatsApp) ParseInbound(r *http.Request) (*InboundMessage, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, 1<<20)
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("invalid webhook form: %w", err)
+	}
+
+	if !w.config.SkipVerification {
+		if !w.validSignature(r) {
+			return nil, ErrUnauthorized
+		}
+	}
+
+	text := strings.TrimSpace(r.PostForm.Get("Body"))
+	from := r.PostForm.Get("From")
+	if text == "" || from == "" {
+		return nil, ErrIgnored
+	}
+
+	return &InboundMessage{
+		Channel:        w.Name(),
+		ID:             r.PostForm.Get("MessageSid"),
+		ConversationID: from,
+		ExternalUserID: strings.TrimPrefix(from, "whatsapp:"),
+		UserName:       r.PostForm.Get("ProfileName"),
+		Text:           text,
+		Raw:            r.PostForm.Get("To"),
+	}, nil
+}
+
+// SendReply implements Channel by creating a message with the Twilio API.
+func (w *WhatsApp) SendReply(ctx context.Context, msg *InboundMessage, reply string) error {
+	from := w.config.From
+	if from == "" {
+		from, _ = msg.Raw.(string)
+	}
+
+	form := url.Values{
+		"From": {from},
+		"To":   {msg.ConversationID},
+		"Body": {reply},
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json",
+		strings.TrimRight(w.config.APIURL, "/"), url.PathEscape(w.config.AccountSID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(w.config.AccountSID, w.config.AuthToken)
+
+	return doRequest(w.client, req)
+}
+
+// Acknowledge implements Channel with an empty TwiML response, since the
+// reply is sent through the API.
+func (w *WhatsApp) Acknowledge(rw http.ResponseWriter) {
+	rw.Header().Set("Content-Type", "text/xml")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write([]byte("<Response></Response>"))
+}
+
+// validSignature checks the X-Twilio-Signature header: a base64 HMAC-SHA1 of
+// the webhook URL followed by every POST parameter name and value, sorted by name.
+func (w *WhatsApp) validSignature(r *http.Request) bool {
+	signature := r.Header.Get("X-Twilio-Signature")
+	if signature == "" {
+		return false
+	}
+
+	expected := twilioSignature(w.config.AuthToken, w.webhookURL(r), r.PostForm)
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1
+}
+
+// webhookURL reconstructs the URL Twilio called.
+func (w *WhatsApp) webhookURL(r *http.Request) string {
+	if w.config.WebhookURL != "" {
+		return w.config.WebhookURL
+	}
+
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// twilioSignature computes a Twilio request signature.
+func twilioSignature(authToken, webhookURL string, params url.Values) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data strings.Builder
+	data.WriteString(webhookURL)
+	for _, name := range names {
+		for _, value := range params[name] {
+			data.WriteString(name)
+			data.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,125 @@
+package channels
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+)
+
+const testWebhookURL = "https://example.com/channels/whatsapp"
+
+func whatsAppRequest(form url.Values, authToken string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, testWebhookURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if authToken != "" {
+		req.Header.Set("X-Twilio-Signature", twilioSignature(authToken, testWebhookURL, form))
+	}
+	return req
+}
+
+func inboundForm() url.Values {
+	return url.Values{
+		"MessageSid":  {"SM123"},
+		"From":        {"whatsapp:+15550001111"},
+		"To":          {"whatsapp:+15550002222"},
+		"Body":        {"Where is my order?"},
+		"ProfileName": {"Ada"},
+	}
+}
+
+func TestTwilioSignature(t *testing.T) {
+	// Example from Twilio's webhook security documentation
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	got := twilioSignature("12345", "https://mycompany.com/myapp.php?foo=1&bar=2", params)
+	if got != "0/KCTR6DLpKmkAf8muzZqo1nDgQ=" {
+		t.Errorf("unexpected signature %q", got)
+	}
+}
+
+func TestWhatsAppReply(t *testing.T) {
+	var mutex sync.Mutex
+	var sent url.Values
+	var path, user, pass string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mutex.Lock()
+		sent, path = r.PostForm, r.URL.Path
+		user, pass, _ = r.BasicAuth()
+		mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer api.Close()
+
+	model := chatbottest.NewMockModel("It ships tomorrow")
+	whatsapp := NewWhatsApp(WhatsAppConfig{AccountSID: "AC1", AuthToken: "token", APIURL: api.URL})
+	handler := Handler(newTestBot(t, model), whatsapp, WithSynchronousReplies())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, whatsAppRequest(inboundForm(), "token"))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "<Response></Response>" {
+		t.Errorf("expected empty TwiML, got %d: %q", rec.Code, rec.Body.String())
+	}
+
+	call, _ := model.LastCall()
+	if call.Message != "Where is my order?" || call.Context["user_id"] != "whatsapp:+15550001111" {
+		t.Errorf("unexpected model call: %+v", call)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if path != "/2010-04-01/Accounts/AC1/Messages.json" || user != "AC1" || pass != "token" {
+		t.Errorf("unexpected API request to %s as %s", path, user)
+	}
+	if sent.Get("To") != "whatsapp:+15550001111" || sent.Get("From") != "whatsapp:+15550002222" || sent.Get("Body") != "It ships tomorrow" {
+		t.Errorf("unexpected message: %v", sent)
+	}
+}
+
+func TestWhatsAppRejectsBadSignature(t *testing.T) {
+	whatsapp := NewWhatsApp(WhatsAppConfig{AuthToken: "token"})
+
+	for name, req := range map[string]*http.Request{
+		"missing": whatsAppRequest(inboundForm(), ""),
+		"wrong":   whatsAppRequest(inboundForm(), "other-token"),
+	} {
+		if _, err := whatsapp.ParseInbound(req); err != ErrUnauthorized {
+			t.Errorf("%s: expected ErrUnauthorized, got %v", name, err)
+		}
+	}
+}
+
+func TestWhatsAppWebhookURL(t *testing.T) {
+	// Behind a proxy the request URL differs from the one Twilio signed
+	whatsapp := NewWhatsApp(WhatsAppConfig{AuthToken: "token", WebhookURL: testWebhookURL})
+	req := whatsAppRequest(inboundForm(), "token")
+	req.Host = "internal:8080"
+
+	msg, err := whatsapp.ParseInbound(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.UserName != "Ada" || msg.ExternalUserID != "+15550001111" || msg.ID != "SM123" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+}
+
+func TestWhatsAppIgnoresStatusCallbacks(t *testing.T) {
+	whatsapp := NewWhatsApp(WhatsAppConfig{AuthToken: "token"})
+	form := url.Values{"MessageSid": {"SM123"}, "MessageStatus": {"delivered"}}
+
+	if _, err := whatsapp.ParseInbound(whatsAppRequest(form, "token")); err != ErrIgnored {
+		t.Errorf("expected ErrIgnored, got %v", err)
+	}
+}
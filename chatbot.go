@@ -10,12 +10,28 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/answercache"
+	"go.rumenx.com/chatbot/budget"
+	"go.rumenx.com/chatbot/chaterrors"
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/faq"
+	"go.rumenx.com/chatbot/glossary"
+	"go.rumenx.com/chatbot/guardrails"
+	"go.rumenx.com/chatbot/internal/logging"
 	"go.rumenx.com/chatbot/middleware"
 	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/packing"
+	"go.rumenx.com/chatbot/schema"
+	"go.rumenx.com/chatbot/speech"
 	"go.rumenx.com/chatbot/streaming"
+	"go.rumenx.com/chatbot/translate"
 )
 
 // Chatbot represents the main chatbot instance.
@@ -23,8 +39,64 @@ type Chatbot struct {
 	config    *config.Config
 	model     models.Model
 	filter    *middleware.ChatMessageFilter
-	rateLimit *middleware.RateLimiter
+	rateLimit middleware.Limiter
 	timeout   time.Duration
+	hooks     Hooks
+	publisher events.Publisher
+	handoff   Handoff
+	dialogs   []Dialog
+	sentiment middleware.SentimentAnalyzer
+	// lexicon scores sentiment for de-escalation without an analyzer; it is
+	// created when de-escalation is first enabled, by New or Reload
+	lexicon   atomic.Pointer[middleware.LexiconAnalyzer]
+	stt       speech.Transcriber
+	tts       speech.Synthesizer
+	guard     *guardrails.Guardrails
+	shadow    func(models.Model) models.Model
+	budget    budget.Plan
+	fastModel models.Model
+	fastBelow time.Duration
+	retriever Retriever
+	depth     int // passages to retrieve
+	packer    *packing.Packer
+	faq       *faq.Matcher
+	rephrase  bool // paraphrase FAQ answers
+	answers   *answercache.Cache
+	glossary  *glossary.Glossary
+	memory    Memory
+	format    *streaming.Format
+	limits    streaming.Limits
+	marshal   ResponseMarshaler
+	mutex     sync.RWMutex
+
+	promptText     string
+	promptTemplate *template.Template
+	promptLimits   PromptLimits
+
+	conversations Conversations
+	historyLength int         // stored messages sent as history
+	stateless     bool        // answer without the store when it fails
+	retries       *RetryQueue // exchanges to save again, or nil
+	outbox        bool        // store events with exchanges
+	stateLimit    int         // bytes of tool state per conversation, 0 for the default
+
+	translator        translate.Translator
+	knowledgeLanguage string // "" for the configured language
+
+	structuredRepairs *int // nil for the default
+	structuredStats   schema.Counter
+	toolArgumentStats schema.Counter
+
+	logger   Logger
+	panics   atomic.Uint64 // recovered panics
+	sessions *SessionCookieOptions
+	csrf     *CSRFOptions
+
+	maintenance maintenanceState
+	analytics   analytics.Exporter
+	name        string // set with WithName, "" if unnamed
+	// sharedRateLimit is set when rateLimit is shared by a Registry
+	sharedRateLimit bool
 }
 
 // Option represents a configuration option for the Chatbot.
@@ -51,8 +123,9 @@ func WithFilter(filter *middleware.ChatMessageFilter) Option {
 	}
 }
 
-// WithRateLimit sets a custom rate limiter.
-func WithRateLimit(limiter *middleware.RateLimiter) Option {
+// WithRateLimit sets a custom rate limiter, e.g. a
+// middleware.RedisRateLimiter to share limits between instances.
+func WithRateLimit(limiter middleware.Limiter) Option {
 	return func(c *Chatbot) {
 		c.rateLimit = limiter
 	}
@@ -86,6 +159,26 @@ func New(cfg *config.Config, opts ...Option) (*Chatbot, error) {
 		}
 		chatbot.model = model
 	}
+	if chatbot.shadow != nil {
+		chatbot.model = chatbot.shadow(chatbot.model)
+	}
+
+	chatbot.promptTemplate, err = parsePromptTemplate(chatbot.promptText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	if err := chatbot.SetMaintenance(cfg.Maintenance); err != nil {
+		return nil, err
+	}
+	if _, ok := chatbot.conversations.(OutboxConversations); chatbot.outbox && !ok {
+		return nil, errors.New("event outbox needs WithConversations with a store keeping events")
+	}
+	if chatbot.analytics == nil {
+		chatbot.analytics, err = analytics.FromConfig(cfg.Analytics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create analytics exporter: %w", err)
+		}
+	}
 
 	// Create message filter
 	if chatbot.filter == nil {
@@ -97,6 +190,8 @@ func New(cfg *config.Config, opts ...Option) (*Chatbot, error) {
 		chatbot.rateLimit = middleware.NewRateLimiter(cfg.RateLimit)
 	}
 
+	chatbot.enableLexicon(cfg)
+
 	return chatbot, nil
 }
 
@@ -104,7 +199,7 @@ func New(cfg *config.Config, opts ...Option) (*Chatbot, error) {
 // It applies message filtering and rate limiting before processing.
 func (c *Chatbot) Ask(ctx context.Context, message string, options ...AskOption) (string, error) {
 	if message == "" {
-		return "", errors.New("message cannot be empty")
+		return "", fmt.Errorf("%w: message cannot be empty", chaterrors.ErrInvalidInput)
 	}
 
 	// Create context with timeout
@@ -113,6 +208,18 @@ func (c *Chatbot) Ask(ctx context.Context, message string, options ...AskOption)
 		ctx, cancel = context.WithTimeout(ctx, c.timeout)
 		defer cancel()
 	}
+	ctx, b := c.newBudget(ctx)
+	ctx = logging.NewContext(ctx, c.logf)
+
+	// Turn new chats away during maintenance
+	if err := c.checkMaintenance(ctx); err != nil {
+		return "", err
+	}
+
+	// Parse options, identifying the user for rate limiting
+	askOpts := newAskOptions(options)
+	ctx = identify(ctx, askOpts.context)
+	ctx = c.scopeRateLimit(ctx, config.RateLimitRouteChat, askOpts.context)
 
 	// Apply rate limiting
 	if c.rateLimit != nil {
@@ -121,25 +228,128 @@ func (c *Chatbot) Ask(ctx context.Context, message string, options ...AskOption)
 		}
 	}
 
+	// Only continue conversations of the user
+	if err := c.checkConversation(ctx, askOpts); err != nil {
+		return "", err
+	}
+
 	// Apply message filtering
-	filtered, err := c.filter.Handle(ctx, message)
+	filterCtx, done := stage(ctx, b, budget.StageFilter)
+	filtered, err := c.filter.Handle(filterCtx, message)
+	if err == nil {
+		err = c.filterClientMessages(filterCtx, askOpts)
+	}
+	done()
 	if err != nil {
 		return "", fmt.Errorf("message filtering failed: %w", err)
 	}
+	askOpts.addFilterContext(filtered)
 
-	// Parse options
-	askOpts := &askOptions{
-		context: filtered.Context,
+	// Start a conversation only once the request passed the checks, so
+	// rejected requests store nothing
+	if askOpts.newConversation {
+		if _, err := c.openConversation(ctx, askOpts.context); err != nil {
+			return "", err
+		}
 	}
-	for _, opt := range options {
-		opt(askOpts)
+
+	// Take turns with concurrent requests on the conversation until the
+	// exchange is stored
+	unlock, err := c.lockConversation(ctx, askOpts.context)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Keep the events of the request for the outbox, publishing those not
+	// stored with an exchange when done
+	if outbox := c.newEventOutbox(askOpts.context); outbox != nil {
+		ctx = context.WithValue(ctx, eventOutboxKey{}, outbox)
+		defer func() { c.sendEvents(ctx, outbox.take()) }()
+	}
+	c.applyDefaults(askOpts)
+	if err := c.renderPrompt(askOpts); err != nil {
+		return "", err
+	}
+	c.assessSentiment(ctx, message, askOpts.context)
+	c.publishReceived(ctx, message, filtered, askOpts.context)
+	c.trackChatStarted(ctx, config.RateLimitRouteChat, filtered.Message, askOpts.context)
+
+	// Leave the conversation to a human operator if it was handed off
+	if paused, err := c.intercepted(ctx, filtered.Message, askOpts.context); err != nil {
+		return "", fmt.Errorf("handoff check failed: %w", err)
+	} else if paused {
+		return "", ErrNeedsHuman
+	}
+
+	// Enforce guardrails on the message
+	prompt, refusal, err := c.guardInput(ctx, filtered.Message, askOpts.context)
+	if err == nil && refusal == "" {
+		refusal, err = c.guardClientMessages(ctx, askOpts)
+	}
+	if err != nil {
+		return "", err
+	}
+	if refusal != "" {
+		c.trackFallback(ctx, analytics.FallbackRefusal, nil, askOpts.context)
+		refusal = c.translateOut(ctx, prompt, refusal, askOpts.context)
+		c.saveExchange(ctx, prompt, refusal, askOpts.context)
+		return refusal, nil
+	}
+
+	// Work in the language of the knowledge base from here on
+	query := c.translateIn(ctx, prompt, askOpts.context)
+	if answer, ok, err := c.continueDialog(ctx, query, askOpts.context); err != nil {
+		return "", err
+	} else if ok {
+		answer = c.translateOut(ctx, prompt, answer, askOpts.context)
+		c.saveExchange(ctx, prompt, answer, askOpts.context)
+		return answer, nil
+	}
+	if answer, ok, err := c.answerFAQ(ctx, b, query, askOpts.context); err != nil {
+		return "", err
+	} else if ok {
+		answer = c.translateOut(ctx, prompt, answer, askOpts.context)
+		c.saveExchange(ctx, prompt, answer, askOpts.context)
+		return answer, nil
+	}
+	if err := c.loadHistory(ctx, askOpts.context); err != nil {
+		return "", err
+	}
+	if answer, ok := c.cachedAnswer(ctx, query, askOpts.context); ok {
+		answer = c.translateOut(ctx, prompt, answer, askOpts.context)
+		c.saveExchange(ctx, prompt, answer, askOpts.context)
+		return answer, nil
 	}
+	if err := c.retrieve(ctx, b, query, askOpts.context); err != nil {
+		return "", err
+	}
+	c.recall(ctx, query, askOpts.context)
+	c.learn(ctx, query, askOpts.context)
 
 	// Send to AI model
-	response, err := c.model.Ask(ctx, filtered.Message, askOpts.context)
+	model := c.modelFor(ctx, b, askOpts.context)
+	started := time.Now()
+	modelCtx, done := stage(ctx, b, budget.StageModel)
+	response, err := model.Ask(modelCtx, query, askOpts.context)
+	done()
 	if err != nil {
 		return "", fmt.Errorf("AI model request failed: %w", err)
 	}
+	if meta, ok := streaming.MetadataFromContext(ctx); ok && meta.Model == "" {
+		meta.Model = model.Name()
+	}
+	postCtx, done := stage(ctx, b, budget.StagePostProcess)
+	response, err = c.guardOutput(postCtx, response, askOpts.context)
+	done()
+	if err != nil {
+		return "", err
+	}
+	response = c.applyGlossary(response)
+	c.cacheAnswer(askOpts.context, response)
+	c.publishReply(ctx, model, query, response, started, askOpts.context)
+	response = c.translateOut(ctx, prompt, response, askOpts.context)
+	c.saveExchange(ctx, prompt, response, askOpts.context)
 
 	return response, nil
 }
@@ -148,7 +358,45 @@ func (c *Chatbot) Ask(ctx context.Context, message string, options ...AskOption)
 type AskOption func(*askOptions)
 
 type askOptions struct {
-	context map[string]interface{}
+	context       map[string]interface{}
+	streamMode    streaming.Mode
+	promptContext map[string]interface{}
+	// language and tone override the configured ones for the request
+	language, tone string
+	// user is set with WithUser; nil for the user of NewUserContext
+	user *User
+	// checkOwner is set by WithConversationID
+	checkOwner bool
+	// newConversation is set by AskResponse
+	newConversation bool
+	// clientMessages is set by ResolveMessages
+	clientMessages bool
+}
+
+// newAskOptions applies options to an empty request context. The user
+// keys of the context are only ever taken from WithUser: values passed
+// with WithContext, which may come from clients, are dropped.
+func newAskOptions(options []AskOption) *askOptions {
+	opts := &askOptions{context: make(map[string]interface{})}
+	for _, opt := range options {
+		opt(opts)
+	}
+	delete(opts.context, "user_id")
+	delete(opts.context, "user_attributes")
+	if opts.user != nil {
+		setUser(opts.context, *opts.user)
+	}
+	return opts
+}
+
+// addFilterContext adds what the message filter found to the request
+// context. Values set with options take precedence.
+func (opts *askOptions) addFilterContext(filtered *middleware.FilteredMessage) {
+	for key, value := range filtered.Context {
+		if _, ok := opts.context[key]; !ok {
+			opts.context[key] = value
+		}
+	}
 }
 
 // WithContext adds additional context to the AI request.
@@ -161,11 +409,82 @@ func WithContext(key string, value interface{}) AskOption {
 	}
 }
 
+// WithMaxTokens limits the length of the reply to a single request,
+// overriding the configured max_tokens.
+func WithMaxTokens(maxTokens int) AskOption {
+	return WithContext("max_tokens", maxTokens)
+}
+
+// WithStop ends the reply to a single request before the first of the
+// stop sequences. Providers that support them stop generating there, and
+// AskStream enforces them for all.
+func WithStop(sequences ...string) AskOption {
+	return WithContext("stop", sequences)
+}
+
+// WithThinking sets the extended thinking budget of a single request, in
+// tokens, for models that support it (Anthropic, at least 1024). A larger
+// budget trades latency for more thorough answers; 0 disables thinking.
+// When streaming, the thinking is sent in chunks of its own.
+func WithThinking(budgetTokens int) AskOption {
+	return WithContext("thinking_budget", budgetTokens)
+}
+
+// WithStreamFormat sets the SSE event names and payload transform used by
+// AskStream, so the stream can match an existing frontend contract, for
+// example streaming.OpenAIFormat.
+func WithStreamFormat(format streaming.Format) Option {
+	return func(c *Chatbot) {
+		c.format = &format
+	}
+}
+
+// WithStreamMode sets whether AskStream chunks carry deltas (the default)
+// or the full text so far.
+func WithStreamMode(mode streaming.Mode) AskOption {
+	return func(opts *askOptions) {
+		opts.streamMode = mode
+	}
+}
+
 // GetConfig returns the chatbot's configuration.
 func (c *Chatbot) GetConfig() *config.Config {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	return c.config
 }
 
+// applyDefaults fills in generation settings from the current configuration
+// that the caller did not override per request. Passing them with every
+// request, rather than only when the model is built, gives models set with
+// WithModel the same defaults and lets Reload change them.
+func (c *Chatbot) applyDefaults(opts *askOptions) {
+	cfg := c.GetConfig()
+	if cfg == nil {
+		return
+	}
+	if opts.context == nil {
+		opts.context = make(map[string]interface{})
+	}
+	if _, ok := opts.context["prompt"]; !ok && cfg.Prompt != "" {
+		opts.context["prompt"] = cfg.Prompt
+	}
+	gen := cfg.ModelGeneration()
+	if _, ok := opts.context["max_tokens"]; !ok && gen.MaxTokens > 0 {
+		opts.context["max_tokens"] = gen.MaxTokens
+	}
+	if _, ok := opts.context["temperature"]; !ok && gen.Temperature != nil {
+		opts.context["temperature"] = *gen.Temperature
+	}
+	if _, ok := opts.context["top_p"]; !ok && gen.TopP != nil {
+		opts.context["top_p"] = *gen.TopP
+	}
+	if _, ok := opts.context["stop"]; !ok && len(gen.Stop) > 0 {
+		opts.context["stop"] = gen.Stop
+	}
+}
+
 // GetModel returns the chatbot's AI model.
 func (c *Chatbot) GetModel() models.Model {
 	return c.model
@@ -189,10 +508,16 @@ func (c *Chatbot) Health(ctx context.Context) error {
 }
 
 // AskStream sends a message to the AI model and returns a streaming response.
-// It applies message filtering and rate limiting before processing.
+// It applies message filtering and rate limiting before processing. The
+// provider request is cancelled as soon as the stream ends, so a client that
+// disconnects stops the generation; once writing to it fails, an error
+// matching streaming.ErrClientDisconnected is returned. With
+// WithConversations, it keeps the history of the request's conversation
+// like Ask, starting one if the request has none, and sends the
+// conversation ID with the done chunk.
 func (c *Chatbot) AskStream(ctx context.Context, w http.ResponseWriter, message string, options ...AskOption) error {
 	if message == "" {
-		return errors.New("message cannot be empty")
+		return fmt.Errorf("%w: message cannot be empty", chaterrors.ErrInvalidInput)
 	}
 
 	// Create streaming handler
@@ -201,6 +526,9 @@ func (c *Chatbot) AskStream(ctx context.Context, w http.ResponseWriter, message
 		return fmt.Errorf("failed to create stream handler: %w", err)
 	}
 	defer streamHandler.Close()
+	if c.format != nil {
+		streamHandler.SetFormat(*c.format)
+	}
 
 	// Create context with timeout
 	if c.timeout > 0 {
@@ -208,57 +536,245 @@ func (c *Chatbot) AskStream(ctx context.Context, w http.ResponseWriter, message
 		ctx, cancel = context.WithTimeout(ctx, c.timeout)
 		defer cancel()
 	}
+	ctx, b := c.newBudget(ctx)
+	ctx = logging.NewContext(ctx, c.logf)
+	ctx = streaming.NewPanicContext(ctx, func(value interface{}, stack []byte) {
+		c.handlePanic(value, stack)
+	})
+
+	// Turn new chats away during maintenance; streams already running
+	// finish
+	if err := c.checkMaintenance(ctx); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return writeStreamError(streamHandler, err)
+	}
+
+	// Parse options, identifying the user for rate limiting
+	askOpts := newAskOptions(options)
+	ctx = identify(ctx, askOpts.context)
+	ctx = c.scopeRateLimit(ctx, config.RateLimitRouteStream, askOpts.context)
 
 	// Apply rate limiting
 	if c.rateLimit != nil {
 		if err := c.rateLimit.Allow(ctx); err != nil {
-			return streamHandler.WriteError("", fmt.Sprintf("rate limit exceeded: %v", err))
+			// Nothing was streamed yet, so the status can still be set
+			if setRateLimitHeaders(w.Header(), err) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+			return writeStreamError(streamHandler, fmt.Errorf("rate limit exceeded: %w", err))
+		}
+	}
+
+	// Only continue conversations of the user
+	if err := c.checkConversation(ctx, askOpts); err != nil {
+		if kind := chaterrors.KindOf(err); kind != nil {
+			w.WriteHeader(kind.Status)
 		}
+		return writeStreamError(streamHandler, err)
 	}
 
 	// Apply message filtering
-	filtered, err := c.filter.Handle(ctx, message)
+	filterCtx, finish := stage(ctx, b, budget.StageFilter)
+	filtered, err := c.filter.Handle(filterCtx, message)
+	if err == nil {
+		err = c.filterClientMessages(filterCtx, askOpts)
+	}
+	finish()
 	if err != nil {
-		return streamHandler.WriteError("", fmt.Sprintf("message filtering failed: %v", err))
+		return writeStreamError(streamHandler, fmt.Errorf("message filtering failed: %w", err))
 	}
+	askOpts.addFilterContext(filtered)
 
-	// Parse options
-	askOpts := &askOptions{
-		context: filtered.Context,
+	// Start a conversation if the request has none, once it passed the
+	// checks; its ID is sent with the done chunk so the client can
+	// continue it
+	conversationID, err := c.openConversation(ctx, askOpts.context)
+	if err != nil {
+		return writeStreamError(streamHandler, err)
 	}
-	for _, opt := range options {
-		opt(askOpts)
+	// Take turns with concurrent requests on the conversation
+	unlock, err := c.lockConversation(ctx, askOpts.context)
+	if err != nil {
+		return writeStreamError(streamHandler, err)
 	}
-
-	// Check if model supports streaming
-	streamingModel, isStreaming := c.model.(models.StreamingModel)
-	if !isStreaming {
+	defer func() { unlock() }()
+	done := &streaming.Metadata{ConversationID: conversationID}
+
+	// Keep the events of the request for the outbox, publishing those not
+	// stored with an exchange when done
+	if outbox := c.newEventOutbox(askOpts.context); outbox != nil {
+		ctx = context.WithValue(ctx, eventOutboxKey{}, outbox)
+		defer func() { c.sendEvents(ctx, outbox.take()) }()
+	}
+	if askOpts.streamMode != "" {
+		streamHandler.SetMode(askOpts.streamMode)
+	}
+	// Only the request's own stop sequences and max tokens tighten the
+	// stream limits; the configured ones are left to the provider
+	limits := c.streamLimits(askOpts.context)
+	c.applyDefaults(askOpts)
+	if err := c.renderPrompt(askOpts); err != nil {
+		return writeStreamError(streamHandler, err)
+	}
+	c.assessSentiment(ctx, message, askOpts.context)
+	c.publishReceived(ctx, message, filtered, askOpts.context)
+	c.trackChatStarted(ctx, config.RateLimitRouteStream, filtered.Message, askOpts.context)
+	if paused, err := c.intercepted(ctx, filtered.Message, askOpts.context); err != nil {
+		return writeStreamError(streamHandler, fmt.Errorf("handoff check failed: %w", err))
+	} else if paused {
+		return streamHandler.WriteError("", ErrNeedsHuman.Error())
+	}
+	prompt, refusal, err := c.guardInput(ctx, filtered.Message, askOpts.context)
+	if err == nil && refusal == "" {
+		refusal, err = c.guardClientMessages(ctx, askOpts)
+	}
+	if err != nil {
+		return writeStreamError(streamHandler, err)
+	}
+	if refusal != "" {
+		c.trackFallback(ctx, analytics.FallbackRefusal, nil, askOpts.context)
+		refusal = c.translateOut(ctx, prompt, refusal, askOpts.context)
+		c.saveExchange(ctx, prompt, refusal, askOpts.context)
+		return writeSingleChunk(streamHandler, refusal, done)
+	}
+	query := c.translateIn(ctx, prompt, askOpts.context)
+	if answer, ok, err := c.continueDialog(ctx, query, askOpts.context); err != nil {
+		return writeStreamError(streamHandler, err)
+	} else if ok {
+		answer = c.translateOut(ctx, prompt, answer, askOpts.context)
+		c.saveExchange(ctx, prompt, answer, askOpts.context)
+		return writeSingleChunk(streamHandler, answer, done)
+	}
+	if answer, ok, err := c.answerFAQ(ctx, b, query, askOpts.context); err != nil {
+		return writeStreamError(streamHandler, err)
+	} else if ok {
+		answer = c.translateOut(ctx, prompt, answer, askOpts.context)
+		c.saveExchange(ctx, prompt, answer, askOpts.context)
+		return writeSingleChunk(streamHandler, answer, done)
+	}
+	if err := c.loadHistory(ctx, askOpts.context); err != nil {
+		return writeStreamError(streamHandler, err)
+	}
+	if answer, ok := c.cachedAnswer(ctx, query, askOpts.context); ok {
+		answer = c.translateOut(ctx, prompt, answer, askOpts.context)
+		c.saveExchange(ctx, prompt, answer, askOpts.context)
+		return writeSingleChunk(streamHandler, answer, done)
+	}
+	if err := c.retrieve(ctx, b, query, askOpts.context); err != nil {
+		return writeStreamError(streamHandler, err)
+	}
+	c.recall(ctx, query, askOpts.context)
+	c.learn(ctx, query, askOpts.context)
+	model := c.modelFor(ctx, b, askOpts.context)
+	started := time.Now()
+
+	// Check if model supports streaming. Replies checked by guardrails or
+	// translated must be complete before any of them is sent.
+	streamingModel, isStreaming := model.(models.StreamingModel)
+	if !isStreaming || (c.guard != nil && c.guard.HasOutputRules()) || translating(askOpts.context) {
 		// Fallback to regular Ask and send as single chunk
-		response, err := c.model.Ask(ctx, filtered.Message, askOpts.context)
+		modelCtx, finish := stage(ctx, b, budget.StageModel)
+		response, err := model.Ask(modelCtx, query, askOpts.context)
+		finish()
 		if err != nil {
-			return streamHandler.WriteError("", fmt.Sprintf("AI model request failed: %v", err))
+			return writeStreamError(streamHandler, fmt.Errorf("AI model request failed: %w", err))
 		}
-
-		// Send as single chunk
-		err = streamHandler.WriteChunk(streaming.StreamResponse{
-			ID:      "single-chunk",
-			Content: response,
-			Done:    false,
-		})
+		if meta, ok := streaming.MetadataFromContext(ctx); ok && meta.Model == "" {
+			meta.Model = model.Name()
+		}
+		postCtx, finish := stage(ctx, b, budget.StagePostProcess)
+		response, err = c.guardOutput(postCtx, response, askOpts.context)
+		finish()
 		if err != nil {
-			return err
+			return writeStreamError(streamHandler, err)
 		}
+		response = c.applyGlossary(response)
+		c.cacheAnswer(askOpts.context, response)
+		c.publishReply(ctx, model, query, response, started, askOpts.context)
+		response = c.translateOut(ctx, prompt, response, askOpts.context)
+		response, done.FinishReason = limits.Apply(response)
+		c.saveExchange(ctx, prompt, response, askOpts.context)
 
-		return streamHandler.WriteDone("single-chunk")
+		// Send as single chunk
+		done.Model = model.Name()
+		return writeSingleChunk(streamHandler, response, done)
 	}
 
-	// Get streaming response
-	responseCh, err := streamingModel.AskStream(ctx, filtered.Message, askOpts.context)
+	// Get streaming response. Providers that report the finish reason and
+	// usage fill in meta for the done chunk.
+	// Models that reason before answering send their thinking separately.
+	meta, ok := streaming.MetadataFromContext(ctx)
+	if !ok {
+		meta = &streaming.Metadata{}
+	}
+	meta.Model = model.Name()
+	meta.ConversationID = conversationID
+	thinking := make(chan string)
+	// Once the stream ends, including when the client goes away, the
+	// provider request is cancelled so no more tokens are generated
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	streamCtx = streaming.NewThinkingContext(streaming.NewMetadataContext(streamCtx, meta), thinking)
+	responseCh, err := streamingModel.AskStream(streamCtx, query, askOpts.context)
 	if err != nil {
-		return streamHandler.WriteError("", fmt.Sprintf("streaming request failed: %v", err))
+		return writeStreamError(streamHandler, fmt.Errorf("streaming request failed: %w", err))
+	}
+
+	responseCh = c.streamGlossary(streamCtx, responseCh)
+
+	// Collect the streamed reply for the reply generated events
+	if c.publisher != nil || c.tracking() {
+		responseCh = c.collectReply(streamCtx, model, query, responseCh, started, askOpts.context)
+	}
+	if c.conversations != nil && conversationID != "" {
+		// The conversation stays locked until the streamed reply is stored
+		responseCh = c.saveStreamed(ctx, streamCtx, prompt, responseCh, limits, meta, askOpts.context, unlock)
+		unlock = func() {}
 	}
 
 	// Process streaming response
 	processor := streaming.NewStreamProcessor("stream", streamHandler)
+	processor.SetMetadata(meta)
+	processor.SetThinking(thinking)
+	processor.SetLimits(limits)
+	processor.SetCancel(cancel)
 	return processor.ProcessChannel(ctx, responseCh)
 }
+
+// WithStreamLimits sets stop sequences and a max tokens cap that AskStream
+// enforces on every reply, whether or not the provider does, to stop
+// runaway generations. Requests add stop sequences with WithStop and can
+// lower the cap with WithMaxTokens.
+func WithStreamLimits(limits streaming.Limits) Option {
+	return func(c *Chatbot) {
+		c.limits = limits
+	}
+}
+
+// streamLimits returns the limits AskStream enforces on the request's
+// reply: the chatbot's and the request's stop sequences, and the lower of
+// their max tokens.
+func (c *Chatbot) streamLimits(askContext map[string]interface{}) streaming.Limits {
+	limits := c.limits
+	if stop, ok := askContext["stop"].([]string); ok {
+		limits.Stop = append(append([]string(nil), limits.Stop...), stop...)
+	}
+	if maxTokens, ok := askContext["max_tokens"].(int); ok && maxTokens > 0 && (limits.MaxTokens == 0 || maxTokens < limits.MaxTokens) {
+		limits.MaxTokens = maxTokens
+	}
+	return limits
+}
+
+// writeSingleChunk sends a complete reply as one chunk, followed by the
+// done chunk carrying meta.
+func writeSingleChunk(handler *streaming.StreamHandler, content string, meta *streaming.Metadata) error {
+	if err := handler.WriteChunk(streaming.StreamResponse{ID: "single-chunk", Content: content}); err != nil {
+		return err
+	}
+	return handler.WriteDoneMetadata("single-chunk", meta)
+}
+
+// writeStreamError ends a stream with the code and message of err's kind.
+func writeStreamError(handler *streaming.StreamHandler, err error) error {
+	return handler.WriteErrorCode("", chaterrors.Code(err), chaterrors.Message(err))
+}
@@ -2,14 +2,19 @@ package gochatbot
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"go.rumenx.com/chatbot/chaterrors"
 	"go.rumenx.com/chatbot/config"
 	"go.rumenx.com/chatbot/middleware"
 	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestWithModel(t *testing.T) {
@@ -269,6 +274,93 @@ func TestChatbotAskStreamWithContext(t *testing.T) {
 	}
 }
 
+// metadataModel streams one chunk and reports stream metadata.
+type metadataModel struct{}
+
+func (metadataModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return "Hi", nil
+}
+
+func (metadataModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	ch := make(chan string, 1)
+	ch <- "Hi"
+	if meta, ok := streaming.MetadataFromContext(ctx); ok {
+		meta.FinishReason = "stop"
+		meta.Usage = &streaming.Usage{PromptTokens: 2, CompletionTokens: 1, TotalTokens: 3}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (metadataModel) Name() string     { return "metadata" }
+func (metadataModel) Provider() string { return "test" }
+
+func TestChatbotAskStreamMetadata(t *testing.T) {
+	chatbot, err := New(config.Default(), WithModel(metadataModel{}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := chatbot.AskStream(context.Background(), w, "Hello"); err != nil {
+		t.Fatalf("AskStream() error = %v", err)
+	}
+
+	want := `"done":true,"finish_reason":"stop","usage":{"prompt_tokens":2,"completion_tokens":1,"total_tokens":3},"model":"metadata"`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %s in the done chunk, got %s", want, w.Body.String())
+	}
+}
+
+// thinkingModel streams its thinking, then the answer, and records the
+// request context.
+type thinkingModel struct {
+	context map[string]interface{}
+}
+
+func (m *thinkingModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return "Hi", nil
+}
+
+func (m *thinkingModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	m.context = context
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		if thinking, ok := streaming.ThinkingFromContext(ctx); ok {
+			thinking <- "Hmm."
+		}
+		ch <- "Hi"
+	}()
+	return ch, nil
+}
+
+func (m *thinkingModel) Name() string     { return "thinking" }
+func (m *thinkingModel) Provider() string { return "test" }
+
+func TestChatbotAskStreamThinking(t *testing.T) {
+	model := &thinkingModel{}
+	chatbot, err := New(config.Default(), WithModel(model))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := chatbot.AskStream(context.Background(), w, "Hello", WithThinking(2048), WithMaxTokens(500)); err != nil {
+		t.Fatalf("AskStream() error = %v", err)
+	}
+
+	if model.context["thinking_budget"] != 2048 || model.context["max_tokens"] != 500 {
+		t.Errorf("expected the thinking budget and max tokens in the context, got %v", model.context)
+	}
+	body := w.Body.String()
+	thinking := strings.Index(body, `"thinking":"Hmm."`)
+	answer := strings.Index(body, `"content":"Hi"`)
+	if thinking < 0 || answer < thinking {
+		t.Errorf("expected a thinking chunk before the answer, got %s", body)
+	}
+}
+
 func TestChatbotAskEmptyMessage(t *testing.T) {
 	chatbot, err := New(&config.Config{Model: "free"})
 	if err != nil {
@@ -278,12 +370,8 @@ func TestChatbotAskEmptyMessage(t *testing.T) {
 	ctx := context.Background()
 	_, err = chatbot.Ask(ctx, "")
 
-	if err == nil {
-		t.Error("Expected error for empty message")
-	}
-
-	if !strings.Contains(err.Error(), "message cannot be empty") {
-		t.Errorf("Expected 'message cannot be empty' error, got: %v", err)
+	if !errors.Is(err, chaterrors.ErrInvalidInput) {
+		t.Errorf("Expected an invalid input error, got: %v", err)
 	}
 }
 
@@ -298,12 +386,8 @@ func TestChatbotAskStreamEmptyMessage(t *testing.T) {
 
 	err = chatbot.AskStream(ctx, w, "")
 
-	if err == nil {
-		t.Error("Expected error for empty message in stream")
-	}
-
-	if !strings.Contains(err.Error(), "message cannot be empty") {
-		t.Errorf("Expected 'message cannot be empty' error, got: %v", err)
+	if !errors.Is(err, chaterrors.ErrInvalidInput) {
+		t.Errorf("Expected an invalid input error in stream, got: %v", err)
 	}
 }
 
@@ -381,6 +465,53 @@ func TestChatbotAskStream_RateLimitExceeded(t *testing.T) {
 	}
 }
 
+func TestChatbot_RateLimitOverrides(t *testing.T) {
+	chatbot, err := New(&config.Config{
+		Model: "free",
+		RateLimit: config.RateLimitConfig{
+			RequestsPerMinute: 1,
+			Window:            time.Minute,
+			Overrides: []config.RateLimitOverride{
+				{Tier: "premium", RequestsPerMinute: 3},
+				{Route: config.RateLimitRouteStream, RequestsPerMinute: 2},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+	ctx := context.Background()
+
+	// Chats and streams of a user are limited separately
+	if _, err := chatbot.Ask(ctx, "Hello", WithUserID("u1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := chatbot.Ask(ctx, "Hello", WithUserID("u1")); !errors.Is(err, chaterrors.ErrRateLimited) {
+		t.Errorf("expected the chat limit, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := chatbot.AskStream(ctx, httptest.NewRecorder(), "Hello", WithUserID("u1")); err != nil {
+			t.Errorf("stream %d: unexpected error: %v", i, err)
+		}
+	}
+	w := httptest.NewRecorder()
+	chatbot.AskStream(ctx, w, "Hello", WithUserID("u1"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the stream limit, got %d", w.Code)
+	}
+
+	// The user's tier attribute selects its limits
+	premium := WithUser("u2", map[string]interface{}{"tier": "premium"})
+	for i := 0; i < 3; i++ {
+		if _, err := chatbot.Ask(ctx, "Hello", premium); err != nil {
+			t.Errorf("premium chat %d: unexpected error: %v", i, err)
+		}
+	}
+	if _, err := chatbot.Ask(ctx, "Hello", premium); !errors.Is(err, chaterrors.ErrRateLimited) {
+		t.Errorf("expected the premium limit, got %v", err)
+	}
+}
+
 func TestChatbotAskStream_WithTimeout(t *testing.T) {
 	chatbot, err := New(&config.Config{Model: "free"}, WithTimeout(1*time.Millisecond))
 	if err != nil {
@@ -468,3 +599,99 @@ func TestChatbotAskStream_ContextCancellation(t *testing.T) {
 		t.Logf("Got expected context cancellation: %v", err)
 	}
 }
+
+func TestChatbotGenerationDefaults(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxTokens = 300
+	cfg.Temperature = 0.5
+	model := &contextModel{}
+	chatbot, err := New(cfg, WithModel(model))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	if _, err := chatbot.Ask(context.Background(), "Hello"); err != nil {
+		t.Fatalf("Ask() error = %v", err)
+	}
+	if model.context["max_tokens"] != 300 || model.context["temperature"] != 0.5 {
+		t.Errorf("expected the configured defaults in the context, got %v", model.context)
+	}
+	if _, err := chatbot.Ask(context.Background(), "Hello", WithMaxTokens(50)); err != nil {
+		t.Fatalf("Ask() error = %v", err)
+	}
+	if model.context["max_tokens"] != 50 {
+		t.Errorf("expected the request's max tokens to win, got %v", model.context["max_tokens"])
+	}
+
+	// Reloaded settings reach the model without rebuilding it
+	next := config.Default()
+	next.MaxTokens = 100
+	next.Temperature = 0.5
+	if _, err := chatbot.Reload(next); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := chatbot.Ask(context.Background(), "Hello"); err != nil {
+		t.Fatalf("Ask() error = %v", err)
+	}
+	if model.context["max_tokens"] != 100 {
+		t.Errorf("expected the reloaded max tokens, got %v", model.context["max_tokens"])
+	}
+
+	// The selected provider's settings win over the global ones
+	cfg = config.Default()
+	cfg.Model = "openai"
+	cfg.Temperature = 0.5
+	cfg.OpenAI.Generation = config.GenerationConfig{MaxTokens: 800, Temperature: new(float64), Stop: []string{"END"}}
+	chatbot, err = New(cfg, WithModel(model))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+	if _, err := chatbot.Ask(context.Background(), "Hello"); err != nil {
+		t.Fatalf("Ask() error = %v", err)
+	}
+	if model.context["max_tokens"] != 800 || model.context["temperature"] != 0.0 {
+		t.Errorf("expected the provider's defaults in the context, got %v", model.context)
+	}
+	if stop, _ := model.context["stop"].([]string); len(stop) != 1 || stop[0] != "END" {
+		t.Errorf("expected the provider's stop sequences, got %v", model.context["stop"])
+	}
+}
+
+func TestChatbotGenerationDefaults_RequestBody(t *testing.T) {
+	var body struct {
+		MaxTokens   int      `json:"max_tokens"`
+		Temperature *float64 `json:"temperature"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Model = "openai"
+	cfg.OpenAI.APIKey = "key"
+	cfg.OpenAI.Endpoint = server.URL
+	cfg.MaxTokens = 200
+	cfg.Temperature = 0.3
+	chatbot, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	next := *cfg
+	next.MaxTokens = 120
+	next.Temperature = 0.9
+	if _, err := chatbot.Reload(&next); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := chatbot.Ask(context.Background(), "Hello"); err != nil {
+		t.Fatalf("Ask() error = %v", err)
+	}
+	if body.MaxTokens != 120 || body.Temperature == nil || *body.Temperature != 0.9 {
+		t.Errorf("expected the reloaded settings in the request, got max_tokens %d, temperature %v", body.MaxTokens, body.Temperature)
+	}
+}
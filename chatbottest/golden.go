@@ -0,0 +1,66 @@
+package chatbottest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// UpdateGoldenEnv is the environment variable that, when set to a non-empty
+// value, makes AssertGolden rewrite golden files instead of comparing:
+//
+//	CHATBOT_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "CHATBOT_UPDATE_GOLDEN"
+
+// GoldenPath returns the path of the named golden file, testdata/<name>.golden.
+func GoldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// AssertGolden compares got with the named golden file and fails the test
+// on mismatch. Set CHATBOT_UPDATE_GOLDEN to create or update the file.
+func AssertGolden(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	path := GoldenPath(name)
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("chatbottest: failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil { // #nosec G306 -- golden files are test fixtures
+			t.Fatalf("chatbottest: failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path) // #nosec G304 -- path is derived from the test name
+	if err != nil {
+		t.Fatalf("chatbottest: failed to read golden file (set %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("chatbottest: output does not match %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// AssertGoldenJSON marshals v as indented JSON and compares it with the
+// named golden file. Useful for HTTP handler responses.
+func AssertGoldenJSON(t testing.TB, name string, v interface{}) {
+	t.Helper()
+
+	// Normalize raw JSON so formatting differences do not matter
+	if raw, ok := v.([]byte); ok {
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("chatbottest: invalid JSON: %v", err)
+		}
+		v = decoded
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("chatbottest: failed to marshal JSON: %v", err)
+	}
+	AssertGolden(t, name, append(data, '\n'))
+}
@@ -0,0 +1,29 @@
+package chatbottest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertGolden(t *testing.T) {
+	AssertGolden(t, "plain", []byte("hello golden\n"))
+}
+
+func TestAssertGolden_Update(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	AssertGoldenJSON(t, "created", map[string]string{"reply": "ok"})
+
+	data, err := os.ReadFile(filepath.Join(dir, GoldenPath("created")))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"reply\": \"ok\"\n}\n", string(data))
+}
@@ -0,0 +1,305 @@
+// Package chatbottest provides test doubles and helpers for applications
+// built on the go-chatbot package, so handlers can be unit tested without
+// network calls or a database.
+package chatbottest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// DefaultReply is returned by a MockModel once its script is exhausted and
+// no fallback reply was configured.
+const DefaultReply = "mock reply"
+
+// Response is a single scripted outcome of a MockModel call.
+type Response struct {
+	// Reply is the text returned (or streamed) to the caller.
+	Reply string
+	// Err, if set, is returned instead of Reply.
+	Err error
+	// Latency delays the response, overriding the model-wide latency.
+	Latency time.Duration
+}
+
+// Call records a single request made to a MockModel.
+type Call struct {
+	Message string
+	Context map[string]interface{}
+	Stream  bool
+}
+
+// MockModel is a scriptable models.Model. Scripted responses are consumed in
+// order; once the script is exhausted every call returns the fallback reply.
+// It also implements models.StreamingModel and models.HealthChecker and is
+// safe for concurrent use.
+type MockModel struct {
+	mutex      sync.Mutex
+	script     []Response
+	fallback   Response
+	replyFunc  func(message string, context map[string]interface{}) (string, error)
+	latency    time.Duration
+	chunkSize  int
+	chunkDelay time.Duration
+	healthErr  error
+	calls      []Call
+}
+
+// Compile-time interface checks.
+var (
+	_ models.Model          = (*MockModel)(nil)
+	_ models.StreamingModel = (*MockModel)(nil)
+	_ models.HealthChecker  = (*MockModel)(nil)
+)
+
+// NewMockModel creates a mock model that returns the given replies in order.
+func NewMockModel(replies ...string) *MockModel {
+	m := &MockModel{fallback: Response{Reply: DefaultReply}}
+	return m.Reply(replies...)
+}
+
+// Reply appends replies to the script.
+func (m *MockModel) Reply(replies ...string) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, reply := range replies {
+		m.script = append(m.script, Response{Reply: reply})
+	}
+	return m
+}
+
+// Fail appends an error response to the script.
+func (m *MockModel) Fail(err error) *MockModel {
+	return m.Enqueue(Response{Err: err})
+}
+
+// Enqueue appends arbitrary responses to the script.
+func (m *MockModel) Enqueue(responses ...Response) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.script = append(m.script, responses...)
+	return m
+}
+
+// Always sets the response returned once the script is exhausted.
+func (m *MockModel) Always(response Response) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.fallback = response
+	return m
+}
+
+// ReplyFunc computes replies dynamically once the script is exhausted,
+// taking precedence over the fallback response.
+func (m *MockModel) ReplyFunc(fn func(message string, context map[string]interface{}) (string, error)) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.replyFunc = fn
+	return m
+}
+
+// WithLatency delays every response by d. Cancelling the context aborts the wait.
+func (m *MockModel) WithLatency(d time.Duration) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.latency = d
+	return m
+}
+
+// WithStreaming controls how AskStream splits replies: into chunks of size
+// runes (0 splits on words), with delay between chunks.
+func (m *MockModel) WithStreaming(size int, delay time.Duration) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.chunkSize = size
+	m.chunkDelay = delay
+	return m
+}
+
+// SetHealth sets the error returned by Health; nil means healthy.
+func (m *MockModel) SetHealth(err error) *MockModel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.healthErr = err
+	return m
+}
+
+// Calls returns every request made to the model, in order.
+func (m *MockModel) Calls() []Call {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	calls := make([]Call, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// LastCall returns the most recent request, or false if there was none.
+func (m *MockModel) LastCall() (Call, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.calls) == 0 {
+		return Call{}, false
+	}
+	return m.calls[len(m.calls)-1], true
+}
+
+// Reset clears the script and recorded calls.
+func (m *MockModel) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.script = nil
+	m.calls = nil
+}
+
+// Ask implements models.Model.
+func (m *MockModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	response := m.next(message, context, false)
+
+	if err := sleep(ctx, response.Latency); err != nil {
+		return "", err
+	}
+	if response.Err != nil {
+		return "", response.Err
+	}
+	return response.Reply, nil
+}
+
+// AskStream implements models.StreamingModel. Errors are returned before
+// any chunk is sent.
+func (m *MockModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	response := m.next(message, context, true)
+
+	if err := sleep(ctx, response.Latency); err != nil {
+		return nil, err
+	}
+	if response.Err != nil {
+		return nil, response.Err
+	}
+
+	m.mutex.Lock()
+	chunks := splitChunks(response.Reply, m.chunkSize)
+	delay := m.chunkDelay
+	m.mutex.Unlock()
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for i, chunk := range chunks {
+			if i > 0 && sleep(ctx, delay) != nil {
+				return
+			}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Health implements models.HealthChecker.
+func (m *MockModel) Health(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.healthErr
+}
+
+// Name implements models.Model.
+func (m *MockModel) Name() string {
+	return "mock-model"
+}
+
+// Provider implements models.Model.
+func (m *MockModel) Provider() string {
+	return "mock"
+}
+
+// next records the call and pops the next scripted response.
+func (m *MockModel) next(message string, context map[string]interface{}, stream bool) Response {
+	m.mutex.Lock()
+
+	m.calls = append(m.calls, Call{Message: message, Context: context, Stream: stream})
+
+	var response Response
+	replyFunc := m.replyFunc
+	switch {
+	case len(m.script) > 0:
+		response = m.script[0]
+		m.script = m.script[1:]
+		replyFunc = nil
+	default:
+		response = m.fallback
+	}
+	if response.Latency == 0 {
+		response.Latency = m.latency
+	}
+	m.mutex.Unlock()
+
+	// Call the reply function without holding the lock so it may use the model
+	if replyFunc != nil {
+		response.Reply, response.Err = replyFunc(message, context)
+	}
+	return response
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// splitChunks splits s into chunks of size runes, or into words (keeping
+// the separating spaces) when size is 0.
+func splitChunks(s string, size int) []string {
+	if s == "" {
+		return nil
+	}
+
+	var chunks []string
+	if size <= 0 {
+		for _, word := range strings.SplitAfter(s, " ") {
+			if word != "" {
+				chunks = append(chunks, word)
+			}
+		}
+		return chunks
+	}
+
+	runes := []rune(s)
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[start:end]))
+	}
+	return chunks
+}
@@ -0,0 +1,116 @@
+package chatbottest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestMockModel_Script(t *testing.T) {
+	errBoom := errors.New("boom")
+	model := NewMockModel("first").Fail(errBoom).Always(Response{Reply: "again"})
+	ctx := context.Background()
+
+	reply, err := model.Ask(ctx, "one", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "first", reply)
+
+	_, err = model.Ask(ctx, "two", nil)
+	assert.ErrorIs(t, err, errBoom)
+
+	reply, err = model.Ask(ctx, "three", map[string]interface{}{"k": "v"})
+	require.NoError(t, err)
+	assert.Equal(t, "again", reply)
+
+	calls := model.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, "two", calls[1].Message)
+
+	last, ok := model.LastCall()
+	require.True(t, ok)
+	assert.Equal(t, "v", last.Context["k"])
+}
+
+func TestMockModel_ReplyFunc(t *testing.T) {
+	model := NewMockModel().ReplyFunc(func(message string, _ map[string]interface{}) (string, error) {
+		return "echo: " + message, nil
+	})
+
+	reply, err := model.Ask(context.Background(), "hi", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "echo: hi", reply)
+}
+
+func TestMockModel_LatencyHonorsContext(t *testing.T) {
+	model := NewMockModel("slow").WithLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := model.Ask(ctx, "hi", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMockModel_AskStream(t *testing.T) {
+	model := NewMockModel("hello streaming world", "abcdef")
+
+	ch, err := model.AskStream(context.Background(), "hi", nil)
+	require.NoError(t, err)
+	var chunks []string
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, []string{"hello ", "streaming ", "world"}, chunks)
+
+	model.WithStreaming(4, time.Millisecond)
+	ch, err = model.AskStream(context.Background(), "hi", nil)
+	require.NoError(t, err)
+	chunks = nil
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, []string{"abcd", "ef"}, chunks)
+}
+
+func TestMockModel_Health(t *testing.T) {
+	model := NewMockModel()
+	assert.NoError(t, model.Health(context.Background()))
+
+	model.SetHealth(errors.New("down"))
+	assert.Error(t, model.Health(context.Background()))
+}
+
+func TestMockModel_WithChatbotHandler(t *testing.T) {
+	model := NewMockModel("Hi there!")
+	bot, err := gochatbot.New(config.Default(), gochatbot.WithModel(model))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"message":"Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	bot.HandleHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Message IDs are random, so only their presence is checked
+	var response gochatbot.ChatResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.MessageID)
+	response.MessageID = ""
+	AssertGoldenJSON(t, "chat_reply", response)
+
+	last, ok := model.LastCall()
+	require.True(t, ok)
+	assert.Equal(t, "Hello", last.Message)
+}
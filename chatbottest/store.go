@@ -0,0 +1,380 @@
+package chatbottest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Store is an in-memory database.ConversationStore for tests. It mirrors the
+// ordering and "not found" errors of the SQL store and is safe for
+// concurrent use. Use FailNext to simulate database errors.
+type Store struct {
+	mutex         sync.Mutex
+	conversations map[string]*database.Conversation
+	messages      []*database.Message
+	failures      []error
+	now           func() time.Time
+}
+
+// Compile-time interface checks.
+var (
+	_ database.ConversationStore   = (*Store)(nil)
+	_ database.ActivityReporter    = (*Store)(nil)
+	_ database.MessageQuerier      = (*Store)(nil)
+	_ database.ConversationScanner = (*Store)(nil)
+)
+
+// NewStore creates an empty in-memory conversation store.
+func NewStore() *Store {
+	return &Store{
+		conversations: make(map[string]*database.Conversation),
+		now:           time.Now,
+	}
+}
+
+// FailNext makes the next store calls return the given errors, one per call.
+func (s *Store) FailNext(errs ...error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures = append(s.failures, errs...)
+}
+
+// Messages returns every stored message, in insertion order.
+func (s *Store) Messages() []*database.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	messages := make([]*database.Message, len(s.messages))
+	for i, msg := range s.messages {
+		messages[i] = copyMessage(msg)
+	}
+	return messages
+}
+
+// CreateConversation implements database.ConversationStore.
+func (s *Store) CreateConversation(ctx context.Context, conv *database.Conversation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return err
+	}
+	if _, exists := s.conversations[conv.ID]; exists {
+		return fmt.Errorf("failed to create conversation: duplicate id %q", conv.ID)
+	}
+
+	conv.CreatedAt = s.now()
+	conv.UpdatedAt = conv.CreatedAt
+	s.conversations[conv.ID] = copyConversation(conv)
+	return nil
+}
+
+// GetConversation implements database.ConversationStore.
+func (s *Store) GetConversation(ctx context.Context, id string) (*database.Conversation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	conv, exists := s.conversations[id]
+	if !exists {
+		return nil, database.ErrConversationNotFound
+	}
+	return copyConversation(conv), nil
+}
+
+// UpdateConversation implements database.ConversationStore.
+func (s *Store) UpdateConversation(ctx context.Context, conv *database.Conversation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return err
+	}
+	existing, exists := s.conversations[conv.ID]
+	if !exists {
+		return database.ErrConversationNotFound
+	}
+
+	conv.CreatedAt = existing.CreatedAt
+	conv.UpdatedAt = s.now()
+	s.conversations[conv.ID] = copyConversation(conv)
+	return nil
+}
+
+// DeleteConversation implements database.ConversationStore.
+func (s *Store) DeleteConversation(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return err
+	}
+	if _, exists := s.conversations[id]; !exists {
+		return database.ErrConversationNotFound
+	}
+
+	delete(s.conversations, id)
+	kept := s.messages[:0]
+	for _, msg := range s.messages {
+		if msg.ConversationID != id {
+			kept = append(kept, msg)
+		}
+	}
+	s.messages = kept
+	return nil
+}
+
+// ListConversations implements database.ConversationStore. Conversations
+// are ordered by most recent update first.
+func (s *Store) ListConversations(ctx context.Context, userID string, limit, offset int) ([]*database.Conversation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+
+	var conversations []*database.Conversation
+	for _, conv := range s.conversations {
+		if conv.UserID == userID {
+			conversations = append(conversations, copyConversation(conv))
+		}
+	}
+	sortByUpdated(conversations)
+	return paginate(conversations, limit, offset), nil
+}
+
+// ScanConversations implements database.ConversationScanner.
+func (s *Store) ScanConversations(ctx context.Context, since, until time.Time, after *database.Conversation, limit int) ([]*database.Conversation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+
+	var conversations []*database.Conversation
+	for _, conv := range s.conversations {
+		if conv.UpdatedAt.Before(since) || !conv.UpdatedAt.Before(until) {
+			continue
+		}
+		if after != nil && (conv.UpdatedAt.Before(after.UpdatedAt) ||
+			(conv.UpdatedAt.Equal(after.UpdatedAt) && conv.ID <= after.ID)) {
+			continue
+		}
+		conversations = append(conversations, copyConversation(conv))
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		if conversations[i].UpdatedAt.Equal(conversations[j].UpdatedAt) {
+			return conversations[i].ID < conversations[j].ID
+		}
+		return conversations[i].UpdatedAt.Before(conversations[j].UpdatedAt)
+	})
+	return paginate(conversations, limit, 0), nil
+}
+
+// AddMessage implements database.ConversationStore.
+func (s *Store) AddMessage(ctx context.Context, msg *database.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return err
+	}
+
+	msg.CreatedAt = s.now()
+	s.messages = append(s.messages, copyMessage(msg))
+	if conv, exists := s.conversations[msg.ConversationID]; exists {
+		conv.UpdatedAt = msg.CreatedAt
+	}
+	return nil
+}
+
+// GetMessages implements database.ConversationStore. Messages are ordered
+// oldest first.
+func (s *Store) GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*database.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return paginate(s.history(conversationID), limit, offset), nil
+}
+
+// QueryMessages implements database.MessageQuerier.
+func (s *Store) QueryMessages(ctx context.Context, conversationID string, query database.MessageQuery) ([]*database.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	messages := s.history(conversationID)
+	if query.Descending {
+		slices.Reverse(messages)
+	}
+	if query.Limit <= 0 {
+		return messages, nil
+	}
+	return paginate(messages, query.Limit, query.Offset), nil
+}
+
+// DeleteMessage implements database.ConversationStore.
+func (s *Store) DeleteMessage(ctx context.Context, messageID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return err
+	}
+	for i, msg := range s.messages {
+		if msg.ID == messageID {
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("message not found")
+}
+
+// GetConversationHistory implements database.ConversationStore.
+func (s *Store) GetConversationHistory(ctx context.Context, conversationID string) ([]*database.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.history(conversationID), nil
+}
+
+// SearchConversations implements database.ConversationStore. Like the SQL
+// store it matches titles and message content case-insensitively.
+func (s *Store) SearchConversations(ctx context.Context, userID, query string, limit int) ([]*database.Conversation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	matches := make(map[string]bool)
+	for _, msg := range s.messages {
+		if strings.Contains(strings.ToLower(msg.Content), query) {
+			matches[msg.ConversationID] = true
+		}
+	}
+
+	var conversations []*database.Conversation
+	for _, conv := range s.conversations {
+		if conv.UserID != userID {
+			continue
+		}
+		if matches[conv.ID] || strings.Contains(strings.ToLower(conv.Title), query) {
+			conversations = append(conversations, copyConversation(conv))
+		}
+	}
+	sortByUpdated(conversations)
+	return paginate(conversations, limit, 0), nil
+}
+
+// DailyActivity implements database.ActivityReporter. Messages of
+// conversations that no longer exist are skipped, as the SQL store's join
+// would.
+func (s *Store) DailyActivity(ctx context.Context, since, until time.Time) ([]database.DailyActivity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+
+	counter := database.NewActivityCounter()
+	for _, msg := range s.messages {
+		conv, exists := s.conversations[msg.ConversationID]
+		if !exists || msg.CreatedAt.Before(since) || !msg.CreatedAt.Before(until) {
+			continue
+		}
+		counter.Add(conv.UserID, msg.CreatedAt)
+	}
+	return counter.Days(), nil
+}
+
+// fail pops the next injected failure. The caller must hold the mutex.
+func (s *Store) fail() error {
+	if len(s.failures) == 0 {
+		return nil
+	}
+	err := s.failures[0]
+	s.failures = s.failures[1:]
+	return err
+}
+
+// history returns copies of a conversation's messages, oldest first.
+// The caller must hold the mutex.
+func (s *Store) history(conversationID string) []*database.Message {
+	var messages []*database.Message
+	for _, msg := range s.messages {
+		if msg.ConversationID == conversationID {
+			messages = append(messages, copyMessage(msg))
+		}
+	}
+	return messages
+}
+
+func sortByUpdated(conversations []*database.Conversation) {
+	sort.SliceStable(conversations, func(i, j int) bool {
+		if conversations[i].UpdatedAt.Equal(conversations[j].UpdatedAt) {
+			return conversations[i].ID < conversations[j].ID
+		}
+		return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+	})
+}
+
+// paginate applies SQL-style LIMIT and OFFSET; a non-positive limit means no limit.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	if offset > 0 {
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func copyConversation(conv *database.Conversation) *database.Conversation {
+	c := *conv
+	c.Metadata = copyMetadata(conv.Metadata)
+	return &c
+}
+
+func copyMessage(msg *database.Message) *database.Message {
+	m := *msg
+	m.Metadata = copyMetadata(msg.Metadata)
+	return &m
+}
+
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+	return c
+}
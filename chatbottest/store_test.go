@@ -0,0 +1,118 @@
+package chatbottest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+func TestStore_ConversationLifecycle(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	manager := database.NewConversationManager(store)
+
+	conv, msg, err := manager.CreateConversationWithMessage(ctx, "user-1", "Trip planning", "Where should I go?")
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+
+	_, err = manager.AddAssistantMessage(ctx, conv.ID, "Try Lisbon")
+	require.NoError(t, err)
+
+	history, err := store.GetConversationHistory(ctx, conv.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "user", history[0].Role)
+	assert.Equal(t, "assistant", history[1].Role)
+
+	found, err := store.SearchConversations(ctx, "user-1", "LISBON", 10)
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	require.NoError(t, store.DeleteConversation(ctx, conv.ID))
+	_, err = store.GetConversation(ctx, conv.ID)
+	assert.EqualError(t, err, "conversation not found")
+	assert.Empty(t, store.Messages())
+}
+
+func TestStore_ListOrderingAndPagination(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, store.CreateConversation(ctx, &database.Conversation{ID: id, UserID: "u"}))
+	}
+	require.NoError(t, store.AddMessage(ctx, &database.Message{ID: "m", ConversationID: "a", Content: "bump"}))
+
+	list, err := store.ListConversations(ctx, "u", 2, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "a", list[0].ID, "most recently updated first")
+	assert.Equal(t, "c", list[1].ID)
+
+	list, err = store.ListConversations(ctx, "u", 2, 2)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "b", list[0].ID)
+}
+
+func TestStore_FailNext(t *testing.T) {
+	store := NewStore()
+	errDown := errors.New("database down")
+	store.FailNext(errDown)
+
+	err := store.CreateConversation(context.Background(), &database.Conversation{ID: "x"})
+	assert.ErrorIs(t, err, errDown)
+
+	assert.NoError(t, store.CreateConversation(context.Background(), &database.Conversation{ID: "x"}))
+	assert.EqualError(t, store.DeleteMessage(context.Background(), "missing"), "message not found")
+}
+
+func TestStore_ReturnsCopies(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	require.NoError(t, store.CreateConversation(ctx, &database.Conversation{ID: "x", Title: "original"}))
+
+	conv, err := store.GetConversation(ctx, "x")
+	require.NoError(t, err)
+	conv.Title = "changed"
+
+	conv, err = store.GetConversation(ctx, "x")
+	require.NoError(t, err)
+	assert.Equal(t, "original", conv.Title)
+}
+
+func TestStore_QueryMessages(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	manager := database.NewConversationManager(store)
+
+	require.NoError(t, store.CreateConversation(ctx, &database.Conversation{ID: "c", UserID: "u"}))
+	for _, content := range []string{"one", "two", "three", "four"} {
+		_, err := manager.AddUserMessage(ctx, "c", content)
+		require.NoError(t, err)
+	}
+
+	messages, err := store.QueryMessages(ctx, "c", database.MessageQuery{Limit: 2, Offset: 1, Descending: true})
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "three", messages[0].Content)
+	assert.Equal(t, "two", messages[1].Content)
+
+	recent, err := manager.GetConversationContext(ctx, "c", 3)
+	require.NoError(t, err)
+	require.Len(t, recent, 3)
+	assert.Equal(t, "two", recent[0].Content)
+	assert.Equal(t, "four", recent[2].Content)
+}
@@ -0,0 +1,153 @@
+// Package chaterrors defines the kinds of errors the chatbot reports, with
+// the codes and HTTP statuses the chat endpoints answer them with. Errors
+// of a kind match it with errors.Is, so callers need not inspect messages:
+//
+//	reply, err := bot.Ask(ctx, message)
+//	if errors.Is(err, chaterrors.ErrRateLimited) {
+//		// ask the user to slow down
+//	}
+//
+// Code and Status return what to send a client for any error.
+package chaterrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// CodeInternal is the code of errors of no kind.
+const CodeInternal = "internal_error"
+
+// messageInternal is the message clients get for errors of no kind.
+const messageInternal = "Failed to process request"
+
+// Kind is a kind of error.
+type Kind struct {
+	// Code identifies the kind in responses, such as "rate_limited".
+	Code string
+	// Status is the HTTP status the chat endpoints answer with.
+	Status int
+
+	message string
+}
+
+func (k *Kind) Error() string {
+	return k.message
+}
+
+// The kinds of errors.
+var (
+	// ErrRateLimited is returned when a user or client exceeds its rate
+	// limit.
+	ErrRateLimited = &Kind{Code: "rate_limited", Status: http.StatusTooManyRequests, message: "rate limit exceeded"}
+	// ErrModeration is returned when a provider refuses a message or reply
+	// by its content policy.
+	ErrModeration = &Kind{Code: "moderation", Status: http.StatusUnprocessableEntity, message: "rejected by content moderation"}
+	// ErrProviderUnavailable is returned when the AI provider cannot be
+	// reached, fails or is overloaded, including when it rate limits the
+	// chatbot.
+	ErrProviderUnavailable = &Kind{Code: "provider_unavailable", Status: http.StatusServiceUnavailable, message: "AI provider unavailable"}
+	// ErrContextTooLong is returned when a request does not fit the model's
+	// context window or the configured prompt limits.
+	ErrContextTooLong = &Kind{Code: "context_too_long", Status: http.StatusRequestEntityTooLarge, message: "context too long"}
+	// ErrInvalidInput is returned for requests the chatbot does not accept,
+	// such as an empty message.
+	ErrInvalidInput = &Kind{Code: "invalid_input", Status: http.StatusBadRequest, message: "invalid input"}
+	// ErrForbidden is returned for requests that may not be served, such
+	// as those failing CSRF protection.
+	ErrForbidden = &Kind{Code: "forbidden", Status: http.StatusForbidden, message: "forbidden"}
+	// ErrNotFound is returned for requests naming something that does not
+	// exist, such as an unknown conversation.
+	ErrNotFound = &Kind{Code: "not_found", Status: http.StatusNotFound, message: "not found"}
+	// ErrMaintenance is returned for chats started while the chatbot is in
+	// maintenance mode.
+	ErrMaintenance = &Kind{Code: "maintenance", Status: http.StatusServiceUnavailable, message: "down for maintenance"}
+)
+
+// kinds lists the kinds ForStatus answers with. ErrMaintenance shares its
+// status with ErrProviderUnavailable and is left out, as is ErrNotFound,
+// since most 404s are for unknown routes rather than things a request names.
+var kinds = []*Kind{ErrRateLimited, ErrModeration, ErrProviderUnavailable, ErrContextTooLong, ErrInvalidInput, ErrForbidden}
+
+// Mark returns err marked as being of kind, with err's message. Use it
+// when wrapping with fmt.Errorf("%w: ...", kind) would repeat the message.
+func Mark(err error, kind *Kind) error {
+	if err == nil {
+		return nil
+	}
+	return &marked{err: err, kind: kind}
+}
+
+// marked is an error marked with a kind.
+type marked struct {
+	err  error
+	kind *Kind
+}
+
+func (m *marked) Error() string {
+	return m.err.Error()
+}
+
+func (m *marked) Unwrap() []error {
+	return []error{m.err, m.kind}
+}
+
+// KindOf returns the kind of err, or nil if it has none.
+func KindOf(err error) *Kind {
+	var kind *Kind
+	if errors.As(err, &kind) {
+		return kind
+	}
+	return nil
+}
+
+// Code returns the code of err's kind, CodeInternal for errors of no kind,
+// and "" for nil.
+func Code(err error) string {
+	if err == nil {
+		return ""
+	}
+	if kind := KindOf(err); kind != nil {
+		return kind.Code
+	}
+	return CodeInternal
+}
+
+// Message returns the message to send a client for err, and "" for nil.
+// Invalid input and maintenance errors keep their own message, which is
+// written for the client. Other errors of a kind get the kind's message,
+// so provider responses are not passed on, and errors of no kind, which may
+// carry internal details, a generic one.
+func Message(err error) string {
+	if err == nil {
+		return ""
+	}
+	switch kind := KindOf(err); kind {
+	case nil:
+		return messageInternal
+	case ErrInvalidInput, ErrMaintenance:
+		return err.Error()
+	default:
+		return kind.Error()
+	}
+}
+
+// Status returns the HTTP status of err's kind, or 500 for errors of no
+// kind.
+func Status(err error) int {
+	if kind := KindOf(err); kind != nil {
+		return kind.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// ForStatus returns the kind answered with status, or nil, so that errors
+// a handler writes by status carry the same codes.
+func ForStatus(status int) *Kind {
+	for _, kind := range kinds {
+		if kind.Status == status {
+			return kind
+		}
+	}
+	return nil
+}
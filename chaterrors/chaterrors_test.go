@@ -0,0 +1,86 @@
+package chaterrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestMark(t *testing.T) {
+	cause := errors.New("prompt is too long")
+	err := fmt.Errorf("AI model request failed: %w", Mark(cause, ErrContextTooLong))
+
+	if err.Error() != "AI model request failed: prompt is too long" {
+		t.Errorf("expected the message to be kept, got %q", err.Error())
+	}
+	if !errors.Is(err, ErrContextTooLong) || !errors.Is(err, cause) {
+		t.Error("expected the error to match its kind and cause")
+	}
+	if errors.Is(err, ErrInvalidInput) {
+		t.Error("expected the error not to match other kinds")
+	}
+	if Mark(nil, ErrInvalidInput) != nil {
+		t.Error("expected Mark(nil) to be nil")
+	}
+}
+
+func TestCodeAndStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   string
+		status int
+	}{
+		{name: "nil", err: nil, code: "", status: http.StatusInternalServerError},
+		{name: "no kind", err: errors.New("boom"), code: CodeInternal, status: http.StatusInternalServerError},
+		{name: "wrapped", err: fmt.Errorf("%w: message cannot be empty", ErrInvalidInput), code: "invalid_input", status: http.StatusBadRequest},
+		{name: "rate limited", err: ErrRateLimited, code: "rate_limited", status: http.StatusTooManyRequests},
+		{name: "moderation", err: Mark(errors.New("refused"), ErrModeration), code: "moderation", status: http.StatusUnprocessableEntity},
+		{name: "provider", err: Mark(errors.New("overloaded"), ErrProviderUnavailable), code: "provider_unavailable", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := Code(tt.err); code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, code)
+			}
+			if status := Status(tt.err); status != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		message string
+	}{
+		{name: "nil", err: nil, message: ""},
+		{name: "no kind", err: errors.New("dial tcp 10.0.0.7:5432: connection refused"), message: "Failed to process request"},
+		{name: "invalid input", err: fmt.Errorf("%w: message cannot be empty", ErrInvalidInput), message: "invalid input: message cannot be empty"},
+		{name: "maintenance", err: fmt.Errorf("%w: back at noon", ErrMaintenance), message: "down for maintenance: back at noon"},
+		{name: "provider", err: Mark(errors.New("upstream said: invalid api key sk-123"), ErrProviderUnavailable), message: "AI provider unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if message := Message(tt.err); message != tt.message {
+				t.Errorf("expected %q, got %q", tt.message, message)
+			}
+		})
+	}
+}
+
+func TestForStatus(t *testing.T) {
+	for _, kind := range kinds {
+		if ForStatus(kind.Status) != kind {
+			t.Errorf("expected %s for status %d", kind.Code, kind.Status)
+		}
+	}
+	if ForStatus(http.StatusNotFound) != nil {
+		t.Error("expected no kind for 404")
+	}
+}
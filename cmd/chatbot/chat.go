@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/streaming"
+)
+
+// chatSession holds the state of a chat.
+type chatSession struct {
+	bot       *gochatbot.Chatbot
+	stream    bool
+	knowledge *embeddings.VectorStore
+	history   []map[string]interface{}
+	store     *database.ConversationManager
+	convID    string
+	out       io.Writer
+}
+
+// runChat chats interactively, or sends the positional message and exits.
+func runChat(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("chat", "[message]", stderr)
+	cfgFlags := addConfigFlags(fs)
+	stream := fs.Bool("stream", false, "stream replies as they are generated")
+	knowledge := fs.String("knowledge", "", "knowledge index built with chatbot ingest")
+	driver := fs.String("driver", "", "database driver for saving the conversation (sqlite3 or postgres)")
+	dsn := fs.String("dsn", "", "database connection string for saving the conversation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cfgFlags.load()
+	if err != nil {
+		return err
+	}
+	bot, err := gochatbot.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	session := &chatSession{bot: bot, stream: *stream, out: stdout}
+
+	if *knowledge != "" {
+		session.knowledge, err = loadKnowledge(cfg, *knowledge)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *dsn != "" {
+		db, err := openStore(ctx, *driver, *dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		store := database.NewSQLConversationStore(db, *driver)
+		session.store = database.NewConversationManager(store)
+	}
+
+	if message := strings.Join(fs.Args(), " "); message != "" {
+		return session.send(ctx, message)
+	}
+	return session.repl(ctx, stdin, stderr)
+}
+
+// repl reads messages line by line until EOF or /exit.
+func (s *chatSession) repl(ctx context.Context, stdin io.Reader, stderr io.Writer) error {
+	model := s.bot.GetModel()
+	fmt.Fprintf(s.out, "Chatting with %s (%s). Type /reset to start over, /exit to quit.\n", model.Provider(), model.Name())
+
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(s.out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			s.history = nil
+			s.convID = ""
+			fmt.Fprintln(s.out, "Conversation reset.")
+			continue
+		}
+
+		if err := s.send(ctx, line); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Keep the session alive on provider errors
+			fmt.Fprintf(stderr, "error: %v\n", err)
+		}
+	}
+}
+
+// send asks one question, prints the reply and records the exchange.
+func (s *chatSession) send(ctx context.Context, message string) error {
+	options := []gochatbot.AskOption{gochatbot.WithContext("history", s.history)}
+	if s.knowledge != nil {
+		prompt, err := s.knowledgePrompt(ctx, message)
+		if err != nil {
+			return err
+		}
+		if prompt != "" {
+			options = append(options, gochatbot.WithContext("prompt", prompt))
+		}
+	}
+
+	var reply string
+	var err error
+	if s.stream {
+		reply, err = s.askStream(ctx, message, options)
+	} else {
+		reply, err = s.bot.Ask(ctx, message, options...)
+		if err == nil {
+			fmt.Fprintln(s.out, reply)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	s.history = append(s.history,
+		map[string]interface{}{"role": "user", "content": message},
+		map[string]interface{}{"role": "assistant", "content": reply},
+	)
+	return s.save(ctx, message, reply)
+}
+
+// askStream streams the reply to the output as it arrives.
+func (s *chatSession) askStream(ctx context.Context, message string, options []gochatbot.AskOption) (string, error) {
+	w := newSSEWriter(s.out)
+	if err := s.bot.AskStream(ctx, w, message, options...); err != nil {
+		return "", err
+	}
+	fmt.Fprintln(s.out)
+
+	if w.err != "" {
+		return "", errors.New(w.err)
+	}
+	return w.reply.String(), nil
+}
+
+// knowledgePrompt returns the system prompt extended with the most relevant
+// knowledge chunks, or "" if nothing relevant was found.
+func (s *chatSession) knowledgePrompt(ctx context.Context, message string) (string, error) {
+	results, err := s.knowledge.Search(ctx, message, 3)
+	if err != nil {
+		return "", fmt.Errorf("knowledge search failed: %w", err)
+	}
+	if len(results) == 0 {
+		return "", nil
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(s.bot.GetConfig().Prompt)
+	prompt.WriteString("\n\nUse the following context when it is relevant:\n")
+	for _, result := range results {
+		text, _ := result.Metadata["text"].(string)
+		source, _ := result.Metadata["source"].(string)
+		fmt.Fprintf(&prompt, "\n[%s]\n%s\n", source, text)
+	}
+	return prompt.String(), nil
+}
+
+// save stores the exchange when a database was configured.
+func (s *chatSession) save(ctx context.Context, message, reply string) error {
+	if s.store == nil {
+		return nil
+	}
+
+	if s.convID == "" {
+		conv, _, err := s.store.CreateConversationWithMessage(ctx, "cli", truncate(message, 60), message)
+		if err != nil {
+			return err
+		}
+		s.convID = conv.ID
+		fmt.Fprintf(s.out, "(saved as conversation %s)\n", conv.ID)
+	} else if _, err := s.store.AddUserMessage(ctx, s.convID, message); err != nil {
+		return err
+	}
+
+	_, err := s.store.AddAssistantMessage(ctx, s.convID, reply)
+	return err
+}
+
+// openStore opens the database and makes sure the schema exists.
+func openStore(ctx context.Context, driver, dsn string) (*sql.DB, error) {
+	if driver == "" {
+		driver = "sqlite3"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := database.NewSQLConversationStore(db, driver).Initialize(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// sseWriter is an http.ResponseWriter that decodes the server-sent events
+// written by Chatbot.AskStream and prints their content.
+type sseWriter struct {
+	out    io.Writer
+	header http.Header
+	buffer bytes.Buffer
+	reply  strings.Builder
+	err    string
+}
+
+func newSSEWriter(out io.Writer) *sseWriter {
+	return &sseWriter{out: out, header: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter.
+func (w *sseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *sseWriter) WriteHeader(int) {}
+
+// Flush implements http.Flusher.
+func (w *sseWriter) Flush() {}
+
+// Write implements http.ResponseWriter, decoding every complete event.
+func (w *sseWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+
+	for {
+		end := bytes.Index(w.buffer.Bytes(), []byte("\n\n"))
+		if end < 0 {
+			return len(p), nil
+		}
+		event := strings.TrimSpace(string(w.buffer.Next(end + 2)))
+
+		data, ok := strings.CutPrefix(event, "data: ")
+		if !ok {
+			continue
+		}
+		var chunk streaming.StreamResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+
+		if chunk.Error != "" {
+			w.err = chunk.Error
+			continue
+		}
+		if chunk.Content != "" {
+			fmt.Fprint(w.out, chunk.Content)
+			w.reply.WriteString(chunk.Content)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// runConfig prints the configuration reference, or checks the environment
+// and a configuration file strictly.
+func runConfig(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("config", "", stderr)
+	format := fs.String("format", config.ReferenceMarkdown, "reference format: markdown or json")
+	check := fs.Bool("check", false, "check the environment and the -config file for unknown keys instead")
+	file := fs.String("config", "", "JSON or YAML configuration file to check")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*format != config.ReferenceMarkdown && *format != config.ReferenceJSON) {
+		fs.Usage()
+		return errUsage
+	}
+
+	if !*check {
+		return config.WriteReference(stdout, *format)
+	}
+	var err error
+	if *file != "" {
+		_, err = config.LoadFileStrict(*file, "")
+	} else {
+		_, err = config.DefaultStrict("")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Configuration is valid")
+	return nil
+}
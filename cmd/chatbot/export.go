@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// conversationExport is the JSON export format.
+type conversationExport struct {
+	Conversation *database.Conversation `json:"conversation"`
+	Messages     []*database.Message    `json:"messages"`
+}
+
+// runExport writes a stored conversation as JSON or Markdown.
+func runExport(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("export", "<conversation-id>", stderr)
+	driver := fs.String("driver", "sqlite3", "database driver (sqlite3 or postgres)")
+	dsn := fs.String("dsn", "", "database connection string")
+	format := fs.String("format", "json", "output format: json or markdown")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *dsn == "" || (*format != "json" && *format != "markdown") {
+		fs.Usage()
+		return errUsage
+	}
+
+	db, err := openStore(ctx, *driver, *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return exportConversation(ctx, database.NewSQLConversationStore(db, *driver), fs.Arg(0), *format, stdout)
+}
+
+// exportConversation writes the conversation with the given ID to w.
+func exportConversation(ctx context.Context, store database.ConversationStore, id, format string, w io.Writer) error {
+	conv, err := store.GetConversation(ctx, id)
+	if err != nil {
+		return err
+	}
+	messages, err := store.GetConversationHistory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if format == "markdown" {
+		_, err := io.WriteString(w, renderMarkdown(conv, messages))
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(conversationExport{Conversation: conv, Messages: messages})
+}
+
+// renderMarkdown formats a conversation as a Markdown transcript.
+func renderMarkdown(conv *database.Conversation, messages []*database.Message) string {
+	var b strings.Builder
+
+	title := conv.Title
+	if title == "" {
+		title = "Conversation " + conv.ID
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- ID: %s\n- User: %s\n- Created: %s\n", conv.ID, conv.UserID, conv.CreatedAt.Format(time.RFC3339))
+
+	for _, msg := range messages {
+		role := msg.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&b, "\n## %s (%s)\n\n%s\n", role, msg.CreatedAt.Format(time.RFC3339), msg.Content)
+	}
+	return b.String()
+}
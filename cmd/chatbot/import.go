@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// importers are the formats of import -format.
+var importers = map[string]func(context.Context, database.ConversationStore, io.Reader, ...database.ImportOption) (*database.ImportResult, error){
+	"chatgpt": database.ImportChatGPT,
+	"openai":  database.ImportOpenAIMessages,
+}
+
+// runImport stores the conversations of a ChatGPT export or an OpenAI
+// messages array.
+func runImport(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("import", "<file>", stderr)
+	driver := fs.String("driver", "sqlite3", "database driver (sqlite3 or postgres)")
+	dsn := fs.String("dsn", "", "database connection string")
+	format := fs.String("format", "chatgpt", "input format: chatgpt (conversations.json) or openai (messages array)")
+	user := fs.String("user", "", "user the imported conversations belong to")
+	title := fs.String("title", "", "title of the conversation, with -format openai")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	importer, ok := importers[*format]
+	if fs.NArg() != 1 || *dsn == "" || !ok {
+		fs.Usage()
+		return errUsage
+	}
+
+	input := stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open export: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	db, err := openStore(ctx, *driver, *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	store := database.NewSQLConversationStore(db, *driver)
+	result, err := importer(ctx, store, input, database.WithImportUser(*user), database.WithImportTitle(*title))
+	if result != nil {
+		for _, id := range result.ConversationIDs {
+			fmt.Fprintln(stdout, id)
+		}
+		fmt.Fprintf(stderr, "Imported %d conversation(s) with %d message(s), skipped %d\n",
+			len(result.ConversationIDs), result.Messages, result.Skipped)
+	}
+	return err
+}
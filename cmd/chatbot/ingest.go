@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/knowledge"
+)
+
+// knowledgeIndex is the file format written by ingest and read by chat -knowledge.
+type knowledgeIndex struct {
+	Model     string              `json:"model"`
+	Documents []knowledgeDocument `json:"documents"`
+}
+
+// knowledgeDocument is one embedded chunk of a source file.
+type knowledgeDocument struct {
+	Source string            `json:"source"`
+	Text   string            `json:"text"`
+	Vector embeddings.Vector `json:"vector"`
+	// Duplicates are the other files the chunk was found in, with
+	// ingest -duplicates merge.
+	Duplicates []string `json:"duplicates,omitempty"`
+}
+
+// duplicatePolicies are the values of ingest -duplicates.
+var duplicatePolicies = map[string]embeddings.DuplicatePolicy{
+	"keep":  embeddings.DuplicatesKeep,
+	"skip":  embeddings.DuplicatesSkip,
+	"merge": embeddings.DuplicatesMerge,
+}
+
+// newEmbeddingProvider creates the provider used by ingest and chat -knowledge.
+// Tests replace it to avoid network calls.
+var newEmbeddingProvider = func(cfg *config.Config, model string) embeddings.EmbeddingProvider {
+	return embeddings.NewOpenAIEmbeddingProvider(cfg.OpenAI, model)
+}
+
+// runIngest embeds documents into a knowledge index file.
+func runIngest(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("ingest", "<path>...", stderr)
+	cfgFlags := addConfigFlags(flags)
+	out := flags.String("out", "knowledge.json", "knowledge index file to write")
+	model := flags.String("embedding-model", "", "OpenAI embedding model (default text-embedding-3-small)")
+	chunkSize := flags.Int("chunk-size", 1000, "maximum characters per chunk")
+	duplicates := flags.String("duplicates", "keep", "what to do with near-duplicate chunks: keep, skip or merge")
+	duplicateThreshold := flags.Float64("duplicate-threshold", embeddings.DefaultDuplicateThreshold,
+		"similarity at which chunks are near duplicates")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	policy, ok := duplicatePolicies[*duplicates]
+	if flags.NArg() == 0 || *chunkSize <= 0 || !ok {
+		flags.Usage()
+		return errUsage
+	}
+
+	// Ingestion only needs embeddings, so skip provider validation
+	cfg, err := cfgFlags.read()
+	if err != nil {
+		return err
+	}
+
+	files, err := knowledge.Files(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var texts []string
+	var metadata []map[string]interface{}
+	for _, file := range files {
+		data, err := os.ReadFile(file) // #nosec G304 -- files are chosen by the operator
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for _, chunk := range knowledge.Chunk(string(data), *chunkSize) {
+			texts = append(texts, chunk)
+			metadata = append(metadata, map[string]interface{}{"source": file, "text": chunk})
+		}
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("no text found in %s", strings.Join(flags.Args(), ", "))
+	}
+
+	provider := newEmbeddingProvider(cfg, *model)
+	store := embeddings.NewVectorStore(provider)
+	store.SetDuplicatePolicy(policy, *duplicateThreshold)
+	report, err := store.Ingest(ctx, texts, metadata)
+	if err != nil {
+		return err
+	}
+	if err := saveKnowledge(*out, provider.Model(), store); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Indexed %d chunks from %d files into %s", store.Count(), len(files), *out)
+	switch {
+	case report.Skipped > 0:
+		fmt.Fprintf(stdout, ", skipping %d duplicates", report.Skipped)
+	case report.Merged > 0:
+		fmt.Fprintf(stdout, ", merging %d duplicates", report.Merged)
+	}
+	fmt.Fprintln(stdout)
+	return nil
+}
+
+// saveKnowledge writes the entries of store to a knowledge index file,
+// replacing it at once so readers never see a partial index.
+func saveKnowledge(path, model string, store *embeddings.VectorStore) error {
+	vectors, metadata := store.Entries()
+	documents := make([]knowledgeDocument, len(vectors))
+	for i, vector := range vectors {
+		source, _ := metadata[i]["source"].(string)
+		text, _ := metadata[i]["text"].(string)
+		documents[i] = knowledgeDocument{Source: source, Text: text, Vector: vector}
+		merged, _ := metadata[i][embeddings.MetadataDuplicates].([]map[string]interface{})
+		for _, duplicate := range merged {
+			source, _ := duplicate["source"].(string)
+			documents[i].Duplicates = append(documents[i].Duplicates, source)
+		}
+	}
+
+	data, err := json.Marshal(knowledgeIndex{Model: model, Documents: documents})
+	if err != nil {
+		return fmt.Errorf("failed to encode knowledge index: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".knowledge-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write knowledge index: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write knowledge index: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write knowledge index: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write knowledge index: %w", err)
+	}
+	return nil
+}
+
+// loadKnowledge reads a knowledge index into a vector store.
+func loadKnowledge(cfg *config.Config, path string) (*embeddings.VectorStore, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is chosen by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read knowledge index: %w", err)
+	}
+
+	var index knowledgeIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse knowledge index: %w", err)
+	}
+
+	vectors := make([]embeddings.Vector, len(index.Documents))
+	metadata := make([]map[string]interface{}, len(index.Documents))
+	for i, doc := range index.Documents {
+		vectors[i] = doc.Vector
+		metadata[i] = map[string]interface{}{"source": doc.Source, "text": doc.Text}
+		if len(doc.Duplicates) > 0 {
+			duplicates := make([]map[string]interface{}, len(doc.Duplicates))
+			for j, source := range doc.Duplicates {
+				duplicates[j] = map[string]interface{}{"source": source, "text": doc.Text}
+			}
+			metadata[i][embeddings.MetadataDuplicates] = duplicates
+		}
+	}
+
+	store := embeddings.NewVectorStore(newEmbeddingProvider(cfg, index.Model))
+	if err := store.AddVectors(vectors, metadata); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
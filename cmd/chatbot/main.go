@@ -0,0 +1,204 @@
+// Command chatbot is a command-line tool for chatting with any configured
+// provider, building knowledge indexes, exporting and importing conversations
+// and checking provider health.
+//
+// Usage:
+//
+//	chatbot chat [flags] [message]      interactive chat, or a single message
+//	chatbot ingest [flags] <path>...    embed documents into a knowledge index
+//	chatbot reindex [flags] <index>     re-embed a knowledge index with another model
+//	chatbot export [flags] <id>         export a stored conversation
+//	chatbot import [flags] <file>       import ChatGPT or OpenAI conversations
+//	chatbot health [flags]              check that the provider is reachable
+//	chatbot mcp [flags]                 serve the chatbot as an MCP server
+//	chatbot config [flags]              print or check the configuration options
+//
+// Configuration is read from the environment (see config.EnvSpec) and,
+// optionally, from a JSON or YAML file given with -config. With -strict,
+// unknown keys and variables are errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/config"
+)
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: chatbot <command> [flags] [arguments]
+
+Commands:
+  chat     Chat interactively, or send a single message
+  ingest   Embed documents into a knowledge index for chat -knowledge
+  reindex  Re-embed a knowledge index with another embedding model
+  export   Export a stored conversation as JSON or Markdown
+  import   Import conversations from a ChatGPT export or OpenAI messages
+  health   Check that the configured provider is reachable
+  mcp      Serve the chatbot and knowledge base as MCP tools
+  config   Print the configuration reference, or check a configuration
+
+Run "chatbot <command> -h" for command flags.
+`
+
+// errUsage reports invalid command-line arguments.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command given by args and returns the exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	commands := map[string]func(context.Context, []string, io.Reader, io.Writer, io.Writer) error{
+		"chat":    runChat,
+		"ingest":  runIngest,
+		"reindex": runReindex,
+		"export":  runExport,
+		"import":  runImport,
+		"health":  runHealth,
+		"mcp":     runMCP,
+		"config":  runConfig,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			fmt.Fprint(stdout, usage)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "chatbot: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	if err := command(ctx, args[1:], stdin, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		if errors.Is(err, errUsage) {
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "chatbot: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// configFlags holds the flags shared by commands that need a configuration.
+type configFlags struct {
+	file    string
+	profile string
+	model   string
+	strict  bool
+}
+
+// addConfigFlags registers the shared configuration flags on fs.
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	f := &configFlags{}
+	fs.StringVar(&f.file, "config", "", "JSON or YAML configuration file")
+	fs.StringVar(&f.profile, "profile", "", "configuration profile to apply")
+	fs.StringVar(&f.model, "model", "", "provider to use, overriding the configuration")
+	fs.BoolVar(&f.strict, "strict", false, "fail on unknown configuration keys and environment variables")
+	return f
+}
+
+// read builds the configuration without validating it.
+func (f *configFlags) read() (*config.Config, error) {
+	switch {
+	case f.file != "" && f.strict:
+		return config.LoadFileStrict(f.file, f.profile)
+	case f.file != "":
+		return config.LoadFileWithProfile(f.file, f.profile)
+	case f.strict:
+		return config.DefaultStrict("")
+	}
+	return config.Default(), nil
+}
+
+// load builds and validates the configuration.
+func (f *configFlags) load() (*config.Config, error) {
+	cfg, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	if f.model != "" {
+		cfg.Model = f.model
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newFlagSet creates a flag set that reports errors instead of exiting.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: chatbot %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args, mapping parse failures to errUsage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// runHealth checks that the configured provider is reachable.
+func runHealth(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("health", "", stderr)
+	cfgFlags := addConfigFlags(fs)
+	timeout := fs.Duration("timeout", 10*time.Second, "health check timeout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cfgFlags.load()
+	if err != nil {
+		return err
+	}
+	bot, err := gochatbot.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	model := bot.GetModel()
+	if err := bot.Health(ctx); err != nil {
+		return fmt.Errorf("%s (%s) is unhealthy: %w", model.Provider(), model.Name(), err)
+	}
+
+	fmt.Fprintf(stdout, "%s (%s) is healthy\n", model.Provider(), model.Name())
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// runCLI runs the command with the free model and returns its output.
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	code, _, stderr := runCLI(t, "")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Commands:")
+
+	code, _, stderr = runCLI(t, "", "bogus")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, `unknown command "bogus"`)
+
+	code, _, _ = runCLI(t, "", "chat", "-unknown-flag")
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestRun_ChatSingleMessage(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "chat", "-model", "free", "Hello")
+	require.Equal(t, exitOK, code, stderr)
+	assert.NotEmpty(t, strings.TrimSpace(stdout))
+}
+
+func TestRun_ChatREPLStreamsAndSaves(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "chat.db")
+
+	code, stdout, stderr := runCLI(t, "Hello\nHow are you?\n/exit\n",
+		"chat", "-model", "free", "-stream", "-driver", "sqlite3", "-dsn", dsn)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "Chatting with local (free-model)")
+	require.Contains(t, stdout, "(saved as conversation ")
+
+	id := strings.SplitN(strings.SplitN(stdout, "(saved as conversation ", 2)[1], ")", 2)[0]
+
+	code, stdout, stderr = runCLI(t, "", "export", "-dsn", dsn, "-format", "markdown", id)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "# Hello")
+	assert.Equal(t, 2, strings.Count(stdout, "## User"))
+	assert.Equal(t, 2, strings.Count(stdout, "## Assistant"))
+
+	code, stdout, stderr = runCLI(t, "", "export", "-dsn", dsn, id)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, `"messages"`)
+}
+
+func TestRun_ImportAndExport(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "chat.db")
+	export := filepath.Join(dir, "conversations.json")
+	require.NoError(t, os.WriteFile(export, []byte(`[{"title": "Hello", "conversation_id": "c1", "current_node": "a",
+		"mapping": {"u": {"message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["Hi"]}}, "children": ["a"]},
+		"a": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["Hello!"]}}, "parent": "u"}}}]`), 0o600))
+
+	code, stdout, stderr := runCLI(t, "", "import", "-dsn", dsn, "-user", "u1", export)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, "c1\n", stdout)
+	assert.Contains(t, stderr, "Imported 1 conversation(s) with 2 message(s), skipped 0")
+
+	code, stdout, stderr = runCLI(t, "", "export", "-dsn", dsn, "-format", "markdown", "c1")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "## User")
+	assert.Contains(t, stdout, "Hello!")
+
+	code, stdout, stderr = runCLI(t, `[{"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello!"}]`,
+		"import", "-dsn", dsn, "-format", "openai", "-")
+	require.Equal(t, exitOK, code, stderr)
+	assert.NotEmpty(t, strings.TrimSpace(stdout))
+
+	code, _, _ = runCLI(t, "", "import", "-dsn", dsn, "-format", "bogus", export)
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestRun_Config(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "config")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "| `model` | string | `free` | `CHATBOT_MODEL` |")
+
+	code, stdout, stderr = runCLI(t, "", "config", "-format", "json")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, `"key": "openai.api_key"`)
+
+	file := filepath.Join(t.TempDir(), "chatbot.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("modle: openai\n"), 0o600))
+	code, _, stderr = runCLI(t, "", "config", "-check", "-config", file)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "modle: unknown configuration key (expected model)")
+
+	code, _, stderr = runCLI(t, "", "chat", "-strict", "-config", file, "-model", "free", "Hello")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "unknown configuration key")
+
+	t.Setenv("CHATBOT_MODLE", "free")
+	code, _, stderr = runCLI(t, "", "health", "-strict")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "CHATBOT_MODLE: unknown environment variable (expected CHATBOT_MODEL)")
+}
+
+func TestRun_Health(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "health", "-model", "free")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "is healthy")
+
+	code, _, stderr = runCLI(t, "", "health", "-model", "openai")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "openai.api_key")
+}
+
+// fakeEmbeddings embeds texts as keyword counts so similarity is predictable.
+type fakeEmbeddings struct{}
+
+var keywords = []string{"refund", "shipping", "password"}
+
+func (fakeEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = make(embeddings.Vector, len(keywords))
+		for j, keyword := range keywords {
+			vectors[i][j] = float64(strings.Count(strings.ToLower(text), keyword))
+		}
+	}
+	return vectors, nil
+}
+
+func (f fakeEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	vectors, err := f.Embed(ctx, []string{text})
+	return vectors[0], err
+}
+
+func (fakeEmbeddings) Dimensions() int  { return len(keywords) }
+func (fakeEmbeddings) Model() string    { return "fake" }
+func (fakeEmbeddings) Provider() string { return "fake" }
+
+func TestRun_IngestAndChatWithKnowledge(t *testing.T) {
+	original := newEmbeddingProvider
+	newEmbeddingProvider = func(*config.Config, string) embeddings.EmbeddingProvider { return fakeEmbeddings{} }
+	t.Cleanup(func() { newEmbeddingProvider = original })
+
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	require.NoError(t, os.MkdirAll(docs, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "refunds.md"), []byte("# Refunds\n\nA refund takes 5 days."), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "shipping.txt"), []byte("Shipping is free."), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "image.png"), []byte("binary"), 0o600))
+
+	index := filepath.Join(dir, "knowledge.json")
+	code, stdout, stderr := runCLI(t, "", "ingest", "-out", index, docs)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "from 2 files")
+
+	cfg := config.Default()
+	store, err := loadKnowledge(cfg, index)
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.Count())
+
+	code, _, stderr = runCLI(t, "", "chat", "-model", "free", "-knowledge", index, "How long does a refund take?")
+	assert.Equal(t, exitOK, code, stderr)
+}
+
+func TestRun_Reindex(t *testing.T) {
+	original := newEmbeddingProvider
+	var models []string
+	newEmbeddingProvider = func(_ *config.Config, model string) embeddings.EmbeddingProvider {
+		models = append(models, model)
+		return fakeEmbeddings{}
+	}
+	t.Cleanup(func() { newEmbeddingProvider = original })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "refunds.md"), []byte("A refund takes 5 days."), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shipping.md"), []byte("Shipping is free."), 0o600))
+	index := filepath.Join(dir, "knowledge.json")
+	code, _, stderr := runCLI(t, "", "ingest", "-out", index, dir)
+	require.Equal(t, exitOK, code, stderr)
+
+	code, _, _ = runCLI(t, "", "reindex", index)
+	assert.Equal(t, exitUsage, code)
+
+	code, stdout, stderr := runCLI(t, "", "reindex", "-embedding-model", "text-embedding-3-large", "-batch-size", "1", index)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "Reindexed 2 chunks")
+	assert.Contains(t, stderr, "Re-embedded 2/2 chunks")
+	assert.Equal(t, "text-embedding-3-large", models[len(models)-1])
+
+	store, err := loadKnowledge(config.Default(), index)
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.Count())
+}
+
+func TestRun_IngestDuplicates(t *testing.T) {
+	original := newEmbeddingProvider
+	newEmbeddingProvider = func(*config.Config, string) embeddings.EmbeddingProvider { return fakeEmbeddings{} }
+	t.Cleanup(func() { newEmbeddingProvider = original })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "refunds.md"), []byte("A refund takes 5 days."), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "faq.md"), []byte("A refund takes five days."), 0o600))
+
+	index := filepath.Join(dir, "knowledge.json")
+	code, stdout, stderr := runCLI(t, "", "ingest", "-out", index, "-duplicates", "merge", dir)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "Indexed 1 chunks from 2 files")
+	assert.Contains(t, stdout, "merging 1 duplicates")
+
+	store, err := loadKnowledge(config.Default(), index)
+	require.NoError(t, err)
+	entries := store.Find(func(metadata map[string]interface{}) bool { return true })
+	require.Len(t, entries, 1)
+	duplicates, _ := entries[0][embeddings.MetadataDuplicates].([]map[string]interface{})
+	require.Len(t, duplicates, 1)
+	assert.Equal(t, filepath.Join(dir, "refunds.md"), duplicates[0]["source"])
+
+	code, _, _ = runCLI(t, "", "ingest", "-out", index, "-duplicates", "drop", dir)
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestRun_MCP(t *testing.T) {
+	stdin := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"ask","arguments":{"message":"Hello"}}}
+`
+	code, stdout, stderr := runCLI(t, stdin, "mcp", "-model", "free")
+	require.Equal(t, exitOK, code, stderr)
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"protocolVersion":"2025-06-18"`)
+	assert.Contains(t, lines[1], `"id":2`)
+	assert.Contains(t, lines[1], `"type":"text"`)
+}
+
+func TestSSEWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newSSEWriter(&out)
+
+	w.Write([]byte("data: {\"id\":\"1\",\"content\":\"Hel\"}\n\ndata: {\"id\":\"1\",\"con"))
+	w.Write([]byte("tent\":\"lo\"}\n\ndata: {\"id\":\"1\",\"done\":true}\n\n"))
+	assert.Equal(t, "Hello", out.String())
+	assert.Equal(t, "Hello", w.reply.String())
+
+	w.Write([]byte("data: {\"error\":\"rate limit exceeded\",\"done\":true}\n\n"))
+	assert.Equal(t, "rate limit exceeded", w.err)
+}
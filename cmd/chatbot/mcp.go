@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/mcp"
+)
+
+// runMCP serves the chatbot as an MCP server on stdio or HTTP.
+func runMCP(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("mcp", "", stderr)
+	cfgFlags := addConfigFlags(fs)
+	knowledge := fs.String("knowledge", "", "knowledge index to expose as the search_knowledge tool")
+	addr := fs.String("http", "", "serve MCP over HTTP on this address instead of stdio, e.g. :8090")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cfgFlags.load()
+	if err != nil {
+		return err
+	}
+	bot, err := gochatbot.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	var opts []mcp.Option
+	if *knowledge != "" {
+		store, err := loadKnowledge(cfg, *knowledge)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, mcp.WithKnowledgeBase(store))
+	}
+	server := mcp.NewServer(bot, opts...)
+
+	if *addr == "" {
+		// stdout carries the protocol, so it must not receive anything else
+		if err := server.Serve(ctx, stdin, stdout); err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(stderr, "Serving MCP on http://%s\n", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
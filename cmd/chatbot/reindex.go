@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// runReindex re-embeds a knowledge index with another embedding model.
+func runReindex(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("reindex", "<index>", stderr)
+	cfgFlags := addConfigFlags(flags)
+	out := flags.String("out", "", "knowledge index file to write (default: replace the index)")
+	model := flags.String("embedding-model", "", "OpenAI embedding model to re-embed with")
+	batchSize := flags.Int("batch-size", embeddings.DefaultReindexBatchSize, "chunks to embed per request")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *model == "" || *batchSize <= 0 {
+		flags.Usage()
+		return errUsage
+	}
+	if *out == "" {
+		*out = flags.Arg(0)
+	}
+
+	// Reindexing only needs embeddings, so skip provider validation
+	cfg, err := cfgFlags.read()
+	if err != nil {
+		return err
+	}
+
+	store, err := loadKnowledge(cfg, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	provider := newEmbeddingProvider(cfg, *model)
+	err = store.Reindex(ctx, provider, embeddings.WithBatchSize(*batchSize), embeddings.WithProgress(func(done, total int) {
+		fmt.Fprintf(stderr, "Re-embedded %d/%d chunks\n", done, total)
+	}))
+	if err != nil {
+		return err
+	}
+	if err := saveKnowledge(*out, provider.Model(), store); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Reindexed %d chunks with %s into %s\n", store.Count(), provider.Model(), *out)
+	return nil
+}
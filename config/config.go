@@ -2,13 +2,18 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"time"
 )
 
 // Config represents the main configuration for the chatbot.
 type Config struct {
+	// Profile is the name of the configuration profile in effect, if any.
+	Profile string `json:"profile" yaml:"profile"`
+
 	// AI Model Configuration
 	Model string `json:"model" yaml:"model"`
 
@@ -30,6 +35,9 @@ type Config struct {
 	// Ollama Configuration
 	Ollama OllamaConfig `json:"ollama" yaml:"ollama"`
 
+	// Free model Configuration
+	Free FreeConfig `json:"free" yaml:"free"`
+
 	// Chatbot Behavior
 	Prompt   string `json:"prompt" yaml:"prompt"`
 	Language string `json:"language" yaml:"language"`
@@ -49,8 +57,18 @@ type Config struct {
 	Deescalate bool `json:"deescalate" yaml:"deescalate"`
 	Funny      bool `json:"funny" yaml:"funny"`
 
+	// Maintenance mode
+	Maintenance MaintenanceConfig `json:"maintenance" yaml:"maintenance"`
+
+	// Analytics exporters
+	Analytics AnalyticsConfig `json:"analytics" yaml:"analytics"`
+
 	// Allowed Scripts
-	AllowedScripts []string `json:"allowed_scripts" yaml:"allowed_scripts"`
+	AllowedScripts []string `json:"allowed_scripts" yaml:"allowed_scripts" desc:"Unicode scripts messages may be written in"`
+
+	// Providers holds settings for third-party providers, keyed by the name
+	// they were registered under with RegisterProvider.
+	Providers map[string]map[string]interface{} `json:"providers,omitempty" yaml:"providers,omitempty" desc:"Settings of providers added with RegisterProvider, by name"`
 }
 
 // OpenAIConfig contains OpenAI-specific configuration.
@@ -58,6 +76,17 @@ type OpenAIConfig struct {
 	APIKey   string `json:"api_key" yaml:"api_key"`
 	Model    string `json:"model" yaml:"model"`
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Generation holds default generation settings for this provider.
+	Generation GenerationConfig `json:"generation" yaml:"generation"`
+
+	// CompletionHealthCheck makes Health fall back to a tiny completion when
+	// the API has no list-models endpoint, to verify the API key too.
+	CompletionHealthCheck bool `json:"completion_health_check" yaml:"completion_health_check" desc:"Check health with a tiny completion when the API cannot list models"`
+
+	// MaxStreamLineSize is the longest line accepted in a streamed response,
+	// in bytes; 0 means 1 MB. Raise it for very large tool call arguments.
+	MaxStreamLineSize int `json:"max_stream_line_size" yaml:"max_stream_line_size" desc:"Longest line of a streamed response in bytes (0 means 1 MB)"`
 }
 
 // AnthropicConfig contains Anthropic-specific configuration.
@@ -65,13 +94,39 @@ type AnthropicConfig struct {
 	APIKey   string `json:"api_key" yaml:"api_key"`
 	Model    string `json:"model" yaml:"model"`
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Generation holds default generation settings for this provider.
+	Generation GenerationConfig `json:"generation" yaml:"generation"`
+
+	// ThinkingBudget enables extended thinking with this many tokens for
+	// reasoning before the answer (at least 1024). 0 disables it.
+	ThinkingBudget int `json:"thinking_budget" yaml:"thinking_budget"`
+
+	// CompletionHealthCheck makes Health fall back to a tiny completion when
+	// the API has no list-models endpoint, to verify the API key too.
+	CompletionHealthCheck bool `json:"completion_health_check" yaml:"completion_health_check" desc:"Check health with a tiny completion when the API cannot list models"`
+
+	// MaxStreamLineSize is the longest line accepted in a streamed response,
+	// in bytes; 0 means 1 MB. Raise it for very large tool call arguments.
+	MaxStreamLineSize int `json:"max_stream_line_size" yaml:"max_stream_line_size" desc:"Longest line of a streamed response in bytes (0 means 1 MB)"`
 }
 
+// MinThinkingBudget is the smallest extended thinking budget Anthropic
+// accepts.
+const MinThinkingBudget = 1024
+
 // GeminiConfig contains Google Gemini-specific configuration.
 type GeminiConfig struct {
 	APIKey   string `json:"api_key" yaml:"api_key"`
 	Model    string `json:"model" yaml:"model"`
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Generation holds default generation settings for this provider.
+	Generation GenerationConfig `json:"generation" yaml:"generation"`
+
+	// CompletionHealthCheck makes Health fall back to a tiny completion when
+	// the API has no list-models endpoint, to verify the API key too.
+	CompletionHealthCheck bool `json:"completion_health_check" yaml:"completion_health_check" desc:"Check health with a tiny completion when the API cannot list models"`
 }
 
 // XAIConfig contains xAI-specific configuration.
@@ -79,6 +134,17 @@ type XAIConfig struct {
 	APIKey   string `json:"api_key" yaml:"api_key"`
 	Model    string `json:"model" yaml:"model"`
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Generation holds default generation settings for this provider.
+	Generation GenerationConfig `json:"generation" yaml:"generation"`
+
+	// CompletionHealthCheck makes Health fall back to a tiny completion when
+	// the API has no list-models endpoint, to verify the API key too.
+	CompletionHealthCheck bool `json:"completion_health_check" yaml:"completion_health_check" desc:"Check health with a tiny completion when the API cannot list models"`
+
+	// MaxStreamLineSize is the longest line accepted in a streamed response,
+	// in bytes; 0 means 1 MB. Raise it for very large tool call arguments.
+	MaxStreamLineSize int `json:"max_stream_line_size" yaml:"max_stream_line_size" desc:"Longest line of a streamed response in bytes (0 means 1 MB)"`
 }
 
 // MetaConfig contains Meta-specific configuration.
@@ -86,77 +152,274 @@ type MetaConfig struct {
 	APIKey   string `json:"api_key" yaml:"api_key"`
 	Model    string `json:"model" yaml:"model"`
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Generation holds default generation settings for this provider.
+	Generation GenerationConfig `json:"generation" yaml:"generation"`
+
+	// CompletionHealthCheck makes Health fall back to a tiny completion when
+	// the API has no list-models endpoint, to verify the API key too.
+	CompletionHealthCheck bool `json:"completion_health_check" yaml:"completion_health_check" desc:"Check health with a tiny completion when the API cannot list models"`
+
+	// MaxStreamLineSize is the longest line accepted in a streamed response,
+	// in bytes; 0 means 1 MB. Raise it for very large tool call arguments.
+	MaxStreamLineSize int `json:"max_stream_line_size" yaml:"max_stream_line_size" desc:"Longest line of a streamed response in bytes (0 means 1 MB)"`
 }
 
 // OllamaConfig contains Ollama-specific configuration.
 type OllamaConfig struct {
 	Endpoint string `json:"endpoint" yaml:"endpoint"`
 	Model    string `json:"model" yaml:"model"`
+
+	// Generation holds default generation settings for this provider.
+	Generation GenerationConfig `json:"generation" yaml:"generation"`
+}
+
+// FreeConfig contains configuration for the built-in free model.
+type FreeConfig struct {
+	// Seed makes the choice between canned replies deterministic, for
+	// tests. 0 picks them at random.
+	Seed int64 `json:"seed" yaml:"seed" desc:"Seed for choosing between canned replies (0 picks at random)"`
+
+	// StreamDelay is the pause between the words of a streamed reply
+	// (50ms if 0).
+	StreamDelay time.Duration `json:"stream_delay" yaml:"stream_delay" desc:"Pause between the words of a streamed reply (0 means 50ms)"`
+
+	// Responses are checked in order before the built-in replies; the
+	// first one matching the message answers it.
+	Responses []FreeResponse `json:"responses" yaml:"responses"`
+}
+
+// FreeResponse maps an intent to a reply of the free model. It matches
+// when any of its keywords or patterns is found in the message.
+type FreeResponse struct {
+	// Keywords are phrases matched as whole words, ignoring case.
+	Keywords []string `json:"keywords" yaml:"keywords" desc:"Phrases matched as whole words, ignoring case"`
+	// Patterns are regular expressions (RE2 syntax).
+	Patterns []string `json:"patterns" yaml:"patterns" desc:"Regular expressions (RE2) matched against the message"`
+	// Response is a text/template rendered with the message, the match
+	// and its groups, for example "Order {{index .Groups 1}} ships today."
+	Response string `json:"response" yaml:"response" desc:"Reply template, rendered with the message, the match and its groups"`
+}
+
+// GenerationConfig contains default generation parameters for a provider.
+// Unset fields fall back to the global MaxTokens and Temperature settings,
+// and per-request values passed in the Ask context override all of them.
+type GenerationConfig struct {
+	MaxTokens   int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty" desc:"Maximum tokens per reply of this provider (0 uses max_tokens)"`
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty" desc:"Sampling temperature of this provider (unset uses temperature)"`
+	TopP        *float64 `json:"top_p,omitempty" yaml:"top_p,omitempty" desc:"Nucleus sampling probability"`
+	Stop        []string `json:"stop,omitempty" yaml:"stop,omitempty" desc:"Sequences ending a reply"`
+}
+
+// Merge returns g with every unset field taken from fallback.
+func (g GenerationConfig) Merge(fallback GenerationConfig) GenerationConfig {
+	if g.MaxTokens == 0 {
+		g.MaxTokens = fallback.MaxTokens
+	}
+	if g.Temperature == nil {
+		g.Temperature = fallback.Temperature
+	}
+	if g.TopP == nil {
+		g.TopP = fallback.TopP
+	}
+	if len(g.Stop) == 0 {
+		g.Stop = fallback.Stop
+	}
+	return g
 }
 
+// Generation returns the effective generation defaults for a provider:
+// the provider's own settings, completed with the global MaxTokens and
+// Temperature. A global temperature of 0 is treated as unset; use the
+// per-provider Temperature to request fully deterministic output.
+func (c *Config) Generation(provider GenerationConfig) GenerationConfig {
+	global := GenerationConfig{MaxTokens: c.MaxTokens}
+	if c.Temperature > 0 {
+		temperature := c.Temperature
+		global.Temperature = &temperature
+	}
+	return provider.Merge(global)
+}
+
+// ModelGeneration returns the effective generation defaults of the selected
+// Model: Generation applied to the provider's settings, or to none for the
+// free model and registered providers.
+func (c *Config) ModelGeneration() GenerationConfig {
+	var provider GenerationConfig
+	switch c.Model {
+	case "openai":
+		provider = c.OpenAI.Generation
+	case "anthropic":
+		provider = c.Anthropic.Generation
+	case "gemini":
+		provider = c.Gemini.Generation
+	case "xai":
+		provider = c.XAI.Generation
+	case "meta":
+		provider = c.Meta.Generation
+	case "ollama":
+		provider = c.Ollama.Generation
+	}
+	return c.Generation(provider)
+}
+
+// Rate limiting algorithms for RateLimitConfig.Algorithm.
+const (
+	// RateLimitFixedWindow counts requests in consecutive windows. It needs
+	// one counter per client, but a client can make up to twice the limit
+	// around the end of a window.
+	RateLimitFixedWindow = "fixed_window"
+	// RateLimitSlidingWindow logs each request and counts those in the last
+	// window. It is exact, but keeps up to RequestsPerMinute timestamps per
+	// client. It is the default.
+	RateLimitSlidingWindow = "sliding_window"
+	// RateLimitTokenBucket refills a bucket of BurstSize tokens at
+	// RequestsPerMinute tokens per window, taking one per request. It allows
+	// short bursts while smoothing the average rate, in constant memory.
+	RateLimitTokenBucket = "token_bucket"
+)
+
 // RateLimitConfig contains rate limiting configuration.
 type RateLimitConfig struct {
-	RequestsPerMinute int           `json:"requests_per_minute" yaml:"requests_per_minute"`
-	BurstSize         int           `json:"burst_size" yaml:"burst_size"`
-	Window            time.Duration `json:"window" yaml:"window"`
+	// RequestsPerMinute is the number of requests allowed per Window; zero
+	// disables rate limiting.
+	RequestsPerMinute int `json:"requests_per_minute" yaml:"requests_per_minute"`
+	// BurstSize is the token bucket size, defaulting to RequestsPerMinute.
+	// The window algorithms ignore it.
+	BurstSize int `json:"burst_size" yaml:"burst_size"`
+	// Window defaults to a minute.
+	Window time.Duration `json:"window" yaml:"window"`
+	// Algorithm is one of the RateLimit* algorithms, defaulting to
+	// RateLimitSlidingWindow.
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	// Overrides replace the limits of the requests they match, resolved
+	// per request; the first match applies, so list specific ones first.
+	Overrides []RateLimitOverride `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// Rate limited routes for RateLimitOverride.Route.
+const (
+	RateLimitRouteChat   = "chat"
+	RateLimitRouteStream = "stream"
+	RateLimitRouteAgent  = "agent"
+)
+
+// RateLimitOverride sets the limits of some requests, such as streams or
+// users of a premium tier. Requests it matches are counted separately from
+// the others.
+type RateLimitOverride struct {
+	// Route, Persona and Tier select the requests to the route (one of the
+	// RateLimitRoute* routes), answered as the persona, or made by users of
+	// the tier. Empty fields match any request; at least one must be set.
+	Route   string `json:"route,omitempty" yaml:"route,omitempty" desc:"Route the override applies to: chat, stream or agent"`
+	Persona string `json:"persona,omitempty" yaml:"persona,omitempty" desc:"Persona the override applies to"`
+	Tier    string `json:"tier,omitempty" yaml:"tier,omitempty" desc:"User tier the override applies to"`
+
+	// The limits, like those of RateLimitConfig; zero values keep the
+	// global ones. A negative RequestsPerMinute lifts the limit.
+	RequestsPerMinute int           `json:"requests_per_minute,omitempty" yaml:"requests_per_minute,omitempty" desc:"Requests allowed per window (negative lifts the limit)"`
+	BurstSize         int           `json:"burst_size,omitempty" yaml:"burst_size,omitempty" desc:"Burst size for the token bucket"`
+	Window            time.Duration `json:"window,omitempty" yaml:"window,omitempty" desc:"Rate limiting window"`
+	Algorithm         string        `json:"algorithm,omitempty" yaml:"algorithm,omitempty" desc:"Rate limiting algorithm"`
+}
+
+// Matches reports whether the override applies to a request to route,
+// answered as persona, by a user of tier.
+func (o RateLimitOverride) Matches(route, persona, tier string) bool {
+	if o.Route == "" && o.Persona == "" && o.Tier == "" {
+		return false
+	}
+	return (o.Route == "" || o.Route == route) &&
+		(o.Persona == "" || o.Persona == persona) &&
+		(o.Tier == "" || o.Tier == tier)
+}
+
+// Apply returns limits with the override's limits in place of theirs.
+// The result has no overrides.
+func (o RateLimitOverride) Apply(limits RateLimitConfig) RateLimitConfig {
+	if o.RequestsPerMinute != 0 {
+		limits.RequestsPerMinute = o.RequestsPerMinute
+	}
+	if o.BurstSize != 0 {
+		limits.BurstSize = o.BurstSize
+	}
+	if o.Window != 0 {
+		limits.Window = o.Window
+	}
+	if o.Algorithm != "" {
+		limits.Algorithm = o.Algorithm
+	}
+	limits.Overrides = nil
+	return limits
+}
+
+// MaintenanceConfig puts the chatbot into maintenance mode, in which new
+// chats are turned away while those already streaming finish.
+type MaintenanceConfig struct {
+	// Enabled turns maintenance mode on.
+	Enabled bool `json:"enabled" yaml:"enabled" desc:"Turn new chats away for maintenance"`
+	// Queue holds new chats until maintenance ends or they time out,
+	// instead of rejecting them at once.
+	Queue bool `json:"queue" yaml:"queue" desc:"Hold new chats until maintenance ends instead of rejecting them"`
+	// Message is the text/template told to clients turned away, executed
+	// with the config, so it can mention {{.Until}}. It has a default.
+	Message string `json:"message,omitempty" yaml:"message,omitempty" desc:"Template of the message told to clients turned away"`
+	// Until is when maintenance is expected to end, if known.
+	Until time.Time `json:"until,omitempty" yaml:"until,omitempty" desc:"When maintenance is expected to end"`
+}
+
+// AnalyticsConfig configures exporting analytics events. Each exporter is
+// used when Enabled is set and it has its key or URL.
+type AnalyticsConfig struct {
+	// Enabled turns analytics on.
+	Enabled bool                   `json:"enabled" yaml:"enabled"`
+	Segment SegmentAnalyticsConfig `json:"segment" yaml:"segment"`
+	PostHog PostHogAnalyticsConfig `json:"posthog" yaml:"posthog"`
+	HTTP    HTTPAnalyticsConfig    `json:"http" yaml:"http"`
+}
+
+// SegmentAnalyticsConfig configures exporting to Segment.
+type SegmentAnalyticsConfig struct {
+	// WriteKey is the source's write key.
+	WriteKey string `json:"write_key" yaml:"write_key"`
+	// Endpoint overrides the tracking API URL, e.g. for the EU region.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty" desc:"Segment tracking API URL, e.g. for the EU region"`
+}
+
+// PostHogAnalyticsConfig configures exporting to PostHog.
+type PostHogAnalyticsConfig struct {
+	// APIKey is the project API key.
+	APIKey string `json:"api_key" yaml:"api_key"`
+	// Host is the PostHog instance, defaulting to PostHog Cloud US.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+}
+
+// HTTPAnalyticsConfig configures exporting to a generic HTTP sink.
+type HTTPAnalyticsConfig struct {
+	// URL receives a POST with each event as JSON.
+	URL string `json:"url" yaml:"url"`
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty" desc:"Headers added to every analytics request"`
 }
 
 // MessageFilteringConfig contains message filtering configuration.
 type MessageFilteringConfig struct {
-	Instructions       []string `json:"instructions" yaml:"instructions"`
-	Profanities        []string `json:"profanities" yaml:"profanities"`
-	AggressionPatterns []string `json:"aggression_patterns" yaml:"aggression_patterns"`
-	LinkPattern        string   `json:"link_pattern" yaml:"link_pattern"`
+	Instructions       []string `json:"instructions" yaml:"instructions" desc:"Instructions passed to the model with every message"`
+	Profanities        []string `json:"profanities" yaml:"profanities" desc:"Words filtered out of messages"`
+	AggressionPatterns []string `json:"aggression_patterns" yaml:"aggression_patterns" desc:"Patterns marking aggressive messages"`
+	LinkPattern        string   `json:"link_pattern" yaml:"link_pattern" desc:"Regular expression matching links"`
 	Enabled            bool     `json:"enabled" yaml:"enabled"`
 }
 
 // Default returns a default configuration with environment variable overrides.
+// See EnvSpec for the list of supported variables.
 func Default() *Config {
+	return DefaultWithPrefix("")
+}
+
+// baseConfig returns the defaults that cannot be set from the environment.
+func baseConfig() *Config {
 	return &Config{
-		Model: getEnv("CHATBOT_MODEL", "free"),
-		OpenAI: OpenAIConfig{
-			APIKey:   getEnv("OPENAI_API_KEY", ""),
-			Model:    getEnv("OPENAI_MODEL", "gpt-4o"),
-			Endpoint: getEnv("OPENAI_ENDPOINT", "https://api.openai.com/v1/chat/completions"),
-		},
-		Anthropic: AnthropicConfig{
-			APIKey:   getEnv("ANTHROPIC_API_KEY", ""),
-			Model:    getEnv("ANTHROPIC_MODEL", "claude-3-sonnet-20240229"),
-			Endpoint: getEnv("ANTHROPIC_ENDPOINT", "https://api.anthropic.com/v1/messages"),
-		},
-		Gemini: GeminiConfig{
-			APIKey:   getEnv("GEMINI_API_KEY", ""),
-			Model:    getEnv("GEMINI_MODEL", "gemini-1.5-pro"),
-			Endpoint: getEnv("GEMINI_ENDPOINT", "https://generativelanguage.googleapis.com/v1beta/models"),
-		},
-		XAI: XAIConfig{
-			APIKey:   getEnv("XAI_API_KEY", ""),
-			Model:    getEnv("XAI_MODEL", "grok-1"),
-			Endpoint: getEnv("XAI_ENDPOINT", "https://api.x.ai/v1/chat/completions"),
-		},
-		Meta: MetaConfig{
-			APIKey:   getEnv("META_API_KEY", ""),
-			Model:    getEnv("META_MODEL", "llama-3-70b"),
-			Endpoint: getEnv("META_ENDPOINT", "https://api.meta.ai/v1/chat/completions"),
-		},
-		Ollama: OllamaConfig{
-			Endpoint: getEnv("OLLAMA_ENDPOINT", "http://localhost:11434/api/chat"),
-			Model:    getEnv("OLLAMA_MODEL", "llama2"),
-		},
-		Prompt:      getEnv("CHATBOT_PROMPT", "You are a helpful, friendly chatbot."),
-		Language:    getEnv("CHATBOT_LANGUAGE", "en"),
-		Tone:        getEnv("CHATBOT_TONE", "neutral"),
-		Timeout:     getDurationEnv("CHATBOT_TIMEOUT", 30*time.Second),
-		MaxTokens:   getIntEnv("CHATBOT_MAX_TOKENS", 256),
-		Temperature: getFloatEnv("CHATBOT_TEMPERATURE", 0.7),
-		Emojis:      getBoolEnv("CHATBOT_EMOJIS", true),
-		Deescalate:  getBoolEnv("CHATBOT_DEESCALATE", true),
-		Funny:       getBoolEnv("CHATBOT_FUNNY", false),
-		RateLimit: RateLimitConfig{
-			RequestsPerMinute: getIntEnv("RATE_LIMIT_REQUESTS", 10),
-			BurstSize:         getIntEnv("RATE_LIMIT_BURST", 5),
-			Window:            getDurationEnv("RATE_LIMIT_WINDOW", time.Minute),
-		},
 		MessageFiltering: MessageFilteringConfig{
 			Instructions: []string{
 				"Avoid sharing external links.",
@@ -168,70 +431,146 @@ func Default() *Config {
 			Profanities:        []string{},
 			AggressionPatterns: []string{"hate", "kill", "stupid", "idiot"},
 			LinkPattern:        `https?://[\w\.-]+`,
-			Enabled:            getBoolEnv("FILTER_ENABLED", true),
 		},
 		AllowedScripts: []string{"Latin", "Cyrillic", "Greek", "Armenian", "Han", "Kana", "Hangul"},
 	}
 }
 
-// Validate checks if the configuration is valid.
+// Validate checks if the configuration is valid. It reports every invalid
+// field at once as a *ValidationError; use errors.Is with the sentinel errors
+// (e.g. ErrMissingAPIKey) or errors.As to inspect individual fields.
 func (c *Config) Validate() error {
+	verr := &ValidationError{}
+
 	if c.Model == "" {
-		return ErrInvalidModel
+		verr.add("model", nil, "one of openai, anthropic, gemini, xai, meta, ollama, free", ErrInvalidModel)
 	}
 
 	if c.Timeout <= 0 {
-		return ErrInvalidTimeout
+		verr.add("timeout", c.Timeout, "> 0", ErrInvalidTimeout)
 	}
 
 	if c.MaxTokens <= 0 {
-		return ErrInvalidMaxTokens
+		verr.add("max_tokens", c.MaxTokens, "> 0", ErrInvalidMaxTokens)
 	}
 
 	if c.Temperature < 0 || c.Temperature > 2 {
-		return ErrInvalidTemperature
+		verr.add("temperature", c.Temperature, "0–2", ErrInvalidTemperature)
+	}
+
+	switch c.RateLimit.Algorithm {
+	case "", RateLimitFixedWindow, RateLimitSlidingWindow, RateLimitTokenBucket:
+	default:
+		verr.add("rate_limit.algorithm", c.RateLimit.Algorithm, "one of fixed_window, sliding_window, token_bucket", ErrInvalidRateLimitAlgorithm)
+	}
+	for i, override := range c.RateLimit.Overrides {
+		field := fmt.Sprintf("rate_limit.overrides[%d]", i)
+		if override.Route == "" && override.Persona == "" && override.Tier == "" {
+			verr.add(field, nil, "a route, persona or tier", ErrInvalidRateLimitOverride)
+		}
+		switch override.Route {
+		case "", RateLimitRouteChat, RateLimitRouteStream, RateLimitRouteAgent:
+		default:
+			verr.add(field+".route", override.Route, "one of chat, stream, agent", ErrInvalidRateLimitOverride)
+		}
+		switch override.Algorithm {
+		case "", RateLimitFixedWindow, RateLimitSlidingWindow, RateLimitTokenBucket:
+		default:
+			verr.add(field+".algorithm", override.Algorithm, "one of fixed_window, sliding_window, token_bucket", ErrInvalidRateLimitAlgorithm)
+		}
+	}
+
+	// Validate per-provider generation defaults
+	for _, provider := range []struct {
+		field string
+		gen   GenerationConfig
+	}{
+		{"openai", c.OpenAI.Generation},
+		{"anthropic", c.Anthropic.Generation},
+		{"gemini", c.Gemini.Generation},
+		{"xai", c.XAI.Generation},
+		{"meta", c.Meta.Generation},
+		{"ollama", c.Ollama.Generation},
+	} {
+		field, gen := provider.field, provider.gen
+		if gen.Temperature != nil && (*gen.Temperature < 0 || *gen.Temperature > 2) {
+			verr.add(field+".generation.temperature", *gen.Temperature, "0–2", ErrInvalidTemperature)
+		}
+		if gen.MaxTokens < 0 {
+			verr.add(field+".generation.max_tokens", gen.MaxTokens, ">= 0", ErrInvalidMaxTokens)
+		}
+	}
+
+	// Validate message filtering patterns
+	for i, word := range c.MessageFiltering.Profanities {
+		if _, err := regexp.Compile(word); err != nil {
+			verr.add(fmt.Sprintf("message_filtering.profanities[%d]", i), word, "a valid regular expression", ErrInvalidPattern)
+		}
+	}
+	for i, pattern := range c.MessageFiltering.AggressionPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			verr.add(fmt.Sprintf("message_filtering.aggression_patterns[%d]", i), pattern, "a valid regular expression", ErrInvalidPattern)
+		}
+	}
+	if _, err := regexp.Compile(c.MessageFiltering.LinkPattern); err != nil {
+		verr.add("message_filtering.link_pattern", c.MessageFiltering.LinkPattern, "a valid regular expression", ErrInvalidPattern)
+	}
+
+	if c.Anthropic.ThinkingBudget != 0 && c.Anthropic.ThinkingBudget < MinThinkingBudget {
+		verr.add("anthropic.thinking_budget", c.Anthropic.ThinkingBudget, "0 or >= 1024", ErrInvalidThinkingBudget)
 	}
 
 	// Validate model-specific configuration
 	switch c.Model {
 	case "openai":
 		if c.OpenAI.APIKey == "" {
-			return ErrMissingAPIKey
+			verr.add("openai.api_key", nil, "non-empty when model is openai", ErrMissingAPIKey)
 		}
 	case "anthropic":
 		if c.Anthropic.APIKey == "" {
-			return ErrMissingAPIKey
+			verr.add("anthropic.api_key", nil, "non-empty when model is anthropic", ErrMissingAPIKey)
 		}
 	case "gemini":
 		if c.Gemini.APIKey == "" {
-			return ErrMissingAPIKey
+			verr.add("gemini.api_key", nil, "non-empty when model is gemini", ErrMissingAPIKey)
 		}
 	case "xai":
 		if c.XAI.APIKey == "" {
-			return ErrMissingAPIKey
+			verr.add("xai.api_key", nil, "non-empty when model is xai", ErrMissingAPIKey)
 		}
 	case "meta":
 		if c.Meta.APIKey == "" {
-			return ErrMissingAPIKey
+			verr.add("meta.api_key", nil, "non-empty when model is meta", ErrMissingAPIKey)
 		}
 	case "ollama":
 		if c.Ollama.Endpoint == "" {
-			return ErrMissingEndpoint
+			verr.add("ollama.endpoint", nil, "non-empty when model is ollama", ErrMissingEndpoint)
 		}
-	case "free":
-		// No validation needed for free model
+	case "free", "":
+		// No validation needed for free model; empty model is reported above
 	default:
-		return ErrUnsupportedModel
+		if IsRegisteredProvider(c.Model) {
+			break
+		}
+		verr.add("model", c.Model, "one of openai, anthropic, gemini, xai, meta, ollama, free", ErrUnsupportedModel)
 	}
 
+	if len(verr.Errors) > 0 {
+		return verr
+	}
 	return nil
 }
 
-// Helper functions for environment variable parsing
+// Helper functions for environment variable parsing.
+// Every string variable may also be supplied as KEY_FILE pointing to a file
+// holding the value (Docker and Kubernetes secrets); KEY takes precedence.
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
+	if value, ok := readSecretFile(key); ok {
+		return value
+	}
 	return defaultValue
 }
 
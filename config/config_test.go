@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -87,6 +88,30 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errType: ErrInvalidMaxTokens,
 		},
+		{
+			name: "thinking budget too small",
+			config: &Config{
+				Model:       "free",
+				Timeout:     30 * time.Second,
+				MaxTokens:   256,
+				Temperature: 0.7,
+				Anthropic:   AnthropicConfig{ThinkingBudget: 512},
+			},
+			wantErr: true,
+			errType: ErrInvalidThinkingBudget,
+		},
+		{
+			name: "invalid link pattern",
+			config: &Config{
+				Model:            "free",
+				Timeout:          30 * time.Second,
+				MaxTokens:        256,
+				Temperature:      0.7,
+				MessageFiltering: MessageFilteringConfig{LinkPattern: "https?://("},
+			},
+			wantErr: true,
+			errType: ErrInvalidPattern,
+		},
 		{
 			name: "invalid temperature",
 			config: &Config{
@@ -193,6 +218,42 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errType: ErrUnsupportedModel,
 		},
+		{
+			name: "unknown rate limit algorithm",
+			config: &Config{
+				Model:       "free",
+				Timeout:     30 * time.Second,
+				MaxTokens:   256,
+				Temperature: 0.7,
+				RateLimit:   RateLimitConfig{Algorithm: "leaky_bucket"},
+			},
+			wantErr: true,
+			errType: ErrInvalidRateLimitAlgorithm,
+		},
+		{
+			name: "rate limit override without a selector",
+			config: &Config{
+				Model:       "free",
+				Timeout:     30 * time.Second,
+				MaxTokens:   256,
+				Temperature: 0.7,
+				RateLimit:   RateLimitConfig{Overrides: []RateLimitOverride{{RequestsPerMinute: 5}}},
+			},
+			wantErr: true,
+			errType: ErrInvalidRateLimitOverride,
+		},
+		{
+			name: "rate limit override with an unknown route",
+			config: &Config{
+				Model:       "free",
+				Timeout:     30 * time.Second,
+				MaxTokens:   256,
+				Temperature: 0.7,
+				RateLimit:   RateLimitConfig{Overrides: []RateLimitOverride{{Route: "upload", RequestsPerMinute: 5}}},
+			},
+			wantErr: true,
+			errType: ErrInvalidRateLimitOverride,
+		},
 		{
 			name: "valid anthropic config",
 			config: &Config{
@@ -245,7 +306,7 @@ func TestValidate(t *testing.T) {
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.errType != nil {
-					assert.Equal(t, tt.errType, err)
+					assert.ErrorIs(t, err, tt.errType)
 				}
 			} else {
 				assert.NoError(t, err)
@@ -316,3 +377,52 @@ func TestGetEnvHelpers(t *testing.T) {
 		assert.Equal(t, time.Minute, result)
 	})
 }
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := &Config{
+		Model:       "openai",
+		Timeout:     0,
+		MaxTokens:   256,
+		Temperature: 2.5,
+	}
+
+	err := cfg.Validate()
+
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+	assert.Len(t, verr.Errors, 3)
+	assert.ErrorIs(t, err, ErrInvalidTimeout)
+	assert.ErrorIs(t, err, ErrInvalidTemperature)
+	assert.ErrorIs(t, err, ErrMissingAPIKey)
+
+	fields := make([]string, len(verr.Errors))
+	for i, fieldErr := range verr.Errors {
+		fields[i] = fieldErr.Field
+	}
+	assert.Equal(t, []string{"timeout", "temperature", "openai.api_key"}, fields)
+	assert.Contains(t, err.Error(), "temperature: temperature must be between 0 and 2 (got 2.5, expected 0–2)")
+	assert.Contains(t, err.Error(), "3 errors")
+}
+
+func TestGeneration(t *testing.T) {
+	cfg := &Config{MaxTokens: 256, Temperature: 0.7}
+	providerTemperature := 0.0
+
+	gen := cfg.Generation(GenerationConfig{Temperature: &providerTemperature})
+	assert.Equal(t, 256, gen.MaxTokens)
+	assert.Equal(t, 0.0, *gen.Temperature, "provider value wins over global")
+
+	gen = cfg.Generation(GenerationConfig{MaxTokens: 1024})
+	assert.Equal(t, 1024, gen.MaxTokens)
+	assert.Equal(t, 0.7, *gen.Temperature)
+
+	gen = (&Config{}).Generation(GenerationConfig{})
+	assert.Nil(t, gen.Temperature, "zero global temperature is treated as unset")
+
+	invalid := 3.0
+	cfg = Default()
+	cfg.Gemini.Generation.Temperature = &invalid
+	err := cfg.Validate()
+	assert.ErrorIs(t, err, ErrInvalidTemperature)
+	assert.Contains(t, err.Error(), "gemini.generation.temperature")
+}
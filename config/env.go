@@ -0,0 +1,261 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvVar describes an environment variable understood by Default and
+// DefaultWithPrefix.
+type EnvVar struct {
+	// Name is the variable name without any prefix, e.g. "OPENAI_API_KEY".
+	Name string `json:"name"`
+	// Field is the dotted configuration path the variable sets.
+	Field string `json:"field"`
+	// Default is the value used when the variable is not set.
+	Default string `json:"default"`
+	// Description explains the setting.
+	Description string `json:"description"`
+	// Secret marks credentials that should be supplied via NAME_FILE or a
+	// SecretProvider rather than as plain text.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// envBinding ties an EnvVar to the code that applies it to a Config.
+type envBinding struct {
+	EnvVar
+	apply func(c *Config, value string) bool
+	get   func(c *Config) string
+	// expected describes the accepted values, for strict mode
+	expected string
+}
+
+func stringVar(name, field, def, description string, target func(*Config) *string) envBinding {
+	return envBinding{
+		EnvVar: EnvVar{Name: name, Field: field, Default: def, Description: description},
+		apply: func(c *Config, value string) bool {
+			*target(c) = value
+			return true
+		},
+		get: func(c *Config) string { return *target(c) },
+	}
+}
+
+func secretVar(name, field, description string, target func(*Config) *string) envBinding {
+	binding := stringVar(name, field, "", description, target)
+	binding.Secret = true
+	return binding
+}
+
+func intVar(name, field, def, description string, target func(*Config) *int) envBinding {
+	return envBinding{
+		EnvVar: EnvVar{Name: name, Field: field, Default: def, Description: description},
+		apply: func(c *Config, value string) bool {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return false
+			}
+			*target(c) = parsed
+			return true
+		},
+		get:      func(c *Config) string { return strconv.Itoa(*target(c)) },
+		expected: "an integer",
+	}
+}
+
+func floatVar(name, field, def, description string, target func(*Config) *float64) envBinding {
+	return envBinding{
+		EnvVar: EnvVar{Name: name, Field: field, Default: def, Description: description},
+		apply: func(c *Config, value string) bool {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false
+			}
+			*target(c) = parsed
+			return true
+		},
+		get:      func(c *Config) string { return strconv.FormatFloat(*target(c), 'f', -1, 64) },
+		expected: "a number",
+	}
+}
+
+func boolVar(name, field, def, description string, target func(*Config) *bool) envBinding {
+	return envBinding{
+		EnvVar: EnvVar{Name: name, Field: field, Default: def, Description: description},
+		apply: func(c *Config, value string) bool {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return false
+			}
+			*target(c) = parsed
+			return true
+		},
+		get:      func(c *Config) string { return strconv.FormatBool(*target(c)) },
+		expected: "true or false",
+	}
+}
+
+func durationVar(name, field, def, description string, target func(*Config) *time.Duration) envBinding {
+	return envBinding{
+		EnvVar: EnvVar{Name: name, Field: field, Default: def, Description: description},
+		apply: func(c *Config, value string) bool {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return false
+			}
+			*target(c) = parsed
+			return true
+		},
+		get:      func(c *Config) string { return target(c).String() },
+		expected: "a duration such as 30s",
+	}
+}
+
+// envBindings is the single source of truth for every supported variable.
+var envBindings = []envBinding{
+	stringVar("CHATBOT_PROFILE", "profile", "", "Configuration profile to apply from the config file (e.g. dev, staging, prod)",
+		func(c *Config) *string { return &c.Profile }),
+	stringVar("CHATBOT_MODEL", "model", "free", "AI provider to use: openai, anthropic, gemini, xai, meta, ollama or free",
+		func(c *Config) *string { return &c.Model }),
+
+	secretVar("OPENAI_API_KEY", "openai.api_key", "OpenAI API key",
+		func(c *Config) *string { return &c.OpenAI.APIKey }),
+	stringVar("OPENAI_MODEL", "openai.model", "gpt-4o", "OpenAI model name",
+		func(c *Config) *string { return &c.OpenAI.Model }),
+	stringVar("OPENAI_ENDPOINT", "openai.endpoint", "https://api.openai.com/v1/chat/completions", "OpenAI chat completions URL",
+		func(c *Config) *string { return &c.OpenAI.Endpoint }),
+
+	secretVar("ANTHROPIC_API_KEY", "anthropic.api_key", "Anthropic API key",
+		func(c *Config) *string { return &c.Anthropic.APIKey }),
+	stringVar("ANTHROPIC_MODEL", "anthropic.model", "claude-3-sonnet-20240229", "Anthropic model name",
+		func(c *Config) *string { return &c.Anthropic.Model }),
+	stringVar("ANTHROPIC_ENDPOINT", "anthropic.endpoint", "https://api.anthropic.com/v1/messages", "Anthropic Messages API URL",
+		func(c *Config) *string { return &c.Anthropic.Endpoint }),
+	intVar("ANTHROPIC_THINKING_BUDGET", "anthropic.thinking_budget", "0", "Extended thinking budget in tokens (0 disables it)",
+		func(c *Config) *int { return &c.Anthropic.ThinkingBudget }),
+
+	secretVar("GEMINI_API_KEY", "gemini.api_key", "Google Gemini API key",
+		func(c *Config) *string { return &c.Gemini.APIKey }),
+	stringVar("GEMINI_MODEL", "gemini.model", "gemini-1.5-pro", "Gemini model name",
+		func(c *Config) *string { return &c.Gemini.Model }),
+	stringVar("GEMINI_ENDPOINT", "gemini.endpoint", "https://generativelanguage.googleapis.com/v1beta/models", "Gemini API base URL",
+		func(c *Config) *string { return &c.Gemini.Endpoint }),
+
+	secretVar("XAI_API_KEY", "xai.api_key", "xAI API key",
+		func(c *Config) *string { return &c.XAI.APIKey }),
+	stringVar("XAI_MODEL", "xai.model", "grok-1", "xAI model name",
+		func(c *Config) *string { return &c.XAI.Model }),
+	stringVar("XAI_ENDPOINT", "xai.endpoint", "https://api.x.ai/v1/chat/completions", "xAI API URL",
+		func(c *Config) *string { return &c.XAI.Endpoint }),
+
+	secretVar("META_API_KEY", "meta.api_key", "Meta Llama API key",
+		func(c *Config) *string { return &c.Meta.APIKey }),
+	stringVar("META_MODEL", "meta.model", "llama-3-70b", "Meta model name",
+		func(c *Config) *string { return &c.Meta.Model }),
+	stringVar("META_ENDPOINT", "meta.endpoint", "https://api.meta.ai/v1/chat/completions", "Meta API URL",
+		func(c *Config) *string { return &c.Meta.Endpoint }),
+
+	stringVar("OLLAMA_ENDPOINT", "ollama.endpoint", "http://localhost:11434/api/chat", "Ollama server URL",
+		func(c *Config) *string { return &c.Ollama.Endpoint }),
+	stringVar("OLLAMA_MODEL", "ollama.model", "llama2", "Ollama model name",
+		func(c *Config) *string { return &c.Ollama.Model }),
+
+	stringVar("CHATBOT_PROMPT", "prompt", "You are a helpful, friendly chatbot.", "System prompt",
+		func(c *Config) *string { return &c.Prompt }),
+	stringVar("CHATBOT_LANGUAGE", "language", "en", "Default reply language",
+		func(c *Config) *string { return &c.Language }),
+	stringVar("CHATBOT_TONE", "tone", "neutral", "Default reply tone",
+		func(c *Config) *string { return &c.Tone }),
+	durationVar("CHATBOT_TIMEOUT", "timeout", "30s", "Timeout for AI requests",
+		func(c *Config) *time.Duration { return &c.Timeout }),
+	intVar("CHATBOT_MAX_TOKENS", "max_tokens", "256", "Default maximum tokens per reply",
+		func(c *Config) *int { return &c.MaxTokens }),
+	floatVar("CHATBOT_TEMPERATURE", "temperature", "0.7", "Default sampling temperature (0–2)",
+		func(c *Config) *float64 { return &c.Temperature }),
+	boolVar("CHATBOT_EMOJIS", "emojis", "true", "Allow emojis in replies",
+		func(c *Config) *bool { return &c.Emojis }),
+	boolVar("CHATBOT_DEESCALATE", "deescalate", "true", "De-escalate aggressive conversations",
+		func(c *Config) *bool { return &c.Deescalate }),
+	boolVar("CHATBOT_FUNNY", "funny", "false", "Use a humorous tone",
+		func(c *Config) *bool { return &c.Funny }),
+
+	intVar("RATE_LIMIT_REQUESTS", "rate_limit.requests_per_minute", "10", "Requests allowed per client and window",
+		func(c *Config) *int { return &c.RateLimit.RequestsPerMinute }),
+	intVar("RATE_LIMIT_BURST", "rate_limit.burst_size", "5", "Burst size for the rate limiter",
+		func(c *Config) *int { return &c.RateLimit.BurstSize }),
+	durationVar("RATE_LIMIT_WINDOW", "rate_limit.window", "1m", "Rate limiting window",
+		func(c *Config) *time.Duration { return &c.RateLimit.Window }),
+	stringVar("RATE_LIMIT_ALGORITHM", "rate_limit.algorithm", "sliding_window", "Rate limiting algorithm: fixed_window, sliding_window or token_bucket",
+		func(c *Config) *string { return &c.RateLimit.Algorithm }),
+
+	boolVar("FILTER_ENABLED", "message_filtering.enabled", "true", "Enable message filtering",
+		func(c *Config) *bool { return &c.MessageFiltering.Enabled }),
+
+	boolVar("ANALYTICS_ENABLED", "analytics.enabled", "false", "Export analytics events",
+		func(c *Config) *bool { return &c.Analytics.Enabled }),
+	secretVar("SEGMENT_WRITE_KEY", "analytics.segment.write_key", "Segment source write key",
+		func(c *Config) *string { return &c.Analytics.Segment.WriteKey }),
+	secretVar("POSTHOG_API_KEY", "analytics.posthog.api_key", "PostHog project API key",
+		func(c *Config) *string { return &c.Analytics.PostHog.APIKey }),
+	stringVar("POSTHOG_HOST", "analytics.posthog.host", "", "PostHog instance URL",
+		func(c *Config) *string { return &c.Analytics.PostHog.Host }),
+	stringVar("ANALYTICS_HTTP_URL", "analytics.http.url", "", "URL receiving analytics events as JSON",
+		func(c *Config) *string { return &c.Analytics.HTTP.URL }),
+}
+
+// EnvSpec returns every environment variable understood by Default, in a
+// stable order, so deployments can document or validate their environment.
+// Names are returned without a prefix.
+func EnvSpec() []EnvVar {
+	spec := make([]EnvVar, len(envBindings))
+	for i, binding := range envBindings {
+		spec[i] = binding.EnvVar
+	}
+	return spec
+}
+
+// DefaultWithPrefix returns a default configuration whose environment
+// overrides are read from prefixed variables, e.g. with prefix "MYAPP" the
+// model is read from MYAPP_CHATBOT_MODEL and the OpenAI key from
+// MYAPP_OPENAI_API_KEY. This lets several services share one environment
+// without colliding. The _FILE variants are prefixed the same way.
+func DefaultWithPrefix(prefix string) *Config {
+	prefix = normalizePrefix(prefix)
+	cfg := baseConfig()
+
+	for _, binding := range envBindings {
+		if value := getEnv(prefix+binding.Name, ""); value != "" && binding.apply(cfg, value) {
+			continue
+		}
+		binding.apply(cfg, binding.Default)
+	}
+
+	return cfg
+}
+
+// Environ renders the configuration as NAME=value pairs for every variable
+// in EnvSpec, using the given prefix. Secrets are omitted unless
+// includeSecrets is true. It is the inverse of DefaultWithPrefix and is
+// useful for handing configuration to child processes.
+func (c *Config) Environ(prefix string, includeSecrets bool) []string {
+	prefix = normalizePrefix(prefix)
+
+	env := make([]string, 0, len(envBindings))
+	for _, binding := range envBindings {
+		if binding.Secret && !includeSecrets {
+			continue
+		}
+		env = append(env, prefix+binding.Name+"="+binding.get(c))
+	}
+	return env
+}
+
+// normalizePrefix makes sure a non-empty prefix ends with an underscore.
+func normalizePrefix(prefix string) string {
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return prefix
+}
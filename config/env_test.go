@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvSpec(t *testing.T) {
+	spec := EnvSpec()
+	require.NotEmpty(t, spec)
+
+	names := make(map[string]EnvVar)
+	for _, v := range spec {
+		assert.NotEmpty(t, v.Field, v.Name)
+		assert.NotEmpty(t, v.Description, v.Name)
+		_, duplicate := names[v.Name]
+		assert.False(t, duplicate, "duplicate variable %s", v.Name)
+		names[v.Name] = v
+	}
+
+	assert.Equal(t, "free", names["CHATBOT_MODEL"].Default)
+	assert.True(t, names["OPENAI_API_KEY"].Secret)
+	assert.False(t, names["OPENAI_MODEL"].Secret)
+}
+
+func TestEnvSpecDefaultsMatchDefault(t *testing.T) {
+	cfg := DefaultWithPrefix("UNSET_TEST_PREFIX")
+	for _, entry := range cfg.Environ("", true) {
+		name, value, _ := strings.Cut(entry, "=")
+		for _, v := range EnvSpec() {
+			if v.Name == name && v.Default != "" {
+				assert.Equal(t, normalizeDefault(v.Default), value, name)
+			}
+		}
+	}
+}
+
+// normalizeDefault renders duration defaults the way time.Duration prints them.
+// A bare "0" is an integer default, even though it parses as a duration.
+func normalizeDefault(value string) string {
+	if d, err := time.ParseDuration(value); err == nil && value != "0" {
+		return d.String()
+	}
+	return value
+}
+
+func TestDefaultWithPrefix(t *testing.T) {
+	t.Setenv("CHATBOT_MODEL", "anthropic")
+	t.Setenv("MYAPP_CHATBOT_MODEL", "openai")
+	t.Setenv("MYAPP_CHATBOT_TIMEOUT", "12s")
+	t.Setenv("MYAPP_CHATBOT_MAX_TOKENS", "not-a-number")
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("sk-prefixed"), 0o600))
+	t.Setenv("MYAPP_OPENAI_API_KEY_FILE", keyFile)
+
+	cfg := DefaultWithPrefix("myapp")
+	assert.Equal(t, "openai", cfg.Model)
+	assert.Equal(t, 12*time.Second, cfg.Timeout)
+	assert.Equal(t, 256, cfg.MaxTokens, "invalid values fall back to the default")
+	assert.Equal(t, "sk-prefixed", cfg.OpenAI.APIKey)
+
+	assert.Equal(t, "anthropic", Default().Model, "unprefixed lookup is unaffected")
+}
+
+func TestEnviron(t *testing.T) {
+	cfg := Default()
+	cfg.OpenAI.APIKey = "sk-secret"
+	cfg.Model = "openai"
+
+	env := cfg.Environ("SVC_", false)
+	assert.Contains(t, env, "SVC_CHATBOT_MODEL=openai")
+	assert.Contains(t, env, "SVC_CHATBOT_TIMEOUT=30s")
+	for _, entry := range env {
+		assert.NotContains(t, entry, "sk-secret")
+	}
+
+	assert.Contains(t, cfg.Environ("", true), "OPENAI_API_KEY=sk-secret")
+}
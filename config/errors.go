@@ -1,14 +1,88 @@
 package config
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // Configuration validation errors.
 var (
-	ErrInvalidModel       = errors.New("invalid model specified")
-	ErrInvalidTimeout     = errors.New("timeout must be greater than 0")
-	ErrInvalidMaxTokens   = errors.New("max_tokens must be greater than 0")
-	ErrInvalidTemperature = errors.New("temperature must be between 0 and 2")
-	ErrMissingAPIKey      = errors.New("API key is required for this model")
-	ErrMissingEndpoint    = errors.New("endpoint is required for this model")
-	ErrUnsupportedModel   = errors.New("unsupported model")
+	ErrInvalidModel              = errors.New("invalid model specified")
+	ErrInvalidTimeout            = errors.New("timeout must be greater than 0")
+	ErrInvalidMaxTokens          = errors.New("max_tokens must be greater than 0")
+	ErrInvalidTemperature        = errors.New("temperature must be between 0 and 2")
+	ErrMissingAPIKey             = errors.New("API key is required for this model")
+	ErrMissingEndpoint           = errors.New("endpoint is required for this model")
+	ErrUnsupportedModel          = errors.New("unsupported model")
+	ErrUnknownProfile            = errors.New("unknown configuration profile")
+	ErrInvalidRateLimitAlgorithm = errors.New("unknown rate limiting algorithm")
+	ErrInvalidRateLimitOverride  = errors.New("invalid rate limit override")
+	ErrInvalidThinkingBudget     = errors.New("thinking budget is too small")
+	ErrInvalidPattern            = errors.New("invalid regular expression")
+	ErrUnknownKey                = errors.New("unknown configuration key")
+	ErrUnknownEnvVar             = errors.New("unknown environment variable")
+	ErrInvalidEnvValue           = errors.New("invalid environment variable value")
 )
+
+// FieldError describes a single invalid configuration field.
+type FieldError struct {
+	// Field is the dotted path of the field, e.g. "openai.api_key", or the
+	// name of an environment variable checked by CheckEnv.
+	Field string
+	// Value is the offending value. Secrets are never included.
+	Value interface{}
+	// Expected describes the accepted values, e.g. "0–2".
+	Expected string
+	// Err is the sentinel error for this kind of failure.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *FieldError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Field, e.Err)
+	if e.Expected != "" {
+		if e.Value != nil {
+			return fmt.Sprintf("%s (got %v, expected %s)", msg, e.Value, e.Expected)
+		}
+		return fmt.Sprintf("%s (expected %s)", msg, e.Expected)
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error so errors.Is keeps working.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every invalid field found by Config.Validate.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Error()
+	}
+	if len(messages) == 1 {
+		return "invalid configuration: " + messages[0]
+	}
+	return fmt.Sprintf("invalid configuration: %d errors: %s", len(messages), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the individual field errors so errors.Is and errors.As can
+// match any of them.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// add records a field error.
+func (e *ValidationError) add(field string, value interface{}, expected string, err error) {
+	e.Errors = append(e.Errors, &FieldError{Field: field, Value: value, Expected: expected, Err: err})
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile reads a JSON or YAML configuration file and overlays it on top of
+// Default(), so any setting missing from the file keeps its default value.
+// The format is chosen from the file extension (.json, .yaml or .yml).
+//
+// If the file defines a "profiles" section, the profile named by the
+// CHATBOT_PROFILE environment variable (or the file's own "profile" key) is
+// applied on top of the top-level settings.
+func LoadFile(path string) (*Config, error) {
+	return LoadFileWithProfile(path, getEnv("CHATBOT_PROFILE", ""))
+}
+
+// LoadFileWithProfile is like LoadFile but selects the profile explicitly.
+// An empty profile falls back to the file's "profile" key, if any.
+func LoadFileWithProfile(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg := Default()
+	if err := decode(path, data, cfg); err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = cfg.Profile
+	}
+	if profile != "" {
+		if err := applyProfile(path, data, cfg, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// decode unmarshals data into cfg using the format implied by path.
+func decode(path string, data []byte, cfg *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("failed to parse JSON config: %w", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension: %q", filepath.Ext(path))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profileFile captures the profile section of a configuration file.
+// Each profile holds the same keys as the top-level configuration plus an
+// optional "extends" key naming the profile it inherits from.
+type profileFile struct {
+	Profiles map[string]map[string]interface{} `json:"profiles" yaml:"profiles"`
+}
+
+// applyProfile overlays the named profile, and every profile it extends,
+// on top of cfg. Ancestors are applied first so the most specific profile wins.
+func applyProfile(path string, data []byte, cfg *Config, name string) error {
+	var file profileFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse profiles: %w", err)
+		}
+	default:
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse profiles: %w", err)
+		}
+	}
+
+	chain, err := profileChain(file.Profiles, name)
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range chain {
+		overrides := make(map[string]interface{}, len(profile))
+		for key, value := range profile {
+			if key != "extends" {
+				overrides[key] = value
+			}
+		}
+
+		encoded, err := encodeLike(path, overrides)
+		if err != nil {
+			return fmt.Errorf("failed to encode profile: %w", err)
+		}
+		if err := decode(path, encoded, cfg); err != nil {
+			return err
+		}
+	}
+
+	cfg.Profile = name
+	return nil
+}
+
+// profileChain returns the profile and its ancestors, root first.
+func profileChain(profiles map[string]map[string]interface{}, name string) ([]map[string]interface{}, error) {
+	var chain []map[string]interface{}
+	seen := make(map[string]bool)
+
+	for current := name; current != ""; {
+		if seen[current] {
+			return nil, fmt.Errorf("profile %q has a circular extends chain", name)
+		}
+		seen[current] = true
+
+		profile, ok := profiles[current]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, current)
+		}
+		chain = append([]map[string]interface{}{profile}, chain...)
+
+		parent, _ := profile["extends"].(string)
+		current = parent
+	}
+
+	return chain, nil
+}
+
+// encodeLike marshals v in the format implied by path.
+func encodeLike(path string, v interface{}) ([]byte, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return json.Marshal(v)
+	}
+	return yaml.Marshal(v)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profilesYAML = `
+prompt: Base prompt
+temperature: 0.5
+profiles:
+  dev:
+    model: free
+    rate_limit:
+      requests_per_minute: 1000
+  staging:
+    model: openai
+    openai:
+      api_key: sk-staging
+    message_filtering:
+      enabled: true
+      profanities: [darn]
+  prod:
+    extends: staging
+    temperature: 0.2
+    openai:
+      model: gpt-4o
+  loop-a:
+    extends: loop-b
+  loop-b:
+    extends: loop-a
+`
+
+func writeProfiles(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chatbot.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(profilesYAML), 0o600))
+	return path
+}
+
+func TestLoadFileWithProfile(t *testing.T) {
+	path := writeProfiles(t)
+
+	dev, err := LoadFileWithProfile(path, "dev")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", dev.Profile)
+	assert.Equal(t, "free", dev.Model)
+	assert.Equal(t, 1000, dev.RateLimit.RequestsPerMinute)
+	assert.Equal(t, "Base prompt", dev.Prompt)
+
+	prod, err := LoadFileWithProfile(path, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "openai", prod.Model, "inherited from staging")
+	assert.Equal(t, "sk-staging", prod.OpenAI.APIKey, "nested keys merge across the chain")
+	assert.Equal(t, "gpt-4o", prod.OpenAI.Model)
+	assert.Equal(t, 0.2, prod.Temperature, "child profile wins")
+	assert.Equal(t, []string{"darn"}, prod.MessageFiltering.Profanities)
+
+	_, err = LoadFileWithProfile(path, "qa")
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+
+	_, err = LoadFileWithProfile(path, "loop-a")
+	assert.ErrorContains(t, err, "circular")
+}
+
+func TestLoadFileSelectsProfileFromEnv(t *testing.T) {
+	path := writeProfiles(t)
+
+	t.Setenv("CHATBOT_PROFILE", "staging")
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", cfg.Profile)
+	assert.Equal(t, "openai", cfg.Model)
+
+	t.Setenv("CHATBOT_PROFILE", "")
+	cfg, err = LoadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Profile)
+	assert.Equal(t, "free", cfg.Model)
+}
+
+func TestLoadFileProfileJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chatbot.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"profile": "dev",
+		"profiles": {"dev": {"tone": "casual", "max_tokens": 64}}
+	}`), 0o600))
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "casual", cfg.Tone)
+	assert.Equal(t, 64, cfg.MaxTokens)
+}
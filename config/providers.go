@@ -0,0 +1,40 @@
+package config
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]bool)
+)
+
+// RegisterProvider marks name as a valid Model value so Validate accepts
+// third-party providers. It is called by models.Register and rarely needs
+// to be called directly.
+func RegisterProvider(name string) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = true
+}
+
+// IsRegisteredProvider reports whether name was registered with RegisterProvider.
+func IsRegisteredProvider(name string) bool {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return providers[name]
+}
+
+// RegisteredProviders returns the names of all registered third-party providers, sorted.
+func RegisteredProviders() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
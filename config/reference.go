@@ -0,0 +1,224 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Setting describes a configuration option, as listed by Reference.
+type Setting struct {
+	// Key is the dotted path of the option in configuration files, e.g.
+	// "rate_limit.window". Options of list items have "[]" after the list,
+	// e.g. "rate_limit.overrides[].tier".
+	Key string `json:"key"`
+	// Type is the option's type: string, int, float, bool, duration, time,
+	// or a list ("[]string") or map ("map[string]string") of them.
+	Type string `json:"type"`
+	// Default is the value used when the option is not set, "" if none.
+	Default string `json:"default,omitempty"`
+	// Env is the environment variable setting the option, if any.
+	Env string `json:"env,omitempty"`
+	// Description explains the option.
+	Description string `json:"description,omitempty"`
+	// Secret marks credentials, like EnvVar.Secret.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// Reference returns every configuration option with its default, in the
+// order of the Config struct. It is produced from the struct tags of
+// Config: keys from the json tags, descriptions from the desc tags or the
+// option's environment variable.
+func Reference() []Setting {
+	bindings := make(map[string]envBinding, len(envBindings))
+	for _, binding := range envBindings {
+		bindings[binding.Field] = binding
+	}
+
+	var settings []Setting
+	defaults := DefaultWithPrefix(unsetPrefix)
+	walkSettings(reflect.TypeOf(*defaults), reflect.ValueOf(*defaults), "", func(key string, field reflect.StructField, value reflect.Value) {
+		setting := Setting{
+			Key:         key,
+			Type:        typeName(field.Type),
+			Default:     formatDefault(value),
+			Description: field.Tag.Get("desc"),
+		}
+		if binding, ok := bindings[key]; ok {
+			setting.Env = binding.Name
+			setting.Secret = binding.Secret
+			if setting.Description == "" {
+				setting.Description = binding.Description
+			}
+		}
+		settings = append(settings, setting)
+	})
+	return settings
+}
+
+// Reference formats for WriteReference.
+const (
+	ReferenceMarkdown = "markdown"
+	ReferenceJSON     = "json"
+)
+
+// WriteReference writes the Reference as a Markdown table or as JSON, for
+// documentation and tooling.
+func WriteReference(w io.Writer, format string) error {
+	settings := Reference()
+	switch format {
+	case ReferenceJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(settings)
+	case ReferenceMarkdown:
+		var b strings.Builder
+		b.WriteString("| Key | Type | Default | Environment | Description |\n")
+		b.WriteString("|-----|------|---------|-------------|-------------|\n")
+		for _, setting := range settings {
+			env := ""
+			if setting.Env != "" {
+				env = "`" + setting.Env + "`"
+				if setting.Secret {
+					env += " (secret)"
+				}
+			}
+			defaultValue := ""
+			if setting.Default != "" {
+				defaultValue = "`" + markdownCell(setting.Default) + "`"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n",
+				setting.Key, setting.Type, defaultValue, env, markdownCell(setting.Description))
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	default:
+		return fmt.Errorf("unknown reference format %q: expected %s or %s", format, ReferenceMarkdown, ReferenceJSON)
+	}
+}
+
+// unsetPrefix is an environment prefix no deployment uses, to read the
+// defaults unaffected by the environment.
+const unsetPrefix = "CHATBOT_REFERENCE_DEFAULTS"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// settingKey returns the key of a struct field in the format of tag
+// ("json" or "yaml"), "" for fields without one.
+func settingKey(field reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// nested returns the struct type holding the options below a field of type
+// t, and whether they are list items; ok is false for single options.
+func nested(t reflect.Type) (elem reflect.Type, list, ok bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct && t.Elem() != timeType {
+		return t.Elem(), true, true
+	}
+	if t.Kind() == reflect.Struct && t != timeType {
+		return t, false, true
+	}
+	return nil, false, false
+}
+
+// walkSettings calls visit with every option of the struct type t, below
+// prefix. value holds the defaults and is invalid for list items.
+func walkSettings(t reflect.Type, value reflect.Value, prefix string, visit func(key string, field reflect.StructField, value reflect.Value)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := settingKey(field, "json")
+		if key == "" || !field.IsExported() {
+			continue
+		}
+		key = prefix + key
+		var fieldValue reflect.Value
+		if value.IsValid() {
+			fieldValue = value.Field(i)
+		}
+		if elem, list, ok := nested(field.Type); ok {
+			if list {
+				walkSettings(elem, reflect.Value{}, key+"[].", visit)
+			} else {
+				walkSettings(elem, fieldValue, key+".", visit)
+			}
+			continue
+		}
+		visit(key, field, fieldValue)
+	}
+}
+
+// typeName names the type of an option for the Reference.
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t == timeType:
+		return "time"
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Interface:
+		return "any"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// formatDefault renders a default value, "" for unset pointers, times,
+// strings, lists and maps.
+func formatDefault(value reflect.Value) string {
+	if !value.IsValid() {
+		return ""
+	}
+	switch {
+	case value.Type() == durationType:
+		return time.Duration(value.Int()).String()
+	case value.Type() == timeType:
+		if value.Interface().(time.Time).IsZero() {
+			return ""
+		}
+		return value.Interface().(time.Time).Format(time.RFC3339)
+	}
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return ""
+		}
+		return formatDefault(value.Elem())
+	case reflect.Slice, reflect.Map:
+		if value.Len() == 0 {
+			return ""
+		}
+		encoded, _ := json.Marshal(value.Interface())
+		return string(encoded)
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
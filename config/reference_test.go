@@ -0,0 +1,53 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReference(t *testing.T) {
+	t.Setenv("CHATBOT_MODEL", "openai")
+
+	settings := make(map[string]Setting)
+	for _, setting := range Reference() {
+		assert.NotEmpty(t, setting.Description, setting.Key)
+		assert.NotEmpty(t, setting.Type, setting.Key)
+		settings[setting.Key] = setting
+	}
+
+	// Defaults are not taken from the environment
+	assert.Equal(t, Setting{Key: "model", Type: "string", Default: "free", Env: "CHATBOT_MODEL",
+		Description: "AI provider to use: openai, anthropic, gemini, xai, meta, ollama or free"}, settings["model"])
+	assert.True(t, settings["openai.api_key"].Secret)
+	assert.Equal(t, "duration", settings["timeout"].Type)
+	assert.Equal(t, "30s", settings["timeout"].Default)
+	assert.Equal(t, "float", settings["openai.generation.temperature"].Type)
+	assert.Equal(t, "string", settings["rate_limit.overrides[].tier"].Type)
+	assert.Equal(t, "time", settings["maintenance.until"].Type)
+	assert.Contains(t, settings["allowed_scripts"].Default, "Cyrillic")
+
+	for _, v := range EnvSpec() {
+		assert.Equal(t, v.Name, settings[v.Field].Env, "%s sets %s", v.Name, v.Field)
+	}
+}
+
+func TestWriteReference(t *testing.T) {
+	var markdown bytes.Buffer
+	require.NoError(t, WriteReference(&markdown, ReferenceMarkdown))
+	lines := strings.Split(strings.TrimSpace(markdown.String()), "\n")
+	assert.Len(t, lines, len(Reference())+2)
+	assert.Contains(t, markdown.String(), "| `openai.api_key` | string |  | `OPENAI_API_KEY` (secret) | OpenAI API key |")
+
+	var encoded bytes.Buffer
+	require.NoError(t, WriteReference(&encoded, ReferenceJSON))
+	var settings []Setting
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &settings))
+	assert.Equal(t, Reference(), settings)
+
+	assert.Error(t, WriteReference(&encoded, "html"))
+}
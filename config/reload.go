@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Change describes a single setting that differs between two configurations.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// reloadableFields lists the settings that can be swapped on a running
+// chatbot without rebuilding the model or dropping in-flight requests.
+// Provider credentials, endpoints and the model selection are deliberately
+// excluded: changing them requires constructing a new Chatbot.
+var reloadableFields = []struct {
+	name string
+	get  func(*Config) interface{}
+	set  func(dst, src *Config)
+}{
+	{"prompt", func(c *Config) interface{} { return c.Prompt }, func(d, s *Config) { d.Prompt = s.Prompt }},
+	{"language", func(c *Config) interface{} { return c.Language }, func(d, s *Config) { d.Language = s.Language }},
+	{"tone", func(c *Config) interface{} { return c.Tone }, func(d, s *Config) { d.Tone = s.Tone }},
+	{"temperature", func(c *Config) interface{} { return c.Temperature }, func(d, s *Config) { d.Temperature = s.Temperature }},
+	{"max_tokens", func(c *Config) interface{} { return c.MaxTokens }, func(d, s *Config) { d.MaxTokens = s.MaxTokens }},
+	{"emojis", func(c *Config) interface{} { return c.Emojis }, func(d, s *Config) { d.Emojis = s.Emojis }},
+	{"deescalate", func(c *Config) interface{} { return c.Deescalate }, func(d, s *Config) { d.Deescalate = s.Deescalate }},
+	{"funny", func(c *Config) interface{} { return c.Funny }, func(d, s *Config) { d.Funny = s.Funny }},
+	{"rate_limit", func(c *Config) interface{} { return c.RateLimit }, func(d, s *Config) { d.RateLimit = s.RateLimit }},
+	{"message_filtering", func(c *Config) interface{} { return c.MessageFiltering }, func(d, s *Config) { d.MessageFiltering = s.MessageFiltering }},
+	{"maintenance", func(c *Config) interface{} { return c.Maintenance }, func(d, s *Config) { d.Maintenance = s.Maintenance }},
+}
+
+// Diff returns the reloadable settings whose values differ between old and next.
+func Diff(old, next *Config) []Change {
+	var changes []Change
+	for _, f := range reloadableFields {
+		oldValue, newValue := f.get(old), f.get(next)
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, Change{Field: f.name, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+// MergeReloadable returns a copy of c with every reloadable setting taken from
+// next. Settings that require a restart keep their current values.
+func (c *Config) MergeReloadable(next *Config) *Config {
+	merged := *c
+	for _, f := range reloadableFields {
+		f.set(&merged, next)
+	}
+	return &merged
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("yaml", func(t *testing.T) {
+		path := filepath.Join(dir, "chatbot.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("prompt: From YAML\ntemperature: 0.2\ntimeout: 10s\n"), 0o600))
+
+		cfg, err := LoadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "From YAML", cfg.Prompt)
+		assert.Equal(t, 0.2, cfg.Temperature)
+		assert.Equal(t, 10*time.Second, cfg.Timeout)
+		assert.Equal(t, "free", cfg.Model, "unset fields keep their defaults")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(dir, "chatbot.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"prompt":"From JSON","max_tokens":42}`), 0o600))
+
+		cfg, err := LoadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "From JSON", cfg.Prompt)
+		assert.Equal(t, 42, cfg.MaxTokens)
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		path := filepath.Join(dir, "chatbot.toml")
+		require.NoError(t, os.WriteFile(path, []byte(`prompt = "x"`), 0o600))
+
+		_, err := LoadFile(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadFile(filepath.Join(dir, "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	next := Default()
+	next.Prompt = "New prompt"
+	next.Temperature = 1.1
+	next.OpenAI.APIKey = "ignored"
+	next.MessageFiltering.AggressionPatterns = []string{"rude"}
+
+	changes := Diff(old, next)
+
+	fields := make(map[string]Change)
+	for _, change := range changes {
+		fields[change.Field] = change
+	}
+	assert.Len(t, changes, 3)
+	assert.Equal(t, "New prompt", fields["prompt"].New)
+	assert.Equal(t, 0.7, fields["temperature"].Old)
+	assert.Contains(t, fields, "message_filtering")
+
+	assert.Empty(t, Diff(old, Default()))
+}
+
+func TestMergeReloadable(t *testing.T) {
+	current := Default()
+	current.Model = "openai"
+	current.OpenAI.APIKey = "current-key"
+
+	next := Default()
+	next.Model = "anthropic"
+	next.OpenAI.APIKey = "next-key"
+	next.Tone = "formal"
+	next.RateLimit.RequestsPerMinute = 99
+
+	merged := current.MergeReloadable(next)
+
+	assert.Equal(t, "openai", merged.Model)
+	assert.Equal(t, "current-key", merged.OpenAI.APIKey)
+	assert.Equal(t, "formal", merged.Tone)
+	assert.Equal(t, 99, merged.RateLimit.RequestsPerMinute)
+	assert.Equal(t, "neutral", current.Tone, "original config must not be modified")
+}
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chatbot.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("prompt: first\n"), 0o600))
+
+	reloaded := make(chan *Config, 1)
+	watcher := NewWatcher(path, 10*time.Millisecond, func(cfg *Config) {
+		reloaded <- cfg
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	// Give the watcher time to record the initial file state
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("prompt: second version\n"), 0o600))
+
+	select {
+	case cfg := <-reloaded:
+		assert.Equal(t, "second version", cfg.Prompt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not reload the changed file")
+	}
+}
+
+func TestWatcherReportsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chatbot.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("prompt: [unterminated\n"), 0o600))
+
+	var reported error
+	watcher := NewWatcher(path, time.Second, func(*Config) {
+		t.Error("reload callback must not run for an invalid file")
+	}).OnError(func(err error) {
+		reported = err
+	})
+
+	watcher.reload()
+	assert.Error(t, reported)
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// SecretPrefix marks a configuration value as a reference to a secret that
+// must be resolved through a SecretProvider, e.g. "secret:chatbot/openai#api_key".
+const SecretPrefix = "secret:"
+
+// ErrSecretNotFound is returned when a secret or one of its keys does not exist.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider fetches secrets from an external secret manager.
+type SecretProvider interface {
+	// GetSecret returns the value of the named secret. A name may end with
+	// "#key" to select a single field of a secret that stores a JSON object.
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// ResolveSecrets replaces every API key, including the analytics keys, that
+// starts with SecretPrefix with the value returned by the provider, so
+// credentials never need to be stored in plain environment variables or
+// configuration files.
+func (c *Config) ResolveSecrets(ctx context.Context, provider SecretProvider) error {
+	fields := map[string]*string{
+		"openai.api_key":    &c.OpenAI.APIKey,
+		"anthropic.api_key": &c.Anthropic.APIKey,
+		"gemini.api_key":    &c.Gemini.APIKey,
+		"xai.api_key":       &c.XAI.APIKey,
+		"meta.api_key":      &c.Meta.APIKey,
+
+		"analytics.segment.write_key": &c.Analytics.Segment.WriteKey,
+		"analytics.posthog.api_key":   &c.Analytics.PostHog.APIKey,
+	}
+
+	for path, value := range fields {
+		if !strings.HasPrefix(*value, SecretPrefix) {
+			continue
+		}
+		if provider == nil {
+			return fmt.Errorf("%s references a secret but no secret provider is configured", path)
+		}
+
+		secret, err := provider.GetSecret(ctx, strings.TrimPrefix(*value, SecretPrefix))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		*value = secret
+	}
+
+	return nil
+}
+
+// splitSecretName separates a secret name from an optional "#key" selector.
+func splitSecretName(name string) (string, string) {
+	if idx := strings.LastIndex(name, "#"); idx != -1 {
+		return name[:idx], name[idx+1:]
+	}
+	return name, ""
+}
+
+// selectSecretKey extracts key from a JSON object secret, or returns the
+// whole value when no key is requested.
+func selectSecretKey(value, key string) (string, error) {
+	if key == "" {
+		return value, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: key %q", ErrSecretNotFound, key)
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(field), nil
+}
+
+// VaultSecretProvider reads secrets from a HashiCorp Vault KV version 2 engine.
+type VaultSecretProvider struct {
+	Address    string
+	Token      string
+	Mount      string
+	httpClient *http.Client
+}
+
+// NewVaultSecretProvider creates a Vault provider. Empty address and token
+// fall back to the standard VAULT_ADDR and VAULT_TOKEN (or VAULT_TOKEN_FILE)
+// variables; the KV mount defaults to "secret".
+func NewVaultSecretProvider(address, token, mount string) *VaultSecretProvider {
+	if address == "" {
+		address = getEnv("VAULT_ADDR", "http://127.0.0.1:8200")
+	}
+	if token == "" {
+		token = getEnv("VAULT_TOKEN", "")
+	}
+	if mount == "" {
+		mount = "secret"
+	}
+
+	return &VaultSecretProvider{
+		Address: strings.TrimRight(address, "/"),
+		Token:   token,
+		Mount:   strings.Trim(mount, "/"),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// GetSecret reads "path#key" from the KV engine. Without a key, the secret
+// must contain exactly one field.
+func (v *VaultSecretProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	path, key := splitSecretName(name)
+	url := fmt.Sprintf("%s/v1/%s/data/%s", v.Address, v.Mount, strings.TrimLeft(path, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var vaultResp struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	fields := vaultResp.Data.Data
+	if key == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("secret %s has %d fields, select one with #key", path, len(fields))
+		}
+		for _, value := range fields {
+			return fmt.Sprint(value), nil
+		}
+	}
+
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: key %q in %s", ErrSecretNotFound, key, path)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager using
+// SigV4-signed requests, without depending on the AWS SDK.
+type AWSSecretsManagerProvider struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Endpoint        string
+	httpClient      *http.Client
+	now             func() time.Time
+}
+
+// NewAWSSecretsManagerProvider creates a Secrets Manager provider for region.
+// Credentials are read from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables (or their _FILE
+// variants); an empty region falls back to AWS_REGION.
+func NewAWSSecretsManagerProvider(region string) *AWSSecretsManagerProvider {
+	if region == "" {
+		region = getEnv("AWS_REGION", "us-east-1")
+	}
+
+	return &AWSSecretsManagerProvider{
+		Region:          region,
+		AccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
+		SecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		SessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+		Endpoint:        fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		now: time.Now,
+	}
+}
+
+// GetSecret fetches the SecretString of "secret-id#key".
+func (a *AWSSecretsManagerProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	secretID, key := splitSecretName(name)
+
+	payload, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	a.sign(req, payload)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if strings.Contains(string(body), "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: %s", ErrSecretNotFound, secretID)
+		}
+		return "", fmt.Errorf("secrets manager request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var awsResp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &awsResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return selectSecretKey(awsResp.SecretString, key)
+}
+
+// sign adds AWS Signature Version 4 headers to the request.
+func (a *AWSSecretsManagerProvider) sign(req *http.Request, payload []byte) {
+	now := a.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.SessionToken)
+	}
+
+	payloadHash := sha256Hex(payload)
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-date:%s\nx-amz-target:%s\n",
+		req.Header.Get("Content-Type"), req.URL.Host, amzDate, req.Header.Get("X-Amz-Target"))
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/secretsmanager/aws4_request", date, a.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.SecretAccessKey), date)
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// readSecretFile returns the trimmed contents of the file named by the
+// KEY_FILE variable, following the Docker and Kubernetes secrets convention.
+func readSecretFile(key string) (string, bool) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", false
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultReadsFileVariants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openai_key")
+	require.NoError(t, os.WriteFile(path, []byte("file-key\n"), 0o600))
+
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY_FILE", path)
+	assert.Equal(t, "file-key", Default().OpenAI.APIKey)
+
+	t.Setenv("OPENAI_API_KEY", "env-key")
+	assert.Equal(t, "env-key", Default().OpenAI.APIKey, "plain variable takes precedence")
+}
+
+type staticSecrets map[string]string
+
+func (s staticSecrets) GetSecret(_ context.Context, name string) (string, error) {
+	value, ok := s[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.OpenAI.APIKey = "secret:chatbot/openai#api_key"
+	cfg.Anthropic.APIKey = "plain-key"
+
+	err := cfg.ResolveSecrets(context.Background(), staticSecrets{"chatbot/openai#api_key": "resolved"})
+	require.NoError(t, err)
+	assert.Equal(t, "resolved", cfg.OpenAI.APIKey)
+	assert.Equal(t, "plain-key", cfg.Anthropic.APIKey)
+
+	cfg.Gemini.APIKey = "secret:missing"
+	err = cfg.ResolveSecrets(context.Background(), staticSecrets{})
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+
+	cfg.Gemini.APIKey = "secret:missing"
+	assert.Error(t, cfg.ResolveSecrets(context.Background(), nil))
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-token", r.Header.Get("X-Vault-Token"))
+		switch r.URL.Path {
+		case "/v1/kv/data/chatbot":
+			w.Write([]byte(`{"data":{"data":{"openai":"sk-vault","anthropic":"sk-ant"}}}`))
+		case "/v1/kv/data/single":
+			w.Write([]byte(`{"data":{"data":{"value":"only"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewVaultSecretProvider(server.URL, "test-token", "kv")
+	ctx := context.Background()
+
+	value, err := provider.GetSecret(ctx, "chatbot#openai")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-vault", value)
+
+	value, err = provider.GetSecret(ctx, "single")
+	require.NoError(t, err)
+	assert.Equal(t, "only", value)
+
+	_, err = provider.GetSecret(ctx, "chatbot")
+	assert.Error(t, err, "multi-field secret requires a key")
+
+	_, err = provider.GetSecret(ctx, "chatbot#missing")
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+
+	_, err = provider.GetSecret(ctx, "absent")
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+}
+
+func TestAWSSecretsManagerProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240101/eu-west-1/secretsmanager/aws4_request"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+
+		var body strings.Builder
+		buf := make([]byte, 512)
+		n, _ := r.Body.Read(buf)
+		body.Write(buf[:n])
+
+		if strings.Contains(body.String(), "prod/chatbot") {
+			w.Write([]byte(`{"SecretString":"{\"openai\":\"sk-aws\"}"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+	}))
+	defer server.Close()
+
+	provider := NewAWSSecretsManagerProvider("eu-west-1")
+	provider.Endpoint = server.URL
+	provider.AccessKeyID = "AKIDEXAMPLE"
+	provider.SecretAccessKey = "secret"
+	provider.SessionToken = "session"
+	provider.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	value, err := provider.GetSecret(context.Background(), "prod/chatbot#openai")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-aws", value)
+
+	raw, err := provider.GetSecret(context.Background(), "prod/chatbot")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"openai":"sk-aws"}`, raw)
+
+	_, err = provider.GetSecret(context.Background(), "other")
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+}
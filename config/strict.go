@@ -0,0 +1,241 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultStrict is DefaultWithPrefix in strict mode: it fails if the
+// environment has variables that look like settings but are not, such as
+// OPENAI_APIKEY, or that have invalid values, which DefaultWithPrefix
+// ignores. See CheckEnv.
+func DefaultStrict(prefix string) (*Config, error) {
+	if err := CheckEnv(prefix, os.Environ()); err != nil {
+		return nil, err
+	}
+	return DefaultWithPrefix(prefix), nil
+}
+
+// LoadFileStrict is LoadFileWithProfile in strict mode: it also fails on
+// keys of the file, or of its profiles, that are not settings, and on the
+// environment variables DefaultStrict fails on. Every problem is reported
+// at once as a *ValidationError.
+func LoadFileStrict(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	verr := &ValidationError{}
+	checkEnv(verr, "", os.Environ())
+	if err := checkFile(verr, path, data); err != nil {
+		return nil, err
+	}
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return LoadFileWithProfile(path, profile)
+}
+
+// CheckEnv reports the variables of environ, in os.Environ format, that are
+// not in EnvSpec or have values that do not parse. With a prefix every
+// variable starting with it is checked. Without one, the environment is
+// shared with other programs, so only variables starting with CHATBOT_ or
+// within a typo of a known variable are reported, such as OPENAI_APIKEY
+// but not OPENAI_BASE_URL. The NAME_FILE variants are accepted. It
+// returns a *ValidationError whose fields are variable names, with
+// ErrUnknownEnvVar or ErrInvalidEnvValue.
+func CheckEnv(prefix string, environ []string) error {
+	verr := &ValidationError{}
+	checkEnv(verr, prefix, environ)
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// CheckFile reports the keys of a JSON or YAML configuration file, and of
+// its profiles, that are not settings. It returns a *ValidationError with
+// ErrUnknownKey for each, suggesting the setting that was probably meant.
+func CheckFile(path string) error {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	verr := &ValidationError{}
+	if err := checkFile(verr, path, data); err != nil {
+		return err
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// checkEnv adds the unknown and invalid variables of environ to verr.
+func checkEnv(verr *ValidationError, prefix string, environ []string) {
+	prefix = normalizePrefix(prefix)
+	known := make(map[string]envBinding, 2*len(envBindings))
+	names := make([]string, 0, len(envBindings))
+	for _, binding := range envBindings {
+		known[prefix+binding.Name] = binding
+		names = append(names, prefix+binding.Name)
+	}
+
+	environ = append([]string(nil), environ...)
+	sort.Strings(environ)
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		if prefix != "" && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		binding, ok := known[name]
+		if !ok {
+			if _, ok := known[strings.TrimSuffix(name, "_FILE")]; ok {
+				continue
+			}
+			// Without a prefix, variables of other programs share the
+			// environment, such as the SDKs' OPENAI_BASE_URL
+			suggestion := suggest(name, names)
+			if prefix == "" && suggestion == "" && !strings.HasPrefix(name, "CHATBOT_") {
+				continue
+			}
+			verr.add(name, nil, suggestion, ErrUnknownEnvVar)
+			continue
+		}
+		if value != "" && !binding.apply(&Config{}, value) {
+			var shown interface{} = value
+			if binding.Secret {
+				shown = nil
+			}
+			verr.add(name, shown, binding.expected, ErrInvalidEnvValue)
+		}
+	}
+}
+
+// checkFile adds the unknown keys of a configuration file to verr. It fails
+// if the file does not parse.
+func checkFile(verr *ValidationError, path string, data []byte) error {
+	values := make(map[string]interface{})
+	tag := "yaml"
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		tag = "json"
+		if err := json.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("failed to parse JSON config: %w", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension: %q", filepath.Ext(path))
+	}
+
+	profiles, _ := values["profiles"].(map[string]interface{})
+	delete(values, "profiles")
+	configType := reflect.TypeOf(Config{})
+	checkKeys(verr, values, configType, "", tag)
+	for _, name := range sortedKeys(profiles) {
+		if profile, ok := profiles[name].(map[string]interface{}); ok {
+			delete(profile, "extends")
+			checkKeys(verr, profile, configType, "profiles."+name+".", tag)
+		}
+	}
+	return nil
+}
+
+// checkKeys adds the keys of values that are not settings of the struct
+// type t to verr, looking into nested settings and list items.
+func checkKeys(verr *ValidationError, values map[string]interface{}, t reflect.Type, prefix, tag string) {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := settingKey(t.Field(i), tag); key != "" && t.Field(i).IsExported() {
+			fields[key] = t.Field(i)
+			names = append(names, key)
+		}
+	}
+
+	for _, key := range sortedKeys(values) {
+		field, ok := fields[key]
+		if !ok {
+			expected := ""
+			if suggestion := suggest(key, names); suggestion != "" {
+				expected = prefix + suggestion
+			}
+			verr.add(prefix+key, nil, expected, ErrUnknownKey)
+			continue
+		}
+		elem, list, ok := nested(field.Type)
+		if !ok {
+			continue
+		}
+		if !list {
+			if nestedValues, ok := values[key].(map[string]interface{}); ok {
+				checkKeys(verr, nestedValues, elem, prefix+key+".", tag)
+			}
+			continue
+		}
+		items, _ := values[key].([]interface{})
+		for i, item := range items {
+			if itemValues, ok := item.(map[string]interface{}); ok {
+				checkKeys(verr, itemValues, elem, fmt.Sprintf("%s%s[%d].", prefix, key, i), tag)
+			}
+		}
+	}
+}
+
+// suggest returns the candidate closest to name, ignoring case and
+// separators, or "" if none is within two edits.
+func suggest(name string, candidates []string) string {
+	normalize := strings.NewReplacer("_", "", "-", "", ".", "")
+	target := strings.ToLower(normalize.Replace(name))
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		distance := editDistance(target, strings.ToLower(normalize.Replace(candidate)))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckEnv(t *testing.T) {
+	err := CheckEnv("", []string{
+		"OPENAI_APIKEY=sk-typo",
+		"OPENAI_API_KEY_FILE=/run/secrets/openai",
+		"CHATBOT_TIMEOUT=30",
+		"CHATBOT_MODEL=openai",
+		"RATE_LIMIT_REQUESTS=ten",
+		"PATH=/usr/bin",
+		"OPENAI_BASE_URL=https://proxy.example.com/v1",
+		"VAULT_ADDR=http://127.0.0.1:8200",
+	})
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "got %v", err)
+	require.Len(t, verr.Errors, 3)
+
+	assert.Equal(t, "CHATBOT_TIMEOUT", verr.Errors[0].Field)
+	assert.ErrorIs(t, verr.Errors[0], ErrInvalidEnvValue)
+	assert.Equal(t, "a duration such as 30s", verr.Errors[0].Expected)
+	assert.Equal(t, "OPENAI_APIKEY", verr.Errors[1].Field)
+	assert.ErrorIs(t, verr.Errors[1], ErrUnknownEnvVar)
+	assert.Equal(t, "OPENAI_API_KEY", verr.Errors[1].Expected)
+	assert.Equal(t, "RATE_LIMIT_REQUESTS", verr.Errors[2].Field)
+	assert.EqualError(t, verr.Errors[2], "RATE_LIMIT_REQUESTS: invalid environment variable value (got ten, expected an integer)")
+
+	// Secrets are not shown, and with a prefix every prefixed variable counts
+	err = CheckEnv("myapp", []string{"MYAPP_OPENAI_API_KEY=sk-ok", "MYAPP_DEBUG=1", "OPENAI_APIKEY=ignored"})
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 1)
+	assert.Equal(t, "MYAPP_DEBUG", verr.Errors[0].Field)
+	assert.Empty(t, verr.Errors[0].Expected)
+
+	assert.NoError(t, CheckEnv("", []string{"CHATBOT_MODEL=free", "HOME=/root"}))
+}
+
+func TestCheckFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "chatbot.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+modle: openai
+openai:
+  apikey: sk-test
+  generation:
+    max_tokens: 100
+rate_limit:
+  overrides:
+    - tier: premium
+      requests_per_mintue: 100
+providers:
+  custom:
+    anything: goes
+profiles:
+  prod:
+    extends: base
+    temprature: 0.2
+`), 0o600))
+
+	err := CheckFile(yamlPath)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "got %v", err)
+	var fields, expected []string
+	for _, fieldErr := range verr.Errors {
+		assert.ErrorIs(t, fieldErr, ErrUnknownKey)
+		fields = append(fields, fieldErr.Field)
+		expected = append(expected, fieldErr.Expected)
+	}
+	assert.Equal(t, []string{"modle", "openai.apikey", "rate_limit.overrides[0].requests_per_mintue", "profiles.prod.temprature"}, fields)
+	assert.Equal(t, []string{"model", "openai.api_key", "rate_limit.overrides[0].requests_per_minute", "profiles.prod.temperature"}, expected)
+
+	jsonPath := filepath.Join(dir, "chatbot.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"model": "free", "maintenance": {"enabled": true}}`), 0o600))
+	assert.NoError(t, CheckFile(jsonPath))
+}
+
+func TestLoadFileStrict(t *testing.T) {
+	path := writeProfiles(t)
+	cfg, err := LoadFileStrict(path, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", cfg.OpenAI.Model)
+
+	t.Setenv("CHATBOT_TEMPRATURE", "0.2")
+	_, err = LoadFileStrict(path, "prod")
+	assert.ErrorIs(t, err, ErrUnknownEnvVar)
+	assert.ErrorContains(t, err, "CHATBOT_TEMPRATURE: unknown environment variable (expected CHATBOT_TEMPERATURE)")
+
+	_, err = DefaultStrict("")
+	assert.ErrorIs(t, err, ErrUnknownEnvVar)
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Watcher reloads a configuration file when it changes on disk or when the
+// process receives SIGHUP, and hands the freshly loaded configuration to a
+// callback.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onReload func(*Config)
+	onError  func(error)
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a watcher for the given file. The file is polled every
+// interval (defaulting to five seconds) and onReload is called with the new
+// configuration whenever its modification time or size changes.
+func NewWatcher(path string, interval time.Duration, onReload func(*Config)) *Watcher {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		onReload: onReload,
+	}
+}
+
+// OnError sets a callback invoked when the file cannot be read or parsed.
+// The previous configuration stays in effect in that case.
+func (w *Watcher) OnError(fn func(error)) *Watcher {
+	w.onError = fn
+	return w
+}
+
+// Start watches the file until the context is cancelled.
+func (w *Watcher) Start(ctx context.Context) {
+	// Record the current file state so the first tick doesn't trigger a reload
+	if info, err := os.Stat(w.path); err == nil {
+		w.mutex.Lock()
+		w.modTime, w.size = info.ModTime(), info.Size()
+		w.mutex.Unlock()
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			w.reload()
+		case <-ticker.C:
+			if w.changed() {
+				w.reload()
+			}
+		}
+	}
+}
+
+// Reload forces the file to be read and delivered to the callback.
+func (w *Watcher) Reload() error {
+	cfg, err := LoadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if w.onReload != nil {
+		w.onReload(cfg)
+	}
+	return nil
+}
+
+// changed reports whether the file differs from the last observed state.
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		w.reportError(fmt.Errorf("failed to stat config file: %w", err))
+		return false
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	return true
+}
+
+// reload loads the file and reports any failure to the error callback.
+func (w *Watcher) reload() {
+	if err := w.Reload(); err != nil {
+		w.reportError(err)
+	}
+}
+
+// reportError forwards err to the error callback, if one is set.
+func (w *Watcher) reportError(err error) {
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
@@ -0,0 +1,353 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/streaming"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// defaultHistoryLength is how many stored messages are sent as history
+// when WithConversations is given no length.
+const defaultHistoryLength = 20
+
+// Conversations stores the messages of conversations, so clients of the
+// chat endpoints can chat statefully by sending back the conversation_id
+// they were given. database.ConversationManager implements it.
+type Conversations interface {
+	// StartConversation creates a conversation for the user, who may be
+	// unknown, and returns its ID.
+	StartConversation(ctx context.Context, userID string) (string, error)
+	// RecentMessages returns the last limit messages of the conversation,
+	// oldest first, as "role" and "content" maps. An unknown conversation
+	// has none.
+	RecentMessages(ctx context.Context, conversationID string, limit int) ([]map[string]interface{}, error)
+	// AppendExchange stores the user's message and the reply, creating the
+	// conversation if it does not exist yet, and returns the ID of the
+	// stored reply.
+	AppendExchange(ctx context.Context, conversationID, userID, message, reply string) (string, error)
+}
+
+// OwnedConversations is implemented by conversation stores that know which
+// user started each conversation, like database.ConversationManager.
+// Requests continuing a conversation with WithConversationID, as the chat
+// endpoints do, are checked with it before its history is loaded or an
+// exchange is stored.
+type OwnedConversations interface {
+	// CheckOwner returns an error of kind chaterrors.ErrNotFound if the
+	// conversation does not exist, and of kind chaterrors.ErrForbidden if
+	// it was started by a user other than userID. Conversations started
+	// without a user may be continued by anyone.
+	CheckOwner(ctx context.Context, conversationID, userID string) error
+}
+
+// LockingConversations is implemented by conversation stores that serialize
+// turns on a conversation, like database.ConversationManager with a locker
+// from database.WithLocker. Requests hold the lock of their conversation
+// from loading its history until the exchange is stored, so concurrent
+// turns are stored in order instead of interleaving.
+type LockingConversations interface {
+	// Lock blocks until the conversation's lock is held or ctx is done, and
+	// returns the function releasing it.
+	Lock(ctx context.Context, conversationID string) (func(), error)
+}
+
+// MetadataConversations is implemented by conversation stores that keep
+// metadata with each message, like database.ConversationManager. Exchanges
+// with metadata, such as the original and translated text of translated
+// messages or the knowledge chunks retrieved for a reply, are stored with
+// it when the store supports it.
+type MetadataConversations interface {
+	// AppendExchangeWithMetadata is AppendExchange storing metadata, which
+	// may be nil, with the message and the reply.
+	AppendExchangeWithMetadata(ctx context.Context, conversationID, userID, message, reply string, messageMetadata, replyMetadata map[string]interface{}) (string, error)
+}
+
+// StateConversations is implemented by conversation stores that keep the
+// state tools set in a conversation, like database.ConversationManager,
+// which stores it in the conversation's metadata.
+type StateConversations interface {
+	// LoadState returns the conversation's state, nil if it has none.
+	LoadState(ctx context.Context, conversationID string) (map[string]json.RawMessage, error)
+	// SaveState replaces the conversation's state.
+	SaveState(ctx context.Context, conversationID string, state map[string]json.RawMessage) error
+}
+
+// defaultStateLimit is the size limit of a conversation's state when
+// WithStateLimit is not given.
+const defaultStateLimit = 16 << 10
+
+// WithStateLimit sets how many bytes of JSON the state tools keep in a
+// conversation may take (default 16 KiB).
+func WithStateLimit(maxBytes int) Option {
+	return func(c *Chatbot) {
+		c.stateLimit = maxBytes
+	}
+}
+
+// loadState returns a context carrying the state of the caller's
+// conversation, for tools to read and write, and a function that saves it
+// if it changed. Without a conversation or a store keeping state, the
+// context is returned as is.
+func (c *Chatbot) loadState(ctx context.Context) (context.Context, func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	store, ok := c.conversations.(StateConversations)
+	caller, _ := tools.CallerFromContext(ctx)
+	if !ok || caller.ConversationID == "" {
+		return ctx, noop, nil
+	}
+	if _, ok := tools.StateFromContext(ctx); ok {
+		return ctx, noop, nil
+	}
+
+	values, err := store.LoadState(ctx, caller.ConversationID)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("failed to load conversation state: %w", err)
+	}
+	limit := c.stateLimit
+	if limit <= 0 {
+		limit = defaultStateLimit
+	}
+	state := tools.NewState(values, limit)
+	save := func(ctx context.Context) error {
+		if !state.Changed() {
+			return nil
+		}
+		if err := store.SaveState(ctx, caller.ConversationID, state.Values()); err != nil {
+			return fmt.Errorf("failed to save conversation state: %w", err)
+		}
+		return nil
+	}
+	return tools.WithState(ctx, state), save, nil
+}
+
+// WithConversations keeps the history of every request with a
+// "conversation_id": the last historyLength messages are sent to the model
+// as "history", and the message and reply are stored afterwards.
+// AskResponse, AskStream, HandleHTTP and the adapters start a conversation
+// when the request has no ID. A historyLength of 0 sends the last 20 messages.
+func WithConversations(conversations Conversations, historyLength int) Option {
+	return func(c *Chatbot) {
+		if historyLength <= 0 {
+			historyLength = defaultHistoryLength
+		}
+		c.conversations = conversations
+		c.historyLength = historyLength
+	}
+}
+
+// WithConversationID continues the conversation with the given ID, as sent
+// back by a client. Unlike a "conversation_id" passed with WithContext,
+// which trusted callers such as channels use to name conversations and
+// which is created if it does not exist, the conversation must exist and
+// belong to the request's user when the WithConversations store is an
+// OwnedConversations. An empty ID is ignored.
+func WithConversationID(id string) AskOption {
+	return func(opts *askOptions) {
+		if id == "" {
+			return
+		}
+		opts.context["conversation_id"] = id
+		opts.checkOwner = true
+	}
+}
+
+// checkConversation makes sure the conversation continued with
+// WithConversationID exists and belongs to the request's user. The store
+// failing to tell is an error unless stateless fallback is enabled.
+func (c *Chatbot) checkConversation(ctx context.Context, opts *askOptions) error {
+	store, ok := c.conversations.(OwnedConversations)
+	conversationID, _ := opts.context["conversation_id"].(string)
+	if !ok || !opts.checkOwner || conversationID == "" {
+		return nil
+	}
+
+	userID, _ := opts.context["user_id"].(string)
+	err := store.CheckOwner(ctx, conversationID, userID)
+	switch {
+	case err == nil:
+		return nil
+	case chaterrors.KindOf(err) != nil:
+		return err
+	case !c.stateless:
+		return fmt.Errorf("failed to check conversation: %w", err)
+	default:
+		c.storeFailed(ctx, "check conversation", err, opts.context)
+		return nil
+	}
+}
+
+// startConversation returns the conversation ID of the request, starting a
+// conversation if conversations are kept and the request has none.
+func (c *Chatbot) startConversation(ctx context.Context, askContext map[string]interface{}) (string, error) {
+	conversationID, _ := askContext["conversation_id"].(string)
+	if conversationID != "" || c.conversations == nil {
+		return conversationID, nil
+	}
+
+	identify(ctx, askContext)
+	userID, _ := askContext["user_id"].(string)
+	conversationID, err := c.conversations.StartConversation(ctx, userID)
+	if err != nil {
+		return c.fallbackConversation(ctx, fmt.Errorf("failed to start conversation: %w", err), askContext)
+	}
+	return conversationID, nil
+}
+
+// withNewConversation makes Ask start a conversation for a request without
+// a "conversation_id", as AskStream does.
+func withNewConversation() AskOption {
+	return func(opts *askOptions) {
+		opts.newConversation = true
+	}
+}
+
+// openConversation starts a conversation for a request without one and sets
+// its ID in the request context and in the metadata of ctx. Call it only
+// once the request passed rate limiting and filtering, so rejected requests
+// store nothing.
+func (c *Chatbot) openConversation(ctx context.Context, askContext map[string]interface{}) (string, error) {
+	conversationID, err := c.startConversation(ctx, askContext)
+	if err != nil {
+		return "", err
+	}
+	if conversationID != "" {
+		askContext["conversation_id"] = conversationID
+	}
+	if meta, ok := streaming.MetadataFromContext(ctx); ok {
+		meta.ConversationID = conversationID
+	}
+	return conversationID, nil
+}
+
+// lockConversation takes the lock of the request's conversation when the
+// store is a LockingConversations. The returned function releases it and may
+// be called more than once. The store failing to lock is an error unless
+// stateless fallback is enabled.
+func (c *Chatbot) lockConversation(ctx context.Context, askContext map[string]interface{}) (func(), error) {
+	store, ok := c.conversations.(LockingConversations)
+	conversationID, _ := askContext["conversation_id"].(string)
+	if !ok || conversationID == "" {
+		return func() {}, nil
+	}
+
+	unlock, err := store.Lock(ctx, conversationID)
+	switch {
+	case err == nil:
+		var once sync.Once
+		return func() { once.Do(unlock) }, nil
+	case !c.stateless || ctx.Err() != nil:
+		return nil, err
+	default:
+		c.storeFailed(ctx, "lock conversation", err, askContext)
+		return func() {}, nil
+	}
+}
+
+// loadHistory sets the request's "history" to the stored messages of its
+// conversation, unless the caller passed a history.
+func (c *Chatbot) loadHistory(ctx context.Context, askContext map[string]interface{}) error {
+	conversationID, _ := askContext["conversation_id"].(string)
+	if c.conversations == nil || conversationID == "" {
+		return nil
+	}
+	if _, ok := askContext["history"]; ok {
+		return nil
+	}
+
+	history, err := c.conversations.RecentMessages(ctx, conversationID, c.historyLength)
+	if err != nil {
+		if !c.stateless {
+			return fmt.Errorf("failed to load conversation history: %w", err)
+		}
+		c.storeFailed(ctx, "load conversation history", err, askContext)
+	}
+	if queued := c.queuedHistory(conversationID); len(queued) > 0 {
+		history = append(history, queued...)
+		history = history[max(len(history)-c.historyLength, 0):]
+	}
+	if len(history) > 0 {
+		askContext["history"] = history
+	}
+	return nil
+}
+
+// saveExchange stores the message and reply in the request's conversation.
+// The reply has been generated by then, so a failure is logged rather than
+// returned, and queued for a retry with WithStatelessFallback.
+func (c *Chatbot) saveExchange(ctx context.Context, message, reply string, askContext map[string]interface{}) {
+	conversationID, _ := askContext["conversation_id"].(string)
+	if c.conversations == nil || conversationID == "" {
+		return
+	}
+	exchange := Exchange{ConversationID: conversationID, Message: message, Reply: reply, Events: eventOutboxFrom(ctx).take()}
+	exchange.UserID, _ = askContext["user_id"].(string)
+	exchange.MessageMetadata, exchange.ReplyMetadata = translationMetadata(askContext)
+	if chunks := retrievalMetadata(askContext); chunks != nil {
+		if exchange.ReplyMetadata == nil {
+			exchange.ReplyMetadata = make(map[string]interface{}, 1)
+		}
+		exchange.ReplyMetadata["retrieved_chunks"] = chunks
+	}
+
+	// Queue behind the exchanges waiting for a retry, keeping their order
+	if c.retries != nil && c.retries.Len() > 0 && c.retries.Add(exchange) {
+		return
+	}
+	messageID, err := appendExchange(context.WithoutCancel(ctx), c.conversations, exchange)
+	switch {
+	case err == nil:
+		if meta, ok := streaming.MetadataFromContext(ctx); ok {
+			meta.MessageID = messageID
+		}
+	case !c.stateless:
+		c.logf("conversations: failed to save exchange: %v", err)
+		c.sendEvents(ctx, exchange.Events)
+	case c.retries != nil && c.retries.Add(exchange):
+		c.storeFailed(ctx, "save exchange", fmt.Errorf("%w; queued for a retry", err), askContext)
+	default:
+		c.storeFailed(ctx, "save exchange", fmt.Errorf("%w; the exchange is lost", err), askContext)
+		c.sendEvents(ctx, exchange.Events)
+	}
+}
+
+// saveStreamed forwards streamed chunks and stores the reply, cut at the
+// stream limits, in the request's conversation once the stream ends. The
+// exchange is saved before the done chunk is sent, so the client's next
+// message sees it, and unlock is called once it is. Failed streams and
+// streams whose request ended are not stored.
+func (c *Chatbot) saveStreamed(ctx, streamCtx context.Context, prompt string, in <-chan string, limits streaming.Limits, meta *streaming.Metadata, askContext map[string]interface{}, unlock func()) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer unlock()
+		defer c.recoverBackground()
+
+		var reply strings.Builder
+		forward := true
+		for chunk := range in {
+			reply.WriteString(chunk)
+			if !forward {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-streamCtx.Done():
+				// The limits may have ended the stream, so keep collecting
+				// what the model still sends
+				forward = false
+			}
+		}
+		if ctx.Err() != nil || meta.Err != nil {
+			return
+		}
+		text, _ := limits.Apply(reply.String())
+		c.saveExchange(ctx, prompt, text, askContext)
+	}()
+	return out
+}
@@ -0,0 +1,341 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+// fakeConversations keeps conversations in memory.
+type fakeConversations struct {
+	mutex    sync.Mutex
+	started  int
+	users    map[string]string
+	messages map[string][]map[string]interface{}
+}
+
+func newFakeConversations() *fakeConversations {
+	return &fakeConversations{
+		users:    make(map[string]string),
+		messages: make(map[string][]map[string]interface{}),
+	}
+}
+
+func (f *fakeConversations) StartConversation(ctx context.Context, userID string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.started++
+	id := fmt.Sprintf("conv-%d", f.started)
+	f.users[id] = userID
+	return id, nil
+}
+
+func (f *fakeConversations) RecentMessages(ctx context.Context, conversationID string, limit int) ([]map[string]interface{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	messages := f.messages[conversationID]
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+func (f *fakeConversations) AppendExchange(ctx context.Context, conversationID, userID, message, reply string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.messages[conversationID] = append(f.messages[conversationID],
+		map[string]interface{}{"role": "user", "content": message},
+		map[string]interface{}{"role": "assistant", "content": reply},
+	)
+	return fmt.Sprintf("%s-msg-%d", conversationID, len(f.messages[conversationID])), nil
+}
+
+// ownedConversations is a fakeConversations checking who owns them.
+type ownedConversations struct {
+	*fakeConversations
+}
+
+func (o ownedConversations) CheckOwner(ctx context.Context, conversationID, userID string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	owner, ok := o.users[conversationID]
+	switch {
+	case !ok:
+		return chaterrors.Mark(fmt.Errorf("conversation %s not found", conversationID), chaterrors.ErrNotFound)
+	case owner != "" && owner != userID:
+		return fmt.Errorf("%w: conversation of another user", chaterrors.ErrForbidden)
+	}
+	return nil
+}
+
+func TestConversations_HandleHTTP(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	conversations := newFakeConversations()
+	bot, err := New(cfg, WithModel(model), WithConversations(conversations, 3))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	chat := func(body string) ChatResponse {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/chat", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		bot.HandleHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response ChatResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	// Without an ID a conversation is started and returned
+	first := chat(`{"message": "Hi"}`)
+	if first.ConversationID != "conv-1" {
+		t.Fatalf("expected a new conversation, got %q", first.ConversationID)
+	}
+	if _, ok := model.context["history"]; ok {
+		t.Errorf("expected no history for a new conversation, got %v", model.context["history"])
+	}
+
+	// Sending it back continues the conversation with its history
+	second := chat(`{"message": "Again", "conversation_id": "conv-1"}`)
+	if second.ConversationID != "conv-1" || conversations.started != 1 {
+		t.Errorf("expected the conversation to continue, got %q", second.ConversationID)
+	}
+	history, _ := model.context["history"].([]map[string]interface{})
+	if len(history) != 2 || history[0]["content"] != "Hi" || history[1]["content"] != "ok" {
+		t.Errorf("unexpected history: %v", history)
+	}
+
+	// Only the last historyLength messages are sent
+	chat(`{"message": "Third", "conversation_id": "conv-1"}`)
+	history, _ = model.context["history"].([]map[string]interface{})
+	if len(history) != 3 || history[0]["content"] != "ok" {
+		t.Errorf("expected the last 3 messages, got %v", history)
+	}
+}
+
+func TestConversations_CallerHistory(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	conversations := newFakeConversations()
+	bot, err := New(cfg, WithModel(model), WithConversations(conversations, 0))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if bot.historyLength != defaultHistoryLength {
+		t.Errorf("expected the default history length, got %d", bot.historyLength)
+	}
+
+	// Ask does not start conversations, and a caller's history is kept
+	if _, err := bot.Ask(context.Background(), "Hi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conversations.started != 0 {
+		t.Errorf("expected Ask not to start a conversation")
+	}
+
+	own := []map[string]interface{}{{"role": "user", "content": "Earlier"}}
+	_, err = bot.Ask(context.Background(), "Hi", WithContext("conversation_id", "c1"), WithContext("history", own))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	history, _ := model.context["history"].([]map[string]interface{})
+	if len(history) != 1 || history[0]["content"] != "Earlier" {
+		t.Errorf("expected the caller's history, got %v", history)
+	}
+	if len(conversations.messages["c1"]) != 2 {
+		t.Errorf("expected the exchange to be stored, got %v", conversations.messages["c1"])
+	}
+}
+
+func TestConversations_HandleStreamHTTP(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &thinkingModel{}
+	conversations := newFakeConversations()
+	bot, err := New(cfg, WithModel(model), WithConversations(conversations, 3))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	stream := func(body string) string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/chat/stream", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		bot.HandleStreamHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	// Without an ID a conversation is started and sent with the done chunk
+	if body := stream(`{"message": "Hello"}`); !strings.Contains(body, `"conversation_id":"conv-1"`) {
+		t.Fatalf("expected the new conversation in the done chunk, got %s", body)
+	}
+	messages := conversations.messages["conv-1"]
+	if len(messages) != 2 || messages[0]["content"] != "Hello" || messages[1]["content"] != "Hi" {
+		t.Fatalf("expected the streamed exchange to be stored, got %v", messages)
+	}
+
+	// Sending it back continues the conversation with its history
+	if body := stream(`{"message": "Again", "conversation_id": "conv-1"}`); !strings.Contains(body, `"conversation_id":"conv-1"`) {
+		t.Errorf("expected the conversation in the done chunk, got %s", body)
+	}
+	history, _ := model.context["history"].([]map[string]interface{})
+	if len(history) != 2 || history[0]["content"] != "Hello" || conversations.started != 1 {
+		t.Errorf("expected the stored history, got %v", history)
+	}
+
+	// Replies sent as a single chunk are stored too
+	bot, err = New(cfg, WithModel(&contextModel{}), WithConversations(conversations, 3))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if body := stream(`{"message": "Once more", "conversation_id": "conv-1"}`); !strings.Contains(body, `"conversation_id":"conv-1"`) {
+		t.Errorf("expected the conversation in the done chunk, got %s", body)
+	}
+	if messages := conversations.messages["conv-1"]; len(messages) != 6 || messages[5]["content"] != "ok" {
+		t.Errorf("expected the single chunk exchange to be stored, got %v", messages)
+	}
+}
+
+func TestConversations_RejectedRequestsStoreNothing(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	conversations := newFakeConversations()
+	limiter := middleware.NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute})
+	bot, err := New(cfg, WithModel(&contextModel{}), WithConversations(conversations, 3), WithRateLimit(limiter))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.AskResponse(context.Background(), "Hi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := bot.AskResponse(context.Background(), "Hi"); err == nil {
+		t.Fatal("expected the second request to be rate limited")
+	}
+	req := httptest.NewRequest("POST", "/chat/stream", strings.NewReader(`{"message": "Hi"}`))
+	w := httptest.NewRecorder()
+	bot.HandleStreamHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the stream to be rate limited, got %d", w.Code)
+	}
+	if _, err := bot.AskResponse(context.Background(), ""); err == nil {
+		t.Fatal("expected an empty message to be rejected")
+	}
+
+	if conversations.started != 1 {
+		t.Errorf("expected only the answered request to start a conversation, got %d", conversations.started)
+	}
+}
+
+// turnModel replies with how many history messages it was sent, holding
+// its first reply until release is closed.
+type turnModel struct {
+	calls   int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (m *turnModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	if atomic.AddInt32(&m.calls, 1) == 1 {
+		close(m.entered)
+		<-m.release
+	}
+	history, _ := context["history"].([]map[string]interface{})
+	return fmt.Sprintf("seen %d", len(history)), nil
+}
+
+func (m *turnModel) Name() string     { return "turns" }
+func (m *turnModel) Provider() string { return "test" }
+
+func TestConversations_ConcurrentTurnsInOrder(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	manager := database.NewConversationManager(chatbottest.NewStore(), database.WithLocker(database.NewMemoryLocker()))
+	model := &turnModel{entered: make(chan struct{}), release: make(chan struct{})}
+	bot, err := New(cfg, WithModel(model), WithConversations(manager, 10))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	id, err := manager.StartConversation(context.Background(), "")
+	if err != nil {
+		t.Fatalf("failed to start conversation: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	ask := func(message string) {
+		defer wg.Done()
+		if _, err := bot.Ask(context.Background(), message, WithContext("conversation_id", id)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	wg.Add(2)
+	go ask("first")
+	<-model.entered
+	// The second turn waits for the first to be stored before it reads
+	// the history
+	go ask("second")
+	time.Sleep(20 * time.Millisecond)
+	close(model.release)
+	wg.Wait()
+
+	messages, err := manager.RecentMessages(context.Background(), id, 10)
+	if err != nil {
+		t.Fatalf("failed to read messages: %v", err)
+	}
+	var got []string
+	for _, message := range messages {
+		got = append(got, message["content"].(string))
+	}
+	want := []string{"first", "seen 0", "second", "seen 2"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected the turns in order %v, got %v", want, got)
+	}
+}
+
+func TestConversations_AskResponseMessageID(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	store := chatbottest.NewStore()
+	manager := database.NewConversationManager(store)
+	bot, err := New(cfg, WithModel(&contextModel{}), WithConversations(manager, 3))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	// The message ID is the ID of the stored reply
+	response, err := bot.AskResponse(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	messages, err := store.GetConversationHistory(context.Background(), response.ConversationID)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(messages) != 2 || messages[1].ID != response.MessageID {
+		t.Errorf("expected the message ID %q of the stored reply, got %+v", response.MessageID, messages)
+	}
+}
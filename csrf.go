@@ -0,0 +1,145 @@
+package gochatbot
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+// Defaults of CSRF protection.
+const (
+	defaultCSRFCookieName = "chatbot_csrf"
+	defaultCSRFHeaderName = "X-CSRF-Token"
+)
+
+// ErrInvalidCSRFToken is returned for requests whose CSRF token is missing
+// or does not match their cookie. It matches chaterrors.ErrForbidden.
+var ErrInvalidCSRFToken = chaterrors.Mark(errors.New("invalid CSRF token"), chaterrors.ErrForbidden)
+
+// CSRFOptions configures the CSRF protection enabled with WithCSRF.
+type CSRFOptions struct {
+	// CookieName is the cookie carrying the token; the default is
+	// "chatbot_csrf". Scripts must be able to read it, so it is not
+	// HttpOnly.
+	CookieName string
+	// HeaderName is the header requests repeat the token in; the default
+	// is "X-CSRF-Token".
+	HeaderName string
+	// Domain and Path scope the cookie; Path defaults to "/".
+	Domain string
+	Path   string
+	// Secure sends the cookie over HTTPS only. Set it in production.
+	Secure bool
+	// SameSite defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+}
+
+// WithCSRF protects HandleHTTP, HandleStreamHTTP and the widget's endpoints
+// with double-submit cookies, for sites whose users are authenticated by a
+// cookie: the chatbot issues a random token in a cookie, and POST requests
+// must repeat it in a header, which other sites cannot read or send. Use
+// Chatbot.CSRF for other endpoints, such as conversation or handoff APIs.
+func WithCSRF(options CSRFOptions) Option {
+	return func(c *Chatbot) {
+		if options.CookieName == "" {
+			options.CookieName = defaultCSRFCookieName
+		}
+		if options.HeaderName == "" {
+			options.HeaderName = defaultCSRFHeaderName
+		}
+		if options.Path == "" {
+			options.Path = "/"
+		}
+		if options.SameSite == 0 {
+			options.SameSite = http.SameSiteLaxMode
+		}
+		c.csrf = &options
+	}
+}
+
+// CSRFToken returns the request's CSRF token, issuing a cookie with a new
+// one if it has none, for pages that render their own forms or scripts
+// posting to the chat endpoints. It returns "" without WithCSRF. It must be
+// called before the response is written.
+func (c *Chatbot) CSRFToken(w http.ResponseWriter, r *http.Request) string {
+	if c.csrf == nil {
+		return ""
+	}
+	if cookie, err := r.Cookie(c.csrf.CookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	return c.issueCSRFToken(w)
+}
+
+// CSRF returns middleware applying the chatbot's CSRF protection to next:
+// GET, HEAD and OPTIONS requests are issued a token, and other requests
+// are answered with a 403 unless they repeat it. Without WithCSRF, next is
+// returned as is.
+func (c *Chatbot) CSRF(next http.Handler) http.Handler {
+	if c.csrf == nil {
+		return next
+	}
+	handler := NewHTTPHandler(c)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.CSRFToken(w, r)
+		default:
+			if !handler.checkCSRF(w, r) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verifyCSRF checks that r repeats the token of its cookie. A request
+// without one is issued a token, so the client can retry.
+func (c *Chatbot) verifyCSRF(w http.ResponseWriter, r *http.Request) error {
+	if c.csrf == nil {
+		return nil
+	}
+	cookie, err := r.Cookie(c.csrf.CookieName)
+	if err != nil || cookie.Value == "" {
+		c.issueCSRFToken(w)
+		return ErrInvalidCSRFToken
+	}
+	header := r.Header.Get(c.csrf.HeaderName)
+	if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+// issueCSRFToken sets a cookie with a new token and returns the token.
+func (c *Chatbot) issueCSRFToken(w http.ResponseWriter) string {
+	token := make([]byte, 32)
+	// crypto/rand.Read never fails on supported platforms
+	rand.Read(token)
+	value := base64.RawURLEncoding.EncodeToString(token)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.csrf.CookieName,
+		Value:    value,
+		Domain:   c.csrf.Domain,
+		Path:     c.csrf.Path,
+		Secure:   c.csrf.Secure,
+		SameSite: c.csrf.SameSite,
+	})
+	return value
+}
+
+// checkCSRF answers a request failing CSRF protection with a 403 and
+// reports whether it may be served.
+func (h *HTTPHandler) checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	if err := h.chatbot.verifyCSRF(w, r); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		h.writeErrorResponse(w, http.StatusForbidden, "Invalid CSRF token")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package gochatbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/widget"
+)
+
+func TestWithCSRF_ServeWidget(t *testing.T) {
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()), WithCSRF(CSRFOptions{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	handler := bot.ServeWidget("chatbot", widget.Options{})
+
+	// Loading the widget issues the token and tells the widget where it is
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/chatbot/widget.js", nil))
+	if !strings.Contains(rec.Body.String(), `"csrfCookie":"chatbot_csrf","csrfHeader":"X-CSRF-Token"`) {
+		t.Errorf("expected the CSRF options in the widget script")
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "chatbot_csrf" || cookies[0].HttpOnly {
+		t.Fatalf("expected a CSRF cookie readable by scripts, got %v", cookies)
+	}
+	token := cookies[0].Value
+
+	chat := func(path, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"message":"Hello"}`))
+		req.AddCookie(&http.Cookie{Name: "chatbot_csrf", Value: token})
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/chatbot/chat", "/chatbot/chat/stream"} {
+		for _, header := range []string{"", "forged"} {
+			rec := chat(path, header)
+			var response ChatResponse
+			json.Unmarshal(rec.Body.Bytes(), &response)
+			if rec.Code != http.StatusForbidden || response.Code != "forbidden" {
+				t.Errorf("%s with token %q: expected 403 forbidden, got %d: %s", path, header, rec.Code, rec.Body.String())
+			}
+		}
+		if rec := chat(path, token); rec.Code != http.StatusOK {
+			t.Errorf("%s: expected the token to be accepted, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestChatbot_CSRF(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Without WithCSRF the handler is unchanged
+	bot, err := New(config.Default())
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	bot.CSRF(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/conversations", nil))
+	if rec.Code != http.StatusNoContent || bot.CSRFToken(rec, httptest.NewRequest(http.MethodGet, "/", nil)) != "" {
+		t.Errorf("expected no CSRF protection, got %d", rec.Code)
+	}
+
+	bot, err = New(config.Default(), WithCSRF(CSRFOptions{CookieName: "csrf", HeaderName: "X-Token", Secure: true}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	handler := bot.CSRF(next)
+
+	// A POST without a token is rejected and issued one to retry with
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/conversations", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusForbidden || len(cookies) != 1 || cookies[0].Name != "csrf" || !cookies[0].Secure {
+		t.Fatalf("expected a 403 issuing a token, got %d and %v", rec.Code, cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/conversations", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set("X-Token", cookies[0].Value)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected the token to be accepted, got %d", rec.Code)
+	}
+
+	// Safe methods pass, keeping an existing token
+	req = httptest.NewRequest(http.MethodGet, "/conversations", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected the GET to pass with its token, got %d and %v", rec.Code, rec.Result().Cookies())
+	}
+}
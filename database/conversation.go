@@ -5,13 +5,30 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/lib/pq"           // PostgreSQL driver
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/models"
 )
 
+// ErrConversationNotFound is returned for a conversation that does not
+// exist.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ErrNotConversationOwner is returned by CheckOwner for a conversation
+// started by another user. It is of kind chaterrors.ErrForbidden.
+var ErrNotConversationOwner = chaterrors.Mark(errors.New("conversation belongs to another user"), chaterrors.ErrForbidden)
+
 // Conversation represents a chat conversation.
 type Conversation struct {
 	ID        string                 `json:"id" db:"id"`
@@ -69,14 +86,26 @@ type ConversationStore interface {
 type SQLConversationStore struct {
 	db     *sql.DB
 	driver string // "postgres" or "sqlite3"
+
+	replicas []*sql.DB // read replicas, used in turn
+	next     atomic.Uint64
+	writes   *writeTracker
+
+	embedder embeddings.EmbeddingProvider // embeds messages, if set
 }
 
-// NewSQLConversationStore creates a new SQL-based conversation store.
-func NewSQLConversationStore(db *sql.DB, driver string) *SQLConversationStore {
-	return &SQLConversationStore{
+// NewSQLConversationStore creates a new SQL-based conversation store. db is
+// the primary database, which gets every write.
+func NewSQLConversationStore(db *sql.DB, driver string, opts ...StoreOption) *SQLConversationStore {
+	s := &SQLConversationStore{
 		db:     db,
 		driver: driver,
+		writes: &writeTracker{window: DefaultMaxStaleness},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Initialize creates the necessary database tables.
@@ -104,12 +133,33 @@ func (s *SQLConversationStore) Initialize(ctx context.Context) error {
 			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
 		)`
 
+	// Create the outbox of events written with messages
+	outboxSQL := `
+		CREATE TABLE IF NOT EXISTS event_outbox (
+			id VARCHAR(255) PRIMARY KEY,
+			payload TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`
+
+	// Create the embeddings of messages, for semantic search
+	embeddingsSQL := `
+		CREATE TABLE IF NOT EXISTS message_embeddings (
+			message_id VARCHAR(255) PRIMARY KEY,
+			conversation_id VARCHAR(255) NOT NULL,
+			model VARCHAR(255) NOT NULL,
+			embedding TEXT NOT NULL
+		)`
+
 	// Create indexes
 	indexSQL := []string{
 		"CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_conversations_created_at ON conversations(created_at)",
 		"CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id)",
 		"CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at)",
+		// Reads a conversation's messages in either order without sorting
+		"CREATE INDEX IF NOT EXISTS idx_messages_conversation_created_at ON messages(conversation_id, created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_event_outbox_created_at ON event_outbox(created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_message_embeddings_conversation_id ON message_embeddings(conversation_id)",
 	}
 
 	// Execute table creation
@@ -121,6 +171,14 @@ func (s *SQLConversationStore) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to create messages table: %w", err)
 	}
 
+	if _, err := s.db.ExecContext(ctx, outboxSQL); err != nil {
+		return fmt.Errorf("failed to create event outbox table: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, embeddingsSQL); err != nil {
+		return fmt.Errorf("failed to create message embeddings table: %w", err)
+	}
+
 	// Execute index creation
 	for _, idx := range indexSQL {
 		if _, err := s.db.ExecContext(ctx, idx); err != nil {
@@ -140,6 +198,7 @@ func (s *SQLConversationStore) CreateConversation(ctx context.Context, conv *Con
 
 	conv.CreatedAt = time.Now()
 	conv.UpdatedAt = conv.CreatedAt
+	s.recordWrites(conversationKey(conv.ID), userKey(conv.UserID))
 
 	query := `
 		INSERT INTO conversations (id, user_id, title, metadata, created_at, updated_at)
@@ -162,12 +221,12 @@ func (s *SQLConversationStore) GetConversation(ctx context.Context, id string) (
 	var conv Conversation
 	var metadataJSON string
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.reader(conversationKey(id)).QueryRowContext(ctx, query, id).Scan(
 		&conv.ID, &conv.UserID, &conv.Title, &metadataJSON, &conv.CreatedAt, &conv.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("conversation not found")
+			return nil, ErrConversationNotFound
 		}
 		return nil, fmt.Errorf("failed to get conversation: %w", err)
 	}
@@ -190,13 +249,16 @@ func (s *SQLConversationStore) UpdateConversation(ctx context.Context, conv *Con
 	}
 
 	conv.UpdatedAt = time.Now()
+	s.recordWrites(conversationKey(conv.ID), userKey(conv.UserID))
 
+	// Placeholders are numbered in order of appearance, as SQLite binds
+	// them that way
 	query := `
 		UPDATE conversations
-		SET user_id = $2, title = $3, metadata = $4, updated_at = $5
-		WHERE id = $1`
+		SET user_id = $1, title = $2, metadata = $3, updated_at = $4
+		WHERE id = $5`
 
-	result, err := s.db.ExecContext(ctx, query, conv.ID, conv.UserID, conv.Title, string(metadataJSON), conv.UpdatedAt)
+	result, err := s.db.ExecContext(ctx, query, conv.UserID, conv.Title, string(metadataJSON), conv.UpdatedAt, conv.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update conversation: %w", err)
 	}
@@ -207,7 +269,7 @@ func (s *SQLConversationStore) UpdateConversation(ctx context.Context, conv *Con
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("conversation not found")
+		return ErrConversationNotFound
 	}
 
 	return nil
@@ -215,6 +277,8 @@ func (s *SQLConversationStore) UpdateConversation(ctx context.Context, conv *Con
 
 // DeleteConversation deletes a conversation and all its messages.
 func (s *SQLConversationStore) DeleteConversation(ctx context.Context, id string) error {
+	s.recordWrites(conversationKey(id))
+
 	// Start transaction
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -235,6 +299,10 @@ func (s *SQLConversationStore) DeleteConversation(ctx context.Context, id string
 	if err != nil {
 		return fmt.Errorf("failed to delete messages: %w", err)
 	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM message_embeddings WHERE conversation_id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete message embeddings: %w", err)
+	}
 
 	// Delete conversation
 	result, err := tx.ExecContext(ctx, "DELETE FROM conversations WHERE id = $1", id)
@@ -248,7 +316,7 @@ func (s *SQLConversationStore) DeleteConversation(ctx context.Context, id string
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("conversation not found")
+		return ErrConversationNotFound
 	}
 
 	return tx.Commit()
@@ -263,7 +331,7 @@ func (s *SQLConversationStore) ListConversations(ctx context.Context, userID str
 		ORDER BY updated_at DESC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := s.reader(userKey(userID)).QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list conversations: %w", err)
 	}
@@ -296,6 +364,59 @@ func (s *SQLConversationStore) ListConversations(ctx context.Context, userID str
 	return conversations, nil
 }
 
+// ConversationScanner is a ConversationStore listing the conversations of
+// all users by when they were last updated, for background jobs such as
+// archiving idle conversations.
+type ConversationScanner interface {
+	// ScanConversations returns up to limit conversations last updated in
+	// [since, until), ordered by update time and ID. Pass the last
+	// conversation of a page as after to get the next page; nil starts at
+	// the beginning.
+	ScanConversations(ctx context.Context, since, until time.Time, after *Conversation, limit int) ([]*Conversation, error)
+}
+
+var _ ConversationScanner = (*SQLConversationStore)(nil)
+
+// ScanConversations implements ConversationScanner.
+func (s *SQLConversationStore) ScanConversations(ctx context.Context, since, until time.Time, after *Conversation, limit int) ([]*Conversation, error) {
+	afterTime, afterID := since, ""
+	if after != nil {
+		afterTime, afterID = after.UpdatedAt, after.ID
+	}
+	query := `
+		SELECT id, user_id, title, metadata, created_at, updated_at
+		FROM conversations
+		WHERE updated_at >= $1 AND updated_at < $2
+			AND (updated_at > $3 OR (updated_at = $4 AND id > $5))
+		ORDER BY updated_at, id
+		LIMIT $6`
+
+	rows, err := s.reader().QueryContext(ctx, query, since, until, afterTime, afterTime, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []*Conversation
+	for rows.Next() {
+		var conv Conversation
+		var metadataJSON string
+		if err := rows.Scan(&conv.ID, &conv.UserID, &conv.Title, &metadataJSON, &conv.CreatedAt, &conv.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		if metadataJSON != "" {
+			if err := json.Unmarshal([]byte(metadataJSON), &conv.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+		conversations = append(conversations, &conv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate conversations: %w", err)
+	}
+	return conversations, nil
+}
+
 // AddMessage adds a message to a conversation.
 func (s *SQLConversationStore) AddMessage(ctx context.Context, msg *Message) error {
 	metadataJSON, err := json.Marshal(msg.Metadata)
@@ -304,6 +425,7 @@ func (s *SQLConversationStore) AddMessage(ctx context.Context, msg *Message) err
 	}
 
 	msg.CreatedAt = time.Now()
+	s.recordWrites(conversationKey(msg.ConversationID))
 
 	query := `
 		INSERT INTO messages (id, conversation_id, role, content, metadata, created_at)
@@ -320,6 +442,82 @@ func (s *SQLConversationStore) AddMessage(ctx context.Context, msg *Message) err
 		return fmt.Errorf("failed to update conversation timestamp: %w", err)
 	}
 
+	s.embedNew(ctx, []*Message{msg})
+	return nil
+}
+
+// insertBatchSize is how many messages AddMessages inserts per statement,
+// keeping the placeholders well under SQLite's limit.
+const insertBatchSize = 100
+
+// AddMessages adds messages in one transaction, inserting up to 100 per
+// statement and updating each conversation's timestamp once. Messages keep
+// their CreatedAt if set, like those queued by a WriteBehindStore.
+func (s *SQLConversationStore) AddMessages(ctx context.Context, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	s.recordMessages(messages)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertMessages(ctx, tx, messages); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit messages: %w", err)
+	}
+	s.embedNew(ctx, messages)
+	return nil
+}
+
+// insertMessages adds messages in tx for AddMessages.
+func insertMessages(ctx context.Context, tx *sql.Tx, messages []*Message) error {
+	now := time.Now()
+	updated := make(map[string]time.Time)
+	var conversations []string
+	for start := 0; start < len(messages); start += insertBatchSize {
+		batch := messages[start:min(start+insertBatchSize, len(messages))]
+		var query strings.Builder
+		query.WriteString("INSERT INTO messages (id, conversation_id, role, content, metadata, created_at) VALUES ")
+		args := make([]interface{}, 0, len(batch)*6)
+		for i, msg := range batch {
+			metadataJSON, err := json.Marshal(msg.Metadata)
+			if err != nil {
+				return fmt.Errorf("failed to marshal metadata: %w", err)
+			}
+			if msg.CreatedAt.IsZero() {
+				msg.CreatedAt = now
+			}
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, msg.ID, msg.ConversationID, msg.Role, msg.Content, string(metadataJSON), msg.CreatedAt)
+
+			last, seen := updated[msg.ConversationID]
+			if !seen {
+				conversations = append(conversations, msg.ConversationID)
+			}
+			if msg.CreatedAt.After(last) {
+				updated[msg.ConversationID] = msg.CreatedAt
+			}
+		}
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("failed to add messages: %w", err)
+		}
+	}
+
+	for _, id := range conversations {
+		if _, err := tx.ExecContext(ctx, "UPDATE conversations SET updated_at = $1 WHERE id = $2", updated[id], id); err != nil {
+			return fmt.Errorf("failed to update conversation timestamp: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -332,7 +530,7 @@ func (s *SQLConversationStore) GetMessages(ctx context.Context, conversationID s
 		ORDER BY created_at ASC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := s.db.QueryContext(ctx, query, conversationID, limit, offset)
+	rows, err := s.reader(conversationKey(conversationID)).QueryContext(ctx, query, conversationID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
@@ -365,6 +563,96 @@ func (s *SQLConversationStore) GetMessages(ctx context.Context, conversationID s
 	return messages, nil
 }
 
+// MessageQuery selects messages of a conversation.
+type MessageQuery struct {
+	// Limit is the most messages returned; 0 returns them all.
+	Limit int
+	// Offset skips messages, and needs a Limit.
+	Offset int
+	// Descending returns the newest messages first.
+	Descending bool
+}
+
+// MessageQuerier is a ConversationStore that pages through messages in
+// either order, so the last messages of a conversation are read without
+// the rest.
+type MessageQuerier interface {
+	QueryMessages(ctx context.Context, conversationID string, query MessageQuery) ([]*Message, error)
+}
+
+var _ MessageQuerier = (*SQLConversationStore)(nil)
+
+// QueryMessages implements MessageQuerier, reading the messages in either
+// order with the conversation and creation time index.
+func (s *SQLConversationStore) QueryMessages(ctx context.Context, conversationID string, query MessageQuery) ([]*Message, error) {
+	order := "ASC"
+	if query.Descending {
+		order = "DESC"
+	}
+	sqlQuery := `
+		SELECT id, conversation_id, role, content, metadata, created_at
+		FROM messages
+		WHERE conversation_id = $1
+		ORDER BY created_at ` + order
+	args := []interface{}{conversationID}
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT $2 OFFSET $3"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := s.reader(conversationKey(conversationID)).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var msg Message
+		var metadataJSON string
+
+		err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Role, &msg.Content, &metadataJSON, &msg.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+
+		// Parse metadata
+		if metadataJSON != "" {
+			if err := json.Unmarshal([]byte(metadataJSON), &msg.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+
+		messages = append(messages, &msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// queryMessages queries store's messages, with QueryMessages if it is a
+// MessageQuerier and from the whole history otherwise.
+func queryMessages(ctx context.Context, store ConversationStore, conversationID string, query MessageQuery) ([]*Message, error) {
+	if querier, ok := store.(MessageQuerier); ok {
+		return querier.QueryMessages(ctx, conversationID, query)
+	}
+	messages, err := store.GetConversationHistory(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if query.Descending {
+		slices.Reverse(messages)
+	}
+	if query.Limit > 0 {
+		messages = messages[min(query.Offset, len(messages)):]
+		messages = messages[:min(query.Limit, len(messages))]
+	}
+	return messages, nil
+}
+
 // DeleteMessage deletes a specific message.
 func (s *SQLConversationStore) DeleteMessage(ctx context.Context, messageID string) error {
 	result, err := s.db.ExecContext(ctx, "DELETE FROM messages WHERE id = $1", messageID)
@@ -381,6 +669,9 @@ func (s *SQLConversationStore) DeleteMessage(ctx context.Context, messageID stri
 		return fmt.Errorf("message not found")
 	}
 
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM message_embeddings WHERE message_id = $1", messageID); err != nil {
+		return fmt.Errorf("failed to delete message embedding: %w", err)
+	}
 	return nil
 }
 
@@ -392,7 +683,7 @@ func (s *SQLConversationStore) GetConversationHistory(ctx context.Context, conve
 		WHERE conversation_id = $1
 		ORDER BY created_at ASC`
 
-	rows, err := s.db.QueryContext(ctx, query, conversationID)
+	rows, err := s.reader(conversationKey(conversationID)).QueryContext(ctx, query, conversationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversation history: %w", err)
 	}
@@ -460,9 +751,9 @@ func (s *SQLConversationStore) SearchConversations(ctx context.Context, userID,
 	var err error
 
 	if s.driver == "postgres" {
-		rows, err = s.db.QueryContext(ctx, searchQuery, userID, searchPattern, limit)
+		rows, err = s.reader(userKey(userID)).QueryContext(ctx, searchQuery, userID, searchPattern, limit)
 	} else {
-		rows, err = s.db.QueryContext(ctx, searchQuery, userID, searchPattern, searchPattern, limit)
+		rows, err = s.reader(userKey(userID)).QueryContext(ctx, searchQuery, userID, searchPattern, searchPattern, limit)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to search conversations: %w", err)
@@ -498,14 +789,20 @@ func (s *SQLConversationStore) SearchConversations(ctx context.Context, userID,
 
 // ConversationManager provides high-level conversation management.
 type ConversationManager struct {
-	store ConversationStore
+	store        ConversationStore
+	summaryModel models.Model
+	locker       Locker
 }
 
 // NewConversationManager creates a new conversation manager.
-func NewConversationManager(store ConversationStore) *ConversationManager {
-	return &ConversationManager{
+func NewConversationManager(store ConversationStore, opts ...ManagerOption) *ConversationManager {
+	cm := &ConversationManager{
 		store: store,
 	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+	return cm
 }
 
 // CreateConversationWithMessage creates a new conversation with an initial message.
@@ -547,52 +844,199 @@ func (cm *ConversationManager) CreateConversationWithMessage(ctx context.Context
 
 // AddUserMessage adds a user message to a conversation.
 func (cm *ConversationManager) AddUserMessage(ctx context.Context, conversationID, content string) (*Message, error) {
-	msg := &Message{
-		ID:             generateID(),
-		ConversationID: conversationID,
-		Role:           "user",
-		Content:        content,
-		Metadata:       make(map[string]interface{}),
-	}
-
-	if err := cm.store.AddMessage(ctx, msg); err != nil {
-		return nil, fmt.Errorf("failed to add user message: %w", err)
-	}
-
-	return msg, nil
+	return cm.addMessage(ctx, conversationID, "user", content, nil)
 }
 
 // AddAssistantMessage adds an assistant message to a conversation.
 func (cm *ConversationManager) AddAssistantMessage(ctx context.Context, conversationID, content string) (*Message, error) {
+	return cm.addMessage(ctx, conversationID, "assistant", content, nil)
+}
+
+// addMessage adds a message with metadata, which may be nil, to a
+// conversation.
+func (cm *ConversationManager) addMessage(ctx context.Context, conversationID, role, content string, metadata map[string]interface{}) (*Message, error) {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
 	msg := &Message{
 		ID:             generateID(),
 		ConversationID: conversationID,
-		Role:           "assistant",
+		Role:           role,
 		Content:        content,
-		Metadata:       make(map[string]interface{}),
+		Metadata:       metadata,
 	}
 
 	if err := cm.store.AddMessage(ctx, msg); err != nil {
-		return nil, fmt.Errorf("failed to add assistant message: %w", err)
+		return nil, fmt.Errorf("failed to add %s message: %w", role, err)
 	}
 
 	return msg, nil
 }
 
-// GetConversationContext retrieves recent messages for context.
+// GetConversationContext retrieves the last maxMessages messages for
+// context, oldest first. Stores implementing MessageQuerier read only those.
 func (cm *ConversationManager) GetConversationContext(ctx context.Context, conversationID string, maxMessages int) ([]*Message, error) {
-	// Get the most recent messages
-	messages, err := cm.store.GetConversationHistory(ctx, conversationID)
+	if maxMessages <= 0 {
+		return nil, nil
+	}
+
+	messages, err := queryMessages(ctx, cm.store, conversationID, MessageQuery{Limit: maxMessages, Descending: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversation history: %w", err)
 	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// StartConversation creates an empty conversation for the user and returns
+// its ID, which is random so it cannot be guessed. With RecentMessages and
+// AppendExchange it lets a ConversationManager keep the conversations of a
+// chatbot's endpoints (gochatbot.WithConversations).
+func (cm *ConversationManager) StartConversation(ctx context.Context, userID string) (string, error) {
+	conv := &Conversation{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		Metadata: make(map[string]interface{}),
+	}
+	if err := cm.store.CreateConversation(ctx, conv); err != nil {
+		return "", fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return conv.ID, nil
+}
 
-	// Return the last N messages
-	if len(messages) <= maxMessages {
-		return messages, nil
+// CheckOwner returns an error matching ErrConversationNotFound and
+// chaterrors.ErrNotFound if the conversation does not exist, and
+// ErrNotConversationOwner if it was started by a user other than userID.
+// Conversations started without a user may be continued by anyone. Call it
+// before RecentMessages and AppendExchange, which do not check the owner,
+// for conversation IDs sent by clients; the chatbot does so for requests
+// continuing a conversation with gochatbot.WithConversationID.
+func (cm *ConversationManager) CheckOwner(ctx context.Context, conversationID, userID string) error {
+	conv, err := cm.store.GetConversation(ctx, conversationID)
+	if errors.Is(err, ErrConversationNotFound) {
+		return chaterrors.Mark(err, chaterrors.ErrNotFound)
+	} else if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if conv.UserID != "" && conv.UserID != userID {
+		return ErrNotConversationOwner
 	}
+	return nil
+}
 
-	return messages[len(messages)-maxMessages:], nil
+// RecentMessages returns the last limit messages of the conversation,
+// oldest first, as the "role" and "content" maps models take as history.
+// It does not check who the conversation belongs to; see CheckOwner.
+func (cm *ConversationManager) RecentMessages(ctx context.Context, conversationID string, limit int) ([]map[string]interface{}, error) {
+	messages, err := cm.GetConversationContext(ctx, conversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		history = append(history, map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		})
+	}
+	return history, nil
+}
+
+// AppendExchange stores a user message and the reply to it, creating the
+// conversation for the user if it does not exist yet, and returns the ID of
+// the stored reply. It does not check who an existing conversation belongs
+// to; see CheckOwner.
+func (cm *ConversationManager) AppendExchange(ctx context.Context, conversationID, userID, message, reply string) (string, error) {
+	return cm.AppendExchangeWithMetadata(ctx, conversationID, userID, message, reply, nil, nil)
+}
+
+// AppendExchangeWithMetadata is AppendExchange storing metadata, which may
+// be nil, with the message and the reply, such as the original and
+// translated text of translated messages.
+func (cm *ConversationManager) AppendExchangeWithMetadata(ctx context.Context, conversationID, userID, message, reply string, messageMetadata, replyMetadata map[string]interface{}) (string, error) {
+	if err := cm.ensureConversation(ctx, conversationID, userID); err != nil {
+		return "", err
+	}
+
+	if _, err := cm.addMessage(ctx, conversationID, "user", message, messageMetadata); err != nil {
+		return "", err
+	}
+	msg, err := cm.addMessage(ctx, conversationID, "assistant", reply, replyMetadata)
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// ensureConversation creates the conversation for the user if it does not
+// exist yet.
+func (cm *ConversationManager) ensureConversation(ctx context.Context, conversationID, userID string) error {
+	if _, err := cm.store.GetConversation(ctx, conversationID); errors.Is(err, ErrConversationNotFound) {
+		conv := &Conversation{
+			ID:       conversationID,
+			UserID:   userID,
+			Metadata: make(map[string]interface{}),
+		}
+		if err := cm.store.CreateConversation(ctx, conv); err != nil {
+			return fmt.Errorf("failed to create conversation: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return nil
+}
+
+// stateKey is the conversation metadata key holding the state tools keep
+// in a conversation.
+const stateKey = "tool_state"
+
+// LoadState returns the state tools keep in the conversation, stored in
+// its metadata. An unknown conversation has none.
+func (cm *ConversationManager) LoadState(ctx context.Context, conversationID string) (map[string]json.RawMessage, error) {
+	conv, err := cm.store.GetConversation(ctx, conversationID)
+	if errors.Is(err, ErrConversationNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	stored, ok := conv.Metadata[stateKey]
+	if !ok {
+		return nil, nil
+	}
+	// The store decodes metadata into generic values; take them back as JSON
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode conversation state: %w", err)
+	}
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation state: %w", err)
+	}
+	return state, nil
+}
+
+// SaveState stores the state tools keep in the conversation in its
+// metadata, removing it when empty.
+func (cm *ConversationManager) SaveState(ctx context.Context, conversationID string, state map[string]json.RawMessage) error {
+	conv, err := cm.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	if conv.Metadata == nil {
+		conv.Metadata = make(map[string]interface{})
+	}
+	if len(state) == 0 {
+		delete(conv.Metadata, stateKey)
+	} else {
+		conv.Metadata[stateKey] = state
+	}
+	if err := cm.store.UpdateConversation(ctx, conv); err != nil {
+		return fmt.Errorf("failed to save conversation state: %w", err)
+	}
+	return nil
 }
 
 // generateID generates a unique ID for conversations and messages.
@@ -3,12 +3,17 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
+
+	"go.rumenx.com/chatbot/chaterrors"
 )
 
 func setupTestDB(t *testing.T) (*sql.DB, func()) {
@@ -515,8 +520,6 @@ func TestSQLConversationStore_UpdateConversation(t *testing.T) {
 		t.Fatalf("failed to create conversation: %v", err)
 	}
 
-	// Note: Skip update test as it may use PostgreSQL-specific syntax
-	// This test validates that the conversation was created successfully
 	retrieved, err := store.GetConversation(ctx, conv.ID)
 	if err != nil {
 		t.Fatalf("failed to get conversation: %v", err)
@@ -526,6 +529,21 @@ func TestSQLConversationStore_UpdateConversation(t *testing.T) {
 		t.Errorf("expected title 'Original Title', got '%s'", retrieved.Title)
 	}
 
+	// Update the conversation
+	retrieved.Title = "Updated Title"
+	if err := store.UpdateConversation(ctx, retrieved); err != nil {
+		t.Fatalf("failed to update conversation: %v", err)
+	}
+
+	updated, err := store.GetConversation(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get conversation: %v", err)
+	}
+
+	if updated.Title != "Updated Title" || updated.UserID != "user123" {
+		t.Errorf("expected updated title and unchanged user, got '%s' for '%s'", updated.Title, updated.UserID)
+	}
+
 	// Test error case: updating non-existent conversation
 	nonExistent := &Conversation{
 		ID:     "non-existent-id",
@@ -1027,6 +1045,124 @@ func TestConversationManager_GetConversationContext(t *testing.T) {
 	}
 }
 
+func TestConversationManager_ChatbotConversations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	manager := NewConversationManager(store)
+
+	id, err := manager.StartConversation(ctx, "user123")
+	if err != nil {
+		t.Fatalf("failed to start conversation: %v", err)
+	}
+	if conv, err := store.GetConversation(ctx, id); err != nil || conv.UserID != "user123" {
+		t.Fatalf("expected the conversation to be stored, got %+v, %v", conv, err)
+	}
+
+	replyID, err := manager.AppendExchange(ctx, id, "user123", "Hello", "Hi there")
+	if err != nil {
+		t.Fatalf("failed to append exchange: %v", err)
+	}
+	history, err := manager.RecentMessages(ctx, id, 10)
+	if err != nil {
+		t.Fatalf("failed to get recent messages: %v", err)
+	}
+	if len(history) != 2 || history[0]["role"] != "user" || history[1]["content"] != "Hi there" {
+		t.Errorf("unexpected history: %v", history)
+	}
+	if stored, err := store.GetConversationHistory(ctx, id); err != nil || len(stored) != 2 || stored[1].ID != replyID {
+		t.Errorf("expected the ID of the stored reply %q, got %+v, %v", replyID, stored, err)
+	}
+
+	if _, err := manager.AppendExchangeWithMetadata(ctx, id, "user123", "Hallo", "Hi", map[string]interface{}{"original_language": "de"}, nil); err != nil {
+		t.Fatalf("failed to append exchange: %v", err)
+	}
+	messages, err := store.GetConversationHistory(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(messages) != 4 || messages[2].Metadata["original_language"] != "de" || len(messages[3].Metadata) != 0 {
+		t.Errorf("expected the metadata to be stored with the message, got %+v", messages)
+	}
+
+	// Only the user who started a conversation owns it
+	if err := manager.CheckOwner(ctx, id, "user123"); err != nil {
+		t.Errorf("expected the owner to pass, got %v", err)
+	}
+	if err := manager.CheckOwner(ctx, id, "user456"); !errors.Is(err, ErrNotConversationOwner) || !errors.Is(err, chaterrors.ErrForbidden) {
+		t.Errorf("expected ErrNotConversationOwner, got %v", err)
+	}
+	if err := manager.CheckOwner(ctx, "client-chosen", "user456"); !errors.Is(err, ErrConversationNotFound) || !errors.Is(err, chaterrors.ErrNotFound) {
+		t.Errorf("expected ErrConversationNotFound, got %v", err)
+	}
+	anonymous, err := manager.StartConversation(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to start conversation: %v", err)
+	}
+	if err := manager.CheckOwner(ctx, anonymous, "user456"); err != nil {
+		t.Errorf("expected anyone to continue an anonymous conversation, got %v", err)
+	}
+
+	// An unknown conversation has no messages and is created on append
+	if history, err := manager.RecentMessages(ctx, "client-chosen", 10); err != nil || len(history) != 0 {
+		t.Errorf("expected no messages, got %v, %v", history, err)
+	}
+	if _, err := manager.AppendExchange(ctx, "client-chosen", "user456", "Hello", "Hi"); err != nil {
+		t.Fatalf("failed to append exchange: %v", err)
+	}
+	if conv, err := store.GetConversation(ctx, "client-chosen"); err != nil || conv.UserID != "user456" {
+		t.Errorf("expected the conversation to be created, got %+v, %v", conv, err)
+	}
+
+	if _, err := store.GetConversation(ctx, "missing"); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("expected ErrConversationNotFound, got %v", err)
+	}
+}
+
+func TestConversationManager_State(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	manager := NewConversationManager(store)
+
+	id, err := manager.StartConversation(ctx, "user123")
+	if err != nil {
+		t.Fatalf("failed to start conversation: %v", err)
+	}
+	if state, err := manager.LoadState(ctx, id); err != nil || state != nil {
+		t.Errorf("expected no state, got %v, %v", state, err)
+	}
+
+	cart := map[string]json.RawMessage{"cart": json.RawMessage(`{"items":["book"],"total":12.5}`)}
+	if err := manager.SaveState(ctx, id, cart); err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+	state, err := manager.LoadState(ctx, id)
+	if err != nil || string(state["cart"]) != `{"items":["book"],"total":12.5}` {
+		t.Errorf("expected the state to round trip, got %s, %v", state["cart"], err)
+	}
+
+	if err := manager.SaveState(ctx, id, nil); err != nil {
+		t.Fatalf("failed to clear state: %v", err)
+	}
+	if conv, err := store.GetConversation(ctx, id); err != nil || conv.Metadata["tool_state"] != nil {
+		t.Errorf("expected the state to be removed from the metadata, got %+v, %v", conv, err)
+	}
+	if err := manager.SaveState(ctx, "missing", cart); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("expected ErrConversationNotFound, got %v", err)
+	}
+}
+
 func TestGenerateID(t *testing.T) {
 	// Test that generateID produces IDs
 	id1 := generateID()
@@ -1054,3 +1190,124 @@ func generateTestID() string {
 	// Use UUID for guaranteed uniqueness in tests
 	return uuid.New().String()
 }
+
+func TestSQLConversationStore_QueryMessages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	if err := store.CreateConversation(ctx, &Conversation{ID: "c1", UserID: "u1", Title: "Billing"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	created := time.Now().Add(-time.Hour)
+	for i, content := range []string{"one", "two", "three", "four"} {
+		msg := &Message{ID: content, ConversationID: "c1", Role: "user", Content: content, CreatedAt: created.Add(time.Duration(i) * time.Minute)}
+		if err := store.AddMessages(ctx, []*Message{msg}); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query MessageQuery
+		want  []string
+	}{
+		{MessageQuery{}, []string{"one", "two", "three", "four"}},
+		{MessageQuery{Descending: true}, []string{"four", "three", "two", "one"}},
+		{MessageQuery{Limit: 2, Descending: true}, []string{"four", "three"}},
+		{MessageQuery{Limit: 2, Offset: 1}, []string{"two", "three"}},
+	}
+	for _, tt := range tests {
+		messages, err := store.QueryMessages(ctx, "c1", tt.query)
+		if err != nil {
+			t.Fatalf("failed to query messages: %v", err)
+		}
+		var got []string
+		for _, msg := range messages {
+			got = append(got, msg.Content)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%+v: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	// The last messages are read through the index, without sorting
+	rows, err := db.Query(`EXPLAIN QUERY PLAN SELECT id FROM messages WHERE conversation_id = 'c1' ORDER BY created_at DESC LIMIT 2`)
+	if err != nil {
+		t.Fatalf("failed to explain query: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("failed to scan plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if joined := strings.Join(plan, "; "); !strings.Contains(joined, "idx_messages_conversation_created_at") || strings.Contains(joined, "TEMP B-TREE") {
+		t.Errorf("expected an index-backed plan, got %q", joined)
+	}
+}
+
+func TestConversationManager_GetConversationContextFallback(t *testing.T) {
+	store := &batchStore{}
+	for _, content := range []string{"one", "two", "three"} {
+		if err := store.AddMessage(context.Background(), &Message{ConversationID: "c1", Content: content}); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+
+	// Stores without QueryMessages are read whole
+	messages, err := NewConversationManager(store).GetConversationContext(context.Background(), "c1", 2)
+	if err != nil || len(messages) != 2 || messages[0].Content != "two" || messages[1].Content != "three" {
+		t.Errorf("expected the last 2 messages, got %v, %v", messages, err)
+	}
+}
+
+func TestSQLConversationStore_ScanConversations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	base := time.Now().Add(-time.Hour).UTC()
+	for _, conv := range []struct {
+		id      string
+		updated time.Time
+	}{{"c1", base}, {"c2", base.Add(time.Minute)}, {"c3", base.Add(time.Minute)}, {"c4", base.Add(2 * time.Minute)}} {
+		if err := store.CreateConversation(ctx, &Conversation{ID: conv.id, UserID: "u1"}); err != nil {
+			t.Fatalf("failed to create conversation: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE conversations SET updated_at = ? WHERE id = ?`, conv.updated, conv.id); err != nil {
+			t.Fatalf("failed to set update time: %v", err)
+		}
+	}
+
+	// Pages continue after the last conversation, even among equal times
+	var got []string
+	var after *Conversation
+	for {
+		page, err := store.ScanConversations(ctx, base, base.Add(2*time.Minute), after, 2)
+		if err != nil {
+			t.Fatalf("failed to scan conversations: %v", err)
+		}
+		for _, conv := range page {
+			got = append(got, conv.ID)
+		}
+		if len(page) < 2 {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if strings.Join(got, ",") != "c1,c2,c3" {
+		t.Errorf("expected the conversations updated in the range in order, got %v", got)
+	}
+}
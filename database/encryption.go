@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// encryptedPrefix marks encrypted values: "enc:v1:<key ID>:<base64 nonce and
+// ciphertext>".
+const encryptedPrefix = "enc:v1:"
+
+// EncryptedMetadataKey holds the encrypted metadata of a message in the
+// underlying store.
+const EncryptedMetadataKey = "_encrypted"
+
+// ErrUnknownKey is returned when data was encrypted with a key the
+// KeyProvider no longer has.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// KeyProvider supplies AES keys. Rotating keys means returning a new current
+// key while still providing the old ones for data encrypted with them.
+type KeyProvider interface {
+	// CurrentKey returns the ID and key used to encrypt new data.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key with the given ID, or ErrUnknownKey.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding keys in memory, e.g. loaded from a
+// secret manager at startup.
+type KeyRing struct {
+	current string
+	keys    map[string][]byte
+}
+
+var _ KeyProvider = (*KeyRing)(nil)
+
+// NewKeyRing creates a key ring that encrypts with the key named current.
+// Keys must be 16, 24 or 32 bytes long, selecting AES-128, AES-192 or
+// AES-256. Key IDs cannot contain colons.
+func NewKeyRing(current string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the key ring", current)
+	}
+	ring := &KeyRing{current: current, keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		ring.keys[id] = append([]byte(nil), key...)
+	}
+	return ring, nil
+}
+
+// CurrentKey implements KeyProvider.
+func (r *KeyRing) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return r.current, r.keys[r.current], nil
+}
+
+// Key implements KeyProvider.
+func (r *KeyRing) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// EncryptedStore wraps a ConversationStore, encrypting message content and
+// metadata with AES-GCM before they are stored and decrypting them when
+// read. Each value is bound to its message, so ciphertext copied to another
+// message fails to decrypt. Conversations (titles and metadata) are stored
+// as is, so they can still be listed.
+//
+// Messages stored before encryption was enabled are read unchanged. Content
+// search cannot see encrypted messages; SearchConversations only matches
+// titles.
+type EncryptedStore struct {
+	ConversationStore
+	keys KeyProvider
+}
+
+// NewEncryptedStore wraps store with encryption using keys.
+func NewEncryptedStore(store ConversationStore, keys KeyProvider) *EncryptedStore {
+	return &EncryptedStore{ConversationStore: store, keys: keys}
+}
+
+// AddMessage implements ConversationStore. msg keeps its plaintext; fields
+// set by the underlying store, such as CreatedAt, are copied back to it.
+func (s *EncryptedStore) AddMessage(ctx context.Context, msg *Message) error {
+	stored, err := s.encryptMessage(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if err := s.ConversationStore.AddMessage(ctx, stored); err != nil {
+		return err
+	}
+	msg.ID = stored.ID
+	msg.CreatedAt = stored.CreatedAt
+	return nil
+}
+
+// AddMessages implements MessageBatcher, adding the messages with the
+// underlying store's AddMessages if it has one, or one by one.
+func (s *EncryptedStore) AddMessages(ctx context.Context, messages []*Message) error {
+	batcher, ok := s.ConversationStore.(MessageBatcher)
+	if !ok {
+		return addEach(ctx, s.AddMessage, messages)
+	}
+
+	stored := make([]*Message, len(messages))
+	for i, msg := range messages {
+		encrypted, err := s.encryptMessage(ctx, msg)
+		if err != nil {
+			return err
+		}
+		stored[i] = encrypted
+	}
+	if err := batcher.AddMessages(ctx, stored); err != nil {
+		return err
+	}
+	for i, msg := range messages {
+		msg.ID = stored[i].ID
+		msg.CreatedAt = stored[i].CreatedAt
+	}
+	return nil
+}
+
+// encryptMessage returns a copy of msg with its content and metadata
+// encrypted.
+func (s *EncryptedStore) encryptMessage(ctx context.Context, msg *Message) (*Message, error) {
+	stored := *msg
+	content, err := s.encrypt(ctx, []byte(msg.Content), messageData(msg, "content"))
+	if err != nil {
+		return nil, err
+	}
+	stored.Content = content
+
+	if msg.Metadata != nil {
+		data, err := json.Marshal(msg.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		metadata, err := s.encrypt(ctx, data, messageData(msg, "metadata"))
+		if err != nil {
+			return nil, err
+		}
+		stored.Metadata = map[string]interface{}{EncryptedMetadataKey: metadata}
+	}
+	return &stored, nil
+}
+
+// GetMessages implements ConversationStore.
+func (s *EncryptedStore) GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*Message, error) {
+	messages, err := s.ConversationStore.GetMessages(ctx, conversationID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return messages, s.decryptMessages(ctx, messages)
+}
+
+// QueryMessages implements MessageQuerier.
+func (s *EncryptedStore) QueryMessages(ctx context.Context, conversationID string, query MessageQuery) ([]*Message, error) {
+	messages, err := queryMessages(ctx, s.ConversationStore, conversationID, query)
+	if err != nil {
+		return nil, err
+	}
+	return messages, s.decryptMessages(ctx, messages)
+}
+
+// GetConversationHistory implements ConversationStore.
+func (s *EncryptedStore) GetConversationHistory(ctx context.Context, conversationID string) ([]*Message, error) {
+	messages, err := s.ConversationStore.GetConversationHistory(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return messages, s.decryptMessages(ctx, messages)
+}
+
+// ScanConversations implements ConversationScanner, failing if the store
+// is not a ConversationScanner. Conversations are not encrypted.
+func (s *EncryptedStore) ScanConversations(ctx context.Context, since, until time.Time, after *Conversation, limit int) ([]*Conversation, error) {
+	scanner, ok := s.ConversationStore.(ConversationScanner)
+	if !ok {
+		return nil, fmt.Errorf("%T does not scan conversations", s.ConversationStore)
+	}
+	return scanner.ScanConversations(ctx, since, until, after, limit)
+}
+
+// decryptMessages decrypts messages in place.
+func (s *EncryptedStore) decryptMessages(ctx context.Context, messages []*Message) error {
+	for _, msg := range messages {
+		content, err := s.decrypt(ctx, msg.Content, messageData(msg, "content"))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt message %s: %w", msg.ID, err)
+		}
+		msg.Content = string(content)
+
+		encrypted, ok := msg.Metadata[EncryptedMetadataKey].(string)
+		if !ok {
+			continue
+		}
+		data, err := s.decrypt(ctx, encrypted, messageData(msg, "metadata"))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt message %s: %w", msg.ID, err)
+		}
+		var metadata map[string]interface{}
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		msg.Metadata = metadata
+	}
+	return nil
+}
+
+// messageData is the associated data binding a field's ciphertext to its
+// message.
+func messageData(msg *Message, field string) []byte {
+	return []byte(msg.ConversationID + "/" + msg.ID + "/" + field)
+}
+
+// encrypt seals plaintext with the current key.
+func (s *EncryptedStore) encrypt(ctx context.Context, plaintext, data []byte) (string, error) {
+	id, key, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, data)
+	return encryptedPrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value sealed by encrypt. Values without the encrypted
+// prefix were stored before encryption was enabled and are returned as is.
+func (s *EncryptedStore) decrypt(ctx context.Context, value string, data []byte) ([]byte, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return []byte(value), nil
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return nil, errors.New("malformed encrypted value")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	key, err := s.keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyRing(t *testing.T, current string) *KeyRing {
+	t.Helper()
+	ring, err := NewKeyRing(current, map[string][]byte{
+		"2025": bytes.Repeat([]byte{1}, 32),
+		"2026": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatalf("failed to create key ring: %v", err)
+	}
+	return ring
+}
+
+func TestEncryptedStore(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	inner := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := inner.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	if err := inner.CreateConversation(ctx, &Conversation{ID: "c1", UserID: "u1", Title: "Billing"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	// Written before encryption was enabled
+	if err := inner.AddMessage(ctx, &Message{ID: "m0", ConversationID: "c1", Role: "user", Content: "plain"}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+
+	store := NewEncryptedStore(inner, testKeyRing(t, "2025"))
+	msg := &Message{ID: "m1", ConversationID: "c1", Role: "user", Content: "My card is 4111", Metadata: map[string]interface{}{"ip": "10.0.0.1"}}
+	if err := store.AddMessage(ctx, msg); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	if msg.Content != "My card is 4111" || msg.CreatedAt.IsZero() {
+		t.Errorf("expected caller's message to keep its plaintext, got %+v", msg)
+	}
+
+	var content, metadata string
+	if err := db.QueryRow("SELECT content, metadata FROM messages WHERE id = 'm1'").Scan(&content, &metadata); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+	if !strings.HasPrefix(content, "enc:v1:2025:") || strings.Contains(metadata, "10.0.0.1") {
+		t.Errorf("expected encrypted row, got content %q metadata %q", content, metadata)
+	}
+
+	// Rotate: new messages use the new key, old ones still decrypt
+	store = NewEncryptedStore(inner, testKeyRing(t, "2026"))
+	if err := store.AddMessage(ctx, &Message{ID: "m2", ConversationID: "c1", Role: "assistant", Content: "Thanks"}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+
+	messages, err := store.GetConversationHistory(ctx, "c1")
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(messages) != 3 || messages[0].Content != "plain" || messages[1].Content != "My card is 4111" || messages[2].Content != "Thanks" {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	if messages[1].Metadata["ip"] != "10.0.0.1" {
+		t.Errorf("expected decrypted metadata, got %v", messages[1].Metadata)
+	}
+
+	// Keys that were dropped can no longer decrypt
+	ring, _ := NewKeyRing("2026", map[string][]byte{"2026": bytes.Repeat([]byte{2}, 32)})
+	if _, err := NewEncryptedStore(inner, ring).GetMessages(ctx, "c1", 10, 0); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestEncryptedStore_BindsCiphertextToMessage(t *testing.T) {
+	store := NewEncryptedStore(nil, testKeyRing(t, "2026"))
+	ctx := context.Background()
+
+	msg := &Message{ID: "m1", ConversationID: "c1"}
+	sealed, err := store.encrypt(ctx, []byte("secret"), messageData(msg, "content"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if plaintext, err := store.decrypt(ctx, sealed, messageData(msg, "content")); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("expected round trip, got %q, %v", plaintext, err)
+	}
+	other := &Message{ID: "m2", ConversationID: "c1"}
+	if _, err := store.decrypt(ctx, sealed, messageData(other, "content")); err == nil {
+		t.Error("expected ciphertext moved to another message to fail")
+	}
+}
+
+func TestNewKeyRing_Errors(t *testing.T) {
+	tests := map[string]struct {
+		current string
+		keys    map[string][]byte
+	}{
+		"missing current": {"b", map[string][]byte{"a": make([]byte, 32)}},
+		"short key":       {"a", map[string][]byte{"a": make([]byte, 10)}},
+		"colon in ID":     {"a:1", map[string][]byte{"a:1": make([]byte, 32)}},
+	}
+	for name, test := range tests {
+		if _, err := NewKeyRing(test.current, test.keys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportResult reports what an import stored.
+type ImportResult struct {
+	// ConversationIDs are the IDs of the conversations created.
+	ConversationIDs []string
+	// Messages is the number of messages added.
+	Messages int
+	// Skipped counts conversations already in the store, which an import
+	// leaves alone so it can be run again, and those without messages.
+	Skipped int
+}
+
+// ImportOption configures ImportChatGPT and ImportOpenAIMessages.
+type ImportOption func(*importer)
+
+// WithImportUser sets the user the imported conversations belong to, since
+// exports do not name one.
+func WithImportUser(userID string) ImportOption {
+	return func(im *importer) {
+		im.userID = userID
+	}
+}
+
+// WithImportTitle sets the title of the conversation created by
+// ImportOpenAIMessages.
+func WithImportTitle(title string) ImportOption {
+	return func(im *importer) {
+		im.title = title
+	}
+}
+
+// importer writes imported conversations to a store.
+type importer struct {
+	store  ConversationStore
+	userID string
+	title  string
+	result ImportResult
+}
+
+func newImporter(store ConversationStore, opts []ImportOption) *importer {
+	im := &importer{store: store}
+	for _, opt := range opts {
+		opt(im)
+	}
+	return im
+}
+
+// ImportChatGPT stores the conversations of a ChatGPT data export, read
+// from its conversations.json, keeping their IDs, titles and message times.
+// Of each conversation the branch shown last is imported, with the visible
+// user and assistant messages; system prompts, tool calls and their output
+// are left out, and so are images and files. Conversations already in the
+// store are skipped.
+//
+// The export is read one conversation at a time, so large exports do not
+// have to fit in memory. The conversations imported before an error stay
+// in the store. Message times are kept by stores implementing
+// MessageBatcher, such as SQLConversationStore.
+func ImportChatGPT(ctx context.Context, store ConversationStore, r io.Reader, opts ...ImportOption) (*ImportResult, error) {
+	im := newImporter(store, opts)
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return &im.result, errors.New("failed to decode ChatGPT export: expected an array of conversations")
+	}
+	for decoder.More() {
+		if err := ctx.Err(); err != nil {
+			return &im.result, err
+		}
+		var export chatGPTConversation
+		if err := decoder.Decode(&export); err != nil {
+			return &im.result, fmt.Errorf("failed to decode ChatGPT export: %w", err)
+		}
+		conv, messages := export.convert()
+		if err := im.add(ctx, conv, messages); err != nil {
+			return &im.result, err
+		}
+	}
+	return &im.result, nil
+}
+
+// ImportOpenAIMessages stores an OpenAI messages array, such as the
+// "messages" of a chat completions request, as a new conversation. The
+// array may be given as is or in an object under "messages". Content may be
+// a string or an array of parts, whose text parts are kept. Only user and
+// assistant messages are imported.
+func ImportOpenAIMessages(ctx context.Context, store ConversationStore, r io.Reader, opts ...ImportOption) (*ImportResult, error) {
+	im := newImporter(store, opts)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return &im.result, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	var request struct {
+		Messages []openAIMessage `json:"messages"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &request.Messages)
+	} else {
+		err = json.Unmarshal(trimmed, &request)
+	}
+	if err != nil {
+		return &im.result, fmt.Errorf("failed to decode messages: %w", err)
+	}
+
+	conv := &Conversation{
+		ID:       uuid.New().String(),
+		Title:    im.title,
+		Metadata: map[string]interface{}{"imported_from": "openai"},
+	}
+	var messages []*Message
+	at := time.Now()
+	for _, msg := range request.Messages {
+		text := msg.text()
+		if (msg.Role != "user" && msg.Role != "assistant") || text == "" {
+			continue
+		}
+		// Keep the order at the database's microsecond precision
+		at = at.Add(time.Microsecond)
+		messages = append(messages, &Message{
+			ID:             generateID(),
+			ConversationID: conv.ID,
+			Role:           msg.Role,
+			Content:        text,
+			Metadata:       make(map[string]interface{}),
+			CreatedAt:      at,
+		})
+	}
+	if err := im.add(ctx, conv, messages); err != nil {
+		return &im.result, err
+	}
+	return &im.result, nil
+}
+
+// add stores a conversation with its messages unless it exists or has no
+// messages. If adding the messages fails, the conversation is deleted, so
+// running the import again retries it.
+func (im *importer) add(ctx context.Context, conv *Conversation, messages []*Message) error {
+	if len(messages) == 0 {
+		im.result.Skipped++
+		return nil
+	}
+	if _, err := im.store.GetConversation(ctx, conv.ID); err == nil {
+		im.result.Skipped++
+		return nil
+	} else if !errors.Is(err, ErrConversationNotFound) {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	conv.UserID = im.userID
+	if err := im.store.CreateConversation(ctx, conv); err != nil {
+		return fmt.Errorf("failed to create conversation: %w", err)
+	}
+	var err error
+	if batcher, ok := im.store.(MessageBatcher); ok {
+		err = batcher.AddMessages(ctx, messages)
+	} else {
+		err = addEach(ctx, im.store.AddMessage, messages)
+	}
+	if err != nil {
+		_ = im.store.DeleteConversation(ctx, conv.ID)
+		return fmt.Errorf("failed to import conversation %s: %w", conv.ID, err)
+	}
+
+	im.result.ConversationIDs = append(im.result.ConversationIDs, conv.ID)
+	im.result.Messages += len(messages)
+	return nil
+}
+
+// chatGPTConversation is a conversation of a ChatGPT export. Its messages
+// form a tree, branching where a message was edited or a reply
+// regenerated.
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+	CurrentNode    string                 `json:"current_node"`
+}
+
+type chatGPTNode struct {
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatGPTMessage struct {
+	ID     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Recipient string `json:"recipient"`
+	Metadata  struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// convert returns the conversation and the messages of its current branch.
+func (c *chatGPTConversation) convert() (*Conversation, []*Message) {
+	conv := &Conversation{
+		ID:       c.ConversationID,
+		Title:    c.Title,
+		Metadata: map[string]interface{}{"imported_from": "chatgpt"},
+	}
+	if conv.ID == "" {
+		conv.ID = c.ID
+	}
+	if conv.ID == "" {
+		conv.ID = uuid.New().String()
+	}
+	started := unixSeconds(c.CreateTime)
+	if !started.IsZero() {
+		conv.Metadata["imported_created_at"] = started.Format(time.RFC3339)
+	} else {
+		started = time.Now()
+	}
+
+	var messages []*Message
+	at := started
+	for _, msg := range c.branch() {
+		text := msg.text()
+		role := msg.Author.Role
+		if (role != "user" && role != "assistant") || text == "" || msg.Metadata.Hidden ||
+			(msg.Recipient != "" && msg.Recipient != "all") {
+			continue
+		}
+		// Keep the branch order even where times are missing or equal
+		if created := unixSeconds(msg.CreateTime); created.After(at) {
+			at = created
+		} else {
+			at = at.Add(time.Microsecond)
+		}
+		metadata := make(map[string]interface{})
+		if msg.Metadata.ModelSlug != "" {
+			metadata["model"] = msg.Metadata.ModelSlug
+		}
+		id := msg.ID
+		if id == "" {
+			id = generateID()
+		}
+		messages = append(messages, &Message{
+			ID:             id,
+			ConversationID: conv.ID,
+			Role:           role,
+			Content:        text,
+			Metadata:       metadata,
+			CreatedAt:      at,
+		})
+	}
+	return conv, messages
+}
+
+// branch returns the messages from the root to the current node, or to the
+// newest leaf if the export names none.
+func (c *chatGPTConversation) branch() []*chatGPTMessage {
+	id := c.CurrentNode
+	if _, ok := c.Mapping[id]; !ok {
+		id = c.newestLeaf()
+	}
+
+	var path []*chatGPTMessage
+	seen := make(map[string]bool)
+	for id != "" && !seen[id] {
+		seen[id] = true
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if node.Message != nil {
+			path = append(path, node.Message)
+		}
+		id = node.Parent
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// newestLeaf follows the last child from the root, the newest edit or
+// regeneration at each branch.
+func (c *chatGPTConversation) newestLeaf() string {
+	var roots []string
+	for id, node := range c.Mapping {
+		if node.Parent == "" {
+			roots = append(roots, id)
+		}
+	}
+	if len(roots) == 0 {
+		return ""
+	}
+	sort.Strings(roots)
+
+	id := roots[0]
+	seen := make(map[string]bool)
+	for !seen[id] {
+		seen[id] = true
+		children := c.Mapping[id].Children
+		if len(children) == 0 {
+			break
+		}
+		id = children[len(children)-1]
+	}
+	return id
+}
+
+// text returns the text parts of the message, skipping images and files.
+func (m *chatGPTMessage) text() string {
+	if m.Content.ContentType != "text" && m.Content.ContentType != "multimodal_text" {
+		return ""
+	}
+	var parts []string
+	for _, raw := range m.Content.Parts {
+		var part string
+		if json.Unmarshal(raw, &part) == nil && part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// openAIMessage is a message of an OpenAI messages array.
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the message content, joining the text of content parts.
+func (m *openAIMessage) text() string {
+	var content string
+	if json.Unmarshal(m.Content, &content) == nil {
+		return strings.TrimSpace(content)
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(m.Content, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
+
+// unixSeconds converts fractional Unix seconds, as in ChatGPT exports, to a
+// time, zero for 0.
+func unixSeconds(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole := int64(seconds)
+	return time.Unix(whole, int64((seconds-float64(whole))*1e9)).UTC()
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// chatGPTExport has a conversation whose first message was edited, so the
+// tree has two branches, with a hidden system message and a tool call.
+const chatGPTExport = `[{
+	"title": "Trip to Sofia",
+	"create_time": 1700000000.5,
+	"conversation_id": "chatgpt-1",
+	"current_node": "a2",
+	"mapping": {
+		"root": {"message": null, "parent": null, "children": ["sys"]},
+		"sys": {"message": {"id": "sys", "author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]},
+			"metadata": {"is_visually_hidden_from_conversation": true}}, "parent": "root", "children": ["u1", "u1b"]},
+		"u1": {"message": {"id": "u1", "author": {"role": "user"}, "create_time": 1700000001,
+			"content": {"content_type": "text", "parts": ["Where should I eat?"]}}, "parent": "sys", "children": ["a1"]},
+		"a1": {"message": {"id": "a1", "author": {"role": "assistant"}, "create_time": 1700000002,
+			"content": {"content_type": "text", "parts": ["Try Shtastliveca."]}}, "parent": "u1", "children": []},
+		"u1b": {"message": {"id": "u1b", "author": {"role": "user"}, "create_time": 1700000010,
+			"content": {"content_type": "multimodal_text", "parts": [{"content_type": "image_asset_pointer"}, "What should I see?"]}},
+			"parent": "sys", "children": ["tool"]},
+		"tool": {"message": {"id": "tool", "author": {"role": "assistant"}, "recipient": "browser",
+			"content": {"content_type": "code", "text": "search(\"Sofia sights\")"}}, "parent": "u1b", "children": ["a2"]},
+		"a2": {"message": {"id": "a2", "author": {"role": "assistant"}, "create_time": null,
+			"content": {"content_type": "text", "parts": ["Visit the Alexander Nevsky Cathedral."]},
+			"metadata": {"model_slug": "gpt-4o"}}, "parent": "tool", "children": []}
+	}
+}, {"title": "Empty", "conversation_id": "chatgpt-2", "mapping": {}}]`
+
+func TestImportChatGPT(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+
+	result, err := ImportChatGPT(ctx, store, strings.NewReader(chatGPTExport), WithImportUser("u1"))
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if len(result.ConversationIDs) != 1 || result.ConversationIDs[0] != "chatgpt-1" || result.Messages != 2 || result.Skipped != 1 {
+		t.Fatalf("expected one conversation with 2 messages and the empty one skipped, got %+v", result)
+	}
+
+	conv, err := store.GetConversation(ctx, "chatgpt-1")
+	if err != nil || conv.Title != "Trip to Sofia" || conv.UserID != "u1" || conv.Metadata["imported_from"] != "chatgpt" {
+		t.Fatalf("expected the conversation with its title and user, got %+v, %v", conv, err)
+	}
+	history, err := store.GetConversationHistory(ctx, "chatgpt-1")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("expected the current branch of 2 messages, got %d, %v", len(history), err)
+	}
+	if history[0].Content != "What should I see?" || history[0].CreatedAt.Unix() != 1700000010 {
+		t.Errorf("expected the edited message with its time, got %q at %v", history[0].Content, history[0].CreatedAt)
+	}
+	if history[1].Role != "assistant" || history[1].Metadata["model"] != "gpt-4o" || !history[1].CreatedAt.After(history[0].CreatedAt) {
+		t.Errorf("expected the reply after the message, got %+v", history[1])
+	}
+
+	// Imports can be run again
+	result, err = ImportChatGPT(ctx, store, strings.NewReader(chatGPTExport))
+	if err != nil || len(result.ConversationIDs) != 0 || result.Skipped != 2 {
+		t.Errorf("expected the imported conversation to be skipped, got %+v, %v", result, err)
+	}
+
+	if _, err := ImportChatGPT(ctx, store, strings.NewReader(`{"mapping": {}}`)); err == nil {
+		t.Error("expected an error for an export that is not an array")
+	}
+}
+
+func TestImportOpenAIMessages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+
+	for _, input := range []string{
+		`[{"role": "system", "content": "Be brief."}, {"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello!"}]`,
+		`{"model": "gpt-4o", "messages": [{"role": "user", "content": [{"type": "text", "text": "Hi"}, {"type": "image_url"}]},
+			{"role": "assistant", "content": "Hello!"}, {"role": "tool", "content": "{}"}]}`,
+	} {
+		result, err := ImportOpenAIMessages(ctx, store, strings.NewReader(input), WithImportTitle("Greeting"))
+		if err != nil || len(result.ConversationIDs) != 1 || result.Messages != 2 {
+			t.Fatalf("expected a conversation with 2 messages, got %+v, %v", result, err)
+		}
+		history, err := store.GetConversationHistory(ctx, result.ConversationIDs[0])
+		if err != nil || len(history) != 2 || history[0].Content != "Hi" || history[1].Content != "Hello!" {
+			t.Errorf("expected the user and assistant messages in order, got %v, %v", history, err)
+		}
+	}
+}
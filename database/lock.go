@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Locker serializes work on a conversation, so concurrent requests for the
+// same conversation take turns instead of interleaving their messages.
+type Locker interface {
+	// Lock blocks until the conversation's lock is held or ctx is done. The
+	// returned function releases the lock; calling it more than once is a
+	// no-op.
+	Lock(ctx context.Context, conversationID string) (func(), error)
+}
+
+// WithLocker sets the locker used by ConversationManager.Lock, which a
+// chatbot given the manager with WithConversations takes for every turn.
+// Use a MemoryLocker for a single process and a RedisLocker when several
+// instances serve the same conversations.
+func WithLocker(locker Locker) ManagerOption {
+	return func(cm *ConversationManager) {
+		cm.locker = locker
+	}
+}
+
+// Lock acquires the conversation's lock for a turn. Hold it from reading the
+// history until the reply is stored:
+//
+//	unlock, err := cm.Lock(ctx, conversationID)
+//	if err != nil {
+//		return err
+//	}
+//	defer unlock()
+//
+// Without a locker it returns immediately.
+func (cm *ConversationManager) Lock(ctx context.Context, conversationID string) (func(), error) {
+	if cm.locker == nil {
+		return func() {}, nil
+	}
+	unlock, err := cm.locker.Lock(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock conversation: %w", err)
+	}
+	return unlock, nil
+}
+
+// MemoryLocker is a Locker for a single process. Waiters acquire the lock
+// in the order they asked for it.
+type MemoryLocker struct {
+	mutex sync.Mutex
+	locks map[string]*memoryLock
+}
+
+// memoryLock is held while its channel is full. refs counts the holder and
+// waiters, so idle locks can be dropped.
+type memoryLock struct {
+	held chan struct{}
+	refs int
+}
+
+var _ Locker = (*MemoryLocker)(nil)
+
+// NewMemoryLocker creates an in-memory locker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]*memoryLock)}
+}
+
+// Lock implements Locker.
+func (l *MemoryLocker) Lock(ctx context.Context, conversationID string) (func(), error) {
+	l.mutex.Lock()
+	lock, ok := l.locks[conversationID]
+	if !ok {
+		lock = &memoryLock{held: make(chan struct{}, 1)}
+		l.locks[conversationID] = lock
+	}
+	lock.refs++
+	l.mutex.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+	case <-ctx.Done():
+		l.release(conversationID, lock)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-lock.held
+			l.release(conversationID, lock)
+		})
+	}, nil
+}
+
+// release drops a reference to the lock, forgetting it when unused.
+func (l *MemoryLocker) release(conversationID string, lock *memoryLock) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, conversationID)
+	}
+}
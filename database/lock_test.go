@@ -0,0 +1,328 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockerTurns runs concurrent turns on one conversation and reports whether
+// any of them overlapped.
+func lockerTurns(t *testing.T, locker Locker) {
+	t.Helper()
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		active  int
+		overlap bool
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := locker.Lock(context.Background(), "conv")
+			if err != nil {
+				t.Errorf("failed to lock: %v", err)
+				return
+			}
+			defer unlock()
+
+			mutex.Lock()
+			active++
+			overlap = overlap || active > 1
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mutex.Lock()
+			active--
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+	if overlap {
+		t.Error("turns on the same conversation overlapped")
+	}
+}
+
+func TestMemoryLocker(t *testing.T) {
+	locker := NewMemoryLocker()
+	lockerTurns(t, locker)
+
+	unlock, err := locker.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	// Other conversations are not blocked
+	other, err := locker.Lock(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("failed to lock other conversation: %v", err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	unlock()
+	unlock()
+	if len(locker.locks) != 0 {
+		t.Errorf("expected idle locks to be dropped, got %d", len(locker.locks))
+	}
+}
+
+func TestMemoryLocker_Order(t *testing.T) {
+	locker := NewMemoryLocker()
+	unlock, _ := locker.Lock(context.Background(), "conv")
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		order []int
+	)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(turn int) {
+			defer wg.Done()
+			release, err := locker.Lock(context.Background(), "conv")
+			if err != nil {
+				t.Errorf("failed to lock: %v", err)
+				return
+			}
+			mutex.Lock()
+			order = append(order, turn)
+			mutex.Unlock()
+			release()
+		}(i)
+		// Let each waiter queue up before the next
+		time.Sleep(10 * time.Millisecond)
+	}
+	unlock()
+	wg.Wait()
+
+	if fmt.Sprint(order) != "[0 1 2]" {
+		t.Errorf("expected turns in arrival order, got %v", order)
+	}
+}
+
+func TestConversationManager_Lock(t *testing.T) {
+	ctx := context.Background()
+
+	unlock, err := NewConversationManager(nil).Lock(ctx, "conv")
+	if err != nil {
+		t.Fatalf("expected no-op lock without a locker, got %v", err)
+	}
+	unlock()
+
+	manager := NewConversationManager(nil, WithLocker(NewMemoryLocker()))
+	unlock, err = manager.Lock(ctx, "conv")
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := manager.Lock(ctx, "conv"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+// fakeLockRedis implements the Redis commands the locker uses, treating
+// EVAL scripts by whether they delete or extend the key.
+type fakeLockRedis struct {
+	listener net.Listener
+	password string
+
+	mutex    sync.Mutex
+	keys     map[string]string
+	ttls     map[string]string
+	commands []string
+}
+
+func newFakeLockRedis(t *testing.T, password string) *fakeLockRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeLockRedis{
+		listener: listener,
+		password: password,
+		keys:     make(map[string]string),
+		ttls:     make(map[string]string),
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeLockRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authed = args[len(args)-1] == s.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = s.exec(args)
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeLockRedis) exec(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands = append(s.commands, args[0])
+
+	switch args[0] {
+	case "SET":
+		if _, ok := s.keys[args[1]]; ok {
+			return "$-1\r\n"
+		}
+		s.keys[args[1]] = args[2]
+		s.ttls[args[1]] = args[5]
+		return "+OK\r\n"
+	case "EVAL":
+		key, token := args[3], args[4]
+		if s.keys[key] != token {
+			return ":0\r\n"
+		}
+		if strings.Contains(args[1], "pexpire") {
+			s.ttls[key] = args[5]
+		} else {
+			delete(s.keys, key)
+		}
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func (s *fakeLockRedis) count(command string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := 0
+	for _, c := range s.commands {
+		if c == command {
+			n++
+		}
+	}
+	return n
+}
+
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisLocker(t *testing.T) {
+	server := newFakeLockRedis(t, "secret")
+	locker, err := NewRedisLocker(RedisLockerConfig{
+		URL:           "redis://:secret@" + server.listener.Addr().String(),
+		TTL:           30 * time.Millisecond,
+		RetryInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create locker: %v", err)
+	}
+	defer locker.Close()
+
+	lockerTurns(t, locker)
+
+	unlock, err := locker.Lock(context.Background(), "conv")
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	server.mutex.Lock()
+	token := server.keys["chatbot:locks:conv"]
+	ttl := server.ttls["chatbot:locks:conv"]
+	server.mutex.Unlock()
+	if token == "" || ttl != "30" {
+		t.Fatalf("expected leased lock key, got token %q ttl %q", token, ttl)
+	}
+
+	// The lease is renewed while held
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, "conv"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	unlock()
+	unlock()
+
+	server.mutex.Lock()
+	_, held := server.keys["chatbot:locks:conv"]
+	server.mutex.Unlock()
+	if held {
+		t.Error("expected lock key to be deleted")
+	}
+	if server.count("EVAL") < 2 {
+		t.Errorf("expected renewals and a release, got %d EVAL commands", server.count("EVAL"))
+	}
+
+	_ = locker.Close()
+	if _, err := locker.Lock(context.Background(), "conv"); !errors.Is(err, ErrLockerClosed) {
+		t.Errorf("expected ErrLockerClosed, got %v", err)
+	}
+}
+
+func TestNewRedisLocker_InvalidURL(t *testing.T) {
+	for _, raw := range []string{"localhost:6379", "http://localhost"} {
+		if _, err := NewRedisLocker(RedisLockerConfig{URL: raw}); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/events"
+)
+
+// ErrOutboxUnsupported is returned when storing events with messages in a
+// store without an event outbox.
+var ErrOutboxUnsupported = errors.New("conversation store has no event outbox")
+
+// OutboxWriter is a ConversationStore that writes events to an outbox in
+// the same transaction as messages, so the events of stored messages are
+// never lost. An OutboxRelay publishes them afterwards.
+type OutboxWriter interface {
+	// AddMessagesWithEvents adds messages like AddMessages and the events
+	// to the outbox, all or none of them.
+	AddMessagesWithEvents(ctx context.Context, messages []*Message, evts []events.Event) error
+}
+
+var (
+	_ OutboxWriter = (*SQLConversationStore)(nil)
+	_ OutboxWriter = (*InstrumentedStore)(nil)
+)
+
+// AddMessagesWithEvents implements OutboxWriter.
+func (s *SQLConversationStore) AddMessagesWithEvents(ctx context.Context, messages []*Message, evts []events.Event) error {
+	s.recordMessages(messages)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertMessages(ctx, tx, messages); err != nil {
+		return err
+	}
+	for _, event := range evts {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		query := "INSERT INTO event_outbox (id, payload, created_at) VALUES ($1, $2, $3)"
+		if _, err := tx.ExecContext(ctx, query, event.ID, string(payload), event.Time.UTC()); err != nil {
+			return fmt.Errorf("failed to add event to the outbox: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit messages: %w", err)
+	}
+	s.embedNew(ctx, messages)
+	return nil
+}
+
+// outboxEvents returns the oldest limit events of the outbox.
+func (s *SQLConversationStore) outboxEvents(ctx context.Context, limit int) ([]events.Event, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT payload FROM event_outbox ORDER BY created_at, id LIMIT $1", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the event outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var outbox []events.Event
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		var event events.Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		outbox = append(outbox, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate events: %w", err)
+	}
+	return outbox, nil
+}
+
+// deleteOutboxEvents removes published events from the outbox.
+func (s *SQLConversationStore) deleteOutboxEvents(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	query := "DELETE FROM event_outbox WHERE id IN (" + strings.Join(placeholders, ", ") + ")"
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete published events: %w", err)
+	}
+	return nil
+}
+
+// AddMessagesWithEvents implements OutboxWriter, failing with
+// ErrOutboxUnsupported if the store is not an OutboxWriter.
+func (s *InstrumentedStore) AddMessagesWithEvents(ctx context.Context, messages []*Message, evts []events.Event) error {
+	writer, ok := s.store.(OutboxWriter)
+	if !ok {
+		return ErrOutboxUnsupported
+	}
+	started := s.now()
+	err := writer.AddMessagesWithEvents(ctx, messages, evts)
+	s.observe("AddMessagesWithEvents", started, err)
+	return err
+}
+
+// AppendExchangeWithEvents is AppendExchangeWithMetadata also writing
+// events, such as the message received and reply generated events of the
+// exchange, to the store's outbox in the same transaction. It fails with
+// ErrOutboxUnsupported, storing nothing, if the store is not an
+// OutboxWriter or an InstrumentedStore wrapping one.
+func (cm *ConversationManager) AppendExchangeWithEvents(ctx context.Context, conversationID, userID, message, reply string, messageMetadata, replyMetadata map[string]interface{}, evts []events.Event) (string, error) {
+	if !hasOutbox(cm.store) {
+		return "", ErrOutboxUnsupported
+	}
+	if err := cm.ensureConversation(ctx, conversationID, userID); err != nil {
+		return "", err
+	}
+
+	// The reply sorts after the message even at microsecond precision
+	now := time.Now()
+	messages := []*Message{
+		{ID: generateID(), ConversationID: conversationID, Role: "user", Content: message, Metadata: messageMetadata, CreatedAt: now},
+		{ID: generateID(), ConversationID: conversationID, Role: "assistant", Content: reply, Metadata: replyMetadata, CreatedAt: now.Add(time.Microsecond)},
+	}
+	for _, msg := range messages {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]interface{})
+		}
+	}
+	if err := cm.store.(OutboxWriter).AddMessagesWithEvents(ctx, messages, evts); err != nil {
+		return "", fmt.Errorf("failed to add exchange: %w", err)
+	}
+	return messages[1].ID, nil
+}
+
+// hasOutbox reports whether store writes events with messages, looking
+// through the stores passing them on.
+func hasOutbox(store ConversationStore) bool {
+	switch s := store.(type) {
+	case *InstrumentedStore:
+		return hasOutbox(s.store)
+	case OutboxWriter:
+		return true
+	}
+	return false
+}
+
+// Defaults of an OutboxRelay.
+const (
+	DefaultRelayInterval  = time.Second
+	DefaultRelayBatchSize = 100
+)
+
+// relayTimeout bounds each background relay.
+const relayTimeout = 30 * time.Second
+
+// RelayOption configures NewOutboxRelay.
+type RelayOption func(*OutboxRelay)
+
+// WithRelayInterval sets how often the outbox is checked for events.
+func WithRelayInterval(interval time.Duration) RelayOption {
+	return func(r *OutboxRelay) {
+		r.interval = interval
+	}
+}
+
+// WithRelayBatchSize sets how many events are read from the outbox at once.
+func WithRelayBatchSize(size int) RelayOption {
+	return func(r *OutboxRelay) {
+		r.batchSize = size
+	}
+}
+
+// WithRelayErrorHandler sets a function called when a background relay
+// fails. By default errors are discarded; the events stay in the outbox and
+// are retried with the next relay either way.
+func WithRelayErrorHandler(fn func(err error)) RelayOption {
+	return func(r *OutboxRelay) {
+		r.onError = fn
+	}
+}
+
+// OutboxRelay publishes the events in the outbox of a SQLConversationStore
+// from a background goroutine, oldest first, deleting them once published.
+// Delivery is at least once: an event published just before a crash, or by
+// two relays sharing an outbox, is published again, so consumers should
+// skip event IDs they have seen. An event that fails to publish holds back
+// the ones after it until it succeeds, keeping their order.
+type OutboxRelay struct {
+	store     *SQLConversationStore
+	publisher events.Publisher
+	interval  time.Duration
+	batchSize int
+	onError   func(err error)
+
+	relaying sync.Mutex
+	once     sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewOutboxRelay starts relaying the outbox of store to publisher.
+func NewOutboxRelay(store *SQLConversationStore, publisher events.Publisher, opts ...RelayOption) *OutboxRelay {
+	r := &OutboxRelay{
+		store:     store,
+		publisher: publisher,
+		interval:  DefaultRelayInterval,
+		batchSize: DefaultRelayBatchSize,
+		onError:   func(error) {},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.batchSize < 1 {
+		r.batchSize = 1
+	}
+
+	go r.run()
+	return r
+}
+
+// Relay publishes the events in the outbox until it is empty or publishing
+// fails, returning how many were published.
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	r.relaying.Lock()
+	defer r.relaying.Unlock()
+
+	published := 0
+	for {
+		batch, err := r.store.outboxEvents(ctx, r.batchSize)
+		if err != nil || len(batch) == 0 {
+			return published, err
+		}
+
+		ids := make([]string, 0, len(batch))
+		var publishErr error
+		for _, event := range batch {
+			if publishErr = r.publisher.Publish(ctx, event); publishErr != nil {
+				publishErr = fmt.Errorf("failed to publish event %s: %w", event.ID, publishErr)
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+		if err := r.store.deleteOutboxEvents(ctx, ids); err != nil {
+			return published, err
+		}
+		published += len(ids)
+		if publishErr != nil {
+			return published, publishErr
+		}
+	}
+}
+
+// Close stops relaying in the background and relays the events left in the
+// outbox. It does not close the publisher.
+func (r *OutboxRelay) Close() error {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+	_, err := r.Relay(ctx)
+	return err
+}
+
+// run relays the outbox every interval until Close.
+func (r *OutboxRelay) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+		if _, err := r.Relay(ctx); err != nil {
+			r.onError(err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/events"
+)
+
+// recordingPublisher keeps the events published, failing while err is set.
+type recordingPublisher struct {
+	published []events.Event
+	err       error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestConversationManager_AppendExchangeWithEvents(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	manager := NewConversationManager(NewInstrumentedStore(store))
+
+	received := events.New(events.MessageReceived, map[string]interface{}{"message": "Hi"})
+	replied := events.New(events.ReplyGenerated, map[string]interface{}{"reply": "Hello"})
+	replied.Time = received.Time.Add(time.Millisecond)
+	if _, err := manager.AppendExchangeWithEvents(ctx, "c1", "u1", "Hi", "Hello", nil, nil, []events.Event{received, replied}); err != nil {
+		t.Fatalf("failed to append exchange: %v", err)
+	}
+	history, err := manager.RecentMessages(ctx, "c1", 10)
+	if err != nil || len(history) != 2 || history[0]["role"] != "user" || history[1]["role"] != "assistant" {
+		t.Fatalf("expected the exchange in order, got %v, %v", history, err)
+	}
+
+	// A failing exchange leaves no events behind
+	duplicate := events.New(events.MessageReceived, nil)
+	duplicate.ID = received.ID
+	if _, err := manager.AppendExchangeWithEvents(ctx, "c1", "u1", "Hi", "Hello", nil, nil, []events.Event{duplicate}); err == nil {
+		t.Fatal("expected an error for a duplicate event")
+	}
+	if history, _ := manager.RecentMessages(ctx, "c1", 10); len(history) != 2 {
+		t.Errorf("expected the failed exchange to be rolled back, got %d messages", len(history))
+	}
+
+	publisher := &recordingPublisher{err: errors.New("broker unavailable")}
+	relay := NewOutboxRelay(store, publisher, WithRelayInterval(time.Hour))
+	if n, err := relay.Relay(ctx); err == nil || n != 0 {
+		t.Fatalf("expected the publish error, got %d published, %v", n, err)
+	}
+	publisher.err = nil
+	if err := relay.Close(); err != nil {
+		t.Fatalf("failed to close relay: %v", err)
+	}
+	if len(publisher.published) != 2 || publisher.published[0].ID != received.ID || publisher.published[1].Data["reply"] != "Hello" {
+		t.Errorf("expected the events published in order, got %+v", publisher.published)
+	}
+	if n, err := relay.Relay(ctx); err != nil || n != 0 {
+		t.Errorf("expected published events to leave the outbox, got %d published, %v", n, err)
+	}
+}
+
+func TestConversationManager_AppendExchangeWithEventsUnsupported(t *testing.T) {
+	manager := NewConversationManager(NewInstrumentedStore(&batchStore{}))
+	_, err := manager.AppendExchangeWithEvents(context.Background(), "c1", "u1", "Hi", "Hello", nil, nil, nil)
+	if !errors.Is(err, ErrOutboxUnsupported) {
+		t.Errorf("expected ErrOutboxUnsupported, got %v", err)
+	}
+}
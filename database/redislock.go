@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/internal/redis"
+)
+
+// releaseScript deletes the lock only if the caller still holds it.
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// renewScript extends the lease only if the caller still holds it.
+const renewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+// ErrLockerClosed is returned by RedisLocker.Lock after Close.
+var ErrLockerClosed = errors.New("locker closed")
+
+// RedisLockerConfig configures the Redis locker.
+type RedisLockerConfig struct {
+	// URL is the server address, e.g. "redis://:password@localhost:6379/0".
+	// Use the "rediss" scheme for TLS connections.
+	URL string
+	// KeyPrefix namespaces the lock keys (default "chatbot:locks").
+	KeyPrefix string
+	// TTL is the lease on a lock (default 30s). It is renewed while the lock
+	// is held, so it only matters when a holder dies without unlocking.
+	TTL time.Duration
+	// RetryInterval is how often waiters try to take a held lock (default 50ms).
+	RetryInterval time.Duration
+	// DialTimeout bounds connecting to the server (default 5s).
+	DialTimeout time.Duration
+	// TLSConfig is used for "rediss" URLs.
+	TLSConfig *tls.Config
+}
+
+// RedisLocker is a Locker shared by every instance connected to the same
+// Redis server. A lock is a key set with NX holding a random token, leased
+// for the TTL and renewed in the background until released. Waiters poll,
+// so unlike MemoryLocker they are not served in order.
+type RedisLocker struct {
+	config RedisLockerConfig
+	client *redis.Client
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+var _ Locker = (*RedisLocker)(nil)
+
+// NewRedisLocker creates a Redis locker. The connection is opened on first use.
+func NewRedisLocker(cfg RedisLockerConfig) (*RedisLocker, error) {
+	if cfg.URL == "" {
+		cfg.URL = "redis://localhost:6379"
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "chatbot:locks"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * time.Second
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = 50 * time.Millisecond
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+
+	client, err := redis.New(cfg.URL, cfg.DialTimeout, cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisLocker{config: cfg, client: client, closed: make(chan struct{})}, nil
+}
+
+func (l *RedisLocker) lockKey(conversationID string) string {
+	return l.config.KeyPrefix + ":" + conversationID
+}
+
+// Lock implements Locker.
+func (l *RedisLocker) Lock(ctx context.Context, conversationID string) (func(), error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	key := l.lockKey(conversationID)
+	ttl := strconv.FormatInt(l.config.TTL.Milliseconds(), 10)
+
+	for {
+		reply, err := l.do(ctx, "SET", key, token, "NX", "PX", ttl)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		if reply != nil {
+			break
+		}
+
+		timer := time.NewTimer(l.config.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-l.closed:
+			timer.Stop()
+			return nil, ErrLockerClosed
+		case <-timer.C:
+		}
+	}
+
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go l.renew(key, token, ttl, stop, renewed)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-renewed
+			// A failed release leaves the lock to expire with its lease
+			ctx, cancel := context.WithTimeout(context.Background(), l.config.DialTimeout)
+			defer cancel()
+			_, _ = l.do(ctx, "EVAL", releaseScript, "1", key, token)
+		})
+	}, nil
+}
+
+// renew extends the lease every third of the TTL until stop is closed.
+func (l *RedisLocker) renew(key, token, ttl string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(l.config.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-l.closed:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.config.DialTimeout)
+			_, _ = l.do(ctx, "EVAL", renewScript, "1", key, token, ttl)
+			cancel()
+		}
+	}
+}
+
+// Close stops lease renewal and closes the connection. Locks still held
+// expire with their lease.
+func (l *RedisLocker) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return l.client.Close()
+}
+
+// newLockToken returns a random token identifying one lock holder.
+func newLockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// do sends a command and returns its reply.
+func (l *RedisLocker) do(ctx context.Context, args ...string) (interface{}, error) {
+	reply, err := l.client.Do(ctx, args...)
+	if errors.Is(err, redis.ErrClosed) {
+		return nil, ErrLockerClosed
+	}
+	return reply, err
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// DefaultMaxStaleness is how far behind the primary replicas are assumed to
+// be, unless WithMaxStaleness says otherwise.
+const DefaultMaxStaleness = 5 * time.Second
+
+// StoreOption configures NewSQLConversationStore.
+type StoreOption func(*SQLConversationStore)
+
+// WithReplicas sends reads to read replicas of the primary, taking turns,
+// such as replicas in the regions the chatbot runs in. Writes always go to
+// the primary, and so do reads of conversations and users written within
+// the max staleness (WithMaxStaleness), so users read their own messages.
+// Other reads may be as stale as the replicas are. Reads that fail on a
+// replica are not retried on the primary.
+func WithReplicas(replicas ...*sql.DB) StoreOption {
+	return func(s *SQLConversationStore) {
+		s.replicas = append(s.replicas, replicas...)
+	}
+}
+
+// WithMaxStaleness sets how far the replicas of WithReplicas may lag behind
+// the primary (default 5s). Conversations and users written by this store
+// are read from the primary for that long; writes by other instances, and
+// deleted messages, may show on replicas later. A zero staleness reads
+// everything from the replicas.
+func WithMaxStaleness(staleness time.Duration) StoreOption {
+	return func(s *SQLConversationStore) {
+		s.writes.window = staleness
+	}
+}
+
+// reader returns the database to read the conversations or users of keys
+// from: the primary if one of them was written recently or there are no
+// replicas, and the next replica otherwise.
+func (s *SQLConversationStore) reader(keys ...string) *sql.DB {
+	if len(s.replicas) == 0 || s.writes.recent(keys...) {
+		return s.db
+	}
+	return s.replicas[(s.next.Add(1)-1)%uint64(len(s.replicas))]
+}
+
+// recordWrites records a write to the conversations or users of keys.
+func (s *SQLConversationStore) recordWrites(keys ...string) {
+	if len(s.replicas) > 0 {
+		s.writes.record(keys...)
+	}
+}
+
+// recordMessages records writes to the conversations of messages.
+func (s *SQLConversationStore) recordMessages(messages []*Message) {
+	if len(s.replicas) == 0 {
+		return
+	}
+	keys := make([]string, len(messages))
+	for i, msg := range messages {
+		keys[i] = conversationKey(msg.ConversationID)
+	}
+	s.writes.record(keys...)
+}
+
+func conversationKey(id string) string {
+	return "conversation:" + id
+}
+
+func userKey(id string) string {
+	return "user:" + id
+}
+
+// writeTracker remembers when conversations and users were last written,
+// for as long as replicas may not have the writes yet.
+type writeTracker struct {
+	mutex   sync.Mutex
+	window  time.Duration
+	written map[string]time.Time
+	pruned  time.Time
+}
+
+// record notes a write to keys, forgetting writes older than the window
+// once per window.
+func (w *writeTracker) record(keys ...string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.window <= 0 {
+		return
+	}
+	now := time.Now()
+	if w.written == nil {
+		w.written = make(map[string]time.Time)
+	}
+	if now.Sub(w.pruned) >= w.window {
+		for key, written := range w.written {
+			if now.Sub(written) >= w.window {
+				delete(w.written, key)
+			}
+		}
+		w.pruned = now
+	}
+	for _, key := range keys {
+		w.written[key] = now
+	}
+}
+
+// recent reports whether one of keys was written within the window.
+func (w *writeTracker) recent(keys ...string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		if written, ok := w.written[key]; ok && now.Sub(written) < w.window {
+			return true
+		}
+	}
+	return false
+}
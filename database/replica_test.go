@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestDBs opens initialized SQLite databases standing in for a primary
+// and its replicas, which do not replicate.
+func openTestDBs(t *testing.T, names ...string) []*sql.DB {
+	t.Helper()
+	dir := t.TempDir()
+	dbs := make([]*sql.DB, len(names))
+	for i, name := range names {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", name, err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := NewSQLConversationStore(db, "sqlite3").Initialize(context.Background()); err != nil {
+			t.Fatalf("failed to initialize %s: %v", name, err)
+		}
+		dbs[i] = db
+	}
+	return dbs
+}
+
+func TestSQLConversationStore_Replicas(t *testing.T) {
+	ctx := context.Background()
+	dbs := openTestDBs(t, "primary", "replica1", "replica2")
+	primary, replica1 := dbs[0], dbs[1]
+	store := NewSQLConversationStore(primary, "sqlite3", WithReplicas(dbs[1:]...), WithMaxStaleness(time.Hour))
+
+	// Recently written conversations are read from the primary
+	if err := store.CreateConversation(ctx, &Conversation{ID: "c1", UserID: "u1", Title: "Billing"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	if err := store.AddMessage(ctx, &Message{ID: "m1", ConversationID: "c1", Role: "user", Content: "Hi"}); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+	if history, err := store.GetConversationHistory(ctx, "c1"); err != nil || len(history) != 1 {
+		t.Fatalf("expected to read the new message, got %d, %v", len(history), err)
+	}
+	if conversations, err := store.ListConversations(ctx, "u1", 10, 0); err != nil || len(conversations) != 1 {
+		t.Errorf("expected to list the new conversation, got %d, %v", len(conversations), err)
+	}
+
+	// Other reads go to the replicas in turn
+	if err := NewSQLConversationStore(replica1, "sqlite3").CreateConversation(ctx, &Conversation{ID: "c2", UserID: "u2"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	_, first := store.GetConversation(ctx, "c2")
+	_, second := store.GetConversation(ctx, "c2")
+	if (first == nil) == (second == nil) || !errors.Is(errors.Join(first, second), ErrConversationNotFound) {
+		t.Errorf("expected one read from each replica, got %v and %v", first, second)
+	}
+
+	// Without staleness, even new writes are read from the replicas
+	store = NewSQLConversationStore(primary, "sqlite3", WithReplicas(dbs[2]), WithMaxStaleness(0))
+	if err := store.CreateConversation(ctx, &Conversation{ID: "c3", UserID: "u1"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	if _, err := store.GetConversation(ctx, "c3"); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("expected the replica not to have the conversation, got %v", err)
+	}
+}
+
+func TestWriteTracker(t *testing.T) {
+	tracker := &writeTracker{window: 20 * time.Millisecond}
+	tracker.record(conversationKey("c1"))
+	if !tracker.recent(userKey("u1"), conversationKey("c1")) || tracker.recent(conversationKey("c2")) {
+		t.Fatal("expected only c1 to be recent")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	tracker.record(conversationKey("c2"))
+	if tracker.recent(conversationKey("c1")) || len(tracker.written) != 1 {
+		t.Errorf("expected c1 to be forgotten, got %v", tracker.written)
+	}
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// ErrEmbeddingsDisabled is returned by semantic searches of a store created
+// without WithMessageEmbeddings.
+var ErrEmbeddingsDisabled = errors.New("conversation store does not embed messages")
+
+// DefaultSemanticResults is how many conversations SearchMessagesSemantic
+// returns at most.
+const DefaultSemanticResults = 10
+
+// embedBatchSize is how many messages EmbedMessages embeds per request.
+const embedBatchSize = 100
+
+// SemanticSearcher is a ConversationStore that finds conversations by the
+// meaning of their messages rather than by keywords.
+type SemanticSearcher interface {
+	// SearchMessagesSemantic returns the user's conversations with the
+	// messages most similar to query, best first.
+	SearchMessagesSemantic(ctx context.Context, userID, query string) ([]*SemanticMatch, error)
+}
+
+var (
+	_ SemanticSearcher = (*SQLConversationStore)(nil)
+	_ SemanticSearcher = (*InstrumentedStore)(nil)
+)
+
+// SemanticMatch is a conversation found by SearchMessagesSemantic, with its
+// message most similar to the query.
+type SemanticMatch struct {
+	Conversation *Conversation `json:"conversation"`
+	Message      *Message      `json:"message"`
+	Similarity   float64       `json:"similarity"`
+}
+
+// WithMessageEmbeddings embeds every message added to the store with
+// provider, for SearchMessagesSemantic. Embeddings are stored next to the
+// messages, tagged with the provider's model; after switching models, or to
+// embed messages stored before, run EmbedMessages. Messages are stored even
+// if embedding them fails, and are embedded by the next EmbedMessages.
+// Behind an EncryptedStore the store only sees ciphertext, which does not
+// embed meaningfully.
+func WithMessageEmbeddings(provider embeddings.EmbeddingProvider) StoreOption {
+	return func(s *SQLConversationStore) {
+		s.embedder = provider
+	}
+}
+
+// SearchMessagesSemantic implements SemanticSearcher. It compares the query
+// with every embedded message of the user, which suits the history of a
+// user rather than of a whole deployment.
+func (s *SQLConversationStore) SearchMessagesSemantic(ctx context.Context, userID, query string) ([]*SemanticMatch, error) {
+	if s.embedder == nil {
+		return nil, ErrEmbeddingsDisabled
+	}
+	queryVector, err := s.embedder.EmbedSingle(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	rows, err := s.reader(userKey(userID)).QueryContext(ctx, `
+		SELECT m.id, m.conversation_id, m.role, m.content, m.metadata, m.created_at, e.embedding
+		FROM message_embeddings e
+		JOIN messages m ON m.id = e.message_id
+		JOIN conversations c ON c.id = e.conversation_id
+		WHERE c.user_id = $1 AND e.model = $2`, userID, s.embedder.Model())
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	// Keep the best message of each conversation
+	best := make(map[string]*SemanticMatch)
+	for rows.Next() {
+		var msg Message
+		var metadataJSON, embeddingJSON string
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Role, &msg.Content, &metadataJSON, &msg.CreatedAt, &embeddingJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		var vector embeddings.Vector
+		if err := json.Unmarshal([]byte(embeddingJSON), &vector); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal embedding: %w", err)
+		}
+		similarity := embeddings.CosineSimilarity(queryVector, vector)
+		if match, ok := best[msg.ConversationID]; ok && match.Similarity >= similarity {
+			continue
+		}
+		if metadataJSON != "" {
+			if err := json.Unmarshal([]byte(metadataJSON), &msg.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+		best[msg.ConversationID] = &SemanticMatch{Message: &msg, Similarity: similarity}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate messages: %w", err)
+	}
+
+	matches := make([]*SemanticMatch, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity == matches[j].Similarity {
+			return matches[i].Message.ConversationID < matches[j].Message.ConversationID
+		}
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	matches = matches[:min(len(matches), DefaultSemanticResults)]
+
+	for _, match := range matches {
+		conv, err := s.GetConversation(ctx, match.Message.ConversationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversation: %w", err)
+		}
+		match.Conversation = conv
+	}
+	return matches, nil
+}
+
+// EmbedMessages embeds the stored messages that have no embedding of the
+// current model, such as those stored before WithMessageEmbeddings was
+// set, returning how many it embedded.
+func (s *SQLConversationStore) EmbedMessages(ctx context.Context) (int, error) {
+	if s.embedder == nil {
+		return 0, ErrEmbeddingsDisabled
+	}
+	embedded := 0
+	for {
+		messages, err := s.unembeddedMessages(ctx, embedBatchSize)
+		if err != nil {
+			return embedded, err
+		}
+		if len(messages) == 0 {
+			return embedded, nil
+		}
+		if err := s.embedMessages(ctx, messages); err != nil {
+			return embedded, err
+		}
+		embedded += len(messages)
+	}
+}
+
+// unembeddedMessages returns messages without an embedding of the current
+// model.
+func (s *SQLConversationStore) unembeddedMessages(ctx context.Context, limit int) ([]*Message, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.conversation_id, m.content
+		FROM messages m
+		LEFT JOIN message_embeddings e ON e.message_id = m.id AND e.model = $1
+		WHERE e.message_id IS NULL AND m.content <> ''
+		ORDER BY m.created_at, m.id
+		LIMIT $2`, s.embedder.Model(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages to embed: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Content); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate messages: %w", err)
+	}
+	return messages, nil
+}
+
+// embedNew embeds newly added messages if the store embeds messages. Errors
+// are dropped: the messages are stored, and EmbedMessages embeds them later.
+func (s *SQLConversationStore) embedNew(ctx context.Context, messages []*Message) {
+	if s.embedder == nil {
+		return
+	}
+	_ = s.embedMessages(ctx, messages)
+}
+
+// embedMessages embeds messages and stores their embeddings, replacing
+// those of another model.
+func (s *SQLConversationStore) embedMessages(ctx context.Context, messages []*Message) error {
+	var texts []string
+	var embeddable []*Message
+	for _, msg := range messages {
+		if msg.Content != "" {
+			texts = append(texts, msg.Content)
+			embeddable = append(embeddable, msg)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed messages: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("failed to embed messages: got %d embeddings for %d messages", len(vectors), len(texts))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	model := s.embedder.Model()
+	for i, msg := range embeddable {
+		vectorJSON, err := json.Marshal(vectors[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal embedding: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM message_embeddings WHERE message_id = $1", msg.ID); err != nil {
+			return fmt.Errorf("failed to replace embedding: %w", err)
+		}
+		query := "INSERT INTO message_embeddings (message_id, conversation_id, model, embedding) VALUES ($1, $2, $3, $4)"
+		if _, err := tx.ExecContext(ctx, query, msg.ID, msg.ConversationID, model, string(vectorJSON)); err != nil {
+			return fmt.Errorf("failed to store embedding: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit embeddings: %w", err)
+	}
+	return nil
+}
+
+// SearchMessagesSemantic implements SemanticSearcher, failing with
+// ErrEmbeddingsDisabled if the store is not a SemanticSearcher.
+func (s *InstrumentedStore) SearchMessagesSemantic(ctx context.Context, userID, query string) ([]*SemanticMatch, error) {
+	searcher, ok := s.store.(SemanticSearcher)
+	if !ok {
+		return nil, ErrEmbeddingsDisabled
+	}
+	started := s.now()
+	matches, err := searcher.SearchMessagesSemantic(ctx, userID, query)
+	s.observe("SearchMessagesSemantic", started, err)
+	return matches, err
+}
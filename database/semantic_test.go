@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// topicEmbeddings embeds texts as topic word counts, so "parcel" and
+// "delivery" are as similar as they mean.
+type topicEmbeddings struct {
+	model string
+	err   error
+}
+
+var topics = [][]string{{"refund", "money back"}, {"parcel", "delivery", "shipping"}, {"password", "login"}}
+
+func (e topicEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = make(embeddings.Vector, len(topics))
+		for j, words := range topics {
+			for _, word := range words {
+				vectors[i][j] += float64(strings.Count(strings.ToLower(text), word))
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func (e topicEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	vectors, err := e.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (topicEmbeddings) Dimensions() int  { return len(topics) }
+func (e topicEmbeddings) Model() string  { return e.model }
+func (topicEmbeddings) Provider() string { return "fake" }
+
+func TestSQLConversationStore_SearchMessagesSemantic(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	plain := NewSQLConversationStore(db, "sqlite3")
+	if err := plain.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	if _, err := plain.SearchMessagesSemantic(ctx, "u1", "parcel"); !errors.Is(err, ErrEmbeddingsDisabled) {
+		t.Errorf("expected embeddings to be disabled, got %v", err)
+	}
+
+	// Messages stored before embeddings were enabled are embedded later
+	manager := NewConversationManager(plain)
+	old, _, err := manager.CreateConversationWithMessage(ctx, "u1", "Account", "I forgot my password")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	store := NewSQLConversationStore(db, "sqlite3", WithMessageEmbeddings(topicEmbeddings{model: "v1"}))
+	manager = NewConversationManager(store)
+	shipping, _, err := manager.CreateConversationWithMessage(ctx, "u1", "Order 42", "Where is my parcel?")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	if _, err := manager.AppendExchange(ctx, "refund-1", "u1", "Can I get my money back?", "Yes, a refund takes 5 days."); err != nil {
+		t.Fatalf("failed to append exchange: %v", err)
+	}
+	if _, _, err := manager.CreateConversationWithMessage(ctx, "u2", "Other user", "My delivery is late"); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	matches, err := store.SearchMessagesSemantic(ctx, "u1", "the delivery never arrived")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Conversation.ID != shipping.ID || matches[0].Message.Content != "Where is my parcel?" ||
+		matches[0].Similarity <= matches[1].Similarity {
+		t.Fatalf("expected the shipping conversation first among the user's embedded ones, got %+v", matches)
+	}
+
+	if embedded, err := store.EmbedMessages(ctx); err != nil || embedded != 1 {
+		t.Fatalf("expected the older message embedded, got %d, %v", embedded, err)
+	}
+	matches, err = store.SearchMessagesSemantic(ctx, "u1", "login problems")
+	if err != nil || len(matches) == 0 || matches[0].Conversation.ID != old.ID {
+		t.Errorf("expected the account conversation first, got %+v, %v", matches, err)
+	}
+
+	// A new model re-embeds everything; until then nothing matches
+	upgraded := NewSQLConversationStore(db, "sqlite3", WithMessageEmbeddings(topicEmbeddings{model: "v2"}))
+	if matches, err := upgraded.SearchMessagesSemantic(ctx, "u1", "parcel"); err != nil || len(matches) != 0 {
+		t.Errorf("expected no embeddings of the new model, got %+v, %v", matches, err)
+	}
+	if embedded, err := upgraded.EmbedMessages(ctx); err != nil || embedded != 5 {
+		t.Errorf("expected every message embedded again, got %d, %v", embedded, err)
+	}
+
+	// Embedding failures do not lose messages, and deletes remove embeddings
+	failing := NewSQLConversationStore(db, "sqlite3", WithMessageEmbeddings(topicEmbeddings{model: "v2", err: errors.New("rate limited")}))
+	if _, err := NewConversationManager(failing).AddUserMessage(ctx, shipping.ID, "Any parcel news?"); err != nil {
+		t.Fatalf("expected the message stored, got %v", err)
+	}
+	if err := upgraded.DeleteConversation(ctx, shipping.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM message_embeddings WHERE conversation_id = ?`, shipping.ID).Scan(&count); err != nil || count != 0 {
+		t.Errorf("expected the embeddings deleted, got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DayFormat is the layout of DailyActivity dates.
+const DayFormat = "2006-01-02"
+
+// DailyActivity counts the messages stored on one day (UTC) and the users
+// who wrote them.
+type DailyActivity struct {
+	Date        string `json:"date"`
+	Messages    int    `json:"messages"`
+	ActiveUsers int    `json:"active_users"`
+	// UserIDs are the day's active users, for counting distinct users
+	// across several days.
+	UserIDs []string `json:"-"`
+}
+
+// ActivityReporter is implemented by stores that can report activity across
+// all users, such as SQLConversationStore.
+type ActivityReporter interface {
+	// DailyActivity returns the activity of each day with messages created
+	// in [since, until), oldest first.
+	DailyActivity(ctx context.Context, since, until time.Time) ([]DailyActivity, error)
+}
+
+var _ ActivityReporter = (*SQLConversationStore)(nil)
+
+// DailyActivity implements ActivityReporter.
+func (s *SQLConversationStore) DailyActivity(ctx context.Context, since, until time.Time) ([]DailyActivity, error) {
+	query := `
+		SELECT c.user_id, m.created_at
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE m.created_at >= $1 AND m.created_at < $2`
+
+	rows, err := s.reader().QueryContext(ctx, query, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity: %w", err)
+	}
+	defer rows.Close()
+
+	counter := NewActivityCounter()
+	for rows.Next() {
+		var userID string
+		var createdAt time.Time
+		if err := rows.Scan(&userID, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		counter.Add(userID, createdAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query activity: %w", err)
+	}
+	return counter.Days(), nil
+}
+
+// ActivityCounter groups messages into DailyActivity, for implementing
+// ActivityReporter.
+type ActivityCounter struct {
+	days map[string]map[string]int
+}
+
+// NewActivityCounter creates an empty counter.
+func NewActivityCounter() *ActivityCounter {
+	return &ActivityCounter{days: make(map[string]map[string]int)}
+}
+
+// Add counts a message written by userID at createdAt.
+func (a *ActivityCounter) Add(userID string, createdAt time.Time) {
+	date := createdAt.UTC().Format(DayFormat)
+	users, ok := a.days[date]
+	if !ok {
+		users = make(map[string]int)
+		a.days[date] = users
+	}
+	users[userID]++
+}
+
+// Days returns the counted activity, oldest first.
+func (a *ActivityCounter) Days() []DailyActivity {
+	days := make([]DailyActivity, 0, len(a.days))
+	for date, users := range a.days {
+		day := DailyActivity{Date: date, ActiveUsers: len(users)}
+		for userID, messages := range users {
+			day.Messages += messages
+			day.UserIDs = append(day.UserIDs, userID)
+		}
+		sort.Strings(day.UserIDs)
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date < days[j].Date
+	})
+	return days
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLConversationStore_DailyActivity(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	for _, conv := range []*Conversation{{ID: "c1", UserID: "u1"}, {ID: "c2", UserID: "u2"}, {ID: "c3", UserID: "u1"}} {
+		if err := store.CreateConversation(ctx, conv); err != nil {
+			t.Fatalf("failed to create conversation: %v", err)
+		}
+	}
+	for _, msg := range []*Message{
+		{ID: "m1", ConversationID: "c1", Role: "user", Content: "a"},
+		{ID: "m2", ConversationID: "c1", Role: "assistant", Content: "b"},
+		{ID: "m3", ConversationID: "c2", Role: "user", Content: "c"},
+		{ID: "m4", ConversationID: "c3", Role: "user", Content: "d"},
+	} {
+		if err := store.AddMessage(ctx, msg); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+
+	now := time.Now()
+	days, err := store.DailyActivity(ctx, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get activity: %v", err)
+	}
+	if len(days) == 0 {
+		t.Fatal("expected activity")
+	}
+	messages, users := 0, map[string]bool{}
+	for _, day := range days {
+		messages += day.Messages
+		for _, userID := range day.UserIDs {
+			users[userID] = true
+		}
+	}
+	if messages != 4 || len(users) != 2 {
+		t.Errorf("expected 4 messages from 2 users, got %d from %v", messages, users)
+	}
+
+	days, err = store.DailyActivity(ctx, now.Add(time.Hour), now.Add(2*time.Hour))
+	if err != nil || len(days) != 0 {
+		t.Errorf("expected no activity, got %+v, %v", days, err)
+	}
+}
+
+func TestActivityCounter(t *testing.T) {
+	counter := NewActivityCounter()
+	day := time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+	counter.Add("u1", day)
+	counter.Add("u1", day.Add(10*time.Minute))
+	counter.Add("u2", day.Add(time.Hour))
+	// Counted by UTC date
+	counter.Add("u2", time.Date(2026, 3, 2, 1, 0, 0, 0, time.FixedZone("CET", 3600)))
+
+	days := counter.Days()
+	if len(days) != 2 || days[0].Date != "2026-03-01" || days[1].Date != "2026-03-02" {
+		t.Fatalf("unexpected days %+v", days)
+	}
+	if days[0].Messages != 2 || days[0].ActiveUsers != 1 {
+		t.Errorf("unexpected first day %+v", days[0])
+	}
+	if days[1].Messages != 2 || days[1].ActiveUsers != 1 || days[1].UserIDs[0] != "u2" {
+		t.Errorf("unexpected second day %+v", days[1])
+	}
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// SummaryMetadataKey is the conversation metadata key holding its summary.
+const SummaryMetadataKey = "summary"
+
+// maxSummaryTranscript bounds the transcript sent for summarization, keeping
+// the most recent messages of long conversations.
+const maxSummaryTranscript = 24000
+
+// summaryPrompt instructs the model to summarize a transcript.
+const summaryPrompt = `You summarize customer conversations for a support inbox. Respond with JSON only, in the form {"summary": "<two or three sentences on what the user wanted and how it was resolved>", "action_items": ["<follow-up task>", ...]}. Use an empty list if nothing needs to be done.`
+
+// ErrNoSummaryModel is returned by Summarize when no model is configured.
+var ErrNoSummaryModel = errors.New("no summary model configured")
+
+// Summary is an overview of a conversation.
+type Summary struct {
+	Text         string    `json:"text"`
+	ActionItems  []string  `json:"action_items"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ManagerOption configures a ConversationManager.
+type ManagerOption func(*ConversationManager)
+
+// WithSummaryModel sets the model used by Summarize.
+func WithSummaryModel(model models.Model) ManagerOption {
+	return func(cm *ConversationManager) {
+		cm.summaryModel = model
+	}
+}
+
+// Summarize generates a summary and action items for a conversation with the
+// summary model and stores it in the conversation metadata, where inbox views
+// listing conversations can show it without another model call.
+func (cm *ConversationManager) Summarize(ctx context.Context, conversationID string) (*Summary, error) {
+	if cm.summaryModel == nil {
+		return nil, ErrNoSummaryModel
+	}
+
+	conv, err := cm.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	messages, err := cm.store.GetConversationHistory(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("conversation has no messages")
+	}
+
+	reply, err := cm.summaryModel.Ask(ctx, transcript(messages), map[string]interface{}{
+		"prompt":      summaryPrompt,
+		"temperature": 0.2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+
+	summary := parseSummary(reply)
+	summary.MessageCount = len(messages)
+	summary.CreatedAt = time.Now()
+
+	if conv.Metadata == nil {
+		conv.Metadata = make(map[string]interface{})
+	}
+	conv.Metadata[SummaryMetadataKey] = summary
+	if err := cm.store.UpdateConversation(ctx, conv); err != nil {
+		return nil, fmt.Errorf("failed to store summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// GetSummary returns the stored summary of a conversation, or nil if it has
+// not been summarized.
+func (cm *ConversationManager) GetSummary(ctx context.Context, conversationID string) (*Summary, error) {
+	conv, err := cm.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return SummaryOf(conv)
+}
+
+// SummaryOf decodes the summary stored in a conversation's metadata. It
+// returns nil if the conversation has not been summarized.
+func SummaryOf(conv *Conversation) (*Summary, error) {
+	value, ok := conv.Metadata[SummaryMetadataKey]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	if summary, ok := value.(*Summary); ok {
+		return summary, nil
+	}
+
+	// Stores return metadata decoded from JSON
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode summary: %w", err)
+	}
+	var summary Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode summary: %w", err)
+	}
+	return &summary, nil
+}
+
+// transcript renders messages as "Role: content" lines, dropping the oldest
+// ones if the transcript is too long.
+func transcript(messages []*Message) string {
+	lines := make([]string, 0, len(messages))
+	size := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		line := fmt.Sprintf("%s: %s", roleLabel(msg.Role), msg.Content)
+		if size+len(line) > maxSummaryTranscript && len(lines) > 0 {
+			break
+		}
+		lines = append(lines, line)
+		size += len(line) + 1
+	}
+
+	// Restore chronological order
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func roleLabel(role string) string {
+	if role == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+// parseSummary reads the model's JSON reply. Replies that are not JSON are
+// used as the summary text.
+func parseSummary(reply string) *Summary {
+	summary := &Summary{ActionItems: []string{}}
+
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start >= 0 && end > start {
+		var parsed struct {
+			Summary     string   `json:"summary"`
+			ActionItems []string `json:"action_items"`
+		}
+		if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err == nil && parsed.Summary != "" {
+			summary.Text = strings.TrimSpace(parsed.Summary)
+			for _, item := range parsed.ActionItems {
+				if item = strings.TrimSpace(item); item != "" {
+					summary.ActionItems = append(summary.ActionItems, item)
+				}
+			}
+			return summary
+		}
+	}
+
+	summary.Text = strings.TrimSpace(reply)
+	return summary
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// summaryModel returns a fixed reply and records the transcript it was sent.
+type summaryModel struct {
+	reply      string
+	err        error
+	transcript string
+}
+
+func (m *summaryModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.transcript = message
+	return m.reply, m.err
+}
+
+func (m *summaryModel) Name() string     { return "summary" }
+func (m *summaryModel) Provider() string { return "test" }
+
+func TestConversationManager_Summarize(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+
+	model := &summaryModel{reply: "Here you go:\n" +
+		`{"summary": "The user was charged twice and a refund was issued.", "action_items": ["Confirm refund in 5 days", " "]}`}
+	manager := NewConversationManager(store, WithSummaryModel(model))
+
+	conv, _, err := manager.CreateConversationWithMessage(ctx, "user123", "Billing", "I was charged twice")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	if _, err := manager.AddAssistantMessage(ctx, conv.ID, "Sorry about that, I've issued a refund."); err != nil {
+		t.Fatalf("failed to add message: %v", err)
+	}
+
+	summary, err := manager.Summarize(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("failed to summarize: %v", err)
+	}
+	if summary.Text != "The user was charged twice and a refund was issued." || summary.MessageCount != 2 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if len(summary.ActionItems) != 1 || summary.ActionItems[0] != "Confirm refund in 5 days" {
+		t.Errorf("unexpected action items %v", summary.ActionItems)
+	}
+	if !strings.Contains(model.transcript, "User: I was charged twice\nAssistant: Sorry") {
+		t.Errorf("unexpected transcript %q", model.transcript)
+	}
+
+	// The summary survives the round trip through the store
+	stored, err := manager.GetSummary(ctx, conv.ID)
+	if err != nil || stored == nil || stored.Text != summary.Text || len(stored.ActionItems) != 1 {
+		t.Errorf("unexpected stored summary %+v, %v", stored, err)
+	}
+}
+
+func TestConversationManager_SummarizeErrors(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+
+	if _, err := NewConversationManager(store).Summarize(ctx, "missing"); !errors.Is(err, ErrNoSummaryModel) {
+		t.Errorf("expected ErrNoSummaryModel, got %v", err)
+	}
+
+	failing := NewConversationManager(store, WithSummaryModel(&summaryModel{err: errors.New("provider down")}))
+	conv, _, _ := failing.CreateConversationWithMessage(ctx, "user123", "Chat", "Hello")
+	if _, err := failing.Summarize(ctx, conv.ID); err == nil || !strings.Contains(err.Error(), "provider down") {
+		t.Errorf("expected model error, got %v", err)
+	}
+	if summary, err := failing.GetSummary(ctx, conv.ID); err != nil || summary != nil {
+		t.Errorf("expected no summary, got %+v, %v", summary, err)
+	}
+}
+
+func TestParseSummary(t *testing.T) {
+	summary := parseSummary("The user asked about opening hours.")
+	if summary.Text != "The user asked about opening hours." || len(summary.ActionItems) != 0 {
+		t.Errorf("expected plain reply as summary, got %+v", summary)
+	}
+}
+
+func TestTranscriptKeepsRecentMessages(t *testing.T) {
+	long := strings.Repeat("x", maxSummaryTranscript)
+	messages := []*Message{
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: "latest"},
+	}
+	if got := transcript(messages); got != "Assistant: latest" {
+		t.Errorf("expected only the latest message, got %d chars", len(got))
+	}
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is how long a store call takes before
+// WithSlowQueryLog logs it, unless another threshold is given.
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// Operation is a call of an InstrumentedStore.
+type Operation struct {
+	// Method is the store method called, such as "AddMessage".
+	Method   string
+	Duration time.Duration
+	// Err is the error returned, if any. ErrConversationNotFound is not
+	// an error here, since looking up new conversations is routine.
+	Err error
+}
+
+// OperationStats summarizes the calls of one method.
+type OperationStats struct {
+	Method string
+	Calls  uint64
+	Errors uint64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Mean returns the mean duration of the calls.
+func (s OperationStats) Mean() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// StoreMetrics records store operations per method, safely for concurrent
+// use. The zero value is ready to use.
+type StoreMetrics struct {
+	mutex sync.Mutex
+	stats map[string]*OperationStats
+}
+
+// Record counts op.
+func (m *StoreMetrics) Record(op Operation) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stats == nil {
+		m.stats = make(map[string]*OperationStats)
+	}
+	stats, ok := m.stats[op.Method]
+	if !ok {
+		stats = &OperationStats{Method: op.Method}
+		m.stats[op.Method] = stats
+	}
+	stats.Calls++
+	if op.Err != nil {
+		stats.Errors++
+	}
+	stats.Total += op.Duration
+	stats.Max = max(stats.Max, op.Duration)
+}
+
+// Stats returns the stats of each method called so far, slowest in total
+// first, so the hotspots lead.
+func (m *StoreMetrics) Stats() []OperationStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stats := make([]OperationStats, 0, len(m.stats))
+	for _, s := range m.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total == stats[j].Total {
+			return stats[i].Method < stats[j].Method
+		}
+		return stats[i].Total > stats[j].Total
+	})
+	return stats
+}
+
+// InstrumentOption configures NewInstrumentedStore.
+type InstrumentOption func(*InstrumentedStore)
+
+// WithStoreMetrics records every call in metrics.
+func WithStoreMetrics(metrics *StoreMetrics) InstrumentOption {
+	return func(s *InstrumentedStore) {
+		s.metrics = metrics
+	}
+}
+
+// WithOperationHandler sets a function called after every call, such as
+// one observing a Prometheus histogram by method. Several handlers may be
+// added.
+func WithOperationHandler(fn func(op Operation)) InstrumentOption {
+	return func(s *InstrumentedStore) {
+		s.handlers = append(s.handlers, fn)
+	}
+}
+
+// WithSlowQueryLog logs calls taking at least threshold, and failed calls,
+// with logf, such as log.Printf or the Printf of a logger. A zero threshold
+// uses DefaultSlowQueryThreshold.
+func WithSlowQueryLog(threshold time.Duration, logf func(format string, v ...interface{})) InstrumentOption {
+	return func(s *InstrumentedStore) {
+		if threshold <= 0 {
+			threshold = DefaultSlowQueryThreshold
+		}
+		s.slowThreshold = threshold
+		s.logf = logf
+	}
+}
+
+// InstrumentedStore wraps a ConversationStore, timing every call for
+// metrics and logging slow and failed ones, to find database hotspots.
+// It passes MessageBatcher, MessageQuerier, ActivityReporter and
+// ConversationScanner calls on to stores implementing them.
+type InstrumentedStore struct {
+	store         ConversationStore
+	metrics       *StoreMetrics
+	handlers      []func(Operation)
+	slowThreshold time.Duration
+	logf          func(format string, v ...interface{})
+	now           func() time.Time
+}
+
+var (
+	_ ConversationStore   = (*InstrumentedStore)(nil)
+	_ MessageBatcher      = (*InstrumentedStore)(nil)
+	_ MessageQuerier      = (*InstrumentedStore)(nil)
+	_ ActivityReporter    = (*InstrumentedStore)(nil)
+	_ ConversationScanner = (*InstrumentedStore)(nil)
+)
+
+// NewInstrumentedStore wraps store with instrumentation.
+func NewInstrumentedStore(store ConversationStore, opts ...InstrumentOption) *InstrumentedStore {
+	s := &InstrumentedStore{store: store, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// observe records a call of method that started at started.
+func (s *InstrumentedStore) observe(method string, started time.Time, err error) {
+	if errors.Is(err, ErrConversationNotFound) {
+		err = nil
+	}
+	op := Operation{Method: method, Duration: s.now().Sub(started), Err: err}
+	if s.metrics != nil {
+		s.metrics.Record(op)
+	}
+	for _, handler := range s.handlers {
+		handler(op)
+	}
+	if s.logf == nil {
+		return
+	}
+	switch {
+	case op.Err != nil:
+		s.logf("conversation store: %s failed after %s: %v", method, op.Duration.Round(time.Millisecond), op.Err)
+	case op.Duration >= s.slowThreshold:
+		s.logf("conversation store: slow %s took %s", method, op.Duration.Round(time.Millisecond))
+	}
+}
+
+// CreateConversation implements ConversationStore.
+func (s *InstrumentedStore) CreateConversation(ctx context.Context, conv *Conversation) error {
+	started := s.now()
+	err := s.store.CreateConversation(ctx, conv)
+	s.observe("CreateConversation", started, err)
+	return err
+}
+
+// GetConversation implements ConversationStore.
+func (s *InstrumentedStore) GetConversation(ctx context.Context, id string) (*Conversation, error) {
+	started := s.now()
+	conv, err := s.store.GetConversation(ctx, id)
+	s.observe("GetConversation", started, err)
+	return conv, err
+}
+
+// UpdateConversation implements ConversationStore.
+func (s *InstrumentedStore) UpdateConversation(ctx context.Context, conv *Conversation) error {
+	started := s.now()
+	err := s.store.UpdateConversation(ctx, conv)
+	s.observe("UpdateConversation", started, err)
+	return err
+}
+
+// DeleteConversation implements ConversationStore.
+func (s *InstrumentedStore) DeleteConversation(ctx context.Context, id string) error {
+	started := s.now()
+	err := s.store.DeleteConversation(ctx, id)
+	s.observe("DeleteConversation", started, err)
+	return err
+}
+
+// ListConversations implements ConversationStore.
+func (s *InstrumentedStore) ListConversations(ctx context.Context, userID string, limit, offset int) ([]*Conversation, error) {
+	started := s.now()
+	conversations, err := s.store.ListConversations(ctx, userID, limit, offset)
+	s.observe("ListConversations", started, err)
+	return conversations, err
+}
+
+// AddMessage implements ConversationStore.
+func (s *InstrumentedStore) AddMessage(ctx context.Context, msg *Message) error {
+	started := s.now()
+	err := s.store.AddMessage(ctx, msg)
+	s.observe("AddMessage", started, err)
+	return err
+}
+
+// AddMessages implements MessageBatcher, adding the messages one by one if
+// the store is not a MessageBatcher.
+func (s *InstrumentedStore) AddMessages(ctx context.Context, messages []*Message) error {
+	batcher, ok := s.store.(MessageBatcher)
+	if !ok {
+		return addEach(ctx, s.AddMessage, messages)
+	}
+	started := s.now()
+	err := batcher.AddMessages(ctx, messages)
+	s.observe("AddMessages", started, err)
+	return err
+}
+
+// GetMessages implements ConversationStore.
+func (s *InstrumentedStore) GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*Message, error) {
+	started := s.now()
+	messages, err := s.store.GetMessages(ctx, conversationID, limit, offset)
+	s.observe("GetMessages", started, err)
+	return messages, err
+}
+
+// QueryMessages implements MessageQuerier.
+func (s *InstrumentedStore) QueryMessages(ctx context.Context, conversationID string, query MessageQuery) ([]*Message, error) {
+	started := s.now()
+	messages, err := queryMessages(ctx, s.store, conversationID, query)
+	s.observe("QueryMessages", started, err)
+	return messages, err
+}
+
+// DeleteMessage implements ConversationStore.
+func (s *InstrumentedStore) DeleteMessage(ctx context.Context, messageID string) error {
+	started := s.now()
+	err := s.store.DeleteMessage(ctx, messageID)
+	s.observe("DeleteMessage", started, err)
+	return err
+}
+
+// GetConversationHistory implements ConversationStore.
+func (s *InstrumentedStore) GetConversationHistory(ctx context.Context, conversationID string) ([]*Message, error) {
+	started := s.now()
+	messages, err := s.store.GetConversationHistory(ctx, conversationID)
+	s.observe("GetConversationHistory", started, err)
+	return messages, err
+}
+
+// SearchConversations implements ConversationStore.
+func (s *InstrumentedStore) SearchConversations(ctx context.Context, userID, query string, limit int) ([]*Conversation, error) {
+	started := s.now()
+	conversations, err := s.store.SearchConversations(ctx, userID, query, limit)
+	s.observe("SearchConversations", started, err)
+	return conversations, err
+}
+
+// ScanConversations implements ConversationScanner, failing if the store
+// is not a ConversationScanner.
+func (s *InstrumentedStore) ScanConversations(ctx context.Context, since, until time.Time, after *Conversation, limit int) ([]*Conversation, error) {
+	scanner, ok := s.store.(ConversationScanner)
+	if !ok {
+		return nil, fmt.Errorf("%T does not scan conversations", s.store)
+	}
+	started := s.now()
+	conversations, err := scanner.ScanConversations(ctx, since, until, after, limit)
+	s.observe("ScanConversations", started, err)
+	return conversations, err
+}
+
+// DailyActivity implements ActivityReporter, failing if the store is not
+// an ActivityReporter.
+func (s *InstrumentedStore) DailyActivity(ctx context.Context, since, until time.Time) ([]DailyActivity, error) {
+	reporter, ok := s.store.(ActivityReporter)
+	if !ok {
+		return nil, fmt.Errorf("%T does not report activity", s.store)
+	}
+	started := s.now()
+	activity, err := reporter.DailyActivity(ctx, since, until)
+	s.observe("DailyActivity", started, err)
+	return activity, err
+}
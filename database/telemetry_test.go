@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowStore takes a second per call on the test clock and fails when told.
+type slowStore struct {
+	ConversationStore
+	clock *time.Time
+	err   error
+	added []*Message
+}
+
+func (s *slowStore) GetConversation(ctx context.Context, id string) (*Conversation, error) {
+	*s.clock = s.clock.Add(time.Second)
+	return nil, ErrConversationNotFound
+}
+
+func (s *slowStore) AddMessage(ctx context.Context, msg *Message) error {
+	*s.clock = s.clock.Add(time.Second)
+	if s.err != nil && len(s.added) == 1 {
+		return s.err
+	}
+	s.added = append(s.added, msg)
+	return nil
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	inner := &slowStore{clock: &clock, err: errors.New("disk full")}
+	metrics := &StoreMetrics{}
+	var logged []string
+	var operations []Operation
+	store := NewInstrumentedStore(inner, WithStoreMetrics(metrics),
+		WithOperationHandler(func(op Operation) { operations = append(operations, op) }),
+		WithSlowQueryLog(2*time.Second, func(format string, v ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, v...))
+		}))
+	store.now = func() time.Time { return clock }
+
+	if _, err := store.GetConversation(ctx, "c1"); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("expected the store's error, got %v", err)
+	}
+	err := store.AddMessages(ctx, []*Message{{ID: "m1"}, {ID: "m2"}, {ID: "m3"}})
+	var partial *PartialBatchError
+	if !errors.As(err, &partial) || partial.Added != 1 {
+		t.Fatalf("expected a partial batch error after 1 message, got %v", err)
+	}
+
+	stats := metrics.Stats()
+	if len(stats) != 2 || stats[0].Method != "AddMessage" || stats[0].Calls != 2 || stats[0].Errors != 1 {
+		t.Fatalf("expected AddMessage to lead with 2 calls and 1 error, got %+v", stats)
+	}
+	if stats[1].Method != "GetConversation" || stats[1].Errors != 0 || stats[1].Max != time.Second || stats[1].Mean() != time.Second {
+		t.Errorf("expected a not found conversation not to count as an error, got %+v", stats[1])
+	}
+	if len(operations) != 3 || operations[2].Err == nil {
+		t.Errorf("expected the handler to see every call, got %+v", operations)
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], "AddMessage failed") {
+		t.Errorf("expected only the failure to be logged, got %q", logged)
+	}
+
+	// Calls at the threshold are slow
+	store.slowThreshold = time.Second
+	_, _ = store.GetConversation(ctx, "c1")
+	if len(logged) != 2 || !strings.Contains(logged[1], "slow GetConversation took 1s") {
+		t.Errorf("expected the slow call to be logged, got %q", logged)
+	}
+}
+
+func TestWriteBehindStore_PartialBatch(t *testing.T) {
+	clock := time.Now()
+	inner := &slowStore{clock: &clock, err: errors.New("disk full")}
+	store := NewWriteBehindStore(NewInstrumentedStore(inner), WithFlushInterval(time.Hour))
+	defer store.Close()
+
+	addMessages(t, store, 3)
+	if err := store.Flush(context.Background()); err == nil || store.Pending() != 2 {
+		t.Fatalf("expected the added message to leave the queue, got %v with %d queued", err, store.Pending())
+	}
+	inner.err = nil
+	if err := store.Flush(context.Background()); err != nil || len(inner.added) != 3 {
+		t.Errorf("expected each message to be added once, got %d added, %v", len(inner.added), err)
+	}
+}
@@ -0,0 +1,355 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MessageBatcher is a ConversationStore that adds several messages at once,
+// which WriteBehindStore uses to write its batches.
+type MessageBatcher interface {
+	// AddMessages adds messages, oldest first, keeping the CreatedAt of
+	// those that have one. It adds all of them or, on error, none, unless
+	// the error is a *PartialBatchError.
+	AddMessages(ctx context.Context, messages []*Message) error
+}
+
+// PartialBatchError is returned by AddMessages of stores adding messages
+// one by one when one fails, after the ones before it were added.
+type PartialBatchError struct {
+	Added int
+	Err   error
+}
+
+func (e *PartialBatchError) Error() string {
+	return fmt.Sprintf("failed to add message %d of the batch: %v", e.Added+1, e.Err)
+}
+
+func (e *PartialBatchError) Unwrap() error {
+	return e.Err
+}
+
+// addEach adds messages one by one with add.
+func addEach(ctx context.Context, add func(context.Context, *Message) error, messages []*Message) error {
+	for i, msg := range messages {
+		if err := add(ctx, msg); err != nil {
+			return &PartialBatchError{Added: i, Err: err}
+		}
+	}
+	return nil
+}
+
+var (
+	_ MessageBatcher    = (*SQLConversationStore)(nil)
+	_ MessageBatcher    = (*EncryptedStore)(nil)
+	_ MessageQuerier    = (*EncryptedStore)(nil)
+	_ ConversationStore = (*WriteBehindStore)(nil)
+	_ MessageQuerier    = (*WriteBehindStore)(nil)
+)
+
+// Defaults of a WriteBehindStore.
+const (
+	DefaultFlushInterval = 100 * time.Millisecond
+	DefaultBatchSize     = 100
+	DefaultMaxPending    = 10000
+)
+
+// WriteBehindOption configures NewWriteBehindStore.
+type WriteBehindOption func(*WriteBehindStore)
+
+// WithFlushInterval sets how long messages wait in the queue at most.
+// Shorter intervals lose fewer messages in a crash; longer ones write
+// bigger batches.
+func WithFlushInterval(interval time.Duration) WriteBehindOption {
+	return func(s *WriteBehindStore) {
+		s.interval = interval
+	}
+}
+
+// WithBatchSize sets how many messages are written at once. A full batch
+// is written without waiting for the interval.
+func WithBatchSize(size int) WriteBehindOption {
+	return func(s *WriteBehindStore) {
+		s.batchSize = size
+	}
+}
+
+// WithMaxPending bounds the queue. Once it is full, AddMessage writes the
+// queue itself and returns its error, so a database outage slows callers
+// down and reports errors instead of growing the queue without bound.
+func WithMaxPending(size int) WriteBehindOption {
+	return func(s *WriteBehindStore) {
+		s.maxPending = size
+	}
+}
+
+// WithWriteTimeout bounds each background write (default 10s).
+func WithWriteTimeout(timeout time.Duration) WriteBehindOption {
+	return func(s *WriteBehindStore) {
+		s.timeout = timeout
+	}
+}
+
+// WithWriteErrorHandler sets a function called when a background write
+// fails. By default errors are discarded; the messages are retried with the
+// next flush either way.
+func WithWriteErrorHandler(fn func(err error)) WriteBehindOption {
+	return func(s *WriteBehindStore) {
+		s.onError = fn
+	}
+}
+
+// WriteBehindStore wraps a ConversationStore, queueing added messages and
+// writing them in batches from a background goroutine, so AddMessage does
+// not wait on the database. Batches are written with AddMessages when the
+// store is a MessageBatcher, in one transaction for SQLConversationStore,
+// and message by message otherwise.
+//
+// Every other call writes the queue first, so reads see every message added
+// before them. Messages get their CreatedAt when added. Close writes the
+// queue before shutting down; messages added after Close are written
+// directly. Messages still queued when the process dies are lost, so keep
+// the interval short where every message counts.
+type WriteBehindStore struct {
+	ConversationStore
+	interval   time.Duration
+	batchSize  int
+	maxPending int
+	timeout    time.Duration
+	onError    func(err error)
+	now        func() time.Time
+
+	mutex   sync.Mutex
+	pending []*Message
+	closed  bool
+
+	writing sync.Mutex // held while writing the queue, so batches stay in order
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewWriteBehindStore wraps store with a write-behind queue.
+func NewWriteBehindStore(store ConversationStore, opts ...WriteBehindOption) *WriteBehindStore {
+	s := &WriteBehindStore{
+		ConversationStore: store,
+		interval:          DefaultFlushInterval,
+		batchSize:         DefaultBatchSize,
+		maxPending:        DefaultMaxPending,
+		timeout:           10 * time.Second,
+		onError:           func(error) {},
+		now:               time.Now,
+		wake:              make(chan struct{}, 1),
+		stop:              make(chan struct{}),
+		done:              make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.batchSize < 1 {
+		s.batchSize = 1
+	}
+
+	go s.run()
+	return s
+}
+
+// AddMessage implements ConversationStore. It queues a copy of msg and
+// returns without writing it, unless the queue is full.
+func (s *WriteBehindStore) AddMessage(ctx context.Context, msg *Message) error {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = s.now()
+	}
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return s.ConversationStore.AddMessage(ctx, msg)
+	}
+	queued := *msg
+	s.pending = append(s.pending, &queued)
+	pending := len(s.pending)
+	s.mutex.Unlock()
+
+	if pending >= s.maxPending {
+		return s.Flush(ctx)
+	}
+	if pending >= s.batchSize {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush writes the queued messages. Messages that fail stay queued.
+func (s *WriteBehindStore) Flush(ctx context.Context) error {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+
+	for {
+		s.mutex.Lock()
+		batch := s.pending[:min(s.batchSize, len(s.pending))]
+		s.mutex.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := s.write(ctx, batch); err != nil {
+			return fmt.Errorf("failed to write queued messages: %w", err)
+		}
+		// Only Flush removes messages, and new ones are appended behind
+		// the batch
+		s.mutex.Lock()
+		clear(s.pending[:len(batch)])
+		s.pending = s.pending[len(batch):]
+		s.mutex.Unlock()
+	}
+}
+
+// Pending returns the number of queued messages.
+func (s *WriteBehindStore) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.pending)
+}
+
+// Close stops the background writer and writes the queue, returning the
+// error if messages could not be written. Call it on shutdown, after the
+// server stopped taking requests. It does not close the wrapped store.
+func (s *WriteBehindStore) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mutex.Unlock()
+
+	close(s.stop)
+	<-s.done
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.Flush(ctx)
+}
+
+// write writes a batch.
+func (s *WriteBehindStore) write(ctx context.Context, batch []*Message) error {
+	var err error
+	if batcher, ok := s.ConversationStore.(MessageBatcher); ok {
+		err = batcher.AddMessages(ctx, batch)
+	} else {
+		err = addEach(ctx, s.ConversationStore.AddMessage, batch)
+	}
+	var partial *PartialBatchError
+	if errors.As(err, &partial) && partial.Added > 0 {
+		// Keep the written messages from being written again
+		s.mutex.Lock()
+		clear(s.pending[:partial.Added])
+		s.pending = s.pending[partial.Added:]
+		s.mutex.Unlock()
+	}
+	return err
+}
+
+// run writes the queue every interval and whenever a batch is full.
+func (s *WriteBehindStore) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		if err := s.Flush(ctx); err != nil {
+			s.onError(err)
+		}
+		cancel()
+	}
+}
+
+// CreateConversation implements ConversationStore. Conversations are
+// created directly, so queued messages always have theirs.
+func (s *WriteBehindStore) CreateConversation(ctx context.Context, conv *Conversation) error {
+	return s.ConversationStore.CreateConversation(ctx, conv)
+}
+
+// GetConversation implements ConversationStore.
+func (s *WriteBehindStore) GetConversation(ctx context.Context, id string) (*Conversation, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.ConversationStore.GetConversation(ctx, id)
+}
+
+// UpdateConversation implements ConversationStore.
+func (s *WriteBehindStore) UpdateConversation(ctx context.Context, conv *Conversation) error {
+	if err := s.Flush(ctx); err != nil {
+		return err
+	}
+	return s.ConversationStore.UpdateConversation(ctx, conv)
+}
+
+// DeleteConversation implements ConversationStore.
+func (s *WriteBehindStore) DeleteConversation(ctx context.Context, id string) error {
+	if err := s.Flush(ctx); err != nil {
+		return err
+	}
+	return s.ConversationStore.DeleteConversation(ctx, id)
+}
+
+// ListConversations implements ConversationStore.
+func (s *WriteBehindStore) ListConversations(ctx context.Context, userID string, limit, offset int) ([]*Conversation, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.ConversationStore.ListConversations(ctx, userID, limit, offset)
+}
+
+// GetMessages implements ConversationStore.
+func (s *WriteBehindStore) GetMessages(ctx context.Context, conversationID string, limit, offset int) ([]*Message, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.ConversationStore.GetMessages(ctx, conversationID, limit, offset)
+}
+
+// QueryMessages implements MessageQuerier.
+func (s *WriteBehindStore) QueryMessages(ctx context.Context, conversationID string, query MessageQuery) ([]*Message, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return queryMessages(ctx, s.ConversationStore, conversationID, query)
+}
+
+// DeleteMessage implements ConversationStore.
+func (s *WriteBehindStore) DeleteMessage(ctx context.Context, messageID string) error {
+	if err := s.Flush(ctx); err != nil {
+		return err
+	}
+	return s.ConversationStore.DeleteMessage(ctx, messageID)
+}
+
+// GetConversationHistory implements ConversationStore.
+func (s *WriteBehindStore) GetConversationHistory(ctx context.Context, conversationID string) ([]*Message, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.ConversationStore.GetConversationHistory(ctx, conversationID)
+}
+
+// SearchConversations implements ConversationStore.
+func (s *WriteBehindStore) SearchConversations(ctx context.Context, userID, query string, limit int) ([]*Conversation, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.ConversationStore.SearchConversations(ctx, userID, query, limit)
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchStore records the batches written to it. Only the methods the
+// write-behind tests use are implemented.
+type batchStore struct {
+	ConversationStore
+	mutex    sync.Mutex
+	batches  [][]*Message
+	failures []error
+}
+
+func (s *batchStore) AddMessages(ctx context.Context, messages []*Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		return err
+	}
+	s.batches = append(s.batches, append([]*Message(nil), messages...))
+	return nil
+}
+
+func (s *batchStore) AddMessage(ctx context.Context, msg *Message) error {
+	return s.AddMessages(ctx, []*Message{msg})
+}
+
+func (s *batchStore) GetConversationHistory(ctx context.Context, conversationID string) ([]*Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var messages []*Message
+	for _, batch := range s.batches {
+		for _, msg := range batch {
+			if msg.ConversationID == conversationID {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages, nil
+}
+
+func (s *batchStore) batchSizes() []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func addMessages(t *testing.T, store ConversationStore, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		msg := &Message{ID: generateID(), ConversationID: "c1", Role: "user", Content: "Hello"}
+		if err := store.AddMessage(context.Background(), msg); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+		if msg.CreatedAt.IsZero() {
+			t.Fatal("expected queued messages to get their CreatedAt")
+		}
+	}
+}
+
+func TestWriteBehindStore(t *testing.T) {
+	inner := &batchStore{}
+	store := NewWriteBehindStore(inner, WithFlushInterval(time.Hour), WithBatchSize(10))
+	defer store.Close()
+
+	addMessages(t, store, 3)
+	if store.Pending() != 3 || len(inner.batchSizes()) != 0 {
+		t.Fatalf("expected 3 queued messages and no writes, got %d queued and %v", store.Pending(), inner.batchSizes())
+	}
+
+	// Reads see queued messages
+	history, err := store.GetConversationHistory(context.Background(), "c1")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("expected 3 messages, got %d, %v", len(history), err)
+	}
+	if sizes := inner.batchSizes(); len(sizes) != 1 || sizes[0] != 3 {
+		t.Errorf("expected one batch of 3, got %v", sizes)
+	}
+}
+
+func TestWriteBehindStore_FullBatch(t *testing.T) {
+	inner := &batchStore{}
+	store := NewWriteBehindStore(inner, WithFlushInterval(time.Hour), WithBatchSize(4))
+	defer store.Close()
+
+	addMessages(t, store, 4)
+	deadline := time.Now().Add(5 * time.Second)
+	for store.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sizes := inner.batchSizes(); len(sizes) != 1 || sizes[0] != 4 {
+		t.Errorf("expected a full batch to be written without waiting, got %v", sizes)
+	}
+}
+
+func TestWriteBehindStore_Close(t *testing.T) {
+	inner := &batchStore{}
+	store := NewWriteBehindStore(inner, WithFlushInterval(time.Hour), WithBatchSize(2))
+	// Hold the writer, so Close has to write the queue itself
+	store.writing.Lock()
+	addMessages(t, store, 5)
+	store.writing.Unlock()
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if store.Pending() != 0 || len(inner.batchSizes()) != 3 {
+		t.Errorf("expected Close to write the queue in batches of 2, got %v with %d queued", inner.batchSizes(), store.Pending())
+	}
+
+	addMessages(t, store, 1)
+	if store.Pending() != 0 || len(inner.batchSizes()) != 4 {
+		t.Errorf("expected messages added after Close to be written directly, got %v", inner.batchSizes())
+	}
+}
+
+func TestWriteBehindStore_Errors(t *testing.T) {
+	unavailable := errors.New("database unavailable")
+	inner := &batchStore{failures: []error{unavailable, unavailable}}
+	store := NewWriteBehindStore(inner, WithFlushInterval(time.Hour), WithMaxPending(3))
+	defer store.Close()
+
+	addMessages(t, store, 2)
+	if err := store.Flush(context.Background()); !errors.Is(err, unavailable) || store.Pending() != 2 {
+		t.Fatalf("expected the write error with the messages kept, got %v with %d queued", err, store.Pending())
+	}
+
+	// A full queue is written by the caller, which gets the error
+	err := store.AddMessage(context.Background(), &Message{ID: generateID(), ConversationID: "c1", Role: "user"})
+	if !errors.Is(err, unavailable) {
+		t.Errorf("expected AddMessage to report the error once the queue is full, got %v", err)
+	}
+	if err := store.Flush(context.Background()); err != nil || store.Pending() != 0 {
+		t.Errorf("expected the retry to write every message, got %v with %d queued", err, store.Pending())
+	}
+	if sizes := inner.batchSizes(); len(sizes) != 1 || sizes[0] != 3 {
+		t.Errorf("expected one batch of 3, got %v", sizes)
+	}
+}
+
+func TestWriteBehindStore_ErrorHandler(t *testing.T) {
+	unavailable := errors.New("database unavailable")
+	inner := &batchStore{failures: []error{unavailable}}
+	reported := make(chan error, 1)
+	store := NewWriteBehindStore(inner, WithFlushInterval(time.Millisecond),
+		WithWriteErrorHandler(func(err error) {
+			select {
+			case reported <- err:
+			default:
+			}
+		}))
+	defer store.Close()
+
+	addMessages(t, store, 1)
+	select {
+	case err := <-reported:
+		if !errors.Is(err, unavailable) {
+			t.Errorf("expected the write error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the background write error to be reported")
+	}
+}
+
+func TestSQLConversationStore_AddMessages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewSQLConversationStore(db, "sqlite3")
+	ctx := context.Background()
+	if err := store.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize store: %v", err)
+	}
+	if err := store.CreateConversation(ctx, &Conversation{ID: "c1", UserID: "u1", Title: "Billing"}); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	created := time.Now().Add(-time.Minute).Truncate(time.Second)
+	messages := make([]*Message, 150)
+	for i := range messages {
+		messages[i] = &Message{ID: fmt.Sprintf("m%d", i), ConversationID: "c1", Role: "user", Content: "Hello"}
+	}
+	messages[0].CreatedAt = created
+	if err := store.AddMessages(ctx, messages); err != nil {
+		t.Fatalf("failed to add messages: %v", err)
+	}
+
+	history, err := store.GetConversationHistory(ctx, "c1")
+	if err != nil || len(history) != 150 {
+		t.Fatalf("expected 150 messages, got %d, %v", len(history), err)
+	}
+	if !history[0].CreatedAt.Equal(created) {
+		t.Errorf("expected the message to keep its CreatedAt %v, got %v", created, history[0].CreatedAt)
+	}
+	conv, err := store.GetConversation(ctx, "c1")
+	if err != nil || conv.UpdatedAt.Before(messages[149].CreatedAt.Truncate(time.Second)) {
+		t.Errorf("expected the conversation timestamp to be updated, got %+v, %v", conv, err)
+	}
+
+	// A failing batch adds nothing
+	if err := store.AddMessages(ctx, []*Message{{ID: "dup", ConversationID: "c1"}, {ID: "dup", ConversationID: "c1"}}); err == nil {
+		t.Error("expected an error for duplicate IDs")
+	}
+	if history, _ := store.GetConversationHistory(ctx, "c1"); len(history) != 150 {
+		t.Errorf("expected the failed batch to be rolled back, got %d messages", len(history))
+	}
+}
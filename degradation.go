@@ -0,0 +1,277 @@
+package gochatbot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go.rumenx.com/chatbot/events"
+)
+
+// Defaults of a RetryQueue.
+const (
+	DefaultRetryInterval    = time.Second
+	DefaultMaxRetryInterval = time.Minute
+	DefaultRetryQueueSize   = 10000
+)
+
+// retrySaveTimeout bounds each attempt to save a queued exchange.
+const retrySaveTimeout = 10 * time.Second
+
+// Exchange is a user message and its reply to store in a conversation.
+type Exchange struct {
+	ConversationID  string
+	UserID          string
+	Message         string
+	Reply           string
+	MessageMetadata map[string]interface{}
+	ReplyMetadata   map[string]interface{}
+	// Events are stored with the exchange in the store's outbox
+	// (WithEventOutbox).
+	Events []events.Event
+}
+
+// RetryQueueOption configures NewRetryQueue.
+type RetryQueueOption func(*RetryQueue)
+
+// WithRetryInterval sets how long the queue waits before retrying, and the
+// longest wait it backs off to while the store keeps failing.
+func WithRetryInterval(interval, maxInterval time.Duration) RetryQueueOption {
+	return func(q *RetryQueue) {
+		q.interval = interval
+		q.maxInterval = maxInterval
+	}
+}
+
+// WithRetryQueueSize sets how many exchanges the queue holds; once full,
+// further exchanges are dropped and logged.
+func WithRetryQueueSize(size int) RetryQueueOption {
+	return func(q *RetryQueue) {
+		q.size = size
+	}
+}
+
+// WithRetryErrorHandler sets a function called when a retry fails, for
+// example to count it in a metric.
+func WithRetryErrorHandler(fn func(exchange Exchange, err error)) RetryQueueOption {
+	return func(q *RetryQueue) {
+		q.onError = fn
+	}
+}
+
+// RetryQueue holds exchanges the conversation store failed to save and
+// retries them in the background, in order, backing off while the store is
+// down. Close it on shutdown to make a last attempt.
+type RetryQueue struct {
+	conversations Conversations
+	interval      time.Duration
+	maxInterval   time.Duration
+	size          int
+	onError       func(exchange Exchange, err error)
+
+	mutex   sync.Mutex
+	pending []Exchange
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRetryQueue creates a queue saving exchanges to conversations, which
+// should be the store given to WithConversations.
+func NewRetryQueue(conversations Conversations, opts ...RetryQueueOption) *RetryQueue {
+	q := &RetryQueue{
+		conversations: conversations,
+		interval:      DefaultRetryInterval,
+		maxInterval:   DefaultMaxRetryInterval,
+		size:          DefaultRetryQueueSize,
+		onError:       func(Exchange, error) {},
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.maxInterval = max(q.maxInterval, q.interval)
+
+	go q.run()
+	return q
+}
+
+// Add queues an exchange, reporting false if the queue is full or closed.
+func (q *RetryQueue) Add(exchange Exchange) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed || len(q.pending) >= q.size {
+		return false
+	}
+	q.pending = append(q.pending, exchange)
+	return true
+}
+
+// Len returns the number of queued exchanges.
+func (q *RetryQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.pending)
+}
+
+// Pending returns the queued exchanges of a conversation, oldest first.
+func (q *RetryQueue) Pending(conversationID string) []Exchange {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var pending []Exchange
+	for _, exchange := range q.pending {
+		if exchange.ConversationID == conversationID {
+			pending = append(pending, exchange)
+		}
+	}
+	return pending
+}
+
+// Close stops retrying in the background and makes a last attempt to save
+// the queued exchanges, returning an error if some could not be.
+func (q *RetryQueue) Close() error {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mutex.Unlock()
+
+	close(q.stop)
+	<-q.done
+	if !q.retry() {
+		return fmt.Errorf("failed to save %d queued exchange(s)", q.Len())
+	}
+	return nil
+}
+
+// run retries the queue, doubling the wait after each failure.
+func (q *RetryQueue) run() {
+	defer close(q.done)
+
+	delay := q.interval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-timer.C:
+		}
+		if q.retry() {
+			delay = q.interval
+		} else {
+			delay = min(delay*2, q.maxInterval)
+		}
+		timer.Reset(delay)
+	}
+}
+
+// retry saves the queued exchanges in order until one fails, reporting
+// whether all were saved. Only one retry runs at a time.
+func (q *RetryQueue) retry() bool {
+	for {
+		q.mutex.Lock()
+		if len(q.pending) == 0 {
+			q.mutex.Unlock()
+			return true
+		}
+		exchange := q.pending[0]
+		q.mutex.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), retrySaveTimeout)
+		_, err := appendExchange(ctx, q.conversations, exchange)
+		cancel()
+		if err != nil {
+			q.onError(exchange, err)
+			return false
+		}
+
+		q.mutex.Lock()
+		q.pending[0] = Exchange{}
+		q.pending = q.pending[1:]
+		q.mutex.Unlock()
+	}
+}
+
+// WithStatelessFallback keeps answering while the conversation store of
+// WithConversations is down, instead of failing requests. A conversation
+// that cannot be started gets an ID anyway, and its messages are saved
+// once the store is back; history that cannot be loaded is left out.
+// Exchanges that cannot be saved go to queue to be retried, and while it
+// holds exchanges new ones join it, so conversations keep their order.
+// Queued exchanges are included in the history. Every failure is logged
+// and published as an events.ConversationStoreFailed event. queue may be
+// nil to only log unsaved exchanges.
+//
+// Without this option, requests whose conversation cannot be started or
+// whose history cannot be loaded fail, and unsaved exchanges are logged.
+func WithStatelessFallback(queue *RetryQueue) Option {
+	return func(c *Chatbot) {
+		c.stateless = true
+		c.retries = queue
+	}
+}
+
+// storeFailed reports a failed conversation store operation the request
+// continues without.
+func (c *Chatbot) storeFailed(ctx context.Context, operation string, err error, askContext map[string]interface{}) {
+	c.logf("conversations: failed to %s, continuing without the store: %v", operation, err)
+	c.publishEvent(ctx, events.ConversationStoreFailed, askContext, map[string]interface{}{
+		"operation": operation,
+		"error":     err.Error(),
+	})
+}
+
+// fallbackConversation returns a new conversation ID for a request whose
+// conversation could not be started, or the error without the fallback.
+func (c *Chatbot) fallbackConversation(ctx context.Context, err error, askContext map[string]interface{}) (string, error) {
+	if !c.stateless {
+		return "", err
+	}
+	c.storeFailed(ctx, "start conversation", err, askContext)
+	return uuid.New().String(), nil
+}
+
+// queuedHistory returns the queued exchanges of a conversation as history
+// messages.
+func (c *Chatbot) queuedHistory(conversationID string) []map[string]interface{} {
+	if c.retries == nil {
+		return nil
+	}
+	var history []map[string]interface{}
+	for _, exchange := range c.retries.Pending(conversationID) {
+		history = append(history,
+			map[string]interface{}{"role": "user", "content": exchange.Message},
+			map[string]interface{}{"role": "assistant", "content": exchange.Reply},
+		)
+	}
+	return history
+}
+
+// appendExchange stores an exchange, with its metadata if conversations
+// keeps metadata, and its events in the outbox. It returns the ID of the
+// stored reply.
+func appendExchange(ctx context.Context, conversations Conversations, exchange Exchange) (string, error) {
+	if len(exchange.Events) > 0 {
+		store, ok := conversations.(OutboxConversations)
+		if !ok {
+			return "", fmt.Errorf("%T cannot store events", conversations)
+		}
+		return store.AppendExchangeWithEvents(ctx, exchange.ConversationID, exchange.UserID, exchange.Message, exchange.Reply,
+			exchange.MessageMetadata, exchange.ReplyMetadata, exchange.Events)
+	}
+	if store, ok := conversations.(MetadataConversations); ok && (exchange.MessageMetadata != nil || exchange.ReplyMetadata != nil) {
+		return store.AppendExchangeWithMetadata(ctx, exchange.ConversationID, exchange.UserID, exchange.Message, exchange.Reply,
+			exchange.MessageMetadata, exchange.ReplyMetadata)
+	}
+	return conversations.AppendExchange(ctx, exchange.ConversationID, exchange.UserID, exchange.Message, exchange.Reply)
+}
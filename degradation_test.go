@@ -0,0 +1,151 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+)
+
+// downConversations is a conversation store that can be taken down.
+type downConversations struct {
+	*fakeConversations
+	mutex sync.Mutex
+	down  bool
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func (d *downConversations) setDown(down bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.down = down
+}
+
+func (d *downConversations) err() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.down {
+		return errStoreDown
+	}
+	return nil
+}
+
+func (d *downConversations) StartConversation(ctx context.Context, userID string) (string, error) {
+	if err := d.err(); err != nil {
+		return "", err
+	}
+	return d.fakeConversations.StartConversation(ctx, userID)
+}
+
+func (d *downConversations) RecentMessages(ctx context.Context, conversationID string, limit int) ([]map[string]interface{}, error) {
+	if err := d.err(); err != nil {
+		return nil, err
+	}
+	return d.fakeConversations.RecentMessages(ctx, conversationID, limit)
+}
+
+func (d *downConversations) AppendExchange(ctx context.Context, conversationID, userID, message, reply string) (string, error) {
+	if err := d.err(); err != nil {
+		return "", err
+	}
+	return d.fakeConversations.AppendExchange(ctx, conversationID, userID, message, reply)
+}
+
+func TestStatelessFallback(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	store := &downConversations{fakeConversations: newFakeConversations(), down: true}
+
+	strict, err := New(cfg, WithModel(&contextModel{}), WithConversations(store, 10))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if _, err := strict.AskResponse(ctx, "Hello"); !errors.Is(err, errStoreDown) {
+		t.Fatalf("expected the store error without the fallback, got %v", err)
+	}
+
+	model := &contextModel{}
+	publisher := &recordingPublisher{}
+	logger := &recordingLogger{}
+	queue := NewRetryQueue(store, WithRetryInterval(time.Hour, time.Hour))
+	bot, err := New(cfg, WithModel(model), WithConversations(store, 10), WithStatelessFallback(queue),
+		WithEventPublisher(publisher), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	response, err := bot.AskResponse(ctx, "Hello")
+	if err != nil || response.ConversationID == "" {
+		t.Fatalf("expected a stateless answer with a conversation ID, got %+v, %v", response, err)
+	}
+	if queue.Len() != 1 {
+		t.Errorf("expected the unsaved exchange to be queued, got %d", queue.Len())
+	}
+	types := publisher.types()
+	if countType(types, events.ConversationStoreFailed) != 3 {
+		t.Errorf("expected store failure events for starting, loading and saving, got %v", types)
+	}
+	if len(logger.messages) != 3 {
+		t.Errorf("expected each failure to be logged, got %q", logger.messages)
+	}
+
+	// The queued exchange is history, and later exchanges queue behind it
+	if _, err := bot.Ask(ctx, "Again", WithContext("conversation_id", response.ConversationID)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history, _ := model.context["history"].([]map[string]interface{}); len(history) != 2 || history[0]["content"] != "Hello" {
+		t.Errorf("expected the queued exchange as history, got %v", model.context["history"])
+	}
+
+	store.setDown(false)
+	if _, err := bot.Ask(ctx, "Third", WithContext("conversation_id", response.ConversationID)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queue.Len() != 3 {
+		t.Errorf("expected exchanges to queue while others wait, got %d", queue.Len())
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatalf("expected Close to save the queue, got %v", err)
+	}
+	saved, _ := store.RecentMessages(ctx, response.ConversationID, 10)
+	if len(saved) != 6 || saved[0]["content"] != "Hello" || saved[4]["content"] != "Third" {
+		t.Errorf("expected the exchanges saved in order, got %v", saved)
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	store := &downConversations{fakeConversations: newFakeConversations(), down: true}
+	var failures int
+	queue := NewRetryQueue(store, WithRetryInterval(time.Millisecond, 4*time.Millisecond), WithRetryQueueSize(1),
+		WithRetryErrorHandler(func(Exchange, error) { failures++ }))
+
+	if !queue.Add(Exchange{ConversationID: "c1", Message: "Hi", Reply: "Hello"}) || queue.Add(Exchange{ConversationID: "c1"}) {
+		t.Fatal("expected the queue to take one exchange")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := queue.Close(); err == nil || queue.Len() != 1 {
+		t.Errorf("expected Close to report the unsaved exchange, got %v with %d queued", err, queue.Len())
+	}
+	if failures < 2 {
+		t.Errorf("expected the exchange to be retried, got %d failure(s)", failures)
+	}
+	if queue.Add(Exchange{ConversationID: "c1"}) {
+		t.Error("expected a closed queue to refuse exchanges")
+	}
+}
+
+func countType(types []events.Type, want events.Type) int {
+	count := 0
+	for _, t := range types {
+		if t == want {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,51 @@
+package gochatbot
+
+import (
+	"context"
+	"fmt"
+)
+
+// Dialog takes over the messages of a conversation while it is active,
+// like a dialog.Form asking for the slots it needs.
+type Dialog interface {
+	// Active reports whether the dialog is in progress in the
+	// conversation.
+	Active(ctx context.Context, conversationID string) (bool, error)
+	// Handle returns the reply to a message of an active conversation.
+	Handle(ctx context.Context, conversationID, message string) (string, error)
+}
+
+// WithDialogs answers messages of conversations with an active dialog
+// through that dialog instead of the model. Dialogs are started by the
+// application, for example with dialog.Form's Start, and only apply to
+// requests carrying a "conversation_id". Messages refused by guardrails
+// are refused before reaching a dialog.
+func WithDialogs(dialogs ...Dialog) Option {
+	return func(c *Chatbot) {
+		c.dialogs = append(c.dialogs, dialogs...)
+	}
+}
+
+// continueDialog returns the reply of the first dialog active in the
+// request's conversation, if any.
+func (c *Chatbot) continueDialog(ctx context.Context, message string, askContext map[string]interface{}) (string, bool, error) {
+	conversationID, _ := askContext["conversation_id"].(string)
+	if len(c.dialogs) == 0 || conversationID == "" {
+		return "", false, nil
+	}
+	for _, dialog := range c.dialogs {
+		active, err := dialog.Active(ctx, conversationID)
+		if err != nil {
+			return "", false, fmt.Errorf("dialog check failed: %w", err)
+		}
+		if !active {
+			continue
+		}
+		reply, err := dialog.Handle(ctx, conversationID, message)
+		if err != nil {
+			return "", false, fmt.Errorf("dialog failed: %w", err)
+		}
+		return reply, true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,429 @@
+// Package dialog fills forms through conversation: a Form declares the
+// slots it needs, such as a name, an email address and a date, and asks
+// follow-up questions until every required slot holds a valid value. It
+// then calls its completion callback with the values decoded into a
+// typed struct.
+//
+//	type Booking struct {
+//		Name  string    `json:"name"`
+//		Email string    `json:"email"`
+//		Date  time.Time `json:"date"`
+//	}
+//
+//	form, err := dialog.New("booking", []dialog.Slot{
+//		{Name: "name", Question: "Who is the booking for?"},
+//		{Name: "email", Validate: dialog.Email()},
+//		{Name: "date", Description: "the day of the visit", Validate: dialog.Date()},
+//	}, func(ctx context.Context, b Booking) (string, error) {
+//		return "Booked for " + b.Date.Format("January 2") + ".", book(ctx, b)
+//	}, dialog.WithExtractor(dialog.NewModelExtractor(model)))
+//
+//	bot, err := gochatbot.New(cfg, gochatbot.WithDialogs(form))
+//	question, err := form.Start(ctx, conversationID, "I'd like to book a visit")
+package dialog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNotActive is returned by Handle for a conversation without the form
+// in progress.
+var ErrNotActive = errors.New("form is not in progress")
+
+// Slot is a value a form asks for.
+type Slot struct {
+	// Name is the slot's key, and the JSON name of its field in the
+	// completed struct.
+	Name string
+	// Description tells the extractor what the slot holds, like "the
+	// day of the visit"; if empty, the name is used.
+	Description string
+	// Question asks for the slot; if empty, it is "What is <description>?".
+	Question string
+	// Validate checks and normalizes the value; if nil, any text is
+	// accepted.
+	Validate Validator
+	// Optional slots are filled when the user gives them, but not asked
+	// for.
+	Optional bool
+}
+
+// describe returns what the slot holds.
+func (s Slot) describe() string {
+	if s.Description != "" {
+		return s.Description
+	}
+	return "your " + strings.ReplaceAll(s.Name, "_", " ")
+}
+
+// question returns the question asking for the slot.
+func (s Slot) question() string {
+	if s.Question != "" {
+		return s.Question
+	}
+	return "What is " + s.describe() + "?"
+}
+
+// Extraction is what an Extractor is asked to find.
+type Extraction struct {
+	// Message is the user's message, and Question the one they were
+	// asked last, which a bare answer like "tomorrow" replies to. Asked
+	// names the slot that question asks for.
+	Message  string
+	Question string
+	Asked    string
+	Slots    []Slot
+}
+
+// Extractor finds slot values in a message.
+type Extractor interface {
+	// Extract returns the values the message gives, by slot name. Slots it
+	// does not give are left out.
+	Extract(ctx context.Context, extraction Extraction) (map[string]string, error)
+}
+
+// ExtractorFunc adapts a function to Extractor.
+type ExtractorFunc func(ctx context.Context, extraction Extraction) (map[string]string, error)
+
+// Extract implements Extractor.
+func (f ExtractorFunc) Extract(ctx context.Context, extraction Extraction) (map[string]string, error) {
+	return f(ctx, extraction)
+}
+
+// Progress is the state of a form in a conversation.
+type Progress struct {
+	// Values holds the validated values by slot name.
+	Values map[string]interface{} `json:"values"`
+	// Asked is the slot asked for last.
+	Asked string `json:"asked,omitempty"`
+}
+
+// Store keeps the progress of forms. Keys combine the form name and the
+// conversation ID.
+type Store interface {
+	// Load returns the progress under key, or nil if there is none.
+	Load(ctx context.Context, key string) (*Progress, error)
+	Save(ctx context.Context, key string, progress *Progress) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store in memory, for a single process. The zero value
+// is ready to use.
+type MemoryStore struct {
+	mu       sync.Mutex
+	progress map[string]*Progress
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, key string) (*Progress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	progress, ok := s.progress[key]
+	if !ok {
+		return nil, nil
+	}
+	values := make(map[string]interface{}, len(progress.Values))
+	for name, value := range progress.Values {
+		values[name] = value
+	}
+	return &Progress{Values: values, Asked: progress.Asked}, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(ctx context.Context, key string, progress *Progress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.progress == nil {
+		s.progress = make(map[string]*Progress)
+	}
+	s.progress[key] = progress
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.progress, key)
+	return nil
+}
+
+// Option configures a Form.
+type Option func(*options)
+
+type options struct {
+	extractor   Extractor
+	store       Store
+	cancelWords []string
+	cancelReply string
+}
+
+// WithExtractor sets how slot values are found in messages. By default the
+// whole message fills the slot asked for, which suits forms asking one
+// thing at a time; NewModelExtractor also picks values out of free text.
+func WithExtractor(extractor Extractor) Option {
+	return func(o *options) {
+		o.extractor = extractor
+	}
+}
+
+// WithStore sets where progress is kept, by default in memory. Use a
+// shared store when several instances serve a conversation.
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithCancel sets the messages that abandon the form, compared ignoring
+// case and surrounding punctuation, and the reply to them. By default
+// "cancel", "stop" and "never mind" do.
+func WithCancel(reply string, words ...string) Option {
+	return func(o *options) {
+		o.cancelReply = reply
+		o.cancelWords = words
+	}
+}
+
+// Form asks for slots until they are filled, then completes with them
+// decoded into T. It is safe for concurrent use across conversations.
+type Form[T any] struct {
+	name     string
+	slots    []Slot
+	complete func(ctx context.Context, value T) (string, error)
+	opts     options
+}
+
+// New creates a form. complete is called with the filled slots, decoded
+// into T by their JSON names, and returns the reply ending the dialog.
+// If it fails the progress is kept, so the next message tries again.
+func New[T any](name string, slots []Slot, complete func(ctx context.Context, value T) (string, error), opts ...Option) (*Form[T], error) {
+	if name == "" {
+		return nil, errors.New("form name is required")
+	}
+	if complete == nil {
+		return nil, errors.New("completion callback is required")
+	}
+	seen := make(map[string]bool, len(slots))
+	for _, slot := range slots {
+		if slot.Name == "" {
+			return nil, errors.New("slot name is required")
+		}
+		if seen[slot.Name] {
+			return nil, fmt.Errorf("duplicate slot %q", slot.Name)
+		}
+		seen[slot.Name] = true
+	}
+
+	o := options{
+		extractor:   askedSlot{},
+		store:       NewMemoryStore(),
+		cancelWords: []string{"cancel", "stop", "never mind"},
+		cancelReply: "Okay, I've cancelled that.",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Form[T]{name: name, slots: slots, complete: complete, opts: o}, nil
+}
+
+// Name returns the form's name.
+func (f *Form[T]) Name() string {
+	return f.name
+}
+
+// Start begins the form in a conversation, filling what message already
+// gives, and returns the next question, or the completion reply if
+// nothing is missing. A form already in progress starts over.
+func (f *Form[T]) Start(ctx context.Context, conversationID, message string) (string, error) {
+	progress := &Progress{Values: map[string]interface{}{}}
+	if err := f.opts.store.Save(ctx, f.key(conversationID), progress); err != nil {
+		return "", fmt.Errorf("failed to save form progress: %w", err)
+	}
+	if strings.TrimSpace(message) == "" {
+		return f.next(ctx, conversationID, progress)
+	}
+	return f.Handle(ctx, conversationID, message)
+}
+
+// Active reports whether the form is in progress in the conversation.
+func (f *Form[T]) Active(ctx context.Context, conversationID string) (bool, error) {
+	progress, err := f.opts.store.Load(ctx, f.key(conversationID))
+	if err != nil {
+		return false, fmt.Errorf("failed to load form progress: %w", err)
+	}
+	return progress != nil, nil
+}
+
+// Cancel abandons the form in the conversation.
+func (f *Form[T]) Cancel(ctx context.Context, conversationID string) error {
+	if err := f.opts.store.Delete(ctx, f.key(conversationID)); err != nil {
+		return fmt.Errorf("failed to delete form progress: %w", err)
+	}
+	return nil
+}
+
+// Handle fills slots from a message of a conversation with the form in
+// progress. It returns the next question, a question again with the
+// reason a value was rejected, or the completion reply once every
+// required slot is filled.
+func (f *Form[T]) Handle(ctx context.Context, conversationID, message string) (string, error) {
+	key := f.key(conversationID)
+	progress, err := f.opts.store.Load(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to load form progress: %w", err)
+	}
+	if progress == nil {
+		return "", ErrNotActive
+	}
+	if progress.Values == nil {
+		progress.Values = map[string]interface{}{}
+	}
+	if f.cancels(message) {
+		if err := f.Cancel(ctx, conversationID); err != nil {
+			return "", err
+		}
+		return f.opts.cancelReply, nil
+	}
+
+	extraction := Extraction{Message: message, Slots: f.slots}
+	if slot, ok := f.slot(progress.Asked); ok {
+		extraction.Question, extraction.Asked = slot.question(), slot.Name
+	}
+	values, err := f.opts.extractor.Extract(ctx, extraction)
+	if err != nil {
+		return "", fmt.Errorf("form %q: %w", f.name, err)
+	}
+
+	// Keep the valid values, and explain the first invalid one
+	problem := ""
+	for _, slot := range f.slots {
+		text, ok := values[slot.Name]
+		if !ok || strings.TrimSpace(text) == "" {
+			continue
+		}
+		value, err := validate(slot, text)
+		if err != nil {
+			if problem == "" {
+				problem = err.Error()
+				progress.Asked = slot.Name
+			}
+			continue
+		}
+		progress.Values[slot.Name] = value
+	}
+	if problem != "" {
+		if err := f.opts.store.Save(ctx, key, progress); err != nil {
+			return "", fmt.Errorf("failed to save form progress: %w", err)
+		}
+		slot, _ := f.slot(progress.Asked)
+		return sentence(problem) + " " + slot.question(), nil
+	}
+	return f.next(ctx, conversationID, progress)
+}
+
+// next asks for the first missing required slot, or completes the form.
+func (f *Form[T]) next(ctx context.Context, conversationID string, progress *Progress) (string, error) {
+	key := f.key(conversationID)
+	for _, slot := range f.slots {
+		if _, ok := progress.Values[slot.Name]; ok || slot.Optional {
+			continue
+		}
+		progress.Asked = slot.Name
+		if err := f.opts.store.Save(ctx, key, progress); err != nil {
+			return "", fmt.Errorf("failed to save form progress: %w", err)
+		}
+		return slot.question(), nil
+	}
+
+	var value T
+	if err := decode(progress.Values, &value); err != nil {
+		return "", fmt.Errorf("failed to decode form %q: %w", f.name, err)
+	}
+	progress.Asked = ""
+	if err := f.opts.store.Save(ctx, key, progress); err != nil {
+		return "", fmt.Errorf("failed to save form progress: %w", err)
+	}
+	reply, err := f.complete(ctx, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to complete form %q: %w", f.name, err)
+	}
+	if err := f.opts.store.Delete(ctx, key); err != nil {
+		return "", fmt.Errorf("failed to delete form progress: %w", err)
+	}
+	return reply, nil
+}
+
+// key returns the store key of the form in a conversation.
+func (f *Form[T]) key(conversationID string) string {
+	return f.name + ":" + conversationID
+}
+
+// slot returns the slot named name.
+func (f *Form[T]) slot(name string) (Slot, bool) {
+	for _, slot := range f.slots {
+		if slot.Name == name {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
+
+// cancels reports whether message abandons the form.
+func (f *Form[T]) cancels(message string) bool {
+	message = strings.ToLower(strings.Trim(message, " \t\r\n.!?,"))
+	for _, word := range f.opts.cancelWords {
+		if message == strings.ToLower(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the text of a slot.
+func validate(slot Slot, text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	if slot.Validate == nil {
+		return text, nil
+	}
+	return slot.Validate(text)
+}
+
+// decode converts slot values into the completed struct through JSON.
+func decode(values map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// sentence ends text with a period unless it has punctuation.
+func sentence(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text[len(text)-1:], ".!?") {
+		return text
+	}
+	return text + "."
+}
+
+// askedSlot fills the slot asked for last with the whole message.
+type askedSlot struct{}
+
+// Extract implements Extractor.
+func (askedSlot) Extract(ctx context.Context, extraction Extraction) (map[string]string, error) {
+	if extraction.Asked == "" {
+		return nil, nil
+	}
+	return map[string]string{extraction.Asked: extraction.Message}, nil
+}
@@ -0,0 +1,213 @@
+package dialog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type booking struct {
+	Name   string    `json:"name"`
+	Email  string    `json:"email"`
+	Date   time.Time `json:"date"`
+	Remark string    `json:"remark"`
+}
+
+func newBookingForm(t *testing.T, completed *booking, opts ...Option) *Form[booking] {
+	t.Helper()
+	form, err := New("booking", []Slot{
+		{Name: "name", Question: "Who is the booking for?"},
+		{Name: "email", Validate: Email()},
+		{Name: "date", Description: "the day of the visit", Validate: Date()},
+		{Name: "remark", Optional: true},
+	}, func(ctx context.Context, b booking) (string, error) {
+		*completed = b
+		return "Booked for " + b.Date.Format("January 2") + ".", nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("failed to create form: %v", err)
+	}
+	return form
+}
+
+func TestForm(t *testing.T) {
+	ctx := context.Background()
+	var completed booking
+	form := newBookingForm(t, &completed)
+
+	steps := []struct{ message, reply string }{
+		{"", "Who is the booking for?"},
+		{"Ada Lovelace", "What is your email?"},
+		{"ada at example", "That is not an email address. What is your email?"},
+		{"Ada <ada@example.com>", "What is the day of the visit?"},
+		{"2026-03-14", "Booked for March 14."},
+	}
+	for i, step := range steps {
+		var reply string
+		var err error
+		if i == 0 {
+			reply, err = form.Start(ctx, "c1", step.message)
+		} else {
+			reply, err = form.Handle(ctx, "c1", step.message)
+		}
+		if err != nil || reply != step.reply {
+			t.Fatalf("%q: expected %q, got %q, %v", step.message, step.reply, reply, err)
+		}
+	}
+	want := booking{Name: "Ada Lovelace", Email: "ada@example.com", Date: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)}
+	if completed != want {
+		t.Errorf("expected %+v, got %+v", want, completed)
+	}
+	if active, err := form.Active(ctx, "c1"); active || err != nil {
+		t.Errorf("expected the completed form to end, got %v, %v", active, err)
+	}
+	if _, err := form.Handle(ctx, "c1", "hello"); !errors.Is(err, ErrNotActive) {
+		t.Errorf("expected ErrNotActive, got %v", err)
+	}
+}
+
+func TestFormExtractsSeveralSlots(t *testing.T) {
+	ctx := context.Background()
+	var completed booking
+	var asked []string
+	extractor := ExtractorFunc(func(ctx context.Context, e Extraction) (map[string]string, error) {
+		asked = append(asked, e.Asked)
+		if strings.Contains(e.Message, "book") {
+			return map[string]string{"name": "Ada", "date": "March 14, 2026", "remark": "Window seat"}, nil
+		}
+		return map[string]string{e.Asked: e.Message}, nil
+	})
+	form := newBookingForm(t, &completed, WithExtractor(extractor))
+
+	reply, err := form.Start(ctx, "c1", "Please book Ada in on March 14, 2026, window seat")
+	if err != nil || reply != "What is your email?" {
+		t.Fatalf("expected the missing slot to be asked for, got %q, %v", reply, err)
+	}
+	if reply, err = form.Handle(ctx, "c1", "ada@example.com"); err != nil || reply != "Booked for March 14." {
+		t.Fatalf("expected the form to complete, got %q, %v", reply, err)
+	}
+	if completed.Name != "Ada" || completed.Remark != "Window seat" || completed.Email != "ada@example.com" {
+		t.Errorf("unexpected booking: %+v", completed)
+	}
+	if len(asked) != 2 || asked[0] != "" || asked[1] != "email" {
+		t.Errorf("unexpected asked slots: %q", asked)
+	}
+}
+
+func TestFormCancel(t *testing.T) {
+	ctx := context.Background()
+	form := newBookingForm(t, &booking{})
+	if _, err := form.Start(ctx, "c1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := form.Start(ctx, "c2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := form.Handle(ctx, "c1", "Never mind!"); err != nil || reply != "Okay, I've cancelled that." {
+		t.Errorf("expected the form to be cancelled, got %q, %v", reply, err)
+	}
+	if active, _ := form.Active(ctx, "c1"); active {
+		t.Error("expected the cancelled form to end")
+	}
+	if active, _ := form.Active(ctx, "c2"); !active {
+		t.Error("expected other conversations to keep their form")
+	}
+}
+
+func TestFormCompletionFailureKeepsProgress(t *testing.T) {
+	ctx := context.Background()
+	fail := true
+	form, err := New("signup", []Slot{{Name: "email", Validate: Email()}}, func(ctx context.Context, v struct {
+		Email string `json:"email"`
+	}) (string, error) {
+		if fail {
+			return "", errors.New("mail server down")
+		}
+		return "Signed up " + v.Email + ".", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.Start(ctx, "c1", "")
+	if _, err := form.Handle(ctx, "c1", "ada@example.com"); err == nil || !strings.Contains(err.Error(), "mail server down") {
+		t.Fatalf("expected the completion error, got %v", err)
+	}
+	fail = false
+	if reply, err := form.Handle(ctx, "c1", "try again"); err != nil || reply != "Signed up ada@example.com." {
+		t.Errorf("expected the retry to complete, got %q, %v", reply, err)
+	}
+}
+
+func TestNewValidatesSlots(t *testing.T) {
+	complete := func(ctx context.Context, v map[string]interface{}) (string, error) { return "", nil }
+	if _, err := New("form", []Slot{{Name: "a"}, {Name: "a"}}, complete); err == nil {
+		t.Error("expected duplicate slots to be rejected")
+	}
+	if _, err := New("form", []Slot{{}}, complete); err == nil {
+		t.Error("expected unnamed slots to be rejected")
+	}
+}
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		validator Validator
+		text      string
+		want      interface{}
+	}{
+		{Text(2, 5), "Ada", "Ada"},
+		{Text(2, 5), "A", nil},
+		{Text(2, 5), "Lovelace", nil},
+		{Email(), "ada@example.com", "ada@example.com"},
+		{Email(), "ada@localhost", nil},
+		{Date(), "14 March 2026", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{Date("02/01/2006"), "14/03/2026", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{Date(), "next Tuesday", nil},
+		{Pattern(`^\d{5}$`, "Postcodes have five digits"), "12345", "12345"},
+		{Pattern(`^\d{5}$`, "Postcodes have five digits"), "1234", nil},
+		{OneOf("Small", "Large"), " large", "Large"},
+		{OneOf("Small", "Large"), "huge", nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.validator(tt.text)
+		if got != tt.want || (tt.want == nil) != (err != nil) {
+			t.Errorf("%q: expected %v, got %v, %v", tt.text, tt.want, got, err)
+		}
+	}
+}
+
+// replyModel replies with a fixed text and records the system prompt.
+type replyModel struct {
+	reply  string
+	prompt string
+}
+
+func (m *replyModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.prompt, _ = context["prompt"].(string)
+	return m.reply, nil
+}
+
+func (m *replyModel) Name() string     { return "reply" }
+func (m *replyModel) Provider() string { return "test" }
+
+func TestModelExtractor(t *testing.T) {
+	model := &replyModel{reply: "```json\n{\"name\": \"Ada\", \"guests\": 2, \"date\": null}\n```"}
+	values, err := NewModelExtractor(model).Extract(context.Background(), Extraction{
+		Message:  "Ada, two of us",
+		Question: "Who is the booking for?",
+		Asked:    "name",
+		Slots:    []Slot{{Name: "name"}, {Name: "guests", Description: "the number of guests"}, {Name: "date"}},
+	})
+	if err != nil || len(values) != 2 || values["name"] != "Ada" || values["guests"] != "2" {
+		t.Errorf("unexpected values %v, %v", values, err)
+	}
+	if !strings.Contains(model.prompt, "- guests: the number of guests") || !strings.Contains(model.prompt, `"Who is the booking for?"`) {
+		t.Errorf("unexpected prompt: %s", model.prompt)
+	}
+
+	model.reply = "I cannot tell."
+	if _, err := NewModelExtractor(model).Extract(context.Background(), Extraction{Message: "hi"}); err == nil {
+		t.Error("expected an error for a reply without JSON")
+	}
+}
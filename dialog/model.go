@@ -0,0 +1,65 @@
+package dialog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/schema"
+)
+
+// extractPrompt instructs a model to pick slot values out of a message.
+const extractPrompt = `Extract the values of these fields from the user's message:
+%s
+%sReply with a JSON object mapping field names to the values as the user gave them, leaving out fields the message does not give. Write dates as YYYY-MM-DD. Do not answer the message.`
+
+// ModelExtractor finds slot values by prompting a model, so users can give
+// several at once or in their own words. A small, fast model is usually
+// enough.
+type ModelExtractor struct {
+	model models.Model
+}
+
+var _ Extractor = (*ModelExtractor)(nil)
+
+// NewModelExtractor creates an extractor prompting model.
+func NewModelExtractor(model models.Model) *ModelExtractor {
+	return &ModelExtractor{model: model}
+}
+
+// Extract implements Extractor.
+func (e *ModelExtractor) Extract(ctx context.Context, extraction Extraction) (map[string]string, error) {
+	var fields strings.Builder
+	for _, slot := range extraction.Slots {
+		fmt.Fprintf(&fields, "- %s: %s\n", slot.Name, slot.describe())
+	}
+	asked := ""
+	if extraction.Question != "" {
+		asked = fmt.Sprintf("The message answers the question %q.\n", extraction.Question)
+	}
+	reply, err := e.model.Ask(ctx, extraction.Message, map[string]interface{}{
+		"prompt":      fmt.Sprintf(extractPrompt, fields.String(), asked),
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract slots: %w", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(schema.ExtractJSON(reply)), &decoded); err != nil {
+		return nil, fmt.Errorf("model replied with no JSON object: %q", reply)
+	}
+	values := make(map[string]string, len(decoded))
+	for name, value := range decoded {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			values[name] = v
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
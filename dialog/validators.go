@@ -0,0 +1,94 @@
+package dialog
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Validator checks a slot's text and returns the value to store, which may
+// be normalized or of another type, like the time.Time of a date. Its
+// error is shown to the user before the slot is asked for again, so it
+// should read as a sentence, like "That is not an email address".
+type Validator func(text string) (interface{}, error)
+
+// Text accepts text of at least min and, if max is positive, at most max
+// characters.
+func Text(min, max int) Validator {
+	return func(text string) (interface{}, error) {
+		length := len([]rune(text))
+		if length < min {
+			return nil, fmt.Errorf("That is too short, it needs at least %d characters", min)
+		}
+		if max > 0 && length > max {
+			return nil, fmt.Errorf("That is too long, it can have at most %d characters", max)
+		}
+		return text, nil
+	}
+}
+
+// Email accepts an email address, alone or with a display name, and
+// returns the bare address.
+func Email() Validator {
+	return func(text string) (interface{}, error) {
+		address, err := mail.ParseAddress(text)
+		if err != nil || !strings.Contains(address.Address[strings.LastIndex(address.Address, "@")+1:], ".") {
+			return nil, errors.New("That is not an email address")
+		}
+		return address.Address, nil
+	}
+}
+
+// dateLayouts are the date formats Date accepts, besides its own.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2 January 2006",
+	"January 2, 2006",
+	"January 2 2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+}
+
+// Date accepts a date in ISO 8601 form, like 2026-03-14, or with the month
+// written out, and in any of layouts, and returns it as a time.Time. Model
+// extractors are asked to write dates in ISO 8601 form.
+func Date(layouts ...string) Validator {
+	layouts = append(layouts, dateLayouts...)
+	return func(text string) (interface{}, error) {
+		for _, layout := range layouts {
+			if date, err := time.Parse(layout, text); err == nil {
+				return date, nil
+			}
+		}
+		return nil, errors.New("I could not read that date, please write it like 2026-03-14")
+	}
+}
+
+// Pattern accepts text matching pattern, and shows message otherwise. It
+// panics if pattern does not compile.
+func Pattern(pattern, message string) Validator {
+	re := regexp.MustCompile(pattern)
+	return func(text string) (interface{}, error) {
+		if !re.MatchString(text) {
+			return nil, errors.New(message)
+		}
+		return text, nil
+	}
+}
+
+// OneOf accepts one of choices, ignoring case, and returns the choice as
+// written.
+func OneOf(choices ...string) Validator {
+	return func(text string) (interface{}, error) {
+		for _, choice := range choices {
+			if strings.EqualFold(strings.TrimSpace(text), choice) {
+				return choice, nil
+			}
+		}
+		return nil, fmt.Errorf("Please choose one of %s", strings.Join(choices, ", "))
+	}
+}
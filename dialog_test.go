@@ -0,0 +1,53 @@
+package gochatbot
+
+import (
+	"context"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/dialog"
+)
+
+func TestDialogs(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &echoModel{}
+	var email string
+	form, err := dialog.New("newsletter", []dialog.Slot{{Name: "email", Validate: dialog.Email()}},
+		func(ctx context.Context, v struct {
+			Email string `json:"email"`
+		}) (string, error) {
+			email = v.Email
+			return "You're subscribed.", nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot, err := New(cfg, WithModel(model), WithDialogs(form))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	ctx := context.Background()
+
+	if reply, err := bot.Ask(ctx, "Hello", WithContext("conversation_id", "c1")); err != nil || reply != "You said: Hello" {
+		t.Fatalf("expected the model to answer without an active dialog, got %q, %v", reply, err)
+	}
+	if _, err := form.Start(ctx, "c1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := bot.Ask(ctx, "Hello", WithContext("conversation_id", "c2")); err != nil || reply != "You said: Hello" {
+		t.Errorf("expected other conversations to reach the model, got %q, %v", reply, err)
+	}
+	if reply, err := bot.Ask(ctx, "not an address", WithContext("conversation_id", "c1")); err != nil || reply != "That is not an email address. What is your email?" {
+		t.Errorf("expected the dialog to ask again, got %q, %v", reply, err)
+	}
+	if reply, err := bot.Ask(ctx, "ada@example.com", WithContext("conversation_id", "c1")); err != nil || reply != "You're subscribed." {
+		t.Errorf("expected the dialog to complete, got %q, %v", reply, err)
+	}
+	if email != "ada@example.com" {
+		t.Errorf("expected the email to be completed, got %q", email)
+	}
+	if reply, err := bot.Ask(ctx, "Thanks", WithContext("conversation_id", "c1")); err != nil || reply != "You said: Thanks" {
+		t.Errorf("expected the model to answer after the dialog, got %q, %v", reply, err)
+	}
+}
@@ -0,0 +1,110 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrCollectionNotFound is returned for collections that do not exist.
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrCollectionExists is returned when creating a collection whose
+	// name is taken.
+	ErrCollectionExists = errors.New("collection already exists")
+)
+
+// collectionName is what collection names may contain.
+var collectionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// CollectionInfo describes a collection.
+type CollectionInfo struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Collections holds named vector stores, such as one knowledge base per
+// tenant or product, so one deployment can serve several without their
+// entries mixing. It is safe for concurrent use.
+type Collections struct {
+	mu          sync.RWMutex
+	provider    EmbeddingProvider
+	collections map[string]*VectorStore
+}
+
+// NewCollections creates an empty set of collections whose stores embed
+// with provider.
+func NewCollections(provider EmbeddingProvider) *Collections {
+	return &Collections{provider: provider, collections: make(map[string]*VectorStore)}
+}
+
+// Create creates an empty collection. Names are 1 to 128 letters, digits,
+// dots, dashes and underscores, starting with a letter or digit.
+func (c *Collections) Create(name string) (*VectorStore, error) {
+	if !collectionName.MatchString(name) {
+		return nil, fmt.Errorf("invalid collection name %q", name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.collections[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+	store := NewVectorStore(c.provider)
+	c.collections[name] = store
+	return store, nil
+}
+
+// Get returns the collection's store.
+func (c *Collections) Get(name string) (*VectorStore, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	store, ok := c.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	return store, nil
+}
+
+// List returns the collections ordered by name.
+func (c *Collections) List() []CollectionInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	infos := make([]CollectionInfo, 0, len(c.collections))
+	for name, store := range c.collections {
+		infos = append(infos, CollectionInfo{Name: name, Count: store.Count()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Drop removes a collection and its entries.
+func (c *Collections) Drop(name string) error {
+	c.mu.Lock()
+	store, ok := c.collections[name]
+	delete(c.collections, name)
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	store.Clear()
+	return nil
+}
+
+// collectionKey is the context key of the collection to search.
+type collectionKey struct{}
+
+// WithCollection returns a context naming the collection retrieval should
+// search, for retrievers like gochatbot.CollectionRetriever.
+func WithCollection(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, collectionKey{}, name)
+}
+
+// CollectionFromContext returns the collection set with WithCollection,
+// or "".
+func CollectionFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(collectionKey{}).(string)
+	return name
+}
@@ -0,0 +1,56 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestCollections(t *testing.T) {
+	collections := NewCollections(NewOpenAIEmbeddingProvider(config.OpenAIConfig{APIKey: "test-key"}, ""))
+
+	acme, err := collections.Create("acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := collections.Create("globex"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := collections.Create("acme"); !errors.Is(err, ErrCollectionExists) {
+		t.Errorf("expected ErrCollectionExists, got %v", err)
+	}
+	for _, name := range []string{"", "../acme", "-acme", "a b"} {
+		if _, err := collections.Create(name); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+
+	acme.AddVectors([]Vector{{1, 0}}, []map[string]interface{}{{"text": "a"}})
+	if got, err := collections.Get("acme"); err != nil || got != acme {
+		t.Errorf("expected the acme store, got %v", err)
+	}
+	infos := collections.List()
+	if len(infos) != 2 || infos[0] != (CollectionInfo{Name: "acme", Count: 1}) || infos[1].Name != "globex" {
+		t.Errorf("unexpected collections: %+v", infos)
+	}
+
+	if err := collections.Drop("acme"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if acme.Count() != 0 {
+		t.Error("expected the dropped collection to be emptied")
+	}
+	if _, err := collections.Get("acme"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("expected ErrCollectionNotFound, got %v", err)
+	}
+	if err := collections.Drop("acme"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("expected ErrCollectionNotFound, got %v", err)
+	}
+
+	ctx := WithCollection(context.Background(), "globex")
+	if CollectionFromContext(ctx) != "globex" || CollectionFromContext(context.Background()) != "" {
+		t.Error("expected the collection to be carried by the context")
+	}
+}
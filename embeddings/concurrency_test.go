@@ -0,0 +1,109 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// hashProvider embeds texts deterministically without shared state, so it
+// is safe for concurrent use.
+type hashProvider struct{}
+
+func (hashProvider) Embed(ctx context.Context, texts []string) ([]Vector, error) {
+	vectors := make([]Vector, len(texts))
+	for i, text := range texts {
+		h := fnv.New64a()
+		h.Write([]byte(text))
+		sum := h.Sum64()
+		vectors[i] = Vector{float64(sum & 0xff), float64(sum >> 8 & 0xff), float64(sum >> 16 & 0xff), 1}
+	}
+	return vectors, nil
+}
+
+func (p hashProvider) EmbedSingle(ctx context.Context, text string) (Vector, error) {
+	vectors, err := p.Embed(ctx, []string{text})
+	return vectors[0], err
+}
+
+func (hashProvider) Dimensions() int  { return 4 }
+func (hashProvider) Model() string    { return "hash" }
+func (hashProvider) Provider() string { return "test" }
+
+const workers, iterations = 8, 200
+
+// TestVectorStore_Concurrency exercises every operation at once; run it
+// with -race.
+func TestVectorStore_Concurrency(t *testing.T) {
+	// Interleave the workers even on a single CPU
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(workers))
+	ctx := context.Background()
+	store := NewVectorStore(hashProvider{})
+	store.SetThreshold(0)
+	if err := store.AddText(ctx, "seed", map[string]interface{}{"worker": -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			mine := func(metadata map[string]interface{}) bool { return metadata["worker"] == w }
+			for i := 0; i < iterations; i++ {
+				text := fmt.Sprintf("worker %d text %d", w, i)
+				var err error
+				switch i % 10 {
+				case 0:
+					_, err = store.Ingest(ctx, []string{text, text}, []map[string]interface{}{{"worker": w}, {"worker": w}})
+				case 1:
+					_, err = store.ReplaceTexts(ctx, mine, []string{text}, []map[string]interface{}{{"worker": w}})
+				case 2:
+					store.SetMetadata(mine, "seen", i)
+				case 3:
+					store.Find(mine)
+				case 4:
+					store.Delete(mine)
+				case 5:
+					_, err = store.SearchMMR(ctx, text, 3, DefaultMMRLambda)
+				case 6:
+					store.SetDuplicatePolicy(DuplicatePolicy(i%3), 0)
+				case 7:
+					store.Entries()
+				case 8:
+					if w == 0 {
+						err = store.Reindex(ctx, hashProvider{}, WithBatchSize(2))
+					}
+				default:
+					err = store.AddText(ctx, text, map[string]interface{}{"worker": w})
+				}
+				if err != nil {
+					errs <- err
+				}
+				// Searches race with every write
+				if _, err := store.Search(ctx, text, 5); err != nil {
+					errs <- err
+				}
+				runtime.Gosched()
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	vectors, metadata := store.Entries()
+	if len(vectors) != len(metadata) || len(vectors) != store.Count() {
+		t.Errorf("expected vectors and metadata to stay paired, got %d and %d", len(vectors), len(metadata))
+	}
+	store.Clear()
+	if store.Count() != 0 {
+		t.Errorf("expected an empty store, got %d entries", store.Count())
+	}
+}
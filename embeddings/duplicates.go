@@ -0,0 +1,213 @@
+package embeddings
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DuplicatePolicy is what a VectorStore does with texts that duplicate an
+// entry it holds.
+type DuplicatePolicy int
+
+const (
+	// DuplicatesKeep adds duplicates like any other text, the default.
+	DuplicatesKeep DuplicatePolicy = iota
+	// DuplicatesSkip leaves duplicates out.
+	DuplicatesSkip
+	// DuplicatesMerge leaves duplicates out and records their metadata on
+	// the entry they duplicate, under MetadataDuplicates.
+	DuplicatesMerge
+)
+
+// DefaultDuplicateThreshold is the similarity at which texts are near
+// duplicates, such as the same paragraph with different punctuation.
+const DefaultDuplicateThreshold = 0.95
+
+// MetadataDuplicates is the metadata key listing the metadata of the
+// duplicates merged into an entry.
+const MetadataDuplicates = "duplicates"
+
+// IngestReport summarizes an Ingest.
+type IngestReport struct {
+	Added   int
+	Skipped int
+	Merged  int
+}
+
+// SetDuplicatePolicy sets what Ingest and AddTexts do with texts that
+// duplicate an entry of the store or an earlier text of the same call.
+// Texts are duplicates if they are the same apart from whitespace, which is
+// checked before embedding them, or if their embeddings' similarity is at
+// least threshold; 0 uses DefaultDuplicateThreshold.
+func (vs *VectorStore) SetDuplicatePolicy(policy DuplicatePolicy, threshold float64) {
+	if threshold == 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.duplicates = policy
+	vs.duplicateThreshold = threshold
+}
+
+// Ingest adds texts to the vector store like AddTexts, and reports how many
+// were added and how many were duplicates. ReplaceTexts does not check for
+// duplicates, since a replaced source must stay whole.
+func (vs *VectorStore) Ingest(ctx context.Context, texts []string, metadata []map[string]interface{}) (IngestReport, error) {
+	var report IngestReport
+	if len(texts) != len(metadata) {
+		return report, fmt.Errorf("texts and metadata length mismatch: %d vs %d", len(texts), len(metadata))
+	}
+	if len(texts) == 0 {
+		return report, nil
+	}
+
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = contentHash(text)
+	}
+	vs.mu.RLock()
+	provider, generation := vs.provider, vs.generation
+	policy := vs.duplicates
+	known := vs.hashIndex()
+	vs.mu.RUnlock()
+
+	// Exact duplicates are not worth embedding
+	var unique []int
+	seen := make(map[string]bool, len(texts))
+	for i, hash := range hashes {
+		if _, ok := known[hash]; policy != DuplicatesKeep && (ok || seen[hash]) {
+			continue
+		}
+		seen[hash] = true
+		unique = append(unique, i)
+	}
+	vectors := make([]Vector, len(texts))
+	if len(unique) > 0 {
+		uniqueTexts := make([]string, len(unique))
+		for j, i := range unique {
+			uniqueTexts[j] = texts[i]
+		}
+		embeddings, err := provider.Embed(ctx, uniqueTexts)
+		if err != nil {
+			return report, fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+		for j, i := range unique {
+			vectors[i] = embeddings[j]
+		}
+	}
+
+	vs.mu.Lock()
+	if vs.generation != generation {
+		// Reindexed meanwhile, so embed with the new provider
+		vs.mu.Unlock()
+		return vs.Ingest(ctx, texts, metadata)
+	}
+	var retry []int
+	known = vs.hashIndex()
+	for i := range texts {
+		if policy == DuplicatesKeep {
+			vs.add(vectors[i], metadata[i], texts[i])
+			report.Added++
+			continue
+		}
+		duplicate, ok := known[hashes[i]]
+		if !ok && vectors[i] == nil {
+			// The entry it duplicated was removed since
+			retry = append(retry, i)
+			continue
+		}
+		if !ok {
+			duplicate = vs.similar(vectors[i])
+		}
+		switch {
+		case duplicate < 0:
+			known[hashes[i]] = len(vs.vectors)
+			vs.add(vectors[i], metadata[i], texts[i])
+			report.Added++
+			continue
+		case policy == DuplicatesMerge:
+			vs.merge(duplicate, metadata[i])
+			report.Merged++
+		default:
+			report.Skipped++
+		}
+		known[hashes[i]] = duplicate
+	}
+	vs.mu.Unlock()
+
+	if len(retry) > 0 {
+		retryTexts := make([]string, len(retry))
+		retryMetadata := make([]map[string]interface{}, len(retry))
+		for j, i := range retry {
+			retryTexts[j], retryMetadata[j] = texts[i], metadata[i]
+		}
+		more, err := vs.Ingest(ctx, retryTexts, retryMetadata)
+		report.Added += more.Added
+		report.Skipped += more.Skipped
+		report.Merged += more.Merged
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// hashIndex maps the content hashes of the entries to their indexes. The
+// caller holds the lock.
+func (vs *VectorStore) hashIndex() map[string]int {
+	index := make(map[string]int, len(vs.hashes))
+	for i, hash := range vs.hashes {
+		if _, ok := index[hash]; !ok && hash != "" {
+			index[hash] = i
+		}
+	}
+	return index
+}
+
+// similar returns the index of the entry most similar to vector at or above
+// the duplicate threshold, or -1. The caller holds the lock.
+func (vs *VectorStore) similar(vector Vector) int {
+	best, bestSimilarity := -1, vs.duplicateThreshold
+	norm := Norm(vector)
+	for i := range vs.vectors {
+		if similarity := vs.similarity(vector, norm, i); similarity >= bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+	return best
+}
+
+// add appends an entry; the caller holds the lock.
+func (vs *VectorStore) add(vector Vector, metadata map[string]interface{}, text string) {
+	vs.vectors = append(vs.vectors, vector)
+	vs.norms = append(vs.norms, Norm(vector))
+	vs.metadata = append(vs.metadata, metadata)
+	vs.texts = append(vs.texts, text)
+	vs.hashes = append(vs.hashes, contentHash(text))
+	vs.version++
+}
+
+// merge records the metadata of a duplicate on entry i, copying its
+// metadata like SetMetadata. The caller holds the lock.
+func (vs *VectorStore) merge(i int, duplicate map[string]interface{}) {
+	existing := vs.metadata[i]
+	merged := make(map[string]interface{}, len(existing)+1)
+	for k, v := range existing {
+		merged[k] = v
+	}
+	previous, _ := existing[MetadataDuplicates].([]map[string]interface{})
+	duplicates := make([]map[string]interface{}, len(previous), len(previous)+1)
+	copy(duplicates, previous)
+	merged[MetadataDuplicates] = append(duplicates, duplicate)
+	vs.metadata[i] = merged
+	vs.version++
+}
+
+// contentHash hashes text with its whitespace collapsed.
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,101 @@
+package embeddings
+
+import (
+	"context"
+	"testing"
+)
+
+// textProvider embeds texts as fixed vectors and records what it embeds.
+type textProvider struct {
+	vectors  map[string]Vector
+	embedded []string
+}
+
+func (p *textProvider) Embed(ctx context.Context, texts []string) ([]Vector, error) {
+	vectors := make([]Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = p.vectors[text]
+		p.embedded = append(p.embedded, text)
+	}
+	return vectors, nil
+}
+
+func (p *textProvider) EmbedSingle(ctx context.Context, text string) (Vector, error) {
+	return p.vectors[text], nil
+}
+
+func (p *textProvider) Dimensions() int  { return 2 }
+func (p *textProvider) Model() string    { return "text" }
+func (p *textProvider) Provider() string { return "test" }
+
+func newTextProvider() *textProvider {
+	return &textProvider{vectors: map[string]Vector{
+		"Refunds take five days.":   {1, 0},
+		"Refunds take five days.\n": {1, 0},
+		"Refunds take 5 days.":      {1, 0.05},
+		"Shipping is free.":         {0, 1},
+	}}
+}
+
+func TestVectorStore_IngestDuplicates(t *testing.T) {
+	ctx := context.Background()
+	texts := []string{"Refunds take five days.", "Refunds take 5 days.", "Shipping is free.", "Refunds take five days.\n"}
+	metadata := func(source string) []map[string]interface{} {
+		return []map[string]interface{}{{"source": source}, {"source": source}, {"source": source}, {"source": source}}
+	}
+
+	provider := newTextProvider()
+	store := NewVectorStore(provider)
+	store.SetDuplicatePolicy(DuplicatesSkip, 0)
+	report, err := store.Ingest(ctx, texts, metadata("a.md"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report != (IngestReport{Added: 2, Skipped: 2}) || store.Count() != 2 {
+		t.Errorf("expected the near and exact duplicates to be skipped, got %+v", report)
+	}
+	if len(provider.embedded) != 3 {
+		t.Errorf("expected the exact duplicate not to be embedded, embedded %v", provider.embedded)
+	}
+
+	// Re-ingesting overlapping documents does not grow the store
+	provider.embedded = nil
+	report, _ = store.Ingest(ctx, texts, metadata("b.md"))
+	if report.Added != 0 || store.Count() != 2 || len(provider.embedded) != 1 {
+		t.Errorf("expected nothing new, got %+v and embedded %v", report, provider.embedded)
+	}
+
+	store = NewVectorStore(newTextProvider())
+	store.SetDuplicatePolicy(DuplicatesMerge, 0)
+	if err := store.AddTexts(ctx, texts[:3], metadata("a.md")[:3]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report, _ = store.Ingest(ctx, texts[:1], metadata("b.md")[:1])
+	if report != (IngestReport{Merged: 1}) {
+		t.Errorf("expected a merge, got %+v", report)
+	}
+	entries := store.Find(func(metadata map[string]interface{}) bool { return metadata[MetadataDuplicates] != nil })
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry with duplicates, got %v", entries)
+	}
+	duplicates, _ := entries[0][MetadataDuplicates].([]map[string]interface{})
+	if entries[0]["source"] != "a.md" || len(duplicates) != 2 || duplicates[1]["source"] != "b.md" {
+		t.Errorf("expected the duplicates' metadata on the first entry, got %v", entries[0])
+	}
+}
+
+func TestVectorStore_IngestKeepsDuplicatesByDefault(t *testing.T) {
+	store := NewVectorStore(newTextProvider())
+	report, err := store.Ingest(context.Background(),
+		[]string{"Shipping is free.", "Shipping is free."}, []map[string]interface{}{{}, {}})
+	if err != nil || report.Added != 2 || store.Count() != 2 {
+		t.Errorf("expected both texts to be added, got %+v, %v", report, err)
+	}
+
+	// Deleted entries no longer count as duplicates
+	store.SetDuplicatePolicy(DuplicatesSkip, 0)
+	store.Delete(func(map[string]interface{}) bool { return true })
+	if report, _ := store.Ingest(context.Background(), []string{"Shipping is free."}, []map[string]interface{}{{}}); report.Added != 1 {
+		t.Errorf("expected the text to be added again, got %+v", report)
+	}
+}
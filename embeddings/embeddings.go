@@ -7,8 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
+	"sync"
 	"time"
 
 	"go.rumenx.com/chatbot/config"
@@ -214,86 +214,117 @@ func (p *OpenAIEmbeddingProvider) Provider() string {
 }
 
 // VectorStore provides vector storage and similarity search functionality.
+// It is safe for concurrent use, so a knowledge base can be refreshed while
+// it is searched.
 type VectorStore struct {
+	mu        sync.RWMutex
 	vectors   []Vector
 	metadata  []map[string]interface{}
+	norms     []float64 // lengths of the vectors, so searches only take dot products
+	texts     []string  // embedded texts, for Reindex
+	hashes    []string  // content hashes, "" for entries added as vectors
 	provider  EmbeddingProvider
 	threshold float64
+	// generation counts the providers Reindex swapped in, so embeddings
+	// made with an earlier one are not added
+	generation int
+	version    uint64
+
+	duplicates         DuplicatePolicy
+	duplicateThreshold float64
 }
 
 // NewVectorStore creates a new vector store.
 func NewVectorStore(provider EmbeddingProvider) *VectorStore {
 	return &VectorStore{
-		provider:  provider,
-		threshold: 0.7, // Default similarity threshold
+		provider:           provider,
+		threshold:          0.7, // Default similarity threshold
+		duplicateThreshold: DefaultDuplicateThreshold,
 	}
 }
 
-// AddTexts adds texts to the vector store.
+// AddTexts adds texts to the vector store, leaving out duplicates as set
+// with SetDuplicatePolicy.
 func (vs *VectorStore) AddTexts(ctx context.Context, texts []string, metadata []map[string]interface{}) error {
-	if len(texts) != len(metadata) {
-		return fmt.Errorf("texts and metadata length mismatch: %d vs %d", len(texts), len(metadata))
-	}
+	_, err := vs.Ingest(ctx, texts, metadata)
+	return err
+}
 
-	// Generate embeddings
-	embeddings, err := vs.provider.Embed(ctx, texts)
-	if err != nil {
-		return fmt.Errorf("failed to generate embeddings: %w", err)
+// AddText adds a single text to the vector store.
+func (vs *VectorStore) AddText(ctx context.Context, text string, metadata map[string]interface{}) error {
+	return vs.AddTexts(ctx, []string{text}, []map[string]interface{}{metadata})
+}
+
+// AddVectors adds precomputed embeddings to the vector store, e.g. ones
+// loaded from a previously built knowledge index.
+func (vs *VectorStore) AddVectors(vectors []Vector, metadata []map[string]interface{}) error {
+	if len(vectors) != len(metadata) {
+		return fmt.Errorf("vectors and metadata length mismatch: %d vs %d", len(vectors), len(metadata))
 	}
 
-	// Add to store
-	vs.vectors = append(vs.vectors, embeddings...)
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.vectors = append(vs.vectors, vectors...)
 	vs.metadata = append(vs.metadata, metadata...)
+	vs.hashes = append(vs.hashes, make([]string, len(vectors))...)
+	for _, vector := range vectors {
+		vs.norms = append(vs.norms, Norm(vector))
+	}
+	for _, metadata := range metadata {
+		text, _ := metadata["text"].(string)
+		vs.texts = append(vs.texts, text)
+	}
+	vs.version++
 
 	return nil
 }
 
-// AddText adds a single text to the vector store.
-func (vs *VectorStore) AddText(ctx context.Context, text string, metadata map[string]interface{}) error {
-	return vs.AddTexts(ctx, []string{text}, []map[string]interface{}{metadata})
+// ReplaceTexts embeds texts and swaps them in for the entries selected by
+// match in one step, so searches see either the old entries or the new
+// ones. If embedding fails the store is unchanged. It returns how many
+// entries were removed.
+func (vs *VectorStore) ReplaceTexts(ctx context.Context, match func(metadata map[string]interface{}) bool, texts []string, metadata []map[string]interface{}) (int, error) {
+	if len(texts) != len(metadata) {
+		return 0, fmt.Errorf("texts and metadata length mismatch: %d vs %d", len(texts), len(metadata))
+	}
+	provider, generation := vs.embedder()
+	var embeddings []Vector
+	if len(texts) > 0 {
+		var err error
+		if embeddings, err = provider.Embed(ctx, texts); err != nil {
+			return 0, fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+	}
+
+	vs.mu.Lock()
+	if vs.generation != generation {
+		// Reindexed meanwhile, so embed with the new provider
+		vs.mu.Unlock()
+		return vs.ReplaceTexts(ctx, match, texts, metadata)
+	}
+	defer vs.mu.Unlock()
+	removed := vs.delete(match)
+	for i, text := range texts {
+		vs.add(embeddings[i], metadata[i], text)
+	}
+	return removed, nil
 }
 
 // Search finds similar texts in the vector store.
 func (vs *VectorStore) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
-	if len(vs.vectors) == 0 {
+	if vs.Count() == 0 {
 		return nil, fmt.Errorf("vector store is empty")
 	}
 
 	// Generate query embedding
-	queryVector, err := vs.provider.EmbedSingle(ctx, query)
+	provider, _ := vs.embedder()
+	queryVector, err := provider.EmbedSingle(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
-	// Calculate similarities
-	similarities := make([]SearchResult, len(vs.vectors))
-	for i, vector := range vs.vectors {
-		similarity := CosineSimilarity(queryVector, vector)
-		similarities[i] = SearchResult{
-			Index:      i,
-			Similarity: similarity,
-			Metadata:   vs.metadata[i],
-		}
-	}
-
-	// Sort by similarity (descending)
-	for i := 0; i < len(similarities)-1; i++ {
-		for j := i + 1; j < len(similarities); j++ {
-			if similarities[i].Similarity < similarities[j].Similarity {
-				similarities[i], similarities[j] = similarities[j], similarities[i]
-			}
-		}
-	}
-
-	// Apply threshold and limit
-	var results []SearchResult
-	for _, result := range similarities {
-		if result.Similarity >= vs.threshold && len(results) < limit {
-			results = append(results, result)
-		}
-	}
-
-	return results, nil
+	results, _, err := vs.candidates(queryVector, limit)
+	return results, err
 }
 
 // SearchResult represents a search result from the vector store.
@@ -305,85 +336,118 @@ type SearchResult struct {
 
 // SetThreshold sets the similarity threshold for search results.
 func (vs *VectorStore) SetThreshold(threshold float64) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
 	vs.threshold = threshold
 }
 
+// Version returns a number that changes whenever the entries or their
+// metadata change, including on Reindex, e.g. to know when answers built
+// from the store are out of date.
+func (vs *VectorStore) Version() uint64 {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.version
+}
+
 // Count returns the number of vectors in the store.
 func (vs *VectorStore) Count() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
 	return len(vs.vectors)
 }
 
-// Clear removes all vectors from the store.
-func (vs *VectorStore) Clear() {
-	vs.vectors = nil
-	vs.metadata = nil
-}
-
-// CosineSimilarity calculates the cosine similarity between two vectors.
-func CosineSimilarity(a, b Vector) float64 {
-	if len(a) != len(b) {
-		return 0
-	}
-
-	var dotProduct, normA, normB float64
-	for i := 0; i < len(a); i++ {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-
-	if normA == 0 || normB == 0 {
-		return 0
+// Find returns the metadata of the entries selected by match, e.g. all
+// entries with a given "user_id".
+func (vs *VectorStore) Find(match func(metadata map[string]interface{}) bool) []map[string]interface{} {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	var found []map[string]interface{}
+	for _, metadata := range vs.metadata {
+		if match(metadata) {
+			found = append(found, metadata)
+		}
 	}
-
-	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+	return found
 }
 
-// EuclideanDistance calculates the Euclidean distance between two vectors.
-func EuclideanDistance(a, b Vector) float64 {
-	if len(a) != len(b) {
-		return math.Inf(1)
-	}
-
-	var sum float64
-	for i := 0; i < len(a); i++ {
-		diff := a[i] - b[i]
-		sum += diff * diff
-	}
-
-	return math.Sqrt(sum)
+// Entries returns the vectors and metadata of all entries, e.g. to save the
+// store as a knowledge index.
+func (vs *VectorStore) Entries() ([]Vector, []map[string]interface{}) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return append([]Vector(nil), vs.vectors...), append([]map[string]interface{}(nil), vs.metadata...)
 }
 
-// DotProduct calculates the dot product of two vectors.
-func DotProduct(a, b Vector) float64 {
-	if len(a) != len(b) {
-		return 0
+// SetMetadata sets key to value in the metadata of the entries selected by
+// match and returns how many were selected. The metadata is copied, so maps
+// returned earlier by Find and Search do not change.
+func (vs *VectorStore) SetMetadata(match func(metadata map[string]interface{}) bool, key string, value interface{}) int {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	set := 0
+	for i, metadata := range vs.metadata {
+		if !match(metadata) {
+			continue
+		}
+		updated := make(map[string]interface{}, len(metadata)+1)
+		for k, v := range metadata {
+			updated[k] = v
+		}
+		updated[key] = value
+		vs.metadata[i] = updated
+		set++
 	}
-
-	var product float64
-	for i := 0; i < len(a); i++ {
-		product += a[i] * b[i]
+	if set > 0 {
+		vs.version++
 	}
-
-	return product
+	return set
 }
 
-// Normalize normalizes a vector to unit length.
-func Normalize(v Vector) Vector {
-	var norm float64
-	for _, val := range v {
-		norm += val * val
-	}
-	norm = math.Sqrt(norm)
-
-	if norm == 0 {
-		return v
-	}
+// Delete removes the entries selected by match and returns how many were
+// removed.
+func (vs *VectorStore) Delete(match func(metadata map[string]interface{}) bool) int {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	return vs.delete(match)
+}
 
-	normalized := make(Vector, len(v))
-	for i, val := range v {
-		normalized[i] = val / norm
-	}
+// delete removes the entries selected by match; the caller holds the lock.
+func (vs *VectorStore) delete(match func(metadata map[string]interface{}) bool) int {
+	kept := 0
+	for i, metadata := range vs.metadata {
+		if match(metadata) {
+			continue
+		}
+		vs.vectors[kept] = vs.vectors[i]
+		vs.norms[kept] = vs.norms[i]
+		vs.metadata[kept] = metadata
+		vs.texts[kept] = vs.texts[i]
+		vs.hashes[kept] = vs.hashes[i]
+		kept++
+	}
+	removed := len(vs.metadata) - kept
+	if removed > 0 {
+		vs.version++
+	}
+	clear(vs.vectors[kept:])
+	clear(vs.metadata[kept:])
+	vs.vectors = vs.vectors[:kept]
+	vs.norms = vs.norms[:kept]
+	vs.metadata = vs.metadata[:kept]
+	vs.texts = vs.texts[:kept]
+	vs.hashes = vs.hashes[:kept]
+	return removed
+}
 
-	return normalized
+// Clear removes all vectors from the store.
+func (vs *VectorStore) Clear() {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.version++
+	vs.vectors = nil
+	vs.norms = nil
+	vs.metadata = nil
+	vs.texts = nil
+	vs.hashes = nil
 }
@@ -0,0 +1,159 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// benchDimensions keeps the 100k-vector store at a manageable memory footprint.
+const benchDimensions = 128
+
+// benchProvider returns deterministic pseudo-random embeddings without network calls.
+type benchProvider struct {
+	rng *rand.Rand
+}
+
+func newBenchProvider() *benchProvider {
+	return &benchProvider{rng: rand.New(rand.NewSource(42))}
+}
+
+func (p *benchProvider) Embed(ctx context.Context, texts []string) ([]Vector, error) {
+	vectors := make([]Vector, len(texts))
+	for i := range texts {
+		vectors[i] = p.vector()
+	}
+	return vectors, nil
+}
+
+func (p *benchProvider) EmbedSingle(ctx context.Context, text string) (Vector, error) {
+	return p.vector(), nil
+}
+
+func (p *benchProvider) vector() Vector {
+	v := make(Vector, benchDimensions)
+	for i := range v {
+		v[i] = p.rng.Float64()*2 - 1
+	}
+	return v
+}
+
+func (p *benchProvider) Dimensions() int  { return benchDimensions }
+func (p *benchProvider) Model() string    { return "bench" }
+func (p *benchProvider) Provider() string { return "bench" }
+
+// newBenchStore returns a vector store filled with n random vectors.
+func newBenchStore(b *testing.B, n int) *VectorStore {
+	b.Helper()
+
+	provider := newBenchProvider()
+	store := NewVectorStore(provider)
+	store.SetThreshold(-1) // Rank every vector
+
+	texts := make([]string, n)
+	metadata := make([]map[string]interface{}, n)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("document %d", i)
+		metadata[i] = map[string]interface{}{"id": i}
+	}
+	if err := store.AddTexts(context.Background(), texts, metadata); err != nil {
+		b.Fatalf("failed to fill store: %v", err)
+	}
+	return store
+}
+
+func BenchmarkVectorStore_Search(b *testing.B) {
+	for _, size := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("vectors=%d", size), func(b *testing.B) {
+			if size > 10_000 && testing.Short() {
+				b.Skip("skipping large vector store in short mode")
+			}
+
+			store := newBenchStore(b, size)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Search(ctx, "query", 10); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCosineSimilarity(b *testing.B) {
+	provider := newBenchProvider()
+	x, y := provider.vector(), provider.vector()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CosineSimilarity(x, y)
+	}
+}
+
+func BenchmarkCosineSimilarity32(b *testing.B) {
+	provider := newBenchProvider()
+	x, y := provider.vector().Float32(), provider.vector().Float32()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CosineSimilarity32(x, y)
+	}
+}
+
+func BenchmarkDotProduct(b *testing.B) {
+	provider := newBenchProvider()
+	x, y := provider.vector(), provider.vector()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		DotProduct(x, y)
+	}
+}
+
+func BenchmarkVectorStore_SearchLimit(b *testing.B) {
+	store := newBenchStore(b, 10_000)
+	ctx := context.Background()
+	for _, limit := range []int{1, 10, 100, 1_000} {
+		b.Run(fmt.Sprintf("limit=%d", limit), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Search(ctx, "query", limit); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTopKSelection compares selecting the 10 best of 100k results
+// with the bounded heap against sorting them all.
+func BenchmarkTopKSelection(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	results := make([]SearchResult, 100_000)
+	for i := range results {
+		results[i] = SearchResult{Index: i, Similarity: rng.Float64()}
+	}
+
+	b.Run("heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			best := make(topK, 0, 10)
+			for _, result := range results {
+				best.offer(result, 10)
+			}
+		}
+	})
+	b.Run("sort", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			all := append([]SearchResult(nil), results...)
+			sort.Slice(all, func(i, j int) bool { return all[i].Similarity > all[j].Similarity })
+			_ = all[:10]
+		}
+	})
+}
@@ -375,3 +375,19 @@ func TestVectorStore_SearchEmptyStore(t *testing.T) {
 		t.Errorf("expected 'vector store is empty' error, got: %v", err)
 	}
 }
+
+func TestVectorStore_AddVectors(t *testing.T) {
+	vectorStore := NewVectorStore(NewOpenAIEmbeddingProvider(config.OpenAIConfig{APIKey: "test-key"}, ""))
+
+	err := vectorStore.AddVectors([]Vector{{1, 0}, {0, 1}}, []map[string]interface{}{{"text": "a"}, {"text": "b"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vectorStore.Count() != 2 {
+		t.Errorf("expected 2 items, got %d", vectorStore.Count())
+	}
+
+	if err := vectorStore.AddVectors([]Vector{{1, 0}}, nil); err == nil {
+		t.Error("expected length mismatch error")
+	}
+}
@@ -0,0 +1,74 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// DefaultMMRLambda balances relevance and diversity evenly.
+const DefaultMMRLambda = 0.5
+
+// SearchMMR finds texts similar to query like Search, but selects them by
+// maximal marginal relevance: each pick is the candidate most similar to
+// the query and least similar to the texts already picked. lambda weighs
+// relevance against diversity, from 1 (relevance only, like Search) to 0
+// (diversity only). Candidates are the 4×limit, and at least 20, most
+// similar texts above the threshold. The results' Similarity is still to
+// the query.
+func (vs *VectorStore) SearchMMR(ctx context.Context, query string, limit int, lambda float64) ([]SearchResult, error) {
+	if lambda < 0 || lambda > 1 {
+		return nil, fmt.Errorf("mmr lambda must be between 0 and 1, got %v", lambda)
+	}
+	if vs.Count() == 0 {
+		return nil, fmt.Errorf("vector store is empty")
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	provider, _ := vs.embedder()
+	queryVector, err := provider.EmbedSingle(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	results, vectors, err := vs.candidates(queryVector, max(4*limit, 20))
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]int, 0, min(limit, len(results)))
+	picked := make([]bool, len(results))
+	// redundancy holds each candidate's highest similarity to a pick
+	redundancy := make([]float64, len(results))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+	for len(selected) < limit && len(selected) < len(results) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, result := range results {
+			if picked[i] {
+				continue
+			}
+			score := lambda * result.Similarity
+			if len(selected) > 0 {
+				score -= (1 - lambda) * redundancy[i]
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		selected = append(selected, best)
+		for i := range results {
+			if !picked[i] {
+				redundancy[i] = max(redundancy[i], CosineSimilarity(vectors[i], vectors[best]))
+			}
+		}
+	}
+
+	mmr := make([]SearchResult, len(selected))
+	for i, index := range selected {
+		mmr[i] = results[index]
+	}
+	return mmr, nil
+}
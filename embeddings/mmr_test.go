@@ -0,0 +1,65 @@
+package embeddings
+
+import (
+	"context"
+	"testing"
+)
+
+// queryProvider embeds every query as the same vector.
+type queryProvider struct {
+	query Vector
+}
+
+func (p queryProvider) Embed(ctx context.Context, texts []string) ([]Vector, error) {
+	vectors := make([]Vector, len(texts))
+	for i := range texts {
+		vectors[i] = p.query
+	}
+	return vectors, nil
+}
+
+func (p queryProvider) EmbedSingle(ctx context.Context, text string) (Vector, error) {
+	return p.query, nil
+}
+
+func (p queryProvider) Dimensions() int  { return len(p.query) }
+func (p queryProvider) Model() string    { return "query" }
+func (p queryProvider) Provider() string { return "test" }
+
+func TestVectorStore_SearchMMR(t *testing.T) {
+	store := NewVectorStore(queryProvider{query: Vector{1, 1, 0}})
+	store.SetThreshold(0.1)
+	store.AddVectors([]Vector{
+		{1, 0.9, 0},   // most relevant
+		{1, 0.88, 0},  // near duplicate of the first
+		{0.9, 1, 0.3}, // relevant from another angle
+		{0, 0, 1},     // irrelevant, below the threshold
+	}, []map[string]interface{}{{"text": "a"}, {"text": "a2"}, {"text": "b"}, {"text": "c"}})
+
+	results, err := store.Search(context.Background(), "q", 2)
+	if err != nil || len(results) != 2 || results[1].Metadata["text"] != "a2" {
+		t.Fatalf("expected plain search to return the near duplicate, got %+v, %v", results, err)
+	}
+
+	results, err = store.SearchMMR(context.Background(), "q", 2, DefaultMMRLambda)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("unexpected results %+v, %v", results, err)
+	}
+	if results[0].Metadata["text"] != "a" || results[1].Metadata["text"] != "b" {
+		t.Errorf("expected MMR to skip the near duplicate, got %v and %v", results[0].Metadata, results[1].Metadata)
+	}
+	if results[1].Similarity <= 0.9 {
+		t.Errorf("expected the similarity to the query, got %v", results[1].Similarity)
+	}
+
+	results, _ = store.SearchMMR(context.Background(), "q", 2, 1)
+	if results[1].Metadata["text"] != "a2" {
+		t.Errorf("expected lambda 1 to rank by relevance only, got %v", results[1].Metadata)
+	}
+	if results, _ := store.SearchMMR(context.Background(), "q", 10, DefaultMMRLambda); len(results) != 3 {
+		t.Errorf("expected the entries above the threshold, got %d", len(results))
+	}
+	if _, err := store.SearchMMR(context.Background(), "q", 2, 1.5); err == nil {
+		t.Error("expected an invalid lambda to be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDimensionMismatch is returned by searches whose query embedding does
+// not match the store's, as after switching embedding models without
+// Reindex.
+var ErrDimensionMismatch = errors.New("embedding dimensions mismatch")
+
+// DefaultReindexBatchSize is how many texts Reindex embeds per request.
+const DefaultReindexBatchSize = 100
+
+// ReindexOption configures Reindex.
+type ReindexOption func(*reindexOptions)
+
+type reindexOptions struct {
+	batchSize int
+	progress  func(done, total int)
+}
+
+// WithBatchSize sets how many texts Reindex embeds per request.
+func WithBatchSize(size int) ReindexOption {
+	return func(o *reindexOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithProgress sets a function called after each batch Reindex embeds,
+// with the texts embedded so far and the total.
+func WithProgress(fn func(done, total int)) ReindexOption {
+	return func(o *reindexOptions) {
+		o.progress = fn
+	}
+}
+
+// Reindex re-embeds every entry with provider, such as a new embedding
+// model, and then swaps the new vectors and provider in at once: searches
+// see the old index until the whole new one is ready, and the store is
+// unchanged if embedding fails. Entries added while it runs are embedded
+// before the swap. Every entry needs its text, so entries added with
+// AddVectors need a "text" metadata.
+func (vs *VectorStore) Reindex(ctx context.Context, provider EmbeddingProvider, opts ...ReindexOption) error {
+	options := reindexOptions{batchSize: DefaultReindexBatchSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.batchSize <= 0 {
+		return fmt.Errorf("reindex batch size must be positive, got %d", options.batchSize)
+	}
+
+	vectors := make(map[string]Vector)
+	done, total := 0, 0
+	for {
+		vs.mu.RLock()
+		pending, err := vs.unembedded(vectors)
+		vs.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		total += len(pending)
+		for start := 0; start < len(pending); start += options.batchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			batch := pending[start:min(start+options.batchSize, len(pending))]
+			embeddings, err := provider.Embed(ctx, batch)
+			if err != nil {
+				return fmt.Errorf("failed to generate embeddings: %w", err)
+			}
+			for i, text := range batch {
+				vectors[text] = embeddings[i]
+			}
+			done += len(batch)
+			if options.progress != nil {
+				options.progress(done, total)
+			}
+		}
+
+		vs.mu.Lock()
+		if pending, err = vs.unembedded(vectors); err != nil || len(pending) > 0 {
+			// Entries were added meanwhile, or one cannot be re-embedded
+			vs.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		swapped := make([]Vector, len(vs.texts))
+		norms := make([]float64, len(vs.texts))
+		for i, text := range vs.texts {
+			swapped[i] = vectors[text]
+			norms[i] = Norm(swapped[i])
+		}
+		vs.vectors, vs.norms = swapped, norms
+		vs.provider = provider
+		vs.generation++
+		vs.version++
+		vs.mu.Unlock()
+		return nil
+	}
+}
+
+// unembedded returns the distinct texts of the entries that vectors has no
+// embedding for. The caller holds the lock.
+func (vs *VectorStore) unembedded(vectors map[string]Vector) ([]string, error) {
+	var pending []string
+	seen := make(map[string]bool)
+	for i, text := range vs.texts {
+		if text == "" {
+			return nil, fmt.Errorf("entry %d has no text to re-embed", i)
+		}
+		if _, ok := vectors[text]; !ok && !seen[text] {
+			seen[text] = true
+			pending = append(pending, text)
+		}
+	}
+	return pending, nil
+}
+
+// embedder returns the provider and its generation.
+func (vs *VectorStore) embedder() (EmbeddingProvider, int) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.provider, vs.generation
+}
+
+// checkDimensions reports a query embedding that cannot be compared with
+// the entries. The caller holds the lock.
+func (vs *VectorStore) checkDimensions(query Vector) error {
+	if len(vs.vectors) > 0 && len(query) != len(vs.vectors[0]) {
+		return fmt.Errorf("%w: the query has %d dimensions and the store %d; reindex the store with the query's model",
+			ErrDimensionMismatch, len(query), len(vs.vectors[0]))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVectorStore_Reindex(t *testing.T) {
+	ctx := context.Background()
+	store := NewVectorStore(newTextProvider())
+	texts := []string{"Refunds take five days.", "Shipping is free.", "Refunds take 5 days."}
+	if err := store.AddTexts(ctx, texts, []map[string]interface{}{{"id": 1}, {"id": 2}, {"id": 3}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The new model embeds in three dimensions, so its queries cannot be
+	// compared with the old index
+	next := &textProvider{vectors: map[string]Vector{
+		"Refunds take five days.": {1, 0, 0},
+		"Refunds take 5 days.":    {0.9, 0.1, 0},
+		"Shipping is free.":       {0, 0, 1},
+		"free shipping":           {0, 0, 1},
+	}}
+	mixed := NewVectorStore(next)
+	mixed.AddVectors([]Vector{{1, 0}}, []map[string]interface{}{{"text": "Shipping is free."}})
+	if _, err := mixed.Search(ctx, "free shipping", 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected a dimension mismatch, got %v", err)
+	}
+
+	var progress [][2]int
+	err := store.Reindex(ctx, next, WithBatchSize(2), WithProgress(func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(progress) != 2 || progress[0] != [2]int{2, 3} || progress[1] != [2]int{3, 3} {
+		t.Errorf("expected progress per batch, got %v", progress)
+	}
+	results, err := store.Search(ctx, "free shipping", 1)
+	if err != nil || len(results) != 1 || results[0].Metadata["id"] != 2 {
+		t.Errorf("expected the new model to find the entry, got %+v, %v", results, err)
+	}
+	if err := mixed.Reindex(ctx, next); err != nil {
+		t.Errorf("expected entries with a text metadata to be re-embedded, got %v", err)
+	}
+}
+
+func TestVectorStore_ReindexFailureKeepsIndex(t *testing.T) {
+	store := NewVectorStore(newTextProvider())
+	store.AddVectors([]Vector{{1, 0}, {0, 1}}, []map[string]interface{}{{"text": "Refunds take five days."}, {}})
+	if err := store.Reindex(context.Background(), &textProvider{}); err == nil {
+		t.Fatal("expected an error for the entry without text")
+	}
+	results, err := store.Search(context.Background(), "Refunds take five days.", 1)
+	if err != nil || len(results) != 1 {
+		t.Errorf("expected the old index to keep working, got %+v, %v", results, err)
+	}
+}
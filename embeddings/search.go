@@ -0,0 +1,118 @@
+package embeddings
+
+import (
+	"container/heap"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// parallelSearchEntries is the store size from which searches scan the
+// entries on all CPUs; below it, starting goroutines costs more than it
+// saves.
+const parallelSearchEntries = 8192
+
+// candidates returns up to n entries above the threshold, most similar to
+// queryVector first, with their vectors. Equally similar entries keep the
+// order in which they were added.
+func (vs *VectorStore) candidates(queryVector Vector, n int) ([]SearchResult, []Vector, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	if err := vs.checkDimensions(queryVector); err != nil {
+		return nil, nil, err
+	}
+	if n <= 0 {
+		return nil, nil, nil
+	}
+
+	norm := Norm(queryVector)
+	workers := min(runtime.GOMAXPROCS(0), len(vs.vectors)/parallelSearchEntries)
+	var best topK
+	if workers <= 1 {
+		best = vs.scan(queryVector, norm, n, 0, len(vs.vectors))
+	} else {
+		// Each worker keeps the best n of its share, and the best of those
+		// are the best overall
+		partial := make([]topK, workers)
+		size := (len(vs.vectors) + workers - 1) / workers
+		var wg sync.WaitGroup
+		for w := range partial {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				partial[w] = vs.scan(queryVector, norm, n, w*size, min((w+1)*size, len(vs.vectors)))
+			}(w)
+		}
+		wg.Wait()
+		best = make(topK, 0, n)
+		for _, results := range partial {
+			for _, result := range results {
+				best.offer(result, n)
+			}
+		}
+	}
+
+	results := []SearchResult(best)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return results[i].Index < results[j].Index
+	})
+	vectors := make([]Vector, len(results))
+	for i, result := range results {
+		results[i].Metadata = vs.metadata[result.Index]
+		vectors[i] = vs.vectors[result.Index]
+	}
+	return results, vectors, nil
+}
+
+// scan returns the n entries from start to end most similar to query above
+// the threshold, without metadata. The caller holds the lock.
+func (vs *VectorStore) scan(query Vector, norm float64, n, start, end int) topK {
+	best := make(topK, 0, min(n, end-start))
+	for i := start; i < end; i++ {
+		if similarity := vs.similarity(query, norm, i); similarity >= vs.threshold {
+			best.offer(SearchResult{Index: i, Similarity: similarity}, n)
+		}
+	}
+	return best
+}
+
+// topK is a bounded min-heap of search results: its root is the least
+// similar result kept, the first to give way to a better one. Of equally
+// similar results, the one added last is the least.
+type topK []SearchResult
+
+func (h topK) Len() int { return len(h) }
+
+func (h topK) Less(i, j int) bool {
+	if h[i].Similarity != h[j].Similarity {
+		return h[i].Similarity < h[j].Similarity
+	}
+	return h[i].Index > h[j].Index
+}
+
+func (h topK) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *topK) Push(x interface{}) { *h = append(*h, x.(SearchResult)) }
+
+func (h *topK) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// offer keeps result if it is among the n best so far.
+func (h *topK) offer(result SearchResult, n int) {
+	if len(*h) < n {
+		heap.Push(h, result)
+		return
+	}
+	root := (*h)[0]
+	if result.Similarity > root.Similarity || (result.Similarity == root.Similarity && result.Index < root.Index) {
+		(*h)[0] = result
+		heap.Fix(h, 0)
+	}
+}
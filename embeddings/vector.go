@@ -0,0 +1,167 @@
+package embeddings
+
+import "math"
+
+// Vector32 is an embedding vector in single precision, half the memory of a
+// Vector. Embedding models return far less precision than float32 holds,
+// so similarities barely change.
+type Vector32 []float32
+
+// Float32 converts v to single precision.
+func (v Vector) Float32() Vector32 {
+	converted := make(Vector32, len(v))
+	for i, val := range v {
+		converted[i] = float32(val)
+	}
+	return converted
+}
+
+// Float64 converts v to double precision.
+func (v Vector32) Float64() Vector {
+	converted := make(Vector, len(v))
+	for i, val := range v {
+		converted[i] = float64(val)
+	}
+	return converted
+}
+
+// CosineSimilarity calculates the cosine similarity between two vectors.
+func CosineSimilarity(a, b Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	// One pass over both vectors, in two independent sums of each
+	b = b[:len(a)]
+	var product0, product1, normA0, normA1, normB0, normB1 float64
+	i := 0
+	for ; i+2 <= len(a); i += 2 {
+		product0 += a[i] * b[i]
+		product1 += a[i+1] * b[i+1]
+		normA0 += a[i] * a[i]
+		normA1 += a[i+1] * a[i+1]
+		normB0 += b[i] * b[i]
+		normB1 += b[i+1] * b[i+1]
+	}
+	if i < len(a) {
+		product0 += a[i] * b[i]
+		normA0 += a[i] * a[i]
+		normB0 += b[i] * b[i]
+	}
+	return cosine(product0+product1, math.Sqrt(normA0+normA1), math.Sqrt(normB0+normB1))
+}
+
+// CosineSimilarity32 calculates the cosine similarity between two single
+// precision vectors.
+func CosineSimilarity32(a, b Vector32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	return cosine(float64(dot32(a, b)), math.Sqrt(float64(dot32(a, a))), math.Sqrt(float64(dot32(b, b))))
+}
+
+// EuclideanDistance calculates the Euclidean distance between two vectors.
+func EuclideanDistance(a, b Vector) float64 {
+	if len(a) != len(b) {
+		return math.Inf(1)
+	}
+
+	var sum float64
+	for i := 0; i < len(a); i++ {
+		diff := a[i] - b[i]
+		sum += diff * diff
+	}
+
+	return math.Sqrt(sum)
+}
+
+// DotProduct calculates the dot product of two vectors.
+func DotProduct(a, b Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	return dot(a, b)
+}
+
+// DotProduct32 calculates the dot product of two single precision vectors.
+func DotProduct32(a, b Vector32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	return dot32(a, b)
+}
+
+// Norm returns the length of v.
+func Norm(v Vector) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+// Normalize normalizes a vector to unit length.
+func Normalize(v Vector) Vector {
+	norm := Norm(v)
+	if norm == 0 {
+		return v
+	}
+
+	normalized := make(Vector, len(v))
+	for i, val := range v {
+		normalized[i] = val / norm
+	}
+
+	return normalized
+}
+
+// cosine returns the cosine similarity of vectors with the dot product
+// product and lengths normA and normB.
+func cosine(product, normA, normB float64) float64 {
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return product / (normA * normB)
+}
+
+// dot returns the dot product of a and b, which have the same length. The
+// loop is unrolled into four independent sums, which the CPU can compute in
+// parallel.
+func dot(a, b []float64) float64 {
+	b = b[:len(a)] // lets the compiler drop the bounds checks
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// dot32 is dot for single precision.
+func dot32(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// similarity returns the cosine similarity of query, whose length is norm,
+// and entry i, using the entry's stored length. The caller holds the lock.
+func (vs *VectorStore) similarity(query Vector, norm float64, i int) float64 {
+	vector := vs.vectors[i]
+	if len(vector) != len(query) {
+		return 0
+	}
+	return cosine(dot(query, vector), norm, vs.norms[i])
+}
@@ -0,0 +1,96 @@
+package embeddings
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestVectorMathMatchesNaiveLoops(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Lengths around the unrolling cover the leftover elements
+	for n := 0; n <= 9; n++ {
+		a, b := make(Vector, n), make(Vector, n)
+		var product, normA, normB float64
+		for i := range a {
+			a[i], b[i] = rng.Float64()*2-1, rng.Float64()*2-1
+			product += a[i] * b[i]
+			normA += a[i] * a[i]
+			normB += b[i] * b[i]
+		}
+		cosine := 0.0
+		if normA > 0 && normB > 0 {
+			cosine = product / (math.Sqrt(normA) * math.Sqrt(normB))
+		}
+
+		if got := DotProduct(a, b); math.Abs(got-product) > 1e-12 {
+			t.Errorf("n=%d: expected dot product %v, got %v", n, product, got)
+		}
+		if got := CosineSimilarity(a, b); math.Abs(got-cosine) > 1e-12 {
+			t.Errorf("n=%d: expected cosine similarity %v, got %v", n, cosine, got)
+		}
+		if got := CosineSimilarity32(a.Float32(), b.Float32()); math.Abs(got-cosine) > 1e-5 {
+			t.Errorf("n=%d: expected single precision cosine similarity near %v, got %v", n, cosine, got)
+		}
+		if got := float64(DotProduct32(a.Float32(), b.Float32())); math.Abs(got-product) > 1e-5 {
+			t.Errorf("n=%d: expected single precision dot product near %v, got %v", n, product, got)
+		}
+	}
+
+	if CosineSimilarity(Vector{1, 2}, Vector{1, 2, 3}) != 0 || DotProduct32(Vector32{1}, Vector32{1, 2}) != 0 {
+		t.Error("expected vectors of different lengths to have no similarity")
+	}
+	if v := (Vector{0.5, -0.25}).Float32().Float64(); v[0] != 0.5 || v[1] != -0.25 {
+		t.Errorf("expected a lossless round trip, got %v", v)
+	}
+}
+
+func TestVectorStore_SearchTopResults(t *testing.T) {
+	// Large stores are searched in parallel
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for _, size := range []int{500, 3 * parallelSearchEntries} {
+		provider := newBenchProvider()
+		vectors := make([]Vector, size)
+		metadata := make([]map[string]interface{}, size)
+		for i := range vectors {
+			vectors[i] = provider.vector()
+			metadata[i] = map[string]interface{}{"index": i}
+		}
+		// Ties keep the order of the entries, across workers too
+		query := vectors[5]
+		vectors[10], vectors[size-1] = query, query
+		store := NewVectorStore(queryProvider{query: query})
+		store.SetThreshold(0)
+		store.AddVectors(vectors, metadata)
+
+		results, err := store.Search(context.Background(), "query", 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		type scored struct {
+			index      int
+			similarity float64
+		}
+		var want []scored
+		for i, vector := range vectors {
+			if similarity := CosineSimilarity(query, vector); similarity >= 0 {
+				want = append(want, scored{i, similarity})
+			}
+		}
+		sort.SliceStable(want, func(i, j int) bool { return want[i].similarity > want[j].similarity })
+		if len(results) != 10 {
+			t.Fatalf("size %d: expected 10 results, got %d", size, len(results))
+		}
+		for i, result := range results {
+			if result.Index != want[i].index || math.Abs(result.Similarity-want[i].similarity) > 1e-12 ||
+				result.Metadata["index"] != result.Index {
+				t.Errorf("size %d, result %d: expected entry %d (%v), got %d (%v)",
+					size, i, want[i].index, want[i].similarity, result.Index, result.Similarity)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package gochatbot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WriteJSONWithETag writes v as JSON with a strong ETag of its content, or
+// only 304 Not Modified if the request's If-None-Match matches the ETag, so
+// polling clients, such as chat UIs refreshing a conversation list, do not
+// download unchanged responses again. Set Cache-Control and Vary before
+// calling it. Nothing is written if v cannot be encoded.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if ETagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(body)
+	return err
+}
+
+// ETagMatches reports whether an If-None-Match header value matches etag:
+// it is "*" or lists etag. Tags are compared weakly, as If-None-Match
+// requires, so W/"a" and "a" match. A malformed header matches nothing.
+func ETagMatches(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return false
+		}
+		header = strings.TrimPrefix(header, "W/")
+		// Tags are quoted and may contain commas
+		if !strings.HasPrefix(header, `"`) {
+			return false
+		}
+		end := strings.IndexByte(header[1:], '"')
+		if end < 0 {
+			return false
+		}
+		if header[:end+2] == etag {
+			return true
+		}
+		header = header[end+2:]
+	}
+}
@@ -0,0 +1,76 @@
+package gochatbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		want   bool
+	}{
+		{name: "strong", header: `"abc"`, etag: `"abc"`, want: true},
+		{name: "different", header: `"abc"`, etag: `"abd"`},
+		{name: "weak header", header: `W/"abc"`, etag: `"abc"`, want: true},
+		{name: "weak etag", header: `"abc"`, etag: `W/"abc"`, want: true},
+		{name: "both weak", header: `W/"abc"`, etag: `W/"abc"`, want: true},
+		{name: "any", header: `*`, etag: `"abc"`, want: true},
+		{name: "any with spaces", header: ` * `, etag: `"abc"`, want: true},
+		{name: "list", header: `"x", W/"y", "abc"`, etag: `"abc"`, want: true},
+		{name: "list without spaces", header: `"x","abc"`, etag: `"abc"`, want: true},
+		{name: "list without match", header: `"x", W/"y"`, etag: `"abc"`},
+		{name: "comma in tag", header: `"a,b"`, etag: `"a,b"`, want: true},
+		{name: "part of a tag", header: `"a,b"`, etag: `"a"`},
+		{name: "empty", header: ``, etag: `"abc"`},
+		{name: "unquoted", header: `abc`, etag: `"abc"`},
+		{name: "unterminated", header: `"abc`, etag: `"abc"`},
+		{name: "star in a list", header: `"x", *`, etag: `"abc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ETagMatches(tt.header, tt.etag); got != tt.want {
+				t.Errorf("ETagMatches(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONWithETag(t *testing.T) {
+	listing := []string{"a", "b"}
+	w := httptest.NewRecorder()
+	if err := WriteJSONWithETag(w, httptest.NewRequest("GET", "/conversations", nil), listing); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.String() != `["a","b"]` {
+		t.Fatalf("expected the listing with an ETag, got %d %q: %s", w.Code, etag, w.Body.String())
+	}
+
+	// The same content is not sent again
+	req := httptest.NewRequest("GET", "/conversations", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	w = httptest.NewRecorder()
+	if err := WriteJSONWithETag(w, req, listing); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Errorf("expected 304 Not Modified, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Changed content is
+	w = httptest.NewRecorder()
+	if err := WriteJSONWithETag(w, req, append(listing, "c")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("expected the changed listing with a new ETag, got %d", w.Code)
+	}
+
+	if err := WriteJSONWithETag(httptest.NewRecorder(), req, func() {}); err == nil {
+		t.Error("expected an error for a value that cannot be encoded")
+	}
+}
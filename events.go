@@ -0,0 +1,159 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/middleware"
+	"go.rumenx.com/chatbot/models"
+)
+
+// WithEventPublisher publishes chat events (message received, reply
+// generated, moderation flagged, tool called) to publisher. Publishing
+// happens inline, so wrap network publishers with events.NewBuffered.
+// Failures never fail the request; they are reported through
+// Hooks.OnEventError.
+func WithEventPublisher(publisher events.Publisher) Option {
+	return func(c *Chatbot) {
+		c.publisher = publisher
+	}
+}
+
+// publishEvent sends an event, filling in the user and conversation from the
+// request context.
+func (c *Chatbot) publishEvent(ctx context.Context, eventType events.Type, askContext map[string]interface{}, data map[string]interface{}) {
+	if !c.publishing(ctx) {
+		return
+	}
+
+	event := events.New(eventType, data)
+	event.UserID, _ = askContext["user_id"].(string)
+	event.ConversationID, _ = askContext["conversation_id"].(string)
+	if outbox := eventOutboxFrom(ctx); outbox != nil {
+		outbox.add(event)
+		return
+	}
+	c.sendEvents(ctx, []events.Event{event})
+}
+
+// publishing reports whether events of the request are published.
+func (c *Chatbot) publishing(ctx context.Context) bool {
+	return c.publisher != nil || eventOutboxFrom(ctx) != nil
+}
+
+// sendEvents publishes events to the publisher, if any.
+func (c *Chatbot) sendEvents(ctx context.Context, evts []events.Event) {
+	if c.publisher == nil {
+		return
+	}
+	for _, event := range evts {
+		// Publish even if the request itself was cancelled or timed out
+		if err := c.publisher.Publish(context.WithoutCancel(ctx), event); err != nil && c.hooks.OnEventError != nil {
+			c.hooks.OnEventError(event, err)
+		}
+	}
+}
+
+// publishReceived publishes the message received event and, if the filter
+// changed or flagged the message, a moderation event.
+func (c *Chatbot) publishReceived(ctx context.Context, message string, filtered *middleware.FilteredMessage, askContext map[string]interface{}) {
+	if !c.publishing(ctx) {
+		return
+	}
+
+	data := map[string]interface{}{
+		"message": filtered.Message,
+	}
+	if label, ok := askContext["sentiment"].(string); ok {
+		data["sentiment"] = label
+		data["sentiment_score"] = askContext["sentiment_score"]
+	}
+	c.publishEvent(ctx, events.MessageReceived, askContext, data)
+
+	var reasons []string
+	if filtered.Message != message {
+		reasons = append(reasons, "content_filtered")
+	}
+	for _, flag := range []string{"aggression_detected", "links_filtered"} {
+		if detected, _ := filtered.Context[flag].(bool); detected {
+			reasons = append(reasons, flag)
+		}
+	}
+	if len(reasons) > 0 {
+		c.publishEvent(ctx, events.ModerationFlagged, askContext, map[string]interface{}{
+			"reasons": reasons,
+		})
+	}
+}
+
+// publishReply publishes the reply generated event and tracks it for
+// analytics. Token counts are estimated at about four characters per token.
+func (c *Chatbot) publishReply(ctx context.Context, model models.Model, prompt, reply string, started time.Time, askContext map[string]interface{}) {
+	data := map[string]interface{}{
+		"reply":             reply,
+		"model":             model.Name(),
+		"provider":          model.Provider(),
+		"latency_ms":        time.Since(started).Milliseconds(),
+		"prompt_tokens":     len(prompt) / 4,
+		"completion_tokens": len(reply) / 4,
+	}
+	// Tag replies from A/B tests with the assigned variant
+	if variant, ok := askContext["variant"].(string); ok {
+		data["experiment"] = askContext["experiment"]
+		data["variant"] = variant
+	}
+	chunks := c.retrievedChunks(reply, askContext)
+	if chunks != nil {
+		data["retrieved_chunks"] = chunks
+	}
+	c.publishEvent(ctx, events.ReplyGenerated, askContext, data)
+
+	// Analytics gets the same data without the reply itself
+	properties := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if key != "reply" && key != "retrieved_chunks" {
+			properties[key] = value
+		}
+	}
+	properties["reply_length"] = len(reply)
+	if chunks != nil {
+		cited := 0
+		for _, chunk := range chunks {
+			if chunk.Cited {
+				cited++
+			}
+		}
+		properties["retrieved_chunks"] = len(chunks)
+		properties["cited_chunks"] = cited
+		properties["top_retrieval_score"] = chunks[0].Score
+	}
+	c.track(ctx, analytics.ReplyGenerated, askContext, properties)
+}
+
+// collectReply forwards streamed chunks and publishes the full reply once the
+// stream ends.
+func (c *Chatbot) collectReply(ctx context.Context, model models.Model, prompt string, in <-chan string, started time.Time, askContext map[string]interface{}) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer c.recoverBackground()
+
+		var reply strings.Builder
+		for chunk := range in {
+			reply.WriteString(chunk)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Drain so the model's goroutine can finish
+				for range in {
+				}
+				return
+			}
+		}
+		c.publishReply(ctx, model, prompt, reply.String(), started, askContext)
+	}()
+	return out
+}
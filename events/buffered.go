@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBufferFull is returned by a buffered publisher when its queue is full.
+// The event is dropped rather than blocking the caller.
+var ErrBufferFull = errors.New("event buffer is full")
+
+// BufferedOption configures NewBuffered.
+type BufferedOption func(*Buffered)
+
+// WithPublishTimeout bounds each call to the underlying publisher (default 10s).
+func WithPublishTimeout(timeout time.Duration) BufferedOption {
+	return func(b *Buffered) {
+		b.timeout = timeout
+	}
+}
+
+// WithErrorHandler sets a function called when the underlying publisher
+// fails. By default errors are discarded.
+func WithErrorHandler(fn func(event Event, err error)) BufferedOption {
+	return func(b *Buffered) {
+		b.onError = fn
+	}
+}
+
+// Buffered publishes events from a background goroutine so callers never
+// wait on the broker.
+type Buffered struct {
+	publisher Publisher
+	queue     chan Event
+	timeout   time.Duration
+	onError   func(event Event, err error)
+	done      chan struct{}
+
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// NewBuffered wraps publisher with a queue of the given size.
+func NewBuffered(publisher Publisher, size int, opts ...BufferedOption) *Buffered {
+	if size < 1 {
+		size = 1
+	}
+	b := &Buffered{
+		publisher: publisher,
+		queue:     make(chan Event, size),
+		timeout:   10 * time.Second,
+		onError:   func(Event, error) {},
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	go b.run()
+	return b
+}
+
+// Publish implements Publisher. It returns ErrBufferFull without blocking
+// when the queue is full.
+func (b *Buffered) Publish(ctx context.Context, event Event) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	select {
+	case b.queue <- event:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// Close implements Publisher. It publishes the queued events and closes the
+// underlying publisher.
+func (b *Buffered) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mutex.Unlock()
+
+	<-b.done
+	return b.publisher.Close()
+}
+
+// run publishes queued events until the queue is closed.
+func (b *Buffered) run() {
+	defer close(b.done)
+
+	for event := range b.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+		if err := b.publisher.Publish(ctx, event); err != nil {
+			b.onError(event, err)
+		}
+		cancel()
+	}
+}
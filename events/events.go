@@ -0,0 +1,143 @@
+// Package events publishes structured chat events (messages received, replies
+// generated, tools called, messages flagged by moderation, conversations
+// escalated to humans) to message brokers such as Kafka or NATS, so
+// downstream analytics pipelines can consume them, or to webhooks, so
+// external systems stay in sync.
+//
+// Publishers implement the Publisher interface. NewNATS and NewKafkaREST talk
+// to the brokers without extra dependencies, NewWebhook posts signed events
+// to HTTP endpoints, and Multi sends events to several publishers; other
+// clients can be adapted with PublisherFunc. Wrap slow publishers with NewBuffered so chat requests
+// never wait on the broker:
+//
+//	nats, _ := events.NewNATS(events.NATSConfig{URL: "nats://localhost:4222"})
+//	bot, _ := gochatbot.New(cfg, gochatbot.WithEventPublisher(events.NewBuffered(nats, 1000)))
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies the kind of event.
+type Type string
+
+// Event types published by the chatbot.
+const (
+	// MessageReceived is published when a user message is accepted.
+	MessageReceived Type = "message.received"
+	// ReplyGenerated is published when the model has answered.
+	ReplyGenerated Type = "reply.generated"
+	// ToolCalled is published when a model invokes a tool.
+	ToolCalled Type = "tool.called"
+	// ModerationFlagged is published when the message filter changed or
+	// flagged a user message.
+	ModerationFlagged Type = "moderation.flagged"
+	// ConversationEscalated is published when a conversation is handed to
+	// a human, by handoff.EventNotifier.
+	ConversationEscalated Type = "conversation.escalated"
+	// ConversationStoreFailed is published when the conversation store
+	// fails and the chatbot answers without it (WithStatelessFallback).
+	ConversationStoreFailed Type = "conversation.store_failed"
+)
+
+// ErrClosed is returned when publishing to a closed publisher.
+var ErrClosed = errors.New("publisher is closed")
+
+// Event is a structured chat event.
+type Event struct {
+	ID             string                 `json:"id"`
+	Type           Type                   `json:"type"`
+	Time           time.Time              `json:"time"`
+	ConversationID string                 `json:"conversation_id,omitempty"`
+	UserID         string                 `json:"user_id,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+}
+
+// New creates an event of the given type with a unique ID and the current time.
+func New(eventType Type, data map[string]interface{}) Event {
+	return Event{
+		ID:   uuid.New().String(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+}
+
+// Key returns the partitioning key for the event: the conversation ID,
+// falling back to the user ID and then the event ID.
+func (e Event) Key() string {
+	switch {
+	case e.ConversationID != "":
+		return e.ConversationID
+	case e.UserID != "":
+		return e.UserID
+	default:
+		return e.ID
+	}
+}
+
+// Publisher sends events to a broker.
+type Publisher interface {
+	// Publish sends a single event.
+	Publish(ctx context.Context, event Event) error
+
+	// Close flushes pending events and releases resources.
+	Close() error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc func(ctx context.Context, event Event) error
+
+// Publish implements Publisher.
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Close implements Publisher.
+func (f PublisherFunc) Close() error {
+	return nil
+}
+
+// Multi returns a publisher sending each event to every publisher, e.g. to
+// several webhooks. Failures are joined into one error; Close closes them
+// all.
+func Multi(publishers ...Publisher) Publisher {
+	return multi(publishers)
+}
+
+type multi []Publisher
+
+// Publish implements Publisher.
+func (m multi) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, publisher := range m {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements Publisher.
+func (m multi) Close() error {
+	var errs []error
+	for _, publisher := range m {
+		if err := publisher.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subject returns the subject or topic name for an event type under prefix,
+// e.g. "chatbot.reply.generated".
+func Subject(prefix string, eventType Type) string {
+	if prefix == "" {
+		return string(eventType)
+	}
+	return prefix + "." + string(eventType)
+}
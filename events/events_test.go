@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	event := New(ReplyGenerated, map[string]interface{}{"reply": "hi"})
+
+	if event.ID == "" || event.Type != ReplyGenerated || event.Time.IsZero() {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.Key() != event.ID {
+		t.Errorf("expected event ID as fallback key, got %q", event.Key())
+	}
+
+	event.UserID = "u1"
+	if event.Key() != "u1" {
+		t.Errorf("expected user ID key, got %q", event.Key())
+	}
+	event.ConversationID = "c1"
+	if event.Key() != "c1" {
+		t.Errorf("expected conversation ID key, got %q", event.Key())
+	}
+}
+
+func TestSubject(t *testing.T) {
+	if got := Subject("chatbot", MessageReceived); got != "chatbot.message.received" {
+		t.Errorf("unexpected subject %q", got)
+	}
+	if got := Subject("", ToolCalled); got != "tool.called" {
+		t.Errorf("unexpected subject %q", got)
+	}
+}
+
+func TestBufferedPublishesInBackground(t *testing.T) {
+	var mutex sync.Mutex
+	var published []Type
+	release := make(chan struct{})
+	publisher := PublisherFunc(func(ctx context.Context, event Event) error {
+		<-release
+		mutex.Lock()
+		defer mutex.Unlock()
+		published = append(published, event.Type)
+		return nil
+	})
+
+	buffered := NewBuffered(publisher, 10)
+	for _, eventType := range []Type{MessageReceived, ReplyGenerated} {
+		if err := buffered.Publish(context.Background(), New(eventType, nil)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	close(release)
+	if err := buffered.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(published) != 2 || published[0] != MessageReceived || published[1] != ReplyGenerated {
+		t.Errorf("expected queued events to be flushed in order, got %v", published)
+	}
+	if err := buffered.Publish(context.Background(), New(MessageReceived, nil)); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestBufferedDropsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	publisher := PublisherFunc(func(ctx context.Context, event Event) error {
+		<-release
+		return nil
+	})
+	buffered := NewBuffered(publisher, 1)
+	defer func() {
+		close(release)
+		_ = buffered.Close()
+	}()
+
+	// The worker holds one event and the queue holds another
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = buffered.Publish(context.Background(), New(MessageReceived, nil))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, ErrBufferFull) {
+		t.Errorf("expected ErrBufferFull, got %v", err)
+	}
+}
+
+func TestBufferedReportsErrors(t *testing.T) {
+	failure := errors.New("broker down")
+	reported := make(chan error, 1)
+	buffered := NewBuffered(PublisherFunc(func(ctx context.Context, event Event) error {
+		return failure
+	}), 1, WithErrorHandler(func(event Event, err error) { reported <- err }))
+
+	_ = buffered.Publish(context.Background(), New(MessageReceived, nil))
+	_ = buffered.Close()
+
+	if err := <-reported; !errors.Is(err, failure) {
+		t.Errorf("expected publisher error, got %v", err)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	failure := errors.New("endpoint down")
+	var received []Type
+	publisher := Multi(
+		PublisherFunc(func(ctx context.Context, event Event) error {
+			received = append(received, event.Type)
+			return nil
+		}),
+		PublisherFunc(func(ctx context.Context, event Event) error {
+			return failure
+		}),
+		PublisherFunc(func(ctx context.Context, event Event) error {
+			received = append(received, event.Type)
+			return nil
+		}),
+	)
+
+	if err := publisher.Publish(context.Background(), New(ReplyGenerated, nil)); !errors.Is(err, failure) {
+		t.Errorf("expected the failure to be reported, got %v", err)
+	}
+	if len(received) != 2 {
+		t.Errorf("expected every publisher to receive the event, got %v", received)
+	}
+	if err := publisher.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaConfig configures publishing to Kafka through a Confluent-compatible
+// REST Proxy.
+type KafkaConfig struct {
+	// URL is the REST Proxy base URL, e.g. "http://localhost:8082".
+	URL string
+	// Topic receives every event. If empty, each event goes to a topic named
+	// by Subject(TopicPrefix, event.Type).
+	Topic string
+	// TopicPrefix prefixes per-type topics (default "chatbot").
+	TopicPrefix string
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// KafkaREST publishes events to Kafka through the REST Proxy v2 API. Events
+// are keyed by Event.Key so a conversation's events stay in one partition.
+// To use a native Kafka client instead, wrap its producer in a PublisherFunc.
+type KafkaREST struct {
+	config KafkaConfig
+	client *http.Client
+}
+
+// NewKafkaREST creates a Kafka REST Proxy publisher.
+func NewKafkaREST(cfg KafkaConfig) (*KafkaREST, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("kafka REST proxy URL is required")
+	}
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = "chatbot"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &KafkaREST{config: cfg, client: client}, nil
+}
+
+// Publish implements Publisher.
+func (k *KafkaREST) Publish(ctx context.Context, event Event) error {
+	topic := k.config.Topic
+	if topic == "" {
+		topic = Subject(k.config.TopicPrefix, event.Type)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"key": event.Key(), "value": event}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	endpoint := strings.TrimRight(k.config.URL, "/") + "/topics/" + url.PathEscape(topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range k.config.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("kafka REST proxy returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	// The proxy reports per-record failures in a 200 response
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err == nil {
+		for _, offset := range result.Offsets {
+			if offset.Error != "" {
+				return fmt.Errorf("kafka rejected event: %s", offset.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements Publisher.
+func (k *KafkaREST) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKafkaRESTPublish(t *testing.T) {
+	var path, contentType string
+	var body struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+	}))
+	defer proxy.Close()
+
+	kafka, err := NewKafkaREST(KafkaConfig{URL: proxy.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	event := New(ReplyGenerated, map[string]interface{}{"reply": "hi"})
+	event.ConversationID = "c1"
+	if err := kafka.Publish(context.Background(), event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != "/topics/chatbot.reply.generated" || contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("unexpected request to %s (%s)", path, contentType)
+	}
+	if len(body.Records) != 1 || body.Records[0].Key != "c1" || body.Records[0].Value.ID != event.ID {
+		t.Errorf("unexpected records: %+v", body.Records)
+	}
+}
+
+func TestKafkaRESTErrors(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		body   string
+		want   string
+	}{
+		"status":        {http.StatusNotFound, `{"error_code":40401,"message":"Topic not found"}`, "404"},
+		"record failed": {http.StatusOK, `{"offsets":[{"error_code":1,"error":"record too large"}]}`, "record too large"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer proxy.Close()
+
+			kafka, _ := NewKafkaREST(KafkaConfig{URL: proxy.URL, Topic: "chat-events"})
+			err := kafka.Publish(context.Background(), New(MessageReceived, nil))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := NewKafkaREST(KafkaConfig{}); err == nil {
+		t.Error("expected error for missing URL")
+	}
+}
@@ -0,0 +1,244 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSConfig configures the NATS publisher.
+type NATSConfig struct {
+	// URL is the server address, e.g. "nats://localhost:4222". Use the
+	// "tls" scheme for TLS connections. Credentials may be given in the URL.
+	URL string
+	// SubjectPrefix prefixes event subjects (default "chatbot"), so replies
+	// go to "chatbot.reply.generated".
+	SubjectPrefix string
+	// Token authenticates with a server token.
+	Token string
+	// Name identifies the connection in server monitoring (default "go-chatbot").
+	Name string
+	// DialTimeout bounds connecting to the server (default 5s).
+	DialTimeout time.Duration
+	// TLSConfig is used for "tls" URLs.
+	TLSConfig *tls.Config
+}
+
+// NATS publishes events to NATS core subjects. Every publish is confirmed
+// with a PING round trip, so an error means the server did not receive the
+// event. Broken connections are re-established on the next publish.
+type NATS struct {
+	config NATSConfig
+	server *url.URL
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	closed bool
+}
+
+// NewNATS creates a NATS publisher. The connection is opened on first use.
+func NewNATS(cfg NATSConfig) (*NATS, error) {
+	if cfg.URL == "" {
+		cfg.URL = "nats://localhost:4222"
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = "chatbot"
+	}
+	if cfg.Name == "" {
+		cfg.Name = "go-chatbot"
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+
+	server, err := url.Parse(cfg.URL)
+	if err != nil || server.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q", cfg.URL)
+	}
+	if server.Scheme != "nats" && server.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported NATS URL scheme %q", server.Scheme)
+	}
+	if server.Port() == "" {
+		server.Host = net.JoinHostPort(server.Hostname(), "4222")
+	}
+
+	return &NATS{config: cfg, server: server}, nil
+}
+
+// Publish implements Publisher.
+func (n *NATS) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	subject := Subject(n.config.SubjectPrefix, event.Type)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		return ErrClosed
+	}
+
+	// Retry once on a fresh connection if the old one was dropped
+	for attempt := 0; ; attempt++ {
+		reused := n.conn != nil
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+		err := n.publish(ctx, subject, payload)
+		if err == nil {
+			return nil
+		}
+		n.disconnect()
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return fmt.Errorf("failed to publish to NATS: %w", err)
+		}
+	}
+}
+
+// Close implements Publisher.
+func (n *NATS) Close() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.closed = true
+	n.disconnect()
+	return nil
+}
+
+// connect opens and authenticates a connection if there is none.
+func (n *NATS) connect(ctx context.Context) error {
+	if n.conn != nil {
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: n.config.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.server.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	n.conn = conn
+	n.reader = bufio.NewReader(conn)
+	n.setDeadline(ctx)
+
+	// The server greets with INFO before anything else
+	line, err := n.readLine()
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		n.disconnect()
+		return fmt.Errorf("unexpected NATS greeting: %q (%v)", line, err)
+	}
+
+	if n.server.Scheme == "tls" {
+		tlsConfig := n.config.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = n.server.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		n.conn = tlsConn
+		n.reader = bufio.NewReader(tlsConn)
+		n.setDeadline(ctx)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     n.config.Name,
+		"lang":     "go",
+		"version":  "1.0.0",
+	}
+	if n.config.Token != "" {
+		options["auth_token"] = n.config.Token
+	}
+	if user := n.server.User; user != nil {
+		options["user"] = user.Username()
+		if pass, ok := user.Password(); ok {
+			options["pass"] = pass
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		n.disconnect()
+		return err
+	}
+
+	if _, err := fmt.Fprintf(n.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		n.disconnect()
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	if err := n.awaitPong(); err != nil {
+		n.disconnect()
+		return fmt.Errorf("NATS rejected connection: %w", err)
+	}
+	return nil
+}
+
+// publish sends one message and waits for the server to acknowledge a PING
+// sent right after it.
+func (n *NATS) publish(ctx context.Context, subject string, payload []byte) error {
+	n.setDeadline(ctx)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "PUB %s %d\r\n", subject, len(payload))
+	buf.Write(payload)
+	buf.WriteString("\r\nPING\r\n")
+
+	if _, err := n.conn.Write([]byte(buf.String())); err != nil {
+		return err
+	}
+	return n.awaitPong()
+}
+
+// awaitPong reads server messages until PONG, answering server PINGs.
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := n.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (n *NATS) readLine() (string, error) {
+	line, err := n.reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// setDeadline applies the context deadline, or the dial timeout, to the connection.
+func (n *NATS) setDeadline(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(n.config.DialTimeout)
+	}
+	_ = n.conn.SetDeadline(deadline)
+}
+
+func (n *NATS) disconnect() {
+	if n.conn != nil {
+		_ = n.conn.Close()
+		n.conn = nil
+		n.reader = nil
+	}
+}
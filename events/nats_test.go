@@ -0,0 +1,175 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// natsServer is a minimal NATS server speaking just enough of the protocol
+// to accept publishes.
+type natsServer struct {
+	listener net.Listener
+	reject   string
+
+	mutex    sync.Mutex
+	connects []map[string]interface{}
+	messages map[string][]byte
+	conns    []net.Conn
+}
+
+func newNATSServer(t *testing.T) *natsServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &natsServer{listener: listener, messages: make(map[string][]byte)}
+	go s.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return s
+}
+
+func (s *natsServer) URL() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *natsServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.conns = append(s.conns, conn)
+		s.mutex.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *natsServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "CONNECT":
+			var options map[string]interface{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &options)
+			s.mutex.Lock()
+			s.connects = append(s.connects, options)
+			reject := s.reject
+			s.mutex.Unlock()
+			if reject != "" {
+				fmt.Fprintf(conn, "-ERR '%s'\r\n", reject)
+				return
+			}
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.messages[fields[1]] = payload[:size]
+			s.mutex.Unlock()
+			// Exercise server-initiated keepalives
+			fmt.Fprint(conn, "PING\r\n")
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		}
+	}
+}
+
+// dropConnections closes all open client connections.
+func (s *natsServer) dropConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func TestNATSPublish(t *testing.T) {
+	server := newNATSServer(t)
+	publisher, err := NewNATS(NATSConfig{URL: strings.Replace(server.URL(), "nats://", "nats://bot:secret@", 1)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer publisher.Close()
+
+	event := New(ModerationFlagged, map[string]interface{}{"reasons": []string{"links_filtered"}})
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	var got Event
+	if err := json.Unmarshal(server.messages["chatbot.moderation.flagged"], &got); err != nil || got.ID != event.ID {
+		t.Errorf("expected event on subject, got %v (%v)", server.messages, err)
+	}
+	if len(server.connects) != 1 || server.connects[0]["user"] != "bot" || server.connects[0]["pass"] != "secret" {
+		t.Errorf("unexpected CONNECT options: %v", server.connects)
+	}
+}
+
+func TestNATSReconnects(t *testing.T) {
+	server := newNATSServer(t)
+	publisher, _ := NewNATS(NATSConfig{URL: server.URL(), SubjectPrefix: "analytics"})
+	defer publisher.Close()
+
+	if err := publisher.Publish(context.Background(), New(MessageReceived, nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.dropConnections()
+	if err := publisher.Publish(context.Background(), New(ReplyGenerated, nil)); err != nil {
+		t.Fatalf("expected publish to reconnect, got %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.connects) != 2 || server.messages["analytics.reply.generated"] == nil {
+		t.Errorf("expected a second connection and message, got %d connects, %v", len(server.connects), server.messages)
+	}
+}
+
+func TestNATSErrors(t *testing.T) {
+	server := newNATSServer(t)
+	server.reject = "Authorization Violation"
+	publisher, _ := NewNATS(NATSConfig{URL: server.URL(), Token: "wrong"})
+
+	err := publisher.Publish(context.Background(), New(MessageReceived, nil))
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("expected authorization error, got %v", err)
+	}
+
+	_ = publisher.Close()
+	if err := publisher.Publish(context.Background(), New(MessageReceived, nil)); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	for _, url := range []string{"http://localhost:4222", "::bad"} {
+		if _, err := NewNATS(NATSConfig{URL: url}); err == nil {
+			t.Errorf("expected error for %q", url)
+		}
+	}
+}
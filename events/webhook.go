@@ -0,0 +1,306 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of webhook deliveries.
+const (
+	// WebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC>", the
+	// HMAC-SHA256 of "<t>.<body>" keyed by the webhook secret.
+	WebhookSignatureHeader = "X-Chatbot-Signature"
+	// WebhookEventHeader carries the event type.
+	WebhookEventHeader = "X-Chatbot-Event"
+	// WebhookDeliveryHeader carries the event ID, which stays the same
+	// across retries so receivers can ignore duplicates.
+	WebhookDeliveryHeader = "X-Chatbot-Delivery"
+)
+
+// ErrInvalidSignature is returned by VerifyWebhookSignature for deliveries
+// that were not signed with the secret or are too old.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// WebhookConfig configures a webhook.
+type WebhookConfig struct {
+	// URL receives a POST with the JSON event per delivery.
+	URL string
+	// Secret signs deliveries in the WebhookSignatureHeader. If empty,
+	// deliveries are not signed.
+	Secret string
+	// Types are the event types delivered; if empty, every event is.
+	Types []Type
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+	// MaxAttempts bounds the deliveries of an event (default 5).
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling after each one
+	// up to MaxBackoff (defaults 1s and 1m). A Retry-After header from the
+	// endpoint takes precedence.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DeadLetter records the events that could not be delivered. If nil,
+	// they are only reported by Publish's error.
+	DeadLetter DeadLetter
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// Webhook publishes events to an HTTP endpoint, such as a CRM or ticketing
+// system. Failed deliveries are retried with exponential backoff while the
+// publish context allows; connection errors, 408, 429 and 5xx responses are
+// retried, other responses are not. Events it gives up on go to the dead
+// letter. Publish waits for the retries, so wrap the webhook with
+// NewBuffered and a publish timeout long enough for them.
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+	types  map[Type]bool
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewWebhook creates a webhook publisher.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	var types map[Type]bool
+	if len(cfg.Types) > 0 {
+		types = make(map[Type]bool, len(cfg.Types))
+		for _, eventType := range cfg.Types {
+			types[eventType] = true
+		}
+	}
+	return &Webhook{config: cfg, client: client, types: types, sleep: sleep}, nil
+}
+
+// Publish implements Publisher. Events of types the webhook does not
+// deliver are ignored.
+func (w *Webhook) Publish(ctx context.Context, event Event) error {
+	if w.types != nil && !w.types[event.Type] {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	attempts, backoff := 0, w.config.Backoff
+	for {
+		attempts++
+		retryAfter, retry, err := w.deliver(ctx, event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempts >= w.config.MaxAttempts {
+			return w.deadLetter(ctx, event, attempts, err)
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if sleepErr := w.sleep(ctx, wait); sleepErr != nil {
+			return w.deadLetter(ctx, event, attempts, err)
+		}
+		backoff = min(backoff*2, w.config.MaxBackoff)
+	}
+}
+
+// Close implements Publisher.
+func (w *Webhook) Close() error {
+	return nil
+}
+
+// deliver posts the event once. It reports whether a failure is worth
+// retrying, and how long the endpoint asked to wait.
+func (w *Webhook) deliver(ctx context.Context, event Event, body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range w.config.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(event.Type))
+	req.Header.Set(WebhookDeliveryHeader, event.ID)
+	if w.config.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.config.Secret, time.Now(), body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, ctx.Err() == nil, fmt.Errorf("failed to deliver event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, false, nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	retry := resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	var retryAfter time.Duration
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return retryAfter, retry, err
+}
+
+// deadLetter records an event the webhook gave up on and returns the
+// delivery error.
+func (w *Webhook) deadLetter(ctx context.Context, event Event, attempts int, err error) error {
+	err = fmt.Errorf("gave up delivering event %s after %d attempts: %w", event.ID, attempts, err)
+	if w.config.DeadLetter == nil {
+		return err
+	}
+	entry := DeadLetterEntry{
+		Event:    event,
+		URL:      w.config.URL,
+		Attempts: attempts,
+		Error:    err.Error(),
+		Time:     time.Now().UTC(),
+	}
+	// Record even if the publish context is done
+	if recordErr := w.config.DeadLetter.Record(context.WithoutCancel(ctx), entry); recordErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to record dead letter: %w", recordErr))
+	}
+	return err
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SignWebhook returns the WebhookSignatureHeader value of body sent at t.
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + webhookMAC(secret, timestamp, body)
+}
+
+// VerifyWebhookSignature checks the WebhookSignatureHeader of a delivery,
+// for receivers. Deliveries signed more than tolerance ago are rejected to
+// prevent replays; a tolerance of zero accepts any age.
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(webhookMAC(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(seconds, 0)).Abs() > tolerance {
+		return fmt.Errorf("%w: signed at %s", ErrInvalidSignature, time.Unix(seconds, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// webhookMAC returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func webhookMAC(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeadLetterEntry is an event a webhook could not deliver.
+type DeadLetterEntry struct {
+	Event    Event     `json:"event"`
+	URL      string    `json:"url"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// DeadLetter records undelivered events, so they can be inspected and
+// replayed.
+type DeadLetter interface {
+	Record(ctx context.Context, entry DeadLetterEntry) error
+}
+
+// DeadLetterFunc adapts a function to the DeadLetter interface.
+type DeadLetterFunc func(ctx context.Context, entry DeadLetterEntry) error
+
+// Record implements DeadLetter.
+func (f DeadLetterFunc) Record(ctx context.Context, entry DeadLetterEntry) error {
+	return f(ctx, entry)
+}
+
+// FileDeadLetter appends entries to a file as JSON lines.
+type FileDeadLetter struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFileDeadLetter returns a dead letter log appending to path, which is
+// created if needed.
+func NewFileDeadLetter(path string) *FileDeadLetter {
+	return &FileDeadLetter{path: path}
+}
+
+// Record implements DeadLetter.
+func (f *FileDeadLetter) Record(ctx context.Context, entry DeadLetterEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write dead letter log: %w", err)
+	}
+	return file.Close()
+}
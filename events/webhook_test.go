@@ -0,0 +1,202 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noSleep records the waits between retries instead of sleeping.
+func noSleep(waits *[]time.Duration) func(context.Context, time.Duration) error {
+	return func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+}
+
+func TestWebhookPublish(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(WebhookConfig{
+		URL:     server.URL,
+		Secret:  "s3cret",
+		Types:   []Type{MessageReceived, ConversationEscalated},
+		Headers: map[string]string{"Authorization": "Bearer t"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	event := New(MessageReceived, map[string]interface{}{"message": "hi"})
+	event.ConversationID = "c1"
+	if err := webhook.Publish(context.Background(), event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var delivered Event
+	if err := json.Unmarshal(body, &delivered); err != nil || delivered.ID != event.ID || delivered.ConversationID != "c1" {
+		t.Errorf("unexpected body %s", body)
+	}
+	if headers.Get(WebhookEventHeader) != "message.received" || headers.Get(WebhookDeliveryHeader) != event.ID || headers.Get("Authorization") != "Bearer t" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if err := VerifyWebhookSignature("s3cret", headers.Get(WebhookSignatureHeader), body, time.Minute); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	// Other types are not delivered
+	body = nil
+	if err := webhook.Publish(context.Background(), New(ReplyGenerated, nil)); err != nil || body != nil {
+		t.Errorf("expected the reply to be skipped, got %v and %s", err, body)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	responses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	var deliveries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries = append(deliveries, r.Header.Get(WebhookDeliveryHeader))
+		status := responses[len(deliveries)-1]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "7")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(WebhookConfig{URL: server.URL, Backoff: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var waits []time.Duration
+	webhook.sleep = noSleep(&waits)
+
+	event := New(ReplyGenerated, nil)
+	if err := webhook.Publish(context.Background(), event); err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+	if len(deliveries) != 3 || deliveries[0] != event.ID || deliveries[2] != event.ID {
+		t.Errorf("expected three deliveries of the event, got %v", deliveries)
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 7*time.Second {
+		t.Errorf("expected the backoff and then Retry-After, got %v", waits)
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	tests := map[string]struct {
+		status   int
+		attempts int
+		waits    []time.Duration
+	}{
+		"not retried":       {http.StatusBadRequest, 1, nil},
+		"retries exhausted": {http.StatusBadGateway, 3, []time.Duration{time.Second, 2 * time.Second}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.Remove(path)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", tt.status)
+			}))
+			defer server.Close()
+
+			webhook, err := NewWebhook(WebhookConfig{URL: server.URL, MaxAttempts: 3, DeadLetter: NewFileDeadLetter(path)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var waits []time.Duration
+			webhook.sleep = noSleep(&waits)
+
+			event := New(ConversationEscalated, nil)
+			if err := webhook.Publish(context.Background(), event); err == nil || !strings.Contains(err.Error(), "nope") {
+				t.Errorf("expected the delivery error, got %v", err)
+			}
+			if len(waits) != len(tt.waits) || (len(waits) > 1 && waits[1] != tt.waits[1]) {
+				t.Errorf("expected waits %v, got %v", tt.waits, waits)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("expected a dead letter log: %v", err)
+			}
+			var entry DeadLetterEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				t.Fatalf("unexpected log %s", data)
+			}
+			if entry.Event.ID != event.ID || entry.Attempts != tt.attempts || entry.URL != server.URL {
+				t.Errorf("unexpected entry %+v", entry)
+			}
+		})
+	}
+}
+
+func TestWebhookGivesUpWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var recorded []DeadLetterEntry
+	webhook, err := NewWebhook(WebhookConfig{
+		URL:     server.URL,
+		Backoff: time.Hour,
+		DeadLetter: DeadLetterFunc(func(ctx context.Context, entry DeadLetterEntry) error {
+			recorded = append(recorded, entry)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := webhook.Publish(ctx, New(ReplyGenerated, nil)); err == nil {
+		t.Error("expected an error")
+	}
+	if len(recorded) != 1 || recorded[0].Attempts != 1 {
+		t.Errorf("expected the event to be dead lettered after one attempt, got %+v", recorded)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signedAt := time.Now().Add(-time.Hour)
+	header := SignWebhook("s3cret", signedAt, body)
+
+	if err := VerifyWebhookSignature("s3cret", header, body, 0); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	for name, err := range map[string]error{
+		"wrong secret": VerifyWebhookSignature("other", header, body, 0),
+		"changed body": VerifyWebhookSignature("s3cret", header, []byte(`{"id":"2"}`), 0),
+		"too old":      VerifyWebhookSignature("s3cret", header, body, time.Minute),
+		"malformed":    VerifyWebhookSignature("s3cret", "v1=abc", body, 0),
+	} {
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestNewWebhookRequiresURL(t *testing.T) {
+	if _, err := NewWebhook(WebhookConfig{}); err == nil {
+		t.Error("expected an error without a URL")
+	}
+}
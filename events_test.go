@@ -0,0 +1,133 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+	"go.rumenx.com/chatbot/experiments"
+	"go.rumenx.com/chatbot/models"
+)
+
+// recordingPublisher collects published events.
+type recordingPublisher struct {
+	mutex  sync.Mutex
+	events []events.Event
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.events = append(p.events, event)
+	return p.err
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func (p *recordingPublisher) types() []events.Type {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var types []events.Type
+	for _, event := range p.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestAskPublishesEvents(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering = config.MessageFilteringConfig{Enabled: true, Profanities: []string{"darn"}}
+	publisher := &recordingPublisher{}
+
+	bot, err := New(cfg, WithModel(models.NewFreeModel()), WithEventPublisher(publisher))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if _, err := bot.Ask(context.Background(), "darn printer", WithUserID("u1"), WithContext("conversation_id", "c1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	types := publisher.types()
+	want := []events.Type{events.MessageReceived, events.ModerationFlagged, events.ReplyGenerated}
+	if len(types) != len(want) {
+		t.Fatalf("expected %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, types)
+		}
+	}
+
+	received, reply := publisher.events[0], publisher.events[2]
+	if received.UserID != "u1" || received.ConversationID != "c1" || received.Data["message"] != "*** printer" {
+		t.Errorf("unexpected received event: %+v", received)
+	}
+	if reply.Data["reply"] == "" || reply.Data["provider"] != "local" {
+		t.Errorf("unexpected reply event: %+v", reply)
+	}
+	if tokens, ok := reply.Data["prompt_tokens"].(int); !ok || tokens != len("*** printer")/4 {
+		t.Errorf("expected estimated prompt tokens, got %v", reply.Data["prompt_tokens"])
+	}
+}
+
+func TestAskStreamPublishesReply(t *testing.T) {
+	publisher := &recordingPublisher{}
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()), WithEventPublisher(publisher))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if err := bot.AskStream(context.Background(), httptest.NewRecorder(), "Hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	types := publisher.types()
+	if len(types) != 2 || types[0] != events.MessageReceived || types[1] != events.ReplyGenerated {
+		t.Fatalf("unexpected events: %v", types)
+	}
+	if publisher.events[1].Data["reply"] == "" {
+		t.Error("expected the streamed reply to be collected")
+	}
+}
+
+func TestReplyEventIncludesVariant(t *testing.T) {
+	publisher := &recordingPublisher{}
+	exp, err := experiments.New("tone", models.NewFreeModel(), []experiments.Variant{{Name: "friendly", Weight: 1}})
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+	bot, err := New(config.Default(), WithModel(exp), WithEventPublisher(publisher))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "Hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reply := publisher.events[len(publisher.events)-1]
+	if reply.Data["experiment"] != "tone" || reply.Data["variant"] != "friendly" {
+		t.Errorf("expected the variant on the reply event, got %v", reply.Data)
+	}
+}
+
+func TestEventErrorsDoNotFailRequests(t *testing.T) {
+	publisher := &recordingPublisher{err: errors.New("broker down")}
+	var reported int
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()), WithEventPublisher(publisher),
+		WithHooks(Hooks{OnEventError: func(event events.Event, err error) { reported++ }}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "Hello"); err != nil {
+		t.Fatalf("expected request to succeed, got %v", err)
+	}
+	if reported != 2 {
+		t.Errorf("expected 2 reported errors, got %d", reported)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	gochatbot "go.rumenx.com/chatbot"
@@ -23,6 +24,7 @@ import (
 type AdvancedChatbotServer struct {
 	chatbot           *gochatbot.Chatbot
 	conversationStore *database.SQLConversationStore
+	conversations     *database.ConversationManager
 	embeddingProvider *embeddings.OpenAIEmbeddingProvider
 	vectorStore       *embeddings.VectorStore
 	dbPath            string
@@ -67,6 +69,7 @@ func NewAdvancedChatbotServer(openaiAPIKey string) (*AdvancedChatbotServer, erro
 	return &AdvancedChatbotServer{
 		chatbot:           bot,
 		conversationStore: conversationStore,
+		conversations:     database.NewConversationManager(conversationStore, database.WithSummaryModel(bot.GetModel()), database.WithLocker(database.NewMemoryLocker())),
 		embeddingProvider: embeddingProvider,
 		vectorStore:       vectorStore,
 		dbPath:            dbPath,
@@ -102,6 +105,7 @@ func (s *AdvancedChatbotServer) handleChat(w http.ResponseWriter, r *http.Reques
 	}
 
 	ctx := r.Context()
+	userID := requestUserID(r)
 
 	// Get or create conversation
 	conversationID := req.ConversationID
@@ -109,7 +113,7 @@ func (s *AdvancedChatbotServer) handleChat(w http.ResponseWriter, r *http.Reques
 		// Create new conversation
 		conversation := &database.Conversation{
 			ID:        fmt.Sprintf("conv_%d", time.Now().Unix()),
-			UserID:    "default_user",
+			UserID:    userID,
 			Title:     "New Chat",
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -121,6 +125,14 @@ func (s *AdvancedChatbotServer) handleChat(w http.ResponseWriter, r *http.Reques
 		conversationID = conversation.ID
 	}
 
+	// Take turns with other requests for this conversation until the reply is saved
+	unlock, err := s.conversations.Lock(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Failed to lock conversation", http.StatusServiceUnavailable)
+		return
+	}
+	defer unlock()
+
 	// Add user message to conversation
 	userMessage := &database.Message{
 		ID:             fmt.Sprintf("msg_%d", time.Now().UnixNano()),
@@ -165,18 +177,27 @@ func (s *AdvancedChatbotServer) handleChat(w http.ResponseWriter, r *http.Reques
 
 	// Generate response based on streaming preference
 	if req.Stream {
-		s.handleStreamingResponse(w, r, conversationID, prompt)
+		s.handleStreamingResponse(w, r, userID, conversationID, prompt)
 	} else {
-		s.handleRegularResponse(w, conversationID, prompt)
+		s.handleRegularResponse(w, userID, conversationID, prompt)
 	}
 }
 
+// requestUserID identifies the caller. A real server would take the user
+// from its authentication middleware; the X-User-ID header stands in for it.
+func requestUserID(r *http.Request) string {
+	if id := r.Header.Get("X-User-ID"); id != "" {
+		return id
+	}
+	return "anonymous"
+}
+
 // handleStreamingResponse handles streaming chat responses
-func (s *AdvancedChatbotServer) handleStreamingResponse(w http.ResponseWriter, r *http.Request, conversationID, prompt string) {
+func (s *AdvancedChatbotServer) handleStreamingResponse(w http.ResponseWriter, r *http.Request, userID, conversationID, prompt string) {
 	ctx := r.Context()
 
 	// Use the chatbot's built-in streaming functionality
-	err := s.chatbot.AskStream(ctx, w, prompt)
+	err := s.chatbot.AskStream(ctx, w, prompt, gochatbot.WithUserID(userID))
 	if err != nil {
 		log.Printf("Error generating streaming response: %v", err)
 		http.Error(w, "Failed to generate streaming response", http.StatusInternalServerError)
@@ -189,11 +210,11 @@ func (s *AdvancedChatbotServer) handleStreamingResponse(w http.ResponseWriter, r
 }
 
 // handleRegularResponse handles non-streaming chat responses
-func (s *AdvancedChatbotServer) handleRegularResponse(w http.ResponseWriter, conversationID, prompt string) {
+func (s *AdvancedChatbotServer) handleRegularResponse(w http.ResponseWriter, userID, conversationID, prompt string) {
 	ctx := context.Background()
 
 	// Get response from chatbot
-	response, err := s.chatbot.Ask(ctx, prompt)
+	response, err := s.chatbot.Ask(ctx, prompt, gochatbot.WithUserID(userID))
 	if err != nil {
 		http.Error(w, "Failed to generate response", http.StatusInternalServerError)
 		return
@@ -272,15 +293,14 @@ func (s *AdvancedChatbotServer) handleConversations(w http.ResponseWriter, r *ht
 
 	switch r.Method {
 	case http.MethodGet:
-		// Get all conversations for default user
-		conversations, err := s.conversationStore.ListConversations(ctx, "default_user", 50, 0)
+		// Get all conversations for the user
+		conversations, err := s.conversationStore.ListConversations(ctx, requestUserID(r), 50, 0)
 		if err != nil {
 			http.Error(w, "Failed to get conversations", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(conversations)
+		writeJSONWithETag(w, r, conversations)
 
 	case http.MethodPost:
 		// Create new conversation
@@ -294,7 +314,7 @@ func (s *AdvancedChatbotServer) handleConversations(w http.ResponseWriter, r *ht
 
 		conversation := &database.Conversation{
 			ID:        fmt.Sprintf("conv_%d", time.Now().Unix()),
-			UserID:    "default_user",
+			UserID:    requestUserID(r),
 			Title:     req.Title,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -315,6 +335,11 @@ func (s *AdvancedChatbotServer) handleConversations(w http.ResponseWriter, r *ht
 
 // handleConversationMessages handles getting messages for a conversation
 func (s *AdvancedChatbotServer) handleConversationMessages(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/summary") {
+		s.handleConversationSummary(w, r)
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -351,8 +376,50 @@ func (s *AdvancedChatbotServer) handleConversationMessages(w http.ResponseWriter
 		return
 	}
 
+	writeJSONWithETag(w, r, messages)
+}
+
+// writeJSONWithETag writes v as JSON with an ETag, or just 304 Not
+// Modified if the client already has it, so polling clients don't download
+// unchanged listings again.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	// Listings differ per user, so only the client may cache them, and it
+	// must revalidate every time
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", "X-User-ID")
+	if err := gochatbot.WriteJSONWithETag(w, r, v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleConversationSummary returns the stored summary of a conversation
+// (GET) or generates a new one (POST)
+func (s *AdvancedChatbotServer) handleConversationSummary(w http.ResponseWriter, r *http.Request) {
+	conversationID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/conversations/"), "/summary")
+	ctx := r.Context()
+
+	var summary *database.Summary
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		summary, err = s.conversations.GetSummary(ctx, conversationID)
+		if err == nil && summary == nil {
+			http.Error(w, "Conversation has not been summarized", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		summary, err = s.conversations.Summarize(ctx, conversationID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to summarize conversation", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(messages)
+	json.NewEncoder(w).Encode(summary)
 }
 
 // addKnowledgeToVectorStore adds knowledge to the vector store for enhanced context
@@ -500,6 +567,7 @@ func main() {
             <div class="endpoint">
                 <span class="method get">GET</span> <strong>/conversations</strong> - List all conversations
                 <pre>curl http://localhost:8080/conversations</pre>
+                <p>Listings carry an <code>ETag</code>; send it back in <code>If-None-Match</code> to get <code>304 Not Modified</code> while nothing changed.</p>
             </div>
 
             <div class="endpoint">
@@ -514,6 +582,11 @@ func main() {
                 <pre>curl http://localhost:8080/conversations/conv_1234567890/messages</pre>
             </div>
 
+            <div class="endpoint">
+                <span class="method post">POST</span> <strong>/conversations/{id}/summary</strong> - Summarize a conversation (GET returns the stored summary)
+                <pre>curl -X POST http://localhost:8080/conversations/conv_1234567890/summary</pre>
+            </div>
+
             <div class="endpoint">
                 <span class="method post">POST</span> <strong>/knowledge</strong> - Add knowledge to vector store
                 <pre>curl -X POST http://localhost:8080/knowledge \\
@@ -572,6 +645,7 @@ func main() {
 	fmt.Println("   GET  /conversations - List conversations")
 	fmt.Println("   POST /conversations - Create new conversation")
 	fmt.Println("   GET  /conversations/{id}/messages - Get messages")
+	fmt.Println("   POST /conversations/{id}/summary - Summarize conversation")
 	fmt.Println("   POST /knowledge - Add to knowledge base")
 	fmt.Println("   GET  /status - Server status")
 	fmt.Println("\n💡 Open http://localhost:8080 in your browser for interactive docs")
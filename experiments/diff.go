@@ -0,0 +1,75 @@
+package experiments
+
+import (
+	"strings"
+)
+
+// maxDiffTokens bounds the size of the LCS table; longer texts are compared
+// by their first maxDiffTokens lines or words.
+const maxDiffTokens = 2000
+
+// Diff returns a line diff of a and b: unchanged lines are prefixed with
+// two spaces, removed lines with "- " and added lines with "+ ". It returns
+// an empty string if the texts are equal.
+func Diff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	x, y := truncate(strings.Split(a, "\n")), truncate(strings.Split(b, "\n"))
+	table := lcsTable(x, y)
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out.WriteString("  " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || table[i+1][j] >= table[i][j+1]):
+			out.WriteString("- " + x[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
+
+// Similarity returns how alike a and b are, from 0 to 1, as the share of
+// words they have in common in the same order.
+func Similarity(a, b string) float64 {
+	x, y := truncate(strings.Fields(a)), truncate(strings.Fields(b))
+	if len(x)+len(y) == 0 {
+		return 1
+	}
+	common := lcsTable(x, y)[0][0]
+	return 2 * float64(common) / float64(len(x)+len(y))
+}
+
+func truncate(tokens []string) []string {
+	if len(tokens) > maxDiffTokens {
+		return tokens[:maxDiffTokens]
+	}
+	return tokens
+}
+
+// lcsTable returns table[i][j], the length of the longest common
+// subsequence of x[i:] and y[j:].
+func lcsTable(x, y []string) [][]int {
+	table := make([][]int, len(x)+1)
+	for i := range table {
+		table[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+	return table
+}
@@ -0,0 +1,301 @@
+// Package experiments runs A/B tests between prompt variants or models.
+//
+// An Experiment is a models.Model that assigns every request to one of its
+// variants, either per conversation or per user, and records latency, cost
+// and feedback per variant for comparison:
+//
+//	exp, err := experiments.New("tone", baseModel, []experiments.Variant{
+//		{Name: "control", Weight: 50},
+//		{Name: "friendly", Weight: 50, Prompt: "You are a warm, upbeat assistant."},
+//	}, experiments.WithSplit(experiments.SplitUserHash))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithModel(exp))
+//	http.Handle("/experiments/tone", exp)
+package experiments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// Context keys set on the request context with the assignment, so that
+// events and stored conversations can be tagged with it.
+const (
+	ContextExperiment = "experiment"
+	ContextVariant    = "variant"
+)
+
+// ErrUnknownVariant is returned when recording feedback for a variant the
+// experiment does not have.
+var ErrUnknownVariant = errors.New("unknown experiment variant")
+
+// Split decides how requests are assigned to variants.
+type Split string
+
+// Split strategies.
+const (
+	// SplitPercentage assigns each conversation independently by variant
+	// weight. Requests without a conversation ID are assigned at random.
+	SplitPercentage Split = "percentage"
+	// SplitUserHash assigns by user ID, so a user sees the same variant in
+	// every conversation. Requests without a user ID fall back to the
+	// conversation.
+	SplitUserHash Split = "user_hash"
+)
+
+// Variant is one arm of an experiment.
+type Variant struct {
+	Name string `json:"name"`
+	// Weight is the variant's share of traffic, relative to the other
+	// variants (usually a percentage).
+	Weight float64 `json:"weight"`
+	// Model answers the variant's requests (default the experiment's base
+	// model).
+	Model models.Model `json:"-"`
+	// Prompt replaces the system prompt, if set.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// Assignment is the variant a request was assigned to.
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+// Tag records the assignment in conversation metadata, under
+// "experiments", keyed by experiment name.
+func (a Assignment) Tag(metadata map[string]interface{}) {
+	tags, ok := metadata["experiments"].(map[string]interface{})
+	if !ok {
+		tags = make(map[string]interface{})
+		metadata["experiments"] = tags
+	}
+	tags[a.Experiment] = a.Variant
+}
+
+// CostFunc computes the cost of a model call from its prompt and response.
+type CostFunc func(variant Variant, prompt, response string) float64
+
+// Option configures an Experiment.
+type Option func(*Experiment)
+
+// WithSplit sets the split strategy (default SplitPercentage).
+func WithSplit(split Split) Option {
+	return func(e *Experiment) {
+		e.split = split
+	}
+}
+
+// WithCostFunc sets how the cost of a call is computed. By default cost is
+// the estimated number of tokens (about four characters per token).
+func WithCostFunc(fn CostFunc) Option {
+	return func(e *Experiment) {
+		e.cost = fn
+	}
+}
+
+// Experiment splits traffic between variants.
+type Experiment struct {
+	name     string
+	base     models.Model
+	variants []Variant
+	total    float64
+	split    Split
+	cost     CostFunc
+	stats    map[string]*variantStats
+}
+
+// New creates an experiment. Variants without a model use base.
+func New(name string, base models.Model, variants []Variant, opts ...Option) (*Experiment, error) {
+	if name == "" {
+		return nil, errors.New("experiment name cannot be empty")
+	}
+	if len(variants) == 0 {
+		return nil, errors.New("experiment needs at least one variant")
+	}
+
+	e := &Experiment{
+		name:  name,
+		base:  base,
+		split: SplitPercentage,
+		cost: func(variant Variant, prompt, response string) float64 {
+			return float64(len(prompt)+len(response)) / 4
+		},
+		stats: make(map[string]*variantStats, len(variants)),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.split != SplitPercentage && e.split != SplitUserHash {
+		return nil, fmt.Errorf("unknown split %q", e.split)
+	}
+
+	for _, variant := range variants {
+		if variant.Name == "" {
+			return nil, errors.New("variant name cannot be empty")
+		}
+		if _, ok := e.stats[variant.Name]; ok {
+			return nil, fmt.Errorf("duplicate variant %q", variant.Name)
+		}
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("variant %q has a negative weight", variant.Name)
+		}
+		if variant.Model == nil {
+			if base == nil {
+				return nil, fmt.Errorf("variant %q has no model", variant.Name)
+			}
+			variant.Model = base
+		}
+		e.variants = append(e.variants, variant)
+		e.total += variant.Weight
+		e.stats[variant.Name] = &variantStats{}
+	}
+	if e.total <= 0 {
+		return nil, errors.New("experiment variants have no weight")
+	}
+	return e, nil
+}
+
+// Name implements models.Model.
+func (e *Experiment) Name() string {
+	return e.name
+}
+
+// Provider implements models.Model.
+func (e *Experiment) Provider() string {
+	return "experiment"
+}
+
+// Variants returns the experiment's variants.
+func (e *Experiment) Variants() []Variant {
+	return append([]Variant(nil), e.variants...)
+}
+
+// Assign returns the variant for a user and conversation. Assignment is
+// deterministic for a given key, so it can be recomputed later, for example
+// when recording feedback.
+func (e *Experiment) Assign(userID, conversationID string) Variant {
+	key := conversationID
+	if e.split == SplitUserHash && userID != "" {
+		key = userID
+	}
+
+	var point float64
+	if key == "" {
+		point = rand.Float64() * e.total // #nosec G404 -- traffic splitting is not security sensitive
+	} else {
+		h := fnv.New64a()
+		h.Write([]byte(e.name + ":" + key))
+		point = float64(h.Sum64()%10000) / 10000 * e.total
+	}
+
+	for _, variant := range e.variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return e.variants[len(e.variants)-1]
+}
+
+// assign picks the variant for a request, records the assignment in its
+// context and returns the context to send to the variant's model.
+func (e *Experiment) assign(askContext map[string]interface{}) (Variant, map[string]interface{}) {
+	userID, _ := askContext["user_id"].(string)
+	conversationID, _ := askContext["conversation_id"].(string)
+	variant := e.Assign(userID, conversationID)
+
+	if askContext != nil {
+		askContext[ContextExperiment] = e.name
+		askContext[ContextVariant] = variant.Name
+	}
+
+	modelContext := make(map[string]interface{}, len(askContext)+1)
+	for key, value := range askContext {
+		modelContext[key] = value
+	}
+	if variant.Prompt != "" {
+		modelContext["prompt"] = variant.Prompt
+	}
+	return variant, modelContext
+}
+
+// Ask implements models.Model. The assignment is added to context under
+// ContextExperiment and ContextVariant.
+func (e *Experiment) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	variant, modelContext := e.assign(context)
+	started := time.Now()
+	response, err := variant.Model.Ask(ctx, message, modelContext)
+	e.record(variant, started, promptOf(modelContext)+message, response, err)
+	return response, err
+}
+
+// AskStream implements models.StreamingModel. Variants whose model does not
+// stream send the reply as a single chunk.
+func (e *Experiment) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	variant, modelContext := e.assign(context)
+	started := time.Now()
+	prompt := promptOf(modelContext) + message
+
+	streamingModel, ok := variant.Model.(models.StreamingModel)
+	if !ok {
+		response, err := variant.Model.Ask(ctx, message, modelContext)
+		e.record(variant, started, prompt, response, err)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan string, 1)
+		out <- response
+		close(out)
+		return out, nil
+	}
+
+	in, err := streamingModel.AskStream(ctx, message, modelContext)
+	if err != nil {
+		e.record(variant, started, prompt, "", err)
+		return nil, err
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+
+		var response strings.Builder
+		for chunk := range in {
+			response.WriteString(chunk)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				for range in {
+				}
+				e.record(variant, started, prompt, response.String(), ctx.Err())
+				return
+			}
+		}
+		e.record(variant, started, prompt, response.String(), nil)
+	}()
+	return out, nil
+}
+
+// Health implements models.HealthChecker by checking every variant's model.
+func (e *Experiment) Health(ctx context.Context) error {
+	for _, variant := range e.variants {
+		if checker, ok := variant.Model.(models.HealthChecker); ok {
+			if err := checker.Health(ctx); err != nil {
+				return fmt.Errorf("variant %q: %w", variant.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func promptOf(context map[string]interface{}) string {
+	prompt, _ := context["prompt"].(string)
+	return prompt
+}
@@ -0,0 +1,171 @@
+package experiments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+)
+
+func TestAssignPercentage(t *testing.T) {
+	exp, err := New("split", chatbottest.NewMockModel(), []Variant{
+		{Name: "a", Weight: 80},
+		{Name: "b", Weight: 20},
+	})
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 5000; i++ {
+		counts[exp.Assign("", fmt.Sprintf("conv-%d", i)).Name]++
+	}
+	if share := float64(counts["a"]) / 5000; math.Abs(share-0.8) > 0.03 {
+		t.Errorf("expected about 80%% in a, got %.2f (%v)", share, counts)
+	}
+
+	first := exp.Assign("u1", "conv-1").Name
+	for i := 0; i < 10; i++ {
+		if exp.Assign("u1", "conv-1").Name != first {
+			t.Fatal("expected a conversation to keep its variant")
+		}
+	}
+}
+
+func TestAssignUserHash(t *testing.T) {
+	exp, err := New("sticky", chatbottest.NewMockModel(), []Variant{
+		{Name: "a", Weight: 50},
+		{Name: "b", Weight: 50},
+	}, WithSplit(SplitUserHash))
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+
+	for user := 0; user < 20; user++ {
+		userID := fmt.Sprintf("user-%d", user)
+		want := exp.Assign(userID, "").Name
+		for conv := 0; conv < 5; conv++ {
+			if got := exp.Assign(userID, fmt.Sprintf("conv-%d", conv)).Name; got != want {
+				t.Fatalf("%s: expected %s in every conversation, got %s", userID, want, got)
+			}
+		}
+	}
+}
+
+func TestAskRoutesToVariant(t *testing.T) {
+	control := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: "control reply"})
+	candidate := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: "candidate reply"})
+	exp, err := New("models", control, []Variant{
+		{Name: "control", Weight: 0},
+		{Name: "candidate", Weight: 1, Model: candidate, Prompt: "Be brief."},
+	}, WithCostFunc(func(variant Variant, prompt, response string) float64 { return 0.5 }))
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+
+	askContext := map[string]interface{}{"prompt": "Be helpful.", "conversation_id": "c1"}
+	reply, err := exp.Ask(context.Background(), "hello", askContext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "candidate reply" || len(control.Calls()) != 0 {
+		t.Errorf("expected the candidate to answer, got %q", reply)
+	}
+	if askContext[ContextExperiment] != "models" || askContext[ContextVariant] != "candidate" {
+		t.Errorf("expected the assignment in the context, got %v", askContext)
+	}
+	if askContext["prompt"] != "Be helpful." {
+		t.Error("expected the caller's context to keep its prompt")
+	}
+	if call, _ := candidate.LastCall(); call.Context["prompt"] != "Be brief." {
+		t.Errorf("expected the variant prompt, got %v", call.Context["prompt"])
+	}
+
+	stream, err := exp.AskStream(context.Background(), "again", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range stream {
+	}
+
+	stats := exp.Stats()
+	if stats[0].Requests != 0 || stats[1].Requests != 2 || stats[1].Cost != 1 || stats[1].AverageCost != 0.5 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats[1].Model != "mock-model" {
+		t.Errorf("unexpected model: %q", stats[1].Model)
+	}
+}
+
+func TestErrorsAndFeedback(t *testing.T) {
+	model := chatbottest.NewMockModel().Fail(errors.New("boom"))
+	exp, err := New("feedback", model, []Variant{{Name: "only", Weight: 1}})
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+	if _, err := exp.Ask(context.Background(), "hi", nil); err == nil {
+		t.Fatal("expected the model error")
+	}
+
+	for _, score := range []float64{1, 0, 1, 1} {
+		if err := exp.RecordFeedback("only", score); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := exp.RecordFeedback("missing", 1); !errors.Is(err, ErrUnknownVariant) {
+		t.Errorf("expected ErrUnknownVariant, got %v", err)
+	}
+
+	stats := exp.Stats()[0]
+	if stats.Requests != 1 || stats.Errors != 1 || stats.Feedback != 4 || stats.AverageFeedback != 0.75 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	recorder := httptest.NewRecorder()
+	exp.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	var body struct {
+		Experiment string  `json:"experiment"`
+		Variants   []Stats `json:"variants"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Experiment != "feedback" || len(body.Variants) != 1 || body.Variants[0].Feedback != 4 {
+		t.Errorf("unexpected body: %s", recorder.Body.String())
+	}
+}
+
+func TestTag(t *testing.T) {
+	metadata := map[string]interface{}{}
+	Assignment{Experiment: "tone", Variant: "friendly"}.Tag(metadata)
+	Assignment{Experiment: "model", Variant: "candidate"}.Tag(metadata)
+
+	tags, _ := metadata["experiments"].(map[string]interface{})
+	if tags["tone"] != "friendly" || tags["model"] != "candidate" {
+		t.Errorf("unexpected tags: %v", metadata)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	model := chatbottest.NewMockModel()
+	tests := map[string]func() (*Experiment, error){
+		"no name":     func() (*Experiment, error) { return New("", model, []Variant{{Name: "a", Weight: 1}}) },
+		"no variants": func() (*Experiment, error) { return New("x", model, nil) },
+		"duplicate":   func() (*Experiment, error) { return New("x", model, []Variant{{Name: "a", Weight: 1}, {Name: "a"}}) },
+		"no weight":   func() (*Experiment, error) { return New("x", model, []Variant{{Name: "a"}}) },
+		"no model":    func() (*Experiment, error) { return New("x", nil, []Variant{{Name: "a", Weight: 1}}) },
+		"bad split": func() (*Experiment, error) {
+			return New("x", model, []Variant{{Name: "a", Weight: 1}}, WithSplit("round_robin"))
+		},
+	}
+	for name, create := range tests {
+		if _, err := create(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
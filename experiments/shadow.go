@@ -0,0 +1,277 @@
+package experiments
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/internal/logging"
+	"go.rumenx.com/chatbot/models"
+)
+
+// ShadowResult compares the primary and shadow replies to one request.
+type ShadowResult struct {
+	Time           time.Time `json:"time"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	UserID         string    `json:"user_id,omitempty"`
+	Message        string    `json:"message"`
+
+	PrimaryModel     string `json:"primary_model"`
+	Primary          string `json:"primary"`
+	PrimaryLatencyMs int64  `json:"primary_latency_ms"`
+
+	ShadowModel     string `json:"shadow_model"`
+	Shadow          string `json:"shadow"`
+	ShadowLatencyMs int64  `json:"shadow_latency_ms"`
+	// ShadowError is set if the shadow model failed.
+	ShadowError string `json:"shadow_error,omitempty"`
+
+	// Diff is a line diff from the primary to the shadow reply.
+	Diff string `json:"diff,omitempty"`
+	// Similarity is the share of words the replies have in common.
+	Similarity float64 `json:"similarity"`
+}
+
+// ShadowLogger records shadow comparisons.
+type ShadowLogger interface {
+	Log(ctx context.Context, result ShadowResult)
+}
+
+// ShadowLoggerFunc adapts a function to ShadowLogger.
+type ShadowLoggerFunc func(ctx context.Context, result ShadowResult)
+
+// Log implements ShadowLogger.
+func (f ShadowLoggerFunc) Log(ctx context.Context, result ShadowResult) {
+	f(ctx, result)
+}
+
+// JSONShadowLogger writes results as JSON lines.
+type JSONShadowLogger struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONShadowLogger writes one JSON object per line to w.
+func NewJSONShadowLogger(w io.Writer) *JSONShadowLogger {
+	return &JSONShadowLogger{encoder: json.NewEncoder(w)}
+}
+
+// Log implements ShadowLogger.
+func (l *JSONShadowLogger) Log(ctx context.Context, result ShadowResult) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.encoder.Encode(result); err != nil {
+		logging.Printf(ctx, "shadow: failed to write result: %v", err)
+	}
+}
+
+// logShadowLogger writes a summary with the chatbot's logger.
+type logShadowLogger struct{}
+
+func (logShadowLogger) Log(ctx context.Context, result ShadowResult) {
+	if result.ShadowError != "" {
+		logging.Printf(ctx, "shadow: %s failed on conversation %q: %s", result.ShadowModel, result.ConversationID, result.ShadowError)
+		return
+	}
+	logging.Printf(ctx, "shadow: %s vs %s on conversation %q: similarity %.2f, latency %dms vs %dms",
+		result.PrimaryModel, result.ShadowModel, result.ConversationID, result.Similarity,
+		result.PrimaryLatencyMs, result.ShadowLatencyMs)
+}
+
+// ShadowOption configures a Shadow.
+type ShadowOption func(*Shadow)
+
+// WithShadowLogger sets where comparisons are recorded. By default a
+// summary is written with the chatbot's logger (see gochatbot.WithLogger).
+func WithShadowLogger(logger ShadowLogger) ShadowOption {
+	return func(s *Shadow) {
+		s.logger = logger
+	}
+}
+
+// WithSampleRate shadows only a share of requests, from 0 to 1 (default 1).
+func WithSampleRate(rate float64) ShadowOption {
+	return func(s *Shadow) {
+		s.sampleRate = rate
+	}
+}
+
+// WithShadowTimeout bounds each shadow request (default 60s).
+func WithShadowTimeout(timeout time.Duration) ShadowOption {
+	return func(s *Shadow) {
+		s.timeout = timeout
+	}
+}
+
+// WithMaxInFlight limits concurrent shadow requests; requests arriving while
+// the limit is reached are not shadowed (default 10).
+func WithMaxInFlight(n int) ShadowOption {
+	return func(s *Shadow) {
+		s.inFlight = make(chan struct{}, n)
+	}
+}
+
+// Shadow is a models.Model that answers with the primary model and sends a
+// copy of each request to a shadow model in the background. The shadow's
+// reply is never returned to the user; both replies and their diff are
+// logged, so a provider migration can be validated on real traffic.
+type Shadow struct {
+	primary    models.Model
+	shadow     models.Model
+	logger     ShadowLogger
+	sampleRate float64
+	timeout    time.Duration
+	inFlight   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewShadow creates a shadow deployment of shadow alongside primary.
+func NewShadow(primary, shadow models.Model, opts ...ShadowOption) *Shadow {
+	s := &Shadow{
+		primary:    primary,
+		shadow:     shadow,
+		logger:     logShadowLogger{},
+		sampleRate: 1,
+		timeout:    60 * time.Second,
+		inFlight:   make(chan struct{}, 10),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Name implements models.Model with the primary model's name.
+func (s *Shadow) Name() string {
+	return s.primary.Name()
+}
+
+// Provider implements models.Model with the primary model's provider.
+func (s *Shadow) Provider() string {
+	return s.primary.Provider()
+}
+
+// Ask implements models.Model.
+func (s *Shadow) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	started := time.Now()
+	response, err := s.primary.Ask(ctx, message, context)
+	if err == nil {
+		s.compare(ctx, message, context, response, time.Since(started))
+	}
+	return response, err
+}
+
+// AskStream implements models.StreamingModel. The primary reply is streamed
+// as usual and compared once it is complete.
+func (s *Shadow) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	started := time.Now()
+	streamingModel, ok := s.primary.(models.StreamingModel)
+	if !ok {
+		response, err := s.Ask(ctx, message, context)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan string, 1)
+		out <- response
+		close(out)
+		return out, nil
+	}
+
+	in, err := streamingModel.AskStream(ctx, message, context)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+
+		var response strings.Builder
+		for chunk := range in {
+			response.WriteString(chunk)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				for range in {
+				}
+				return
+			}
+		}
+		s.compare(ctx, message, context, response.String(), time.Since(started))
+	}()
+	return out, nil
+}
+
+// Health implements models.HealthChecker. Only the primary model is checked,
+// since the shadow never affects users.
+func (s *Shadow) Health(ctx context.Context) error {
+	if checker, ok := s.primary.(models.HealthChecker); ok {
+		return checker.Health(ctx)
+	}
+	return nil
+}
+
+// Wait blocks until in-flight shadow requests have finished, for example
+// before shutting down.
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// compare sends the request to the shadow model in the background and logs
+// the result.
+func (s *Shadow) compare(ctx context.Context, message string, askContext map[string]interface{}, primary string, latency time.Duration) {
+	if s.sampleRate < 1 && rand.Float64() >= s.sampleRate { // #nosec G404 -- sampling is not security sensitive
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		return
+	}
+
+	// The request context may be modified by the caller once Ask returns
+	shadowContext := make(map[string]interface{}, len(askContext))
+	for key, value := range askContext {
+		shadowContext[key] = value
+	}
+
+	result := ShadowResult{
+		Time:             time.Now().UTC(),
+		Message:          message,
+		PrimaryModel:     s.primary.Name(),
+		Primary:          primary,
+		PrimaryLatencyMs: latency.Milliseconds(),
+		ShadowModel:      s.shadow.Name(),
+	}
+	result.ConversationID, _ = askContext["conversation_id"].(string)
+	result.UserID, _ = askContext["user_id"].(string)
+
+	// Keep going after the user's request has been answered
+	ctx = context.WithoutCancel(ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.inFlight }()
+
+		shadowCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+
+		started := time.Now()
+		reply, err := s.shadow.Ask(shadowCtx, message, shadowContext)
+		result.ShadowLatencyMs = time.Since(started).Milliseconds()
+		if err != nil {
+			result.ShadowError = err.Error()
+		} else {
+			result.Shadow = reply
+			result.Diff = Diff(primary, reply)
+			result.Similarity = Similarity(primary, reply)
+		}
+		s.logger.Log(ctx, result)
+	}()
+}
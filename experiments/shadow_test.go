@@ -0,0 +1,138 @@
+package experiments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+)
+
+// shadowRecorder collects shadow results.
+type shadowRecorder struct {
+	mutex   sync.Mutex
+	results []ShadowResult
+}
+
+func (r *shadowRecorder) Log(ctx context.Context, result ShadowResult) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, result)
+}
+
+func TestDiff(t *testing.T) {
+	if Diff("same", "same") != "" {
+		t.Error("expected no diff for equal texts")
+	}
+	got := Diff("Hello\nOpen 9-5\nBye", "Hello\nOpen 9-6\nBye")
+	want := "  Hello\n- Open 9-5\n+ Open 9-6\n  Bye\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := Diff("", "new"); got != "- \n+ new\n" {
+		t.Errorf("unexpected diff: %q", got)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"the shop opens at nine", "the shop opens at nine", 1},
+		{"the shop opens at nine", "the shop opens at ten", 0.8},
+		{"yes", "no", 0},
+	}
+	for _, test := range tests {
+		if got := Similarity(test.a, test.b); got != test.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestShadow(t *testing.T) {
+	primary := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: "We open at nine."})
+	candidate := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: "We open at 9am."})
+	recorder := &shadowRecorder{}
+	shadow := NewShadow(primary, candidate, WithShadowLogger(recorder))
+
+	askContext := map[string]interface{}{"conversation_id": "c1", "prompt": "Be brief."}
+	reply, err := shadow.Ask(context.Background(), "When do you open?", askContext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream, err := shadow.AskStream(context.Background(), "And on Sunday?", askContext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range stream {
+	}
+	shadow.Wait()
+
+	if reply != "We open at nine." {
+		t.Errorf("expected the primary reply, got %q", reply)
+	}
+	if len(recorder.results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(recorder.results))
+	}
+	result := recorder.results[0]
+	if result.ConversationID != "c1" || result.Primary != "We open at nine." || result.Shadow != "We open at 9am." {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Diff == "" || result.Similarity != 0.75 {
+		t.Errorf("unexpected comparison: diff %q, similarity %v", result.Diff, result.Similarity)
+	}
+	if call, _ := candidate.LastCall(); call.Context["prompt"] != "Be brief." || call.Stream {
+		t.Errorf("expected the shadow to get the same request, got %+v", call)
+	}
+}
+
+func TestShadowErrors(t *testing.T) {
+	primary := chatbottest.NewMockModel().Always(chatbottest.Response{Reply: "ok"})
+	candidate := chatbottest.NewMockModel().Fail(errors.New("rate limited"))
+	var buf bytes.Buffer
+	shadow := NewShadow(primary, candidate, WithShadowLogger(NewJSONShadowLogger(&buf)))
+
+	if _, err := shadow.Ask(context.Background(), "hi", nil); err != nil {
+		t.Fatalf("shadow errors must not fail the request: %v", err)
+	}
+	shadow.Wait()
+
+	var result ShadowResult
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if result.ShadowError != "rate limited" || result.Shadow != "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// Failed primary requests are not shadowed
+	failing := NewShadow(chatbottest.NewMockModel().Fail(errors.New("down")), candidate, WithShadowLogger(&shadowRecorder{}))
+	if _, err := failing.Ask(context.Background(), "hi", nil); err == nil {
+		t.Error("expected the primary error")
+	}
+	failing.Wait()
+	if len(candidate.Calls()) != 1 {
+		t.Errorf("expected one shadow call, got %d", len(candidate.Calls()))
+	}
+}
+
+func TestShadowSampling(t *testing.T) {
+	candidate := chatbottest.NewMockModel()
+	recorder := &shadowRecorder{}
+	shadow := NewShadow(chatbottest.NewMockModel(), candidate, WithSampleRate(0), WithShadowLogger(recorder))
+
+	for i := 0; i < 10; i++ {
+		if _, err := shadow.Ask(context.Background(), "hi", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	shadow.Wait()
+	if len(candidate.Calls()) != 0 || len(recorder.results) != 0 {
+		t.Errorf("expected nothing shadowed at a zero sample rate")
+	}
+}
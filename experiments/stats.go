@@ -0,0 +1,109 @@
+package experiments
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Stats summarizes a variant's results.
+type Stats struct {
+	Variant string `json:"variant"`
+	Model   string `json:"model"`
+	// Requests counts model calls, including failed ones.
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// AverageLatencyMs is the mean time to a complete reply.
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+	Cost             float64 `json:"cost"`
+	AverageCost      float64 `json:"average_cost"`
+	// Feedback counts recorded feedback scores.
+	Feedback        int     `json:"feedback"`
+	AverageFeedback float64 `json:"average_feedback"`
+}
+
+type variantStats struct {
+	mutex    sync.Mutex
+	requests int
+	errors   int
+	latency  time.Duration
+	cost     float64
+	feedback int
+	score    float64
+}
+
+// record adds a model call to the variant's stats.
+func (e *Experiment) record(variant Variant, started time.Time, prompt, response string, err error) {
+	latency := time.Since(started)
+	cost := e.cost(variant, prompt, response)
+
+	stats := e.stats[variant.Name]
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.requests++
+	stats.latency += latency
+	stats.cost += cost
+	if err != nil {
+		stats.errors++
+	}
+}
+
+// RecordFeedback adds a feedback score for a variant, for example 1 for a
+// thumbs up and 0 for a thumbs down, or a rating. Look the variant up with
+// Assign or from the conversation's tags.
+func (e *Experiment) RecordFeedback(variant string, score float64) error {
+	stats, ok := e.stats[variant]
+	if !ok {
+		return ErrUnknownVariant
+	}
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.feedback++
+	stats.score += score
+	return nil
+}
+
+// Stats returns the results of every variant, in variant order.
+func (e *Experiment) Stats() []Stats {
+	results := make([]Stats, 0, len(e.variants))
+	for _, variant := range e.variants {
+		stats := e.stats[variant.Name]
+		stats.mutex.Lock()
+		result := Stats{
+			Variant:  variant.Name,
+			Model:    variant.Model.Name(),
+			Requests: stats.requests,
+			Errors:   stats.errors,
+			Cost:     stats.cost,
+			Feedback: stats.feedback,
+		}
+		if stats.requests > 0 {
+			result.AverageLatencyMs = float64(stats.latency.Milliseconds()) / float64(stats.requests)
+			result.AverageCost = stats.cost / float64(stats.requests)
+		}
+		if stats.feedback > 0 {
+			result.AverageFeedback = stats.score / float64(stats.feedback)
+		}
+		stats.mutex.Unlock()
+		results = append(results, result)
+	}
+	return results
+}
+
+// ServeHTTP serves the experiment's stats as JSON.
+func (e *Experiment) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"experiment": e.name,
+		"split":      e.split,
+		"variants":   e.Stats(),
+	})
+}
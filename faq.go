@@ -0,0 +1,84 @@
+package gochatbot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/budget"
+	"go.rumenx.com/chatbot/faq"
+	"go.rumenx.com/chatbot/models"
+)
+
+// faqParaphrasePrompt instructs the model to fit an FAQ answer to the
+// question.
+const faqParaphrasePrompt = `Answer the user's question by rephrasing the approved answer below so it addresses the question directly. Keep every fact, add none, and keep it about as long.
+
+Approved answer:
+%s`
+
+// WithFAQ answers messages matching an entry of matcher with its curated
+// answer before the model is called, cutting the cost of common questions.
+// With paraphrase, the model rewords the answer to fit the question, which
+// still costs a short model call; if that fails the answer is used as is.
+// Messages refused by guardrails are refused before matching.
+func WithFAQ(matcher *faq.Matcher, paraphrase bool) Option {
+	return func(c *Chatbot) {
+		c.faq = matcher
+		c.rephrase = paraphrase
+	}
+}
+
+// answerFAQ returns the answer of the FAQ entry matching message, if any.
+// Matching failures are logged and leave the message to the model.
+func (c *Chatbot) answerFAQ(ctx context.Context, b *budget.Budget, message string, askContext map[string]interface{}) (string, bool, error) {
+	if c.faq == nil {
+		return "", false, nil
+	}
+	match, err := c.faq.Match(ctx, message)
+	if err != nil {
+		c.logf("faq: %v", err)
+		return "", false, nil
+	}
+	if match == nil {
+		return "", false, nil
+	}
+	track := func(model models.Model) {
+		properties := map[string]interface{}{"reason": analytics.FallbackFAQ, "faq_id": match.Entry.ID, "score": match.Score}
+		if model != nil {
+			properties["model"] = model.Name()
+		}
+		c.track(ctx, analytics.FallbackUsed, askContext, properties)
+	}
+	if !c.rephrase {
+		track(nil)
+		return match.Entry.Answer, true, nil
+	}
+
+	// Paraphrase with the request's options but the FAQ's prompt
+	paraphraseContext := make(map[string]interface{}, len(askContext))
+	for key, value := range askContext {
+		paraphraseContext[key] = value
+	}
+	paraphraseContext["prompt"] = fmt.Sprintf(faqParaphrasePrompt, match.Entry.Answer)
+	started := time.Now()
+	modelCtx, done := stage(ctx, b, budget.StageModel)
+	reply, err := c.model.Ask(modelCtx, message, paraphraseContext)
+	done()
+	if err != nil {
+		c.logf("faq: failed to paraphrase %q: %v", match.Entry.ID, err)
+		track(nil)
+		return match.Entry.Answer, true, nil
+	}
+	postCtx, done := stage(ctx, b, budget.StagePostProcess)
+	reply, err = c.guardOutput(postCtx, reply, askContext)
+	done()
+	if err != nil {
+		return "", false, err
+	}
+	reply = c.applyGlossary(reply)
+	track(c.model)
+	c.publishReply(ctx, c.model, message, reply, started, askContext)
+	return reply, true, nil
+}
@@ -0,0 +1,149 @@
+// Package faq matches questions against a curated set of frequently asked
+// questions by embedding similarity, so common questions can be answered
+// with a canned answer instead of a model call.
+//
+//	entries, err := faq.Load("faq.yaml")
+//	matcher, err := faq.NewMatcher(ctx, provider, entries, faq.WithThreshold(0.88))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithFAQ(matcher, false))
+package faq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// DefaultThreshold is the similarity a question needs by default to match.
+// Useful values depend on the embedding model, so check it against real
+// questions.
+const DefaultThreshold = 0.85
+
+// Entry is a curated question and its answer.
+type Entry struct {
+	// ID identifies the entry in analytics and logs; if empty, the
+	// question is used.
+	ID       string `json:"id,omitempty" yaml:"id"`
+	Question string `json:"question" yaml:"question"`
+	// Variants are other phrasings of the question, matched like it.
+	Variants []string `json:"variants,omitempty" yaml:"variants"`
+	Answer   string   `json:"answer" yaml:"answer"`
+}
+
+// Match is an entry matching a question.
+type Match struct {
+	Entry Entry
+	// Score is the cosine similarity of the question and the closest
+	// phrasing of the entry.
+	Score float64
+}
+
+// Option configures a Matcher.
+type Option func(*Matcher)
+
+// WithThreshold sets the similarity a question needs to match.
+func WithThreshold(threshold float64) Option {
+	return func(m *Matcher) {
+		m.threshold = threshold
+	}
+}
+
+// Matcher finds the FAQ entry closest to a question.
+type Matcher struct {
+	provider  embeddings.EmbeddingProvider
+	threshold float64
+	entries   []Entry
+	// vectors holds the embedding of every phrasing, and owners the index
+	// of its entry
+	vectors []embeddings.Vector
+	owners  []int
+}
+
+// NewMatcher embeds the questions and variants of entries with provider.
+// It returns an error if an entry has no question or answer, or embedding
+// fails.
+func NewMatcher(ctx context.Context, provider embeddings.EmbeddingProvider, entries []Entry, opts ...Option) (*Matcher, error) {
+	m := &Matcher{provider: provider, threshold: DefaultThreshold, entries: append([]Entry(nil), entries...)}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	var phrasings []string
+	for i, entry := range entries {
+		if strings.TrimSpace(entry.Question) == "" || strings.TrimSpace(entry.Answer) == "" {
+			return nil, fmt.Errorf("faq entry %d needs a question and an answer", i+1)
+		}
+		if entry.ID == "" {
+			m.entries[i].ID = entry.Question
+		}
+		for _, phrasing := range append([]string{entry.Question}, entry.Variants...) {
+			phrasings = append(phrasings, phrasing)
+			m.owners = append(m.owners, i)
+		}
+	}
+	if len(phrasings) == 0 {
+		return m, nil
+	}
+
+	vectors, err := provider.Embed(ctx, phrasings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed faq: %w", err)
+	}
+	if len(vectors) != len(phrasings) {
+		return nil, fmt.Errorf("failed to embed faq: got %d embeddings for %d questions", len(vectors), len(phrasings))
+	}
+	m.vectors = vectors
+	return m, nil
+}
+
+// Match returns the entry closest to question, or nil if none reaches the
+// threshold.
+func (m *Matcher) Match(ctx context.Context, question string) (*Match, error) {
+	if len(m.vectors) == 0 {
+		return nil, nil
+	}
+	vector, err := m.provider.EmbedSingle(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	best, score := -1, 0.0
+	for i, reference := range m.vectors {
+		if similarity := embeddings.CosineSimilarity(vector, reference); similarity > score {
+			best, score = m.owners[i], similarity
+		}
+	}
+	if best < 0 || score < m.threshold {
+		return nil, nil
+	}
+	return &Match{Entry: m.entries[best], Score: score}, nil
+}
+
+// Load reads FAQ entries from a file. The format is chosen from the
+// extension (.json, .yaml or .yml); both hold a list of entries.
+func Load(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read faq: %w", err)
+	}
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &entries)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &entries)
+	default:
+		return nil, fmt.Errorf("unsupported faq extension: %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse faq: %w", err)
+	}
+	return entries, nil
+}
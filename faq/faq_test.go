@@ -0,0 +1,107 @@
+package faq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// keywordEmbeddings embeds texts as keyword counts so similarity is predictable.
+type keywordEmbeddings struct{ err error }
+
+var keywords = []string{"refund", "shipping", "password", "reset"}
+
+func (k keywordEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = make(embeddings.Vector, len(keywords))
+		for j, keyword := range keywords {
+			vectors[i][j] = float64(strings.Count(strings.ToLower(text), keyword))
+		}
+	}
+	return vectors, nil
+}
+
+func (k keywordEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	vectors, err := k.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (keywordEmbeddings) Dimensions() int  { return len(keywords) }
+func (keywordEmbeddings) Model() string    { return "keywords" }
+func (keywordEmbeddings) Provider() string { return "test" }
+
+var testEntries = []Entry{
+	{ID: "refunds", Question: "How long does a refund take?", Answer: "Refunds take five days."},
+	{Question: "How do I reset my password?", Variants: []string{"Forgot password"}, Answer: "Use the reset link."},
+}
+
+func TestMatcher(t *testing.T) {
+	ctx := context.Background()
+	matcher, err := NewMatcher(ctx, keywordEmbeddings{}, testEntries)
+	if err != nil {
+		t.Fatalf("failed to create matcher: %v", err)
+	}
+
+	match, err := matcher.Match(ctx, "When will my refund arrive?")
+	if err != nil || match == nil || match.Entry.ID != "refunds" || match.Score < 0.99 {
+		t.Fatalf("expected the refunds entry, got %+v, %v", match, err)
+	}
+	// Variants match, and entries without an ID are identified by their question
+	match, _ = matcher.Match(ctx, "I lost my password")
+	if match == nil || match.Entry.ID != "How do I reset my password?" {
+		t.Errorf("expected the password entry, got %+v", match)
+	}
+	if match, _ := matcher.Match(ctx, "What about shipping?"); match != nil {
+		t.Errorf("expected no match, got %+v", match)
+	}
+	if testEntries[1].ID != "" {
+		t.Error("expected the entries not to be modified")
+	}
+
+	strict, _ := NewMatcher(ctx, keywordEmbeddings{}, testEntries, WithThreshold(0.99))
+	if match, _ := strict.Match(ctx, "refund and shipping"); match != nil {
+		t.Errorf("expected the threshold to reject a partial match, got %+v", match)
+	}
+}
+
+func TestNewMatcherErrors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewMatcher(ctx, keywordEmbeddings{}, []Entry{{Question: "Hours?"}}); err == nil {
+		t.Error("expected an entry without an answer to be rejected")
+	}
+	if _, err := NewMatcher(ctx, keywordEmbeddings{err: errors.New("unavailable")}, testEntries); err == nil {
+		t.Error("expected the embedding error")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "faq.yaml")
+	os.WriteFile(yamlPath, []byte("- id: hours\n  question: When are you open?\n  variants: [Opening hours]\n  answer: Nine to five.\n"), 0o600)
+	entries, err := Load(yamlPath)
+	if err != nil || len(entries) != 1 || entries[0].ID != "hours" || entries[0].Variants[0] != "Opening hours" {
+		t.Errorf("unexpected entries: %+v, %v", entries, err)
+	}
+
+	jsonPath := filepath.Join(dir, "faq.json")
+	os.WriteFile(jsonPath, []byte(`[{"question":"When are you open?","answer":"Nine to five."}]`), 0o600)
+	if entries, err := Load(jsonPath); err != nil || entries[0].Answer != "Nine to five." {
+		t.Errorf("unexpected entries: %+v, %v", entries, err)
+	}
+
+	if _, err := Load(filepath.Join(dir, "faq.txt")); err == nil {
+		t.Error("expected an error for an unsupported extension")
+	}
+}
@@ -0,0 +1,101 @@
+package gochatbot
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/faq"
+)
+
+// refundEmbeddings embeds texts by whether they mention refunds.
+type refundEmbeddings struct{}
+
+func (refundEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = embeddings.Vector{1, 0}
+		if strings.Contains(strings.ToLower(text), "refund") {
+			vectors[i] = embeddings.Vector{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func (e refundEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	vectors, err := e.Embed(ctx, []string{text})
+	return vectors[0], err
+}
+
+func (refundEmbeddings) Dimensions() int  { return 2 }
+func (refundEmbeddings) Model() string    { return "refunds" }
+func (refundEmbeddings) Provider() string { return "test" }
+
+func newTestFAQ(t *testing.T) *faq.Matcher {
+	t.Helper()
+	matcher, err := faq.NewMatcher(context.Background(), refundEmbeddings{}, []faq.Entry{
+		{ID: "refunds", Question: "How long do refunds take?", Answer: "Refunds take five days."},
+	})
+	if err != nil {
+		t.Fatalf("failed to create matcher: %v", err)
+	}
+	return matcher
+}
+
+func TestFAQ(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &echoModel{}
+	var fallback analytics.Event
+	bot, err := New(cfg, WithModel(model), WithFAQ(newTestFAQ(t), false),
+		WithHooks(Hooks{OnAnalytics: func(event analytics.Event) {
+			if event.Name == analytics.FallbackUsed {
+				fallback = event
+			}
+		}}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	reply, err := bot.Ask(context.Background(), "When do I get my refund?")
+	if err != nil || reply != "Refunds take five days." || len(model.messages) != 0 {
+		t.Errorf("expected the FAQ answer without a model call, got %q, %v after %d call(s)", reply, err, len(model.messages))
+	}
+	if fallback.Properties["reason"] != analytics.FallbackFAQ || fallback.Properties["faq_id"] != "refunds" {
+		t.Errorf("expected an FAQ fallback, got %+v", fallback)
+	}
+
+	if reply, _ := bot.Ask(context.Background(), "Hello"); reply != "You said: Hello" {
+		t.Errorf("expected other messages to reach the model, got %q", reply)
+	}
+
+	w := httptest.NewRecorder()
+	if err := bot.AskStream(context.Background(), w, "Refund status?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), "Refunds take five days.") {
+		t.Errorf("expected the FAQ answer to be streamed, got %s", w.Body.String())
+	}
+}
+
+func TestFAQParaphrase(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &scriptedModel{replies: []string{"Your refund arrives within five days."}}
+	bot, err := New(cfg, WithModel(model), WithFAQ(newTestFAQ(t), true))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	reply, err := bot.Ask(context.Background(), "When do I get my refund?")
+	if err != nil || reply != "Your refund arrives within five days." {
+		t.Errorf("expected the paraphrased answer, got %q, %v", reply, err)
+	}
+	if len(model.prompts) != 1 || !strings.Contains(model.prompts[0], "Approved answer:\nRefunds take five days.") {
+		t.Errorf("expected the model to be given the FAQ answer, got %q", model.prompts)
+	}
+}
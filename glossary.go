@@ -0,0 +1,59 @@
+package gochatbot
+
+import (
+	"context"
+
+	"go.rumenx.com/chatbot/glossary"
+)
+
+// WithGlossary rewrites model replies to use the preferred terms of g,
+// whether buffered or streamed. Streamed replies are held back by a few
+// characters so terms split across chunks are caught.
+func WithGlossary(g *glossary.Glossary) Option {
+	return func(c *Chatbot) {
+		c.glossary = g
+	}
+}
+
+// applyGlossary returns reply with the preferred terms.
+func (c *Chatbot) applyGlossary(reply string) string {
+	if c.glossary == nil {
+		return reply
+	}
+	return c.glossary.Apply(reply)
+}
+
+// streamGlossary rewrites a streamed reply to use the preferred terms.
+func (c *Chatbot) streamGlossary(ctx context.Context, in <-chan string) <-chan string {
+	if c.glossary == nil {
+		return in
+	}
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer c.recoverBackground()
+
+		rewriter := c.glossary.NewRewriter()
+		send := func(chunk string) bool {
+			if chunk == "" {
+				return true
+			}
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				// Drain so the model's goroutine can finish
+				for range in {
+				}
+				return false
+			}
+		}
+		for chunk := range in {
+			if !send(rewriter.Write(chunk)) {
+				return
+			}
+		}
+		send(rewriter.Flush())
+	}()
+	return out
+}
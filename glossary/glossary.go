@@ -0,0 +1,265 @@
+// Package glossary enforces preferred terminology in replies: deprecated
+// product names are replaced with current ones and brand names get their
+// proper casing. Code and URLs are left alone.
+//
+//	g, err := glossary.Load("glossary.yaml")
+//	bot, err := gochatbot.New(cfg, gochatbot.WithGlossary(g))
+//
+// Streamed replies are rewritten with a Rewriter, which holds back just
+// enough text to catch terms split across chunks.
+package glossary
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Term is a preferred term and the variants replaced with it.
+type Term struct {
+	// Preferred is the term as it should appear, e.g. "GitHub".
+	Preferred string `json:"preferred" yaml:"preferred"`
+	// Replaces lists deprecated names and misspellings of the term.
+	Replaces []string `json:"replaces,omitempty" yaml:"replaces"`
+	// CaseSensitive matches the variants exactly. By default they and
+	// Preferred are matched in any case, so the casing of Preferred is
+	// enforced; set it for terms that are also common words, like "Go".
+	CaseSensitive bool `json:"case_sensitive,omitempty" yaml:"case_sensitive"`
+}
+
+// protected matches the text terms are never replaced in: fenced and
+// inline code, also when not closed yet, and URLs.
+var protected = regexp.MustCompile("```[\\s\\S]*?(?:```|\\z)|`[^`]*(?:`|\\z)|\\bhttps?://\\S+")
+
+// Glossary rewrites text to use preferred terms.
+type Glossary struct {
+	pattern *regexp.Regexp
+	// preferred holds the replacement of each group of the pattern
+	preferred []string
+	// maxLen is the length of the longest variant, in bytes
+	maxLen int
+}
+
+// New compiles a glossary. Variants are matched as whole words, longest
+// first. It returns an error if a term has no preferred form or variants
+// are claimed by several terms.
+func New(terms []Term) (*Glossary, error) {
+	type variant struct {
+		text  string
+		term  int
+		exact bool
+	}
+	var variants []variant
+	claimed := make(map[string]int)
+	for i, term := range terms {
+		if strings.TrimSpace(term.Preferred) == "" {
+			return nil, fmt.Errorf("glossary term %d has no preferred form", i+1)
+		}
+		texts := term.Replaces
+		if !term.CaseSensitive {
+			texts = append([]string{term.Preferred}, texts...)
+		}
+		for _, text := range texts {
+			if text == "" {
+				continue
+			}
+			key := text
+			if !term.CaseSensitive {
+				key = strings.ToLower(text)
+			}
+			if other, ok := claimed[key]; ok && other != i {
+				return nil, fmt.Errorf("glossary variant %q belongs to %q and %q", text, terms[other].Preferred, term.Preferred)
+			}
+			claimed[key] = i
+			variants = append(variants, variant{text: text, term: i, exact: term.CaseSensitive})
+		}
+	}
+
+	g := &Glossary{}
+	if len(variants) == 0 {
+		return g, nil
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return len(variants[i].text) > len(variants[j].text)
+	})
+	alternatives := make([]string, len(variants))
+	for i, v := range variants {
+		alternative := regexp.QuoteMeta(v.text)
+		if !v.exact {
+			alternative = "(?i:" + alternative + ")"
+		}
+		// Go's \b only knows ASCII words
+		if isWordByte(v.text[0]) && v.text[0] < 0x80 {
+			alternative = `\b` + alternative
+		}
+		if isWordByte(v.text[len(v.text)-1]) && v.text[len(v.text)-1] < 0x80 {
+			alternative += `\b`
+		}
+		alternatives[i] = "(" + alternative + ")"
+		g.preferred = append(g.preferred, terms[v.term].Preferred)
+		g.maxLen = max(g.maxLen, len(v.text))
+	}
+	pattern, err := regexp.Compile(strings.Join(alternatives, "|"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile glossary: %w", err)
+	}
+	g.pattern = pattern
+	return g, nil
+}
+
+// Load reads and compiles a glossary file. The format is chosen from the
+// extension (.json, .yaml or .yml); both hold a list of terms.
+func Load(path string) (*Glossary, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read glossary: %w", err)
+	}
+
+	var terms []Term
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &terms)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &terms)
+	default:
+		return nil, fmt.Errorf("unsupported glossary extension: %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse glossary: %w", err)
+	}
+	return New(terms)
+}
+
+// Apply returns text with the preferred terms.
+func (g *Glossary) Apply(text string) string {
+	return g.replace(text, g.scan(text), 0, len(text))
+}
+
+// span is a variant found in text.
+type span struct {
+	start, end int
+	preferred  string
+}
+
+// scan returns the variants found in text outside code and URLs.
+func (g *Glossary) scan(text string) []span {
+	if g.pattern == nil {
+		return nil
+	}
+	skip := protected.FindAllStringIndex(text, -1)
+	var spans []span
+	for _, match := range g.pattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[0], match[1]
+		for len(skip) > 0 && skip[0][1] <= start {
+			skip = skip[1:]
+		}
+		if len(skip) > 0 && skip[0][0] < end {
+			continue
+		}
+		// The group that matched identifies the variant
+		for group := 1; group < len(match)/2; group++ {
+			if match[2*group] >= 0 {
+				spans = append(spans, span{start: start, end: end, preferred: g.preferred[group-1]})
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// replace returns text[from:to] with the spans starting in it replaced.
+func (g *Glossary) replace(text string, spans []span, from, to int) string {
+	var out strings.Builder
+	last := from
+	for _, s := range spans {
+		if s.start < from || s.start >= to {
+			continue
+		}
+		out.WriteString(text[last:s.start])
+		out.WriteString(s.preferred)
+		last = s.end
+	}
+	out.WriteString(text[last:to])
+	return out.String()
+}
+
+// Rewriter applies a glossary to text arriving in chunks.
+type Rewriter struct {
+	glossary *Glossary
+	// text holds what was received since a point outside code, URLs and
+	// words, of which the first emitted bytes were already returned
+	text    string
+	emitted int
+}
+
+// NewRewriter returns a rewriter of a stream of text.
+func (g *Glossary) NewRewriter() *Rewriter {
+	return &Rewriter{glossary: g}
+}
+
+// Write adds a chunk and returns the text that can be sent on, rewritten,
+// which may be empty while a term could still be completed.
+func (r *Rewriter) Write(chunk string) string {
+	r.text += chunk
+	return r.emit(len(r.text) - r.glossary.maxLen)
+}
+
+// Flush returns the rest of the text at the end of the stream.
+func (r *Rewriter) Flush() string {
+	return r.emit(len(r.text))
+}
+
+// emit returns the rewritten text up to about cut. Variants starting before
+// cut are complete, since none is longer than the text after it.
+func (r *Rewriter) emit(cut int) string {
+	if cut <= r.emitted {
+		return ""
+	}
+	spans := r.glossary.scan(r.text)
+	end := cut
+	for _, s := range spans {
+		if s.start < cut && s.end > cut {
+			end = s.end
+		}
+	}
+	// Never end inside a word or character, so later matches see its start
+	for end < len(r.text) && end > r.emitted && isWordByte(r.text[end-1]) && isWordByte(r.text[end]) {
+		end--
+	}
+	for _, s := range spans {
+		if s.start < end && end < s.end {
+			end = s.start
+		}
+	}
+	if end <= r.emitted {
+		return ""
+	}
+	out := r.glossary.replace(r.text, spans, r.emitted, end)
+	r.emitted = end
+
+	// Keep the text since the last space outside code and URLs as context
+	keep := strings.LastIndexAny(r.text[:end], " \t\n")
+	for _, skip := range protected.FindAllStringIndex(r.text, -1) {
+		if skip[0] <= keep && keep < skip[1] {
+			keep = skip[0] - 1
+			break
+		}
+	}
+	if keep > 0 {
+		r.text = r.text[keep:]
+		r.emitted -= keep
+	}
+	return out
+}
+
+// isWordByte reports whether b belongs to a word: an ASCII letter, digit or
+// underscore, or part of a multi-byte character.
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 0x80 || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
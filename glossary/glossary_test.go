@@ -0,0 +1,80 @@
+package glossary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testTerms = []Term{
+	{Preferred: "GitHub"},
+	{Preferred: "Acme Platform", Replaces: []string{"Acme Cloud", "Acme Cloud Classic"}},
+	{Preferred: "Go", Replaces: []string{"Golang", "golang"}, CaseSensitive: true},
+}
+
+func newTestGlossary(t *testing.T) *Glossary {
+	t.Helper()
+	g, err := New(testTerms)
+	if err != nil {
+		t.Fatalf("failed to create glossary: %v", err)
+	}
+	return g
+}
+
+func TestApply(t *testing.T) {
+	g := newTestGlossary(t)
+	for text, want := range map[string]string{
+		"Push it to github or Github.":                   "Push it to GitHub or GitHub.",
+		"Acme Cloud Classic users move to acme cloud.":   "Acme Platform users move to Acme Platform.",
+		"Golang is great; go build it.":                  "Go is great; go build it.",
+		"githubber and Golangs are other words.":         "githubber and Golangs are other words.",
+		"See https://github.com/acme/golang for github.": "See https://github.com/acme/golang for GitHub.",
+		"Run `golang build` then:\n```\ngithub\n```":     "Run `golang build` then:\n```\ngithub\n```",
+	} {
+		if got := g.Apply(text); got != want {
+			t.Errorf("Apply(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestRewriter(t *testing.T) {
+	g := newTestGlossary(t)
+	text := "Acme Cloud Classic is now on github. Golang and golang, see `acme cloud` or " +
+		"https://example.com/github. Also Acme Cloud, githubs and Acme Cloudy, and ```\nGolang\n``` done github"
+	want := g.Apply(text)
+
+	// Every way of splitting the text into chunks gives the same result
+	for size := 1; size <= len(text); size++ {
+		rewriter := g.NewRewriter()
+		var got strings.Builder
+		for start := 0; start < len(text); start += size {
+			got.WriteString(rewriter.Write(text[start:min(start+size, len(text))]))
+		}
+		got.WriteString(rewriter.Flush())
+		if got.String() != want {
+			t.Fatalf("chunks of %d: got %q, want %q", size, got.String(), want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New([]Term{{Replaces: []string{"old"}}}); err == nil {
+		t.Error("expected a term without a preferred form to be rejected")
+	}
+	if _, err := New([]Term{{Preferred: "A", Replaces: []string{"x"}}, {Preferred: "B", Replaces: []string{"X"}}}); err == nil {
+		t.Error("expected a variant of two terms to be rejected")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary.yaml")
+	os.WriteFile(path, []byte("- preferred: GitHub\n- preferred: Go\n  replaces: [Golang]\n  case_sensitive: true\n"), 0o600)
+	g, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load glossary: %v", err)
+	}
+	if got := g.Apply("Golang on github"); got != "Go on GitHub" {
+		t.Errorf("unexpected rewrite %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/glossary"
+)
+
+// echoStreamModel streams the message back in chunks of three bytes.
+type echoStreamModel struct{}
+
+func (echoStreamModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return message, nil
+}
+
+func (echoStreamModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	ch := make(chan string, len(message))
+	for start := 0; start < len(message); start += 3 {
+		ch <- message[start:min(start+3, len(message))]
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (echoStreamModel) Name() string     { return "echo-stream" }
+func (echoStreamModel) Provider() string { return "test" }
+
+func TestGlossary(t *testing.T) {
+	g, err := glossary.New([]glossary.Term{
+		{Preferred: "GitHub"},
+		{Preferred: "Acme Platform", Replaces: []string{"Acme Cloud"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create glossary: %v", err)
+	}
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	bot, err := New(cfg, WithModel(echoStreamModel{}), WithGlossary(g))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	const message, want = "Deploy acme cloud apps from github", "Deploy Acme Platform apps from GitHub"
+	if reply, err := bot.Ask(context.Background(), message); err != nil || reply != want {
+		t.Errorf("expected %q, got %q, %v", want, reply, err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := bot.AskStream(context.Background(), w, message); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var streamed strings.Builder
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		var chunk struct{ Content string }
+		if data, ok := strings.CutPrefix(line, "data: "); ok && json.Unmarshal([]byte(data), &chunk) == nil {
+			streamed.WriteString(chunk.Content)
+		}
+	}
+	if streamed.String() != want {
+		t.Errorf("expected the stream to read %q, got %q", want, streamed.String())
+	}
+}
@@ -0,0 +1,51 @@
+package gochatbot
+
+import (
+	"context"
+	"fmt"
+
+	"go.rumenx.com/chatbot/guardrails"
+)
+
+// WithGuardrails enforces a guardrails policy. User messages are checked
+// before they reach the model and replies before they are returned; refused
+// messages are answered with the rule's refusal instead. When the policy
+// checks output, AskStream sends the reply as a single chunk once it has
+// been checked.
+func WithGuardrails(g *guardrails.Guardrails) Option {
+	return func(c *Chatbot) {
+		c.guard = g
+	}
+}
+
+// guardInput checks a user message. It returns the message to send to the
+// model, with redactions applied, or the refusal to answer with.
+func (c *Chatbot) guardInput(ctx context.Context, message string, askContext map[string]interface{}) (string, string, error) {
+	if c.guard == nil {
+		return message, "", nil
+	}
+	decision, err := c.guard.CheckInput(ctx, message, askContext)
+	if err != nil {
+		return "", "", fmt.Errorf("guardrails check failed: %w", err)
+	}
+	if !decision.Allowed {
+		return "", decision.Refusal, nil
+	}
+	return decision.Text, "", nil
+}
+
+// guardOutput checks a model reply and returns it with redactions applied,
+// or the refusal in its place.
+func (c *Chatbot) guardOutput(ctx context.Context, reply string, askContext map[string]interface{}) (string, error) {
+	if c.guard == nil {
+		return reply, nil
+	}
+	decision, err := c.guard.CheckOutput(ctx, reply, askContext)
+	if err != nil {
+		return "", fmt.Errorf("guardrails check failed: %w", err)
+	}
+	if !decision.Allowed {
+		return decision.Refusal, nil
+	}
+	return decision.Text, nil
+}
@@ -0,0 +1,238 @@
+package guardrails
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/internal/logging"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// AuditEntry records one enforcement decision: a rule that fired and what it
+// did. Matched text is included so reviewers can judge false positives;
+// protect the audit log accordingly.
+type AuditEntry struct {
+	Time           time.Time `json:"time"`
+	Stage          Stage     `json:"stage"`
+	Rule           string    `json:"rule"`
+	Action         Action    `json:"action"`
+	Allowed        bool      `json:"allowed"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	UserID         string    `json:"user_id,omitempty"`
+	Matches        []Match   `json:"matches"`
+}
+
+// AuditLogger records enforcement decisions.
+type AuditLogger interface {
+	Log(ctx context.Context, entry AuditEntry)
+}
+
+// AuditFunc adapts a function to AuditLogger.
+type AuditFunc func(ctx context.Context, entry AuditEntry)
+
+// Log implements AuditLogger.
+func (f AuditFunc) Log(ctx context.Context, entry AuditEntry) {
+	f(ctx, entry)
+}
+
+// ToolPermissionsRule is the rule of audit entries for denied tool calls.
+const ToolPermissionsRule = "tool_permissions"
+
+// ToolDenials returns a tools.DenialHandler that records denied tool calls
+// in logger, so they appear in the audit trail next to guardrail decisions:
+//
+//	registry.SetAuthorizer(permissions, guardrails.ToolDenials(auditLog))
+func ToolDenials(logger AuditLogger) tools.DenialHandler {
+	return func(ctx context.Context, caller tools.Caller, tool string, err error) {
+		logger.Log(ctx, AuditEntry{
+			Time:           time.Now(),
+			Stage:          StageTool,
+			Rule:           ToolPermissionsRule,
+			Action:         ActionRefuse,
+			ConversationID: caller.ConversationID,
+			UserID:         caller.UserID,
+			Matches: []Match{{
+				Rule:   ToolPermissionsRule,
+				Action: ActionRefuse,
+				Kind:   "tool",
+				Match:  tool,
+			}},
+		})
+	}
+}
+
+// JSONAuditLogger writes entries as JSON lines.
+type JSONAuditLogger struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONAuditLogger writes one JSON object per line to w.
+func NewJSONAuditLogger(w io.Writer) *JSONAuditLogger {
+	return &JSONAuditLogger{encoder: json.NewEncoder(w)}
+}
+
+// Log implements AuditLogger.
+func (l *JSONAuditLogger) Log(ctx context.Context, entry AuditEntry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.encoder.Encode(entry); err != nil {
+		logging.Printf(ctx, "guardrails: failed to write audit entry: %v", err)
+	}
+}
+
+// FileAuditLogger appends entries as JSON lines to a file and can find and
+// remove a user's entries, so the audit log can be included in data subject
+// requests.
+type FileAuditLogger struct {
+	path string
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileAuditLogger opens path for appending, creating it if needed.
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileAuditLogger{path: path, file: file}, nil
+}
+
+// Log implements AuditLogger.
+func (l *FileAuditLogger) Log(ctx context.Context, entry AuditEntry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := json.NewEncoder(l.file).Encode(entry); err != nil {
+		logging.Printf(ctx, "guardrails: failed to write audit entry: %v", err)
+	}
+}
+
+// UserEntries returns the entries recorded for a user.
+func (l *FileAuditLogger) UserEntries(ctx context.Context, userID string) ([]AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entries, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	var matched []AuditEntry
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			matched = append(matched, entry)
+		}
+	}
+	return matched, nil
+}
+
+// DeleteUserEntries rewrites the log without a user's entries and returns
+// how many were removed.
+func (l *FileAuditLogger) DeleteUserEntries(ctx context.Context, userID string) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entries, err := l.read()
+	if err != nil {
+		return 0, err
+	}
+
+	// Write the remaining entries next to the log and swap it in
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	removed := 0
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			removed++
+			continue
+		}
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			return 0, fmt.Errorf("failed to rewrite audit log: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return 0, fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return removed, fmt.Errorf("failed to reopen audit log: %w", err)
+	}
+	_ = l.file.Close()
+	l.file = file
+	return removed, nil
+}
+
+// Close closes the log file.
+func (l *FileAuditLogger) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.file.Close()
+}
+
+// read decodes every entry in the log.
+func (l *FileAuditLogger) read() ([]AuditEntry, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	decoder := json.NewDecoder(file)
+	for {
+		var entry AuditEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// logAuditLogger writes entries with the chatbot's logger.
+type logAuditLogger struct{}
+
+func (logAuditLogger) Log(ctx context.Context, entry AuditEntry) {
+	logging.Printf(ctx, "guardrails: %s rule %q (%s) on %s, conversation %q, %d match(es)",
+		entry.Action, entry.Rule, allowedLabel(entry.Allowed), entry.Stage, entry.ConversationID, len(entry.Matches))
+}
+
+func allowedLabel(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "refused"
+}
@@ -0,0 +1,132 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/models"
+)
+
+// Classifier scores text against semantic categories.
+type Classifier interface {
+	// Classify returns a score from 0 to 1 for each category name.
+	Classify(ctx context.Context, text string, categories []Category) (map[string]float64, error)
+}
+
+// EmbeddingClassifier scores categories by the cosine similarity between the
+// text and the category's description and examples. It is cheap and fast;
+// set thresholds per category after checking real messages, since useful
+// values depend on the embedding model.
+type EmbeddingClassifier struct {
+	provider embeddings.EmbeddingProvider
+
+	mutex   sync.Mutex
+	vectors map[string][]embeddings.Vector
+}
+
+// NewEmbeddingClassifier creates a classifier using provider.
+func NewEmbeddingClassifier(provider embeddings.EmbeddingProvider) *EmbeddingClassifier {
+	return &EmbeddingClassifier{provider: provider, vectors: make(map[string][]embeddings.Vector)}
+}
+
+// Classify implements Classifier. A category scores the highest similarity
+// of any of its description and examples.
+func (c *EmbeddingClassifier) Classify(ctx context.Context, text string, categories []Category) (map[string]float64, error) {
+	vector, err := c.provider.EmbedSingle(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+
+	scores := make(map[string]float64, len(categories))
+	for _, category := range categories {
+		references, err := c.references(ctx, category)
+		if err != nil {
+			return nil, err
+		}
+		for _, reference := range references {
+			scores[category.Name] = math.Max(scores[category.Name], embeddings.CosineSimilarity(vector, reference))
+		}
+	}
+	return scores, nil
+}
+
+// references embeds a category's description and examples once.
+func (c *EmbeddingClassifier) references(ctx context.Context, category Category) ([]embeddings.Vector, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if vectors, ok := c.vectors[category.Name]; ok {
+		return vectors, nil
+	}
+
+	var texts []string
+	if category.Description != "" {
+		texts = append(texts, category.Description)
+	}
+	texts = append(texts, category.Examples...)
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("category %q has no description or examples", category.Name)
+	}
+	vectors, err := c.provider.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed category %q: %w", category.Name, err)
+	}
+	c.vectors[category.Name] = vectors
+	return vectors, nil
+}
+
+// ModelClassifier asks a model to score the categories. It copes better
+// with paraphrases and context than embeddings, at the cost of a model call
+// per check.
+type ModelClassifier struct {
+	model models.Model
+}
+
+// NewModelClassifier creates a classifier using model, ideally a small,
+// cheap one.
+func NewModelClassifier(model models.Model) *ModelClassifier {
+	return &ModelClassifier{model: model}
+}
+
+// classifierPrompt instructs the model to score categories.
+const classifierPrompt = `You are a content policy classifier. For each category below, rate from 0 to 1 how well the user's text belongs to it. Respond with JSON only, in the form {"category name": score}.
+
+Categories:
+%s`
+
+// Classify implements Classifier.
+func (c *ModelClassifier) Classify(ctx context.Context, text string, categories []Category) (map[string]float64, error) {
+	var list strings.Builder
+	for _, category := range categories {
+		fmt.Fprintf(&list, "- %s: %s\n", category.Name, category.Description)
+		for _, example := range category.Examples {
+			fmt.Fprintf(&list, "  example: %q\n", example)
+		}
+	}
+
+	reply, err := c.model.Ask(ctx, text, map[string]interface{}{
+		"prompt":      fmt.Sprintf(classifierPrompt, list.String()),
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("classification request failed: %w", err)
+	}
+
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no classification JSON in model reply")
+	}
+	var scores map[string]float64
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("failed to parse classification: %w", err)
+	}
+	for name, score := range scores {
+		scores[name] = math.Max(0, math.Min(1, score))
+	}
+	return scores, nil
+}
@@ -0,0 +1,344 @@
+// Package guardrails enforces operator-defined content policies on user
+// messages and model replies. A Policy declares forbidden topics, regular
+// expressions and semantic categories together with refusal templates; every
+// enforcement decision is written to an audit log.
+//
+//	policy, err := guardrails.LoadPolicy("guardrails.yaml")
+//	g, err := guardrails.New(policy,
+//		guardrails.WithClassifier(guardrails.NewEmbeddingClassifier(provider)),
+//		guardrails.WithAuditLogger(guardrails.NewJSONAuditLogger(auditFile)))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithGuardrails(g))
+package guardrails
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Decision is the outcome of a check.
+type Decision struct {
+	Stage Stage `json:"stage"`
+	// Allowed is false if the text was refused.
+	Allowed bool `json:"allowed"`
+	// Text is the checked text, with redactions applied.
+	Text string `json:"-"`
+	// Refusal is the message to show instead, when refused.
+	Refusal string `json:"refusal,omitempty"`
+	// Matches lists every rule that fired, in rule order.
+	Matches []Match `json:"matches,omitempty"`
+}
+
+// Match is a rule that fired.
+type Match struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	// Kind is "topic", "pattern", "category" or "tool".
+	Kind string `json:"kind"`
+	// Match is the matched text, or the category name.
+	Match string `json:"match"`
+	// Score is the classifier score of category matches.
+	Score float64 `json:"score,omitempty"`
+}
+
+// refusalData is passed to refusal templates.
+type refusalData struct {
+	Rule  string
+	Stage Stage
+	Kind  string
+	Match string
+}
+
+// Option configures Guardrails.
+type Option func(*Guardrails)
+
+// WithClassifier sets the classifier for semantic categories. It is required
+// if any rule uses categories.
+func WithClassifier(classifier Classifier) Option {
+	return func(g *Guardrails) {
+		g.classifier = classifier
+	}
+}
+
+// WithAuditLogger sets where enforcement decisions are recorded. By default
+// they are written with the chatbot's logger (see gochatbot.WithLogger).
+func WithAuditLogger(logger AuditLogger) Option {
+	return func(g *Guardrails) {
+		g.audit = logger
+	}
+}
+
+// Guardrails checks text against a policy.
+type Guardrails struct {
+	rules      []*rule
+	categories map[string]Category
+	redaction  string
+	classifier Classifier
+	audit      AuditLogger
+}
+
+// rule is a compiled Rule.
+type rule struct {
+	Rule
+	topics   *regexp.Regexp
+	patterns []*regexp.Regexp
+	refusal  *template.Template
+	stages   map[Stage]bool
+}
+
+// New compiles a policy.
+func New(policy *Policy, opts ...Option) (*Guardrails, error) {
+	g := &Guardrails{
+		categories: make(map[string]Category, len(policy.Categories)),
+		redaction:  policy.Redaction,
+		audit:      logAuditLogger{},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.redaction == "" {
+		g.redaction = "[REDACTED]"
+	}
+	for name, category := range policy.Categories {
+		category.Name = name
+		if category.Threshold <= 0 {
+			category.Threshold = 0.8
+		}
+		g.categories[name] = category
+	}
+
+	defaultRefusal := policy.Refusal
+	if defaultRefusal == "" {
+		defaultRefusal = DefaultRefusal
+	}
+
+	for i, r := range policy.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule_%d", i+1)
+		}
+		compiled, err := g.compile(r, defaultRefusal)
+		if err != nil {
+			return nil, fmt.Errorf("guardrails rule %q: %w", r.Name, err)
+		}
+		g.rules = append(g.rules, compiled)
+	}
+	return g, nil
+}
+
+func (g *Guardrails) compile(r Rule, defaultRefusal string) (*rule, error) {
+	if r.Action == "" {
+		r.Action = ActionRefuse
+	}
+	switch r.Action {
+	case ActionRefuse, ActionRedact, ActionFlag:
+	default:
+		return nil, fmt.Errorf("unknown action %q", r.Action)
+	}
+	if len(r.Topics) == 0 && len(r.Patterns) == 0 && len(r.Categories) == 0 {
+		return nil, fmt.Errorf("no topics, patterns or categories")
+	}
+
+	compiled := &rule{Rule: r, stages: map[Stage]bool{StageInput: true, StageOutput: true}}
+	if len(r.ApplyTo) > 0 {
+		compiled.stages = make(map[Stage]bool)
+		for _, stage := range r.ApplyTo {
+			if stage != StageInput && stage != StageOutput {
+				return nil, fmt.Errorf("unknown stage %q", stage)
+			}
+			compiled.stages[stage] = true
+		}
+	}
+
+	if len(r.Topics) > 0 {
+		phrases := make([]string, 0, len(r.Topics))
+		for _, topic := range r.Topics {
+			topic = strings.TrimSpace(topic)
+			words := strings.Fields(topic)
+			for i, word := range words {
+				words[i] = regexp.QuoteMeta(word)
+			}
+			if len(words) == 0 {
+				continue
+			}
+			// Only anchor at word characters, so topics like "C++" match
+			phrase := strings.Join(words, `\s+`)
+			if isWordChar(topic[0]) {
+				phrase = `\b` + phrase
+			}
+			if isWordChar(topic[len(topic)-1]) {
+				phrase += `\b`
+			}
+			phrases = append(phrases, phrase)
+		}
+		if len(phrases) > 0 {
+			compiled.topics = regexp.MustCompile(`(?i)(?:` + strings.Join(phrases, "|") + `)`)
+		}
+	}
+	for _, pattern := range r.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		compiled.patterns = append(compiled.patterns, re)
+	}
+	for _, name := range r.Categories {
+		if _, ok := g.categories[name]; !ok {
+			return nil, fmt.Errorf("unknown category %q", name)
+		}
+		if g.classifier == nil {
+			return nil, fmt.Errorf("category %q needs a classifier", name)
+		}
+	}
+	if len(r.Categories) > 0 && r.Action == ActionRedact {
+		return nil, fmt.Errorf("category matches cannot be redacted")
+	}
+
+	refusal := r.Refusal
+	if refusal == "" {
+		refusal = defaultRefusal
+	}
+	tmpl, err := template.New(r.Name).Option("missingkey=error").Parse(refusal)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refusal template: %w", err)
+	}
+	compiled.refusal = tmpl
+	return compiled, nil
+}
+
+// HasOutputRules reports whether any rule checks model replies.
+func (g *Guardrails) HasOutputRules() bool {
+	for _, r := range g.rules {
+		if r.stages[StageOutput] {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckInput checks a user message. meta is the request context; its
+// "conversation_id" and "user_id" are recorded in the audit log.
+func (g *Guardrails) CheckInput(ctx context.Context, text string, meta map[string]interface{}) (*Decision, error) {
+	return g.Check(ctx, StageInput, text, meta)
+}
+
+// CheckOutput checks a model reply.
+func (g *Guardrails) CheckOutput(ctx context.Context, text string, meta map[string]interface{}) (*Decision, error) {
+	return g.Check(ctx, StageOutput, text, meta)
+}
+
+// Check runs the rules for stage. Rules are evaluated in order: redactions
+// accumulate, and the first refusing rule stops the check. Each match is
+// written to the audit log.
+func (g *Guardrails) Check(ctx context.Context, stage Stage, text string, meta map[string]interface{}) (*Decision, error) {
+	decision := &Decision{Stage: stage, Allowed: true, Text: text}
+	var scores map[string]float64
+
+	for _, r := range g.rules {
+		if !r.stages[stage] {
+			continue
+		}
+
+		var matches []Match
+		for _, match := range r.find(decision.Text) {
+			matches = append(matches, Match{Rule: r.Name, Action: r.Action, Kind: match[0], Match: match[1]})
+		}
+		if len(r.Categories) > 0 {
+			if scores == nil {
+				var err error
+				scores, err = g.classify(ctx, decision.Text)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for _, name := range r.Categories {
+				if score := scores[name]; score >= g.categories[name].Threshold {
+					matches = append(matches, Match{Rule: r.Name, Action: r.Action, Kind: "category", Match: name, Score: score})
+				}
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		decision.Matches = append(decision.Matches, matches...)
+		switch r.Action {
+		case ActionRedact:
+			decision.Text = r.redact(decision.Text, g.redaction)
+		case ActionRefuse:
+			decision.Allowed = false
+			var refusal bytes.Buffer
+			data := refusalData{Rule: r.Name, Stage: stage, Kind: matches[0].Kind, Match: matches[0].Match}
+			if err := r.refusal.Execute(&refusal, data); err != nil {
+				return nil, fmt.Errorf("failed to render refusal for rule %q: %w", r.Name, err)
+			}
+			decision.Refusal = refusal.String()
+		}
+		g.record(ctx, decision, matches, meta)
+		if !decision.Allowed {
+			break
+		}
+	}
+	return decision, nil
+}
+
+// find returns the [kind, text] of every topic and pattern match.
+func (r *rule) find(text string) [][2]string {
+	var found [][2]string
+	if r.topics != nil {
+		for _, match := range r.topics.FindAllString(text, -1) {
+			found = append(found, [2]string{"topic", match})
+		}
+	}
+	for _, re := range r.patterns {
+		for _, match := range re.FindAllString(text, -1) {
+			found = append(found, [2]string{"pattern", match})
+		}
+	}
+	return found
+}
+
+func (r *rule) redact(text, redaction string) string {
+	if r.topics != nil {
+		text = r.topics.ReplaceAllLiteralString(text, redaction)
+	}
+	for _, re := range r.patterns {
+		text = re.ReplaceAllLiteralString(text, redaction)
+	}
+	return text
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// classify scores text against every category rules refer to.
+func (g *Guardrails) classify(ctx context.Context, text string) (map[string]float64, error) {
+	categories := make([]Category, 0, len(g.categories))
+	for _, category := range g.categories {
+		categories = append(categories, category)
+	}
+	scores, err := g.classifier.Classify(ctx, text, categories)
+	if err != nil {
+		return nil, fmt.Errorf("guardrails classification failed: %w", err)
+	}
+	return scores, nil
+}
+
+// record writes one audit entry for a rule's matches.
+func (g *Guardrails) record(ctx context.Context, decision *Decision, matches []Match, meta map[string]interface{}) {
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		Stage:   decision.Stage,
+		Rule:    matches[0].Rule,
+		Action:  matches[0].Action,
+		Allowed: decision.Allowed,
+		Matches: matches,
+	}
+	entry.ConversationID, _ = meta["conversation_id"].(string)
+	entry.UserID, _ = meta["user_id"].(string)
+	g.audit.Log(ctx, entry)
+}
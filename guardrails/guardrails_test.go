@@ -0,0 +1,329 @@
+package guardrails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// fakeClassifier returns fixed scores.
+type fakeClassifier struct {
+	scores map[string]float64
+	calls  int
+}
+
+func (c *fakeClassifier) Classify(ctx context.Context, text string, categories []Category) (map[string]float64, error) {
+	c.calls++
+	return c.scores, nil
+}
+
+// recorder collects audit entries.
+type recorder struct {
+	entries []AuditEntry
+}
+
+func (r *recorder) Log(ctx context.Context, entry AuditEntry) {
+	r.entries = append(r.entries, entry)
+}
+
+func TestTopics(t *testing.T) {
+	audit := &recorder{}
+	g, err := New(&Policy{Rules: []Rule{
+		{Name: "languages", Topics: []string{"C++", "visual  basic"}, Refusal: "No {{.Match}} talk on {{.Stage}}."},
+	}}, WithAuditLogger(audit))
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+
+	tests := []struct {
+		text    string
+		allowed bool
+		refusal string
+	}{
+		{"How do I learn c++?", false, "No c++ talk on input."},
+		{"Is Visual\nBasic dead?", false, "No Visual\nBasic talk on input."},
+		{"Tell me about basics", true, ""},
+		{"I like visualbasic", true, ""},
+	}
+	for _, test := range tests {
+		decision, err := g.CheckInput(context.Background(), test.text, map[string]interface{}{"conversation_id": "conv-1"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decision.Allowed != test.allowed || decision.Refusal != test.refusal {
+			t.Errorf("%q: got allowed=%v refusal=%q", test.text, decision.Allowed, decision.Refusal)
+		}
+	}
+
+	if len(audit.entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(audit.entries))
+	}
+	entry := audit.entries[0]
+	if entry.Rule != "languages" || entry.Action != ActionRefuse || entry.Allowed || entry.ConversationID != "conv-1" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestRedactAndFlag(t *testing.T) {
+	audit := &recorder{}
+	g, err := New(&Policy{Redaction: "***", Rules: []Rule{
+		{Name: "emails", Patterns: []string{`[\w.]+@[\w.]+`}, Action: ActionRedact},
+		{Name: "pricing", Topics: []string{"discount"}, Action: ActionFlag},
+	}}, WithAuditLogger(audit))
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+
+	decision, err := g.CheckOutput(context.Background(), "Mail jane@example.com for a discount", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decision.Allowed || decision.Text != "Mail *** for a discount" {
+		t.Errorf("unexpected decision: %+v", decision)
+	}
+	if len(decision.Matches) != 2 || decision.Matches[0].Match != "jane@example.com" || decision.Matches[1].Action != ActionFlag {
+		t.Errorf("unexpected matches: %+v", decision.Matches)
+	}
+	if len(audit.entries) != 2 || !audit.entries[0].Allowed {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+}
+
+func TestApplyTo(t *testing.T) {
+	g, err := New(&Policy{Rules: []Rule{
+		{Topics: []string{"password"}, ApplyTo: []Stage{StageOutput}},
+	}}, WithAuditLogger(&recorder{}))
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+	if !g.HasOutputRules() {
+		t.Error("expected output rules")
+	}
+
+	input, _ := g.CheckInput(context.Background(), "I forgot my password", nil)
+	output, _ := g.CheckOutput(context.Background(), "Your password is hunter2", nil)
+	if !input.Allowed || output.Allowed || output.Refusal != DefaultRefusal {
+		t.Errorf("unexpected decisions: input %+v, output %+v", input, output)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	classifier := &fakeClassifier{scores: map[string]float64{"medical": 0.9, "legal": 0.5}}
+	g, err := New(&Policy{
+		Categories: map[string]Category{
+			"medical": {Description: "Medical advice"},
+			"legal":   {Description: "Legal advice", Threshold: 0.4},
+		},
+		Rules: []Rule{
+			{Name: "legal", Categories: []string{"legal"}, Action: ActionFlag},
+			{Name: "medical", Categories: []string{"medical"}, Refusal: "Not {{.Match}} ({{.Kind}})."},
+		},
+	}, WithClassifier(classifier), WithAuditLogger(&recorder{}))
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+
+	decision, err := g.CheckInput(context.Background(), "Can I sue my doctor?", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Allowed || decision.Refusal != "Not medical (category)." {
+		t.Errorf("unexpected decision: %+v", decision)
+	}
+	if len(decision.Matches) != 2 || decision.Matches[0].Score != 0.5 {
+		t.Errorf("unexpected matches: %+v", decision.Matches)
+	}
+	if classifier.calls != 1 {
+		t.Errorf("expected one classification, got %d", classifier.calls)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := map[string]*Policy{
+		"empty rule":       {Rules: []Rule{{Name: "empty"}}},
+		"unknown action":   {Rules: []Rule{{Topics: []string{"x"}, Action: "delete"}}},
+		"unknown stage":    {Rules: []Rule{{Topics: []string{"x"}, ApplyTo: []Stage{"both"}}}},
+		"bad pattern":      {Rules: []Rule{{Patterns: []string{"("}}}},
+		"bad template":     {Rules: []Rule{{Topics: []string{"x"}, Refusal: "{{.Match"}}},
+		"unknown category": {Rules: []Rule{{Categories: []string{"missing"}}}},
+		"no classifier": {
+			Categories: map[string]Category{"medical": {}},
+			Rules:      []Rule{{Categories: []string{"medical"}}},
+		},
+	}
+	for name, policy := range tests {
+		if _, err := New(policy); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+refusal: "Nope."
+categories:
+  medical:
+    description: Medical advice
+    threshold: 0.7
+rules:
+  - name: cards
+    patterns: ['\d{16}']
+    action: redact
+    apply_to: [output]
+`))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	if policy.Refusal != "Nope." || policy.Categories["medical"].Threshold != 0.7 {
+		t.Errorf("unexpected policy: %+v", policy)
+	}
+	if len(policy.Rules) != 1 || policy.Rules[0].Action != ActionRedact || policy.Rules[0].ApplyTo[0] != StageOutput {
+		t.Errorf("unexpected rules: %+v", policy.Rules)
+	}
+}
+
+func TestJSONAuditLogger(t *testing.T) {
+	var buf bytes.Buffer
+	g, err := New(&Policy{Rules: []Rule{{Name: "banned", Topics: []string{"foo"}}}}, WithAuditLogger(NewJSONAuditLogger(&buf)))
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+	if _, err := g.CheckInput(context.Background(), "foo bar", map[string]interface{}{"user_id": "u1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var entry AuditEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid audit JSON %q: %v", buf.String(), err)
+	}
+	if entry.Rule != "banned" || entry.UserID != "u1" || entry.Stage != StageInput || len(entry.Matches) != 1 {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestToolDenials(t *testing.T) {
+	audit := &recorder{}
+	registry := tools.NewRegistry(tools.New("refund", "Refunds an order", nil,
+		func(ctx context.Context, args json.RawMessage) (string, error) { return "refunded", nil }))
+	registry.SetAuthorizer(tools.Permissions{Roles: map[string][]string{"staff": {"*"}}}, ToolDenials(audit))
+
+	refund, _ := registry.Get("refund")
+	ctx := tools.WithCaller(context.Background(), tools.Caller{Role: "customer", UserID: "u1", ConversationID: "c1"})
+	if _, err := refund.Call(ctx, nil); !errors.Is(err, tools.ErrPermissionDenied) {
+		t.Fatalf("expected the call to be denied, got %v", err)
+	}
+
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected one audit entry, got %+v", audit.entries)
+	}
+	entry := audit.entries[0]
+	if entry.Stage != StageTool || entry.Rule != ToolPermissionsRule || entry.Allowed || entry.UserID != "u1" ||
+		entry.ConversationID != "c1" || len(entry.Matches) != 1 || entry.Matches[0].Match != "refund" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestFileAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewFileAuditLogger(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer logger.Close()
+	ctx := context.Background()
+
+	logger.Log(ctx, AuditEntry{Rule: "a", UserID: "u1"})
+	logger.Log(ctx, AuditEntry{Rule: "b", UserID: "u2"})
+	logger.Log(ctx, AuditEntry{Rule: "c", UserID: "u1"})
+
+	entries, err := logger.UserEntries(ctx, "u1")
+	if err != nil || len(entries) != 2 || entries[1].Rule != "c" {
+		t.Fatalf("unexpected entries %+v, %v", entries, err)
+	}
+
+	removed, err := logger.DeleteUserEntries(ctx, "u1")
+	if err != nil || removed != 2 {
+		t.Fatalf("expected 2 entries removed, got %d, %v", removed, err)
+	}
+	// Logging continues in the rewritten file
+	logger.Log(ctx, AuditEntry{Rule: "d", UserID: "u2"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || strings.Contains(string(data), "u1") {
+		t.Errorf("unexpected audit log %q", data)
+	}
+}
+
+// fakeProvider embeds texts by counting keywords.
+type fakeProvider struct{}
+
+func (fakeProvider) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = fakeProvider{}.EmbedSingle(ctx, text)
+	}
+	return vectors, nil
+}
+
+func (fakeProvider) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	text = strings.ToLower(text)
+	return embeddings.Vector{
+		float64(strings.Count(text, "doctor")),
+		float64(strings.Count(text, "lawyer")),
+		0.1,
+	}, nil
+}
+
+func (fakeProvider) Dimensions() int  { return 3 }
+func (fakeProvider) Model() string    { return "fake" }
+func (fakeProvider) Provider() string { return "test" }
+
+func TestEmbeddingClassifier(t *testing.T) {
+	classifier := NewEmbeddingClassifier(fakeProvider{})
+	scores, err := classifier.Classify(context.Background(), "Should I see a doctor?", []Category{
+		{Name: "medical", Examples: []string{"Ask your doctor"}},
+		{Name: "legal", Description: "Talk to a lawyer"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scores["medical"] < 0.9 || scores["legal"] > 0.2 {
+		t.Errorf("unexpected scores: %v", scores)
+	}
+
+	if _, err := classifier.Classify(context.Background(), "hi", []Category{{Name: "empty"}}); err == nil {
+		t.Error("expected an error for a category without description or examples")
+	}
+}
+
+func TestModelClassifier(t *testing.T) {
+	model := chatbottest.NewMockModel(`Scores: {"medical": 1.4, "legal": 0.2}`)
+	scores, err := NewModelClassifier(model).Classify(context.Background(), "Which pills?", []Category{
+		{Name: "medical", Description: "Medical advice", Examples: []string{"What dose?"}},
+		{Name: "legal", Description: "Legal advice"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scores["medical"] != 1 || scores["legal"] != 0.2 {
+		t.Errorf("unexpected scores: %v", scores)
+	}
+
+	call, _ := model.LastCall()
+	prompt, _ := call.Context["prompt"].(string)
+	if !strings.Contains(prompt, "- medical: Medical advice") || !strings.Contains(prompt, `example: "What dose?"`) {
+		t.Errorf("unexpected prompt: %q", prompt)
+	}
+}
@@ -0,0 +1,127 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stage is where a check runs.
+type Stage string
+
+// Check stages.
+const (
+	StageInput  Stage = "input"
+	StageOutput Stage = "output"
+	// StageTool records tool calls denied by a tools.Authorizer.
+	StageTool Stage = "tool"
+)
+
+// Action is what happens when a rule matches.
+type Action string
+
+// Rule actions.
+const (
+	// ActionRefuse blocks the message and answers with the refusal.
+	ActionRefuse Action = "refuse"
+	// ActionRedact replaces matched text with the redaction and continues.
+	ActionRedact Action = "redact"
+	// ActionFlag only records the match in the audit log.
+	ActionFlag Action = "flag"
+)
+
+// Policy declares the guardrails. It is usually loaded from YAML:
+//
+//	refusal: "Sorry, I can't help with that."
+//	categories:
+//	  medical:
+//	    description: Requests for diagnosis, treatment or medication advice
+//	    examples: ["What dose of ibuprofen should I take?"]
+//	rules:
+//	  - name: competitors
+//	    topics: ["Acme Corp", "Globex"]
+//	    refusal: "I can only help with questions about our own products."
+//	  - name: card_numbers
+//	    patterns: ['\b(?:\d[ -]?){13,16}\b']
+//	    action: redact
+//	  - name: medical_advice
+//	    categories: [medical]
+//	    apply_to: [input]
+//	    refusal: "I can't give medical advice. Please talk to a doctor."
+type Policy struct {
+	// Refusal is the default refusal template for rules without their own.
+	Refusal string `json:"refusal" yaml:"refusal"`
+	// Redaction replaces redacted text (default "[REDACTED]").
+	Redaction string `json:"redaction" yaml:"redaction"`
+	// Categories defines the semantic categories rules can refer to.
+	Categories map[string]Category `json:"categories" yaml:"categories"`
+	// Rules are checked in order.
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Category is a semantic category recognized by a Classifier.
+type Category struct {
+	Name        string   `json:"-" yaml:"-"`
+	Description string   `json:"description" yaml:"description"`
+	Examples    []string `json:"examples" yaml:"examples"`
+	// Threshold is the minimum score for a match (default 0.8).
+	Threshold float64 `json:"threshold" yaml:"threshold"`
+}
+
+// Rule matches forbidden content by topic phrases, regular expressions or
+// semantic categories; any of them triggers the rule.
+type Rule struct {
+	Name string `json:"name" yaml:"name"`
+	// Topics are phrases matched as whole words, ignoring case.
+	Topics []string `json:"topics" yaml:"topics"`
+	// Patterns are regular expressions (RE2 syntax).
+	Patterns []string `json:"patterns" yaml:"patterns"`
+	// Categories are names from Policy.Categories.
+	Categories []string `json:"categories" yaml:"categories"`
+	// ApplyTo limits the rule to input or output (default both).
+	ApplyTo []Stage `json:"apply_to" yaml:"apply_to"`
+	// Action defaults to refuse.
+	Action Action `json:"action" yaml:"action"`
+	// Refusal is a text/template rendered with the Decision, for example
+	// "I can't discuss {{.Match}}."
+	Refusal string `json:"refusal" yaml:"refusal"`
+}
+
+// DefaultRefusal is used when neither the rule nor the policy has a refusal.
+const DefaultRefusal = "Sorry, I can't help with that request."
+
+// ParsePolicy parses a YAML or JSON policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	// YAML is a superset of JSON
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse guardrails policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// LoadPolicy reads a policy file. The format is chosen from the extension
+// (.json, .yaml or .yml).
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guardrails policy: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var policy Policy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return nil, fmt.Errorf("failed to parse guardrails policy: %w", err)
+		}
+		return &policy, nil
+	case ".yaml", ".yml":
+		return ParsePolicy(data)
+	default:
+		return nil, fmt.Errorf("unsupported guardrails policy extension: %q", filepath.Ext(path))
+	}
+}
@@ -0,0 +1,84 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/guardrails"
+)
+
+// echoModel replies with the message it was sent.
+type echoModel struct {
+	messages []string
+}
+
+func (m *echoModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.messages = append(m.messages, message)
+	return "You said: " + message, nil
+}
+
+func (m *echoModel) Name() string     { return "echo" }
+func (m *echoModel) Provider() string { return "test" }
+
+func TestGuardrails(t *testing.T) {
+	g, err := guardrails.New(&guardrails.Policy{Rules: []guardrails.Rule{
+		{Name: "competitors", Topics: []string{"Globex"}, ApplyTo: []guardrails.Stage{guardrails.StageInput}, Refusal: "I can't discuss {{.Match}}."},
+		{Name: "secrets", Patterns: []string{`sk-[a-z0-9]+`}, Action: guardrails.ActionRedact},
+	}}, guardrails.WithAuditLogger(guardrails.AuditFunc(func(context.Context, guardrails.AuditEntry) {})))
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &echoModel{}
+	bot, err := New(cfg, WithModel(model), WithGuardrails(g))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	reply, err := bot.Ask(context.Background(), "Is globex any good?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "I can't discuss globex." || len(model.messages) != 0 {
+		t.Errorf("expected a refusal without a model call, got %q after %d call(s)", reply, len(model.messages))
+	}
+
+	reply, err = bot.Ask(context.Background(), "My key is sk-abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.messages[0] != "My key is [REDACTED]" {
+		t.Errorf("expected the message to be redacted, got %q", model.messages[0])
+	}
+	if reply != "You said: My key is [REDACTED]" {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+}
+
+func TestGuardrails_LogsWithLogger(t *testing.T) {
+	g, err := guardrails.New(&guardrails.Policy{Rules: []guardrails.Rule{
+		{Name: "competitors", Topics: []string{"Globex"}, ApplyTo: []guardrails.Stage{guardrails.StageInput}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	logger := &recordingLogger{}
+	bot, err := New(cfg, WithModel(&echoModel{}), WithGuardrails(g), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "Is globex any good?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], `rule "competitors"`) {
+		t.Errorf("expected the audit entry to be logged with the chatbot's logger, got %q", logger.messages)
+	}
+}
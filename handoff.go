@@ -0,0 +1,34 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNeedsHuman is returned by Ask and AskStream when a human operator
+// handles the conversation instead of the chatbot.
+var ErrNeedsHuman = errors.New("conversation is handled by a human")
+
+// Handoff decides whether a human should answer a message instead of the
+// chatbot. handoff.Manager implements it.
+type Handoff interface {
+	// Intercept is called with the filtered message and request context,
+	// which carries "conversation_id" and "user_id" when the caller set them.
+	Intercept(ctx context.Context, message string, askContext map[string]interface{}) (bool, error)
+}
+
+// WithHandoff pauses bot replies for conversations handed to a human. Ask and
+// AskStream return ErrNeedsHuman for those conversations.
+func WithHandoff(handoff Handoff) Option {
+	return func(c *Chatbot) {
+		c.handoff = handoff
+	}
+}
+
+// intercepted reports whether the message goes to a human instead.
+func (c *Chatbot) intercepted(ctx context.Context, message string, askContext map[string]interface{}) (bool, error) {
+	if c.handoff == nil {
+		return false, nil
+	}
+	return c.handoff.Intercept(ctx, message, askContext)
+}
@@ -0,0 +1,272 @@
+// Package handoff hands conversations from the chatbot to human operators.
+//
+// A Manager checks each user message against configurable triggers (the user
+// asking for a human, negative sentiment, de-escalation failing). When one
+// fires, the conversation is marked as needing a human, operators are
+// notified, and the chatbot stops replying until an operator resolves it.
+// Operators reply through the conversation store, directly or via
+// Manager.Handler.
+//
+//	manager := handoff.NewManager(store,
+//		handoff.WithNotifier(&handoff.SlackNotifier{WebhookURL: slackURL}))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithHandoff(manager))
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Conversation metadata keys used to record a handoff.
+const (
+	MetadataStatus = "handoff_status"
+	MetadataReason = "handoff_reason"
+	MetadataSince  = "handoff_at"
+)
+
+// StatusNeedsHuman is the MetadataStatus value of handed off conversations.
+const StatusNeedsHuman = "needs_human"
+
+// Status describes a conversation's handoff state.
+type Status struct {
+	ConversationID string     `json:"conversation_id"`
+	NeedsHuman     bool       `json:"needs_human"`
+	Reason         string     `json:"reason,omitempty"`
+	Since          *time.Time `json:"since,omitempty"`
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithTriggers replaces the default triggers.
+func WithTriggers(triggers ...Trigger) Option {
+	return func(m *Manager) {
+		m.triggers = triggers
+	}
+}
+
+// WithNotifier adds a notifier called when a conversation is handed off.
+func WithNotifier(notifier Notifier) Option {
+	return func(m *Manager) {
+		m.notifiers = append(m.notifiers, notifier)
+	}
+}
+
+// WithErrorHandler sets a function called when a notification fails. By
+// default failures are logged; they never block the handoff.
+func WithErrorHandler(fn func(n Notification, err error)) Option {
+	return func(m *Manager) {
+		m.onError = fn
+	}
+}
+
+// Manager tracks which conversations are handled by a human.
+type Manager struct {
+	store     database.ConversationStore
+	triggers  []Trigger
+	notifiers []Notifier
+	onError   func(n Notification, err error)
+	now       func() time.Time
+}
+
+// NewManager creates a Manager that records handoffs in store. By default it
+// hands off when the user asks for a human, when the sentiment score drops
+// to -0.6 or below, and after three consecutive aggressive messages.
+func NewManager(store database.ConversationStore, opts ...Option) *Manager {
+	m := &Manager{
+		store: store,
+		triggers: []Trigger{
+			UserRequest(),
+			NegativeSentiment(-0.6),
+			DeescalationFailure(3),
+		},
+		onError: func(n Notification, err error) {
+			log.Printf("handoff: failed to notify about conversation %s: %v", n.ConversationID, err)
+		},
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Intercept checks a user message before the chatbot answers it and reports
+// whether a human handles the conversation instead. Messages sent while a
+// conversation is handed off are stored for the operator. Messages without
+// a "conversation_id" in the context are never intercepted.
+func (m *Manager) Intercept(ctx context.Context, message string, askContext map[string]interface{}) (bool, error) {
+	signal := &Signal{Message: message, Context: askContext}
+	signal.ConversationID, _ = askContext["conversation_id"].(string)
+	signal.UserID, _ = askContext["user_id"].(string)
+	if signal.ConversationID == "" {
+		return false, nil
+	}
+
+	status, err := m.Status(ctx, signal.ConversationID)
+	if err != nil {
+		return false, err
+	}
+	if status.NeedsHuman {
+		return true, m.addUserMessage(ctx, signal.ConversationID, message, askContext)
+	}
+
+	for _, trigger := range m.triggers {
+		if reason, ok := trigger.Check(ctx, signal); ok {
+			return true, m.escalate(ctx, signal, reason)
+		}
+	}
+	return false, nil
+}
+
+// Escalate hands a conversation to a human, e.g. from an operator console.
+func (m *Manager) Escalate(ctx context.Context, conversationID, reason string) error {
+	return m.escalate(ctx, &Signal{ConversationID: conversationID}, reason)
+}
+
+// escalate marks the conversation, stores the triggering message and
+// notifies operators.
+func (m *Manager) escalate(ctx context.Context, signal *Signal, reason string) error {
+	conv, err := m.conversation(ctx, signal.ConversationID, signal.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	if conv.Metadata == nil {
+		conv.Metadata = make(map[string]interface{})
+	}
+	conv.Metadata[MetadataStatus] = StatusNeedsHuman
+	conv.Metadata[MetadataReason] = reason
+	conv.Metadata[MetadataSince] = now.UTC().Format(time.RFC3339)
+	if err := m.store.UpdateConversation(ctx, conv); err != nil {
+		return fmt.Errorf("failed to mark conversation for handoff: %w", err)
+	}
+
+	if signal.Message != "" {
+		if err := m.addUserMessage(ctx, conv.ID, signal.Message, signal.Context); err != nil {
+			return err
+		}
+	}
+
+	n := Notification{
+		ConversationID: conv.ID,
+		UserID:         conv.UserID,
+		Reason:         reason,
+		Message:        signal.Message,
+		Time:           now,
+	}
+	for _, notifier := range m.notifiers {
+		if err := notifier.Notify(ctx, n); err != nil && m.onError != nil {
+			m.onError(n, err)
+		}
+	}
+	return nil
+}
+
+// Status returns the handoff state of a conversation. Conversations that do
+// not exist yet are handled by the chatbot.
+func (m *Manager) Status(ctx context.Context, conversationID string) (*Status, error) {
+	status := &Status{ConversationID: conversationID}
+
+	conv, err := m.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return status, nil
+	}
+
+	if value, _ := conv.Metadata[MetadataStatus].(string); value != StatusNeedsHuman {
+		return status, nil
+	}
+	status.NeedsHuman = true
+	status.Reason, _ = conv.Metadata[MetadataReason].(string)
+	if since, _ := conv.Metadata[MetadataSince].(string); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			status.Since = &t
+		}
+	}
+	return status, nil
+}
+
+// Post stores an operator's reply in the conversation. The message has the
+// "assistant" role so it reads naturally in the history once the chatbot
+// takes over again, with the operator recorded in its metadata.
+func (m *Manager) Post(ctx context.Context, conversationID, operator, content string) (*database.Message, error) {
+	if content == "" {
+		return nil, fmt.Errorf("message content cannot be empty")
+	}
+	if _, err := m.store.GetConversation(ctx, conversationID); err != nil {
+		return nil, err
+	}
+
+	msg := &database.Message{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		Role:           "assistant",
+		Content:        content,
+		Metadata:       map[string]interface{}{"operator": operator},
+	}
+	if err := m.store.AddMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Resolve hands a conversation back to the chatbot.
+func (m *Manager) Resolve(ctx context.Context, conversationID string) error {
+	conv, err := m.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	delete(conv.Metadata, MetadataStatus)
+	delete(conv.Metadata, MetadataReason)
+	delete(conv.Metadata, MetadataSince)
+	if err := m.store.UpdateConversation(ctx, conv); err != nil {
+		return fmt.Errorf("failed to resolve handoff: %w", err)
+	}
+	return nil
+}
+
+// conversation loads a conversation, creating it if the chatbot has not
+// stored it yet.
+func (m *Manager) conversation(ctx context.Context, id, userID string) (*database.Conversation, error) {
+	conv, err := m.store.GetConversation(ctx, id)
+	if err == nil {
+		return conv, nil
+	}
+
+	conv = &database.Conversation{ID: id, UserID: userID, Title: "Handoff", Metadata: map[string]interface{}{}}
+	if createErr := m.store.CreateConversation(ctx, conv); createErr != nil {
+		return nil, fmt.Errorf("failed to load conversation: %w", err)
+	}
+	return conv, nil
+}
+
+// addUserMessage stores a user message so the operator sees it, along with
+// its sentiment if it was analyzed.
+func (m *Manager) addUserMessage(ctx context.Context, conversationID, content string, askContext map[string]interface{}) error {
+	metadata := map[string]interface{}{"handoff": true}
+	for _, key := range []string{"sentiment", "sentiment_score", "emotions"} {
+		if value, ok := askContext[key]; ok {
+			metadata[key] = value
+		}
+	}
+
+	err := m.store.AddMessage(ctx, &database.Message{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		Role:           "user",
+		Content:        content,
+		Metadata:       metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store message for operator: %w", err)
+	}
+	return nil
+}
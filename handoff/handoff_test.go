@@ -0,0 +1,221 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/events"
+)
+
+func askContext(conversationID string) map[string]interface{} {
+	return map[string]interface{}{"conversation_id": conversationID, "user_id": "u1"}
+}
+
+func TestInterceptHandsOffAndPauses(t *testing.T) {
+	store := chatbottest.NewStore()
+	var notified []Notification
+	manager := NewManager(store, WithNotifier(NotifierFunc(func(ctx context.Context, n Notification) error {
+		notified = append(notified, n)
+		return nil
+	})))
+	ctx := context.Background()
+
+	if paused, err := manager.Intercept(ctx, "Where is my order?", askContext("c1")); err != nil || paused {
+		t.Fatalf("expected bot to answer, got %v, %v", paused, err)
+	}
+
+	if paused, err := manager.Intercept(ctx, "I want to talk to a human", askContext("c1")); err != nil || !paused {
+		t.Fatalf("expected handoff, got %v, %v", paused, err)
+	}
+	if len(notified) != 1 || notified[0].Reason != "user_request" || notified[0].UserID != "u1" {
+		t.Errorf("unexpected notifications: %+v", notified)
+	}
+
+	status, _ := manager.Status(ctx, "c1")
+	if !status.NeedsHuman || status.Reason != "user_request" || status.Since == nil {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// Further messages wait for the operator
+	if paused, _ := manager.Intercept(ctx, "Hello?", askContext("c1")); !paused {
+		t.Error("expected conversation to stay paused")
+	}
+	if len(notified) != 1 {
+		t.Error("expected a single notification")
+	}
+
+	if _, err := manager.Post(ctx, "c1", "alice", "Hi, I'm Alice. Let me check."); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	messages := store.Messages()
+	if len(messages) != 3 || messages[1].Content != "Hello?" || messages[2].Metadata["operator"] != "alice" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+
+	if err := manager.Resolve(ctx, "c1"); err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if paused, _ := manager.Intercept(ctx, "Thanks!", askContext("c1")); paused {
+		t.Error("expected bot to answer after resolving")
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("negative sentiment", func(t *testing.T) {
+		trigger := NegativeSentiment(-0.5)
+		if _, ok := trigger.Check(ctx, &Signal{Context: map[string]interface{}{"sentiment_score": -0.8}}); !ok {
+			t.Error("expected trigger to fire")
+		}
+		if _, ok := trigger.Check(ctx, &Signal{Context: map[string]interface{}{"sentiment_score": 0.2}}); ok {
+			t.Error("expected trigger not to fire")
+		}
+		if _, ok := trigger.Check(ctx, &Signal{}); ok {
+			t.Error("expected trigger not to fire without a score")
+		}
+	})
+
+	t.Run("deescalation failure", func(t *testing.T) {
+		trigger := DeescalationFailure(2)
+		aggressive := &Signal{ConversationID: "c1", Context: map[string]interface{}{"aggression_detected": true}}
+		calm := &Signal{ConversationID: "c1", Context: map[string]interface{}{}}
+
+		if _, ok := trigger.Check(ctx, aggressive); ok {
+			t.Error("expected first aggressive message to be de-escalated")
+		}
+		trigger.Check(ctx, calm)
+		if _, ok := trigger.Check(ctx, aggressive); ok {
+			t.Error("expected calm message to reset the streak")
+		}
+		if reason, ok := trigger.Check(ctx, aggressive); !ok || reason != "deescalation_failed" {
+			t.Errorf("expected trigger to fire, got %q", reason)
+		}
+	})
+
+	t.Run("custom phrases", func(t *testing.T) {
+		trigger := UserRequest("agente humano")
+		if _, ok := trigger.Check(ctx, &Signal{Message: "Quiero un AGENTE HUMANO"}); !ok {
+			t.Error("expected trigger to fire")
+		}
+	})
+}
+
+func TestInterceptWithoutConversation(t *testing.T) {
+	manager := NewManager(chatbottest.NewStore())
+	if paused, err := manager.Intercept(context.Background(), "talk to a human", map[string]interface{}{}); err != nil || paused {
+		t.Errorf("expected messages without a conversation to pass, got %v, %v", paused, err)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var bodies []map[string]interface{}
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		auths = append(auths, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	n := Notification{ConversationID: "c1", UserID: "u1", Reason: "user_request", Message: "help"}
+	webhook := &WebhookNotifier{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t"}}
+	if err := webhook.Notify(context.Background(), n); err != nil {
+		t.Fatalf("webhook failed: %v", err)
+	}
+	if err := (&SlackNotifier{WebhookURL: server.URL}).Notify(context.Background(), n); err != nil {
+		t.Fatalf("slack failed: %v", err)
+	}
+
+	if bodies[0]["conversation_id"] != "c1" || auths[0] != "Bearer t" {
+		t.Errorf("unexpected webhook request %v, %q", bodies[0], auths[0])
+	}
+	if text, _ := bodies[1]["text"].(string); !strings.Contains(text, "`c1` needs a human") || !strings.Contains(text, "> help") {
+		t.Errorf("unexpected slack text %q", text)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := (&WebhookNotifier{URL: failing.URL}).Notify(context.Background(), n); err == nil {
+		t.Error("expected error for failing endpoint")
+	}
+}
+
+func TestEventNotifier(t *testing.T) {
+	var published []events.Event
+	notifier := &EventNotifier{Publisher: events.PublisherFunc(func(ctx context.Context, event events.Event) error {
+		published = append(published, event)
+		return nil
+	})}
+
+	n := Notification{ConversationID: "c1", UserID: "u1", Reason: "user_request", Message: "help", Time: time.Now()}
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(published) != 1 {
+		t.Fatalf("expected one event, got %d", len(published))
+	}
+	event := published[0]
+	if event.Type != events.ConversationEscalated || event.ConversationID != "c1" || event.UserID != "u1" || !event.Time.Equal(n.Time) {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.Data["reason"] != "user_request" || event.Data["message"] != "help" {
+		t.Errorf("unexpected data %v", event.Data)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := chatbottest.NewStore()
+	manager := NewManager(store)
+	server := httptest.NewServer(http.StripPrefix("/handoffs", manager.Handler()))
+	defer server.Close()
+
+	post := func(path, body string) *http.Response {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := post("/handoffs/c1/messages", `{"operator":"alice","content":"hi"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown conversation, got %d", resp.StatusCode)
+	}
+	if resp := post("/handoffs/c1/escalate", `{}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+	if resp := post("/handoffs/c1/messages", `{"operator":"alice","content":"hi"}`); resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201, got %d", resp.StatusCode)
+	}
+	if resp := post("/handoffs/c1/messages", `{"content":" "}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty content, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(server.URL + "/handoffs/c1")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var status Status
+	_ = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if !status.NeedsHuman || status.Reason != "operator" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if resp := post("/handoffs/c1/resolve", ``); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", resp.StatusCode)
+	}
+	if status, _ := manager.Status(context.Background(), "c1"); status.NeedsHuman {
+		t.Error("expected conversation to be resolved")
+	}
+}
@@ -0,0 +1,103 @@
+package handoff
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Handler returns the operator API. Mount it under a prefix with
+// http.StripPrefix and put it behind your own authentication:
+//
+//	GET  /{id}           handoff status
+//	POST /{id}/escalate  {"reason": "..."} hands the conversation to a human
+//	POST /{id}/messages  {"operator": "...", "content": "..."} posts a reply
+//	POST /{id}/resolve   hands the conversation back to the chatbot
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{id}", m.handleStatus)
+	mux.HandleFunc("POST /{id}/escalate", m.handleEscalate)
+	mux.HandleFunc("POST /{id}/messages", m.handlePost)
+	mux.HandleFunc("POST /{id}/resolve", m.handleResolve)
+	return mux
+}
+
+func (m *Manager) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := m.Status(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (m *Manager) handleEscalate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON request")
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "operator"
+	}
+
+	id := r.PathValue("id")
+	if err := m.Escalate(r.Context(), id, req.Reason); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	m.handleStatus(w, r)
+}
+
+func (m *Manager) handlePost(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Operator string `json:"operator"`
+		Content  string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON request")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "Content cannot be empty")
+		return
+	}
+
+	msg, err := m.Post(r.Context(), r.PathValue("id"), req.Operator, req.Content)
+	if err != nil {
+		writeError(w, statusFor(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+func (m *Manager) handleResolve(w http.ResponseWriter, r *http.Request) {
+	if err := m.Resolve(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, statusFor(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// statusFor maps store errors to HTTP statuses.
+func statusFor(err error) int {
+	if errors.Is(err, database.ErrConversationNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,123 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/events"
+)
+
+// Notification tells operators a conversation needs a human.
+type Notification struct {
+	ConversationID string    `json:"conversation_id"`
+	UserID         string    `json:"user_id,omitempty"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+// Notifier delivers handoff notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, n Notification) error
+
+// Notify implements Notifier.
+func (f NotifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// WebhookNotifier posts notifications as JSON to a URL.
+type WebhookNotifier struct {
+	// URL receives a POST per notification.
+	URL string
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// Notify implements Notifier.
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, w.HTTPClient, w.URL, w.Headers, n)
+}
+
+// SlackNotifier posts notifications to a Slack incoming webhook.
+type SlackNotifier struct {
+	// WebhookURL is the incoming webhook URL from the Slack app settings.
+	WebhookURL string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// Notify implements Notifier.
+func (s *SlackNotifier) Notify(ctx context.Context, n Notification) error {
+	text := fmt.Sprintf(":raising_hand: Conversation `%s` needs a human (%s)", n.ConversationID, n.Reason)
+	if n.UserID != "" {
+		text += fmt.Sprintf("\nUser: %s", n.UserID)
+	}
+	if n.Message != "" {
+		text += fmt.Sprintf("\n> %s", n.Message)
+	}
+	return postJSON(ctx, s.HTTPClient, s.WebhookURL, nil, map[string]string{"text": text})
+}
+
+// EventNotifier publishes notifications as events.ConversationEscalated
+// events, e.g. to the webhooks receiving the chatbot's other events.
+type EventNotifier struct {
+	Publisher events.Publisher
+}
+
+// Notify implements Notifier.
+func (e *EventNotifier) Notify(ctx context.Context, n Notification) error {
+	data := map[string]interface{}{"reason": n.Reason}
+	if n.Message != "" {
+		data["message"] = n.Message
+	}
+	event := events.New(events.ConversationEscalated, data)
+	event.ConversationID = n.ConversationID
+	event.UserID = n.UserID
+	event.Time = n.Time.UTC()
+	return e.Publisher.Publish(ctx, event)
+}
+
+// postJSON posts payload and expects a 2xx response.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Signal is what triggers see for each user message.
+type Signal struct {
+	ConversationID string
+	UserID         string
+	Message        string
+
+	// Context is the request context passed to the model. It carries the
+	// filter's "aggression_detected" flag and analyzer results such as
+	// "sentiment_score".
+	Context map[string]interface{}
+}
+
+// Trigger decides whether a message should be handed to a human. It returns
+// a short reason when it fires.
+type Trigger interface {
+	Check(ctx context.Context, signal *Signal) (reason string, ok bool)
+}
+
+// TriggerFunc adapts a function to the Trigger interface.
+type TriggerFunc func(ctx context.Context, signal *Signal) (string, bool)
+
+// Check implements Trigger.
+func (f TriggerFunc) Check(ctx context.Context, signal *Signal) (string, bool) {
+	return f(ctx, signal)
+}
+
+// DefaultPhrases are the phrases UserRequest looks for when none are given.
+var DefaultPhrases = []string{
+	"talk to a human",
+	"speak to a human",
+	"talk to a person",
+	"speak to a person",
+	"real person",
+	"human agent",
+	"live agent",
+	"talk to someone",
+	"speak to someone",
+	"representative",
+	"operator",
+}
+
+// UserRequest fires when the user asks for a human, matching any of the
+// phrases case-insensitively (DefaultPhrases if none are given).
+func UserRequest(phrases ...string) Trigger {
+	if len(phrases) == 0 {
+		phrases = DefaultPhrases
+	}
+	lowered := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		lowered[i] = strings.ToLower(phrase)
+	}
+
+	return TriggerFunc(func(ctx context.Context, signal *Signal) (string, bool) {
+		message := strings.ToLower(signal.Message)
+		for _, phrase := range lowered {
+			if strings.Contains(message, phrase) {
+				return "user_request", true
+			}
+		}
+		return "", false
+	})
+}
+
+// NegativeSentiment fires when the message's "sentiment_score" (from -1,
+// very negative, to 1, very positive) is at or below threshold. Messages
+// without a score never fire.
+func NegativeSentiment(threshold float64) Trigger {
+	return TriggerFunc(func(ctx context.Context, signal *Signal) (string, bool) {
+		score, ok := signal.Context["sentiment_score"].(float64)
+		if !ok || score > threshold {
+			return "", false
+		}
+		return fmt.Sprintf("negative_sentiment(%.2f)", score), true
+	})
+}
+
+// DeescalationFailure fires when a conversation stays aggressive for the
+// given number of consecutive messages, i.e. de-escalating did not work. A
+// calm message resets the count.
+func DeescalationFailure(attempts int) Trigger {
+	var mutex sync.Mutex
+	streaks := make(map[string]int)
+
+	return TriggerFunc(func(ctx context.Context, signal *Signal) (string, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if aggressive, _ := signal.Context["aggression_detected"].(bool); !aggressive {
+			delete(streaks, signal.ConversationID)
+			return "", false
+		}
+
+		streaks[signal.ConversationID]++
+		if streaks[signal.ConversationID] < attempts {
+			return "", false
+		}
+		delete(streaks, signal.ConversationID)
+		return "deescalation_failed", true
+	})
+}
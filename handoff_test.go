@@ -0,0 +1,46 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/models"
+)
+
+// pausedConversations hands off the listed conversations.
+type pausedConversations map[string]bool
+
+func (p pausedConversations) Intercept(ctx context.Context, message string, askContext map[string]interface{}) (bool, error) {
+	id, _ := askContext["conversation_id"].(string)
+	return p[id], nil
+}
+
+func TestWithHandoff(t *testing.T) {
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()), WithHandoff(pausedConversations{"c1": true}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "Hello", WithContext("conversation_id", "c1")); !errors.Is(err, ErrNeedsHuman) {
+		t.Errorf("expected ErrNeedsHuman, got %v", err)
+	}
+	if _, err := bot.Ask(context.Background(), "Hello", WithContext("conversation_id", "c2")); err != nil {
+		t.Errorf("expected bot to answer other conversations, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"message":"Hello","conversation_id":"c1"}`))
+	w := httptest.NewRecorder()
+	bot.HandleHTTP(w, req)
+
+	var resp ChatResponse
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || !resp.Handoff || resp.Reply != "" {
+		t.Errorf("expected handoff response, got %d %+v", w.Code, resp)
+	}
+}
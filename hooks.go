@@ -0,0 +1,45 @@
+package gochatbot
+
+import (
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+)
+
+// Hooks contains optional callbacks invoked on chatbot lifecycle events.
+// Every field may be left nil.
+type Hooks struct {
+	// OnConfigReload is called after a configuration reload has been applied,
+	// with the list of settings that changed.
+	OnConfigReload func(changes []config.Change)
+
+	// OnConfigError is called when a configuration reload fails. The previous
+	// configuration remains in effect.
+	OnConfigError func(err error)
+
+	// OnEventError is called when publishing a chat event fails. The chat
+	// request itself is not affected.
+	OnEventError func(event events.Event, err error)
+
+	// OnPanic is called when the chatbot recovers a panic, after it has
+	// been logged, for example to count it in a metric.
+	OnPanic func(err *PanicError)
+
+	// OnAnalytics is called with every analytics event, before it is
+	// exported, for example to feed an in-house pipeline. It runs on the
+	// request, so it must be quick.
+	OnAnalytics func(event analytics.Event)
+
+	// OnRetrieval is called with the knowledge chunks retrieved for each
+	// reply, their scores and whether the reply cites them, to debug
+	// answers that went wrong because of what was retrieved. It runs on
+	// the request, so it must be quick.
+	OnRetrieval func(retrieval Retrieval)
+}
+
+// WithHooks sets lifecycle hooks for the chatbot.
+func WithHooks(hooks Hooks) Option {
+	return func(c *Chatbot) {
+		c.hooks = hooks
+	}
+}
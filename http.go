@@ -3,9 +3,17 @@ package gochatbot
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/middleware"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 // contextKey is a custom type for context keys to avoid collisions
@@ -17,35 +25,145 @@ const (
 
 // Health status constants
 const (
-	healthStatusHealthy   = "healthy"
-	healthStatusUnhealthy = "unhealthy"
+	healthStatusHealthy     = "healthy"
+	healthStatusUnhealthy   = "unhealthy"
+	healthStatusMaintenance = "maintenance"
 )
 
 // ChatRequest represents an incoming chat request.
 type ChatRequest struct {
 	Message string `json:"message"`
+	// Messages replaces Message for clients that send the history
+	// themselves; see ResolveMessages.
+	Messages       []ChatMessage `json:"messages,omitempty"`
+	ConversationID string        `json:"conversation_id,omitempty"`
+	// StreamMode is "delta" (default) or "cumulative" for streaming
+	// requests; it can also be set with the stream_mode query parameter.
+	StreamMode string `json:"stream_mode,omitempty"`
+	// Stop ends the reply before the first of up to four sequences, and
+	// MaxTokens lowers the configured max_tokens. Streams enforce both
+	// whether or not the provider does.
+	Stop      []string `json:"stop,omitempty"`
+	MaxTokens int      `json:"max_tokens,omitempty"`
+	// Language and Tone override the configured reply language and tone,
+	// such as "de" and "formal"; see WithLanguage and WithTone.
+	Language string `json:"language,omitempty"`
+	Tone     string `json:"tone,omitempty"`
 }
 
+// maxStopSequences is how many stop sequences a request may set, as many
+// as OpenAI accepts.
+const maxStopSequences = 4
+
+// maxStyleLength is how long a request's language and tone may be.
+const maxStyleLength = 64
+
 // ChatResponse represents a chat response.
 type ChatResponse struct {
 	Reply string `json:"reply"`
 	Error string `json:"error,omitempty"`
+	// Code identifies the kind of error, such as "rate_limited"; see the
+	// chaterrors package.
+	Code string `json:"code,omitempty"`
+	// Handoff is set when a human operator answers the conversation instead.
+	Handoff bool `json:"handoff,omitempty"`
+
+	// MessageID identifies the reply, as stored in its conversation;
+	// ConversationID is the conversation it belongs to, if any.
+	MessageID      string `json:"message_id,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	// Model, Usage and FinishReason are set when the provider reports them.
+	Model        string           `json:"model,omitempty"`
+	Usage        *streaming.Usage `json:"usage,omitempty"`
+	FinishReason string           `json:"finish_reason,omitempty"`
+
+	// Reference identifies the logged panic behind an internal error.
+	Reference string `json:"reference,omitempty"`
+}
+
+// ResponseMarshaler encodes the body of a chat response, errors included,
+// for frontends that expect another envelope than {"reply": ...}, such as
+// {"data": {"content": ...}}. Errors have only Error set.
+type ResponseMarshaler func(response *ChatResponse) ([]byte, error)
+
+// WithResponseMarshaler sets how HandleHTTP, HandleStreamHTTP errors and
+// the framework adapters encode chat responses. The default is the JSON of
+// ChatResponse, or of the adapter's own response type.
+func WithResponseMarshaler(marshal ResponseMarshaler) Option {
+	return func(c *Chatbot) {
+		c.marshal = marshal
+	}
+}
+
+// GetResponseMarshaler returns the marshaler set with
+// WithResponseMarshaler, or nil.
+func (c *Chatbot) GetResponseMarshaler() ResponseMarshaler {
+	return c.marshal
+}
+
+// AskResponse is like Ask, but returns the reply as a ChatResponse with its
+// message ID, the conversation ID and, when the provider reports them, the
+// model, token usage and finish reason. With WithConversations, a request
+// without a "conversation_id" starts a new conversation, and the message ID
+// is the ID of the stored reply; it is empty if storing the exchange failed
+// or was queued for a retry. Without stored conversations, a new message ID
+// is generated.
+func (c *Chatbot) AskResponse(ctx context.Context, message string, options ...AskOption) (*ChatResponse, error) {
+	options = append(options[:len(options):len(options)], withNewConversation())
+
+	// A request logger may already collect the metadata
+	meta, ok := streaming.MetadataFromContext(ctx)
+	if !ok {
+		meta = &streaming.Metadata{}
+		ctx = streaming.NewMetadataContext(ctx, meta)
+	}
+	reply, err := c.Ask(ctx, message, options...)
+	if err != nil {
+		return nil, err
+	}
+	messageID := meta.MessageID
+	if c.conversations == nil || meta.ConversationID == "" {
+		messageID = uuid.New().String()
+	}
+
+	return &ChatResponse{
+		Reply:          reply,
+		MessageID:      messageID,
+		ConversationID: meta.ConversationID,
+		Model:          meta.Model,
+		Usage:          meta.Usage,
+		FinishReason:   meta.FinishReason,
+	}, nil
 }
 
 // HTTPHandler provides HTTP handling functionality for the chatbot.
 type HTTPHandler struct {
 	chatbot *Chatbot
+	marshal ResponseMarshaler
 }
 
 // NewHTTPHandler creates a new HTTP handler for the chatbot.
 func NewHTTPHandler(chatbot *Chatbot) *HTTPHandler {
 	return &HTTPHandler{
 		chatbot: chatbot,
+		marshal: chatbot.marshal,
 	}
 }
 
+// WithResponseMarshaler sets how the handler encodes chat responses,
+// overriding the chatbot's.
+func (h *HTTPHandler) WithResponseMarshaler(marshal ResponseMarshaler) *HTTPHandler {
+	h.marshal = marshal
+	return h
+}
+
 // HandleHTTP handles HTTP requests for chat functionality.
 func (h *HTTPHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	writer := &recoveryWriter{ResponseWriter: w}
+	w = writer
+	defer h.recoverPanic(writer)
+
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
@@ -63,6 +181,9 @@ func (h *HTTPHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
+	if !h.checkCSRF(w, r) {
+		return
+	}
 
 	// Parse request
 	var req ChatRequest
@@ -72,13 +193,25 @@ func (h *HTTPHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if strings.TrimSpace(req.Message) == "" {
+	if strings.TrimSpace(req.Message) == "" && len(req.Messages) == 0 {
 		h.writeErrorResponse(w, http.StatusBadRequest, "Message cannot be empty")
 		return
 	}
+	message, options, err := ResolveMessages(req.Message, req.Messages)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	generation, err := h.generationOptions(req)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	options = append(options, generation...)
 
 	// Create context with client information
 	ctx := context.WithValue(r.Context(), clientIPContextKey, h.getClientIP(r))
+	ctx = h.chatbot.identifySession(ctx, w, r)
 
 	// Add timeout if not already set
 	if h.chatbot.timeout > 0 {
@@ -88,44 +221,125 @@ func (h *HTTPHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process chat request
-	reply, err := h.chatbot.Ask(ctx, req.Message)
+	options = append(options, conversationOptions(req)...)
+	response, err := h.chatbot.AskResponse(ctx, message, options...)
 	if err != nil {
+		if errors.Is(err, ErrNeedsHuman) {
+			h.writeResponse(w, http.StatusOK, &ChatResponse{Handoff: true})
+			return
+		}
 		// Check for specific error types
 		if ctx.Err() == context.DeadlineExceeded {
 			h.writeErrorResponse(w, http.StatusRequestTimeout, "Request timeout")
 			return
 		}
-		if strings.Contains(err.Error(), "rate limit") {
-			h.writeErrorResponse(w, http.StatusTooManyRequests, "Rate limit exceeded")
-			return
-		}
-
-		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to process request")
+		setRateLimitHeaders(w.Header(), err)
+		h.writeError(w, err)
 		return
 	}
 
 	// Send response
-	response := ChatResponse{
-		Reply: reply,
+	h.writeResponse(w, http.StatusOK, response)
+}
+
+// generationOptions passes the request's stop sequences, max tokens,
+// language and tone to the chatbot. Clients may lower the configured
+// max_tokens, not raise it.
+func (h *HTTPHandler) generationOptions(req ChatRequest) ([]AskOption, error) {
+	var options []AskOption
+	var stop []string
+	for _, sequence := range req.Stop {
+		if sequence != "" {
+			stop = append(stop, sequence)
+		}
+	}
+	if len(stop) > maxStopSequences {
+		return nil, fmt.Errorf("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	if len(stop) > 0 {
+		options = append(options, WithStop(stop...))
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		// Error encoding response, but headers already sent
-		return
+	if req.MaxTokens < 0 {
+		return nil, errors.New("max_tokens must not be negative")
+	}
+	if req.MaxTokens > 0 {
+		maxTokens := req.MaxTokens
+		if cfg := h.chatbot.GetConfig(); cfg != nil && cfg.MaxTokens > 0 {
+			maxTokens = min(maxTokens, cfg.MaxTokens)
+		}
+		options = append(options, WithMaxTokens(maxTokens))
+	}
+
+	if len(req.Language) > maxStyleLength || len(req.Tone) > maxStyleLength {
+		return nil, fmt.Errorf("language and tone must be at most %d bytes", maxStyleLength)
 	}
+	if req.Language != "" {
+		options = append(options, WithLanguage(req.Language))
+	}
+	if req.Tone != "" {
+		options = append(options, WithTone(req.Tone))
+	}
+	return options, nil
 }
 
-// writeErrorResponse writes an error response to the client.
+// conversationOptions passes the request's conversation ID to the chatbot,
+// which checks that it belongs to the caller.
+func conversationOptions(req ChatRequest) []AskOption {
+	if req.ConversationID == "" {
+		return nil
+	}
+	return []AskOption{WithConversationID(req.ConversationID)}
+}
+
+// writeErrorResponse writes an error response to the client, with the code
+// of the kind of error answered with statusCode, if any.
 func (h *HTTPHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
-	response := ChatResponse{
-		Error: message,
+	response := &ChatResponse{Error: message}
+	if kind := chaterrors.ForStatus(statusCode); kind != nil {
+		response.Code = kind.Code
+	} else if statusCode == http.StatusInternalServerError {
+		response.Code = chaterrors.CodeInternal
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		// Error encoding response, but headers already sent
+	h.writeResponse(w, statusCode, response)
+}
+
+// writeError writes the error response for err, with the status, code and
+// message of its kind (see chaterrors.Message).
+func (h *HTTPHandler) writeError(w http.ResponseWriter, err error) {
+	h.writeResponse(w, chaterrors.Status(err), &ChatResponse{Error: chaterrors.Message(err), Code: chaterrors.Code(err)})
+}
+
+// writeResponse encodes response with the handler's marshaler, or as JSON.
+func (h *HTTPHandler) writeResponse(w http.ResponseWriter, statusCode int, response *ChatResponse) {
+	var body []byte
+	var err error
+	if h.marshal != nil {
+		body, err = h.marshal(response)
+	} else {
+		body, err = json.Marshal(response)
+		body = append(body, '\n')
+	}
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
+
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// setRateLimitHeaders sets the X-RateLimit-* and Retry-After headers if err
+// is a rate limiter rejection, reporting whether it was.
+func setRateLimitHeaders(header http.Header, err error) bool {
+	var limited *middleware.RateLimitError
+	if !errors.As(err, &limited) {
+		return false
+	}
+	for name, value := range limited.Headers() {
+		header.Set(name, value)
+	}
+	return true
 }
 
 // getClientIP extracts the client IP address from the request.
@@ -175,6 +389,13 @@ func (h *HTTPHandler) Health(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"status": healthStatusHealthy,
 	}
+	if maintenance := h.chatbot.Maintenance(); maintenance.Enabled {
+		response["status"] = healthStatusMaintenance
+		response["maintenance"] = true
+		if !maintenance.Until.IsZero() {
+			response["until"] = maintenance.Until
+		}
+	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		// Error encoding response, but headers already sent
 		return
@@ -189,6 +410,10 @@ func (c *Chatbot) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 
 // HandleStreamHTTP handles streaming HTTP requests for chat functionality.
 func (h *HTTPHandler) HandleStreamHTTP(w http.ResponseWriter, r *http.Request) {
+	writer := &recoveryWriter{ResponseWriter: w}
+	w = writer
+	defer h.recoverPanic(writer)
+
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
@@ -205,6 +430,9 @@ func (h *HTTPHandler) HandleStreamHTTP(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
+	if !h.checkCSRF(w, r) {
+		return
+	}
 
 	// Parse request
 	var req ChatRequest
@@ -214,23 +442,49 @@ func (h *HTTPHandler) HandleStreamHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if strings.TrimSpace(req.Message) == "" {
+	if strings.TrimSpace(req.Message) == "" && len(req.Messages) == 0 {
 		h.writeErrorResponse(w, http.StatusBadRequest, "Message cannot be empty")
 		return
 	}
+	message, options, err := ResolveMessages(req.Message, req.Messages)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	generation, err := h.generationOptions(req)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	options = append(options, generation...)
+
+	if req.StreamMode == "" {
+		req.StreamMode = r.URL.Query().Get("stream_mode")
+	}
+	mode, err := streaming.ParseMode(req.StreamMode)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
+	ctx = h.chatbot.identifySession(ctx, w, r)
 
 	// Add client IP to context
 	clientIP := h.getClientIP(r)
 
 	// Process streaming request
-	err := h.chatbot.AskStream(ctx, w, req.Message, WithContext("client_ip", clientIP))
-	if err != nil {
+	options = append(options, conversationOptions(req)...)
+	options = append(options, WithContext("client_ip", clientIP), WithStreamMode(mode))
+	if err := h.chatbot.AskStream(ctx, w, message, options...); err != nil {
+		if errors.Is(err, streaming.ErrClientDisconnected) {
+			// Nobody is left to answer
+			return
+		}
 		// If we couldn't set up streaming, fall back to error response
-		h.writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, err)
 		return
 	}
 }
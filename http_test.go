@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"go.rumenx.com/chatbot/chaterrors"
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestNewHTTPHandler(t *testing.T) {
@@ -294,6 +297,214 @@ func TestChatbotHandleHTTP(t *testing.T) {
 	}
 }
 
+func TestChatbotHandleHTTP_ResponseFields(t *testing.T) {
+	chatbot, err := New(&config.Config{Model: "free"})
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	chatbot.HandleHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.MessageID == "" {
+		t.Error("Expected a message ID")
+	}
+	if response.Model != "free-model" || response.FinishReason != "stop" {
+		t.Errorf("Unexpected model or finish reason: %+v", response)
+	}
+	if !strings.Contains(w.Body.String(), `"message_id"`) || strings.Contains(w.Body.String(), `"usage"`) {
+		t.Errorf("Unexpected response fields: %s", w.Body.String())
+	}
+}
+
+func TestHandleHTTP_ResponseMarshaler(t *testing.T) {
+	envelope := func(response *ChatResponse) ([]byte, error) {
+		if response.Error != "" {
+			return json.Marshal(map[string]interface{}{"ok": false, "message": response.Error})
+		}
+		return json.Marshal(map[string]interface{}{"data": map[string]string{"content": response.Reply}})
+	}
+	chatbot, err := New(&config.Config{Model: "free"}, WithResponseMarshaler(envelope))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	chatbot.HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "Hello"}`)))
+	var ok struct {
+		Data struct {
+			Content string `json:"content"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &ok); err != nil || ok.Data.Content == "" {
+		t.Errorf("Expected the custom envelope, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	chatbot.HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": ""}`)))
+	if w.Code != http.StatusBadRequest || w.Body.String() != `{"message":"Message cannot be empty","ok":false}` {
+		t.Errorf("Expected the custom error envelope, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A handler's marshaler overrides the chatbot's
+	plain := func(response *ChatResponse) ([]byte, error) {
+		return []byte(response.Reply + response.Error), nil
+	}
+	w = httptest.NewRecorder()
+	NewHTTPHandler(chatbot).WithResponseMarshaler(plain).HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{}`)))
+	if w.Body.String() != "Message cannot be empty" {
+		t.Errorf("Expected the handler's marshaler, got %s", w.Body.String())
+	}
+}
+
+func TestChatbotAskResponse(t *testing.T) {
+	chatbot, err := New(&config.Config{Model: "free"})
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	first, err := chatbot.AskResponse(context.Background(), "Hello", WithContext("conversation_id", "conv-1"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := chatbot.AskResponse(context.Background(), "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if first.ConversationID != "conv-1" || second.ConversationID != "" {
+		t.Errorf("Unexpected conversation IDs: %q, %q", first.ConversationID, second.ConversationID)
+	}
+	if first.MessageID == "" || first.MessageID == second.MessageID {
+		t.Errorf("Expected distinct message IDs, got %q and %q", first.MessageID, second.MessageID)
+	}
+}
+
+// unavailableModel fails like a provider that is down.
+type unavailableModel struct{}
+
+func (m *unavailableModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return "", chaterrors.Mark(errors.New("status 503, body: upstream connect error"), chaterrors.ErrProviderUnavailable)
+}
+
+// AskStream fails like a provider that goes down partway through a reply.
+func (m *unavailableModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		ch <- "Hel"
+		if meta, ok := streaming.MetadataFromContext(ctx); ok {
+			meta.Fail(chaterrors.Mark(errors.New("connection reset"), chaterrors.ErrProviderUnavailable))
+		}
+	}()
+	return ch, nil
+}
+
+func (m *unavailableModel) Name() string     { return "unavailable" }
+func (m *unavailableModel) Provider() string { return "test" }
+
+func TestHandleHTTP_ErrorCodes(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	chatbot, err := New(cfg, WithModel(&unavailableModel{}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	tests := []struct {
+		body   string
+		status int
+		code   string
+		error  string
+	}{
+		{body: `{"message": "Hello"}`, status: http.StatusServiceUnavailable, code: "provider_unavailable", error: "AI provider unavailable"},
+		{body: `{"message": " "}`, status: http.StatusBadRequest, code: "invalid_input", error: "Message cannot be empty"},
+		{body: `{"messages": [{"role": "system", "content": "Hi"}]}`, status: http.StatusBadRequest, code: "invalid_input"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		chatbot.HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(tt.body)))
+		var response ChatResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if w.Code != tt.status || response.Code != tt.code {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.body, tt.status, tt.code, w.Code, response.Code)
+		}
+		if tt.error != "" && response.Error != tt.error {
+			t.Errorf("%s: expected error %q, got %q", tt.body, tt.error, response.Error)
+		}
+	}
+}
+
+func TestHandleStreamHTTP_StreamFailure(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	chatbot, err := New(cfg, WithModel(&unavailableModel{}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	chatbot.HandleStreamHTTP(w, httptest.NewRequest("POST", "/chat/stream", strings.NewReader(`{"message": "Hello"}`)))
+	body := w.Body.String()
+	if !strings.Contains(body, `"content":"Hel"`) {
+		t.Errorf("Expected the content streamed before the failure, got %s", body)
+	}
+	if !strings.Contains(body, `"error":"AI provider unavailable","code":"provider_unavailable"`) {
+		t.Errorf("Expected an error chunk, got %s", body)
+	}
+	if strings.Contains(body, "connection reset") || strings.Count(body, `"done":true`) != 1 {
+		t.Errorf("Expected the error chunk to end the stream, got %s", body)
+	}
+}
+
+func TestHandleHTTP_RateLimitHeaders(t *testing.T) {
+	chatbot, err := New(&config.Config{
+		Model:     "free",
+		RateLimit: config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	// The first request is allowed, the chat and stream requests after it not
+	for i, handle := range []http.HandlerFunc{chatbot.HandleHTTP, chatbot.HandleHTTP, chatbot.HandleStreamHTTP} {
+		w := httptest.NewRecorder()
+		handle(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "Hello"}`)))
+		if i == 0 {
+			continue
+		}
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Request %d: expected status %d, got %d", i, http.StatusTooManyRequests, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"code":"rate_limited"`) {
+			t.Errorf("Request %d: expected the rate_limited code, got %s", i, w.Body.String())
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+			t.Errorf("Unexpected rate limit headers %v", w.Header())
+		}
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != "60" && retryAfter != "59" {
+			t.Errorf("Expected Retry-After of about a minute, got %q", retryAfter)
+		}
+		if w.Header().Get("X-RateLimit-Reset") == "" {
+			t.Error("Expected X-RateLimit-Reset")
+		}
+	}
+}
+
 func TestChatbotHandleStreamHTTP(t *testing.T) {
 	chatbot, err := New(&config.Config{Model: "free"})
 	if err != nil {
@@ -312,6 +523,212 @@ func TestChatbotHandleStreamHTTP(t *testing.T) {
 	}
 }
 
+// wordsModel streams its reply word by word.
+type wordsModel struct{}
+
+func (wordsModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return "Hello there friend", nil
+}
+
+func (wordsModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	ch := make(chan string, 3)
+	for _, word := range []string{"Hello", " there", " friend"} {
+		ch <- word
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (wordsModel) Name() string     { return "words" }
+func (wordsModel) Provider() string { return "test" }
+
+// endlessModel streams until its request is cancelled, which it reports.
+type endlessModel struct {
+	cancelled chan struct{}
+}
+
+func (m *endlessModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return "tick", nil
+}
+
+func (m *endlessModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case ch <- "tick":
+			case <-ctx.Done():
+				close(m.cancelled)
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (m *endlessModel) Name() string     { return "endless" }
+func (m *endlessModel) Provider() string { return "test" }
+
+// disconnectingWriter fails every write after the first, like a client that
+// went away mid-stream.
+type disconnectingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *disconnectingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("broken pipe")
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func (w *disconnectingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func TestAskStream_ClientDisconnected(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &endlessModel{cancelled: make(chan struct{})}
+	chatbot, err := New(cfg, WithModel(model))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	w := &disconnectingWriter{ResponseRecorder: httptest.NewRecorder()}
+	err = chatbot.AskStream(context.Background(), w, "Hello")
+	if !errors.Is(err, streaming.ErrClientDisconnected) {
+		t.Fatalf("Expected ErrClientDisconnected, got %v", err)
+	}
+
+	select {
+	case <-model.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the provider request to be cancelled")
+	}
+	if w.writes != 2 {
+		t.Errorf("Expected writing to stop at the failed write, got %d writes", w.writes)
+	}
+}
+
+func TestHandleStreamHTTP_StreamMode(t *testing.T) {
+	chatbot, err := New(config.Default(), WithModel(wordsModel{}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   []string
+	}{
+		{"delta", "/stream", `{"message":"Hi"}`, []string{"Hello", " there", " friend", ""}},
+		{"cumulative body", "/stream", `{"message":"Hi","stream_mode":"cumulative"}`,
+			[]string{"Hello", "Hello there", "Hello there friend", "Hello there friend"}},
+		{"cumulative query", "/stream?stream_mode=cumulative", `{"message":"Hi"}`,
+			[]string{"Hello", "Hello there", "Hello there friend", "Hello there friend"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			chatbot.HandleStreamHTTP(w, httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body)))
+
+			var contents []string
+			for _, line := range strings.Split(w.Body.String(), "\n") {
+				if data, ok := strings.CutPrefix(line, "data: "); ok {
+					var chunk struct{ Content string }
+					if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+						t.Fatalf("invalid chunk %q: %v", data, err)
+					}
+					contents = append(contents, chunk.Content)
+				}
+			}
+			if strings.Join(contents, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("expected %q, got %q", tt.want, contents)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	chatbot.HandleStreamHTTP(w, httptest.NewRequest("POST", "/stream", strings.NewReader(`{"message":"Hi","stream_mode":"words"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown mode, got %d", w.Code)
+	}
+}
+
+func TestHandleStreamHTTP_Limits(t *testing.T) {
+	chatbot, err := New(config.Default(), WithModel(wordsModel{}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	tests := []struct {
+		body, want, reason string
+	}{
+		{`{"message":"Hi","stop":["friend"]}`, "Hello there ", "stop"},
+		{`{"message":"Hi","max_tokens":2}`, "Hello th", "length"},
+		{`{"message":"Hi","stop":["nowhere"],"max_tokens":100}`, "Hello there friend", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		chatbot.HandleStreamHTTP(w, httptest.NewRequest("POST", "/stream", strings.NewReader(tt.body)))
+
+		var content strings.Builder
+		var reason string
+		for _, line := range strings.Split(w.Body.String(), "\n") {
+			var chunk struct {
+				Content      string
+				FinishReason string `json:"finish_reason"`
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok && json.Unmarshal([]byte(data), &chunk) == nil {
+				content.WriteString(chunk.Content)
+				reason += chunk.FinishReason
+			}
+		}
+		if content.String() != tt.want || reason != tt.reason {
+			t.Errorf("%s: expected %q ending with %q, got %q ending with %q", tt.body, tt.want, tt.reason, content.String(), reason)
+		}
+	}
+
+	for _, body := range []string{`{"message":"Hi","stop":["a","b","c","d","e"]}`, `{"message":"Hi","max_tokens":-1}`} {
+		w := httptest.NewRecorder()
+		chatbot.HandleStreamHTTP(w, httptest.NewRequest("POST", "/stream", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestHandleHTTP_LanguageAndTone(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	chatbot, err := New(cfg, WithModel(model))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	chatbot.HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message":"Hi","language":"es","tone":"formal"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if prompt, _ := model.context["prompt"].(string); !strings.Contains(prompt, "language: es.") || !strings.Contains(prompt, "formal tone") {
+		t.Errorf("expected the language and tone in the prompt, got %q", prompt)
+	}
+
+	w = httptest.NewRecorder()
+	body := `{"message":"Hi","tone":"` + strings.Repeat("x", maxStyleLength+1) + `"}`
+	chatbot.HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a long tone, got %d", w.Code)
+	}
+}
+
 func TestHandleStreamHTTP_OPTIONS(t *testing.T) {
 	chatbot, err := New(&config.Config{Model: "free"})
 	if err != nil {
@@ -0,0 +1,128 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// GeminiGenerator generates images with Gemini's native image output.
+type GeminiGenerator struct {
+	apiKey string
+	opts   options
+}
+
+// NewGeminiGenerator creates a generator using the Gemini API key from cfg.
+// The default model is "gemini-2.5-flash-image". cfg.Model is ignored
+// because it names the chat model.
+func NewGeminiGenerator(cfg config.GeminiConfig, opts ...Option) *GeminiGenerator {
+	o := newOptions(opts)
+	if o.model == "" {
+		o.model = "gemini-2.5-flash-image"
+	}
+	if o.endpoint == "" {
+		o.endpoint = "https://generativelanguage.googleapis.com"
+	}
+	o.endpoint = strings.TrimRight(o.endpoint, "/")
+	return &GeminiGenerator{apiKey: cfg.APIKey, opts: o}
+}
+
+// Generate implements Generator. Gemini returns one image per call, so
+// Count images are generated one after another. Size is not supported.
+func (g *GeminiGenerator) Generate(ctx context.Context, req Request) ([]*Image, error) {
+	if req.Prompt == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	count := req.Count
+	if count < 1 {
+		count = 1
+	}
+	images := make([]*Image, 0, count)
+	for i := 0; i < count; i++ {
+		image, err := g.generate(ctx, req.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func (g *GeminiGenerator) generate(ctx context.Context, prompt string) (*Image, error) {
+	request := map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{"parts": []map[string]string{{"text": prompt}}},
+		},
+		"generationConfig": map[string]interface{}{
+			"responseModalities": []string{"TEXT", "IMAGE"},
+		},
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", g.opts.endpoint, g.opts.model, url.QueryEscape(g.apiKey))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.opts.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "Gemini"); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text       string `json:"text"`
+					InlineData *struct {
+						MimeType string `json:"mimeType"`
+						Data     string `json:"data"`
+					} `json:"inlineData"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// The model may answer in text instead, e.g. when it refuses the prompt
+	var text []string
+	for _, candidate := range result.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData == nil {
+				if part.Text != "" {
+					text = append(text, part.Text)
+				}
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image: %w", err)
+			}
+			return &Image{Data: data, ContentType: part.InlineData.MimeType}, nil
+		}
+	}
+	if len(text) > 0 {
+		return nil, fmt.Errorf("no image returned: %s", strings.Join(text, " "))
+	}
+	return nil, fmt.Errorf("no image returned")
+}
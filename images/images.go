@@ -0,0 +1,125 @@
+// Package images generates images with OpenAI Images or Gemini and exposes
+// generation as a tool, so users can ask the bot to draw something:
+//
+//	generator := images.NewOpenAIGenerator(cfg.OpenAI)
+//	store := images.NewFileStore("./public/images", "https://example.com/images")
+//	registry := tools.NewRegistry(images.Tool(generator, store))
+//	result, err := bot.RunAgent(ctx, "Draw a lighthouse at dusk", registry)
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Image is a generated image.
+type Image struct {
+	Data []byte
+	// ContentType is the MIME type, e.g. "image/png".
+	ContentType string
+	// RevisedPrompt is the prompt the provider actually used, if it
+	// rewrote the request.
+	RevisedPrompt string
+}
+
+// Request describes the images to generate.
+type Request struct {
+	Prompt string
+	// Size is the image size, e.g. "1024x1024". Empty uses the generator's
+	// default.
+	Size string
+	// Count is the number of images (default 1).
+	Count int
+}
+
+// Generator generates images from a text prompt.
+type Generator interface {
+	Generate(ctx context.Context, req Request) ([]*Image, error)
+}
+
+// Option configures a generator.
+type Option func(*options)
+
+type options struct {
+	model      string
+	endpoint   string
+	size       string
+	quality    string
+	httpClient *http.Client
+}
+
+// WithModel overrides the default model, e.g. "dall-e-3".
+func WithModel(model string) Option {
+	return func(o *options) {
+		o.model = model
+	}
+}
+
+// WithEndpoint overrides the API endpoint.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithSize sets the default image size for OpenAI, e.g. "1536x1024".
+func WithSize(size string) Option {
+	return func(o *options) {
+		o.size = size
+	}
+}
+
+// WithQuality sets the OpenAI image quality, e.g. "high" or "hd".
+func WithQuality(quality string) Option {
+	return func(o *options) {
+		o.quality = quality
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for API calls.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{httpClient: &http.Client{Timeout: 2 * time.Minute}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// extensions maps image MIME types to file extensions.
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// Extension returns the file extension for an image MIME type.
+func Extension(contentType string) string {
+	if ext, ok := extensions[strings.ToLower(contentType)]; ok {
+		return ext
+	}
+	return ".bin"
+}
+
+// checkResponse turns a non-2xx response into an error with the API's message.
+func checkResponse(resp *http.Response, service string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(body))
+	if apiMessage := errorMessage(body); apiMessage != "" {
+		message = apiMessage
+	}
+	return fmt.Errorf("%s API error (status %d): %s", service, resp.StatusCode, message)
+}
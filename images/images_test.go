@@ -0,0 +1,144 @@
+package images
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n")
+
+func TestOpenAIGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image.png" {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+			return
+		}
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("Authorization") != "Bearer key" || req["prompt"] != "a cat" || req["size"] != "1024x1024" {
+			t.Errorf("unexpected request %v", req)
+		}
+		if req["model"] == "dall-e-3" {
+			if req["response_format"] != "b64_json" {
+				t.Errorf("expected base64 response format, got %v", req)
+			}
+			// Fall back to a URL to exercise downloading
+			_, _ = w.Write([]byte(`{"data":[{"url":"http://` + r.Host + `/image.png","revised_prompt":"a fluffy cat"}]}`))
+			return
+		}
+		if _, ok := req["response_format"]; ok {
+			t.Errorf("unexpected response_format for %v", req["model"])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":          []map[string]string{{"b64_json": base64.StdEncoding.EncodeToString(png)}},
+			"output_format": "png",
+		})
+	}))
+	defer server.Close()
+
+	generator := NewOpenAIGenerator(config.OpenAIConfig{APIKey: "key"}, WithEndpoint(server.URL), WithSize("1024x1024"))
+	images, err := generator.Generate(context.Background(), Request{Prompt: "a cat"})
+	if err != nil || len(images) != 1 || string(images[0].Data) != string(png) || images[0].ContentType != "image/png" {
+		t.Fatalf("unexpected images %+v, %v", images, err)
+	}
+
+	generator = NewOpenAIGenerator(config.OpenAIConfig{APIKey: "key"}, WithEndpoint(server.URL), WithModel("dall-e-3"))
+	images, err = generator.Generate(context.Background(), Request{Prompt: "a cat", Size: "1024x1024"})
+	if err != nil || len(images) != 1 || string(images[0].Data) != string(png) || images[0].RevisedPrompt != "a fluffy cat" {
+		t.Fatalf("unexpected images %+v, %v", images, err)
+	}
+}
+
+func TestGeminiGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash-image:generateContent" || r.URL.Query().Get("key") == "" {
+			t.Errorf("unexpected URL %s", r.URL)
+		}
+		body := `{"candidates":[{"content":{"parts":[{"text":"Here you go"},` +
+			`{"inlineData":{"mimeType":"image/png","data":"` + base64.StdEncoding.EncodeToString(png) + `"}}]}}]}`
+		if strings.Contains(r.URL.RawQuery, "refuse") {
+			body = `{"candidates":[{"content":{"parts":[{"text":"I can't draw that."}]}}]}`
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	generator := NewGeminiGenerator(config.GeminiConfig{APIKey: "gkey"}, WithEndpoint(server.URL+"/"))
+	images, err := generator.Generate(context.Background(), Request{Prompt: "a dog", Count: 2})
+	if err != nil || len(images) != 2 || images[0].ContentType != "image/png" || string(images[1].Data) != string(png) {
+		t.Fatalf("unexpected images %+v, %v", images, err)
+	}
+
+	refusing := NewGeminiGenerator(config.GeminiConfig{APIKey: "refuse"}, WithEndpoint(server.URL))
+	if _, err := refusing.Generate(context.Background(), Request{Prompt: "x"}); err == nil || !strings.Contains(err.Error(), "can't draw") {
+		t.Errorf("expected refusal error, got %v", err)
+	}
+}
+
+type fakeGenerator struct{ requests []Request }
+
+func (g *fakeGenerator) Generate(ctx context.Context, req Request) ([]*Image, error) {
+	g.requests = append(g.requests, req)
+	images := make([]*Image, req.Count)
+	for i := range images {
+		images[i] = &Image{Data: png, ContentType: "image/png"}
+	}
+	return images, nil
+}
+
+func TestTool(t *testing.T) {
+	dir := t.TempDir()
+	generator := &fakeGenerator{}
+	tool := Tool(generator, NewFileStore(dir, "https://cdn.example.com/images/"))
+	if tool.Name() != ToolName {
+		t.Errorf("unexpected name %q", tool.Name())
+	}
+
+	out, err := tool.Call(context.Background(), json.RawMessage(`{"prompt":"a red bicycle","count":10}`))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	var result struct {
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	}
+	_ = json.Unmarshal([]byte(out), &result)
+	if len(result.Images) != maxToolImages || generator.requests[0].Prompt != "a red bicycle" {
+		t.Fatalf("unexpected result %s", out)
+	}
+
+	url := result.Images[0].URL
+	if !strings.HasPrefix(url, "https://cdn.example.com/images/") || !strings.HasSuffix(url, ".png") {
+		t.Errorf("unexpected URL %q", url)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Base(url)))
+	if err != nil || string(data) != string(png) {
+		t.Errorf("image not stored: %v", err)
+	}
+
+	if _, err := tool.Call(context.Background(), json.RawMessage(`{"prompt":" "}`)); err == nil {
+		t.Error("expected error for an empty prompt")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	url, err := store.Put(context.Background(), "a.png", &Image{Data: png, ContentType: "image/png"})
+	if err != nil || url != "data:image/png;base64,"+base64.StdEncoding.EncodeToString(png) {
+		t.Errorf("unexpected URL %q, %v", url, err)
+	}
+	if image, ok := store.Get("a.png"); !ok || string(image.Data) != string(png) {
+		t.Error("expected stored image")
+	}
+}
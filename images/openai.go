@@ -0,0 +1,165 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// OpenAIGenerator generates images with the OpenAI Images API.
+type OpenAIGenerator struct {
+	apiKey string
+	opts   options
+}
+
+// NewOpenAIGenerator creates a generator using the OpenAI API key from cfg.
+// The default model is "gpt-image-1".
+func NewOpenAIGenerator(cfg config.OpenAIConfig, opts ...Option) *OpenAIGenerator {
+	o := newOptions(opts)
+	if o.model == "" {
+		o.model = "gpt-image-1"
+	}
+	if o.endpoint == "" {
+		o.endpoint = "https://api.openai.com/v1/images/generations"
+	}
+	return &OpenAIGenerator{apiKey: cfg.APIKey, opts: o}
+}
+
+// Generate implements Generator.
+func (g *OpenAIGenerator) Generate(ctx context.Context, req Request) ([]*Image, error) {
+	if req.Prompt == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	request := map[string]interface{}{
+		"model":  g.opts.model,
+		"prompt": req.Prompt,
+	}
+	if req.Count > 1 {
+		request["n"] = req.Count
+	}
+	if size := req.Size; size != "" {
+		request["size"] = size
+	} else if g.opts.size != "" {
+		request["size"] = g.opts.size
+	}
+	if g.opts.quality != "" {
+		request["quality"] = g.opts.quality
+	}
+	// DALL-E models return URLs unless asked for base64; gpt-image models
+	// always return base64 and reject the parameter
+	if g.opts.model == "dall-e-2" || g.opts.model == "dall-e-3" {
+		request["response_format"] = "b64_json"
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.opts.endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+g.apiKey)
+
+	resp, err := g.opts.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "OpenAI"); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			B64JSON       string `json:"b64_json"`
+			URL           string `json:"url"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+		OutputFormat string `json:"output_format"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("no images returned")
+	}
+
+	contentType := "image/png"
+	if result.OutputFormat != "" {
+		contentType = "image/" + result.OutputFormat
+		if result.OutputFormat == "jpg" {
+			contentType = "image/jpeg"
+		}
+	}
+
+	images := make([]*Image, 0, len(result.Data))
+	for _, item := range result.Data {
+		image := &Image{ContentType: contentType, RevisedPrompt: item.RevisedPrompt}
+		switch {
+		case item.B64JSON != "":
+			image.Data, err = base64.StdEncoding.DecodeString(item.B64JSON)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image: %w", err)
+			}
+		case item.URL != "":
+			image.Data, image.ContentType, err = g.download(ctx, item.URL)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("image has no data")
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// download fetches an image the API returned by URL; those URLs expire.
+func (g *OpenAIGenerator) download(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := g.opts.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download image: %w", err)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// errorMessage extracts the message from an OpenAI or Gemini error body.
+func errorMessage(body []byte) string {
+	var apiError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiError) != nil {
+		return ""
+	}
+	return apiError.Error.Message
+}
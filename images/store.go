@@ -0,0 +1,72 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store keeps generated images and returns a URL users can open. There is no
+// shared attachment storage yet, so implement Store to put images in S3, GCS
+// or a CDN.
+type Store interface {
+	Put(ctx context.Context, name string, image *Image) (url string, err error)
+}
+
+// FileStore writes images to a directory served at BaseURL, for example with
+// http.FileServer.
+type FileStore struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewFileStore creates a store writing to dir. The directory is created on
+// first use.
+func NewFileStore(dir, baseURL string) *FileStore {
+	return &FileStore{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Put implements Store.
+func (s *FileStore) Put(ctx context.Context, name string, image *Image) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create image directory: %w", err)
+	}
+	name = filepath.Base(name)
+	if err := os.WriteFile(filepath.Join(s.Dir, name), image.Data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	return s.BaseURL + "/" + name, nil
+}
+
+// MemoryStore keeps images in memory and returns data: URLs. It suits tests
+// and clients that render data URLs directly; the URLs are large.
+type MemoryStore struct {
+	mutex  sync.RWMutex
+	images map[string]*Image
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{images: make(map[string]*Image)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(ctx context.Context, name string, image *Image) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.images[name] = image
+	return dataURL(image), nil
+}
+
+// Get returns a stored image by name.
+func (s *MemoryStore) Get(name string) (*Image, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	image, ok := s.images[name]
+	return image, ok
+}
@@ -0,0 +1,82 @@
+package images
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"go.rumenx.com/chatbot/tools"
+)
+
+// ToolName is the name of the image generation tool.
+const ToolName = "generate_image"
+
+// maxToolImages caps how many images one tool call may generate.
+const maxToolImages = 4
+
+// Tool returns a tool that generates images with generator and keeps them in
+// store. The model receives the image URLs to include in its reply.
+func Tool(generator Generator, store Store) tools.Tool {
+	parameters := tools.Object(map[string]interface{}{
+		"prompt": tools.String("Detailed description of the image to generate"),
+		"size":   tools.String("Optional size such as 1024x1024, 1536x1024 (landscape) or 1024x1536 (portrait)"),
+		"count":  tools.Number(fmt.Sprintf("Number of images, 1 to %d (default 1)", maxToolImages)),
+	}, "prompt")
+
+	return tools.New(ToolName,
+		"Generate images from a text description. Returns URLs of the generated images.",
+		parameters,
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var input struct {
+				Prompt string  `json:"prompt"`
+				Size   string  `json:"size"`
+				Count  float64 `json:"count"`
+			}
+			if err := json.Unmarshal(args, &input); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if strings.TrimSpace(input.Prompt) == "" {
+				return "", fmt.Errorf("prompt is required")
+			}
+			count := int(input.Count)
+			if count < 1 {
+				count = 1
+			}
+			if count > maxToolImages {
+				count = maxToolImages
+			}
+
+			images, err := generator.Generate(ctx, Request{Prompt: input.Prompt, Size: input.Size, Count: count})
+			if err != nil {
+				return "", err
+			}
+
+			type result struct {
+				URL           string `json:"url"`
+				RevisedPrompt string `json:"revised_prompt,omitempty"`
+			}
+			results := make([]result, 0, len(images))
+			for _, image := range images {
+				url, err := store.Put(ctx, uuid.New().String()+Extension(image.ContentType), image)
+				if err != nil {
+					return "", err
+				}
+				results = append(results, result{URL: url, RevisedPrompt: image.RevisedPrompt})
+			}
+
+			data, err := json.Marshal(map[string]interface{}{"images": results})
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		})
+}
+
+// dataURL encodes an image as a data: URL.
+func dataURL(image *Image) string {
+	return "data:" + image.ContentType + ";base64," + base64.StdEncoding.EncodeToString(image.Data)
+}
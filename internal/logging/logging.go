@@ -0,0 +1,27 @@
+// Package logging carries the chatbot's logger in request contexts, so the
+// streams, guardrails, shadow models and rate limiters a chatbot calls log
+// where the chatbot does without each taking a logger option.
+package logging
+
+import (
+	"context"
+	"log"
+)
+
+type logfKey struct{}
+
+// NewContext returns a context carrying logf, such as the Printf of the
+// chatbot's logger.
+func NewContext(ctx context.Context, logf func(format string, v ...interface{})) context.Context {
+	return context.WithValue(ctx, logfKey{}, logf)
+}
+
+// Printf logs with the logger carried by ctx, or with the standard logger
+// of the log package outside a chatbot.
+func Printf(ctx context.Context, format string, v ...interface{}) {
+	if logf, ok := ctx.Value(logfKey{}).(func(format string, v ...interface{})); ok && logf != nil {
+		logf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
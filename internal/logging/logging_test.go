@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestPrintf(t *testing.T) {
+	var logged []string
+	ctx := NewContext(context.Background(), func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	})
+	Printf(ctx, "hello %s", "there")
+	if len(logged) != 1 || logged[0] != "hello there" {
+		t.Errorf("expected the context's logger to be used, got %q", logged)
+	}
+
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+	Printf(context.Background(), "fallback")
+	if !strings.Contains(buf.String(), "fallback") {
+		t.Errorf("expected the standard logger without one, got %q", buf.String())
+	}
+}
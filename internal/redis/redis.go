@@ -0,0 +1,263 @@
+// Package redis is a minimal client of the Redis protocol (RESP2), shared by
+// the rate limiter, the job queue and the locker so that none of them needs
+// a Redis library.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by a client after Close.
+var ErrClosed = errors.New("redis client closed")
+
+// Error is an error reply from the server.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ParseURL parses a "redis" or "rediss" URL, such as
+// "redis://:password@localhost:6379/0", defaulting the port to 6379.
+func ParseURL(rawURL string) (*url.URL, error) {
+	server, err := url.Parse(rawURL)
+	if err != nil || server.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q", rawURL)
+	}
+	if server.Scheme != "redis" && server.Scheme != "rediss" {
+		return nil, fmt.Errorf("unsupported Redis URL scheme %q", server.Scheme)
+	}
+	if server.Port() == "" {
+		server.Host = net.JoinHostPort(server.Hostname(), "6379")
+	}
+	return server, nil
+}
+
+// Client is a connection to one server, opened on first use and reopened
+// after it drops. Commands are sent one round trip at a time, so a Client
+// is safe for concurrent use.
+type Client struct {
+	server    *url.URL
+	timeout   time.Duration
+	tlsConfig *tls.Config
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	closed bool
+}
+
+// New creates a client of the server at rawURL (see ParseURL). The timeout
+// bounds connecting, and each round trip whose context has no deadline.
+// tlsConfig is used for "rediss" URLs; nil means TLS 1.2 or later with the
+// URL's host name.
+func New(rawURL string, timeout time.Duration, tlsConfig *tls.Config) (*Client, error) {
+	server, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{server: server, timeout: timeout, tlsConfig: tlsConfig}, nil
+}
+
+// Do sends one command and returns its reply. An error reply is returned
+// as an Error.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if replies == nil {
+		return nil, err
+	}
+	return replies[0], err
+}
+
+// Pipeline sends the commands in one round trip and returns one reply per
+// command. The first error reply is returned as an Error, with the replies
+// of all commands. A connection that dropped since the last round trip is
+// reopened and the commands are sent again.
+func (c *Client) Pipeline(ctx context.Context, commands ...[]string) ([]interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+
+	for attempt := 0; ; attempt++ {
+		reused := c.conn != nil
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+		replies, err := c.roundTrip(ctx, commands)
+		var replyErr Error
+		if err == nil || errors.As(err, &replyErr) {
+			return replies, err
+		}
+		c.disconnect()
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the connection. Later commands fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	c.disconnect()
+	return nil
+}
+
+// roundTrip writes the commands and reads one reply per command. An error
+// reply is returned after all replies were read, keeping the connection in
+// sync.
+func (c *Client) roundTrip(ctx context.Context, commands [][]string) ([]interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	_ = c.conn.SetDeadline(deadline)
+
+	var buf strings.Builder
+	for _, args := range commands {
+		fmt.Fprintf(&buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(c.conn, buf.String()); err != nil {
+		return nil, err
+	}
+
+	return c.readReplies(len(commands))
+}
+
+// readReplies reads count RESP values, returning the first error reply
+// after reading them all.
+func (c *Client) readReplies(count int) ([]interface{}, error) {
+	replies := make([]interface{}, count)
+	var firstErr error
+	for i := range replies {
+		reply, err := c.readReply()
+		var replyErr Error
+		if err != nil && !errors.As(err, &replyErr) {
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// readReply reads one RESP value. Nil bulk strings and arrays are returned
+// as nil.
+func (c *Client) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values, err := c.readReplies(count)
+		if values == nil {
+			return nil, err
+		}
+		return values, err
+	default:
+		return nil, fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
+
+// connect opens, authenticates and selects the database if there is no
+// connection.
+func (c *Client) connect(ctx context.Context) error {
+	if c.conn != nil {
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.server.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	if c.server.Scheme == "rediss" {
+		tlsConfig := c.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = c.server.Hostname()
+		}
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	if user := c.server.User; user != nil {
+		password, hasPassword := user.Password()
+		switch {
+		case hasPassword && user.Username() != "":
+			setup = append(setup, []string{"AUTH", user.Username(), password})
+		case hasPassword:
+			setup = append(setup, []string{"AUTH", password})
+		}
+	}
+	if db := strings.Trim(c.server.Path, "/"); db != "" && db != "0" {
+		setup = append(setup, []string{"SELECT", db})
+	}
+	if len(setup) > 0 {
+		if _, err := c.roundTrip(ctx, setup); err != nil {
+			c.disconnect()
+			return fmt.Errorf("failed to authenticate with Redis: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) disconnect() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+		c.reader = nil
+	}
+}
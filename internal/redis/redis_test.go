@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serve answers each command with the reply replies gives for it, closing
+// the connection when it returns "".
+func serve(t *testing.T, replies func(args []string) string) (string, *atomic.Int32) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var connections atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+					reply := replies(args)
+					if reply == "" {
+						return
+					}
+					if _, err := io.WriteString(conn, reply); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "redis://" + listener.Addr().String(), &connections
+}
+
+// readCommand reads one RESP array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line)[1:])
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestClient(t *testing.T) {
+	var drop atomic.Bool
+	addr, connections := serve(t, func(args []string) string {
+		if drop.CompareAndSwap(true, false) {
+			return ""
+		}
+		switch args[0] {
+		case "AUTH":
+			return "+OK\r\n"
+		case "GET":
+			return "$5\r\nhello\r\n"
+		case "MISSING":
+			return "$-1\r\n"
+		case "INCR":
+			return ":42\r\n"
+		case "RANGE":
+			return "*2\r\n$1\r\na\r\n:1\r\n"
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	})
+	client, err := New(strings.Replace(addr, "redis://", "redis://:secret@", 1), time.Second, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		command string
+		want    interface{}
+	}{
+		{"GET", "hello"},
+		{"MISSING", nil},
+		{"INCR", int64(42)},
+		{"RANGE", []interface{}{"a", int64(1)}},
+	} {
+		if reply, err := client.Do(ctx, tt.command); err != nil || !reflect.DeepEqual(reply, tt.want) {
+			t.Errorf("%s: expected %v, got %v, %v", tt.command, tt.want, reply, err)
+		}
+	}
+
+	// An error reply does not get the connection out of sync
+	replies, err := client.Pipeline(ctx, []string{"BOGUS"}, []string{"GET", "key"})
+	var replyErr Error
+	if !errors.As(err, &replyErr) || len(replies) != 2 || replies[1] != "hello" {
+		t.Errorf("expected the error reply with both replies, got %v, %v", replies, err)
+	}
+
+	// A dropped connection is reopened
+	drop.Store(true)
+	if reply, err := client.Do(ctx, "GET", "key"); err != nil || reply != "hello" {
+		t.Errorf("expected the command to be retried, got %v, %v", reply, err)
+	}
+	if connections.Load() != 2 {
+		t.Errorf("expected a second connection, got %d", connections.Load())
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, err := client.Do(ctx, "GET", "key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	server, err := ParseURL("rediss://cache.internal")
+	if err != nil || server.Host != "cache.internal:6379" {
+		t.Errorf("expected the default port, got %v, %v", server, err)
+	}
+	for _, raw := range []string{"localhost:6379", "http://localhost", "redis://"} {
+		if _, err := ParseURL(raw); err == nil {
+			t.Errorf("expected an error for %q", raw)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/database"
+)
+
+// Built-in job types.
+const (
+	AskJob       = "ask"
+	SummarizeJob = "summarize"
+)
+
+// AskInput is the input of an ask job.
+type AskInput struct {
+	Message        string `json:"message"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	UserID         string `json:"user_id,omitempty"`
+}
+
+// AskResult is the result of an ask job.
+type AskResult struct {
+	Reply string `json:"reply"`
+}
+
+// AskHandler answers the job's message with bot, for asks that take too long
+// to wait for, such as deep research with many tool calls.
+func AskHandler(bot *gochatbot.Chatbot) HandlerFunc {
+	return func(ctx context.Context, job *Job) (interface{}, error) {
+		var input AskInput
+		if err := json.Unmarshal(job.Input, &input); err != nil {
+			return nil, fmt.Errorf("invalid ask input: %w", err)
+		}
+		if strings.TrimSpace(input.Message) == "" {
+			return nil, fmt.Errorf("message is required")
+		}
+
+		var opts []gochatbot.AskOption
+		if input.ConversationID != "" {
+			opts = append(opts, gochatbot.WithContext("conversation_id", input.ConversationID))
+		}
+		if input.UserID != "" {
+			opts = append(opts, gochatbot.WithUserID(input.UserID))
+		}
+
+		reply, err := bot.Ask(ctx, input.Message, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return AskResult{Reply: reply}, nil
+	}
+}
+
+// SummarizeInput is the input of a summarize job.
+type SummarizeInput struct {
+	ConversationIDs []string `json:"conversation_ids"`
+}
+
+// SummarizeHandler summarizes a batch of conversations with
+// ConversationManager.Summarize, which stores each summary on its
+// conversation. The result maps conversation IDs to summaries; the job fails
+// if any conversation could not be summarized.
+func SummarizeHandler(conversations *database.ConversationManager) HandlerFunc {
+	return func(ctx context.Context, job *Job) (interface{}, error) {
+		var input SummarizeInput
+		if err := json.Unmarshal(job.Input, &input); err != nil {
+			return nil, fmt.Errorf("invalid summarize input: %w", err)
+		}
+		if len(input.ConversationIDs) == 0 {
+			return nil, fmt.Errorf("conversation_ids is required")
+		}
+
+		summaries := make(map[string]*database.Summary, len(input.ConversationIDs))
+		for _, id := range input.ConversationIDs {
+			summary, err := conversations.Summarize(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to summarize conversation %s: %w", id, err)
+			}
+			summaries[id] = summary
+		}
+		return summaries, nil
+	}
+}
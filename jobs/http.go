@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Handler returns the job API for the given job types. Mount it under a
+// prefix with http.StripPrefix:
+//
+//	POST /      {"type": "ask", "input": {...}, "callback_url": "...",
+//	             "run_at": "...", "max_attempts": 3} enqueues a job (202)
+//	GET  /{id}  job status and, once finished, its result or error
+//
+// If no types are given any type may be enqueued.
+func Handler(queue Queue, types ...string) http.Handler {
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[t] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Type        string          `json:"type"`
+			Input       json.RawMessage `json:"input"`
+			CallbackURL string          `json:"callback_url"`
+			RunAt       *time.Time      `json:"run_at"`
+			MaxAttempts int             `json:"max_attempts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON request")
+			return
+		}
+		if req.Type == "" {
+			writeError(w, http.StatusBadRequest, "Job type is required")
+			return
+		}
+		if len(allowed) > 0 && !allowed[req.Type] {
+			writeError(w, http.StatusBadRequest, "Unknown job type")
+			return
+		}
+
+		opts := []JobOption{WithMaxAttempts(req.MaxAttempts), WithCallbackURL(req.CallbackURL)}
+		if req.RunAt != nil {
+			opts = append(opts, WithRunAt(req.RunAt.UTC()))
+		}
+		job, err := NewJob(req.Type, nil, opts...)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		job.Input = req.Input
+
+		if err := queue.Enqueue(r.Context(), job); err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		w.Header().Set("Location", job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	})
+	mux.HandleFunc("GET /{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := queue.Get(r.Context(), r.PathValue("id"))
+		switch {
+		case errors.Is(err, ErrNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, job)
+		}
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
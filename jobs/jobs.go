@@ -0,0 +1,142 @@
+// Package jobs runs long-running chatbot work, such as deep research asks or
+// batch summarization, asynchronously on a queue of jobs processed by
+// workers. Clients enqueue a job, poll its status over HTTP and can receive a
+// signed webhook when it finishes.
+//
+//	queue := jobs.NewMemoryQueue()
+//	worker := jobs.NewWorker(queue)
+//	worker.Handle(jobs.AskJob, jobs.AskHandler(bot))
+//	go worker.Run(ctx)
+//
+//	http.Handle("/jobs/", http.StripPrefix("/jobs", jobs.Handler(queue, jobs.AskJob)))
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status is the state of a job.
+type Status string
+
+// Job states.
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+var (
+	// ErrNotFound is returned for unknown job IDs.
+	ErrNotFound = errors.New("job not found")
+	// ErrClosed is returned by a queue after Close.
+	ErrClosed = errors.New("queue is closed")
+)
+
+// Job is a unit of asynchronous work.
+type Job struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Input  json.RawMessage `json:"input,omitempty"`
+	Status Status          `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+
+	// Attempts counts runs so far; failed jobs are retried until they reach
+	// MaxAttempts.
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+
+	// CallbackURL receives the finished job as a signed webhook.
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	// RunAt schedules the job; it is not dequeued before then.
+	RunAt      time.Time  `json:"run_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// JobOption configures a new job.
+type JobOption func(*Job)
+
+// WithRunAt schedules the job for later.
+func WithRunAt(t time.Time) JobOption {
+	return func(j *Job) {
+		j.RunAt = t
+	}
+}
+
+// WithMaxAttempts sets how many times a failing job runs (default 1).
+func WithMaxAttempts(n int) JobOption {
+	return func(j *Job) {
+		if n > 0 {
+			j.MaxAttempts = n
+		}
+	}
+}
+
+// WithCallbackURL posts the finished job to url.
+func WithCallbackURL(url string) JobOption {
+	return func(j *Job) {
+		j.CallbackURL = url
+	}
+}
+
+// NewJob creates a queued job of the given type with input marshaled as JSON.
+func NewJob(jobType string, input interface{}, opts ...JobOption) (*Job, error) {
+	if jobType == "" {
+		return nil, fmt.Errorf("job type is required")
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job input: %w", err)
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:          uuid.New().String(),
+		Type:        jobType,
+		Input:       data,
+		Status:      StatusQueued,
+		MaxAttempts: 1,
+		RunAt:       now,
+		CreatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return job, nil
+}
+
+// Queue stores jobs and hands due ones to workers.
+type Queue interface {
+	// Enqueue stores a job and schedules it to run at job.RunAt.
+	Enqueue(ctx context.Context, job *Job) error
+
+	// Dequeue blocks until a job is due and claims it for the caller, or
+	// returns when ctx is done or the queue is closed.
+	Dequeue(ctx context.Context) (*Job, error)
+
+	// Update stores a job's new state. Jobs updated with StatusQueued are
+	// scheduled again.
+	Update(ctx context.Context, job *Job) error
+
+	// Get returns a job by ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Job, error)
+
+	// Close stops the queue; blocked Dequeue calls return ErrClosed.
+	Close() error
+}
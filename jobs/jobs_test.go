@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/channels"
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/config"
+)
+
+// waitFor polls the queue until the job has finished.
+func waitFor(t *testing.T, queue Queue, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := queue.Get(context.Background(), id)
+		if err == nil && job.Done() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestMemoryQueueOrdersByRunAt(t *testing.T) {
+	queue := NewMemoryQueue()
+	ctx := context.Background()
+
+	later, _ := NewJob("test", 1, WithRunAt(time.Now().Add(50*time.Millisecond)))
+	now, _ := NewJob("test", 2)
+	_ = queue.Enqueue(ctx, later)
+	_ = queue.Enqueue(ctx, now)
+
+	first, err := queue.Dequeue(ctx)
+	if err != nil || first.ID != now.ID {
+		t.Fatalf("expected due job first, got %+v, %v", first, err)
+	}
+	second, err := queue.Dequeue(ctx)
+	if err != nil || second.ID != later.ID {
+		t.Fatalf("expected scheduled job second, got %+v, %v", second, err)
+	}
+	if time.Now().Before(later.RunAt) {
+		t.Error("scheduled job was dequeued early")
+	}
+
+	if _, err := queue.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = queue.Close()
+	}()
+	if _, err := queue.Dequeue(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWorkerRetriesAndCallsBack(t *testing.T) {
+	var mutex sync.Mutex
+	var delivered *Job
+	var headers http.Header
+	var body []byte
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		body, _ = io.ReadAll(r.Body)
+		headers = r.Header
+		delivered = &Job{}
+		_ = json.Unmarshal(body, delivered)
+	}))
+	defer callback.Close()
+
+	queue := NewMemoryQueue()
+	worker := NewWorker(queue, WithRetryBackoff(time.Millisecond), WithCallbackSecret("secret"))
+	calls := 0
+	worker.Handle("flaky", func(ctx context.Context, job *Job) (interface{}, error) {
+		calls++
+		if calls < 2 {
+			return nil, errors.New("temporary failure")
+		}
+		return map[string]int{"calls": calls}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = worker.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	job, _ := NewJob("flaky", nil, WithMaxAttempts(3), WithCallbackURL(callback.URL))
+	_ = queue.Enqueue(ctx, job)
+
+	finished := waitFor(t, queue, job.ID)
+	if finished.Status != StatusSucceeded || finished.Attempts != 2 || string(finished.Result) != `{"calls":2}` {
+		t.Errorf("unexpected job: %+v", finished)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mutex.Lock()
+		got := delivered
+		mutex.Unlock()
+		if got != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if delivered == nil || delivered.ID != job.ID || delivered.Status != StatusSucceeded {
+		t.Fatalf("unexpected callback: %+v", delivered)
+	}
+	timestamp, _ := strconv.ParseInt(headers.Get(channels.WebhookTimestampHeader), 10, 64)
+	if headers.Get(channels.WebhookSignatureHeader) != channels.WebhookSignature("secret", timestamp, body) {
+		t.Error("expected a valid callback signature")
+	}
+	if headers.Get(channels.WebhookDeliveryHeader) != job.ID {
+		t.Errorf("unexpected delivery header %q", headers.Get(channels.WebhookDeliveryHeader))
+	}
+}
+
+func TestWorkerFailsJob(t *testing.T) {
+	queue := NewMemoryQueue()
+	worker := NewWorker(queue)
+	worker.Handle("panics", func(ctx context.Context, job *Job) (interface{}, error) {
+		panic("boom")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = worker.Run(ctx) }()
+
+	unknown, _ := NewJob("unknown", nil)
+	panics, _ := NewJob("panics", nil)
+	_ = queue.Enqueue(ctx, unknown)
+	_ = queue.Enqueue(ctx, panics)
+
+	if job := waitFor(t, queue, unknown.ID); job.Status != StatusFailed || !strings.Contains(job.Error, "no handler") {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if job := waitFor(t, queue, panics.ID); job.Status != StatusFailed || !strings.Contains(job.Error, "boom") {
+		t.Errorf("unexpected job: %+v", job)
+	}
+}
+
+func TestAskJobOverHTTP(t *testing.T) {
+	model := chatbottest.NewMockModel("Here is your research.")
+	bot, err := gochatbot.New(config.Default(), gochatbot.WithModel(model))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	queue := NewMemoryQueue()
+	worker := NewWorker(queue)
+	worker.Handle(AskJob, AskHandler(bot))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = worker.Run(ctx) }()
+
+	server := httptest.NewServer(Handler(queue, AskJob))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/", "application/json", strings.NewReader(`{"type":"summarize","input":{}}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a disallowed type, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/", "application/json",
+		strings.NewReader(`{"type":"ask","input":{"message":"Research Go generics","conversation_id":"c1"}}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var accepted Job
+	_ = json.NewDecoder(resp.Body).Decode(&accepted)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || accepted.ID == "" || accepted.Status != StatusQueued {
+		t.Fatalf("unexpected response %d: %+v", resp.StatusCode, accepted)
+	}
+
+	waitFor(t, queue, accepted.ID)
+	resp, err = http.Get(server.URL + "/" + accepted.ID)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var job Job
+	_ = json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+
+	var result AskResult
+	_ = json.Unmarshal(job.Result, &result)
+	if job.Status != StatusSucceeded || result.Reply != "Here is your research." {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if calls := model.Calls(); len(calls) != 1 || calls[0].Context["conversation_id"] != "c1" {
+		t.Errorf("unexpected model calls: %+v", calls)
+	}
+
+	resp, err = http.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", resp.StatusCode)
+	}
+}
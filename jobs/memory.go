@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryQueue is an in-process Queue. Jobs are lost when the process exits,
+// so use it for development, tests and single-instance deployments.
+type MemoryQueue struct {
+	mutex   sync.Mutex
+	jobs    map[string]*Job
+	pending []string // IDs of queued jobs, ordered by RunAt
+	wake    chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryQueue creates an empty in-memory queue.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		jobs:   make(map[string]*Job),
+		wake:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+// Enqueue implements Queue.
+func (q *MemoryQueue) Enqueue(ctx context.Context, job *Job) error {
+	return q.Update(ctx, job)
+}
+
+// Dequeue implements Queue.
+func (q *MemoryQueue) Dequeue(ctx context.Context) (*Job, error) {
+	for {
+		job, wait := q.next()
+		if job != nil {
+			return job, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-q.closed:
+			timer.Stop()
+			return nil, ErrClosed
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// next pops the first due job, or reports how long until one may be due.
+func (q *MemoryQueue) next() (*Job, time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, time.Minute
+	}
+
+	job := q.jobs[q.pending[0]]
+	if wait := time.Until(job.RunAt); wait > 0 {
+		return nil, wait
+	}
+	q.pending = q.pending[1:]
+	return copyJob(job), 0
+}
+
+// Update implements Queue.
+func (q *MemoryQueue) Update(ctx context.Context, job *Job) error {
+	select {
+	case <-q.closed:
+		return ErrClosed
+	default:
+	}
+
+	q.mutex.Lock()
+	q.jobs[job.ID] = copyJob(job)
+	q.removePending(job.ID)
+	if job.Status == StatusQueued {
+		q.pending = append(q.pending, job.ID)
+		sort.SliceStable(q.pending, func(i, j int) bool {
+			return q.jobs[q.pending[i]].RunAt.Before(q.jobs[q.pending[j]].RunAt)
+		})
+	}
+	q.mutex.Unlock()
+
+	// Wake a waiting Dequeue to look at the new schedule
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// removePending drops a job from the schedule.
+func (q *MemoryQueue) removePending(id string) {
+	for i, pending := range q.pending {
+		if pending == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get implements Queue.
+func (q *MemoryQueue) Get(ctx context.Context, id string) (*Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyJob(job), nil
+}
+
+// Close implements Queue.
+func (q *MemoryQueue) Close() error {
+	q.once.Do(func() { close(q.closed) })
+	return nil
+}
+
+// copyJob returns a copy that shares no mutable state with job.
+func copyJob(job *Job) *Job {
+	c := *job
+	c.Input = append([]byte(nil), job.Input...)
+	c.Result = append([]byte(nil), job.Result...)
+	if job.StartedAt != nil {
+		t := *job.StartedAt
+		c.StartedAt = &t
+	}
+	if job.FinishedAt != nil {
+		t := *job.FinishedAt
+		c.FinishedAt = &t
+	}
+	return &c
+}
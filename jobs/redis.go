@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/internal/redis"
+)
+
+// RedisConfig configures the Redis queue.
+type RedisConfig struct {
+	// URL is the server address, e.g. "redis://:password@localhost:6379/0".
+	// Use the "rediss" scheme for TLS connections.
+	URL string
+	// KeyPrefix namespaces the queue's keys (default "chatbot:jobs").
+	KeyPrefix string
+	// PollInterval is how often idle workers check for due jobs (default 1s).
+	PollInterval time.Duration
+	// ResultTTL expires finished jobs after the given time (default: never).
+	ResultTTL time.Duration
+	// DialTimeout bounds connecting to the server (default 5s).
+	DialTimeout time.Duration
+	// TLSConfig is used for "rediss" URLs.
+	TLSConfig *tls.Config
+}
+
+// RedisQueue is a Queue stored in Redis, so jobs survive restarts and can be
+// shared by workers on several machines. Jobs are stored as JSON strings and
+// scheduled in a sorted set scored by RunAt; a worker claims a job by
+// removing it from the set, so each job is handed to one worker only.
+type RedisQueue struct {
+	config RedisConfig
+	client *redis.Client
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewRedisQueue creates a Redis queue. The connection is opened on first use.
+func NewRedisQueue(cfg RedisConfig) (*RedisQueue, error) {
+	if cfg.URL == "" {
+		cfg.URL = "redis://localhost:6379"
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "chatbot:jobs"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+
+	client, err := redis.New(cfg.URL, cfg.DialTimeout, cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisQueue{config: cfg, client: client, closed: make(chan struct{})}, nil
+}
+
+func (q *RedisQueue) jobKey(id string) string {
+	return q.config.KeyPrefix + ":job:" + id
+}
+
+func (q *RedisQueue) queueKey() string {
+	return q.config.KeyPrefix + ":queue"
+}
+
+// Enqueue implements Queue.
+func (q *RedisQueue) Enqueue(ctx context.Context, job *Job) error {
+	return q.Update(ctx, job)
+}
+
+// Update implements Queue.
+func (q *RedisQueue) Update(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	set := []string{"SET", q.jobKey(job.ID), string(data)}
+	if job.Done() && q.config.ResultTTL > 0 {
+		set = append(set, "PX", strconv.FormatInt(q.config.ResultTTL.Milliseconds(), 10))
+	}
+	schedule := []string{"ZREM", q.queueKey(), job.ID}
+	if job.Status == StatusQueued {
+		schedule = []string{"ZADD", q.queueKey(), strconv.FormatInt(job.RunAt.UnixMilli(), 10), job.ID}
+	}
+
+	replies, err := q.do(ctx, []string{"MULTI"}, set, schedule, []string{"EXEC"})
+	if err != nil {
+		return fmt.Errorf("failed to store job: %w", err)
+	}
+	if replies[3] == nil {
+		return fmt.Errorf("failed to store job: transaction aborted")
+	}
+	return nil
+}
+
+// Dequeue implements Queue.
+func (q *RedisQueue) Dequeue(ctx context.Context) (*Job, error) {
+	for {
+		job, err := q.claim(ctx)
+		if err != nil || job != nil {
+			return job, err
+		}
+
+		timer := time.NewTimer(q.config.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-q.closed:
+			timer.Stop()
+			return nil, ErrClosed
+		case <-timer.C:
+		}
+	}
+}
+
+// claim removes the first due job from the schedule and loads it. It returns
+// nil if no job is due.
+func (q *RedisQueue) claim(ctx context.Context) (*Job, error) {
+	select {
+	case <-q.closed:
+		return nil, ErrClosed
+	default:
+	}
+
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	replies, err := q.do(ctx, []string{"ZRANGEBYSCORE", q.queueKey(), "-inf", now, "LIMIT", "0", "1"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to poll queue: %w", err)
+	}
+	ids, _ := replies[0].([]interface{})
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	id, _ := ids[0].(string)
+
+	// Another worker may claim the same job first
+	replies, err = q.do(ctx, []string{"ZREM", q.queueKey(), id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if removed, _ := replies[0].(int64); removed == 0 {
+		return nil, nil
+	}
+
+	job, err := q.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		// The job expired or was deleted while scheduled
+		return nil, nil
+	}
+	return job, err
+}
+
+// Get implements Queue.
+func (q *RedisQueue) Get(ctx context.Context, id string) (*Job, error) {
+	replies, err := q.do(ctx, []string{"GET", q.jobKey(id)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	data, ok := replies[0].(string)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	return &job, nil
+}
+
+// Close implements Queue.
+func (q *RedisQueue) Close() error {
+	q.once.Do(func() { close(q.closed) })
+	return q.client.Close()
+}
+
+// do sends the commands in one round trip and returns their replies. Error
+// replies are returned as errors.
+func (q *RedisQueue) do(ctx context.Context, commands ...[]string) ([]interface{}, error) {
+	replies, err := q.client.Pipeline(ctx, commands...)
+	if errors.Is(err, redis.ErrClosed) {
+		return nil, ErrClosed
+	}
+	return replies, err
+}
@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis implements the handful of Redis commands the queue uses.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mutex    sync.Mutex
+	strings  map[string]string
+	zset     map[string]float64
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeRedis{
+		listener: listener,
+		password: password,
+		strings:  make(map[string]string),
+		zset:     make(map[string]float64),
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+	var queued [][]string
+	inMulti := false
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.commands = append(s.commands, args[0])
+		s.mutex.Unlock()
+
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authed = args[len(args)-1] == s.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "MULTI":
+			inMulti = true
+			reply = "+OK\r\n"
+		case args[0] == "EXEC":
+			inMulti = false
+			reply = fmt.Sprintf("*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				reply += s.exec(cmd)
+			}
+			queued = nil
+		case inMulti:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = s.exec(args)
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) exec(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch args[0] {
+	case "SET":
+		s.strings[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.strings[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "ZADD":
+		score, _ := strconv.ParseFloat(args[2], 64)
+		s.zset[args[3]] = score
+		return ":1\r\n"
+	case "ZREM":
+		if _, ok := s.zset[args[2]]; !ok {
+			return ":0\r\n"
+		}
+		delete(s.zset, args[2])
+		return ":1\r\n"
+	case "ZRANGEBYSCORE":
+		max, _ := strconv.ParseFloat(args[3], 64)
+		var members []string
+		for member, score := range s.zset {
+			if score <= max {
+				members = append(members, member)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool { return s.zset[members[i]] < s.zset[members[j]] })
+		if len(members) > 1 {
+			members = members[:1]
+		}
+		reply := fmt.Sprintf("*%d\r\n", len(members))
+		for _, member := range members {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(member), member)
+		}
+		return reply
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisQueue(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	queue, err := NewRedisQueue(RedisConfig{
+		URL:          "redis://:secret@" + server.listener.Addr().String(),
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+	defer queue.Close()
+	ctx := context.Background()
+
+	later, _ := NewJob("ask", map[string]string{"message": "later"}, WithRunAt(time.Now().Add(50*time.Millisecond)))
+	now, _ := NewJob("ask", map[string]string{"message": "now"})
+	if err := queue.Enqueue(ctx, later); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := queue.Enqueue(ctx, now); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+
+	first, err := queue.Dequeue(ctx)
+	if err != nil || first.ID != now.ID || string(first.Input) != `{"message":"now"}` {
+		t.Fatalf("expected due job first, got %+v, %v", first, err)
+	}
+	second, err := queue.Dequeue(ctx)
+	if err != nil || second.ID != later.ID {
+		t.Fatalf("expected scheduled job second, got %+v, %v", second, err)
+	}
+
+	second.Status = StatusSucceeded
+	second.Result = []byte(`{"reply":"done"}`)
+	if err := queue.Update(ctx, second); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	stored, err := queue.Get(ctx, later.ID)
+	if err != nil || stored.Status != StatusSucceeded || string(stored.Result) != `{"reply":"done"}` {
+		t.Errorf("unexpected stored job: %+v, %v", stored, err)
+	}
+	if _, err := queue.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	server.mutex.Lock()
+	auth := server.commands[0]
+	server.mutex.Unlock()
+	if auth != "AUTH" {
+		t.Errorf("expected AUTH first, got %s", auth)
+	}
+
+	_ = queue.Close()
+	if _, err := queue.Dequeue(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestRedisQueueErrors(t *testing.T) {
+	if _, err := NewRedisQueue(RedisConfig{URL: "http://localhost"}); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+
+	server := newFakeRedis(t, "secret")
+	queue, _ := NewRedisQueue(RedisConfig{URL: "redis://:wrong@" + server.listener.Addr().String()})
+	defer queue.Close()
+
+	job, _ := NewJob("ask", nil)
+	err := queue.Enqueue(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/channels"
+)
+
+// HandlerFunc processes a job and returns its result, which is stored as
+// JSON. Returning an error fails the attempt.
+type HandlerFunc func(ctx context.Context, job *Job) (interface{}, error)
+
+// WorkerOption configures a Worker.
+type WorkerOption func(*Worker)
+
+// WithConcurrency sets how many jobs run at once (default 4).
+func WithConcurrency(n int) WorkerOption {
+	return func(w *Worker) {
+		if n > 0 {
+			w.concurrency = n
+		}
+	}
+}
+
+// WithJobTimeout bounds the time one attempt may take (default 10m).
+func WithJobTimeout(timeout time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.timeout = timeout
+	}
+}
+
+// WithRetryBackoff sets the delay before retrying a failed job, multiplied
+// by the number of attempts so far (default 30s).
+func WithRetryBackoff(backoff time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.backoff = backoff
+	}
+}
+
+// WithCallbackSecret signs completion webhooks like channels.NewWebhook does,
+// so receivers can verify them with channels.WebhookSignature.
+func WithCallbackSecret(secret string) WorkerOption {
+	return func(w *Worker) {
+		w.secret = secret
+	}
+}
+
+// WithHTTPClient sets the client used for completion webhooks.
+func WithHTTPClient(client *http.Client) WorkerOption {
+	return func(w *Worker) {
+		w.client = client
+	}
+}
+
+// WithErrorHandler sets a function called with queue and webhook errors. The
+// job is nil for errors not tied to one. By default errors are logged.
+func WithErrorHandler(fn func(job *Job, err error)) WorkerOption {
+	return func(w *Worker) {
+		w.onError = fn
+	}
+}
+
+// Worker takes jobs from a queue and runs the handler registered for their
+// type.
+type Worker struct {
+	queue       Queue
+	handlers    map[string]HandlerFunc
+	concurrency int
+	timeout     time.Duration
+	backoff     time.Duration
+	secret      string
+	client      *http.Client
+	onError     func(job *Job, err error)
+	mutex       sync.RWMutex
+}
+
+// NewWorker creates a worker for queue.
+func NewWorker(queue Queue, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		queue:       queue,
+		handlers:    make(map[string]HandlerFunc),
+		concurrency: 4,
+		timeout:     10 * time.Minute,
+		backoff:     30 * time.Second,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		onError: func(job *Job, err error) {
+			if job != nil {
+				log.Printf("jobs: job %s (%s): %v", job.ID, job.Type, err)
+				return
+			}
+			log.Printf("jobs: %v", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Handle registers the handler for a job type.
+func (w *Worker) Handle(jobType string, handler HandlerFunc) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.handlers[jobType] = handler
+}
+
+// Run processes jobs until ctx is done or the queue is closed. Jobs already
+// running are allowed to finish, bounded by the job timeout.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// loop dequeues and processes jobs one at a time.
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		job, err := w.queue.Dequeue(ctx)
+		switch {
+		case ctx.Err() != nil || errors.Is(err, ErrClosed):
+			return
+		case err != nil:
+			w.onError(nil, err)
+			// Back off so an unavailable queue is not hammered
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		// Finish the job even if the worker is being stopped
+		w.process(context.WithoutCancel(ctx), job)
+	}
+}
+
+// process runs one attempt of a job and records the outcome.
+func (w *Worker) process(ctx context.Context, job *Job) {
+	now := time.Now().UTC()
+	job.Status = StatusRunning
+	job.Attempts++
+	job.StartedAt = &now
+	if err := w.queue.Update(ctx, job); err != nil {
+		w.onError(job, err)
+	}
+
+	result, err := w.run(ctx, job)
+	finished := time.Now().UTC()
+	switch {
+	case err != nil && job.Attempts < job.MaxAttempts:
+		job.Status = StatusQueued
+		job.Error = err.Error()
+		job.RunAt = finished.Add(w.backoff * time.Duration(job.Attempts))
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		job.FinishedAt = &finished
+	default:
+		job.Status = StatusSucceeded
+		job.Error = ""
+		job.Result = result
+		job.FinishedAt = &finished
+	}
+
+	if err := w.queue.Update(ctx, job); err != nil {
+		w.onError(job, err)
+	}
+	if job.Done() && job.CallbackURL != "" {
+		if err := w.notify(ctx, job); err != nil {
+			w.onError(job, err)
+		}
+	}
+}
+
+// run calls the job's handler, recovering from panics.
+func (w *Worker) run(ctx context.Context, job *Job) (result json.RawMessage, err error) {
+	w.mutex.RLock()
+	handler, ok := w.handlers[job.Type]
+	w.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no handler for job type %q", job.Type)
+	}
+
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+
+	value, err := handler(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job result: %w", err)
+	}
+	return data, nil
+}
+
+// notify posts the finished job to its callback URL.
+func (w *Worker) notify(ctx context.Context, job *Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(channels.WebhookDeliveryHeader, job.ID)
+	if w.secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(channels.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(channels.WebhookSignatureHeader, channels.WebhookSignature(w.secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send callback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("callback returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package knowledge
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Extensions lists the file types picked up when walking directories.
+var Extensions = map[string]bool{
+	".md": true, ".markdown": true, ".txt": true, ".rst": true, ".html": true,
+}
+
+// Files expands directories into the supported files they contain.
+func Files(paths []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && Extensions[strings.ToLower(filepath.Ext(path))] {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Chunk splits text into chunks of at most size characters, breaking on
+// paragraph boundaries where possible.
+func Chunk(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+len(paragraph)+2 > size {
+			flush()
+		}
+
+		// Split paragraphs that are too long on their own
+		for len(paragraph) > size {
+			cut := strings.LastIndex(paragraph[:size], " ")
+			if cut <= 0 {
+				cut = size
+				for cut > 0 && !utf8.RuneStart(paragraph[cut]) {
+					cut--
+				}
+				if cut == 0 {
+					_, cut = utf8.DecodeRuneInString(paragraph)
+				}
+			}
+			current.WriteString(paragraph[:cut])
+			flush()
+			paragraph = strings.TrimSpace(paragraph[cut:])
+		}
+
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(paragraph)
+	}
+	flush()
+
+	return chunks
+}
@@ -0,0 +1,146 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/tools/web"
+)
+
+var (
+	// ErrNotModified is returned by a Fetcher when the document has not
+	// changed since the source's validators were recorded.
+	ErrNotModified = errors.New("document not modified")
+	// ErrNotFound is matched by the errors of documents that no longer
+	// exist.
+	ErrNotFound = errors.New("document not found")
+)
+
+// maxDocumentBytes caps the size of a fetched document.
+const maxDocumentBytes = 10 << 20
+
+// Document is the fetched text of a source.
+type Document struct {
+	Text string
+	// ETag and LastModified are the validators to send on the next fetch.
+	ETag         string
+	LastModified string
+}
+
+// Fetcher fetches the current text of a source.
+type Fetcher interface {
+	// Fetch returns the document, or ErrNotModified if the source's
+	// validators show it has not changed.
+	Fetch(ctx context.Context, source Source) (*Document, error)
+}
+
+// FetcherFunc adapts a function to Fetcher.
+type FetcherFunc func(ctx context.Context, source Source) (*Document, error)
+
+// Fetch implements Fetcher.
+func (f FetcherFunc) Fetch(ctx context.Context, source Source) (*Document, error) {
+	return f(ctx, source)
+}
+
+// DefaultFetcher fetches http(s) URLs with HTTPFetcher and everything
+// else from the file system with FileFetcher.
+func DefaultFetcher() Fetcher {
+	files, pages := FileFetcher(), HTTPFetcher(nil)
+	return FetcherFunc(func(ctx context.Context, source Source) (*Document, error) {
+		if strings.HasPrefix(source.ID, "http://") || strings.HasPrefix(source.ID, "https://") {
+			return pages.Fetch(ctx, source)
+		}
+		return files.Fetch(ctx, source)
+	})
+}
+
+// FileFetcher reads sources from the file system, using the modification
+// time as the validator. HTML files are reduced to their text.
+func FileFetcher() Fetcher {
+	return FetcherFunc(func(ctx context.Context, source Source) (*Document, error) {
+		info, err := os.Stat(source.ID)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, source.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		modified := info.ModTime().UTC().Format(time.RFC3339Nano)
+		if modified == source.LastModified {
+			return nil, ErrNotModified
+		}
+		data, err := os.ReadFile(source.ID) // #nosec G304 -- sources are chosen by the operator
+		if err != nil {
+			return nil, err
+		}
+		text := string(data)
+		if ext := strings.ToLower(filepath.Ext(source.ID)); ext == ".html" || ext == ".htm" {
+			text = htmlText(text)
+		}
+		return &Document{Text: text, LastModified: modified}, nil
+	})
+}
+
+// HTTPFetcher fetches http(s) sources with conditional requests, so
+// unchanged pages cost no download. HTML pages are reduced to their text.
+// A nil client uses http.DefaultClient.
+func HTTPFetcher(client *http.Client) Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return FetcherFunc(func(ctx context.Context, source Source) (*Document, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.ID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("User-Agent", web.UserAgent)
+		if source.ETag != "" {
+			req.Header.Set("If-None-Match", source.ETag)
+		}
+		if source.LastModified != "" {
+			req.Header.Set("If-Modified-Since", source.LastModified)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", source.ID, err)
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotModified:
+			return nil, ErrNotModified
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			return nil, fmt.Errorf("%w: %s returned status %d", ErrNotFound, source.ID, resp.StatusCode)
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			return nil, fmt.Errorf("%s returned status %d", source.ID, resp.StatusCode)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", source.ID, err)
+		}
+		text := string(body)
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+			text = htmlText(text)
+		}
+		return &Document{Text: text, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+	})
+}
+
+// htmlText returns the title and readable text of an HTML page.
+func htmlText(page string) string {
+	title, text := web.PageText(page)
+	if title == "" {
+		return text
+	}
+	return title + "\n\n" + text
+}
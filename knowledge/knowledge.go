@@ -0,0 +1,104 @@
+// Package knowledge keeps a knowledge base in step with the documents it
+// was built from. A Registry records each source with the checksum of its
+// text and its HTTP validators, and a Refresher re-fetches the sources on
+// an interval, re-embeds the ones that changed and marks the chunks of
+// sources it could not check as stale, so the corpus does not silently
+// drift out of date.
+//
+//	store := embeddings.NewVectorStore(provider)
+//	refresher := knowledge.NewRefresher(store, knowledge.NewMemoryRegistry(),
+//		knowledge.WithInterval(6*time.Hour))
+//	refresher.Add(ctx, "docs/", "https://example.com/pricing")
+//	go refresher.Run(ctx)
+//
+//	bot, err := gochatbot.New(cfg, gochatbot.WithRetriever(gochatbot.KnowledgeRetriever(store), 5))
+package knowledge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Source is a document the knowledge base is built from.
+type Source struct {
+	// ID is the file path or http(s) URL of the document.
+	ID string `json:"id"`
+	// Checksum is the SHA-256 of the text last embedded, and ETag and
+	// LastModified the validators it was fetched with.
+	Checksum     string `json:"checksum,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Chunks is how many chunks the document was split into.
+	Chunks int `json:"chunks"`
+	// CheckedAt is when the source was last fetched, and UpdatedAt when
+	// its chunks were last embedded.
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// Stale is set when the last check failed, so the chunks may no
+	// longer match the document; Error says why.
+	Stale bool   `json:"stale,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Registry keeps the sources of a knowledge base.
+type Registry interface {
+	// Get returns the source with the ID, or nil if there is none.
+	Get(ctx context.Context, id string) (*Source, error)
+	Put(ctx context.Context, source Source) error
+	Delete(ctx context.Context, id string) error
+	// List returns the sources ordered by ID.
+	List(ctx context.Context) ([]Source, error)
+}
+
+// MemoryRegistry is a Registry in memory. With an in-memory vector store,
+// which is rebuilt on start, it needs no persistence.
+type MemoryRegistry struct {
+	mu      sync.Mutex
+	sources map[string]Source
+}
+
+// NewMemoryRegistry creates an empty MemoryRegistry.
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{sources: make(map[string]Source)}
+}
+
+// Get implements Registry.
+func (r *MemoryRegistry) Get(ctx context.Context, id string) (*Source, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	source, ok := r.sources[id]
+	if !ok {
+		return nil, nil
+	}
+	return &source, nil
+}
+
+// Put implements Registry.
+func (r *MemoryRegistry) Put(ctx context.Context, source Source) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[source.ID] = source
+	return nil
+}
+
+// Delete implements Registry.
+func (r *MemoryRegistry) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sources, id)
+	return nil
+}
+
+// List implements Registry.
+func (r *MemoryRegistry) List(ctx context.Context) ([]Source, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := make([]Source, 0, len(r.sources))
+	for _, source := range r.sources {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].ID < sources[j].ID })
+	return sources, nil
+}
@@ -0,0 +1,220 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// countingEmbeddings embeds every text as the same vector and counts the
+// texts it embedded.
+type countingEmbeddings struct {
+	texts int
+	fail  bool
+}
+
+func (e *countingEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	if e.fail {
+		return nil, errors.New("embedding service down")
+	}
+	e.texts += len(texts)
+	vectors := make([]embeddings.Vector, len(texts))
+	for i := range texts {
+		vectors[i] = embeddings.Vector{1, 0}
+	}
+	return vectors, nil
+}
+
+func (e *countingEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	return embeddings.Vector{1, 0}, nil
+}
+
+func (e *countingEmbeddings) Dimensions() int  { return 2 }
+func (e *countingEmbeddings) Model() string    { return "counting" }
+func (e *countingEmbeddings) Provider() string { return "test" }
+
+func TestRefresher(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingEmbeddings{}
+	store := embeddings.NewVectorStore(provider)
+	pages := map[string]string{
+		"a.md": "Alpha.\n\nMore alpha.",
+		"b.md": "Beta.",
+	}
+	var fetchErr error
+	fetcher := FetcherFunc(func(ctx context.Context, source Source) (*Document, error) {
+		if fetchErr != nil && source.ID == "b.md" {
+			return nil, fetchErr
+		}
+		return &Document{Text: pages[source.ID]}, nil
+	})
+	registry := NewMemoryRegistry()
+	refresher := NewRefresher(store, registry, WithFetcher(fetcher), WithChunkSize(12))
+	if err := refresher.Add(ctx, "a.md", "b.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := refresher.Refresh(ctx)
+	if err != nil || report.Checked != 2 || report.Updated != 2 {
+		t.Fatalf("expected both sources to be embedded, got %+v, %v", report, err)
+	}
+	if store.Count() != 3 || provider.texts != 3 {
+		t.Fatalf("expected 3 chunks, got %d stored and %d embedded", store.Count(), provider.texts)
+	}
+	chunks := store.Find(from("a.md"))
+	if len(chunks) != 2 || chunks[1][MetadataID] != "a.md#1" || chunks[1][MetadataText] != "More alpha." {
+		t.Errorf("unexpected chunks: %v", chunks)
+	}
+
+	// Unchanged text is not embedded again
+	pages["a.md"] = "Alpha.\n\nNew alpha."
+	report, _ = refresher.Refresh(ctx)
+	if report.Updated != 1 || report.Unchanged != 1 || provider.texts != 5 || store.Count() != 3 {
+		t.Errorf("expected only the changed source to be embedded, got %+v with %d texts", report, provider.texts)
+	}
+
+	// Sources that cannot be checked keep their chunks, marked stale
+	fetchErr = errors.New("connection refused")
+	report, _ = refresher.Refresh(ctx)
+	if report.Failed != 1 || report.Errors["b.md"] != fetchErr {
+		t.Errorf("expected b.md to fail, got %+v", report)
+	}
+	if chunks := store.Find(from("b.md")); len(chunks) != 1 || chunks[0][MetadataStale] != true {
+		t.Errorf("expected the chunk to be marked stale, got %v", chunks)
+	}
+	if source, _ := registry.Get(ctx, "b.md"); !source.Stale || source.Error != "connection refused" {
+		t.Errorf("expected the source to be stale, got %+v", source)
+	}
+
+	fetchErr = nil
+	refresher.Refresh(ctx)
+	if chunks := store.Find(from("b.md")); chunks[0][MetadataStale] != false {
+		t.Errorf("expected the stale mark to be cleared, got %v", chunks)
+	}
+
+	// Embedding failures keep the old chunks, marked stale
+	pages["a.md"] = "Alpha changed again."
+	provider.fail = true
+	report, _ = refresher.Refresh(ctx)
+	if report.Failed != 1 || store.Count() != 3 {
+		t.Errorf("expected the old chunks to be kept, got %+v and %d chunks", report, store.Count())
+	}
+	if source, _ := registry.Get(ctx, "a.md"); !source.Stale {
+		t.Errorf("expected the source to be stale, got %+v", source)
+	}
+
+	if err := refresher.Remove(ctx, "a.md"); err != nil || store.Count() != 1 {
+		t.Errorf("expected the source's chunks to be removed, got %d chunks, %v", store.Count(), err)
+	}
+}
+
+func TestRefresherRemoveMissing(t *testing.T) {
+	ctx := context.Background()
+	store := embeddings.NewVectorStore(&countingEmbeddings{})
+	missing := false
+	fetcher := FetcherFunc(func(ctx context.Context, source Source) (*Document, error) {
+		if missing {
+			return nil, ErrNotFound
+		}
+		return &Document{Text: "Gone soon."}, nil
+	})
+	refresher := NewRefresher(store, NewMemoryRegistry(), WithFetcher(fetcher), WithRemoveMissing(true))
+	refresher.Add(ctx, "old.md")
+	refresher.Refresh(ctx)
+	missing = true
+	if report, _ := refresher.Refresh(ctx); report.Failed != 1 || store.Count() != 0 {
+		t.Errorf("expected the missing source's chunks to be removed, got %+v and %d chunks", report, store.Count())
+	}
+}
+
+func TestFileFetcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.html")
+	os.WriteFile(path, []byte("<html><head><title>Hours</title></head><body><p>Open at nine.</p></body></html>"), 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("Notes."), 0o600)
+	os.WriteFile(filepath.Join(dir, "image.png"), []byte{0x89}, 0o600)
+
+	fetcher := FileFetcher()
+	document, err := fetcher.Fetch(context.Background(), Source{ID: path})
+	if err != nil || document.Text != "Hours\n\nOpen at nine." || document.LastModified == "" {
+		t.Fatalf("unexpected document %+v, %v", document, err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), Source{ID: path, LastModified: document.LastModified}); !errors.Is(err, ErrNotModified) {
+		t.Errorf("expected ErrNotModified, got %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), Source{ID: filepath.Join(dir, "gone.md")}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	registry := NewMemoryRegistry()
+	refresher := NewRefresher(embeddings.NewVectorStore(&countingEmbeddings{}), registry)
+	if err := refresher.Add(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if sources, _ := registry.List(context.Background()); len(sources) != 2 || sources[1].ID != path {
+		t.Errorf("expected the supported files to be added, got %+v", sources)
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pricing":
+			if r.Header.Get("If-None-Match") == `"v2"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v2"`)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<p>Pro costs $20.</p><script>track()</script>"))
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := DefaultFetcher()
+	document, err := fetcher.Fetch(context.Background(), Source{ID: server.URL + "/pricing"})
+	if err != nil || document.Text != "Pro costs $20." || document.ETag != `"v2"` {
+		t.Fatalf("unexpected document %+v, %v", document, err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), Source{ID: server.URL + "/pricing", ETag: `"v2"`}); !errors.Is(err, ErrNotModified) {
+		t.Errorf("expected ErrNotModified, got %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), Source{ID: server.URL + "/old"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), Source{ID: server.URL + "/broken"}); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("expected the status error, got %v", err)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	text := "First paragraph.\n\nSecond paragraph is here.\n\n" + strings.Repeat("word ", 30)
+
+	chunks := Chunk(text, 50)
+	for _, chunk := range chunks {
+		if len(chunk) > 50 {
+			t.Errorf("chunk longer than 50 bytes: %q", chunk)
+		}
+	}
+	if chunks[0] != "First paragraph.\n\nSecond paragraph is here." {
+		t.Errorf("unexpected first chunk %q", chunks[0])
+	}
+
+	// Multi-byte text without spaces is split on rune boundaries
+	for _, chunk := range Chunk(strings.Repeat("é", 10), 3) {
+		if len([]rune(chunk)) < 1 || strings.ToValidUTF8(chunk, "?") != chunk {
+			t.Errorf("invalid chunk %q", chunk)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package knowledge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// Chunk metadata keys set on the vector store entries of a source.
+const (
+	MetadataID     = "id"
+	MetadataSource = "source"
+	MetadataText   = "text"
+	MetadataStale  = "stale"
+)
+
+// Report summarizes a refresh.
+type Report struct {
+	// Checked counts the sources fetched; each is also counted as
+	// Unchanged, Updated or Failed.
+	Checked   int
+	Unchanged int
+	Updated   int
+	Failed    int
+	// Errors holds the error of each failed source by ID.
+	Errors map[string]error
+}
+
+// Option configures a Refresher.
+type Option func(*Refresher)
+
+// WithFetcher sets how sources are fetched, DefaultFetcher by default.
+func WithFetcher(fetcher Fetcher) Option {
+	return func(r *Refresher) {
+		r.fetcher = fetcher
+	}
+}
+
+// WithChunkSize sets the maximum characters per chunk, 1000 by default.
+func WithChunkSize(size int) Option {
+	return func(r *Refresher) {
+		r.chunkSize = size
+	}
+}
+
+// WithInterval sets how often Run refreshes, hourly by default.
+func WithInterval(interval time.Duration) Option {
+	return func(r *Refresher) {
+		r.interval = interval
+	}
+}
+
+// WithRemoveMissing removes the chunks of sources that no longer exist,
+// instead of keeping them marked stale.
+func WithRemoveMissing(remove bool) Option {
+	return func(r *Refresher) {
+		r.removeMissing = remove
+	}
+}
+
+// WithOnRefresh sets a function called after each refresh Run makes, for
+// example to log the report or alert on failures.
+func WithOnRefresh(fn func(report Report, err error)) Option {
+	return func(r *Refresher) {
+		r.onRefresh = fn
+	}
+}
+
+// Refresher re-fetches the sources of a knowledge base and re-embeds the
+// ones whose text changed into a vector store. Chunks are identified by
+// their source and position, like "docs/faq.md#2", and carry the "id",
+// "source" and "text" metadata that gochatbot.KnowledgeRetriever reads.
+// Chunks of sources that could not be checked are kept with the "stale"
+// metadata set, until a later refresh succeeds.
+type Refresher struct {
+	store         *embeddings.VectorStore
+	registry      Registry
+	fetcher       Fetcher
+	chunkSize     int
+	interval      time.Duration
+	removeMissing bool
+	onRefresh     func(Report, error)
+	mu            sync.Mutex // one refresh at a time
+}
+
+// NewRefresher creates a refresher of store with the sources in registry.
+func NewRefresher(store *embeddings.VectorStore, registry Registry, opts ...Option) *Refresher {
+	r := &Refresher{
+		store:     store,
+		registry:  registry,
+		fetcher:   DefaultFetcher(),
+		chunkSize: 1000,
+		interval:  time.Hour,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Add registers sources, expanding directories into the files they
+// contain. They are fetched and embedded on the next refresh. Sources
+// already registered are left as they are.
+func (r *Refresher) Add(ctx context.Context, ids ...string) error {
+	for _, id := range ids {
+		files := []string{id}
+		if info, err := os.Stat(id); err == nil && info.IsDir() {
+			if files, err = Files([]string{id}); err != nil {
+				return fmt.Errorf("failed to list %s: %w", id, err)
+			}
+		}
+		for _, file := range files {
+			existing, err := r.registry.Get(ctx, file)
+			if err != nil {
+				return fmt.Errorf("failed to load source: %w", err)
+			}
+			if existing != nil {
+				continue
+			}
+			if err := r.registry.Put(ctx, Source{ID: file}); err != nil {
+				return fmt.Errorf("failed to save source: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Remove unregisters a source and removes its chunks.
+func (r *Refresher) Remove(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store.Delete(from(id))
+	if err := r.registry.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+	return nil
+}
+
+// Run refreshes now and then on the interval until ctx is done.
+func (r *Refresher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		report, err := r.Refresh(ctx)
+		if r.onRefresh != nil {
+			r.onRefresh(report, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh checks every source once. A source failing is recorded in the
+// report and does not stop the others; the error is for the registry
+// failing.
+func (r *Refresher) Refresh(ctx context.Context) (Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := Report{Errors: make(map[string]error)}
+	sources, err := r.registry.List(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list sources: %w", err)
+	}
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		report.Checked++
+		updated, err := r.refresh(ctx, &source)
+		switch {
+		case err != nil:
+			report.Failed++
+			report.Errors[source.ID] = err
+		case updated:
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+		if err := r.registry.Put(ctx, source); err != nil {
+			return report, fmt.Errorf("failed to save source: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// refresh fetches a source and re-embeds it if its text changed, updating
+// source. It reports whether the chunks were replaced.
+func (r *Refresher) refresh(ctx context.Context, source *Source) (bool, error) {
+	source.CheckedAt = time.Now()
+	document, err := r.fetcher.Fetch(ctx, *source)
+	if errors.Is(err, ErrNotModified) {
+		r.fresh(source)
+		return false, nil
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) && r.removeMissing {
+			r.store.Delete(from(source.ID))
+			source.Chunks = 0
+		}
+		r.fail(source, err)
+		return false, err
+	}
+
+	sum := sha256.Sum256([]byte(document.Text))
+	checksum := hex.EncodeToString(sum[:])
+	source.ETag, source.LastModified = document.ETag, document.LastModified
+	if checksum == source.Checksum {
+		r.fresh(source)
+		return false, nil
+	}
+
+	chunks := Chunk(document.Text, r.chunkSize)
+	metadata := make([]map[string]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		metadata[i] = map[string]interface{}{
+			MetadataID:     source.ID + "#" + strconv.Itoa(i),
+			MetadataSource: source.ID,
+			MetadataText:   chunk,
+		}
+	}
+	if _, err := r.store.ReplaceTexts(ctx, from(source.ID), chunks, metadata); err != nil {
+		// Fetch it in full next time rather than trust the validators
+		source.ETag, source.LastModified = "", ""
+		r.fail(source, err)
+		return false, err
+	}
+	source.Checksum = checksum
+	source.Chunks = len(chunks)
+	source.UpdatedAt = source.CheckedAt
+	source.Stale, source.Error = false, ""
+	return true, nil
+}
+
+// fresh clears the stale mark of an unchanged source.
+func (r *Refresher) fresh(source *Source) {
+	if source.Stale {
+		r.store.SetMetadata(from(source.ID), MetadataStale, false)
+	}
+	source.Stale, source.Error = false, ""
+}
+
+// fail marks a source and its chunks stale.
+func (r *Refresher) fail(source *Source, err error) {
+	r.store.SetMetadata(from(source.ID), MetadataStale, true)
+	source.Stale, source.Error = true, err.Error()
+}
+
+// from selects the chunks of a source.
+func from(id string) func(metadata map[string]interface{}) bool {
+	return func(metadata map[string]interface{}) bool {
+		return metadata[MetadataSource] == id
+	}
+}
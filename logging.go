@@ -0,0 +1,311 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/streaming"
+)
+
+// defaultMaxLoggedBody is how much of a body is logged by default.
+const defaultMaxLoggedBody = 4 << 10
+
+// maxCapturedBody is how much of a body is kept for logging, so long
+// streams don't grow without bound.
+const maxCapturedBody = 64 << 10
+
+// redactedFields are the JSON fields RedactMessages hides.
+var redactedFields = map[string]bool{
+	"message":    true,
+	"content":    true,
+	"reply":      true,
+	"response":   true,
+	"thinking":   true,
+	"transcript": true,
+}
+
+// RequestLogEntry is what a RequestLogger logs about a request.
+type RequestLogEntry struct {
+	Method  string
+	Path    string
+	Status  int
+	Latency time.Duration
+	// UserID, Model and Usage are set once the chatbot knows them.
+	UserID string
+	Model  string
+	Usage  *streaming.Usage
+	// RequestBody and ResponseBody are set with RequestLogOptions.Bodies,
+	// redacted and truncated.
+	RequestBody  string
+	ResponseBody string
+}
+
+// String formats the entry as one log line.
+func (e RequestLogEntry) String() string {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s %s %d %s", e.Method, e.Path, e.Status, e.Latency.Round(time.Millisecond))
+	if e.UserID != "" {
+		fmt.Fprintf(&line, " user=%s", e.UserID)
+	}
+	if e.Model != "" {
+		fmt.Fprintf(&line, " model=%s", e.Model)
+	}
+	if e.Usage != nil {
+		fmt.Fprintf(&line, " prompt_tokens=%d completion_tokens=%d", e.Usage.PromptTokens, e.Usage.CompletionTokens)
+	}
+	if e.RequestBody != "" {
+		fmt.Fprintf(&line, " request=%s", e.RequestBody)
+	}
+	if e.ResponseBody != "" {
+		fmt.Fprintf(&line, " response=%s", e.ResponseBody)
+	}
+	return line.String()
+}
+
+// RequestLogOptions configures a RequestLogger.
+type RequestLogOptions struct {
+	// Log receives every entry. The default writes it with log.Printf.
+	Log func(entry RequestLogEntry)
+	// Bodies adds the request and response bodies to the entries.
+	Bodies bool
+	// Redact rewrites a body before it is logged. The default is
+	// RedactMessages; use a function returning the body as is to log
+	// message texts.
+	Redact func(body []byte) string
+	// MaxBodySize truncates logged bodies; 0 means 4 KB.
+	MaxBodySize int
+}
+
+// RequestLogger logs the method, path, status, latency, user, model and
+// token counts of chat requests. Use Middleware with net/http, and the
+// adapters' LoggingMiddleware with Gin, Echo, Fiber and Chi.
+type RequestLogger struct {
+	options RequestLogOptions
+}
+
+// NewRequestLogger creates a request logger.
+func NewRequestLogger(options RequestLogOptions) *RequestLogger {
+	if options.Log == nil {
+		options.Log = func(entry RequestLogEntry) {
+			log.Printf("chat request: %s", entry)
+		}
+	}
+	if options.Redact == nil {
+		options.Redact = RedactMessages
+	}
+	if options.MaxBodySize <= 0 {
+		options.MaxBodySize = defaultMaxLoggedBody
+	}
+	return &RequestLogger{options: options}
+}
+
+// Middleware logs every request served by next.
+func (l *RequestLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, record := l.Begin(r)
+		writer := &loggingWriter{ResponseWriter: w, record: record, status: http.StatusOK}
+		next.ServeHTTP(writer, r)
+		record.Finish(writer.status)
+	})
+}
+
+// Begin starts logging r, for framework middleware. Serve the returned
+// request, pass the response body to the record's Write and call Finish
+// with the status.
+func (l *RequestLogger) Begin(r *http.Request) (*http.Request, *RequestRecord) {
+	record := &RequestRecord{
+		logger:  l,
+		started: time.Now(),
+		entry:   RequestLogEntry{Method: r.Method, Path: r.URL.Path},
+	}
+	if user, ok := UserFromContext(r.Context()); ok {
+		record.entry.UserID = user.ID
+	}
+	if l.options.Bodies && r.Body != nil {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, &record.request), r.Body}
+	}
+
+	ctx := streaming.NewMetadataContext(r.Context(), &record.meta)
+	ctx = newRequestRecordContext(ctx, record)
+	return r.WithContext(ctx), record
+}
+
+// RequestRecord is a request being logged.
+type RequestRecord struct {
+	logger   *RequestLogger
+	started  time.Time
+	entry    RequestLogEntry
+	meta     streaming.Metadata
+	request  cappedBuffer
+	response cappedBuffer
+}
+
+// Write records response body bytes. It never fails.
+func (r *RequestRecord) Write(p []byte) (int, error) {
+	if r.logger.options.Bodies {
+		r.response.Write(p)
+	}
+	return len(p), nil
+}
+
+// Finish logs the request with the response status.
+func (r *RequestRecord) Finish(status int) {
+	entry := r.entry
+	entry.Status = status
+	entry.Latency = time.Since(r.started)
+	entry.Model = r.meta.Model
+	entry.Usage = r.meta.Usage
+
+	options := r.logger.options
+	if options.Bodies {
+		entry.RequestBody = truncateBody(options.Redact(r.request.data), options.MaxBodySize)
+		entry.ResponseBody = truncateBody(options.Redact(r.response.data), options.MaxBodySize)
+	}
+	options.Log(entry)
+}
+
+type requestRecordKey struct{}
+
+// newRequestRecordContext returns a context carrying the record of the
+// request being logged, for the chatbot to note the user in.
+func newRequestRecordContext(ctx context.Context, record *RequestRecord) context.Context {
+	return context.WithValue(ctx, requestRecordKey{}, record)
+}
+
+// noteRequestUser records the user of a logged request.
+func noteRequestUser(ctx context.Context, userID string) {
+	if record, ok := ctx.Value(requestRecordKey{}).(*RequestRecord); ok && userID != "" {
+		record.entry.UserID = userID
+	}
+}
+
+// RedactMessages is the default RequestLogOptions.Redact. It replaces the
+// message, content, reply, response, thinking and transcript fields of
+// JSON bodies and of server-sent event data with "[REDACTED]". Bodies it
+// cannot parse are redacted whole.
+func RedactMessages(body []byte) string {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return ""
+	}
+	if redacted, ok := redactJSON(body); ok {
+		return redacted
+	}
+
+	lines := strings.Split(strings.TrimRight(string(body), "\n"), "\n")
+	for i, line := range lines {
+		data, isEvent := strings.CutPrefix(line, "data: ")
+		if !isEvent {
+			if line != "" && !strings.HasPrefix(line, "event:") && !strings.HasPrefix(line, "id:") {
+				return "[REDACTED]"
+			}
+			continue
+		}
+		if redacted, ok := redactJSON([]byte(data)); ok {
+			lines[i] = "data: " + redacted
+		} else if data != "[DONE]" {
+			lines[i] = "data: [REDACTED]"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactJSON redacts the message fields of a JSON document.
+func redactJSON(body []byte) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	redacted, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return "", false
+	}
+	return string(redacted), true
+}
+
+// redactValue replaces the message fields in v.
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if _, isString := field.(string); isString && redactedFields[key] {
+				value[key] = "[REDACTED]"
+			} else {
+				value[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	}
+	return v
+}
+
+// truncateBody cuts body to max bytes.
+func truncateBody(body string, max int) string {
+	if len(body) <= max {
+		return body
+	}
+	return body[:max] + "...(truncated)"
+}
+
+// cappedBuffer keeps the first maxCapturedBody bytes written to it. It
+// never fails, so it can be written to with a TeeReader.
+type cappedBuffer struct {
+	data []byte
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := maxCapturedBody - len(b.data); room < len(p) {
+		p = p[:room]
+	}
+	b.data = append(b.data, p...)
+	return n, nil
+}
+
+// loggingWriter records the status and body of a response.
+type loggingWriter struct {
+	http.ResponseWriter
+	record      *RequestRecord
+	status      int
+	wroteHeader bool
+}
+
+func (w *loggingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	w.record.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush supports streaming responses.
+func (w *loggingWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError flushes, reporting a failure to http.ResponseController.
+func (w *loggingWriter) FlushError() error {
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *loggingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
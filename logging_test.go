@@ -0,0 +1,99 @@
+package gochatbot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
+)
+
+// usageModel reports token usage like the provider models do.
+type usageModel struct{}
+
+func (m *usageModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	if meta, ok := streaming.MetadataFromContext(ctx); ok {
+		meta.Usage = &streaming.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}
+	}
+	return "Hello there", nil
+}
+
+func (m *usageModel) Name() string     { return "usage" }
+func (m *usageModel) Provider() string { return "test" }
+
+func TestRequestLogger_Middleware(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	bot, err := New(cfg, WithModel(&usageModel{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	var entries []RequestLogEntry
+	logger := NewRequestLogger(RequestLogOptions{
+		Log:    func(entry RequestLogEntry) { entries = append(entries, entry) },
+		Bodies: true,
+	})
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewUserContext(r.Context(), User{ID: "u1"})))
+		})
+	}
+	handler := logger.Middleware(authenticate(http.HandlerFunc(bot.HandleHTTP)))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "my secret"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "Hello there") {
+		t.Errorf("expected the reply to reach the client, got %s", w.Body.String())
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Method != "POST" || entry.Path != "/chat" || entry.Status != http.StatusOK {
+		t.Errorf("unexpected request fields: %+v", entry)
+	}
+	if entry.UserID != "u1" || entry.Model != "usage" {
+		t.Errorf("expected user u1 and model usage, got %q and %q", entry.UserID, entry.Model)
+	}
+	if entry.Usage == nil || entry.Usage.PromptTokens != 12 || entry.Usage.CompletionTokens != 5 {
+		t.Errorf("unexpected usage: %+v", entry.Usage)
+	}
+	for _, body := range []string{entry.RequestBody, entry.ResponseBody} {
+		if !strings.Contains(body, "[REDACTED]") || strings.Contains(body, "my secret") || strings.Contains(body, "Hello there") {
+			t.Errorf("expected a redacted body, got %s", body)
+		}
+	}
+	if line := entry.String(); !strings.Contains(line, "POST /chat 200") || !strings.Contains(line, "prompt_tokens=12") {
+		t.Errorf("unexpected log line: %s", line)
+	}
+}
+
+func TestRedactMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "empty", body: "", want: ""},
+		{name: "request", body: `{"message": "secret", "conversation_id": "c1"}`, want: `{"conversation_id":"c1","message":"[REDACTED]"}`},
+		{name: "nested", body: `{"messages": [{"role": "user", "content": "secret"}]}`, want: `{"messages":[{"content":"[REDACTED]","role":"user"}]}`},
+		{name: "stream", body: "data: {\"content\": \"secret\"}\n\ndata: [DONE]\n", want: "data: {\"content\":\"[REDACTED]\"}\n\ndata: [DONE]"},
+		{name: "text", body: "secret", want: "[REDACTED]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactMessages([]byte(tt.body)); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+)
+
+// defaultMaintenanceMessage is told to clients turned away by maintenance
+// mode without a configured message.
+const defaultMaintenanceMessage = `The chatbot is down for maintenance{{if not .Until.IsZero}} until {{.Until.Format "2006-01-02 15:04 MST"}}{{end}}. Please try again later.`
+
+// maintenanceState is the chatbot's maintenance mode. resumed is closed
+// when maintenance ends, releasing queued chats.
+type maintenanceState struct {
+	mutex   sync.RWMutex
+	config  config.MaintenanceConfig
+	message *template.Template
+	resumed chan struct{}
+}
+
+// SetMaintenance switches maintenance mode at runtime, like an admin kill
+// switch: while it is enabled new chats are rejected with an error matching
+// chaterrors.ErrMaintenance, or queued with Queue, and chats already
+// streaming finish. The state holds until it is set again or the
+// maintenance section of a reloaded configuration changes. It returns an
+// error if the message is not a valid template.
+func (c *Chatbot) SetMaintenance(maintenance config.MaintenanceConfig) error {
+	message, err := parseMaintenanceMessage(maintenance.Message)
+	if err != nil {
+		return err
+	}
+
+	m := &c.maintenance
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.config = maintenance
+	m.message = message
+	switch {
+	case maintenance.Enabled && m.resumed == nil:
+		m.resumed = make(chan struct{})
+	case !maintenance.Enabled && m.resumed != nil:
+		close(m.resumed)
+		m.resumed = nil
+	}
+	return nil
+}
+
+// Maintenance returns the chatbot's maintenance mode.
+func (c *Chatbot) Maintenance() config.MaintenanceConfig {
+	c.maintenance.mutex.RLock()
+	defer c.maintenance.mutex.RUnlock()
+	return c.maintenance.config
+}
+
+// parseMaintenanceMessage parses a maintenance message template, or the
+// default one if message is empty.
+func parseMaintenanceMessage(message string) (*template.Template, error) {
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	tmpl, err := template.New("maintenance").Parse(message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance message: %w", err)
+	}
+	return tmpl, nil
+}
+
+// checkMaintenance returns an error matching chaterrors.ErrMaintenance if
+// new chats are turned away. In queue mode it waits for maintenance to end
+// and only fails once ctx is done.
+func (c *Chatbot) checkMaintenance(ctx context.Context) error {
+	m := &c.maintenance
+	for {
+		m.mutex.RLock()
+		if !m.config.Enabled {
+			m.mutex.RUnlock()
+			return nil
+		}
+		resumed := m.resumed
+		err := c.maintenanceError()
+		queue := m.config.Queue
+		m.mutex.RUnlock()
+
+		if !queue {
+			return err
+		}
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// maintenanceError renders the maintenance message. The caller holds the
+// read lock.
+func (c *Chatbot) maintenanceError() error {
+	m := &c.maintenance
+	var message strings.Builder
+	if err := m.message.Execute(&message, m.config); err != nil {
+		return chaterrors.ErrMaintenance
+	}
+	return chaterrors.Mark(errors.New(message.String()), chaterrors.ErrMaintenance)
+}
+
+// MaintenanceHandler returns the admin endpoint of maintenance mode: GET
+// reports the current state and PUT or POST replace it with the
+// config.MaintenanceConfig in the JSON body, answering with the new state.
+// It does no authentication, so mount it behind the application's admin
+// authentication.
+func (c *Chatbot) MaintenanceHandler() http.Handler {
+	handler := NewHTTPHandler(c)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var maintenance config.MaintenanceConfig
+			if err := json.NewDecoder(r.Body).Decode(&maintenance); err != nil {
+				handler.writeErrorResponse(w, http.StatusBadRequest, "Invalid JSON request")
+				return
+			}
+			if err := c.SetMaintenance(maintenance); err != nil {
+				handler.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		default:
+			handler.writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(c.Maintenance())
+	})
+}
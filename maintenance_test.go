@@ -0,0 +1,172 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/models"
+)
+
+func TestChatbot_Maintenance(t *testing.T) {
+	cfg := config.Default()
+	cfg.Maintenance = config.MaintenanceConfig{
+		Enabled: true,
+		Message: `Back at {{.Until.Format "15:04"}}.`,
+		Until:   time.Date(2026, 1, 1, 18, 30, 0, 0, time.UTC),
+	}
+	bot, err := New(cfg, WithModel(models.NewFreeModel()))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	_, err = bot.Ask(context.Background(), "Hello")
+	if !errors.Is(err, chaterrors.ErrMaintenance) || err.Error() != "Back at 18:30." {
+		t.Fatalf("expected the maintenance message, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	bot.HandleHTTP(rec, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"message":"Hello"}`)))
+	var response ChatResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusServiceUnavailable || response.Code != "maintenance" || response.Error != "Back at 18:30." {
+		t.Errorf("expected a 503 with the maintenance message, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	bot.HandleStreamHTTP(rec, httptest.NewRequest(http.MethodPost, "/chat/stream", strings.NewReader(`{"message":"Hello"}`)))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"code":"maintenance"`) {
+		t.Errorf("expected the stream to fail with the maintenance code, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	NewHTTPHandler(bot).Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &health)
+	if rec.Code != http.StatusOK || health["status"] != "maintenance" || health["maintenance"] != true || health["until"] != "2026-01-01T18:30:00Z" {
+		t.Errorf("expected health to report maintenance, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if err := bot.SetMaintenance(config.MaintenanceConfig{}); err != nil {
+		t.Fatalf("failed to end maintenance: %v", err)
+	}
+	if _, err := bot.Ask(context.Background(), "Hello"); err != nil {
+		t.Errorf("expected chats after maintenance, got %v", err)
+	}
+}
+
+func TestChatbot_MaintenanceQueue(t *testing.T) {
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if err := bot.SetMaintenance(config.MaintenanceConfig{Enabled: true, Queue: true}); err != nil {
+		t.Fatalf("failed to start maintenance: %v", err)
+	}
+
+	// Queued chats give up with the message when they time out
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = bot.Ask(ctx, "Hello")
+	if !errors.Is(err, chaterrors.ErrMaintenance) || !strings.Contains(err.Error(), "down for maintenance") {
+		t.Errorf("expected the default maintenance message, got %v", err)
+	}
+
+	// and are answered once maintenance ends
+	done := make(chan error, 1)
+	go func() {
+		_, err := bot.Ask(context.Background(), "Hello")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected the chat to be queued, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := bot.SetMaintenance(config.MaintenanceConfig{}); err != nil {
+		t.Fatalf("failed to end maintenance: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the queued chat to be answered, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the queued chat to resume")
+	}
+}
+
+func TestChatbot_MaintenanceHandler(t *testing.T) {
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	handler := bot.MaintenanceHandler()
+
+	request := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/admin/maintenance", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := request(http.MethodPut, `{"enabled":true,"message":"Upgrading"}`)
+	if rec.Code != http.StatusOK || !bot.Maintenance().Enabled {
+		t.Fatalf("expected maintenance to start, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var state config.MaintenanceConfig
+	json.Unmarshal(request(http.MethodGet, "").Body.Bytes(), &state)
+	if !state.Enabled || state.Message != "Upgrading" {
+		t.Errorf("expected the state to be reported, got %+v", state)
+	}
+
+	if rec := request(http.MethodPut, `{"enabled":true,"message":"{{.Missing"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid message to be rejected, got %d", rec.Code)
+	}
+	if bot.Maintenance().Message != "Upgrading" {
+		t.Errorf("expected a rejected update to keep the state, got %+v", bot.Maintenance())
+	}
+	if rec := request(http.MethodDelete, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestChatbot_ReloadMaintenance(t *testing.T) {
+	cfg := config.Default()
+	bot, err := New(cfg, WithModel(models.NewFreeModel()))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	next := *cfg
+	next.Maintenance = config.MaintenanceConfig{Enabled: true}
+	if _, err := bot.Reload(&next); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if !bot.Maintenance().Enabled {
+		t.Fatal("expected the reload to start maintenance")
+	}
+
+	// An admin switch holds across reloads not changing maintenance
+	if err := bot.SetMaintenance(config.MaintenanceConfig{}); err != nil {
+		t.Fatalf("failed to end maintenance: %v", err)
+	}
+	next.Prompt = "Be brief."
+	if _, err := bot.Reload(&next); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if bot.Maintenance().Enabled {
+		t.Error("expected the admin switch to hold")
+	}
+
+	next.Maintenance.Message = "{{"
+	if _, err := bot.Reload(&next); err == nil {
+		t.Error("expected an invalid maintenance message to be rejected")
+	}
+}
@@ -0,0 +1,265 @@
+// Package mcp exposes the chatbot as a Model Context Protocol (MCP) server,
+// so IDEs and other agents can call it as a capability. The server offers an
+// "ask" tool that sends a message to the chatbot and, when a knowledge base
+// is configured, a "search_knowledge" tool that queries it.
+//
+// Serve speaks MCP's stdio transport (newline-delimited JSON-RPC); Server is
+// also an http.Handler for clients that post JSON-RPC messages over HTTP:
+//
+//	server := mcp.NewServer(bot, mcp.WithKnowledgeBase(store))
+//	err := server.Serve(ctx, os.Stdin, os.Stdout)
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// ProtocolVersion is the latest MCP protocol version supported by the server.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions lists the protocol versions the server can speak.
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// maxMessageSize bounds a single JSON-RPC message.
+const maxMessageSize = 4 << 20
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithKnowledgeBase enables the search_knowledge tool backed by store.
+func WithKnowledgeBase(store *embeddings.VectorStore) Option {
+	return func(s *Server) {
+		s.knowledge = store
+	}
+}
+
+// WithServerInfo sets the name and version reported to clients
+// (default "go-chatbot", "1.0.0").
+func WithServerInfo(name, version string) Option {
+	return func(s *Server) {
+		s.name = name
+		s.version = version
+	}
+}
+
+// Server is an MCP server backed by a Chatbot.
+type Server struct {
+	bot       *gochatbot.Chatbot
+	knowledge *embeddings.VectorStore
+	name      string
+	version   string
+}
+
+// NewServer creates an MCP server for bot.
+func NewServer(bot *gochatbot.Chatbot, opts ...Option) *Server {
+	s := &Server{
+		bot:     bot,
+		name:    "go-chatbot",
+		version: "1.0.0",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// request is a JSON-RPC request or notification.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve reads JSON-RPC messages from r and writes responses to w, one per
+// line, until r is exhausted or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	// Read in the background so cancellation does not wait for the next line
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-readErr:
+					if err != nil {
+						return fmt.Errorf("failed to read request: %w", err)
+					}
+				default:
+				}
+				return ctx.Err()
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			if resp := s.handle(ctx, []byte(line)); resp != nil {
+				if err := encoder.Encode(resp); err != nil {
+					return fmt.Errorf("failed to write response: %w", err)
+				}
+			}
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler. Each POST carries one JSON-RPC message;
+// notifications are answered with 202 Accepted.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	resp := s.handle(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handle processes one message and returns the response, or nil for notifications.
+func (s *Server) handle(ctx context.Context, data []byte) *response {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errorResponse(json.RawMessage("null"), &rpcError{Code: codeParseError, Message: "parse error"})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = json.RawMessage("null")
+		}
+		return errorResponse(id, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+	}
+
+	result, err := s.dispatch(ctx, req)
+
+	// Notifications never get a response
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// dispatch routes a request to its method.
+func (s *Server) dispatch(ctx context.Context, req request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.tools()}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+// initialize negotiates the protocol version and announces capabilities.
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid initialize params: %w", err)
+		}
+	}
+
+	version := ProtocolVersion
+	if supportedVersions[p.ProtocolVersion] {
+		version = p.ProtocolVersion
+	}
+
+	model := s.bot.GetModel()
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+		"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		"instructions": fmt.Sprintf("Use the ask tool to get answers from a chatbot backed by %s (%s).",
+			model.Provider(), model.Name()),
+	}, nil
+}
+
+// errorResponse builds a JSON-RPC error response.
+func errorResponse(id json.RawMessage, err *rpcError) *response {
+	return &response{JSONRPC: "2.0", ID: id, Error: err}
+}
@@ -0,0 +1,255 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gochatbot "go.rumenx.com/chatbot"
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// keywordEmbeddings embeds texts as keyword counts so similarity is predictable.
+type keywordEmbeddings struct{}
+
+var keywords = []string{"refund", "shipping", "password"}
+
+func (keywordEmbeddings) Embed(ctx context.Context, texts []string) ([]embeddings.Vector, error) {
+	vectors := make([]embeddings.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = make(embeddings.Vector, len(keywords))
+		for j, keyword := range keywords {
+			vectors[i][j] = float64(strings.Count(strings.ToLower(text), keyword))
+		}
+	}
+	return vectors, nil
+}
+
+func (k keywordEmbeddings) EmbedSingle(ctx context.Context, text string) (embeddings.Vector, error) {
+	vectors, err := k.Embed(ctx, []string{text})
+	return vectors[0], err
+}
+
+func (keywordEmbeddings) Dimensions() int  { return len(keywords) }
+func (keywordEmbeddings) Model() string    { return "keywords" }
+func (keywordEmbeddings) Provider() string { return "test" }
+
+func newTestServer(t *testing.T, model *chatbottest.MockModel, opts ...Option) *Server {
+	t.Helper()
+	bot, err := gochatbot.New(config.Default(), gochatbot.WithModel(model))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	return NewServer(bot, opts...)
+}
+
+func newKnowledgeBase(t *testing.T) *embeddings.VectorStore {
+	t.Helper()
+	store := embeddings.NewVectorStore(keywordEmbeddings{})
+	err := store.AddTexts(context.Background(),
+		[]string{"Refunds take five days.", "Shipping is free over $50."},
+		[]map[string]interface{}{
+			{"source": "refunds.md", "text": "Refunds take five days."},
+			{"source": "shipping.md", "text": "Shipping is free over $50."},
+		})
+	if err != nil {
+		t.Fatalf("failed to build knowledge base: %v", err)
+	}
+	return store
+}
+
+// call sends one message through the stdio transport and decodes the reply.
+func call(t *testing.T, server *Server, message string) map[string]interface{} {
+	t.Helper()
+	var out bytes.Buffer
+	if err := server.Serve(context.Background(), strings.NewReader(message+"\n"), &out); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", out.String(), err)
+	}
+	return resp
+}
+
+func result(t *testing.T, resp map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	res, ok := resp["result"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected result, got %v", resp)
+	}
+	return res
+}
+
+func TestInitialize(t *testing.T) {
+	server := newTestServer(t, chatbottest.NewMockModel())
+
+	resp := call(t, server, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"ide","version":"1"}}}`)
+	res := result(t, resp)
+	if res["protocolVersion"] != "2025-03-26" {
+		t.Errorf("expected requested version to be accepted, got %v", res["protocolVersion"])
+	}
+	if info := res["serverInfo"].(map[string]interface{}); info["name"] != "go-chatbot" {
+		t.Errorf("unexpected server info: %v", info)
+	}
+
+	resp = call(t, server, `{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	if got := result(t, resp)["protocolVersion"]; got != ProtocolVersion {
+		t.Errorf("expected latest version for unknown request, got %v", got)
+	}
+}
+
+func TestToolsList(t *testing.T) {
+	names := func(server *Server) []string {
+		resp := call(t, server, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+		var list []string
+		for _, tool := range result(t, resp)["tools"].([]interface{}) {
+			list = append(list, tool.(map[string]interface{})["name"].(string))
+		}
+		return list
+	}
+
+	if got := names(newTestServer(t, chatbottest.NewMockModel())); len(got) != 1 || got[0] != AskTool {
+		t.Errorf("expected only the ask tool, got %v", got)
+	}
+	withKnowledge := newTestServer(t, chatbottest.NewMockModel(), WithKnowledgeBase(newKnowledgeBase(t)))
+	if got := names(withKnowledge); len(got) != 2 || got[1] != SearchKnowledgeTool {
+		t.Errorf("expected ask and search_knowledge tools, got %v", got)
+	}
+}
+
+func TestAskTool(t *testing.T) {
+	model := chatbottest.NewMockModel("Five days.")
+	server := newTestServer(t, model)
+
+	resp := call(t, server, `{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"ask","arguments":{"message":"How long do refunds take?","history":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello!"}]}}}`)
+	res := result(t, resp)
+	content := res["content"].([]interface{})[0].(map[string]interface{})
+	if content["text"] != "Five days." || res["isError"] != nil {
+		t.Errorf("unexpected result: %v", res)
+	}
+	if resp["id"] != "a" {
+		t.Errorf("expected string ID to be echoed, got %v", resp["id"])
+	}
+
+	last, _ := model.LastCall()
+	history, ok := last.Context["history"].([]map[string]interface{})
+	if last.Message != "How long do refunds take?" || !ok || len(history) != 2 {
+		t.Errorf("unexpected model call: %+v", last)
+	}
+}
+
+func TestAskToolErrors(t *testing.T) {
+	model := chatbottest.NewMockModel()
+	model.Fail(errors.New("provider down"))
+	server := newTestServer(t, model)
+
+	// Model failures are tool results the caller can read
+	res := result(t, call(t, server, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"ask","arguments":{"message":"hi"}}}`))
+	if res["isError"] != true || !strings.Contains(res["content"].([]interface{})[0].(map[string]interface{})["text"].(string), "provider down") {
+		t.Errorf("expected tool error result, got %v", res)
+	}
+
+	// Bad arguments are protocol errors
+	resp := call(t, server, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"ask","arguments":{}}}`)
+	if resp["error"].(map[string]interface{})["code"] != float64(codeInvalidParams) {
+		t.Errorf("expected invalid params error, got %v", resp)
+	}
+}
+
+func TestSearchKnowledgeTool(t *testing.T) {
+	server := newTestServer(t, chatbottest.NewMockModel(), WithKnowledgeBase(newKnowledgeBase(t)))
+
+	res := result(t, call(t, server, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_knowledge","arguments":{"query":"refund policy","limit":1}}}`))
+	text := res["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	if !strings.Contains(text, "refunds.md") || !strings.Contains(text, "Refunds take five days.") {
+		t.Errorf("unexpected search result: %q", text)
+	}
+	results := res["structuredContent"].(map[string]interface{})["results"].([]interface{})
+	if len(results) != 1 {
+		t.Errorf("expected limit to apply, got %d results", len(results))
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	server := newTestServer(t, chatbottest.NewMockModel())
+
+	tests := map[string]struct {
+		message string
+		code    float64
+	}{
+		"parse error":    {`{not json`, codeParseError},
+		"invalid":        {`{"id":1,"method":"ping"}`, codeInvalidRequest},
+		"unknown method": {`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`, codeMethodNotFound},
+		"unknown tool":   {`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_knowledge"}}`, codeInvalidParams},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := call(t, server, tt.message)
+			rpcErr, ok := resp["error"].(map[string]interface{})
+			if !ok || rpcErr["code"] != tt.code {
+				t.Errorf("expected error %v, got %v", tt.code, resp)
+			}
+		})
+	}
+
+	if resp := call(t, server, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp != nil {
+		t.Errorf("expected no response to a notification, got %v", resp)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := newTestServer(t, chatbottest.NewMockModel())
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"ping"}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"jsonrpc":"2.0","id":7,"result":{}}` {
+		t.Errorf("unexpected ping response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202 for notification, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestServeStopsOnCancel(t *testing.T) {
+	server := newTestServer(t, chatbottest.NewMockModel())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// A reader that never returns, like an idle stdin
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, reader, &bytes.Buffer{}) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+}
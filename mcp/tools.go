@@ -0,0 +1,206 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gochatbot "go.rumenx.com/chatbot"
+)
+
+// Tool names.
+const (
+	AskTool             = "ask"
+	SearchKnowledgeTool = "search_knowledge"
+)
+
+// Search result limits for search_knowledge.
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 20
+)
+
+// tool describes an MCP tool.
+type tool struct {
+	Name        string                 `json:"name"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// toolResult is the result of a tools/call request. Tool failures are
+// reported in the result with IsError set, so the calling model can see them.
+type toolResult struct {
+	Content           []textContent `json:"content"`
+	StructuredContent interface{}   `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+// textContent is a text content block.
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func textResult(text string) *toolResult {
+	return &toolResult{Content: []textContent{{Type: "text", Text: text}}}
+}
+
+func toolError(err error) *toolResult {
+	result := textResult(err.Error())
+	result.IsError = true
+	return result
+}
+
+// tools lists the available tools.
+func (s *Server) tools() []tool {
+	tools := []tool{{
+		Name:        AskTool,
+		Title:       "Ask the chatbot",
+		Description: "Send a message to the chatbot and return its reply. Pass earlier turns in history to continue a conversation.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"message": map[string]interface{}{"type": "string", "description": "The message to send"},
+				"history": map[string]interface{}{
+					"type":        "array",
+					"description": "Earlier conversation turns, oldest first",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"role":    map[string]interface{}{"type": "string", "enum": []string{"user", "assistant"}},
+							"content": map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"role", "content"},
+					},
+				},
+			},
+			"required": []string{"message"},
+		},
+	}}
+
+	if s.knowledge != nil {
+		tools = append(tools, tool{
+			Name:        SearchKnowledgeTool,
+			Title:       "Search the knowledge base",
+			Description: "Find the knowledge base passages most relevant to a query.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{"type": "string", "description": "What to search for"},
+					"limit": map[string]interface{}{
+						"type": "integer", "minimum": 1, "maximum": maxSearchLimit,
+						"description": fmt.Sprintf("Maximum number of results (default %d)", defaultSearchLimit),
+					},
+				},
+				"required": []string{"query"},
+			},
+		})
+	}
+	return tools
+}
+
+// callTool runs a tools/call request.
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, fmt.Errorf("invalid tools/call params: %w", err)
+	}
+	if len(call.Arguments) == 0 {
+		call.Arguments = json.RawMessage("{}")
+	}
+
+	switch {
+	case call.Name == AskTool:
+		return s.ask(ctx, call.Arguments)
+	case call.Name == SearchKnowledgeTool && s.knowledge != nil:
+		return s.searchKnowledge(ctx, call.Arguments)
+	default:
+		return nil, fmt.Errorf("unknown tool %q", call.Name)
+	}
+}
+
+// ask implements the ask tool.
+func (s *Server) ask(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Message string `json:"message"`
+		History []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"history"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid ask arguments: %w", err)
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return nil, fmt.Errorf("ask requires a message")
+	}
+
+	var opts []gochatbot.AskOption
+	if len(args.History) > 0 {
+		history := make([]map[string]interface{}, 0, len(args.History))
+		for _, turn := range args.History {
+			if turn.Role != "user" && turn.Role != "assistant" {
+				return nil, fmt.Errorf("invalid history role %q", turn.Role)
+			}
+			history = append(history, map[string]interface{}{"role": turn.Role, "content": turn.Content})
+		}
+		opts = append(opts, gochatbot.WithContext("history", history))
+	}
+
+	reply, err := s.bot.Ask(ctx, args.Message, opts...)
+	if err != nil {
+		return toolError(err), nil
+	}
+	return textResult(reply), nil
+}
+
+// searchKnowledge implements the search_knowledge tool.
+func (s *Server) searchKnowledge(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid search_knowledge arguments: %w", err)
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return nil, fmt.Errorf("search_knowledge requires a query")
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultSearchLimit
+	}
+	if args.Limit > maxSearchLimit {
+		args.Limit = maxSearchLimit
+	}
+
+	results, err := s.knowledge.Search(ctx, args.Query, args.Limit)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if len(results) == 0 {
+		return textResult("No matching passages found."), nil
+	}
+
+	var text strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			text.WriteString("\n\n")
+		}
+		fmt.Fprintf(&text, "[%d] (similarity %.2f)", i+1, result.Similarity)
+		if source, ok := result.Metadata["source"].(string); ok && source != "" {
+			fmt.Fprintf(&text, " %s", source)
+		}
+		if passage, ok := result.Metadata["text"].(string); ok {
+			text.WriteString("\n" + passage)
+		}
+	}
+
+	out := textResult(text.String())
+	out.StructuredContent = map[string]interface{}{"results": results}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// learnTimeout bounds learning from a message, which runs in the background.
+const learnTimeout = 30 * time.Second
+
+// Memory remembers durable facts about users across conversations.
+// profile.Manager implements it.
+type Memory interface {
+	// Recall returns facts about the user relevant to the message.
+	Recall(ctx context.Context, userID, message string) ([]string, error)
+	// Learn extracts and stores facts stated in the user's message.
+	Learn(ctx context.Context, userID, conversationID, message string) error
+}
+
+// WithMemory adds what is remembered about the user to the system prompt and
+// learns from every message. It needs a "user_id" in the request context.
+// Learning runs in the background, so it adds no latency.
+func WithMemory(memory Memory) Option {
+	return func(c *Chatbot) {
+		c.memory = memory
+	}
+}
+
+// recall extends the request's system prompt with facts about the user.
+// Memory is best effort and never fails the request.
+func (c *Chatbot) recall(ctx context.Context, message string, askContext map[string]interface{}) {
+	userID, _ := askContext["user_id"].(string)
+	if c.memory == nil || userID == "" {
+		return
+	}
+
+	facts, err := c.memory.Recall(ctx, userID, message)
+	if err != nil || len(facts) == 0 {
+		return
+	}
+
+	var prompt strings.Builder
+	if existing, _ := askContext["prompt"].(string); existing != "" {
+		prompt.WriteString(existing)
+		prompt.WriteString("\n\n")
+	}
+	prompt.WriteString("What you know about the user from earlier conversations:\n")
+	for _, fact := range facts {
+		prompt.WriteString("- ")
+		prompt.WriteString(fact)
+		prompt.WriteString("\n")
+	}
+	askContext["prompt"] = prompt.String()
+}
+
+// learn extracts facts from the message in the background.
+func (c *Chatbot) learn(ctx context.Context, message string, askContext map[string]interface{}) {
+	userID, _ := askContext["user_id"].(string)
+	if c.memory == nil || userID == "" {
+		return
+	}
+	conversationID, _ := askContext["conversation_id"].(string)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), learnTimeout)
+	go func() {
+		defer cancel()
+		defer c.recoverBackground()
+		if err := c.memory.Learn(ctx, userID, conversationID, message); err != nil {
+			c.logf("memory: failed to learn from message: %v", err)
+		}
+	}()
+}
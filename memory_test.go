@@ -0,0 +1,56 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// fakeMemory recalls fixed facts and reports learned messages on a channel.
+type fakeMemory struct {
+	facts   []string
+	learned chan string
+}
+
+func (m *fakeMemory) Recall(ctx context.Context, userID, message string) ([]string, error) {
+	return m.facts, nil
+}
+
+func (m *fakeMemory) Learn(ctx context.Context, userID, conversationID, message string) error {
+	m.learned <- userID + "/" + conversationID + ": " + message
+	return nil
+}
+
+func TestMemory(t *testing.T) {
+	cfg := config.Default()
+	cfg.Prompt = "You are a cooking assistant."
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	memory := &fakeMemory{facts: []string{"Is a vegetarian"}, learned: make(chan string, 1)}
+	bot, err := New(cfg, WithModel(model), WithMemory(memory))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	// Without a user there is nothing to remember
+	if _, err := bot.Ask(context.Background(), "Dinner ideas?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["prompt"] != cfg.Prompt {
+		t.Errorf("expected prompt untouched, got %q", model.context["prompt"])
+	}
+
+	_, err = bot.Ask(context.Background(), "Dinner ideas?", WithUserID("u1"), WithContext("conversation_id", "c1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prompt, _ := model.context["prompt"].(string)
+	if !strings.HasPrefix(prompt, cfg.Prompt) || !strings.Contains(prompt, "- Is a vegetarian") {
+		t.Errorf("expected facts in prompt, got %q", prompt)
+	}
+	if learned := <-memory.learned; learned != "u1/c1: Dinner ideas?" {
+		t.Errorf("unexpected learned message %q", learned)
+	}
+}
@@ -0,0 +1,132 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+// Limits of the messages a chat request may send.
+const (
+	// MaxRequestMessages is the most messages a request may send.
+	MaxRequestMessages = 100
+	// MaxRequestMessagesSize is the most bytes of content they may have
+	// together.
+	MaxRequestMessagesSize = 512 << 10
+)
+
+// ErrInvalidMessages is returned by ResolveMessages for messages the chat
+// endpoints do not accept. It matches chaterrors.ErrInvalidInput.
+var ErrInvalidMessages = chaterrors.Mark(errors.New("invalid messages"), chaterrors.ErrInvalidInput)
+
+// ChatMessage is one message of an OpenAI-style conversation sent by a
+// client that keeps the history itself.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ResolveMessages returns the message to ask and the options of a chat
+// request that sent either a single message or a list of messages. The last
+// of the messages is the one asked and must be from the user; the earlier
+// ones are passed as "history". Only "user" and "assistant" roles are
+// accepted, so clients cannot replace the chatbot's system prompt. The
+// message filter and input guardrails check the earlier user messages as
+// well as the last one.
+func ResolveMessages(message string, messages []ChatMessage) (string, []AskOption, error) {
+	if len(messages) == 0 {
+		return message, nil, nil
+	}
+	if message != "" {
+		return "", nil, fmt.Errorf("%w: send either message or messages, not both", ErrInvalidMessages)
+	}
+	if len(messages) > MaxRequestMessages {
+		return "", nil, fmt.Errorf("%w: more than %d messages", ErrInvalidMessages, MaxRequestMessages)
+	}
+
+	size := 0
+	history := make([]map[string]interface{}, 0, len(messages)-1)
+	for i, msg := range messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return "", nil, fmt.Errorf("%w: message %d has role %q, expected \"user\" or \"assistant\"", ErrInvalidMessages, i, msg.Role)
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			return "", nil, fmt.Errorf("%w: message %d is empty", ErrInvalidMessages, i)
+		}
+		size += len(msg.Content)
+		if size > MaxRequestMessagesSize {
+			return "", nil, fmt.Errorf("%w: more than %d bytes of content", ErrInvalidMessages, MaxRequestMessagesSize)
+		}
+		if i < len(messages)-1 {
+			history = append(history, map[string]interface{}{"role": msg.Role, "content": msg.Content})
+		}
+	}
+
+	last := messages[len(messages)-1]
+	if last.Role != "user" {
+		return "", nil, fmt.Errorf("%w: the last message must be from the user", ErrInvalidMessages)
+	}
+	if len(history) == 0 {
+		return last.Content, nil, nil
+	}
+	return last.Content, []AskOption{WithContext("history", history), withClientMessages()}, nil
+}
+
+// withClientMessages marks the request's "history" as sent by the client,
+// for the filter and guardrails to check.
+func withClientMessages() AskOption {
+	return func(opts *askOptions) {
+		opts.clientMessages = true
+	}
+}
+
+// clientMessages returns the user messages of a history sent by the
+// client, nil for other requests.
+func clientMessages(opts *askOptions) []map[string]interface{} {
+	if !opts.clientMessages {
+		return nil
+	}
+	history, _ := opts.context["history"].([]map[string]interface{})
+	var messages []map[string]interface{}
+	for _, msg := range history {
+		if msg["role"] == "user" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// filterClientMessages runs the message filter over the user messages of a
+// history sent by the client, replacing them with the filtered text and
+// adding what it found to the request context, so earlier messages cannot
+// carry what the last one could not.
+func (c *Chatbot) filterClientMessages(ctx context.Context, opts *askOptions) error {
+	for _, msg := range clientMessages(opts) {
+		content, _ := msg["content"].(string)
+		filtered, err := c.filter.Handle(ctx, content)
+		if err != nil {
+			return err
+		}
+		msg["content"] = filtered.Message
+		opts.addFilterContext(filtered)
+	}
+	return nil
+}
+
+// guardClientMessages checks the user messages of a history sent by the
+// client with the input guardrails, applying their redactions. It returns
+// the refusal to answer the request with if one of them is refused.
+func (c *Chatbot) guardClientMessages(ctx context.Context, opts *askOptions) (string, error) {
+	for _, msg := range clientMessages(opts) {
+		content, _ := msg["content"].(string)
+		text, refusal, err := c.guardInput(ctx, content, opts.context)
+		if err != nil || refusal != "" {
+			return refusal, err
+		}
+		msg["content"] = text
+	}
+	return "", nil
+}
@@ -27,32 +27,71 @@ type FilteredMessage struct {
 	Context map[string]interface{}
 }
 
-// NewChatMessageFilter creates a new message filter.
+// NewChatMessageFilter creates a new message filter. It panics if one of
+// the patterns in cfg does not compile; use ValidateFilterConfig to check
+// a configuration first.
 func NewChatMessageFilter(cfg config.MessageFilteringConfig) *ChatMessageFilter {
-	filter := &ChatMessageFilter{
-		config: cfg,
+	patterns, err := compileFilterPatterns(cfg)
+	if err != nil {
+		panic(err)
 	}
 
-	// Compile regex patterns
+	return &ChatMessageFilter{
+		config:          cfg,
+		profanityRegex:  patterns.profanity,
+		aggressionRegex: patterns.aggression,
+		linkRegex:       patterns.link,
+	}
+}
+
+// filterPatterns holds the compiled patterns of a filter configuration.
+type filterPatterns struct {
+	profanity  *regexp.Regexp
+	aggression *regexp.Regexp
+	link       *regexp.Regexp
+}
+
+// compileFilterPatterns compiles the patterns of cfg. Empty lists and an
+// empty link pattern leave the matching regexp nil.
+func compileFilterPatterns(cfg config.MessageFilteringConfig) (filterPatterns, error) {
+	var patterns filterPatterns
+	var err error
+
 	if len(cfg.Profanities) > 0 {
 		pattern := strings.Join(cfg.Profanities, "|")
-		filter.profanityRegex = regexp.MustCompile(`(?i)\b(` + pattern + `)\b`)
+		if patterns.profanity, err = regexp.Compile(`(?i)\b(` + pattern + `)\b`); err != nil {
+			return filterPatterns{}, fmt.Errorf("invalid profanities: %w", err)
+		}
 	}
 
 	if len(cfg.AggressionPatterns) > 0 {
 		pattern := strings.Join(cfg.AggressionPatterns, "|")
-		filter.aggressionRegex = regexp.MustCompile(`(?i)\b(` + pattern + `)\b`)
+		if patterns.aggression, err = regexp.Compile(`(?i)\b(` + pattern + `)\b`); err != nil {
+			return filterPatterns{}, fmt.Errorf("invalid aggression patterns: %w", err)
+		}
 	}
 
 	if cfg.LinkPattern != "" {
-		filter.linkRegex = regexp.MustCompile(cfg.LinkPattern)
+		if patterns.link, err = regexp.Compile(cfg.LinkPattern); err != nil {
+			return filterPatterns{}, fmt.Errorf("invalid link pattern: %w", err)
+		}
 	}
 
-	return filter
+	return patterns, nil
+}
+
+// ValidateFilterConfig reports an error if one of the patterns in cfg does
+// not compile.
+func ValidateFilterConfig(cfg config.MessageFilteringConfig) error {
+	_, err := compileFilterPatterns(cfg)
+	return err
 }
 
 // Handle processes and filters a message.
 func (f *ChatMessageFilter) Handle(ctx context.Context, message string) (*FilteredMessage, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
 	if !f.config.Enabled {
 		return &FilteredMessage{
 			Message: message,
@@ -60,9 +99,6 @@ func (f *ChatMessageFilter) Handle(ctx context.Context, message string) (*Filter
 		}, nil
 	}
 
-	f.mutex.RLock()
-	defer f.mutex.RUnlock()
-
 	filtered := message
 	context := make(map[string]interface{})
 
@@ -97,40 +133,48 @@ func (f *ChatMessageFilter) Handle(ctx context.Context, message string) (*Filter
 	}, nil
 }
 
-// UpdateConfig updates the filter configuration.
+// UpdateConfig updates the filter configuration. A configuration whose
+// patterns do not compile is ignored and the previous one stays in effect;
+// check it first with ValidateFilterConfig.
 func (f *ChatMessageFilter) UpdateConfig(cfg config.MessageFilteringConfig) {
+	patterns, err := compileFilterPatterns(cfg)
+	if err != nil {
+		return
+	}
+
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
 	f.config = cfg
+	f.profanityRegex = patterns.profanity
+	f.aggressionRegex = patterns.aggression
+	f.linkRegex = patterns.link
+}
 
-	// Recompile regex patterns
-	if len(cfg.Profanities) > 0 {
-		pattern := strings.Join(cfg.Profanities, "|")
-		f.profanityRegex = regexp.MustCompile(`(?i)\b(` + pattern + `)\b`)
-	} else {
-		f.profanityRegex = nil
-	}
-
-	if len(cfg.AggressionPatterns) > 0 {
-		pattern := strings.Join(cfg.AggressionPatterns, "|")
-		f.aggressionRegex = regexp.MustCompile(`(?i)\b(` + pattern + `)\b`)
-	} else {
-		f.aggressionRegex = nil
-	}
-
-	if cfg.LinkPattern != "" {
-		f.linkRegex = regexp.MustCompile(cfg.LinkPattern)
-	} else {
-		f.linkRegex = nil
-	}
+// Limiter decides whether a request may proceed. RateLimiter limits
+// requests within one process; RedisRateLimiter shares the limits between
+// instances.
+type Limiter interface {
+	// Allow counts a request, returning a *RateLimitError if it is rejected.
+	Allow(ctx context.Context) error
+	// Check counts a request and returns the decision.
+	Check(ctx context.Context) Decision
+	// UpdateConfig replaces the limits, e.g. on configuration reload.
+	UpdateConfig(cfg config.RateLimitConfig)
 }
 
-// RateLimiter provides rate limiting functionality.
+var _ Limiter = (*RateLimiter)(nil)
+
+// RateLimiter provides rate limiting functionality. The algorithm is chosen
+// with RateLimitConfig.Algorithm; see the config package for the trade-offs.
 type RateLimiter struct {
 	config   config.RateLimitConfig
 	requests map[string][]time.Time
-	mutex    sync.RWMutex
+	windows  map[string]*fixedWindow
+	buckets  map[string]*tokenBucket
+	// scopes count the requests of each override, keyed by overrideKey
+	scopes map[string]*RateLimiter
+	mutex  sync.RWMutex
 }
 
 // NewRateLimiter creates a new rate limiter.
@@ -138,45 +182,121 @@ func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
 	return &RateLimiter{
 		config:   cfg,
 		requests: make(map[string][]time.Time),
+		windows:  make(map[string]*fixedWindow),
+		buckets:  make(map[string]*tokenBucket),
+		scopes:   make(map[string]*RateLimiter),
 	}
 }
 
-// Allow checks if a request is allowed based on rate limiting rules.
+// UpdateConfig updates the rate limiting configuration.
+// Requests already recorded for the current window are kept, including
+// those of overrides that remain.
+func (r *RateLimiter) UpdateConfig(cfg config.RateLimitConfig) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.config = cfg
+	for key, limiter := range r.scopes {
+		override, ok := findOverride(cfg, key)
+		if !ok {
+			delete(r.scopes, key)
+			continue
+		}
+		limiter.UpdateConfig(override.Apply(cfg))
+	}
+}
+
+// Allow checks if a request is allowed based on rate limiting rules,
+// returning a *RateLimitError if it is not.
 func (r *RateLimiter) Allow(ctx context.Context) error {
+	decision := r.Check(ctx)
+	if !decision.Allowed {
+		return &RateLimitError{Decision: decision}
+	}
+	return nil
+}
+
+// Check counts a request against the client's limit, or that of the
+// override matching the request's RateLimitScope, and returns the decision.
+// A limit of zero requests disables rate limiting.
+func (r *RateLimiter) Check(ctx context.Context) Decision {
 	// Extract client identifier from context (IP, user ID, etc.)
 	clientID := r.getClientID(ctx)
 
+	limiter, _ := r.scoped(ctx)
+	return limiter.check(clientID)
+}
+
+// scoped returns the limiter counting the requests of the override
+// matching ctx's scope, with the override's key, or r itself and "".
+func (r *RateLimiter) scoped(ctx context.Context) (*RateLimiter, string) {
+	scope, _ := RateLimitScopeFromContext(ctx)
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	now := time.Now()
-	windowStart := now.Add(-r.config.Window)
-
-	// Clean old requests
-	if requests, exists := r.requests[clientID]; exists {
-		validRequests := make([]time.Time, 0, len(requests))
-		for _, reqTime := range requests {
-			if reqTime.After(windowStart) {
-				validRequests = append(validRequests, reqTime)
+	for _, override := range r.config.Overrides {
+		if !override.Matches(scope.Route, scope.Persona, scope.Tier) {
+			continue
+		}
+		key := overrideKey(override)
+		limiter, exists := r.scopes[key]
+		if !exists {
+			limiter = NewRateLimiter(override.Apply(r.config))
+			if r.scopes == nil {
+				r.scopes = make(map[string]*RateLimiter)
 			}
+			r.scopes[key] = limiter
 		}
-		r.requests[clientID] = validRequests
+		return limiter, key
+	}
+	return r, ""
+}
+
+// check counts a request of the client against r's own limit.
+func (r *RateLimiter) check(clientID string) Decision {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.config.RequestsPerMinute <= 0 {
+		return Decision{Allowed: true}
 	}
 
-	// Check if within limit
-	requestCount := len(r.requests[clientID])
-	if requestCount >= r.config.RequestsPerMinute {
-		return fmt.Errorf("rate limit exceeded: %d requests in %v", requestCount, r.config.Window)
+	now := time.Now()
+	switch r.config.Algorithm {
+	case config.RateLimitFixedWindow:
+		return r.checkFixedWindow(clientID, now)
+	case config.RateLimitTokenBucket:
+		return r.checkTokenBucket(clientID, now)
+	default:
+		return r.checkSlidingWindow(clientID, now)
 	}
+}
 
-	// Add current request
-	r.requests[clientID] = append(r.requests[clientID], now)
+// window returns the configured window, defaulting to a minute. The caller
+// must hold the mutex.
+func (r *RateLimiter) window() time.Duration {
+	if r.config.Window <= 0 {
+		return time.Minute
+	}
+	return r.config.Window
+}
 
-	return nil
+type clientIDContextKey struct{}
+
+// WithClientID returns a context whose requests are counted against id, such
+// as an authenticated user ID, instead of the "client_ip" or "user_id"
+// context values.
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDContextKey{}, id)
 }
 
 // getClientID extracts a client identifier from the context.
 func (r *RateLimiter) getClientID(ctx context.Context) string {
+	if id, ok := ctx.Value(clientIDContextKey{}).(string); ok && id != "" {
+		return id
+	}
+
 	// Try to get IP address from context
 	if ip, ok := ctx.Value("client_ip").(string); ok {
 		return ip
@@ -197,7 +317,7 @@ func (r *RateLimiter) Cleanup() {
 	defer r.mutex.Unlock()
 
 	now := time.Now()
-	windowStart := now.Add(-r.config.Window)
+	windowStart := now.Add(-r.window())
 
 	for clientID, requests := range r.requests {
 		validRequests := make([]time.Time, 0, len(requests))
@@ -213,11 +333,30 @@ func (r *RateLimiter) Cleanup() {
 			r.requests[clientID] = validRequests
 		}
 	}
+
+	// Expired windows and refilled buckets are the same as no record
+	for clientID, window := range r.windows {
+		if !window.start.After(windowStart) {
+			delete(r.windows, clientID)
+		}
+	}
+	for clientID, bucket := range r.buckets {
+		if r.refill(bucket, now) >= float64(r.capacity()) {
+			delete(r.buckets, clientID)
+		}
+	}
+	for _, limiter := range r.scopes {
+		limiter.Cleanup()
+	}
 }
 
 // StartCleanupRoutine starts a background routine to clean up old records.
 func (r *RateLimiter) StartCleanupRoutine(ctx context.Context) {
-	ticker := time.NewTicker(r.config.Window)
+	r.mutex.RLock()
+	interval := r.window()
+	r.mutex.RUnlock()
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
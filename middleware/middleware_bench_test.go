@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func BenchmarkRateLimiter_Allow(b *testing.B) {
+	limiter := NewRateLimiter(config.RateLimitConfig{
+		RequestsPerMinute: 1000,
+		Window:            time.Second,
+	})
+	ctx := context.WithValue(context.Background(), "client_ip", "10.0.0.1")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = limiter.Allow(ctx)
+	}
+}
+
+// BenchmarkRateLimiter_Contention measures lock contention with many
+// goroutines spread over a fixed set of clients.
+func BenchmarkRateLimiter_Contention(b *testing.B) {
+	for _, clients := range []int{1, 100} {
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			limiter := NewRateLimiter(config.RateLimitConfig{
+				RequestsPerMinute: 1000,
+				Window:            time.Second,
+			})
+
+			contexts := make([]context.Context, clients)
+			for i := range contexts {
+				contexts[i] = context.WithValue(context.Background(), "client_ip", fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+			}
+
+			var next uint64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					n := atomic.AddUint64(&next, 1)
+					_ = limiter.Allow(contexts[n%uint64(clients)])
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkChatMessageFilter_Handle(b *testing.B) {
+	filter := NewChatMessageFilter(config.Default().MessageFiltering)
+	ctx := context.Background()
+	message := "Hello, could you help me plan a trip to Lisbon next spring? I am so stupid with maps."
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := filter.Handle(ctx, message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
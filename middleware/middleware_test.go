@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"go.rumenx.com/chatbot/chaterrors"
 	"go.rumenx.com/chatbot/config"
 )
 
@@ -38,6 +40,31 @@ func TestChatMessageFilter_UpdateConfig(t *testing.T) {
 	}
 }
 
+func TestChatMessageFilter_UpdateConfigInvalidPattern(t *testing.T) {
+	filter := NewChatMessageFilter(config.MessageFilteringConfig{
+		Enabled:     true,
+		Profanities: []string{"bad"},
+	})
+
+	invalid := config.MessageFilteringConfig{
+		Enabled:     true,
+		LinkPattern: "https?://(",
+	}
+	if err := ValidateFilterConfig(invalid); err == nil {
+		t.Error("expected an error for the invalid link pattern")
+	}
+
+	// An invalid configuration is ignored instead of panicking
+	filter.UpdateConfig(invalid)
+	result, err := filter.Handle(context.Background(), "This is bad")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Message != "This is ***" {
+		t.Errorf("expected the previous configuration to stay, got '%s'", result.Message)
+	}
+}
+
 func TestRateLimiter_Cleanup(t *testing.T) {
 	limiter := NewRateLimiter(config.RateLimitConfig{
 		RequestsPerMinute: 10,
@@ -214,3 +241,39 @@ func TestRateLimiter_GetClientID(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimiter_RateLimitError(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitConfig{
+		RequestsPerMinute: 2,
+		Window:            time.Minute,
+	})
+	ctx := context.WithValue(context.Background(), "client_ip", "192.168.1.1")
+
+	limiter.mutex.Lock()
+	limiter.requests["192.168.1.1"] = []time.Time{time.Now().Add(-50 * time.Second), time.Now()}
+	limiter.mutex.Unlock()
+
+	err := limiter.Allow(ctx)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) {
+		t.Fatalf("expected a RateLimitError, got %v", err)
+	}
+	if limited.Error() != "rate limit exceeded: 2 requests in 1m0s" {
+		t.Errorf("unexpected message %q", limited.Error())
+	}
+	if !errors.Is(err, chaterrors.ErrRateLimited) {
+		t.Error("expected the error to match ErrRateLimited")
+	}
+	// The oldest request leaves the window in about 10 seconds
+	if limited.RetryAfter < 9*time.Second || limited.RetryAfter > 10*time.Second {
+		t.Errorf("unexpected retry after %v", limited.RetryAfter)
+	}
+
+	headers := limited.Headers()
+	if headers["X-RateLimit-Limit"] != "2" || headers["X-RateLimit-Remaining"] != "0" || headers["Retry-After"] != "10" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if headers["X-RateLimit-Reset"] == "" {
+		t.Error("expected X-RateLimit-Reset")
+	}
+}
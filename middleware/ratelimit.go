@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+)
+
+// RateLimitScope describes a request for choosing its rate limits among
+// config.RateLimitConfig.Overrides.
+type RateLimitScope struct {
+	// Route is one of the config.RateLimitRoute* routes.
+	Route string
+	// Persona is the persona the chatbot answers as.
+	Persona string
+	// Tier is the requesting user's tier or plan, e.g. "free" or "premium".
+	Tier string
+}
+
+type rateLimitScopeContextKey struct{}
+
+// WithRateLimitScope returns a context whose requests are limited by the
+// override matching scope, e.g. for authentication middleware to set the
+// tier of an API key.
+func WithRateLimitScope(ctx context.Context, scope RateLimitScope) context.Context {
+	return context.WithValue(ctx, rateLimitScopeContextKey{}, scope)
+}
+
+// RateLimitScopeFromContext returns the scope set with WithRateLimitScope.
+func RateLimitScopeFromContext(ctx context.Context) (RateLimitScope, bool) {
+	scope, ok := ctx.Value(rateLimitScopeContextKey{}).(RateLimitScope)
+	return scope, ok
+}
+
+// overrideKey identifies the requests of an override, so they are counted
+// apart from the others and keep their counts across reloads.
+func overrideKey(override config.RateLimitOverride) string {
+	return fmt.Sprintf("route=%s,persona=%s,tier=%s", override.Route, override.Persona, override.Tier)
+}
+
+// findOverride returns the first override of limits with key.
+func findOverride(limits config.RateLimitConfig, key string) (config.RateLimitOverride, bool) {
+	for _, override := range limits.Overrides {
+		if overrideKey(override) == key {
+			return override, true
+		}
+	}
+	return config.RateLimitOverride{}, false
+}
+
+// Decision is the outcome of a rate limit check.
+type Decision struct {
+	Allowed bool
+	// Limit is the number of requests allowed per window, or the bucket
+	// size for the token bucket algorithm.
+	Limit int
+	// Remaining is how many more requests the client can make right now.
+	Remaining int
+	Window    time.Duration
+	// Reset is when the client regains capacity: for rejected requests, when
+	// the next request will be allowed.
+	Reset time.Time
+	// RetryAfter is how long a rejected client should wait before retrying.
+	RetryAfter time.Duration
+}
+
+// Headers returns the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (Unix seconds) response headers, plus Retry-After
+// (seconds) if the request was rejected.
+func (d Decision) Headers() map[string]string {
+	headers := map[string]string{
+		"X-RateLimit-Limit":     strconv.Itoa(d.Limit),
+		"X-RateLimit-Remaining": strconv.Itoa(d.Remaining),
+		"X-RateLimit-Reset":     strconv.FormatInt(int64(math.Ceil(float64(d.Reset.UnixNano())/1e9)), 10),
+	}
+	if !d.Allowed {
+		seconds := int(math.Ceil(d.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		headers["Retry-After"] = strconv.Itoa(seconds)
+	}
+	return headers
+}
+
+// RateLimitError is returned by RateLimiter.Allow when a request is
+// rejected. It carries the decision so HTTP handlers can tell clients when
+// to retry. It matches chaterrors.ErrRateLimited.
+type RateLimitError struct {
+	Decision
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %d requests in %v", e.Limit, e.Window)
+}
+
+// Unwrap makes the error match chaterrors.ErrRateLimited.
+func (e *RateLimitError) Unwrap() error {
+	return chaterrors.ErrRateLimited
+}
+
+// checkSlidingWindow keeps a log of each client's requests in the last
+// window. The caller must hold the mutex.
+func (r *RateLimiter) checkSlidingWindow(clientID string, now time.Time) Decision {
+	limit, window := r.config.RequestsPerMinute, r.window()
+	windowStart := now.Add(-window)
+
+	// Clean old requests
+	requests := r.requests[clientID]
+	validRequests := make([]time.Time, 0, len(requests)+1)
+	for _, reqTime := range requests {
+		if reqTime.After(windowStart) {
+			validRequests = append(validRequests, reqTime)
+		}
+	}
+	r.requests[clientID] = validRequests
+
+	decision := Decision{Limit: limit, Window: window}
+	if len(validRequests) >= limit {
+		// Enough requests must leave the window to get below the limit
+		decision.Reset = validRequests[len(validRequests)-limit].Add(window)
+		decision.RetryAfter = decision.Reset.Sub(now)
+		return decision
+	}
+
+	validRequests = append(validRequests, now)
+	r.requests[clientID] = validRequests
+	decision.Allowed = true
+	decision.Remaining = limit - len(validRequests)
+	decision.Reset = validRequests[0].Add(window)
+	return decision
+}
+
+// fixedWindow counts a client's requests in the current window.
+type fixedWindow struct {
+	start time.Time
+	count int
+}
+
+// checkFixedWindow counts requests in windows aligned to multiples of the
+// window length. The caller must hold the mutex.
+func (r *RateLimiter) checkFixedWindow(clientID string, now time.Time) Decision {
+	limit, window := r.config.RequestsPerMinute, r.window()
+	start := now.Truncate(window)
+
+	current, exists := r.windows[clientID]
+	if !exists || !current.start.Equal(start) {
+		current = &fixedWindow{start: start}
+		r.windows[clientID] = current
+	}
+
+	decision := Decision{Limit: limit, Window: window, Reset: start.Add(window)}
+	if current.count >= limit {
+		decision.RetryAfter = decision.Reset.Sub(now)
+		return decision
+	}
+	current.count++
+	decision.Allowed = true
+	decision.Remaining = limit - current.count
+	return decision
+}
+
+// tokenBucket holds a client's tokens as of updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// capacity returns the token bucket size: the burst size, or the request
+// limit if no burst size is set. The caller must hold the mutex.
+func (r *RateLimiter) capacity() int {
+	if r.config.BurstSize > 0 {
+		return r.config.BurstSize
+	}
+	return r.config.RequestsPerMinute
+}
+
+// rate returns how many tokens are added per second. The caller must hold
+// the mutex.
+func (r *RateLimiter) rate() float64 {
+	return float64(r.config.RequestsPerMinute) / r.window().Seconds()
+}
+
+// refill adds the tokens earned since the bucket was last updated and
+// returns the new count. The caller must hold the mutex.
+func (r *RateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	earned := now.Sub(bucket.updated).Seconds() * r.rate()
+	bucket.tokens = math.Min(float64(r.capacity()), bucket.tokens+earned)
+	bucket.updated = now
+	return bucket.tokens
+}
+
+// checkTokenBucket takes a token from the client's bucket, which refills
+// at the request limit per window. The caller must hold the mutex.
+func (r *RateLimiter) checkTokenBucket(clientID string, now time.Time) Decision {
+	capacity := r.capacity()
+	bucket, exists := r.buckets[clientID]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(capacity), updated: now}
+		r.buckets[clientID] = bucket
+	}
+	tokens := r.refill(bucket, now)
+
+	decision := Decision{Limit: capacity, Window: r.window()}
+	if tokens < 1 {
+		decision.RetryAfter = r.timeToEarn(1 - tokens)
+		decision.Reset = now.Add(decision.RetryAfter)
+		return decision
+	}
+	bucket.tokens--
+	decision.Allowed = true
+	decision.Remaining = int(bucket.tokens)
+	decision.Reset = now.Add(r.timeToEarn(float64(capacity) - bucket.tokens))
+	return decision
+}
+
+// timeToEarn returns how long the bucket takes to earn tokens. The caller
+// must hold the mutex.
+func (r *RateLimiter) timeToEarn(tokens float64) time.Duration {
+	return time.Duration(tokens / r.rate() * float64(time.Second))
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestRateLimiter_Algorithms(t *testing.T) {
+	for _, algorithm := range []string{"", config.RateLimitSlidingWindow, config.RateLimitFixedWindow, config.RateLimitTokenBucket} {
+		limiter := NewRateLimiter(config.RateLimitConfig{
+			RequestsPerMinute: 3,
+			Window:            time.Hour,
+			Algorithm:         algorithm,
+		})
+		ctx := context.WithValue(context.Background(), "client_ip", "192.168.1.1")
+
+		for i := 0; i < 3; i++ {
+			decision := limiter.Check(ctx)
+			if !decision.Allowed || decision.Limit != 3 || decision.Remaining != 2-i {
+				t.Errorf("%s: request %d: unexpected decision %+v", algorithm, i, decision)
+			}
+		}
+		decision := limiter.Check(ctx)
+		if decision.Allowed || decision.Remaining != 0 || decision.RetryAfter <= 0 || decision.RetryAfter > time.Hour {
+			t.Errorf("%s: expected rejection, got %+v", algorithm, decision)
+		}
+		if !decision.Reset.After(time.Now()) {
+			t.Errorf("%s: expected a reset in the future, got %v", algorithm, decision.Reset)
+		}
+
+		// Other clients have their own limits
+		other := context.WithValue(context.Background(), "client_ip", "192.168.1.2")
+		if !limiter.Check(other).Allowed {
+			t.Errorf("%s: expected another client to be allowed", algorithm)
+		}
+	}
+}
+
+func TestRateLimiter_FixedWindowResets(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute, Algorithm: config.RateLimitFixedWindow})
+	ctx := context.Background()
+
+	limiter.Check(ctx)
+	// Move the recorded window into the past
+	limiter.mutex.Lock()
+	limiter.windows["default"].start = limiter.windows["default"].start.Add(-time.Minute)
+	limiter.mutex.Unlock()
+
+	if decision := limiter.Check(ctx); !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("expected a new window, got %+v", decision)
+	}
+	if decision := limiter.Check(ctx); decision.Allowed || decision.Reset.Sub(time.Now()) > time.Minute {
+		t.Errorf("expected rejection until the window ends, got %+v", decision)
+	}
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	// Bursts of 2, refilling one token every 6 seconds
+	limiter := NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 10, BurstSize: 2, Window: time.Minute, Algorithm: config.RateLimitTokenBucket})
+	ctx := context.Background()
+
+	limiter.Check(ctx)
+	limiter.Check(ctx)
+	decision := limiter.Check(ctx)
+	if decision.Allowed || decision.Limit != 2 || decision.RetryAfter > 6*time.Second || decision.RetryAfter < 5*time.Second {
+		t.Fatalf("expected to wait about 6 seconds for a token, got %+v", decision)
+	}
+	if decision.Headers()["Retry-After"] != "6" {
+		t.Errorf("unexpected headers %v", decision.Headers())
+	}
+
+	// Half a minute later the bucket is full again
+	limiter.mutex.Lock()
+	limiter.buckets["default"].updated = time.Now().Add(-30 * time.Second)
+	limiter.mutex.Unlock()
+	if decision := limiter.Check(ctx); !decision.Allowed || decision.Remaining != 1 {
+		t.Errorf("expected a refilled bucket, got %+v", decision)
+	}
+
+	limiter.mutex.Lock()
+	limiter.buckets["default"].updated = time.Now().Add(-time.Minute)
+	limiter.mutex.Unlock()
+	limiter.Cleanup()
+	if len(limiter.buckets) != 0 {
+		t.Error("expected cleanup to drop full buckets")
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	algorithms := []string{"", config.RateLimitSlidingWindow, config.RateLimitFixedWindow, config.RateLimitTokenBucket}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			limiter := NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 0, Window: time.Minute, Algorithm: algorithm})
+			for i := 0; i < 100; i++ {
+				if err := limiter.Allow(context.Background()); err != nil {
+					t.Fatalf("expected no limit, got %v", err)
+				}
+				if decision := limiter.Check(context.Background()); !decision.Allowed {
+					t.Fatalf("expected no limit, got %+v", decision)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter_WithClientID(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute})
+	ctx := context.WithValue(context.Background(), "client_ip", "192.168.1.1")
+
+	if err := limiter.Allow(WithClientID(ctx, "u1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The client ID takes precedence over the shared IP
+	if err := limiter.Allow(WithClientID(ctx, "u2")); err != nil {
+		t.Errorf("expected u2 to have its own limit, got %v", err)
+	}
+	if err := limiter.Allow(WithClientID(ctx, "u1")); err == nil {
+		t.Error("expected u1 to be rate limited")
+	}
+}
+
+func TestRateLimiter_Overrides(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitConfig{
+		RequestsPerMinute: 1,
+		Window:            time.Minute,
+		Overrides: []config.RateLimitOverride{
+			{Route: config.RateLimitRouteStream, Tier: "premium", RequestsPerMinute: -1},
+			{Route: config.RateLimitRouteStream, RequestsPerMinute: 2},
+		},
+	})
+	ctx := context.WithValue(context.Background(), "client_ip", "192.168.1.1")
+	chat := WithRateLimitScope(ctx, RateLimitScope{Route: config.RateLimitRouteChat})
+	stream := WithRateLimitScope(ctx, RateLimitScope{Route: config.RateLimitRouteStream})
+	premium := WithRateLimitScope(ctx, RateLimitScope{Route: config.RateLimitRouteStream, Tier: "premium"})
+
+	if err := limiter.Allow(chat); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := limiter.Allow(chat); err == nil {
+		t.Error("expected the global limit for chats")
+	}
+
+	// Streams are counted apart, with their own limit
+	for i := 0; i < 2; i++ {
+		if decision := limiter.Check(stream); !decision.Allowed || decision.Limit != 2 {
+			t.Errorf("stream %d: unexpected decision %+v", i, decision)
+		}
+	}
+	if err := limiter.Allow(stream); err == nil {
+		t.Error("expected the stream limit")
+	}
+	for i := 0; i < 5; i++ {
+		if err := limiter.Allow(premium); err != nil {
+			t.Errorf("expected premium streams to be unlimited, got %v", err)
+		}
+	}
+
+	// Reloading keeps the counts of remaining overrides and drops the others
+	limiter.UpdateConfig(config.RateLimitConfig{
+		RequestsPerMinute: 1,
+		Window:            time.Minute,
+		Overrides:         []config.RateLimitOverride{{Route: config.RateLimitRouteStream, RequestsPerMinute: 3}},
+	})
+	if err := limiter.Allow(stream); err != nil {
+		t.Errorf("expected the raised stream limit, got %v", err)
+	}
+	if err := limiter.Allow(stream); err == nil {
+		t.Error("expected the earlier streams to still count")
+	}
+	if err := limiter.Allow(premium); err == nil {
+		t.Error("expected premium streams to share the stream limit")
+	}
+	if len(limiter.scopes) != 1 {
+		t.Errorf("expected the removed override's counts to be dropped, got %d scopes", len(limiter.scopes))
+	}
+}
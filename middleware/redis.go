@@ -0,0 +1,289 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/internal/logging"
+	"go.rumenx.com/chatbot/internal/redis"
+)
+
+// The scripts run atomically on the server, so every instance sees the same
+// count. They use the server clock and return {allowed, remaining, reset in
+// milliseconds}. Each is passed the same arguments: the limit, the window in
+// milliseconds, the requests allowed per window and a unique request ID.
+const (
+	scriptClock = `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+`
+
+	// slidingWindowScript keeps a sorted set of request times.
+	slidingWindowScript = scriptClock + `
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count >= limit then
+	local oldest = redis.call("ZRANGE", KEYS[1], count - limit, count - limit, "WITHSCORES")
+	return {0, 0, tonumber(oldest[2]) + window - now}
+end
+redis.call("ZADD", KEYS[1], now, ARGV[4])
+redis.call("PEXPIRE", KEYS[1], window)
+local first = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {1, limit - count - 1, tonumber(first[2]) + window - now}
+`
+
+	// fixedWindowScript counts requests in windows aligned to the window length.
+	fixedWindowScript = scriptClock + `
+local start = now - now % window
+local reset = start + window - now
+local state = redis.call("HMGET", KEYS[1], "start", "count")
+local count = 0
+if tonumber(state[1]) == start then
+	count = tonumber(state[2])
+end
+if count >= limit then
+	return {0, 0, reset}
+end
+redis.call("HSET", KEYS[1], "start", start, "count", count + 1)
+redis.call("PEXPIRE", KEYS[1], reset)
+return {1, limit - count - 1, reset}
+`
+
+	// tokenBucketScript refills ARGV[3] tokens per window up to the limit,
+	// which is the burst size.
+	tokenBucketScript = scriptClock + `
+local rate = tonumber(ARGV[3]) / window
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or limit
+local updated = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - updated) * rate)
+if tokens < 1 then
+	return {0, 0, math.ceil((1 - tokens) / rate)}
+end
+tokens = tokens - 1
+local full = math.ceil((limit - tokens) / rate)
+redis.call("HSET", KEYS[1], "tokens", tokens, "updated", now)
+redis.call("PEXPIRE", KEYS[1], full)
+return {1, math.floor(tokens), full}
+`
+)
+
+// RedisRateLimiterConfig configures the Redis rate limiter.
+type RedisRateLimiterConfig struct {
+	// URL is the server address, e.g. "redis://:password@localhost:6379/0".
+	// Use the "rediss" scheme for TLS connections.
+	URL string
+	// KeyPrefix namespaces the limiter's keys (default "chatbot:ratelimit").
+	KeyPrefix string
+	// Timeout bounds each check, including connecting (default 500ms).
+	// Requests are limited locally if the server does not answer in time.
+	Timeout time.Duration
+	// RetryInterval is how long to limit locally after the server fails
+	// before trying it again (default 5s).
+	RetryInterval time.Duration
+	// TLSConfig is used for "rediss" URLs.
+	TLSConfig *tls.Config
+}
+
+// RedisRateLimiter is a Limiter shared by every instance connected to the
+// same Redis server (5.0 or later), so a client gets one limit however many
+// instances serve it. Each check is one Lua script implementing the
+// configured algorithm.
+//
+// If Redis is unavailable, requests are limited by an in-process
+// RateLimiter instead, so each instance enforces the limit on its own until
+// the server is back.
+type RedisRateLimiter struct {
+	config RedisRateLimiterConfig
+	client *redis.Client
+	local  *RateLimiter
+
+	mutex    sync.Mutex
+	degraded bool
+	retryAt  time.Time
+}
+
+var _ Limiter = (*RedisRateLimiter)(nil)
+
+// NewRedisRateLimiter creates a Redis rate limiter. The connection is opened
+// on first use.
+func NewRedisRateLimiter(limits config.RateLimitConfig, cfg RedisRateLimiterConfig) (*RedisRateLimiter, error) {
+	if cfg.URL == "" {
+		cfg.URL = "redis://localhost:6379"
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "chatbot:ratelimit"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 500 * time.Millisecond
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = 5 * time.Second
+	}
+
+	client, err := redis.New(cfg.URL, cfg.Timeout, cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRateLimiter{config: cfg, client: client, local: NewRateLimiter(limits)}, nil
+}
+
+// UpdateConfig updates the rate limiting configuration.
+func (r *RedisRateLimiter) UpdateConfig(cfg config.RateLimitConfig) {
+	r.local.UpdateConfig(cfg)
+}
+
+// Allow checks if a request is allowed based on rate limiting rules,
+// returning a *RateLimitError if it is not.
+func (r *RedisRateLimiter) Allow(ctx context.Context) error {
+	decision := r.Check(ctx)
+	if !decision.Allowed {
+		return &RateLimitError{Decision: decision}
+	}
+	return nil
+}
+
+// Check counts a request against the client's shared limit, or that of the
+// override matching the request's RateLimitScope, and returns the decision,
+// falling back to local limiting if Redis is unavailable.
+func (r *RedisRateLimiter) Check(ctx context.Context) Decision {
+	scoped, scope := r.local.scoped(ctx)
+	scoped.mutex.RLock()
+	limits := scoped.config
+	window, capacity := scoped.window(), scoped.capacity()
+	scoped.mutex.RUnlock()
+
+	if limits.RequestsPerMinute <= 0 {
+		return Decision{Allowed: true}
+	}
+	if !r.available() {
+		return r.local.Check(ctx)
+	}
+
+	script, limit := slidingWindowScript, limits.RequestsPerMinute
+	algorithm := limits.Algorithm
+	switch algorithm {
+	case config.RateLimitFixedWindow:
+		script = fixedWindowScript
+	case config.RateLimitTokenBucket:
+		script, limit = tokenBucketScript, capacity
+	default:
+		algorithm = config.RateLimitSlidingWindow
+	}
+	// Keys are per algorithm, as each stores a different type, and per
+	// override, as each counts its requests apart
+	key := r.config.KeyPrefix + ":" + algorithm + ":"
+	if scope != "" {
+		key += scope + ":"
+	}
+	key += r.local.getClientID(ctx)
+
+	member, err := newRequestID()
+	if err != nil {
+		return r.fallback(ctx, err)
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+	reply, err := r.client.Do(checkCtx, "EVAL", script, "1", key,
+		strconv.Itoa(limit),
+		strconv.FormatInt(window.Milliseconds(), 10),
+		strconv.Itoa(limits.RequestsPerMinute),
+		member)
+	if err == nil {
+		var decision Decision
+		decision, err = parseDecision(reply, limit, window)
+		if err == nil {
+			r.recovered(ctx)
+			return decision
+		}
+	}
+	if ctx.Err() != nil {
+		// The caller gave up, which says nothing about the server
+		return r.local.Check(ctx)
+	}
+	return r.fallback(ctx, err)
+}
+
+// available reports whether to try Redis: it has not failed within the
+// retry interval.
+func (r *RedisRateLimiter) available() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return !r.degraded || !time.Now().Before(r.retryAt)
+}
+
+// fallback limits the request locally until the retry interval has passed.
+func (r *RedisRateLimiter) fallback(ctx context.Context, err error) Decision {
+	r.mutex.Lock()
+	if !r.degraded {
+		logging.Printf(ctx, "middleware: Redis rate limiter unavailable, limiting locally: %v", err)
+	}
+	r.degraded = true
+	r.retryAt = time.Now().Add(r.config.RetryInterval)
+	r.mutex.Unlock()
+
+	return r.local.Check(ctx)
+}
+
+func (r *RedisRateLimiter) recovered(ctx context.Context) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.degraded {
+		logging.Printf(ctx, "middleware: Redis rate limiter recovered")
+		r.degraded = false
+	}
+}
+
+// parseDecision converts a script reply into a decision.
+func parseDecision(reply interface{}, limit int, window time.Duration) (Decision, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Decision{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+	}
+	numbers := make([]int64, len(values))
+	for i, value := range values {
+		number, ok := value.(int64)
+		if !ok {
+			return Decision{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+		}
+		numbers[i] = number
+	}
+
+	reset := time.Duration(numbers[2]) * time.Millisecond
+	decision := Decision{
+		Allowed:   numbers[0] == 1,
+		Limit:     limit,
+		Remaining: int(numbers[1]),
+		Window:    window,
+		Reset:     time.Now().Add(reset),
+	}
+	if !decision.Allowed {
+		decision.RetryAfter = reset
+	}
+	return decision, nil
+}
+
+// Close closes the connection.
+func (r *RedisRateLimiter) Close() error {
+	return r.client.Close()
+}
+
+// newRequestID returns a random ID, so requests made in the same
+// millisecond are recorded separately by the sliding window.
+func newRequestID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
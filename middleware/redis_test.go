@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// fakeRateLimitRedis answers the limiter's scripts with a plain counter per
+// key, which is enough to check that instances share limits.
+type fakeRateLimitRedis struct {
+	listener net.Listener
+
+	mutex  sync.Mutex
+	counts map[string]int
+	evals  [][]string
+	fail   bool
+}
+
+func newFakeRateLimitRedis(t *testing.T) *fakeRateLimitRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeRateLimitRedis{listener: listener, counts: make(map[string]int)}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRateLimitRedis) url() string {
+	return "redis://" + s.listener.Addr().String()
+}
+
+func (s *fakeRateLimitRedis) setFail(fail bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fail = fail
+}
+
+func (s *fakeRateLimitRedis) count(key string) (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	count, ok := s.counts[key]
+	return count, ok
+}
+
+func (s *fakeRateLimitRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, s.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRateLimitRedis) exec(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.fail {
+		return "-LOADING Redis is loading the dataset in memory\r\n"
+	}
+	if args[0] != "EVAL" {
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+	s.evals = append(s.evals, args)
+	key := args[3]
+	limit, _ := strconv.Atoi(args[4])
+	if s.counts[key] >= limit {
+		return "*3\r\n:0\r\n:0\r\n:" + args[5] + "\r\n"
+	}
+	s.counts[key]++
+	return fmt.Sprintf("*3\r\n:1\r\n:%d\r\n:%s\r\n", limit-s.counts[key], args[5])
+}
+
+func (s *fakeRateLimitRedis) lastEval() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.evals) == 0 {
+		return nil
+	}
+	return s.evals[len(s.evals)-1]
+}
+
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		// Scripts span several lines, so bulk strings are read by length
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisRateLimiter_SharedLimit(t *testing.T) {
+	server := newFakeRateLimitRedis(t)
+	limits := config.RateLimitConfig{RequestsPerMinute: 2, Window: time.Minute}
+	ctx := context.WithValue(context.Background(), "client_ip", "192.168.1.1")
+
+	var instances []*RedisRateLimiter
+	for i := 0; i < 2; i++ {
+		limiter, err := NewRedisRateLimiter(limits, RedisRateLimiterConfig{URL: server.url()})
+		if err != nil {
+			t.Fatalf("failed to create limiter: %v", err)
+		}
+		defer limiter.Close()
+		instances = append(instances, limiter)
+	}
+
+	if decision := instances[0].Check(ctx); !decision.Allowed || decision.Remaining != 1 {
+		t.Errorf("unexpected first decision %+v", decision)
+	}
+	if err := instances[1].Allow(ctx); err != nil {
+		t.Errorf("expected the second request to be allowed, got %v", err)
+	}
+	decision := instances[0].Check(ctx)
+	if decision.Allowed || decision.RetryAfter != time.Minute || decision.Limit != 2 {
+		t.Errorf("expected the shared limit to reject, got %+v", decision)
+	}
+	if _, ok := server.count("chatbot:ratelimit:sliding_window:192.168.1.1"); !ok {
+		t.Error("expected the sliding window key to be used")
+	}
+
+	// Another algorithm keeps its own keys
+	instances[0].UpdateConfig(config.RateLimitConfig{RequestsPerMinute: 2, Algorithm: config.RateLimitTokenBucket})
+	if err := instances[0].Allow(ctx); err != nil {
+		t.Errorf("expected a fresh token bucket, got %v", err)
+	}
+
+	// Overrides keep their own keys and limits
+	instances[0].UpdateConfig(config.RateLimitConfig{
+		RequestsPerMinute: 2,
+		Overrides:         []config.RateLimitOverride{{Tier: "premium", RequestsPerMinute: 5}},
+	})
+	premium := WithRateLimitScope(ctx, RateLimitScope{Tier: "premium"})
+	if decision := instances[0].Check(premium); !decision.Allowed || decision.Limit != 5 {
+		t.Errorf("expected the premium limit, got %+v", decision)
+	}
+	if count, _ := server.count("chatbot:ratelimit:sliding_window:route=,persona=,tier=premium:192.168.1.1"); count != 1 {
+		t.Errorf("expected the premium key to be used, got %d", count)
+	}
+}
+
+func TestRedisRateLimiter_Disabled(t *testing.T) {
+	server := newFakeRateLimitRedis(t)
+	ctx := context.WithValue(context.Background(), "client_ip", "192.168.1.1")
+	algorithms := []string{"", config.RateLimitSlidingWindow, config.RateLimitFixedWindow, config.RateLimitTokenBucket}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			limiter, err := NewRedisRateLimiter(
+				config.RateLimitConfig{RequestsPerMinute: 0, Window: time.Minute, Algorithm: algorithm},
+				RedisRateLimiterConfig{URL: server.url()},
+			)
+			if err != nil {
+				t.Fatalf("failed to create limiter: %v", err)
+			}
+			defer limiter.Close()
+			for i := 0; i < 100; i++ {
+				if err := limiter.Allow(ctx); err != nil {
+					t.Fatalf("expected no limit, got %v", err)
+				}
+				if decision := limiter.Check(ctx); !decision.Allowed {
+					t.Fatalf("expected no limit, got %+v", decision)
+				}
+			}
+		})
+	}
+	if eval := server.lastEval(); eval != nil {
+		t.Errorf("expected no requests counted in Redis, got %v", eval)
+	}
+}
+
+func TestRedisRateLimiter_FallsBackToLocal(t *testing.T) {
+	server := newFakeRateLimitRedis(t)
+	server.setFail(true)
+	limiter, err := NewRedisRateLimiter(
+		config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute},
+		RedisRateLimiterConfig{URL: server.url(), RetryInterval: 20 * time.Millisecond},
+	)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close()
+	ctx := context.Background()
+
+	if err := limiter.Allow(ctx); err != nil {
+		t.Errorf("expected the local limiter to allow, got %v", err)
+	}
+	if err := limiter.Allow(ctx); err == nil {
+		t.Error("expected the local limiter to reject")
+	}
+
+	server.setFail(false)
+	time.Sleep(30 * time.Millisecond)
+	if err := limiter.Allow(ctx); err != nil {
+		t.Errorf("expected Redis to be used again, got %v", err)
+	}
+	if count, _ := server.count("chatbot:ratelimit:sliding_window:default"); count != 1 {
+		t.Errorf("expected the request to be counted in Redis, got %d", count)
+	}
+}
+
+func TestRedisRateLimiter_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	limiter, err := NewRedisRateLimiter(
+		config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute},
+		RedisRateLimiterConfig{URL: "redis://" + addr},
+	)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	if err := limiter.Allow(context.Background()); err != nil {
+		t.Errorf("expected the local limiter to allow, got %v", err)
+	}
+	if err := limiter.Allow(context.Background()); err == nil {
+		t.Error("expected the local limiter to reject")
+	}
+}
+
+func TestNewRedisRateLimiter_InvalidURL(t *testing.T) {
+	for _, url := range []string{"localhost:6379", "http://localhost"} {
+		if _, err := NewRedisRateLimiter(config.RateLimitConfig{}, RedisRateLimiterConfig{URL: url}); err == nil {
+			t.Errorf("%q: expected an error", url)
+		}
+	}
+}
+
+// The fake server does not run Lua, so check that every argument a script
+// reads is passed, and that those it converts with tonumber are numbers.
+func TestRedisRateLimiter_ScriptArguments(t *testing.T) {
+	server := newFakeRateLimitRedis(t)
+	limiter, err := NewRedisRateLimiter(config.RateLimitConfig{RequestsPerMinute: 10}, RedisRateLimiterConfig{URL: server.url()})
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close()
+
+	argument := regexp.MustCompile(`(tonumber\()?ARGV\[(\d+)\]`)
+	for _, algorithm := range []string{config.RateLimitSlidingWindow, config.RateLimitFixedWindow, config.RateLimitTokenBucket} {
+		limiter.UpdateConfig(config.RateLimitConfig{RequestsPerMinute: 10, BurstSize: 2, Window: time.Minute, Algorithm: algorithm})
+		if decision := limiter.Check(context.Background()); !decision.Allowed {
+			t.Fatalf("%s: expected the request to be allowed, got %+v", algorithm, decision)
+		}
+		eval := server.lastEval()
+		script, args := eval[1], eval[4:]
+		for _, match := range argument.FindAllStringSubmatch(script, -1) {
+			n, _ := strconv.Atoi(match[2])
+			if n > len(args) {
+				t.Errorf("%s: the script reads ARGV[%d] but %d arguments were passed", algorithm, n, len(args))
+				continue
+			}
+			if _, err := strconv.ParseFloat(args[n-1], 64); match[1] != "" && err != nil {
+				t.Errorf("%s: ARGV[%d] is %q, not a number", algorithm, n, args[n-1])
+			}
+		}
+		if algorithm == config.RateLimitTokenBucket && (args[0] != "2" || args[2] != "10") {
+			t.Errorf("expected a burst of 2 refilling 10 tokens per window, got %q", args)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// Sentiment labels.
+const (
+	SentimentNegative = "negative"
+	SentimentNeutral  = "neutral"
+	SentimentPositive = "positive"
+)
+
+// Sentiment is the result of analyzing a message.
+type Sentiment struct {
+	// Score ranges from -1 (very negative) to 1 (very positive).
+	Score float64 `json:"score"`
+	// Label is SentimentNegative, SentimentNeutral or SentimentPositive.
+	Label string `json:"label"`
+	// Emotions maps detected emotions (anger, frustration, sadness, fear,
+	// joy) to their share of the emotional signal, from 0 to 1.
+	Emotions map[string]float64 `json:"emotions,omitempty"`
+	// Source is "lexicon" or "model".
+	Source string `json:"source"`
+}
+
+// SentimentAnalyzer scores the sentiment of a message.
+type SentimentAnalyzer interface {
+	Analyze(ctx context.Context, message string) (*Sentiment, error)
+}
+
+// sentimentLabel maps a score to its label.
+func sentimentLabel(score float64) string {
+	switch {
+	case score <= -0.05:
+		return SentimentNegative
+	case score >= 0.05:
+		return SentimentPositive
+	default:
+		return SentimentNeutral
+	}
+}
+
+// LexiconAnalyzer scores messages with a word list, handling negation ("not
+// good"), intensifiers ("very bad"), shouting and exclamation marks. It is
+// fast and free but misses sarcasm and context; use ModelAnalyzer for that.
+type LexiconAnalyzer struct {
+	words    map[string]float64
+	emotions map[string]string
+}
+
+// NewLexiconAnalyzer creates an analyzer with the built-in English lexicon.
+// Extra words (with weights from -4 to 4) extend or override it.
+func NewLexiconAnalyzer(extra map[string]float64) *LexiconAnalyzer {
+	words := make(map[string]float64, len(sentimentLexicon)+len(extra))
+	for word, weight := range sentimentLexicon {
+		words[word] = weight
+	}
+	for word, weight := range extra {
+		words[strings.ToLower(word)] = weight
+	}
+
+	emotions := make(map[string]string)
+	for emotion, list := range emotionLexicon {
+		for _, word := range list {
+			emotions[word] = emotion
+		}
+	}
+
+	return &LexiconAnalyzer{words: words, emotions: emotions}
+}
+
+// Analyze implements SentimentAnalyzer.
+func (a *LexiconAnalyzer) Analyze(ctx context.Context, message string) (*Sentiment, error) {
+	tokens := strings.FieldsFunc(message, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	shouting := isShouting(message)
+
+	var sum float64
+	emotionHits := make(map[string]int)
+	var totalHits int
+
+	for i, token := range tokens {
+		word := strings.ToLower(token)
+		if emotion, ok := a.emotions[word]; ok {
+			emotionHits[emotion]++
+			totalHits++
+		}
+
+		weight, ok := a.words[word]
+		if !ok {
+			continue
+		}
+
+		// Intensifiers right before the word strengthen it
+		if i > 0 && sentimentIntensifiers[strings.ToLower(tokens[i-1])] {
+			weight += math.Copysign(0.3, weight)
+		}
+		// Words in capitals within a normal message are emphasized
+		if !shouting && len(token) > 2 && strings.ToUpper(token) == token {
+			weight += math.Copysign(0.7, weight)
+		}
+		// Negation within the three preceding words flips and dampens
+		for j := i - 1; j >= 0 && j >= i-3; j-- {
+			if sentimentNegators[strings.ToLower(tokens[j])] {
+				weight *= -0.74
+				break
+			}
+		}
+		sum += weight
+	}
+
+	// Exclamation marks amplify whatever the message already expresses
+	if sum != 0 {
+		exclamations := math.Min(float64(strings.Count(message, "!")), 3)
+		sum += math.Copysign(0.3*exclamations, sum)
+		if shouting {
+			sum += math.Copysign(0.7, sum)
+		}
+	}
+
+	score := sum / math.Sqrt(sum*sum+15)
+	result := &Sentiment{Score: score, Label: sentimentLabel(score), Source: "lexicon"}
+	if totalHits > 0 {
+		result.Emotions = make(map[string]float64, len(emotionHits))
+		for emotion, hits := range emotionHits {
+			result.Emotions[emotion] = float64(hits) / float64(totalHits)
+		}
+	}
+	return result, nil
+}
+
+// isShouting reports whether the whole message is written in capitals.
+func isShouting(message string) bool {
+	var letters, upper int
+	for _, r := range message {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= 6 && upper == letters
+}
+
+// ModelAnalyzer asks a model to score sentiment, which handles sarcasm and
+// context better than a lexicon at the cost of an extra model call. If the
+// call fails or returns no usable score, the fallback analyzer is used.
+type ModelAnalyzer struct {
+	model    models.Model
+	fallback SentimentAnalyzer
+}
+
+// NewModelAnalyzer creates an analyzer that scores messages with model. A
+// nil fallback makes failures return an error.
+func NewModelAnalyzer(model models.Model, fallback SentimentAnalyzer) *ModelAnalyzer {
+	return &ModelAnalyzer{model: model, fallback: fallback}
+}
+
+// sentimentPrompt instructs the model to answer with a score.
+const sentimentPrompt = `You rate the sentiment of customer messages. Respond with JSON only, in the form {"score": <number from -1 (very negative) to 1 (very positive)>, "emotions": {"anger": <0-1>, "frustration": <0-1>, "sadness": <0-1>, "fear": <0-1>, "joy": <0-1>}}. Include only emotions that are present.`
+
+// Analyze implements SentimentAnalyzer.
+func (a *ModelAnalyzer) Analyze(ctx context.Context, message string) (*Sentiment, error) {
+	result, err := a.score(ctx, message)
+	if err != nil && a.fallback != nil {
+		return a.fallback.Analyze(ctx, message)
+	}
+	return result, err
+}
+
+func (a *ModelAnalyzer) score(ctx context.Context, message string) (*Sentiment, error) {
+	reply, err := a.model.Ask(ctx, message, map[string]interface{}{
+		"prompt":      sentimentPrompt,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sentiment request failed: %w", err)
+	}
+
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no sentiment JSON in model reply")
+	}
+
+	var parsed struct {
+		Score    *float64           `json:"score"`
+		Emotions map[string]float64 `json:"emotions"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse sentiment: %w", err)
+	}
+	if parsed.Score == nil {
+		return nil, fmt.Errorf("model reply has no sentiment score")
+	}
+
+	score := math.Max(-1, math.Min(1, *parsed.Score))
+	result := &Sentiment{Score: score, Label: sentimentLabel(score), Source: "model"}
+	for emotion, value := range parsed.Emotions {
+		if value > 0 {
+			if result.Emotions == nil {
+				result.Emotions = make(map[string]float64)
+			}
+			result.Emotions[emotion] = math.Min(1, value)
+		}
+	}
+	return result, nil
+}
+
+// sentimentNegators flip the sentiment of the words that follow them.
+var sentimentNegators = map[string]bool{
+	"not": true, "no": true, "never": true, "nothing": true, "nobody": true, "none": true,
+	"neither": true, "nor": true, "without": true, "cannot": true, "can't": true,
+	"don't": true, "doesn't": true, "didn't": true, "isn't": true, "wasn't": true,
+	"aren't": true, "weren't": true, "won't": true, "wouldn't": true, "shouldn't": true,
+	"couldn't": true, "haven't": true, "hasn't": true, "hadn't": true,
+}
+
+// sentimentIntensifiers strengthen the word that follows them.
+var sentimentIntensifiers = map[string]bool{
+	"very": true, "really": true, "extremely": true, "so": true, "totally": true,
+	"absolutely": true, "completely": true, "incredibly": true, "super": true,
+	"utterly": true, "truly": true, "too": true, "most": true,
+}
+
+// sentimentLexicon weights words from -4 (very negative) to 4 (very positive).
+var sentimentLexicon = map[string]float64{
+	// Negative
+	"awful": -3, "terrible": -3, "horrible": -3, "worst": -3.2, "hate": -3, "hated": -3,
+	"disgusting": -3, "useless": -2.5, "pathetic": -2.8, "ridiculous": -2, "scam": -3,
+	"rubbish": -2.5, "garbage": -2.5, "stupid": -2.5, "idiot": -3, "idiots": -3, "incompetent": -2.8,
+	"angry": -2.5, "furious": -3.2, "mad": -2, "annoyed": -2, "annoying": -2, "irritated": -2,
+	"frustrated": -2.2, "frustrating": -2.2, "disappointed": -2, "disappointing": -2,
+	"upset": -2, "unhappy": -2, "sad": -2, "miserable": -2.8, "depressed": -2.5,
+	"bad": -2.2, "poor": -1.8, "wrong": -1.5, "broken": -1.8, "fail": -2, "failed": -2,
+	"failure": -2, "fails": -2, "problem": -1.2, "problems": -1.2, "issue": -0.8, "issues": -0.8,
+	"bug": -1.2, "error": -1.2, "slow": -1.2, "late": -1, "delay": -1.2, "delayed": -1.2,
+	"missing": -1.2, "lost": -1.5, "refund": -0.5, "complaint": -1.8,
+	"cancel": -1, "unacceptable": -2.8, "outrageous": -3, "ripoff": -3, "waste": -2,
+	"wasted": -2, "worthless": -2.8, "confusing": -1.5, "confused": -1.2, "worried": -1.5,
+	"scared": -2, "afraid": -2, "anxious": -1.8, "fear": -2, "sorry": -0.5, "unfortunately": -1,
+	"rude": -2.5, "unhelpful": -2.2, "liar": -3, "lie": -2.2, "lies": -2.2, "sucks": -2.5,
+	"damn": -1.8, "crap": -2.2, "kill": -3, "nightmare": -3, "disaster": -3, "fed": -0.5,
+	// Positive
+	"good": 1.9, "great": 3.1, "excellent": 3.2, "amazing": 3.2, "awesome": 3.1,
+	"fantastic": 3.3, "wonderful": 3.2, "perfect": 3, "love": 3.2, "loved": 3, "like": 1.5,
+	"nice": 1.8, "happy": 2.7, "glad": 2, "pleased": 2, "satisfied": 1.8, "thanks": 1.9,
+	"thank": 1.5, "grateful": 2.5, "appreciate": 2.2, "appreciated": 2.2, "helpful": 2,
+	"best": 3.2, "fast": 1.2, "quick": 1.2, "easy": 1.8, "works": 1, "worked": 1,
+	"fixed": 1.5, "resolved": 1.8, "solved": 1.8, "brilliant": 3, "cool": 1.3, "fine": 0.8,
+	"ok": 0.5, "okay": 0.5, "well": 0.8, "yes": 0.5, "welcome": 1.8, "enjoy": 2.2,
+	"enjoyed": 2.2, "impressed": 2.5, "recommend": 1.8, "smooth": 1.5, "friendly": 2.2,
+	"kind": 1.8, "superb": 3.1, "delighted": 3, "excited": 2.5, "relieved": 1.8,
+}
+
+// emotionLexicon lists words that signal each emotion.
+var emotionLexicon = map[string][]string{
+	"anger": {
+		"angry", "furious", "mad", "hate", "hated", "outrageous", "ridiculous", "unacceptable",
+		"idiot", "idiots", "stupid", "pathetic", "incompetent", "rude", "scam", "ripoff", "liar", "kill", "damn",
+	},
+	"frustration": {
+		"frustrated", "frustrating", "annoyed", "annoying", "irritated", "useless", "again",
+		"still", "waiting", "waste", "wasted", "broken", "fed", "tired", "unhelpful",
+	},
+	"sadness": {
+		"sad", "unhappy", "disappointed", "disappointing", "miserable", "depressed", "upset", "lost", "sorry",
+	},
+	"fear": {
+		"scared", "afraid", "worried", "anxious", "fear", "nervous", "panic", "nightmare",
+	},
+	"joy": {
+		"happy", "glad", "great", "love", "loved", "amazing", "awesome", "wonderful", "delighted",
+		"excited", "fantastic", "thanks", "grateful", "enjoy", "enjoyed",
+	},
+}
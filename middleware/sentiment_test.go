@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// replyModel answers every request with a fixed reply or error.
+type replyModel struct {
+	reply string
+	err   error
+}
+
+func (m replyModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	return m.reply, m.err
+}
+
+func (m replyModel) Name() string     { return "reply" }
+func (m replyModel) Provider() string { return "test" }
+
+func TestLexiconAnalyzer(t *testing.T) {
+	analyzer := NewLexiconAnalyzer(nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		message string
+		label   string
+	}{
+		{"Thanks, that was really helpful!", SentimentPositive},
+		{"This is terrible, I hate waiting for support", SentimentNegative},
+		{"What are your opening hours?", SentimentNeutral},
+		{"The update is not good", SentimentNegative},
+		{"It's not bad at all", SentimentPositive},
+	}
+	for _, tt := range tests {
+		result, err := analyzer.Analyze(ctx, tt.message)
+		if err != nil || result.Label != tt.label {
+			t.Errorf("%q: expected %s, got %+v, %v", tt.message, tt.label, result, err)
+		}
+	}
+
+	calm, _ := analyzer.Analyze(ctx, "this is bad")
+	loud, _ := analyzer.Analyze(ctx, "THIS IS VERY BAD!!!")
+	if loud.Score >= calm.Score || loud.Score < -1 {
+		t.Errorf("expected shouting to be more negative: %v vs %v", loud.Score, calm.Score)
+	}
+
+	angry, _ := analyzer.Analyze(ctx, "I'm furious and frustrated, this is ridiculous")
+	if angry.Emotions["anger"] <= angry.Emotions["frustration"] || angry.Emotions["frustration"] == 0 {
+		t.Errorf("unexpected emotions %v", angry.Emotions)
+	}
+
+	custom := NewLexiconAnalyzer(map[string]float64{"Meh": -1.5})
+	if result, _ := custom.Analyze(ctx, "meh"); result.Label != SentimentNegative {
+		t.Errorf("expected extra word to count, got %+v", result)
+	}
+}
+
+func TestModelAnalyzer(t *testing.T) {
+	ctx := context.Background()
+
+	model := replyModel{reply: "```json\n{\"score\": -1.4, \"emotions\": {\"anger\": 0.8, \"joy\": 0}}\n```"}
+	result, err := NewModelAnalyzer(model, nil).Analyze(ctx, "great, broken again")
+	if err != nil || result.Score != -1 || result.Label != SentimentNegative || result.Source != "model" {
+		t.Errorf("unexpected result %+v, %v", result, err)
+	}
+	if len(result.Emotions) != 1 || result.Emotions["anger"] != 0.8 {
+		t.Errorf("unexpected emotions %v", result.Emotions)
+	}
+
+	failing := replyModel{err: errors.New("provider down")}
+	if _, err := NewModelAnalyzer(failing, nil).Analyze(ctx, "hi"); err == nil {
+		t.Error("expected error without fallback")
+	}
+	result, err = NewModelAnalyzer(replyModel{reply: "positive"}, NewLexiconAnalyzer(nil)).Analyze(ctx, "thanks!")
+	if err != nil || result.Source != "lexicon" || result.Label != SentimentPositive {
+		t.Errorf("expected lexicon fallback, got %+v, %v", result, err)
+	}
+}
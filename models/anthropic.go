@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 // AnthropicModel implements the Model interface for Anthropic's Claude API.
@@ -40,11 +41,22 @@ func NewAnthropicModel(cfg config.AnthropicConfig) (*AnthropicModel, error) {
 
 // anthropicRequest represents the request structure for Anthropic's API.
 type anthropicRequest struct {
-	Model     string                 `json:"model"`
-	MaxTokens int                    `json:"max_tokens"`
-	Messages  []anthropicMessage     `json:"messages"`
-	System    string                 `json:"system,omitempty"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Model         string                 `json:"model"`
+	MaxTokens     int                    `json:"max_tokens"`
+	Messages      []anthropicMessage     `json:"messages"`
+	System        string                 `json:"system,omitempty"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Thinking      *anthropicThinking     `json:"thinking,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+}
+
+// anthropicThinking enables extended thinking for a request.
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 // anthropicMessage represents a message in the conversation.
@@ -71,6 +83,26 @@ type anthropicContent struct {
 	Text string `json:"text"`
 }
 
+// anthropicStreamEvent represents an event of a streaming response.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"delta"`
+	Error anthropicError `json:"error"`
+}
+
+// anthropicErrorStatuses are the HTTP statuses of the error types Anthropic
+// may report in an error event, once a stream has begun.
+var anthropicErrorStatuses = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
 // anthropicUsage represents token usage information.
 type anthropicUsage struct {
 	InputTokens  int `json:"input_tokens"`
@@ -85,24 +117,96 @@ type anthropicError struct {
 
 // Ask sends a message to Claude and returns the response.
 func (a *AnthropicModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	req, err := a.newRequest(message, context)
+	if err != nil {
+		return "", err
+	}
+
+	// Marshal the request
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.endpoint(), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", a.config.APIKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	// Send the request
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return "", requestError(err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Handle error responses
+	if resp.StatusCode != http.StatusOK {
+		return "", a.apiError(resp.StatusCode, body)
+	}
+
+	// Parse the response
+	var anthropicResp anthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// Extract the text content
+	if len(anthropicResp.Content) == 0 {
+		return "", fmt.Errorf("no content in response")
+	}
+
+	// Thinking blocks come before the answer and are not part of it
+	var responseText strings.Builder
+	for _, content := range anthropicResp.Content {
+		if content.Type == "text" {
+			responseText.WriteString(content.Text)
+		}
+	}
+
+	if responseText.Len() == 0 {
+		return "", fmt.Errorf("no text content in response")
+	}
+
+	reportMetadata(ctx, anthropicResp.Model, anthropicResp.StopReason, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
+	return responseText.String(), nil
+}
+
+// newRequest builds the Messages API request for message, with the history,
+// system message, generation settings and thinking budget from the context.
+func (a *AnthropicModel) newRequest(message string, context map[string]interface{}) (anthropicRequest, error) {
+	// Resolve generation settings; Anthropic requires max_tokens on every request
+	gen := resolveGeneration(a.config.Generation, config.GenerationConfig{MaxTokens: a.maxTokens}, context)
+
 	// Prepare the request
 	req := anthropicRequest{
 		Model:     a.config.Model,
-		MaxTokens: a.maxTokens,
+		MaxTokens: gen.MaxTokens,
 		Messages: []anthropicMessage{
 			{
 				Role:    "user",
 				Content: message,
 			},
 		},
+		Temperature:   gen.Temperature,
+		TopP:          gen.TopP,
+		StopSequences: gen.Stop,
 	}
 
-	// Add system message if provided in context
-	if systemMsg, ok := context["system"]; ok {
-		if sys, ok := systemMsg.(string); ok {
-			req.System = sys
-		}
-	}
+	// Add the system message
+	req.System = systemPrompt(context)
 
 	// Add conversation history if provided
 	if history, ok := context["history"]; ok {
@@ -130,68 +234,164 @@ func (a *AnthropicModel) Ask(ctx context.Context, message string, context map[st
 		}
 	}
 
-	// Marshal the request
-	reqBody, err := json.Marshal(req)
+	// Enable extended thinking if a budget is set
+	if budget := a.thinkingBudget(context); budget > 0 {
+		if budget < config.MinThinkingBudget {
+			return req, fmt.Errorf("thinking budget must be at least %d tokens, got %d", config.MinThinkingBudget, budget)
+		}
+		req.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: budget}
+
+		// max_tokens covers the thinking too, so leave room for the answer
+		if req.MaxTokens <= budget {
+			req.MaxTokens = budget + gen.MaxTokens
+		}
+
+		// Thinking is incompatible with modified temperature and top_p
+		req.Temperature = nil
+		req.TopP = nil
+	}
+
+	return req, nil
+}
+
+// thinkingBudget returns the extended thinking budget for a request: the
+// "thinking_budget" context value if set, or the configured budget. A
+// budget of 0 disables thinking.
+func (a *AnthropicModel) thinkingBudget(context map[string]interface{}) int {
+	if budget, ok := context["thinking_budget"].(int); ok {
+		return budget
+	}
+	return a.config.ThinkingBudget
+}
+
+// apiError converts an error response into an error of its kind.
+func (a *AnthropicModel) apiError(status int, body []byte) error {
+	var errResp anthropicError
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		return markAPIError(status, fmt.Errorf("anthropic API error: %s", errResp.Message))
+	}
+	return markAPIError(status, fmt.Errorf("anthropic API error: status %d, body: %s", status, string(body)))
+}
+
+// AskStream sends a streaming request to Claude and returns a channel of
+// responses. With extended thinking, the thinking deltas are sent to the
+// channel from streaming.ThinkingFromContext, if any, and not to the
+// returned channel.
+func (a *AnthropicModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	req, err := a.newRequest(message, context)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
+	req.Stream = true
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(reqBody))
+	reqBody, err := json.Marshal(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Set headers
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.endpoint(), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("x-api-key", a.config.APIKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-cache")
 
-	// Send the request
 	resp, err := a.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, requestError(err)
 	}
-	defer resp.Body.Close()
-
-	// Read the response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, a.apiError(resp.StatusCode, body)
 	}
 
-	// Handle error responses
-	if resp.StatusCode != http.StatusOK {
-		var errResp anthropicError
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			return "", fmt.Errorf("anthropic API error: %s", errResp.Message)
+	return readAnthropicStream(ctx, resp.Body, a.config.MaxStreamLineSize), nil
+}
+
+// readAnthropicStream reads a Messages API SSE stream and returns a channel
+// of the text deltas. Thinking deltas go to the context's thinking channel.
+// The body is closed when the stream ends; a line longer than maxLineSize
+// bytes ends it with an error.
+func readAnthropicStream(ctx context.Context, body io.ReadCloser, maxLineSize int) <-chan string {
+	responseCh := make(chan string, 10)
+
+	// The stop reason, usage and model are reported to the caller's
+	// metadata, if any, before the channel is closed
+	meta, _ := streaming.MetadataFromContext(ctx)
+	thinkingCh, _ := streaming.ThinkingFromContext(ctx)
+
+	go func() {
+		defer close(responseCh)
+		defer body.Close()
+		defer streaming.Recover(ctx)
+
+		send := func(ch chan<- string, content string) bool {
+			select {
+			case ch <- content:
+				return true
+			case <-ctx.Done():
+				return false
+			}
 		}
-		return "", fmt.Errorf("anthropic API error: status %d, body: %s", resp.StatusCode, string(body))
-	}
 
-	// Parse the response
-	var anthropicResp anthropicResponse
-	if err := json.Unmarshal(body, &anthropicResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
-	}
+		scanner := streaming.NewLineScanner(body, maxLineSize)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue // Skip event names, comments and blank lines
+			}
 
-	// Extract the text content
-	if len(anthropicResp.Content) == 0 {
-		return "", fmt.Errorf("no content in response")
-	}
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				continue // Skip malformed events
+			}
 
-	var responseText strings.Builder
-	for _, content := range anthropicResp.Content {
-		if content.Type == "text" {
-			responseText.WriteString(content.Text)
+			if meta != nil {
+				var chunk map[string]interface{}
+				if err := json.Unmarshal([]byte(data), &chunk); err == nil {
+					streaming.ExtractAnthropicMetadata(chunk, meta)
+				}
+			}
+
+			switch event.Type {
+			case "content_block_delta":
+				switch event.Delta.Type {
+				case "text_delta":
+					if event.Delta.Text != "" && !send(responseCh, event.Delta.Text) {
+						return
+					}
+				case "thinking_delta":
+					if thinkingCh != nil && event.Delta.Thinking != "" && !send(thinkingCh, event.Delta.Thinking) {
+						return
+					}
+				}
+			case "error":
+				err := fmt.Errorf("anthropic stream error: %s", event.Error.Message)
+				failStream(meta, markAPIError(anthropicErrorStatuses[event.Error.Type], err))
+				return
+			case "message_stop":
+				return
+			}
 		}
-	}
 
-	if responseText.Len() == 0 {
-		return "", fmt.Errorf("no text content in response")
-	}
+		if err := scanner.Err(); err != nil {
+			failStream(meta, streaming.ScanError(err, maxLineSize))
+		}
+	}()
 
-	return responseText.String(), nil
+	return responseCh
+}
+
+// endpoint returns the configured Messages API URL.
+func (a *AnthropicModel) endpoint() string {
+	if a.config.Endpoint != "" {
+		return a.config.Endpoint
+	}
+	return "https://api.anthropic.com/v1/messages"
 }
 
 // Name returns the name of the model.
@@ -204,8 +404,23 @@ func (a *AnthropicModel) Provider() string {
 	return "anthropic"
 }
 
-// Health checks if the Anthropic API is accessible.
+// Health checks if the Anthropic API is accessible by listing its models,
+// or with a tiny completion if it has no models endpoint and
+// CompletionHealthCheck is set.
 func (a *AnthropicModel) Health(ctx context.Context) error {
+	header := http.Header{
+		"X-Api-Key":         {a.config.APIKey},
+		"Anthropic-Version": {"2023-06-01"},
+	}
+	url := baseEndpoint(a.endpoint(), "/messages") + "/models"
+	err := checkModelsEndpoint(ctx, a.httpClient, url, header, "anthropic")
+	return healthFallback(err, a.config.CompletionHealthCheck, func() error {
+		return a.completionHealth(ctx)
+	})
+}
+
+// completionHealth checks the Anthropic API with a minimal completion.
+func (a *AnthropicModel) completionHealth(ctx context.Context) error {
 	// Create a simple test request
 	req := anthropicRequest{
 		Model:     a.config.Model,
@@ -223,7 +438,7 @@ func (a *AnthropicModel) Health(ctx context.Context) error {
 		return fmt.Errorf("failed to marshal health check request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.endpoint(), bytes.NewBuffer(reqBody))
 	if err != nil {
 		return fmt.Errorf("failed to create health check request: %w", err)
 	}
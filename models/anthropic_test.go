@@ -2,13 +2,18 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go.rumenx.com/chatbot/chaterrors"
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestNewAnthropicModel(t *testing.T) {
@@ -134,3 +139,149 @@ func TestAnthropicModel_ConversationHistory(t *testing.T) {
 	assert.Error(t, err)
 	assert.Empty(t, response)
 }
+
+func TestAnthropicModel_MaxTokensAndThinking(t *testing.T) {
+	var request anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = anthropicRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"content":[{"type":"thinking","thinking":"Hmm."},{"type":"text","text":"42"}]}`))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel(config.AnthropicConfig{APIKey: "test-key", Endpoint: server.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Per-request max_tokens
+	response, err := model.Ask(ctx, "Hello", map[string]interface{}{"max_tokens": 300, "temperature": 0.5})
+	require.NoError(t, err)
+	assert.Equal(t, "42", response)
+	assert.Equal(t, 300, request.MaxTokens)
+	assert.Nil(t, request.Thinking)
+	require.NotNil(t, request.Temperature)
+
+	// Thinking per request; the answer keeps its max_tokens on top of the
+	// budget, and the thinking block is not part of the reply
+	response, err = model.Ask(ctx, "Hello", map[string]interface{}{"max_tokens": 300, "temperature": 0.5, "thinking_budget": 2048})
+	require.NoError(t, err)
+	assert.Equal(t, "42", response)
+	require.NotNil(t, request.Thinking)
+	assert.Equal(t, anthropicThinking{Type: "enabled", BudgetTokens: 2048}, *request.Thinking)
+	assert.Equal(t, 2348, request.MaxTokens)
+	assert.Nil(t, request.Temperature)
+
+	// A max_tokens above the budget is kept
+	_, err = model.Ask(ctx, "Hello", map[string]interface{}{"max_tokens": 8000, "thinking_budget": 2048})
+	require.NoError(t, err)
+	assert.Equal(t, 8000, request.MaxTokens)
+
+	// Thinking from the configuration, disabled per request
+	model.config.ThinkingBudget = 1024
+	_, err = model.Ask(ctx, "Hello", nil)
+	require.NoError(t, err)
+	require.NotNil(t, request.Thinking)
+	assert.Equal(t, 1024, request.Thinking.BudgetTokens)
+	_, err = model.Ask(ctx, "Hello", map[string]interface{}{"thinking_budget": 0})
+	require.NoError(t, err)
+	assert.Nil(t, request.Thinking)
+
+	_, err = model.Ask(ctx, "Hello", map[string]interface{}{"thinking_budget": 100})
+	assert.ErrorContains(t, err, "thinking budget must be at least 1024 tokens")
+}
+
+func TestAnthropicModel_AskStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request anthropicRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.True(t, request.Stream)
+		assert.NotNil(t, request.Thinking)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"model":"claude-sonnet-4","usage":{"input_tokens":10}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me think."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"abc"}}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":" world"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
+			`{"type":"message_stop"}`,
+		} {
+			w.Write([]byte("event: x\ndata: " + event + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel(config.AnthropicConfig{APIKey: "test-key", Endpoint: server.URL, ThinkingBudget: 1024})
+	require.NoError(t, err)
+
+	meta := &streaming.Metadata{}
+	thinking := make(chan string)
+	ctx := streaming.NewThinkingContext(streaming.NewMetadataContext(context.Background(), meta), thinking)
+	ch, err := model.AskStream(ctx, "Hello", nil)
+	require.NoError(t, err)
+
+	var text, reasoning string
+	for ch != nil {
+		select {
+		case chunk, ok := <-ch:
+			if !ok {
+				ch = nil
+				continue
+			}
+			text += chunk
+		case delta := <-thinking:
+			assert.Empty(t, text, "expected the thinking before the answer")
+			reasoning += delta
+		}
+	}
+
+	assert.Equal(t, "Hello world", text)
+	assert.Equal(t, "Let me think.", reasoning)
+	assert.Equal(t, "end_turn", meta.FinishReason)
+	assert.Equal(t, "claude-sonnet-4", meta.Model)
+	require.NotNil(t, meta.Usage)
+	assert.Equal(t, 30, meta.Usage.TotalTokens)
+}
+
+func TestAnthropicModel_AskStream_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"authentication_error","message":"invalid x-api-key"}`))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel(config.AnthropicConfig{APIKey: "test-key", Endpoint: server.URL})
+	require.NoError(t, err)
+
+	ch, err := model.AskStream(context.Background(), "Hello", nil)
+	assert.ErrorContains(t, err, "anthropic API error: invalid x-api-key")
+	assert.Nil(t, ch)
+}
+
+func TestAnthropicModel_AskStream_ErrorEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n"))
+		w.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicModel(config.AnthropicConfig{APIKey: "test-key", Endpoint: server.URL})
+	require.NoError(t, err)
+
+	meta := &streaming.Metadata{}
+	ch, err := model.AskStream(streaming.NewMetadataContext(context.Background(), meta), "Hello", nil)
+	require.NoError(t, err)
+
+	var text string
+	for chunk := range ch {
+		text += chunk
+	}
+	assert.Equal(t, "Hello", text, "expected the error not to be sent as content")
+	assert.Equal(t, "error", meta.FinishReason)
+	assert.ErrorContains(t, meta.Err, "Overloaded")
+	assert.ErrorIs(t, meta.Err, chaterrors.ErrProviderUnavailable)
+}
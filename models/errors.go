@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/streaming"
+)
+
+// contextTooLongMarkers and moderationMarkers are what providers' error
+// messages say when a request exceeds the context window or breaks the
+// content policy. Providers report both as plain 400s.
+var (
+	contextTooLongMarkers = []string{
+		"context_length_exceeded", "maximum context length", "context window",
+		"prompt is too long", "too many tokens", "input is too long",
+	}
+	moderationMarkers = []string{
+		"content_policy_violation", "content_filter", "content management policy",
+		"safety system",
+	}
+)
+
+// requestError reports a request that did not reach the provider.
+func requestError(err error) error {
+	return unavailable(fmt.Errorf("failed to send request: %w", err))
+}
+
+// unavailable marks err as the provider being unavailable, unless the
+// caller gave up.
+func unavailable(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return chaterrors.Mark(err, chaterrors.ErrProviderUnavailable)
+}
+
+// failStream records in meta, if the caller passed one, that a stream failed
+// partway, so that it ends with an error chunk instead of the error being
+// sent as content.
+func failStream(meta *streaming.Metadata, err error) {
+	if meta != nil {
+		meta.Fail(err)
+	}
+}
+
+// markAPIError marks the error for a provider response with the given status
+// with the kind of error it stands for, if any.
+func markAPIError(status int, err error) error {
+	message := strings.ToLower(err.Error())
+	switch {
+	case status == http.StatusTooManyRequests || status >= 500:
+		return chaterrors.Mark(err, chaterrors.ErrProviderUnavailable)
+	case containsAny(message, contextTooLongMarkers):
+		return chaterrors.Mark(err, chaterrors.ErrContextTooLong)
+	case containsAny(message, moderationMarkers):
+		return chaterrors.Mark(err, chaterrors.ErrModeration)
+	}
+	return err
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
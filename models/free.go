@@ -3,20 +3,77 @@ package models
 import (
 	"context"
 	"crypto/rand"
+	"fmt"
 	"math/big"
+	mathrand "math/rand"
+	"regexp"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
+// freeHistoryTurns is how many recent history messages the free model
+// looks at.
+const freeHistoryTurns = 6
+
+// defaultFreeStreamDelay is the pause between streamed words.
+const defaultFreeStreamDelay = 50 * time.Millisecond
+
+// personaPattern finds the bot's name in a system prompt.
+var personaPattern = regexp.MustCompile(`(?i)(?:your name is|you are called|call yourself)\s+([\p{L}\d-]+)`)
+
 // FreeModel is a simple fallback model that provides basic responses
-// without requiring API keys or external services.
+// without requiring API keys or external services. Its replies take the
+// system prompt, the recent history and the generation settings of the
+// request into account, so local testing behaves like a real provider.
 type FreeModel struct {
-	responses []string
+	responses   []string
+	templates   []*freeTemplate
+	streamDelay time.Duration
+
+	// rng picks the canned replies when seeded; nil uses crypto/rand.
+	mu  sync.Mutex
+	rng *mathrand.Rand
+}
+
+// freeTemplate is a compiled config.FreeResponse.
+type freeTemplate struct {
+	patterns []*regexp.Regexp
+	response *template.Template
+}
+
+// freeTemplateData is passed to response templates.
+type freeTemplateData struct {
+	// Message is the user's message.
+	Message string
+	// Match is the matched keyword or pattern text.
+	Match string
+	// Groups are the pattern's submatches; Groups[0] is the whole match.
+	Groups []string
+	// Named holds the pattern's named groups.
+	Named map[string]string
+	// Context is the request context.
+	Context map[string]interface{}
 }
 
 // NewFreeModel creates a new free model instance.
 func NewFreeModel() *FreeModel {
-	return &FreeModel{
+	model, _ := NewFreeModelWithConfig(config.FreeConfig{})
+	return model
+}
+
+// NewFreeModelWithConfig creates a free model with a seed, stream delay and
+// custom responses. It fails if a response pattern or template is invalid.
+func NewFreeModelWithConfig(cfg config.FreeConfig) (*FreeModel, error) {
+	if cfg.StreamDelay <= 0 {
+		cfg.StreamDelay = defaultFreeStreamDelay
+	}
+
+	model := &FreeModel{
 		responses: []string{
 			"I'm a simple chatbot. How can I help you today?",
 			"Thanks for your message! I'm here to assist you.",
@@ -27,46 +84,302 @@ func NewFreeModel() *FreeModel {
 			"Hello! I'm an AI assistant. Feel free to ask me anything.",
 			"I'm here to help! What can I assist you with today?",
 		},
+		streamDelay: cfg.StreamDelay,
+	}
+	if cfg.Seed != 0 {
+		model.rng = mathrand.New(mathrand.NewSource(cfg.Seed))
+	}
+
+	for i, response := range cfg.Responses {
+		compiled, err := compileFreeTemplate(response)
+		if err != nil {
+			return nil, fmt.Errorf("invalid free model response %d: %w", i, err)
+		}
+		model.templates = append(model.templates, compiled)
 	}
+	return model, nil
+}
+
+// compileFreeTemplate compiles the keywords, patterns and template of r.
+func compileFreeTemplate(r config.FreeResponse) (*freeTemplate, error) {
+	compiled := &freeTemplate{}
+
+	for _, keyword := range r.Keywords {
+		words := strings.Fields(keyword)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		// Only anchor at word characters, so keywords like "C++" match
+		phrase := strings.Join(words, `\s+`)
+		if isWordByte(keyword[0]) {
+			phrase = `\b` + phrase
+		}
+		if isWordByte(keyword[len(keyword)-1]) {
+			phrase += `\b`
+		}
+		compiled.patterns = append(compiled.patterns, regexp.MustCompile(`(?i)`+phrase))
+	}
+	for _, pattern := range r.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		compiled.patterns = append(compiled.patterns, re)
+	}
+	if len(compiled.patterns) == 0 {
+		return nil, fmt.Errorf("no keywords or patterns")
+	}
+
+	tmpl, err := template.New("response").Parse(r.Response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response template: %w", err)
+	}
+	compiled.response = tmpl
+	return compiled, nil
+}
+
+// isWordByte reports whether b is matched by \w.
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
 }
 
 // Ask processes a message and returns a response.
 func (f *FreeModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	reply, truncated, err := f.respond(ctx, message, context)
+	if err != nil {
+		return "", err
+	}
+
+	finishReason := "stop"
+	if truncated {
+		finishReason = "length"
+	}
+	reportMetadata(ctx, f.Name(), finishReason, 0, 0)
+	return reply, nil
+}
+
+// AskStream returns the same reply as Ask, one word at a time with the
+// configured delay in between, to exercise streaming clients locally.
+func (f *FreeModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	reply, truncated, err := f.respond(ctx, message, context)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, _ := streaming.MetadataFromContext(ctx)
+	responseCh := make(chan string)
+
+	go func() {
+		defer close(responseCh)
+		defer streaming.Recover(ctx)
+
+		for i, word := range strings.SplitAfter(reply, " ") {
+			if i > 0 {
+				select {
+				case <-time.After(f.streamDelay):
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case responseCh <- word:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if meta != nil {
+			meta.Model = f.Name()
+			meta.FinishReason = "stop"
+			if truncated {
+				meta.FinishReason = "length"
+			}
+		}
+	}()
+
+	return responseCh, nil
+}
+
+// respond builds the reply to message and reports whether max_tokens cut
+// it short.
+func (f *FreeModel) respond(ctx context.Context, message string, context map[string]interface{}) (string, bool, error) {
 	// Simulate processing time with context awareness
 	select {
 	case <-time.After(100 * time.Millisecond):
 		// Continue processing
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return "", false, ctx.Err()
+	}
+
+	prompt := systemPrompt(context)
+
+	// Custom responses are answered as written
+	reply, ok, err := f.templateReply(message, context)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok {
+		reply = f.reply(message, prompt, recentHistory(context), context)
+
+		// A system prompt asking for brevity keeps the first sentence
+		lowerPrompt := strings.ToLower(prompt)
+		if strings.Contains(lowerPrompt, "brief") || strings.Contains(lowerPrompt, "concise") {
+			if end := strings.IndexAny(reply, ".!?"); end > 0 {
+				reply = reply[:end+1]
+			}
+		}
 	}
 
+	reply, truncated := limitReply(reply, context)
+	return reply, truncated, nil
+}
+
+// templateReply renders the first custom response matching message, and
+// reports whether one matched.
+func (f *FreeModel) templateReply(message string, context map[string]interface{}) (string, bool, error) {
+	for _, t := range f.templates {
+		for _, re := range t.patterns {
+			groups := re.FindStringSubmatch(message)
+			if groups == nil {
+				continue
+			}
+
+			data := freeTemplateData{
+				Message: message,
+				Match:   groups[0],
+				Groups:  groups,
+				Named:   make(map[string]string),
+				Context: context,
+			}
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					data.Named[name] = groups[i]
+				}
+			}
+
+			var reply strings.Builder
+			if err := t.response.Execute(&reply, data); err != nil {
+				return "", false, fmt.Errorf("failed to render free model response: %w", err)
+			}
+			return reply.String(), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// reply picks the reply to message from simple keyword heuristics.
+func (f *FreeModel) reply(message, prompt string, history []map[string]interface{}, context map[string]interface{}) string {
 	// Simple response based on message content
 	message = strings.ToLower(strings.TrimSpace(message))
+	lastUser := lastMessage(history, "user")
 
 	switch {
+	case strings.Contains(message, "what did i") || strings.Contains(message, "earlier"):
+		if lastUser == "" {
+			return "We haven't talked about anything else yet."
+		}
+		return fmt.Sprintf("Earlier you said: %q.", lastUser)
 	case strings.Contains(message, "hello") || strings.Contains(message, "hi"):
-		return "Hello! Nice to meet you. How can I help you today?", nil
+		if lastMessage(history, "assistant") != "" {
+			return "Hello again! What else can I help you with?"
+		}
+		return "Hello! Nice to meet you. How can I help you today?"
 	case strings.Contains(message, "how are you"):
-		return "I'm doing well, thank you for asking! How are you?", nil
+		return "I'm doing well, thank you for asking! How are you?"
 	case strings.Contains(message, "thank"):
-		return "You're welcome! I'm happy to help.", nil
+		return "You're welcome! I'm happy to help."
 	case strings.Contains(message, "bye") || strings.Contains(message, "goodbye"):
-		return "Goodbye! Have a great day!", nil
+		return "Goodbye! Have a great day!"
 	case strings.Contains(message, "help"):
-		return "I'm here to help! Feel free to ask me any questions.", nil
+		return "I'm here to help! Feel free to ask me any questions."
 	case strings.Contains(message, "name"):
-		return "I'm a simple AI chatbot. You can call me Bot!", nil
+		if match := personaPattern.FindStringSubmatch(prompt); match != nil {
+			return fmt.Sprintf("I'm %s, a simple AI chatbot.", match[1])
+		}
+		return "I'm a simple AI chatbot. You can call me Bot!"
 	case strings.Contains(message, "?"):
-		return "That's an interesting question! While I'm a basic model, I'll do my best to help.", nil
+		return "That's an interesting question! While I'm a basic model, I'll do my best to help."
 	default:
-		// Return a random response for other messages using crypto/rand
-		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(f.responses))))
-		if err != nil {
-			// Fallback to first response if random generation fails
-			return f.responses[0], nil
+		response := f.pick(context)
+		if lastUser != "" {
+			return fmt.Sprintf("You mentioned %q before. %s", truncateWords(lastUser, 8), response)
 		}
-		return f.responses[n.Int64()], nil
+		return response
+	}
+}
+
+// pick returns a canned response: seeded by the "seed" context value or
+// the model's seed, or at random.
+func (f *FreeModel) pick(context map[string]interface{}) string {
+	if seed, ok := context["seed"].(int); ok {
+		return f.responses[mathrand.New(mathrand.NewSource(int64(seed))).Intn(len(f.responses))]
+	}
+	if f.rng != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.responses[f.rng.Intn(len(f.responses))]
+	}
+
+	// Return a random response using crypto/rand
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(f.responses))))
+	if err != nil {
+		// Fallback to first response if random generation fails
+		return f.responses[0]
+	}
+	return f.responses[n.Int64()]
+}
+
+// recentHistory returns the last freeHistoryTurns messages of the "history"
+// context value.
+func recentHistory(context map[string]interface{}) []map[string]interface{} {
+	history, _ := context["history"].([]map[string]interface{})
+	if len(history) > freeHistoryTurns {
+		history = history[len(history)-freeHistoryTurns:]
+	}
+	return history
+}
+
+// lastMessage returns the content of the last history message from role.
+func lastMessage(history []map[string]interface{}, role string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i]["role"] == role {
+			if content, ok := history[i]["content"].(string); ok && content != "" {
+				return content
+			}
+		}
+	}
+	return ""
+}
+
+// limitReply applies the "stop" and "max_tokens" context values, counting
+// a word as a token, and reports whether max_tokens cut the reply short.
+func limitReply(reply string, context map[string]interface{}) (string, bool) {
+	if stop, ok := context["stop"].([]string); ok {
+		for _, sequence := range stop {
+			if i := strings.Index(reply, sequence); sequence != "" && i >= 0 {
+				reply = reply[:i]
+			}
+		}
+	}
+
+	if maxTokens, ok := context["max_tokens"].(int); ok && maxTokens > 0 {
+		if limited := truncateWords(reply, maxTokens); limited != reply {
+			return limited, true
+		}
+	}
+	return reply, false
+}
+
+// truncateWords returns the first n words of s.
+func truncateWords(s string, n int) string {
+	words := strings.Fields(s)
+	if len(words) <= n {
+		return s
 	}
+	return strings.Join(words[:n], " ")
 }
 
 // Name returns the name of the model.
@@ -2,10 +2,15 @@ package models
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestFreeModel(t *testing.T) {
@@ -102,3 +107,149 @@ func TestFreeModelTimeout(t *testing.T) {
 	assert.Equal(t, context.DeadlineExceeded, err)
 	assert.Empty(t, response)
 }
+
+func TestFreeModelPromptAndHistory(t *testing.T) {
+	model := NewFreeModel()
+	ctx := context.Background()
+	history := []map[string]interface{}{
+		{"role": "user", "content": "I like hiking in the mountains"},
+		{"role": "assistant", "content": "That sounds lovely!"},
+	}
+
+	response, err := model.Ask(ctx, "What's your name", map[string]interface{}{"prompt": "Your name is Ada. Be helpful."})
+	require.NoError(t, err)
+	assert.Equal(t, "I'm Ada, a simple AI chatbot.", response)
+
+	response, err = model.Ask(ctx, "What did I tell you?", map[string]interface{}{"history": history})
+	require.NoError(t, err)
+	assert.Equal(t, `Earlier you said: "I like hiking in the mountains".`, response)
+
+	response, err = model.Ask(ctx, "hello", map[string]interface{}{"history": history})
+	require.NoError(t, err)
+	assert.Contains(t, response, "Hello again")
+
+	response, err = model.Ask(ctx, "Tell me more", map[string]interface{}{"history": history})
+	require.NoError(t, err)
+	assert.Contains(t, response, `You mentioned "I like hiking in the mountains" before.`)
+
+	// A system prompt asking for brevity keeps the first sentence
+	response, err = model.Ask(ctx, "hello", map[string]interface{}{"system": "Be brief."})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello!", response)
+}
+
+func TestFreeModelGeneration(t *testing.T) {
+	model := NewFreeModel()
+	ctx := context.Background()
+
+	response, err := model.Ask(ctx, "hello", map[string]interface{}{"max_tokens": 3})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello! Nice to", response)
+
+	response, err = model.Ask(ctx, "hello", map[string]interface{}{"stop": []string{" to"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello! Nice", response)
+}
+
+func TestFreeModelSeed(t *testing.T) {
+	ctx := context.Background()
+	replies := func(model *FreeModel) []string {
+		var out []string
+		for i := 0; i < 5; i++ {
+			response, err := model.Ask(ctx, "Tell me something", nil)
+			require.NoError(t, err)
+			out = append(out, response)
+		}
+		return out
+	}
+
+	newModel := func() *FreeModel {
+		model, err := NewFreeModelWithConfig(config.FreeConfig{Seed: 42})
+		require.NoError(t, err)
+		return model
+	}
+	assert.Equal(t, replies(newModel()), replies(newModel()))
+
+	// A per-request seed gives the same reply every time
+	model := NewFreeModel()
+	want, err := model.Ask(ctx, "Tell me something", map[string]interface{}{"seed": 7})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		response, err := model.Ask(ctx, "Tell me something", map[string]interface{}{"seed": 7})
+		require.NoError(t, err)
+		assert.Equal(t, want, response)
+	}
+}
+
+func TestFreeModelAskStream(t *testing.T) {
+	model, err := NewFreeModelWithConfig(config.FreeConfig{StreamDelay: time.Millisecond})
+	require.NoError(t, err)
+	meta := &streaming.Metadata{}
+	ctx := streaming.NewMetadataContext(context.Background(), meta)
+
+	want, err := model.Ask(ctx, "hello", nil)
+	require.NoError(t, err)
+
+	ch, err := model.AskStream(ctx, "hello", nil)
+	require.NoError(t, err)
+	var chunks []string
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+
+	assert.Greater(t, len(chunks), 1)
+	assert.Equal(t, want, strings.Join(chunks, ""))
+	assert.Equal(t, "stop", meta.FinishReason)
+	assert.Equal(t, "free-model", meta.Model)
+
+	ch, err = model.AskStream(ctx, "hello", map[string]interface{}{"max_tokens": 2})
+	require.NoError(t, err)
+	for range ch {
+	}
+	assert.Equal(t, "length", meta.FinishReason)
+}
+
+func TestFreeModelResponses(t *testing.T) {
+	model, err := NewFreeModelWithConfig(config.FreeConfig{Responses: []config.FreeResponse{
+		{Keywords: []string{"opening hours", "open"}, Response: "We are open 9-17, Monday to Friday."},
+		{Patterns: []string{`(?i)order\s+#?(?P<id>\d+)`}, Response: "Order {{.Named.id}} ships today ({{index .Groups 1}})."},
+		{Keywords: []string{"refund"}, Response: "Refunds for {{.Context.user_id}} take 5 days."},
+	}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		message string
+		context map[string]interface{}
+		want    string
+	}{
+		{message: "What are your Opening  Hours?", want: "We are open 9-17, Monday to Friday."},
+		{message: "Where is order #123?", want: "Order 123 ships today (123)."},
+		{message: "I want a refund", context: map[string]interface{}{"user_id": "u1"}, want: "Refunds for u1 take 5 days."},
+		// Keywords match whole words only; unmatched messages use the built-in replies
+		{message: "hello, reopened", want: "Hello! Nice to meet you. How can I help you today?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			response, err := model.Ask(ctx, tt.message, tt.context)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, response)
+		})
+	}
+
+	// Generation settings still apply
+	response, err := model.Ask(ctx, "open?", map[string]interface{}{"max_tokens": 3})
+	require.NoError(t, err)
+	assert.Equal(t, "We are open", response)
+}
+
+func TestFreeModelResponses_Invalid(t *testing.T) {
+	for _, response := range []config.FreeResponse{
+		{Patterns: []string{"("}, Response: "x"},
+		{Keywords: []string{"hi"}, Response: "{{.Missing"},
+		{Response: "no trigger"},
+	} {
+		_, err := NewFreeModelWithConfig(config.FreeConfig{Responses: []config.FreeResponse{response}})
+		assert.Error(t, err)
+	}
+}
@@ -38,9 +38,10 @@ func NewGeminiModel(cfg config.GeminiConfig) (*GeminiModel, error) {
 
 // geminiRequest represents the request structure for Gemini's API.
 type geminiRequest struct {
-	Contents         []geminiContent         `json:"contents"`
-	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
-	SafetySettings   []geminiSafetySetting   `json:"safetySettings,omitempty"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []geminiSafetySetting   `json:"safetySettings,omitempty"`
 }
 
 // geminiContent represents content in the request.
@@ -61,6 +62,32 @@ type geminiGenerationConfig struct {
 	TopP            float64  `json:"topP,omitempty"`
 	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
 	StopSequences   []string `json:"stopSequences,omitempty"`
+
+	// set records a Temperature or TopP of 0 to send
+	set samplingSet
+}
+
+// MarshalJSON implements json.Marshaler, sending a Temperature or TopP of 0
+// that was set.
+func (c geminiGenerationConfig) MarshalJSON() ([]byte, error) {
+	type generationConfig geminiGenerationConfig
+	return json.Marshal(struct {
+		generationConfig
+		Temperature *float64 `json:"temperature,omitempty"`
+		TopP        *float64 `json:"topP,omitempty"`
+	}{generationConfig(c), sent(c.Temperature, c.set.temperature), sent(c.TopP, c.set.topP)})
+}
+
+// newGeminiGenerationConfig returns the generation configuration of a
+// request with the resolved settings gen.
+func newGeminiGenerationConfig(gen config.GenerationConfig) *geminiGenerationConfig {
+	generation := &geminiGenerationConfig{
+		TopK:            40,
+		MaxOutputTokens: gen.MaxTokens,
+		StopSequences:   gen.Stop,
+	}
+	generation.Temperature, generation.TopP, generation.set = sampling(gen)
+	return generation
 }
 
 // geminiSafetySetting represents safety settings.
@@ -105,8 +132,19 @@ type geminiError struct {
 	} `json:"error"`
 }
 
+// geminiFallbackGeneration holds the settings used when neither the provider
+// configuration nor the request specify a value.
+var geminiFallbackGeneration = config.GenerationConfig{
+	MaxTokens:   1000,
+	Temperature: floatPtr(0.7),
+	TopP:        floatPtr(0.8),
+}
+
 // Ask sends a message to Gemini and returns the response.
 func (g *GeminiModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	// Resolve generation defaults and per-request overrides
+	gen := resolveGeneration(g.config.Generation, geminiFallbackGeneration, context)
+
 	// Prepare the request
 	req := geminiRequest{
 		Contents: []geminiContent{
@@ -116,12 +154,7 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 				},
 			},
 		},
-		GenerationConfig: &geminiGenerationConfig{
-			Temperature:     0.7,
-			TopK:            40,
-			TopP:            0.8,
-			MaxOutputTokens: 1000,
-		},
+		GenerationConfig: newGeminiGenerationConfig(gen),
 		SafetySettings: []geminiSafetySetting{
 			{
 				Category:  "HARM_CATEGORY_HARASSMENT",
@@ -142,6 +175,11 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 		},
 	}
 
+	// Add the system message
+	if system := systemPrompt(context); system != "" {
+		req.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+
 	// Add conversation history if provided
 	if history, ok := context["history"]; ok {
 		if hist, ok := history.([]map[string]interface{}); ok {
@@ -169,18 +207,6 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 		}
 	}
 
-	// Override generation config from context if provided
-	if temp, ok := context["temperature"]; ok {
-		if temperature, ok := temp.(float64); ok {
-			req.GenerationConfig.Temperature = temperature
-		}
-	}
-	if maxTokens, ok := context["max_tokens"]; ok {
-		if tokens, ok := maxTokens.(int); ok {
-			req.GenerationConfig.MaxOutputTokens = tokens
-		}
-	}
-
 	// Marshal the request
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -188,11 +214,7 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 	}
 
 	// Construct URL
-	endpoint := "https://generativelanguage.googleapis.com"
-	if g.config.Endpoint != "" {
-		endpoint = g.config.Endpoint
-	}
-	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", endpoint, g.config.Model, g.config.APIKey)
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", g.apiBase(), g.config.Model, g.config.APIKey)
 
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
@@ -206,7 +228,7 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 	// Send the request
 	resp, err := g.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", requestError(err)
 	}
 	defer resp.Body.Close()
 
@@ -220,9 +242,9 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 	if resp.StatusCode != http.StatusOK {
 		var errResp geminiError
 		if err := json.Unmarshal(body, &errResp); err == nil {
-			return "", fmt.Errorf("gemini API error: %s", errResp.Error.Message)
+			return "", markAPIError(resp.StatusCode, fmt.Errorf("gemini API error: %s", errResp.Error.Message))
 		}
-		return "", fmt.Errorf("gemini API error: status %d, body: %s", resp.StatusCode, string(body))
+		return "", markAPIError(resp.StatusCode, fmt.Errorf("gemini API error: status %d, body: %s", resp.StatusCode, string(body)))
 	}
 
 	// Parse the response
@@ -250,6 +272,8 @@ func (g *GeminiModel) Ask(ctx context.Context, message string, context map[strin
 		return "", fmt.Errorf("no text content in response")
 	}
 
+	usage := geminiResp.UsageMetadata
+	reportMetadata(ctx, g.config.Model, candidate.FinishReason, usage.PromptTokenCount, usage.CandidatesTokenCount)
 	return responseText.String(), nil
 }
 
@@ -263,8 +287,27 @@ func (g *GeminiModel) Provider() string {
 	return "gemini"
 }
 
-// Health checks if the Gemini API is accessible.
+// apiBase returns the API base URL.
+func (g *GeminiModel) apiBase() string {
+	if g.config.Endpoint != "" {
+		return baseEndpoint(g.config.Endpoint, "/v1beta/models")
+	}
+	return "https://generativelanguage.googleapis.com"
+}
+
+// Health checks if the Gemini API is accessible by listing its models, or
+// with a tiny completion if it has no models endpoint and
+// CompletionHealthCheck is set.
 func (g *GeminiModel) Health(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1beta/models?key=%s", g.apiBase(), g.config.APIKey)
+	err := checkModelsEndpoint(ctx, g.httpClient, url, nil, "gemini")
+	return healthFallback(err, g.config.CompletionHealthCheck, func() error {
+		return g.completionHealth(ctx)
+	})
+}
+
+// completionHealth checks the Gemini API with a minimal completion.
+func (g *GeminiModel) completionHealth(ctx context.Context) error {
 	// Create a simple test request
 	req := geminiRequest{
 		Contents: []geminiContent{
@@ -284,11 +327,7 @@ func (g *GeminiModel) Health(ctx context.Context) error {
 		return fmt.Errorf("failed to marshal health check request: %w", err)
 	}
 
-	endpoint := "https://generativelanguage.googleapis.com"
-	if g.config.Endpoint != "" {
-		endpoint = g.config.Endpoint
-	}
-	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", endpoint, g.config.Model, g.config.APIKey)
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", g.apiBase(), g.config.Model, g.config.APIKey)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
 	if err != nil {
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"go.rumenx.com/chatbot/config"
+)
+
+// resolveGeneration returns the generation settings for a single request.
+// Values passed in the request context ("max_tokens", "temperature", "top_p",
+// "stop") take precedence over the provider defaults, which in turn take
+// precedence over the model's built-in fallback values.
+func resolveGeneration(defaults, fallback config.GenerationConfig, context map[string]interface{}) config.GenerationConfig {
+	gen := defaults.Merge(fallback)
+
+	if maxTokens, ok := context["max_tokens"].(int); ok && maxTokens > 0 {
+		gen.MaxTokens = maxTokens
+	}
+	if temperature, ok := context["temperature"].(float64); ok {
+		gen.Temperature = &temperature
+	}
+	if topP, ok := context["top_p"].(float64); ok {
+		gen.TopP = &topP
+	}
+	if stop, ok := context["stop"].([]string); ok && len(stop) > 0 {
+		gen.Stop = stop
+	}
+
+	return gen
+}
+
+// samplingSet records which of a request's temperature and top_p were set.
+// Request bodies keep them as float64 fields with omitempty, which drops a
+// value of 0, so their MarshalJSON methods send the fields set here even
+// when 0.
+type samplingSet struct {
+	temperature, topP bool
+}
+
+// sampling returns the temperature and top_p of gen, 0 for those not set,
+// and which of them were set.
+func sampling(gen config.GenerationConfig) (temperature, topP float64, set samplingSet) {
+	if gen.Temperature != nil {
+		temperature, set.temperature = *gen.Temperature, true
+	}
+	if gen.TopP != nil {
+		topP, set.topP = *gen.TopP, true
+	}
+	return temperature, topP, set
+}
+
+// sent returns the value of a sampling field to marshal: a pointer to it if
+// it was set or is not 0, and nil to omit it.
+func sent(value float64, set bool) *float64 {
+	if !set && value == 0 {
+		return nil
+	}
+	return &value
+}
+
+// floatPtr returns a pointer to v, for building fallback generation settings.
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// openAICompatibleFallbackGeneration holds the built-in settings of the
+// OpenAI-compatible Meta and xAI models.
+var openAICompatibleFallbackGeneration = config.GenerationConfig{
+	MaxTokens:   1000,
+	Temperature: floatPtr(0.7),
+	TopP:        floatPtr(1.0),
+}
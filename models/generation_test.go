@@ -0,0 +1,263 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestResolveGeneration(t *testing.T) {
+	defaults := config.GenerationConfig{MaxTokens: 300, Temperature: floatPtr(0.2)}
+	fallback := config.GenerationConfig{MaxTokens: 1000, Temperature: floatPtr(0.7), TopP: floatPtr(0.9)}
+
+	gen := resolveGeneration(defaults, fallback, map[string]interface{}{})
+	assert.Equal(t, 300, gen.MaxTokens)
+	assert.Equal(t, 0.2, *gen.Temperature)
+	assert.Equal(t, 0.9, *gen.TopP)
+
+	gen = resolveGeneration(defaults, fallback, map[string]interface{}{
+		"max_tokens":  50,
+		"temperature": 0.0,
+		"stop":        []string{"END"},
+	})
+	assert.Equal(t, 50, gen.MaxTokens)
+	assert.Equal(t, 0.0, *gen.Temperature, "explicit zero temperature must be honored")
+	assert.Equal(t, []string{"END"}, gen.Stop)
+}
+
+// captureRequest starts a server that records the JSON body of the last request.
+func captureRequest(t *testing.T, response string) (*httptest.Server, *map[string]interface{}) {
+	t.Helper()
+	body := make(map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func TestGeminiModel_HonorsGenerationDefaults(t *testing.T) {
+	server, body := captureRequest(t, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`)
+
+	model, err := NewGeminiModel(config.GeminiConfig{
+		APIKey:     "key",
+		Endpoint:   server.URL,
+		Generation: config.GenerationConfig{MaxTokens: 2048, Temperature: floatPtr(0.3)},
+	})
+	require.NoError(t, err)
+
+	_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{})
+	require.NoError(t, err)
+
+	generation := (*body)["generationConfig"].(map[string]interface{})
+	assert.Equal(t, float64(2048), generation["maxOutputTokens"])
+	assert.Equal(t, 0.3, generation["temperature"])
+
+	_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{"max_tokens": 64})
+	require.NoError(t, err)
+
+	generation = (*body)["generationConfig"].(map[string]interface{})
+	assert.Equal(t, float64(64), generation["maxOutputTokens"], "per-request override wins")
+}
+
+func TestAnthropicModel_HonorsGenerationDefaults(t *testing.T) {
+	server, body := captureRequest(t, `{"content":[{"type":"text","text":"ok"}]}`)
+
+	model, err := NewAnthropicModel(config.AnthropicConfig{
+		APIKey:     "key",
+		Endpoint:   server.URL,
+		Generation: config.GenerationConfig{MaxTokens: 4096, Stop: []string{"\n\nHuman:"}},
+	})
+	require.NoError(t, err)
+
+	_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{"temperature": 0.1})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(4096), (*body)["max_tokens"])
+	assert.Equal(t, 0.1, (*body)["temperature"])
+	assert.Equal(t, []interface{}{"\n\nHuman:"}, (*body)["stop_sequences"])
+}
+
+func TestOllamaModel_HonorsGenerationDefaults(t *testing.T) {
+	server, body := captureRequest(t, `{"message":{"role":"assistant","content":"ok"},"done":true}`)
+
+	model, err := NewOllamaModel(config.OllamaConfig{
+		Endpoint:   server.URL,
+		Model:      "llama3.2",
+		Generation: config.GenerationConfig{MaxTokens: 128, Temperature: floatPtr(0.4)},
+	})
+	require.NoError(t, err)
+
+	_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{"num_predict": 32})
+	require.NoError(t, err)
+
+	options := (*body)["options"].(map[string]interface{})
+	assert.Equal(t, 0.4, options["temperature"])
+	assert.Equal(t, float64(32), options["num_predict"], "native Ollama option wins")
+}
+
+func TestNewFromConfig_AppliesGlobalGeneration(t *testing.T) {
+	cfg := config.Default()
+	cfg.Model = "meta"
+	cfg.Meta.APIKey = "key"
+	cfg.MaxTokens = 512
+	cfg.Meta.Generation.Temperature = floatPtr(0.0)
+
+	model, err := NewFromConfig(cfg)
+	require.NoError(t, err)
+
+	meta := model.(*MetaModel)
+	assert.Equal(t, 512, meta.config.Generation.MaxTokens)
+	assert.Equal(t, 0.0, *meta.config.Generation.Temperature)
+}
+
+func TestNewFromConfig_RequestBodies(t *testing.T) {
+	openAICompatible := `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`
+	tests := []struct {
+		provider    string
+		response    string
+		configure   func(cfg *config.Config, endpoint string)
+		generation  func(body map[string]interface{}) map[string]interface{}
+		maxTokenKey string
+	}{
+		{"openai", openAICompatible, func(cfg *config.Config, endpoint string) {
+			cfg.OpenAI = config.OpenAIConfig{APIKey: "key", Endpoint: endpoint}
+		}, nil, "max_tokens"},
+		{"anthropic", `{"content":[{"type":"text","text":"ok"}]}`, func(cfg *config.Config, endpoint string) {
+			cfg.Anthropic = config.AnthropicConfig{APIKey: "key", Endpoint: endpoint}
+		}, nil, "max_tokens"},
+		{"gemini", `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`, func(cfg *config.Config, endpoint string) {
+			cfg.Gemini = config.GeminiConfig{APIKey: "key", Endpoint: endpoint}
+		}, func(body map[string]interface{}) map[string]interface{} {
+			return body["generationConfig"].(map[string]interface{})
+		}, "maxOutputTokens"},
+		{"xai", openAICompatible, func(cfg *config.Config, endpoint string) {
+			cfg.XAI = config.XAIConfig{APIKey: "key", Endpoint: endpoint}
+		}, nil, "max_tokens"},
+		{"meta", openAICompatible, func(cfg *config.Config, endpoint string) {
+			cfg.Meta = config.MetaConfig{APIKey: "key", Endpoint: endpoint}
+		}, nil, "max_tokens"},
+		{"ollama", `{"message":{"role":"assistant","content":"ok"},"done":true}`, func(cfg *config.Config, endpoint string) {
+			cfg.Ollama = config.OllamaConfig{Endpoint: endpoint, Model: "llama3.2"}
+		}, func(body map[string]interface{}) map[string]interface{} {
+			return body["options"].(map[string]interface{})
+		}, "num_predict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			server, body := captureRequest(t, tt.response)
+			generation := func() map[string]interface{} {
+				if tt.generation == nil {
+					return *body
+				}
+				return tt.generation(*body)
+			}
+
+			cfg := config.Default()
+			cfg.Model = tt.provider
+			cfg.MaxTokens = 321
+			cfg.Temperature = 0.4
+			tt.configure(cfg, server.URL)
+			model, err := NewFromConfig(cfg)
+			require.NoError(t, err)
+
+			_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{})
+			require.NoError(t, err)
+			assert.Equal(t, float64(321), generation()[tt.maxTokenKey], "configured max_tokens")
+			assert.Equal(t, 0.4, generation()["temperature"], "configured temperature")
+
+			// An explicit temperature of 0 is sent rather than dropped
+			_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{"max_tokens": 50, "temperature": 0.0})
+			require.NoError(t, err)
+			assert.Equal(t, float64(50), generation()[tt.maxTokenKey], "per-request max_tokens")
+			assert.Equal(t, 0.0, generation()["temperature"], "per-request zero temperature")
+		})
+	}
+}
+
+func TestSystemPrompt_RequestBodies(t *testing.T) {
+	openAICompatible := `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`
+	firstMessage := func(body map[string]interface{}) interface{} {
+		messages, _ := body["messages"].([]interface{})
+		if len(messages) == 0 {
+			return nil
+		}
+		message := messages[0].(map[string]interface{})
+		if message["role"] != "system" {
+			return nil
+		}
+		return message["content"]
+	}
+	tests := []struct {
+		provider  string
+		response  string
+		configure func(cfg *config.Config, endpoint string)
+		system    func(body map[string]interface{}) interface{}
+	}{
+		{"openai", openAICompatible, func(cfg *config.Config, endpoint string) {
+			cfg.OpenAI = config.OpenAIConfig{APIKey: "key", Endpoint: endpoint}
+		}, firstMessage},
+		{"anthropic", `{"content":[{"type":"text","text":"ok"}]}`, func(cfg *config.Config, endpoint string) {
+			cfg.Anthropic = config.AnthropicConfig{APIKey: "key", Endpoint: endpoint}
+		}, func(body map[string]interface{}) interface{} {
+			return body["system"]
+		}},
+		{"gemini", `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`, func(cfg *config.Config, endpoint string) {
+			cfg.Gemini = config.GeminiConfig{APIKey: "key", Endpoint: endpoint}
+		}, func(body map[string]interface{}) interface{} {
+			instruction, _ := body["systemInstruction"].(map[string]interface{})
+			parts, _ := instruction["parts"].([]interface{})
+			if len(parts) == 0 {
+				return nil
+			}
+			return parts[0].(map[string]interface{})["text"]
+		}},
+		{"xai", openAICompatible, func(cfg *config.Config, endpoint string) {
+			cfg.XAI = config.XAIConfig{APIKey: "key", Endpoint: endpoint}
+		}, firstMessage},
+		{"meta", openAICompatible, func(cfg *config.Config, endpoint string) {
+			cfg.Meta = config.MetaConfig{APIKey: "key", Endpoint: endpoint}
+		}, firstMessage},
+		{"ollama", `{"message":{"role":"assistant","content":"ok"},"done":true}`, func(cfg *config.Config, endpoint string) {
+			cfg.Ollama = config.OllamaConfig{Endpoint: endpoint, Model: "llama3.2"}
+		}, firstMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			server, body := captureRequest(t, tt.response)
+			cfg := config.Default()
+			cfg.Model = tt.provider
+			tt.configure(cfg, server.URL)
+			model, err := NewFromConfig(cfg)
+			require.NoError(t, err)
+
+			_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{"prompt": "You are Ava. Reply in French."})
+			require.NoError(t, err)
+			assert.Equal(t, "You are Ava. Reply in French.", tt.system(*body), "rendered prompt")
+
+			// Without a prompt, a caller's system message is sent
+			_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{"system": "Be brief."})
+			require.NoError(t, err)
+			assert.Equal(t, "Be brief.", tt.system(*body), "system message")
+
+			if streamingModel, ok := model.(StreamingModel); ok {
+				ch, err := streamingModel.AskStream(context.Background(), "Hi", map[string]interface{}{"prompt": "You are Ava."})
+				require.NoError(t, err)
+				for range ch {
+				}
+				assert.Equal(t, "You are Ava.", tt.system(*body), "streamed prompt")
+			}
+		})
+	}
+}
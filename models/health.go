@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errNoModelsEndpoint is returned by checkModelsEndpoint when the API does
+// not serve a list-models endpoint, as some OpenAI-compatible proxies don't.
+var errNoModelsEndpoint = errors.New("no list-models endpoint")
+
+// checkModelsEndpoint checks a provider with a GET request to its
+// list-models URL, which generates nothing and costs no tokens. Errors
+// other than a rejected API key or a server error count as healthy, as the
+// API was reached.
+func checkModelsEndpoint(ctx context.Context, client *http.Client, url string, header http.Header, provider string) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health check request: %w", err)
+	}
+	for key, values := range header {
+		httpReq.Header[key] = values
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("invalid API key")
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%s API server error: %d", provider, resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		return errNoModelsEndpoint
+	}
+	return nil
+}
+
+// healthFallback handles a list-models check without an endpoint to check:
+// the API was reached, so it is healthy unless the provider is configured
+// to verify with a tiny completion instead.
+func healthFallback(err error, completion bool, check func() error) error {
+	if !errors.Is(err, errNoModelsEndpoint) {
+		return err
+	}
+	if completion {
+		return check()
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestHealth_ListsModels(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		model func(endpoint string) (Model, error)
+	}{
+		{
+			name: "openai",
+			path: "/v1/models",
+			model: func(endpoint string) (Model, error) {
+				return NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Endpoint: endpoint + "/v1/chat/completions"})
+			},
+		},
+		{
+			name: "anthropic",
+			path: "/v1/models",
+			model: func(endpoint string) (Model, error) {
+				return NewAnthropicModel(config.AnthropicConfig{APIKey: "test-key", Endpoint: endpoint + "/v1/messages"})
+			},
+		},
+		{
+			name: "gemini",
+			path: "/v1beta/models",
+			model: func(endpoint string) (Model, error) {
+				return NewGeminiModel(config.GeminiConfig{APIKey: "test-key", Endpoint: endpoint})
+			},
+		},
+		{
+			name: "xai",
+			path: "/v1/models",
+			model: func(endpoint string) (Model, error) {
+				return NewXAIModel(config.XAIConfig{APIKey: "test-key", Endpoint: endpoint})
+			},
+		},
+		{
+			name: "meta",
+			path: "/v1/models",
+			model: func(endpoint string) (Model, error) {
+				return NewMetaModel(config.MetaConfig{APIKey: "test-key", Endpoint: endpoint})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				w.Write([]byte(`{"data":[]}`))
+			}))
+			defer server.Close()
+
+			model, err := tt.model(server.URL)
+			if err != nil {
+				t.Fatalf("failed to create model: %v", err)
+			}
+			if err := model.(HealthChecker).Health(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(requests) != 1 || requests[0] != "GET "+tt.path {
+				t.Errorf("expected only GET %s, got %v", tt.path, requests)
+			}
+		})
+	}
+}
+
+func TestHealth_CompletionFallback(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == "GET" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	// Without the fallback, reaching the API is enough
+	model, err := NewXAIModel(config.XAIConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+	if err := model.Health(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("expected no completion, got %v", requests)
+	}
+
+	// With it, a tiny completion verifies the key
+	requests = nil
+	model, err = NewXAIModel(config.XAIConfig{APIKey: "test-key", Endpoint: server.URL, CompletionHealthCheck: true})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+	err = model.Health(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid API key") {
+		t.Errorf("expected the completion to reject the key, got %v", err)
+	}
+	if len(requests) != 2 || requests[1] != "POST /v1/chat/completions" {
+		t.Errorf("expected a completion after the models request, got %v", requests)
+	}
+}
+
+func TestHealth_ModelsEndpointErrors(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusUnauthorized:        "invalid API key",
+		http.StatusForbidden:           "invalid API key",
+		http.StatusServiceUnavailable:  "meta API server error: 503",
+		http.StatusTooManyRequests:     "",
+		http.StatusInternalServerError: "meta API server error: 500",
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		model, err := NewMetaModel(config.MetaConfig{APIKey: "test-key", Endpoint: server.URL})
+		if err != nil {
+			t.Fatalf("failed to create model: %v", err)
+		}
+		err = model.Health(context.Background())
+		if want == "" && err != nil {
+			t.Errorf("status %d: unexpected error: %v", status, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("status %d: expected %q, got %v", status, want, err)
+		}
+		server.Close()
+	}
+}
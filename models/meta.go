@@ -45,6 +45,20 @@ type metaRequest struct {
 	TopP        float64       `json:"top_p,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
 	Stop        []string      `json:"stop,omitempty"`
+
+	// set records a Temperature or TopP of 0 to send
+	set samplingSet
+}
+
+// MarshalJSON implements json.Marshaler, sending a Temperature or TopP of 0
+// that was set.
+func (r metaRequest) MarshalJSON() ([]byte, error) {
+	type request metaRequest
+	return json.Marshal(struct {
+		request
+		Temperature *float64 `json:"temperature,omitempty"`
+		TopP        *float64 `json:"top_p,omitempty"`
+	}{request(r), sent(r.Temperature, r.set.temperature), sent(r.TopP, r.set.topP)})
 }
 
 // metaMessage represents a message in the conversation.
@@ -88,6 +102,68 @@ type metaError struct {
 
 // Ask sends a message to Meta LLaMA and returns the response.
 func (m *MetaModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	req := m.newRequest(message, context)
+
+	// Marshal the request
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.chatURL(), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+m.config.APIKey)
+
+	// Send the request
+	resp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return "", requestError(err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Handle error responses
+	if resp.StatusCode != http.StatusOK {
+		return "", m.apiError(resp.StatusCode, body)
+	}
+
+	// Parse the response
+	var metaResp metaResponse
+	if err := json.Unmarshal(body, &metaResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// Extract the text content
+	if len(metaResp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	choice := metaResp.Choices[0]
+	if choice.Message.Content == "" {
+		return "", fmt.Errorf("no content in response message")
+	}
+
+	reportMetadata(ctx, metaResp.Model, choice.FinishReason, metaResp.Usage.PromptTokens, metaResp.Usage.CompletionTokens)
+	return choice.Message.Content, nil
+}
+
+// newRequest builds the chat completion request for message, with the
+// history, system message and generation settings from the context.
+func (m *MetaModel) newRequest(message string, context map[string]interface{}) metaRequest {
+	// Resolve generation defaults and per-request overrides
+	gen := resolveGeneration(m.config.Generation, openAICompatibleFallbackGeneration, context)
+
 	// Prepare the request
 	req := metaRequest{
 		Model: m.config.Model,
@@ -97,11 +173,10 @@ func (m *MetaModel) Ask(ctx context.Context, message string, context map[string]
 				Content: message,
 			},
 		},
-		MaxTokens:   1000,
-		Temperature: 0.7,
-		TopP:        1.0,
-		Stream:      false,
+		MaxTokens: gen.MaxTokens,
+		Stop:      gen.Stop,
 	}
+	req.Temperature, req.TopP, req.set = sampling(gen)
 
 	// Add conversation history if provided
 	if history, ok := context["history"]; ok {
@@ -127,103 +202,69 @@ func (m *MetaModel) Ask(ctx context.Context, message string, context map[string]
 		}
 	}
 
-	// Add system message if provided
-	if systemMsg, ok := context["system"]; ok {
-		if sys, ok := systemMsg.(string); ok {
-			// Prepend system message
-			req.Messages = append([]metaMessage{
-				{
-					Role:    "system",
-					Content: sys,
-				},
-			}, req.Messages...)
-		}
+	// Prepend the system message
+	if system := systemPrompt(context); system != "" {
+		req.Messages = append([]metaMessage{{Role: "system", Content: system}}, req.Messages...)
 	}
 
-	// Override parameters from context if provided
-	if temp, ok := context["temperature"]; ok {
-		if temperature, ok := temp.(float64); ok {
-			req.Temperature = temperature
-		}
-	}
-	if maxTokens, ok := context["max_tokens"]; ok {
-		if tokens, ok := maxTokens.(int); ok {
-			req.MaxTokens = tokens
-		}
-	}
-	if topP, ok := context["top_p"]; ok {
-		if tp, ok := topP.(float64); ok {
-			req.TopP = tp
-		}
+	return req
+}
+
+// chatURL returns the chat completions URL.
+func (m *MetaModel) chatURL() string {
+	return m.apiBase() + "/v1/chat/completions"
+}
+
+// apiBase returns the API base URL. Meta LLaMA is often accessed through
+// platforms like Replicate or Together AI, so set the endpoint.
+func (m *MetaModel) apiBase() string {
+	if m.config.Endpoint != "" {
+		return baseEndpoint(m.config.Endpoint, "/v1/chat/completions")
 	}
-	if stop, ok := context["stop"]; ok {
-		if stopSequences, ok := stop.([]string); ok {
-			req.Stop = stopSequences
-		}
+	return "https://api.llama-api.com" // Default endpoint (hypothetical)
+}
+
+// apiError converts an error response into an error of its kind.
+func (m *MetaModel) apiError(status int, body []byte) error {
+	var errResp metaError
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		return markAPIError(status, fmt.Errorf("meta API error: %s", errResp.Error.Message))
 	}
+	return markAPIError(status, fmt.Errorf("meta API error: status %d, body: %s", status, string(body)))
+}
+
+// AskStream sends a streaming request to Meta LLaMA and returns a channel of
+// responses.
+func (m *MetaModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	req := m.newRequest(message, context)
+	req.Stream = true
 
-	// Marshal the request
 	reqBody, err := json.Marshal(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Construct URL - Meta LLaMA is often accessed through platforms like Replicate or Together AI
-	endpoint := "https://api.llama-api.com" // Default endpoint (hypothetical)
-	if m.config.Endpoint != "" {
-		endpoint = m.config.Endpoint
-	}
-	url := fmt.Sprintf("%s/v1/chat/completions", endpoint)
-
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.chatURL(), bytes.NewBuffer(reqBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-
-	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+m.config.APIKey)
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-cache")
 
-	// Send the request
 	resp, err := m.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read the response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, requestError(err)
 	}
-
-	// Handle error responses
 	if resp.StatusCode != http.StatusOK {
-		var errResp metaError
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			return "", fmt.Errorf("meta API error: %s", errResp.Error.Message)
-		}
-		return "", fmt.Errorf("meta API error: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse the response
-	var metaResp metaResponse
-	if err := json.Unmarshal(body, &metaResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	// Extract the text content
-	if len(metaResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
-	}
-
-	choice := metaResp.Choices[0]
-	if choice.Message.Content == "" {
-		return "", fmt.Errorf("no content in response message")
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, m.apiError(resp.StatusCode, body)
 	}
 
-	return choice.Message.Content, nil
+	// The API is OpenAI-compatible, streaming included
+	return readOpenAIStream(ctx, resp.Body, m.config.MaxStreamLineSize), nil
 }
 
 // Name returns the name of the model.
@@ -236,8 +277,19 @@ func (m *MetaModel) Provider() string {
 	return "meta"
 }
 
-// Health checks if the Meta API is accessible.
+// Health checks if the Meta API is accessible by listing its models, or
+// with a tiny completion if it has no models endpoint and
+// CompletionHealthCheck is set.
 func (m *MetaModel) Health(ctx context.Context) error {
+	header := http.Header{"Authorization": {"Bearer " + m.config.APIKey}}
+	err := checkModelsEndpoint(ctx, m.httpClient, m.apiBase()+"/v1/models", header, "meta")
+	return healthFallback(err, m.config.CompletionHealthCheck, func() error {
+		return m.completionHealth(ctx)
+	})
+}
+
+// completionHealth checks the Meta API with a minimal completion.
+func (m *MetaModel) completionHealth(ctx context.Context) error {
 	// Create a simple test request
 	req := metaRequest{
 		Model: m.config.Model,
@@ -255,13 +307,7 @@ func (m *MetaModel) Health(ctx context.Context) error {
 		return fmt.Errorf("failed to marshal health check request: %w", err)
 	}
 
-	endpoint := "https://api.llama-api.com"
-	if m.config.Endpoint != "" {
-		endpoint = m.config.Endpoint
-	}
-	url := fmt.Sprintf("%s/v1/chat/completions", endpoint)
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.chatURL(), bytes.NewBuffer(reqBody))
 	if err != nil {
 		return fmt.Errorf("failed to create health check request: %w", err)
 	}
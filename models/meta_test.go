@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestNewMetaModel_Detailed(t *testing.T) {
@@ -248,8 +249,9 @@ func TestMetaModel_Ask_ContextCancellation(t *testing.T) {
 func TestMetaModel_Health_Success(t *testing.T) {
 	// Create mock server for health check
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			t.Errorf("Expected POST request, got %s", r.Method)
+		// The health check lists the models instead of generating
+		if r.Method != "GET" || r.URL.Path != "/v1/models" {
+			t.Errorf("Expected GET /v1/models, got %s %s", r.Method, r.URL.Path)
 		}
 
 		authHeader := r.Header.Get("Authorization")
@@ -257,20 +259,8 @@ func TestMetaModel_Health_Success(t *testing.T) {
 			t.Errorf("Expected Authorization header 'Bearer test-key', got '%s'", authHeader)
 		}
 
-		// Mock successful health check response
-		response := metaResponse{
-			Choices: []metaChoice{
-				{
-					Message: metaMessage{
-						Role:    "assistant",
-						Content: "OK",
-					},
-				},
-			},
-		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		w.Write([]byte(`{"object":"list","data":[{"id":"llama-2-70b-chat"}]}`))
 	}))
 	defer server.Close()
 
@@ -446,3 +436,72 @@ func TestMetaModel_CustomEndpoint(t *testing.T) {
 		t.Errorf("Expected response '%s', got '%s'", expected, response)
 	}
 }
+
+func TestMetaModel_AskStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var request metaRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		if !request.Stream || len(request.Messages) != 2 || request.Messages[0].Role != "system" {
+			t.Errorf("unexpected request %+v", request)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`data: {"model":"llama-3.2-3b-instruct","choices":[{"delta":{"content":"Hello"}}]}`,
+			`data: {"model":"llama-3.2-3b-instruct","choices":[{"delta":{"content":" world"},"finish_reason":"stop"}]}`,
+			`data: [DONE]`,
+		} {
+			w.Write([]byte(chunk + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	model, err := NewMetaModel(config.MetaConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+
+	meta := &streaming.Metadata{}
+	ctx := streaming.NewMetadataContext(context.Background(), meta)
+	ch, err := model.AskStream(ctx, "Hello", map[string]interface{}{"system": "Be brief."})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var result strings.Builder
+	for chunk := range ch {
+		result.WriteString(chunk)
+	}
+
+	if result.String() != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", result.String())
+	}
+	if meta.FinishReason != "stop" || meta.Model != "llama-3.2-3b-instruct" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestMetaModel_AskStream_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid key"}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewMetaModel(config.MetaConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+
+	ch, err := model.AskStream(context.Background(), "Hello", nil)
+	if err == nil || !strings.Contains(err.Error(), "meta API error: invalid key") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+	if ch != nil {
+		t.Error("expected nil channel with error")
+	}
+}
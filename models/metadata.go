@@ -0,0 +1,27 @@
+package models
+
+import (
+	"context"
+
+	"go.rumenx.com/chatbot/streaming"
+)
+
+// reportMetadata fills in the caller's metadata, if any, for a reply from
+// Ask, so the model, finish reason and token usage are available whether
+// or not the reply was streamed. Usage is left unset when the provider did
+// not report it.
+func reportMetadata(ctx context.Context, model, finishReason string, promptTokens, completionTokens int) {
+	meta, ok := streaming.MetadataFromContext(ctx)
+	if !ok {
+		return
+	}
+	meta.Model = model
+	meta.FinishReason = finishReason
+	if promptTokens > 0 || completionTokens > 0 {
+		meta.Usage = &streaming.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		}
+	}
+}
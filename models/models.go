@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"go.rumenx.com/chatbot/config"
 )
@@ -41,28 +42,69 @@ func NewFromConfig(cfg *config.Config) (Model, error) {
 		return nil, errors.New("config cannot be nil")
 	}
 
+	// Provider generation defaults fall back to the global settings
 	switch cfg.Model {
 	case "openai":
-		return NewOpenAIModel(cfg.OpenAI)
+		providerCfg := cfg.OpenAI
+		providerCfg.Generation = cfg.Generation(providerCfg.Generation)
+		return NewOpenAIModel(providerCfg)
 	case "anthropic":
-		return NewAnthropicModel(cfg.Anthropic)
+		providerCfg := cfg.Anthropic
+		providerCfg.Generation = cfg.Generation(providerCfg.Generation)
+		return NewAnthropicModel(providerCfg)
 	case "gemini":
-		return NewGeminiModel(cfg.Gemini)
+		providerCfg := cfg.Gemini
+		providerCfg.Generation = cfg.Generation(providerCfg.Generation)
+		return NewGeminiModel(providerCfg)
 	case "xai":
-		return NewXAIModel(cfg.XAI)
+		providerCfg := cfg.XAI
+		providerCfg.Generation = cfg.Generation(providerCfg.Generation)
+		return NewXAIModel(providerCfg)
 	case "meta":
-		return NewMetaModel(cfg.Meta)
+		providerCfg := cfg.Meta
+		providerCfg.Generation = cfg.Generation(providerCfg.Generation)
+		return NewMetaModel(providerCfg)
 	case "ollama":
-		return NewOllamaModel(cfg.Ollama)
+		providerCfg := cfg.Ollama
+		providerCfg.Generation = cfg.Generation(providerCfg.Generation)
+		return NewOllamaModel(providerCfg)
 	case "free":
-		return NewFreeModel(), nil
+		return NewFreeModelWithConfig(cfg.Free)
 	default:
+		// Third-party providers registered with Register receive the full config
+		if config.IsRegisteredProvider(cfg.Model) {
+			return DefaultRegistry.Create(cfg.Model, cfg)
+		}
 		return nil, fmt.Errorf("unsupported model: %s", cfg.Model)
 	}
 }
 
+// Register plugs a third-party provider into NewFromConfig. Once registered,
+// setting Config.Model to name creates the model by calling factory with the
+// *config.Config; provider settings can be read from Config.Providers[name].
+// Register is typically called from an init function of the package
+// implementing the provider. It panics if factory is nil or name is a
+// built-in provider.
+func Register(name string, factory func(cfg any) (Model, error)) {
+	if factory == nil {
+		panic("models: Register factory is nil")
+	}
+	if builtinProviders[name] {
+		panic("models: Register called for built-in provider " + name)
+	}
+	DefaultRegistry.Register(name, factory)
+	config.RegisterProvider(name)
+}
+
+// builtinProviders are the providers handled directly by NewFromConfig.
+var builtinProviders = map[string]bool{
+	"openai": true, "anthropic": true, "gemini": true, "xai": true,
+	"meta": true, "ollama": true, "free": true,
+}
+
 // Registry holds available model constructors.
 type Registry struct {
+	mu           sync.RWMutex
 	constructors map[string]func(interface{}) (Model, error)
 }
 
@@ -75,12 +117,16 @@ func NewRegistry() *Registry {
 
 // Register registers a model constructor.
 func (r *Registry) Register(name string, constructor func(interface{}) (Model, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.constructors[name] = constructor
 }
 
 // Create creates a model instance by name.
 func (r *Registry) Create(name string, config interface{}) (Model, error) {
+	r.mu.RLock()
 	constructor, exists := r.constructors[name]
+	r.mu.RUnlock()
 	if !exists {
 		return nil, fmt.Errorf("unknown model: %s", name)
 	}
@@ -89,6 +135,9 @@ func (r *Registry) Create(name string, config interface{}) (Model, error) {
 
 // ListAvailable returns a list of available model names.
 func (r *Registry) ListAvailable() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	names := make([]string, 0, len(r.constructors))
 	for name := range r.constructors {
 		names = append(names, name)
@@ -144,6 +193,20 @@ func init() {
 	})
 
 	DefaultRegistry.Register("free", func(cfg interface{}) (Model, error) {
+		if freeCfg, ok := cfg.(config.FreeConfig); ok {
+			return NewFreeModelWithConfig(freeCfg)
+		}
 		return NewFreeModel(), nil
 	})
 }
+
+// systemPrompt returns the system message of a request: the "prompt" the
+// chatbot renders, with its persona, reply style, retrieved knowledge and
+// memory, or else a "system" message set by the caller.
+func systemPrompt(context map[string]interface{}) string {
+	if prompt, _ := context["prompt"].(string); prompt != "" {
+		return prompt
+	}
+	system, _ := context["system"].(string)
+	return system
+}
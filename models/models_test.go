@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"testing"
 
 	"go.rumenx.com/chatbot/config"
@@ -239,3 +240,36 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestRegister_ThirdPartyProvider(t *testing.T) {
+	Register("test-llm", func(cfg any) (Model, error) {
+		c := cfg.(*config.Config)
+		if c.Providers["test-llm"]["api_key"] != "secret" {
+			return nil, errors.New("missing api_key")
+		}
+		return NewFreeModel(), nil
+	})
+
+	cfg := config.Default()
+	cfg.Model = "test-llm"
+	cfg.Providers = map[string]map[string]interface{}{"test-llm": {"api_key": "secret"}}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("registered provider should validate: %v", err)
+	}
+
+	model, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.Name() != "free-model" {
+		t.Errorf("expected factory model, got %s", model.Name())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when registering a built-in provider")
+		}
+	}()
+	Register("openai", func(cfg any) (Model, error) { return nil, nil })
+}
@@ -64,6 +64,7 @@ type ollamaResponse struct {
 	PromptEvalDuration int64          `json:"prompt_eval_duration,omitempty"`
 	EvalCount          int            `json:"eval_count,omitempty"`
 	EvalDuration       int64          `json:"eval_duration,omitempty"`
+	DoneReason         string         `json:"done_reason,omitempty"`
 }
 
 // ollamaError represents an error response from the API.
@@ -76,7 +77,7 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 	// Determine endpoint
 	endpoint := "http://localhost:11434"
 	if o.config.Endpoint != "" {
-		endpoint = o.config.Endpoint
+		endpoint = baseEndpoint(o.config.Endpoint, "/api/chat", "/api/generate", "/api/tags")
 	}
 
 	// Use chat endpoint for conversation-style interactions
@@ -130,21 +131,13 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 			}
 		}
 
-		// Add system message if provided
-		if systemMsg, ok := context["system"]; ok {
-			if sys, ok := systemMsg.(string); ok {
-				// Prepend system message
-				req.Messages = append([]ollamaMessage{
-					{
-						Role:    "system",
-						Content: sys,
-					},
-				}, req.Messages...)
-			}
+		// Prepend the system message
+		if system := systemPrompt(context); system != "" {
+			req.Messages = append([]ollamaMessage{{Role: "system", Content: system}}, req.Messages...)
 		}
 
 		// Add options if provided
-		if options := buildOllamaOptions(context); len(options) > 0 {
+		if options := o.buildOptions(context); len(options) > 0 {
 			req.Options = options
 		}
 
@@ -167,7 +160,7 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 		}
 
 		// Add options if provided
-		if options := buildOllamaOptions(context); len(options) > 0 {
+		if options := o.buildOptions(context); len(options) > 0 {
 			req.Options = options
 		}
 
@@ -190,7 +183,7 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 	// Send the request
 	resp, err := o.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", requestError(err)
 	}
 	defer resp.Body.Close()
 
@@ -204,9 +197,9 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 	if resp.StatusCode != http.StatusOK {
 		var errResp ollamaError
 		if err := json.Unmarshal(body, &errResp); err == nil {
-			return "", fmt.Errorf("ollama API error: %s", errResp.Error)
+			return "", markAPIError(resp.StatusCode, fmt.Errorf("ollama API error: %s", errResp.Error))
 		}
-		return "", fmt.Errorf("ollama API error: status %d, body: %s", resp.StatusCode, string(body))
+		return "", markAPIError(resp.StatusCode, fmt.Errorf("ollama API error: status %d, body: %s", resp.StatusCode, string(body)))
 	}
 
 	// Parse the response
@@ -215,6 +208,8 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	reportMetadata(ctx, ollamaResp.Model, ollamaResp.DoneReason, ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
+
 	// Extract the text content based on API used
 	if useChatAPI {
 		if ollamaResp.Message == nil {
@@ -232,6 +227,33 @@ func (o *OllamaModel) Ask(ctx context.Context, message string, context map[strin
 	}
 }
 
+// buildOptions merges the provider generation defaults with the options
+// requested in the context.
+func (o *OllamaModel) buildOptions(context map[string]interface{}) map[string]interface{} {
+	gen := resolveGeneration(o.config.Generation, config.GenerationConfig{}, context)
+
+	options := make(map[string]interface{})
+	if gen.MaxTokens > 0 {
+		options["num_predict"] = gen.MaxTokens
+	}
+	if gen.Temperature != nil {
+		options["temperature"] = *gen.Temperature
+	}
+	if gen.TopP != nil {
+		options["top_p"] = *gen.TopP
+	}
+	if len(gen.Stop) > 0 {
+		options["stop"] = gen.Stop
+	}
+
+	// Native Ollama options from the context win over the defaults above
+	for key, value := range buildOllamaOptions(context) {
+		options[key] = value
+	}
+
+	return options
+}
+
 // buildOllamaOptions builds options map from context.
 func buildOllamaOptions(context map[string]interface{}) map[string]interface{} {
 	options := make(map[string]interface{})
@@ -279,7 +301,7 @@ func (o *OllamaModel) Provider() string {
 func (o *OllamaModel) Health(ctx context.Context) error {
 	endpoint := "http://localhost:11434"
 	if o.config.Endpoint != "" {
-		endpoint = o.config.Endpoint
+		endpoint = baseEndpoint(o.config.Endpoint, "/api/chat", "/api/generate", "/api/tags")
 	}
 
 	// Check if Ollama is running by hitting the /api/tags endpoint
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -12,6 +11,7 @@ import (
 	"time"
 
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 // OpenAIModel implements the Model interface for OpenAI's API.
@@ -26,13 +26,39 @@ type OpenAIRequest struct {
 	Messages    []Message `json:"messages"`
 	Temperature float64   `json:"temperature,omitempty"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
+	TopP        float64   `json:"top_p,omitempty"`
+	Stop        []string  `json:"stop,omitempty"`
 	Stream      bool      `json:"stream,omitempty"`
+	// StreamOptions asks for token usage at the end of a stream.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+
+	// set records a Temperature or TopP of 0 to send
+	set samplingSet
+}
+
+// MarshalJSON implements json.Marshaler. A Temperature or TopP of 0 is
+// sent when the model set it from the generation settings, and omitted
+// otherwise.
+func (r OpenAIRequest) MarshalJSON() ([]byte, error) {
+	type request OpenAIRequest
+	return json.Marshal(struct {
+		request
+		Temperature *float64 `json:"temperature,omitempty"`
+		TopP        *float64 `json:"top_p,omitempty"`
+	}{request(r), sent(r.Temperature, r.set.temperature), sent(r.TopP, r.set.topP)})
+}
+
+// StreamOptions configures OpenAI streaming responses.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // OpenAIResponse represents a response from the OpenAI API.
 type OpenAIResponse struct {
-	Choices []Choice  `json:"choices"`
-	Error   *APIError `json:"error,omitempty"`
+	Model   string           `json:"model,omitempty"`
+	Choices []Choice         `json:"choices"`
+	Usage   *streaming.Usage `json:"usage,omitempty"`
+	Error   *APIError        `json:"error,omitempty"`
 }
 
 // Message represents a chat message.
@@ -43,7 +69,8 @@ type Message struct {
 
 // Choice represents a response choice.
 type Choice struct {
-	Message Message `json:"message"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason,omitempty"`
 }
 
 // APIError represents an API error.
@@ -78,27 +105,17 @@ func NewOpenAIModel(cfg config.OpenAIConfig) (*OpenAIModel, error) {
 // Ask sends a message to the OpenAI API and returns the response.
 func (o *OpenAIModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
 	// Prepare system prompt
-	systemPrompt := "You are a helpful chatbot."
-	if prompt, ok := context["prompt"].(string); ok && prompt != "" {
-		systemPrompt = prompt
-	}
-
 	// Prepare request
 	request := OpenAIRequest{
 		Model: o.config.Model,
 		Messages: []Message{
-			{Role: "system", Content: systemPrompt},
+			{Role: "system", Content: openAISystemPrompt(context)},
 			{Role: "user", Content: message},
 		},
 	}
 
-	// Add optional parameters from context
-	if temp, ok := context["temperature"].(float64); ok {
-		request.Temperature = temp
-	}
-	if maxTokens, ok := context["max_tokens"].(int); ok {
-		request.MaxTokens = maxTokens
-	}
+	// Apply generation defaults and per-request overrides
+	o.applyGeneration(&request, context)
 
 	// Marshal request
 	jsonData, err := json.Marshal(request)
@@ -119,7 +136,7 @@ func (o *OpenAIModel) Ask(ctx context.Context, message string, context map[strin
 	// Send request
 	resp, err := o.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", requestError(err)
 	}
 	defer resp.Body.Close()
 
@@ -132,12 +149,12 @@ func (o *OpenAIModel) Ask(ctx context.Context, message string, context map[strin
 	// Parse response
 	var openaiResp OpenAIResponse
 	if err := json.Unmarshal(body, &openaiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return "", markAPIError(resp.StatusCode, fmt.Errorf("failed to parse response: %w", err))
 	}
 
 	// Check for API errors
 	if openaiResp.Error != nil {
-		return "", fmt.Errorf("OpenAI API error: %s", openaiResp.Error.Message)
+		return "", markAPIError(resp.StatusCode, fmt.Errorf("OpenAI API error: %s", openaiResp.Error.Message))
 	}
 
 	// Check for choices
@@ -145,7 +162,21 @@ func (o *OpenAIModel) Ask(ctx context.Context, message string, context map[strin
 		return "", fmt.Errorf("no response choices returned")
 	}
 
-	return openaiResp.Choices[0].Message.Content, nil
+	choice := openaiResp.Choices[0]
+	var promptTokens, completionTokens int
+	if openaiResp.Usage != nil {
+		promptTokens, completionTokens = openaiResp.Usage.PromptTokens, openaiResp.Usage.CompletionTokens
+	}
+	reportMetadata(ctx, openaiResp.Model, choice.FinishReason, promptTokens, completionTokens)
+	return choice.Message.Content, nil
+}
+
+// applyGeneration sets the generation parameters of an outgoing request.
+func (o *OpenAIModel) applyGeneration(request *OpenAIRequest, context map[string]interface{}) {
+	gen := resolveGeneration(o.config.Generation, config.GenerationConfig{}, context)
+	request.MaxTokens = gen.MaxTokens
+	request.Temperature, request.TopP, request.set = sampling(gen)
+	request.Stop = gen.Stop
 }
 
 // Name returns the name of the model.
@@ -158,8 +189,20 @@ func (o *OpenAIModel) Provider() string {
 	return "openai"
 }
 
-// Health checks if the OpenAI API is accessible.
+// Health checks if the OpenAI API is accessible by listing its models, or
+// with a tiny completion if it has no models endpoint and
+// CompletionHealthCheck is set.
 func (o *OpenAIModel) Health(ctx context.Context) error {
+	header := http.Header{"Authorization": {"Bearer " + o.config.APIKey}}
+	url := baseEndpoint(o.config.Endpoint, "/chat/completions") + "/models"
+	err := checkModelsEndpoint(ctx, o.httpClient, url, header, "OpenAI")
+	return healthFallback(err, o.config.CompletionHealthCheck, func() error {
+		return o.completionHealth(ctx)
+	})
+}
+
+// completionHealth checks the OpenAI API with a minimal completion.
+func (o *OpenAIModel) completionHealth(ctx context.Context) error {
 	// Simple health check by making a minimal request
 	_, err := o.Ask(ctx, "Hi", map[string]interface{}{
 		"max_tokens": 1,
@@ -167,27 +210,33 @@ func (o *OpenAIModel) Health(ctx context.Context) error {
 	return err
 }
 
+// openAISystemPrompt returns the system message of a request, with a
+// default for requests without one.
+func openAISystemPrompt(context map[string]interface{}) string {
+	if prompt := systemPrompt(context); prompt != "" {
+		return prompt
+	}
+	return "You are a helpful chatbot."
+}
+
 // AskStream sends a streaming request to OpenAI and returns a channel of responses.
 func (o *OpenAIModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
 	// Prepare messages
 	messages := []Message{
+		{Role: "system", Content: openAISystemPrompt(context)},
 		{Role: "user", Content: message},
 	}
 
 	// Build request
 	request := OpenAIRequest{
-		Model:    o.config.Model,
-		Messages: messages,
-		Stream:   true,
+		Model:         o.config.Model,
+		Messages:      messages,
+		Stream:        true,
+		StreamOptions: &StreamOptions{IncludeUsage: true},
 	}
 
-	// Apply context parameters
-	if temp, ok := context["temperature"].(float64); ok {
-		request.Temperature = temp
-	}
-	if maxTokens, ok := context["max_tokens"].(int); ok {
-		request.MaxTokens = maxTokens
-	}
+	// Apply generation defaults and per-request overrides
+	o.applyGeneration(&request, context)
 
 	// Marshal request
 	jsonData, err := json.Marshal(request)
@@ -210,25 +259,38 @@ func (o *OpenAIModel) AskStream(ctx context.Context, message string, context map
 	// Send request
 	resp, err := o.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, requestError(err)
 	}
 
 	// Check status
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, markAPIError(resp.StatusCode, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)))
 	}
 
+	return readOpenAIStream(ctx, resp.Body, o.config.MaxStreamLineSize), nil
+}
+
+// readOpenAIStream reads an OpenAI-format SSE stream, as served by OpenAI
+// and the APIs compatible with it, and returns a channel of the content
+// deltas. The body is closed when the stream ends; a line longer than
+// maxLineSize bytes ends it with an error.
+func readOpenAIStream(ctx context.Context, body io.ReadCloser, maxLineSize int) <-chan string {
 	// Create response channel
 	responseCh := make(chan string, 10)
 
+	// The finish reason, usage and model are reported to the caller's
+	// metadata, if any, before the channel is closed
+	meta, _ := streaming.MetadataFromContext(ctx)
+
 	// Start goroutine to read streaming response
 	go func() {
 		defer close(responseCh)
-		defer resp.Body.Close()
+		defer body.Close()
+		defer streaming.Recover(ctx)
 
-		scanner := bufio.NewScanner(resp.Body)
+		scanner := streaming.NewLineScanner(body, maxLineSize)
 		for scanner.Scan() {
 			line := scanner.Text()
 
@@ -253,6 +315,9 @@ func (o *OpenAIModel) AskStream(ctx context.Context, message string, context map
 				}
 
 				// Extract content
+				if meta != nil {
+					streaming.ExtractOpenAIMetadata(chunk, meta)
+				}
 				content := extractOpenAIStreamContent(chunk)
 				if content != "" {
 					select {
@@ -265,15 +330,11 @@ func (o *OpenAIModel) AskStream(ctx context.Context, message string, context map
 		}
 
 		if err := scanner.Err(); err != nil {
-			// Log error but don't panic
-			select {
-			case responseCh <- fmt.Sprintf("[ERROR: %v]", err):
-			case <-ctx.Done():
-			}
+			failStream(meta, streaming.ScanError(err, maxLineSize))
 		}
 	}()
 
-	return responseCh, nil
+	return responseCh
 }
 
 // extractOpenAIStreamContent extracts content from OpenAI streaming format.
@@ -2,13 +2,18 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"go.rumenx.com/chatbot/chaterrors"
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestNewOpenAIModel(t *testing.T) {
@@ -209,6 +214,121 @@ func TestOpenAIModel_AskStream_Success(t *testing.T) {
 	}
 }
 
+func TestOpenAIModel_AskStream_Metadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		if request.StreamOptions == nil || !request.StreamOptions.IncludeUsage {
+			t.Error("expected the request to ask for usage")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`data: {"model":"gpt-4o-2024-08-06","choices":[{"delta":{"content":"Hi"},"finish_reason":null}]}`,
+			`data: {"model":"gpt-4o-2024-08-06","choices":[{"delta":{},"finish_reason":"length"}]}`,
+			`data: {"model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":1,"total_tokens":13}}`,
+			`data: [DONE]`,
+		} {
+			w.Write([]byte(chunk + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	model, err := NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+
+	meta := &streaming.Metadata{}
+	ch, err := model.AskStream(streaming.NewMetadataContext(context.Background(), meta), "Hello", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range ch {
+	}
+
+	if meta.FinishReason != "length" || meta.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+	if meta.Usage == nil || meta.Usage.PromptTokens != 12 || meta.Usage.TotalTokens != 13 {
+		t.Errorf("unexpected usage: %+v", meta.Usage)
+	}
+}
+
+func TestOpenAIModel_Ask_Metadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"gpt-4o-2024-08-06","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":1,"total_tokens":10}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+
+	meta := &streaming.Metadata{}
+	reply, err := model.Ask(streaming.NewMetadataContext(context.Background(), meta), "Hello", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "Hi" {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+
+	if meta.FinishReason != "stop" || meta.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+	if meta.Usage == nil || meta.Usage.PromptTokens != 9 || meta.Usage.TotalTokens != 10 {
+		t.Errorf("unexpected usage: %+v", meta.Usage)
+	}
+}
+
+func TestOpenAIModel_Ask_ErrorKinds(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		kind   *chaterrors.Kind
+	}{
+		{name: "context length", status: http.StatusBadRequest, body: `{"error":{"message":"This model's maximum context length is 8192 tokens.","code":"context_length_exceeded"}}`, kind: chaterrors.ErrContextTooLong},
+		{name: "content policy", status: http.StatusBadRequest, body: `{"error":{"message":"Your request was rejected by our safety system.","code":"content_policy_violation"}}`, kind: chaterrors.ErrModeration},
+		{name: "overloaded", status: http.StatusServiceUnavailable, body: `upstream overloaded`, kind: chaterrors.ErrProviderUnavailable},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"error":{"message":"Rate limit reached"}}`, kind: chaterrors.ErrProviderUnavailable},
+		{name: "invalid key", status: http.StatusUnauthorized, body: `{"error":{"message":"Incorrect API key provided"}}`, kind: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			model, err := NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Endpoint: server.URL})
+			if err != nil {
+				t.Fatalf("failed to create model: %v", err)
+			}
+			_, err = model.Ask(context.Background(), "Hello", nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if kind := chaterrors.KindOf(err); kind != tt.kind {
+				t.Errorf("expected kind %v, got %v for %v", tt.kind, kind, err)
+			}
+		})
+	}
+
+	// An unreachable provider is unavailable
+	model, _ := NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Endpoint: "http://127.0.0.1:1"})
+	if _, err := model.Ask(context.Background(), "Hello", nil); !errors.Is(err, chaterrors.ErrProviderUnavailable) {
+		t.Errorf("expected a provider unavailable error, got %v", err)
+	}
+}
+
 func TestOpenAIModel_AskStream_ContextCancellation(t *testing.T) {
 	// Create a mock server with delay
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -242,6 +362,88 @@ func TestOpenAIModel_AskStream_ContextCancellation(t *testing.T) {
 	}
 }
 
+func TestOpenAIModel_AskStream_ConnectionLost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack connection: %v", err)
+			return
+		}
+		defer conn.Close()
+		// Drop the connection partway through a chunked stream
+		chunk := "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n", len(chunk), chunk)
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	model, err := NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+
+	meta := &streaming.Metadata{}
+	ch, err := model.AskStream(streaming.NewMetadataContext(context.Background(), meta), "Hello", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var text string
+	for chunk := range ch {
+		text += chunk
+	}
+	if text != "Hello" {
+		t.Errorf("Expected only the streamed content, got %q", text)
+	}
+	if meta.FinishReason != "error" || !errors.Is(meta.Err, chaterrors.ErrProviderUnavailable) {
+		t.Errorf("Expected the stream to fail as provider_unavailable, got %q: %v", meta.FinishReason, meta.Err)
+	}
+}
+
+func TestOpenAIModel_AskStream_LongLines(t *testing.T) {
+	long := strings.Repeat("a", 100<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", long)
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	stream := func(maxLineSize int) (string, *streaming.Metadata) {
+		model, err := NewOpenAIModel(config.OpenAIConfig{APIKey: "test-key", Endpoint: server.URL, MaxStreamLineSize: maxLineSize})
+		if err != nil {
+			t.Fatalf("Failed to create model: %v", err)
+		}
+		meta := &streaming.Metadata{}
+		ch, err := model.AskStream(streaming.NewMetadataContext(context.Background(), meta), "Hello", nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var text string
+		for chunk := range ch {
+			text += chunk
+		}
+		return text, meta
+	}
+
+	// Lines over the scanner's default 64 KB are read by default
+	if text, meta := stream(0); text != long || meta.Err != nil {
+		t.Errorf("Expected the long line to be read, got %d bytes: %v", len(text), meta.Err)
+	}
+
+	// Lines over the configured size end the stream with an error
+	text, meta := stream(1024)
+	if text != "" || meta.FinishReason != "error" {
+		t.Errorf("Expected the stream to fail, got %d bytes and %q", len(text), meta.FinishReason)
+	}
+	if meta.Err == nil || !strings.Contains(meta.Err.Error(), "longer than 1024 bytes") {
+		t.Errorf("Expected a line size error, got %v", meta.Err)
+	}
+	if errors.Is(meta.Err, chaterrors.ErrProviderUnavailable) {
+		t.Errorf("Expected a long line not to mark the provider as unavailable")
+	}
+}
+
 func TestOpenAIModel_AskStream_HTTPError(t *testing.T) {
 	// Create a mock server that returns an error
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -263,8 +465,8 @@ func TestOpenAIModel_AskStream_HTTPError(t *testing.T) {
 
 	ctx := context.Background()
 	ch, err := model.AskStream(ctx, "Hello", nil)
-	if err == nil {
-		t.Fatal("Expected error for HTTP failure")
+	if !errors.Is(err, chaterrors.ErrProviderUnavailable) {
+		t.Fatalf("Expected a provider unavailable error, got %v", err)
 	}
 
 	if ch != nil {
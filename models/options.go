@@ -0,0 +1,325 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// ModelOption configures a provider model created with one of the
+// option-style constructors (NewOpenAI, NewAnthropic, NewGemini, NewXAI,
+// NewMeta and NewOllama). The same options work for every provider.
+type ModelOption func(*modelOptions)
+
+// modelOptions collects the settings shared by all providers.
+type modelOptions struct {
+	model      string
+	endpoint   string
+	httpClient *http.Client
+	generation config.GenerationConfig
+	retry      *RetryPolicy
+}
+
+// WithModelName sets the provider model, e.g. "gpt-4o" or "claude-3-haiku-20240307".
+func WithModelName(name string) ModelOption {
+	return func(o *modelOptions) {
+		o.model = name
+	}
+}
+
+// WithEndpoint sets the API endpoint. OpenAI and Anthropic expect the full
+// request URL; Gemini, xAI, Meta and Ollama accept either the base URL or
+// the full request URL.
+func WithEndpoint(endpoint string) ModelOption {
+	return func(o *modelOptions) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for API requests.
+func WithHTTPClient(client *http.Client) ModelOption {
+	return func(o *modelOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithDefaultParams sets the default generation parameters for every request.
+// Values passed in the request context still take precedence.
+func WithDefaultParams(params config.GenerationConfig) ModelOption {
+	return func(o *modelOptions) {
+		o.generation = params
+	}
+}
+
+// WithRetryPolicy retries failed API requests according to the policy.
+func WithRetryPolicy(policy RetryPolicy) ModelOption {
+	return func(o *modelOptions) {
+		o.retry = &policy
+	}
+}
+
+// newModelOptions applies opts on top of the zero configuration.
+func newModelOptions(opts []ModelOption) *modelOptions {
+	o := &modelOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// client returns the HTTP client to use, wrapping it with the retry policy.
+// The fallback client is used when no client was supplied.
+func (o *modelOptions) client(fallback *http.Client) *http.Client {
+	client := fallback
+	if o.httpClient != nil {
+		client = o.httpClient
+	}
+	if o.retry == nil {
+		return client
+	}
+
+	// Copy the client so a caller-supplied client is not modified
+	wrapped := *client
+	wrapped.Transport = o.retry.Transport(client.Transport)
+	return &wrapped
+}
+
+// NewOpenAI creates an OpenAI model using functional options.
+func NewOpenAI(apiKey string, opts ...ModelOption) (*OpenAIModel, error) {
+	o := newModelOptions(opts)
+	model, err := NewOpenAIModel(config.OpenAIConfig{
+		APIKey: apiKey, Model: o.model, Endpoint: o.endpoint, Generation: o.generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.httpClient = o.client(model.httpClient)
+	return model, nil
+}
+
+// NewAnthropic creates an Anthropic model using functional options.
+func NewAnthropic(apiKey string, opts ...ModelOption) (*AnthropicModel, error) {
+	o := newModelOptions(opts)
+	model, err := NewAnthropicModel(config.AnthropicConfig{
+		APIKey: apiKey, Model: o.model, Endpoint: o.endpoint, Generation: o.generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.httpClient = o.client(model.httpClient)
+	return model, nil
+}
+
+// NewGemini creates a Gemini model using functional options.
+func NewGemini(apiKey string, opts ...ModelOption) (*GeminiModel, error) {
+	o := newModelOptions(opts)
+	model, err := NewGeminiModel(config.GeminiConfig{
+		APIKey: apiKey, Model: o.model, Endpoint: o.endpoint, Generation: o.generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.httpClient = o.client(model.httpClient)
+	return model, nil
+}
+
+// NewXAI creates an xAI model using functional options.
+func NewXAI(apiKey string, opts ...ModelOption) (*XAIModel, error) {
+	o := newModelOptions(opts)
+	model, err := NewXAIModel(config.XAIConfig{
+		APIKey: apiKey, Model: o.model, Endpoint: o.endpoint, Generation: o.generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.httpClient = o.client(model.httpClient)
+	return model, nil
+}
+
+// NewMeta creates a Meta model using functional options.
+func NewMeta(apiKey string, opts ...ModelOption) (*MetaModel, error) {
+	o := newModelOptions(opts)
+	model, err := NewMetaModel(config.MetaConfig{
+		APIKey: apiKey, Model: o.model, Endpoint: o.endpoint, Generation: o.generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.httpClient = o.client(model.httpClient)
+	return model, nil
+}
+
+// NewOllama creates an Ollama model using functional options.
+func NewOllama(opts ...ModelOption) (*OllamaModel, error) {
+	o := newModelOptions(opts)
+	model, err := NewOllamaModel(config.OllamaConfig{
+		Model: o.model, Endpoint: o.endpoint, Generation: o.generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.httpClient = o.client(model.httpClient)
+	return model, nil
+}
+
+// RetryPolicy controls how failed API requests are retried. Network errors
+// and 429, 500, 502, 503 and 504 responses are retried with exponential
+// backoff; a Retry-After header from the provider is honored.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry (default 500ms).
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts (default 10s).
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns a policy of three attempts with 500ms initial backoff.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// backoff returns the delay before the given retry (1-based).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 10 * time.Second
+	}
+
+	delay := initial << (retry - 1)
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// Transport wraps base (http.DefaultTransport if nil) so requests are
+// retried according to the policy.
+func (p RetryPolicy) Transport(base http.RoundTripper) http.RoundTripper {
+	return &retryTransport{base: base, policy: p}
+}
+
+// retryTransport retries requests according to a RetryPolicy.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Buffer the body so it can be replayed on every attempt
+	var body []byte
+	if req.Body != nil && req.GetBody == nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attempts := t.policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 || body != nil {
+			attemptReq = req.Clone(req.Context())
+			if body != nil {
+				attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+			} else if req.GetBody != nil {
+				newBody, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = newBody
+			}
+		}
+
+		resp, err := base.RoundTrip(attemptReq)
+		if attempt >= attempts || !shouldRetry(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		delay := t.policy.backoff(attempt)
+		if resp != nil {
+			if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 {
+				delay = retryAfter
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether a request outcome is worth retrying.
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// baseEndpoint strips a known request path from endpoint so providers
+// accept both a base URL and a full request URL.
+func baseEndpoint(endpoint string, paths ...string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	for _, path := range paths {
+		if strings.HasSuffix(endpoint, path) {
+			return strings.TrimSuffix(endpoint, path)
+		}
+	}
+	return endpoint
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestOptionConstructors(t *testing.T) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	params := config.GenerationConfig{MaxTokens: 64}
+
+	openai, err := NewOpenAI("key", WithModelName("gpt-4o"), WithEndpoint("http://example.test"),
+		WithHTTPClient(client), WithDefaultParams(params))
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", openai.config.Model)
+	assert.Equal(t, "http://example.test", openai.config.Endpoint)
+	assert.Same(t, client, openai.httpClient)
+	assert.Equal(t, 64, openai.config.Generation.MaxTokens)
+
+	_, err = NewAnthropic("")
+	assert.Error(t, err, "struct constructor validation still applies")
+
+	ollama, err := NewOllama(WithRetryPolicy(DefaultRetryPolicy()))
+	require.NoError(t, err)
+	assert.IsType(t, &retryTransport{}, ollama.httpClient.Transport)
+	assert.Nil(t, client.Transport, "caller client must not be modified")
+}
+
+func TestRetryPolicy_RetriesTransientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	model, err := NewXAI("key", WithEndpoint(server.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	require.NoError(t, err)
+
+	reply, err := model.Ask(context.Background(), "Hi", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", reply)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicy_StopsOnClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	model, err := NewMeta("key", WithEndpoint(server.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	require.NoError(t, err)
+
+	_, err = model.Ask(context.Background(), "Hi", map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestBaseEndpoint(t *testing.T) {
+	assert.Equal(t, "https://api.x.ai", baseEndpoint("https://api.x.ai/v1/chat/completions", "/v1/chat/completions"))
+	assert.Equal(t, "https://api.x.ai", baseEndpoint("https://api.x.ai/", "/v1/chat/completions"))
+	assert.Equal(t, "http://localhost:11434", baseEndpoint("http://localhost:11434/api/chat", "/api/chat", "/api/generate"))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 250 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 250*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2"))
+}
@@ -44,6 +44,21 @@ type xaiRequest struct {
 	Temperature float64      `json:"temperature,omitempty"`
 	TopP        float64      `json:"top_p,omitempty"`
 	Stream      bool         `json:"stream,omitempty"`
+	Stop        []string     `json:"stop,omitempty"`
+
+	// set records a Temperature or TopP of 0 to send
+	set samplingSet
+}
+
+// MarshalJSON implements json.Marshaler, sending a Temperature or TopP of 0
+// that was set.
+func (r xaiRequest) MarshalJSON() ([]byte, error) {
+	type request xaiRequest
+	return json.Marshal(struct {
+		request
+		Temperature *float64 `json:"temperature,omitempty"`
+		TopP        *float64 `json:"top_p,omitempty"`
+	}{request(r), sent(r.Temperature, r.set.temperature), sent(r.TopP, r.set.topP)})
 }
 
 // xaiMessage represents a message in the conversation.
@@ -87,6 +102,68 @@ type xaiError struct {
 
 // Ask sends a message to xAI Grok and returns the response.
 func (x *XAIModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	req := x.newRequest(message, context)
+
+	// Marshal the request
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", x.chatURL(), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+x.config.APIKey)
+
+	// Send the request
+	resp, err := x.httpClient.Do(httpReq)
+	if err != nil {
+		return "", requestError(err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Handle error responses
+	if resp.StatusCode != http.StatusOK {
+		return "", x.apiError(resp.StatusCode, body)
+	}
+
+	// Parse the response
+	var xaiResp xaiResponse
+	if err := json.Unmarshal(body, &xaiResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// Extract the text content
+	if len(xaiResp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	choice := xaiResp.Choices[0]
+	if choice.Message.Content == "" {
+		return "", fmt.Errorf("no content in response message")
+	}
+
+	reportMetadata(ctx, xaiResp.Model, choice.FinishReason, xaiResp.Usage.PromptTokens, xaiResp.Usage.CompletionTokens)
+	return choice.Message.Content, nil
+}
+
+// newRequest builds the chat completion request for message, with the
+// history, system message and generation settings from the context.
+func (x *XAIModel) newRequest(message string, context map[string]interface{}) xaiRequest {
+	// Resolve generation defaults and per-request overrides
+	gen := resolveGeneration(x.config.Generation, openAICompatibleFallbackGeneration, context)
+
 	// Prepare the request
 	req := xaiRequest{
 		Model: x.config.Model,
@@ -96,11 +173,10 @@ func (x *XAIModel) Ask(ctx context.Context, message string, context map[string]i
 				Content: message,
 			},
 		},
-		MaxTokens:   1000,
-		Temperature: 0.7,
-		TopP:        1.0,
-		Stream:      false,
+		MaxTokens: gen.MaxTokens,
+		Stop:      gen.Stop,
 	}
+	req.Temperature, req.TopP, req.set = sampling(gen)
 
 	// Add conversation history if provided
 	if history, ok := context["history"]; ok {
@@ -126,98 +202,68 @@ func (x *XAIModel) Ask(ctx context.Context, message string, context map[string]i
 		}
 	}
 
-	// Add system message if provided
-	if systemMsg, ok := context["system"]; ok {
-		if sys, ok := systemMsg.(string); ok {
-			// Prepend system message
-			req.Messages = append([]xaiMessage{
-				{
-					Role:    "system",
-					Content: sys,
-				},
-			}, req.Messages...)
-		}
+	// Prepend the system message
+	if system := systemPrompt(context); system != "" {
+		req.Messages = append([]xaiMessage{{Role: "system", Content: system}}, req.Messages...)
 	}
 
-	// Override parameters from context if provided
-	if temp, ok := context["temperature"]; ok {
-		if temperature, ok := temp.(float64); ok {
-			req.Temperature = temperature
-		}
-	}
-	if maxTokens, ok := context["max_tokens"]; ok {
-		if tokens, ok := maxTokens.(int); ok {
-			req.MaxTokens = tokens
-		}
+	return req
+}
+
+// chatURL returns the chat completions URL.
+func (x *XAIModel) chatURL() string {
+	return x.apiBase() + "/v1/chat/completions"
+}
+
+// apiBase returns the API base URL.
+func (x *XAIModel) apiBase() string {
+	if x.config.Endpoint != "" {
+		return baseEndpoint(x.config.Endpoint, "/v1/chat/completions")
 	}
-	if topP, ok := context["top_p"]; ok {
-		if tp, ok := topP.(float64); ok {
-			req.TopP = tp
-		}
+	return "https://api.x.ai"
+}
+
+// apiError converts an error response into an error of its kind.
+func (x *XAIModel) apiError(status int, body []byte) error {
+	var errResp xaiError
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		return markAPIError(status, fmt.Errorf("xAI API error: %s", errResp.Error.Message))
 	}
+	return markAPIError(status, fmt.Errorf("xAI API error: status %d, body: %s", status, string(body)))
+}
+
+// AskStream sends a streaming request to xAI Grok and returns a channel of
+// responses.
+func (x *XAIModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	req := x.newRequest(message, context)
+	req.Stream = true
 
-	// Marshal the request
 	reqBody, err := json.Marshal(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Construct URL
-	endpoint := "https://api.x.ai"
-	if x.config.Endpoint != "" {
-		endpoint = x.config.Endpoint
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
-	url := fmt.Sprintf("%s/v1/chat/completions", endpoint)
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", x.chatURL(), bytes.NewBuffer(reqBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-
-	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+x.config.APIKey)
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-cache")
 
-	// Send the request
 	resp, err := x.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, requestError(err)
 	}
-	defer resp.Body.Close()
-
-	// Read the response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Handle error responses
 	if resp.StatusCode != http.StatusOK {
-		var errResp xaiError
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			return "", fmt.Errorf("xAI API error: %s", errResp.Error.Message)
-		}
-		return "", fmt.Errorf("xAI API error: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse the response
-	var xaiResp xaiResponse
-	if err := json.Unmarshal(body, &xaiResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, x.apiError(resp.StatusCode, body)
 	}
 
-	// Extract the text content
-	if len(xaiResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
-	}
-
-	choice := xaiResp.Choices[0]
-	if choice.Message.Content == "" {
-		return "", fmt.Errorf("no content in response message")
-	}
-
-	return choice.Message.Content, nil
+	// The API is OpenAI-compatible, streaming included
+	return readOpenAIStream(ctx, resp.Body, x.config.MaxStreamLineSize), nil
 }
 
 // Name returns the name of the model.
@@ -230,8 +276,19 @@ func (x *XAIModel) Provider() string {
 	return "xai"
 }
 
-// Health checks if the xAI API is accessible.
+// Health checks if the xAI API is accessible by listing its models, or
+// with a tiny completion if it has no models endpoint and
+// CompletionHealthCheck is set.
 func (x *XAIModel) Health(ctx context.Context) error {
+	header := http.Header{"Authorization": {"Bearer " + x.config.APIKey}}
+	err := checkModelsEndpoint(ctx, x.httpClient, x.apiBase()+"/v1/models", header, "xAI")
+	return healthFallback(err, x.config.CompletionHealthCheck, func() error {
+		return x.completionHealth(ctx)
+	})
+}
+
+// completionHealth checks the xAI API with a minimal completion.
+func (x *XAIModel) completionHealth(ctx context.Context) error {
 	// Create a simple test request
 	req := xaiRequest{
 		Model: x.config.Model,
@@ -249,13 +306,7 @@ func (x *XAIModel) Health(ctx context.Context) error {
 		return fmt.Errorf("failed to marshal health check request: %w", err)
 	}
 
-	endpoint := "https://api.x.ai"
-	if x.config.Endpoint != "" {
-		endpoint = x.config.Endpoint
-	}
-	url := fmt.Sprintf("%s/v1/chat/completions", endpoint)
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", x.chatURL(), bytes.NewBuffer(reqBody))
 	if err != nil {
 		return fmt.Errorf("failed to create health check request: %w", err)
 	}
@@ -2,12 +2,15 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
 )
 
 func TestXAIModel_Health(t *testing.T) {
@@ -185,3 +188,72 @@ func TestXAIModel_Ask_APIError(t *testing.T) {
 		t.Error("expected error for API error response")
 	}
 }
+
+func TestXAIModel_AskStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var request xaiRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		if !request.Stream || len(request.Messages) != 2 || request.Messages[0].Role != "system" {
+			t.Errorf("unexpected request %+v", request)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`data: {"model":"grok-beta","choices":[{"delta":{"content":"Hello"}}]}`,
+			`data: {"model":"grok-beta","choices":[{"delta":{"content":" world"},"finish_reason":"stop"}]}`,
+			`data: [DONE]`,
+		} {
+			w.Write([]byte(chunk + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	model, err := NewXAIModel(config.XAIConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+
+	meta := &streaming.Metadata{}
+	ctx := streaming.NewMetadataContext(context.Background(), meta)
+	ch, err := model.AskStream(ctx, "Hello", map[string]interface{}{"system": "Be brief."})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var result strings.Builder
+	for chunk := range ch {
+		result.WriteString(chunk)
+	}
+
+	if result.String() != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", result.String())
+	}
+	if meta.FinishReason != "stop" || meta.Model != "grok-beta" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestXAIModel_AskStream_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid key"}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewXAIModel(config.XAIConfig{APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+
+	ch, err := model.AskStream(context.Background(), "Hello", nil)
+	if err == nil || !strings.Contains(err.Error(), "xAI API error: invalid key") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+	if ch != nil {
+		t.Error("expected nil channel with error")
+	}
+}
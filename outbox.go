@@ -0,0 +1,74 @@
+package gochatbot
+
+import (
+	"context"
+	"sync"
+
+	"go.rumenx.com/chatbot/events"
+)
+
+// OutboxConversations is implemented by conversation stores that write
+// events to an outbox in the same transaction as an exchange, like
+// database.ConversationManager over a database.SQLConversationStore. A relay
+// such as database.OutboxRelay publishes them afterwards.
+type OutboxConversations interface {
+	// AppendExchangeWithEvents is AppendExchangeWithMetadata also storing
+	// events, all or none of them.
+	AppendExchangeWithEvents(ctx context.Context, conversationID, userID, message, reply string, messageMetadata, replyMetadata map[string]interface{}, evts []events.Event) (string, error)
+}
+
+// WithEventOutbox stores the events of requests in a conversation, such as
+// the message received and reply generated events, together with the
+// exchange in the outbox of the WithConversations store, which must be an
+// OutboxConversations. Either both are stored or neither, and the relay
+// publishing the outbox delivers every event of a stored exchange at least
+// once. Events of requests that store no exchange, such as failed ones,
+// and of exchanges that cannot be saved are published to the
+// WithEventPublisher publisher, if any.
+func WithEventOutbox() Option {
+	return func(c *Chatbot) {
+		c.outbox = true
+	}
+}
+
+// eventOutbox collects the events of a request until its exchange is
+// stored.
+type eventOutbox struct {
+	mutex  sync.Mutex
+	events []events.Event
+}
+
+type eventOutboxKey struct{}
+
+// newEventOutbox returns an outbox for a request in a conversation, or nil
+// without WithEventOutbox.
+func (c *Chatbot) newEventOutbox(askContext map[string]interface{}) *eventOutbox {
+	if conversationID, _ := askContext["conversation_id"].(string); !c.outbox || conversationID == "" {
+		return nil
+	}
+	return &eventOutbox{}
+}
+
+// eventOutboxFrom returns the outbox of the request, or nil.
+func eventOutboxFrom(ctx context.Context) *eventOutbox {
+	outbox, _ := ctx.Value(eventOutboxKey{}).(*eventOutbox)
+	return outbox
+}
+
+func (o *eventOutbox) add(event events.Event) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.events = append(o.events, event)
+}
+
+// take removes and returns the collected events. A nil outbox has none.
+func (o *eventOutbox) take() []events.Event {
+	if o == nil {
+		return nil
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	taken := o.events
+	o.events = nil
+	return taken
+}
@@ -0,0 +1,74 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/events"
+)
+
+// outboxConversations stores the events of each exchange in an outbox.
+type outboxConversations struct {
+	*fakeConversations
+	outbox []events.Event
+	err    error
+}
+
+func (o *outboxConversations) AppendExchangeWithEvents(ctx context.Context, conversationID, userID, message, reply string, messageMetadata, replyMetadata map[string]interface{}, evts []events.Event) (string, error) {
+	if o.err != nil {
+		return "", o.err
+	}
+	o.outbox = append(o.outbox, evts...)
+	return o.AppendExchange(ctx, conversationID, userID, message, reply)
+}
+
+func TestEventOutbox(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+
+	if _, err := New(cfg, WithModel(&echoModel{}), WithConversations(newFakeConversations(), 10), WithEventOutbox()); err == nil {
+		t.Fatal("expected an error for a store without an outbox")
+	}
+
+	store := &outboxConversations{fakeConversations: newFakeConversations()}
+	publisher := &recordingPublisher{}
+	bot, err := New(cfg, WithModel(&echoModel{}), WithConversations(store, 10), WithEventOutbox(),
+		WithEventPublisher(publisher), WithLogger(&recordingLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	response, err := bot.AskResponse(ctx, "Hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.outbox) != 2 || store.outbox[0].Type != events.MessageReceived || store.outbox[1].Type != events.ReplyGenerated {
+		t.Fatalf("expected the exchange's events in the outbox, got %+v", store.outbox)
+	}
+	if store.outbox[0].ConversationID != response.ConversationID {
+		t.Errorf("expected the events to carry the conversation %q, got %q", response.ConversationID, store.outbox[0].ConversationID)
+	}
+	if types := publisher.types(); len(types) != 0 {
+		t.Errorf("expected the outbox events not to be published directly, got %v", types)
+	}
+
+	// Requests outside a conversation have no exchange to store events with
+	if _, err := bot.Ask(ctx, "Hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if types := publisher.types(); len(types) != 2 {
+		t.Errorf("expected the events to be published directly, got %v", types)
+	}
+
+	// Events of exchanges that cannot be saved are not lost
+	store.err = errors.New("database unavailable")
+	if _, err := bot.Ask(ctx, "Hello", WithContext("conversation_id", response.ConversationID)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if types := publisher.types(); len(types) != 4 || len(store.outbox) != 2 {
+		t.Errorf("expected the unsaved exchange's events to be published directly, got %v", types)
+	}
+}
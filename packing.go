@@ -0,0 +1,57 @@
+package gochatbot
+
+import (
+	"go.rumenx.com/chatbot/packing"
+)
+
+// WithContextWindow fits each request into a context window of maxTokens
+// tokens. The system prompt and message are kept, room is left for the
+// reply's max tokens, and the rest is filled with the retrieved passages
+// and history messages worth the most, leaving out near-duplicate
+// passages, instead of sending the last messages of the history whatever
+// their size. History is always the most recent messages, without gaps.
+func WithContextWindow(maxTokens int, opts ...packing.Option) Option {
+	return func(c *Chatbot) {
+		c.packer = packing.New(maxTokens, opts...)
+	}
+}
+
+// pack trims the request's history to what fits the context window with
+// the passages, and returns the passages that fit.
+func (c *Chatbot) pack(message string, passages []Passage, askContext map[string]interface{}) []Passage {
+	if c.packer == nil {
+		return passages
+	}
+
+	prompt, _ := askContext["prompt"].(string)
+	history, _ := askContext["history"].([]map[string]interface{})
+	input := packing.Input{Prompt: prompt, Message: message, History: history, Reserve: c.replyTokens(askContext)}
+	for _, passage := range passages {
+		input.Passages = append(input.Passages, packing.Passage{Text: passage.Text, Score: passage.Score})
+	}
+	result := c.packer.Pack(input)
+
+	if len(history) > 0 {
+		if len(result.History) > 0 {
+			askContext["history"] = result.History
+		} else {
+			delete(askContext, "history")
+		}
+	}
+	kept := make([]Passage, len(result.Passages))
+	for i, index := range result.Passages {
+		kept[i] = passages[index]
+	}
+	return kept
+}
+
+// replyTokens returns the max tokens of the request's reply.
+func (c *Chatbot) replyTokens(askContext map[string]interface{}) int {
+	if maxTokens, ok := askContext["max_tokens"].(int); ok && maxTokens > 0 {
+		return maxTokens
+	}
+	if cfg := c.GetConfig(); cfg != nil {
+		return cfg.MaxTokens
+	}
+	return 0
+}
@@ -0,0 +1,255 @@
+// Package packing fits a request into a model's context window. Given the
+// token budget, a Packer keeps the system prompt and the message, then
+// fills the rest with the retrieved passages and history messages worth
+// the most, greedily by score, skipping near-duplicate passages, instead of
+// cutting the history at a fixed length.
+//
+//	packer := packing.New(8000)
+//	result := packer.Pack(packing.Input{
+//		Prompt:   prompt,
+//		Message:  message,
+//		Passages: passages,
+//		History:  history,
+//		Reserve:  1000, // for the reply
+//	})
+package packing
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Token costs beyond the text, as chat APIs count them.
+const (
+	messageOverhead = 4  // per history message
+	passageOverhead = 1  // per passage, the line break
+	contextHeader   = 10 // the line introducing the passages
+)
+
+// EstimateTokens estimates the tokens of text at four bytes per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Passage is a retrieved passage to pack.
+type Passage struct {
+	Text string
+	// Score is its relevance, like the similarity to the message. Passages
+	// without scores are scored by rank.
+	Score float64
+}
+
+// Input is what a request would send to the model.
+type Input struct {
+	Prompt   string
+	Message  string
+	Passages []Passage
+	// History holds the conversation's messages, oldest first, with
+	// "role" and "content" keys.
+	History []map[string]interface{}
+	// Reserve is the tokens kept free for the reply.
+	Reserve int
+}
+
+// Result is what fits.
+type Result struct {
+	// Passages are the indexes of the passages kept, most relevant first.
+	Passages []int
+	// History is the messages kept, oldest first; they are always the
+	// most recent ones, without gaps.
+	History []map[string]interface{}
+	// Tokens is the estimated size of the packed request.
+	Tokens int
+	// DroppedPassages counts passages left out for space, and Duplicates
+	// those left out as near-duplicates of a kept one.
+	DroppedPassages int
+	Duplicates      int
+	DroppedHistory  int
+}
+
+// Option configures a Packer.
+type Option func(*Packer)
+
+// WithTokenizer sets how tokens are counted, EstimateTokens by default.
+// Use the model's tokenizer when the budget is tight.
+func WithTokenizer(tokens func(text string) int) Option {
+	return func(p *Packer) {
+		p.tokens = tokens
+	}
+}
+
+// WithHistoryWeight scores the newest history message weight and each
+// older one decay times the one after it, against the relevance scores of
+// the passages. The defaults, 1 and 0.9, keep the last exchanges ahead of
+// all but the most relevant passages.
+func WithHistoryWeight(weight, decay float64) Option {
+	return func(p *Packer) {
+		p.historyWeight = weight
+		p.decay = decay
+	}
+}
+
+// WithDuplicateThreshold sets how alike two passages' words must be, as
+// the share of words they have in common, for the less relevant one to be
+// left out; 0.9 by default. 1 only drops identical passages.
+func WithDuplicateThreshold(threshold float64) Option {
+	return func(p *Packer) {
+		p.duplicate = threshold
+	}
+}
+
+// Packer fits requests into a context window.
+type Packer struct {
+	maxTokens     int
+	tokens        func(string) int
+	historyWeight float64
+	decay         float64
+	duplicate     float64
+}
+
+// New creates a packer for a context window of maxTokens tokens.
+func New(maxTokens int, opts ...Option) *Packer {
+	p := &Packer{
+		maxTokens:     maxTokens,
+		tokens:        EstimateTokens,
+		historyWeight: 1,
+		decay:         0.9,
+		duplicate:     0.9,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// candidate is a passage or history message competing for space.
+type candidate struct {
+	passage int // index of the passage, or -1
+	history int // index of the history message, or -1
+	score   float64
+	tokens  int
+}
+
+// Pack selects what fits of in. The prompt and message are always kept,
+// even if they alone exceed the window.
+func (p *Packer) Pack(in Input) Result {
+	result := Result{Tokens: p.tokens(in.Prompt) + p.tokens(in.Message) + messageOverhead}
+	free := p.maxTokens - result.Tokens - in.Reserve
+
+	var candidates []candidate
+	var kept [][]string // words of the passages kept so far
+	passages := p.rank(in.Passages)
+	for rank, i := range passages {
+		score := in.Passages[i].Score
+		if score == 0 {
+			score = 1 / float64(rank+2)
+		}
+		candidates = append(candidates, candidate{
+			passage: i, history: -1, score: score,
+			tokens: p.tokens(in.Passages[i].Text) + passageOverhead,
+		})
+	}
+	weight := p.historyWeight
+	for i := len(in.History) - 1; i >= 0; i-- {
+		content, _ := in.History[i]["content"].(string)
+		candidates = append(candidates, candidate{
+			passage: -1, history: i, score: weight,
+			tokens: p.tokens(content) + messageOverhead,
+		})
+		weight *= p.decay
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	// History is kept newest first without gaps, so once a message does
+	// not fit the older ones are dropped too
+	oldest := len(in.History)
+	historyFull := false
+	header := contextHeader
+	for _, c := range candidates {
+		if c.history >= 0 {
+			if historyFull || c.history != oldest-1 || c.tokens > free {
+				historyFull = true
+				continue
+			}
+			oldest = c.history
+			free -= c.tokens
+			result.Tokens += c.tokens
+			continue
+		}
+
+		words := passageWords(in.Passages[c.passage].Text)
+		if p.duplicates(words, kept) {
+			result.Duplicates++
+			continue
+		}
+		if c.tokens+header > free {
+			result.DroppedPassages++
+			continue
+		}
+		free -= c.tokens + header
+		result.Tokens += c.tokens + header
+		header = 0
+		kept = append(kept, words)
+		result.Passages = append(result.Passages, c.passage)
+	}
+
+	result.History = in.History[oldest:]
+	result.DroppedHistory = oldest
+	return result
+}
+
+// rank returns the indexes of passages, most relevant first; passages
+// without scores keep their order.
+func (p *Packer) rank(passages []Passage) []int {
+	order := make([]int, len(passages))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return passages[order[i]].Score > passages[order[j]].Score })
+	return order
+}
+
+// duplicates reports whether words are near-duplicates of a kept passage.
+func (p *Packer) duplicates(words []string, kept [][]string) bool {
+	for _, other := range kept {
+		if overlap(words, other) >= p.duplicate {
+			return true
+		}
+	}
+	return false
+}
+
+// overlap returns the share of the distinct words of a and b they have in
+// common.
+func overlap(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, word := range a {
+		set[word] = true
+	}
+	union := len(set)
+	common := 0
+	seen := make(map[string]bool, len(b))
+	for _, word := range b {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		if set[word] {
+			common++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 1
+	}
+	return float64(common) / float64(union)
+}
+
+// passageWords returns the lowercase words of text.
+func passageWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
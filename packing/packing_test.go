@@ -0,0 +1,89 @@
+package packing
+
+import (
+	"strings"
+	"testing"
+)
+
+// words returns n words, 5n-1 characters.
+func words(n int) string {
+	return strings.TrimSpace(strings.Repeat("word ", n))
+}
+
+func history(contents ...string) []map[string]interface{} {
+	messages := make([]map[string]interface{}, len(contents))
+	for i, content := range contents {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = map[string]interface{}{"role": role, "content": content}
+	}
+	return messages
+}
+
+func TestPackKeepsWhatFits(t *testing.T) {
+	// One token per character keeps the arithmetic readable
+	packer := New(110, WithTokenizer(func(text string) int { return len(text) }))
+	result := packer.Pack(Input{
+		Prompt:  strings.Repeat("p", 10),
+		Message: strings.Repeat("m", 6),
+		Passages: []Passage{
+			{Text: "the store opens at nine", Score: 0.9},
+			{Text: "returns are accepted within thirty days of purchase", Score: 0.5},
+			{Text: "parking is free", Score: 0.4},
+		},
+		History: history("old question", "old answer", "last question", "last answer"),
+		Reserve: 20,
+	})
+
+	// 110 - 20 (prompt, message) - 20 (reply) leaves 70: the two newest
+	// messages (15 and 17) and the first passage with the header (34),
+	// but not the other passages or older messages
+	if len(result.Passages) != 1 || result.Passages[0] != 0 {
+		t.Errorf("expected the most relevant passage, got %v", result.Passages)
+	}
+	if len(result.History) != 2 || result.History[0]["content"] != "last question" || result.DroppedHistory != 2 {
+		t.Errorf("expected the last exchange, got %v", result.History)
+	}
+	if result.DroppedPassages != 2 || result.Tokens != 86 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestPackDropsDuplicates(t *testing.T) {
+	result := New(1000).Pack(Input{
+		Message: "When do you open?",
+		Passages: []Passage{
+			{Text: "The store opens at nine, Monday to Friday.", Score: 0.9},
+			{Text: "The store opens at nine Monday to Friday", Score: 0.89},
+			{Text: "On weekends the store opens at ten.", Score: 0.8},
+		},
+	})
+	if len(result.Passages) != 2 || result.Passages[1] != 2 || result.Duplicates != 1 {
+		t.Errorf("expected the duplicate to be dropped, got %+v", result)
+	}
+}
+
+func TestPackHistoryWithoutGaps(t *testing.T) {
+	// A long middle message stops the history there, even though the
+	// older, short messages would fit
+	packer := New(60, WithTokenizer(func(text string) int { return len(text) }))
+	result := packer.Pack(Input{History: history("hi", strings.Repeat("x", 40), "ok", "yes")})
+	if len(result.History) != 2 || result.History[0]["content"] != "ok" {
+		t.Errorf("expected only the newest messages, got %v", result.History)
+	}
+}
+
+func TestPackScoresUnrankedPassagesByRank(t *testing.T) {
+	packer := New(40, WithHistoryWeight(0.4, 1))
+	result := packer.Pack(Input{
+		Passages: []Passage{{Text: words(8)}, {Text: "other text entirely here"}},
+		History:  history(words(8)),
+	})
+	// The first passage scores 0.5, ahead of the history, and the second
+	// 0.33, behind it; the second no longer fits
+	if len(result.Passages) != 1 || result.Passages[0] != 0 || len(result.History) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
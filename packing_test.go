@@ -0,0 +1,43 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestContextWindow(t *testing.T) {
+	cfg := config.Default()
+	cfg.Prompt = "You are a support assistant."
+	cfg.MaxTokens = 20
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	retriever := passageRetriever{
+		{ID: "hours-1", Text: "The store opens at nine, Monday to Friday.", Score: 0.9},
+		{ID: "hours-2", Text: "The store opens at nine Monday to Friday", Score: 0.88},
+	}
+	bot, err := New(cfg, WithModel(model), WithRetriever(retriever, 2), WithContextWindow(100))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	history := []map[string]interface{}{
+		{"role": "user", "content": strings.Repeat("A long question about something else. ", 6)},
+		{"role": "assistant", "content": "Sure."},
+		{"role": "user", "content": "Thanks"},
+	}
+	if _, err := bot.Ask(context.Background(), "When do you open?", WithContext("history", history)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	kept, _ := model.context["history"].([]map[string]interface{})
+	if len(kept) != 2 || kept[0]["content"] != "Sure." {
+		t.Errorf("expected the long message to be left out, got %v", kept)
+	}
+	prompt, _ := model.context["prompt"].(string)
+	if !strings.HasPrefix(prompt, cfg.Prompt) || strings.Count(prompt, "Monday to Friday") != 1 {
+		t.Errorf("expected one copy of the passage, got %q", prompt)
+	}
+}
@@ -0,0 +1,137 @@
+// Package privacy serves data subject requests: exporting everything stored
+// about a user, and erasing it.
+//
+// A Manager is built from the sources that hold user data, such as the
+// conversation store, user memory, knowledge embeddings and the guardrails
+// audit log, and cascades each request across all of them:
+//
+//	manager := privacy.NewManager(
+//		privacy.Conversations(store),
+//		privacy.Memories(memories),
+//		privacy.AuditLog(auditLogger),
+//	)
+//	report, err := manager.DeleteUserData(ctx, userID, privacy.DryRun())
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Source is a place where user data is stored.
+type Source interface {
+	// Name identifies the source in exports and reports, e.g. "conversations".
+	Name() string
+	// Export returns the user's data in a form that encodes to JSON.
+	Export(ctx context.Context, userID string) (interface{}, error)
+	// Delete erases the user's data and returns how many records were
+	// deleted. With dryRun it only counts the records it would delete.
+	Delete(ctx context.Context, userID string, dryRun bool) (int, error)
+}
+
+// Export is everything stored about a user, keyed by source name.
+type Export struct {
+	UserID     string                 `json:"user_id"`
+	ExportedAt time.Time              `json:"exported_at"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// Report describes an erasure: how many records each source deleted, or
+// would delete in a dry run.
+type Report struct {
+	UserID  string         `json:"user_id"`
+	DryRun  bool           `json:"dry_run"`
+	Deleted map[string]int `json:"deleted"`
+	// Errors holds the error of each source that failed.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Total returns the number of records deleted across sources.
+func (r *Report) Total() int {
+	total := 0
+	for _, count := range r.Deleted {
+		total += count
+	}
+	return total
+}
+
+// DeleteOption configures DeleteUserData.
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	dryRun bool
+}
+
+// DryRun reports what would be deleted without deleting anything.
+func DryRun() DeleteOption {
+	return func(opts *deleteOptions) {
+		opts.dryRun = true
+	}
+}
+
+// Manager cascades data subject requests across sources.
+type Manager struct {
+	sources []Source
+	now     func() time.Time
+}
+
+// NewManager creates a Manager for the given sources.
+func NewManager(sources ...Source) *Manager {
+	return &Manager{sources: sources, now: time.Now}
+}
+
+// ExportUserData collects everything stored about a user. It fails if any
+// source fails, since a partial export would be misleading.
+func (m *Manager) ExportUserData(ctx context.Context, userID string) (*Export, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	export := &Export{
+		UserID:     userID,
+		ExportedAt: m.now(),
+		Data:       make(map[string]interface{}, len(m.sources)),
+	}
+	for _, source := range m.sources {
+		data, err := source.Export(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", source.Name(), err)
+		}
+		export.Data[source.Name()] = data
+	}
+	return export, nil
+}
+
+// DeleteUserData erases everything stored about a user. A failing source
+// does not stop the others; the report records what was deleted and the
+// returned error joins the failures, so the request can be retried.
+func (m *Manager) DeleteUserData(ctx context.Context, userID string, opts ...DeleteOption) (*Report, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+	options := &deleteOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	report := &Report{
+		UserID:  userID,
+		DryRun:  options.dryRun,
+		Deleted: make(map[string]int, len(m.sources)),
+	}
+	var errs []error
+	for _, source := range m.sources {
+		count, err := source.Delete(ctx, userID, options.dryRun)
+		report.Deleted[source.Name()] = count
+		if err != nil {
+			if report.Errors == nil {
+				report.Errors = make(map[string]string)
+			}
+			report.Errors[source.Name()] = err.Error()
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", source.Name(), err))
+		}
+	}
+	return report, errors.Join(errs...)
+}
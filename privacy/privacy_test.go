@@ -0,0 +1,164 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/guardrails"
+	"go.rumenx.com/chatbot/profile"
+)
+
+// fixture stores data for users u1 and u2 in every kind of source.
+func fixture(t *testing.T) (*Manager, *chatbottest.Store, *profile.Manager, *embeddings.VectorStore, *guardrails.FileAuditLogger) {
+	t.Helper()
+	ctx := context.Background()
+
+	store := chatbottest.NewStore()
+	for _, conv := range []*database.Conversation{{ID: "c1", UserID: "u1"}, {ID: "c2", UserID: "u2"}} {
+		if err := store.CreateConversation(ctx, conv); err != nil {
+			t.Fatalf("failed to create conversation: %v", err)
+		}
+	}
+	for _, msg := range []*database.Message{
+		{ID: "m1", ConversationID: "c1", Role: "user", Content: "I'm vegetarian"},
+		{ID: "m2", ConversationID: "c1", Role: "assistant", Content: "Noted!"},
+		{ID: "m3", ConversationID: "c2", Role: "user", Content: "Hello"},
+	} {
+		if err := store.AddMessage(ctx, msg); err != nil {
+			t.Fatalf("failed to add message: %v", err)
+		}
+	}
+
+	memories := profile.NewManager(profile.NewMemoryStore())
+	_, _ = memories.Add(ctx, "u1", "Is a vegetarian")
+	_, _ = memories.Add(ctx, "u2", "Prefers tea")
+
+	vectors := embeddings.NewVectorStore(nil)
+	_ = vectors.AddVectors([]embeddings.Vector{{1, 0}, {0, 1}}, []map[string]interface{}{
+		{"user_id": "u1", "text": "vegetarian recipes"},
+		{"user_id": "u2", "text": "tea"},
+	})
+
+	audit, err := guardrails.NewFileAuditLogger(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	t.Cleanup(func() { _ = audit.Close() })
+	audit.Log(ctx, guardrails.AuditEntry{Rule: "pii", UserID: "u1"})
+	audit.Log(ctx, guardrails.AuditEntry{Rule: "pii", UserID: "u2"})
+
+	manager := NewManager(Conversations(store), Memories(memories), Embeddings(vectors, "user_id"), AuditLog(audit))
+	return manager, store, memories, vectors, audit
+}
+
+func TestExportUserData(t *testing.T) {
+	manager, _, _, _, _ := fixture(t)
+
+	export, err := manager.ExportUserData(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("failed to encode export: %v", err)
+	}
+	for _, want := range []string{`"I'm vegetarian"`, `"Is a vegetarian"`, `"vegetarian recipes"`, `"rule":"pii"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in export %s", want, data)
+		}
+	}
+	for _, other := range []string{"Hello", "Prefers tea", `"tea"`} {
+		if strings.Contains(string(data), other) {
+			t.Errorf("export leaked another user's data %s", other)
+		}
+	}
+}
+
+func TestDeleteUserData(t *testing.T) {
+	manager, store, memories, vectors, audit := fixture(t)
+	ctx := context.Background()
+
+	report, err := manager.DeleteUserData(ctx, "u1", DryRun())
+	if err != nil {
+		t.Fatalf("failed dry run: %v", err)
+	}
+	want := map[string]int{"conversations": 3, "memories": 1, "embeddings": 1, "audit_log": 1}
+	for name, count := range want {
+		if report.Deleted[name] != count {
+			t.Errorf("expected %d %s, got %d", count, name, report.Deleted[name])
+		}
+	}
+	if !report.DryRun || report.Total() != 6 || len(store.Messages()) != 3 || vectors.Count() != 2 {
+		t.Fatalf("dry run deleted data: %+v", report)
+	}
+
+	report, err = manager.DeleteUserData(ctx, "u1")
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if report.DryRun || report.Total() != 6 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if messages := store.Messages(); len(messages) != 1 || messages[0].ID != "m3" {
+		t.Errorf("expected only the other user's messages, got %+v", messages)
+	}
+	if facts, _ := memories.Facts(ctx, "u1"); len(facts) != 0 {
+		t.Errorf("expected memories to be erased, got %+v", facts)
+	}
+	if facts, _ := memories.Facts(ctx, "u2"); len(facts) != 1 {
+		t.Errorf("expected other user's memories kept, got %+v", facts)
+	}
+	if vectors.Count() != 1 {
+		t.Errorf("expected one embedding left, got %d", vectors.Count())
+	}
+	if entries, _ := audit.UserEntries(ctx, "u2"); len(entries) != 1 {
+		t.Errorf("expected other user's audit entries kept, got %+v", entries)
+	}
+
+	export, err := manager.ExportUserData(ctx, "u1")
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if data, _ := json.Marshal(export.Data); string(data) != `{"audit_log":[],"conversations":[],"embeddings":[],"memories":[]}` {
+		t.Errorf("expected an empty export, got %s", data)
+	}
+}
+
+// failingSource fails every request.
+type failingSource struct{}
+
+func (failingSource) Name() string { return "broken" }
+func (failingSource) Export(ctx context.Context, userID string) (interface{}, error) {
+	return nil, errors.New("unavailable")
+}
+func (failingSource) Delete(ctx context.Context, userID string, dryRun bool) (int, error) {
+	return 0, errors.New("unavailable")
+}
+
+func TestDeleteUserData_ContinuesAfterFailure(t *testing.T) {
+	memories := profile.NewManager(profile.NewMemoryStore())
+	_, _ = memories.Add(context.Background(), "u1", "Likes jazz")
+	manager := NewManager(failingSource{}, Memories(memories))
+
+	report, err := manager.DeleteUserData(context.Background(), "u1")
+	if err == nil || !strings.Contains(err.Error(), "failed to delete broken") {
+		t.Errorf("expected the failure to be reported, got %v", err)
+	}
+	if report.Deleted["memories"] != 1 || report.Errors["broken"] != "unavailable" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if _, err := manager.ExportUserData(context.Background(), "u1"); err == nil {
+		t.Error("expected export to fail")
+	}
+	if _, err := manager.DeleteUserData(context.Background(), ""); err == nil {
+		t.Error("expected an error for an empty user ID")
+	}
+}
@@ -0,0 +1,179 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/embeddings"
+	"go.rumenx.com/chatbot/guardrails"
+	"go.rumenx.com/chatbot/profile"
+)
+
+// conversationPage is how many conversations are listed per store call.
+const conversationPage = 100
+
+// ConversationExport is one conversation with its messages.
+type ConversationExport struct {
+	Conversation *database.Conversation `json:"conversation"`
+	Messages     []*database.Message    `json:"messages"`
+}
+
+type conversationSource struct {
+	store database.ConversationStore
+}
+
+// Conversations covers the user's conversations and their messages. Deleted
+// records count both conversations and messages.
+func Conversations(store database.ConversationStore) Source {
+	return &conversationSource{store: store}
+}
+
+func (s *conversationSource) Name() string {
+	return "conversations"
+}
+
+func (s *conversationSource) Export(ctx context.Context, userID string) (interface{}, error) {
+	conversations, err := s.list(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	exports := make([]ConversationExport, 0, len(conversations))
+	for _, conv := range conversations {
+		messages, err := s.store.GetConversationHistory(ctx, conv.ID)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, ConversationExport{Conversation: conv, Messages: messages})
+	}
+	return exports, nil
+}
+
+func (s *conversationSource) Delete(ctx context.Context, userID string, dryRun bool) (int, error) {
+	conversations, err := s.list(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, conv := range conversations {
+		messages, err := s.store.GetConversationHistory(ctx, conv.ID)
+		if err != nil {
+			return deleted, err
+		}
+		if !dryRun {
+			if err := s.store.DeleteConversation(ctx, conv.ID); err != nil {
+				return deleted, err
+			}
+		}
+		deleted += 1 + len(messages)
+	}
+	return deleted, nil
+}
+
+// list pages through all of the user's conversations.
+func (s *conversationSource) list(ctx context.Context, userID string) ([]*database.Conversation, error) {
+	var conversations []*database.Conversation
+	for offset := 0; ; offset += conversationPage {
+		page, err := s.store.ListConversations(ctx, userID, conversationPage, offset)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, page...)
+		if len(page) < conversationPage {
+			return conversations, nil
+		}
+	}
+}
+
+type memorySource struct {
+	manager *profile.Manager
+}
+
+// Memories covers the facts remembered about the user.
+func Memories(manager *profile.Manager) Source {
+	return &memorySource{manager: manager}
+}
+
+func (s *memorySource) Name() string {
+	return "memories"
+}
+
+func (s *memorySource) Export(ctx context.Context, userID string) (interface{}, error) {
+	return s.manager.Facts(ctx, userID)
+}
+
+func (s *memorySource) Delete(ctx context.Context, userID string, dryRun bool) (int, error) {
+	facts, err := s.manager.Facts(ctx, userID)
+	if err != nil || dryRun {
+		return len(facts), err
+	}
+	if err := s.manager.ForgetUser(ctx, userID); err != nil {
+		return 0, err
+	}
+	return len(facts), nil
+}
+
+type embeddingSource struct {
+	store *embeddings.VectorStore
+	key   string
+}
+
+// Embeddings covers vector store entries whose metadata holds the user ID
+// under key, e.g. "user_id". Exports contain the entries' metadata.
+func Embeddings(store *embeddings.VectorStore, key string) Source {
+	return &embeddingSource{store: store, key: key}
+}
+
+func (s *embeddingSource) Name() string {
+	return "embeddings"
+}
+
+func (s *embeddingSource) match(userID string) func(map[string]interface{}) bool {
+	return func(metadata map[string]interface{}) bool {
+		return fmt.Sprint(metadata[s.key]) == userID
+	}
+}
+
+func (s *embeddingSource) Export(ctx context.Context, userID string) (interface{}, error) {
+	entries := s.store.Find(s.match(userID))
+	if entries == nil {
+		entries = []map[string]interface{}{}
+	}
+	return entries, nil
+}
+
+func (s *embeddingSource) Delete(ctx context.Context, userID string, dryRun bool) (int, error) {
+	if dryRun {
+		return len(s.store.Find(s.match(userID))), nil
+	}
+	return s.store.Delete(s.match(userID)), nil
+}
+
+type auditSource struct {
+	logger *guardrails.FileAuditLogger
+}
+
+// AuditLog covers the guardrails audit entries recorded for the user.
+func AuditLog(logger *guardrails.FileAuditLogger) Source {
+	return &auditSource{logger: logger}
+}
+
+func (s *auditSource) Name() string {
+	return "audit_log"
+}
+
+func (s *auditSource) Export(ctx context.Context, userID string) (interface{}, error) {
+	entries, err := s.logger.UserEntries(ctx, userID)
+	if entries == nil && err == nil {
+		entries = []guardrails.AuditEntry{}
+	}
+	return entries, err
+}
+
+func (s *auditSource) Delete(ctx context.Context, userID string, dryRun bool) (int, error) {
+	if dryRun {
+		entries, err := s.logger.UserEntries(ctx, userID)
+		return len(entries), err
+	}
+	return s.logger.DeleteUserEntries(ctx, userID)
+}
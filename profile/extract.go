@@ -0,0 +1,77 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// Extractor finds durable facts about the user in a message.
+type Extractor interface {
+	// Extract returns new facts stated in message. known lists the facts
+	// already remembered, so they need not be repeated.
+	Extract(ctx context.Context, message string, known []string) ([]string, error)
+}
+
+// ExtractorFunc adapts a function to the Extractor interface.
+type ExtractorFunc func(ctx context.Context, message string, known []string) ([]string, error)
+
+// Extract implements Extractor.
+func (f ExtractorFunc) Extract(ctx context.Context, message string, known []string) ([]string, error) {
+	return f(ctx, message, known)
+}
+
+// extractorPrompt instructs the model to extract facts.
+const extractorPrompt = `You maintain a long-term profile of a user. From the user's message, extract facts about the user that will still be true in future conversations, such as preferences, dietary needs, preferred units or language, location or profession. Ignore one-off requests, questions, and facts about other people. Do not repeat known facts. Respond with JSON only, in the form {"facts": ["<short fact in the third person, e.g. prefers metric units>", ...]}. Use an empty list if there is nothing to remember.
+
+Known facts:
+%s`
+
+// ModelExtractor asks a model to extract facts.
+type ModelExtractor struct {
+	model models.Model
+}
+
+// NewModelExtractor creates an extractor using model, ideally a small,
+// cheap one, since it runs on every message.
+func NewModelExtractor(model models.Model) *ModelExtractor {
+	return &ModelExtractor{model: model}
+}
+
+// Extract implements Extractor.
+func (e *ModelExtractor) Extract(ctx context.Context, message string, known []string) ([]string, error) {
+	list := "(none)"
+	if len(known) > 0 {
+		list = "- " + strings.Join(known, "\n- ")
+	}
+
+	reply, err := e.model.Ask(ctx, message, map[string]interface{}{
+		"prompt":      fmt.Sprintf(extractorPrompt, list),
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fact extraction request failed: %w", err)
+	}
+
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no facts JSON in model reply")
+	}
+	var parsed struct {
+		Facts []string `json:"facts"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse facts: %w", err)
+	}
+
+	facts := make([]string, 0, len(parsed.Facts))
+	for _, fact := range parsed.Facts {
+		if fact = strings.TrimSpace(fact); fact != "" {
+			facts = append(facts, fact)
+		}
+	}
+	return facts, nil
+}
@@ -0,0 +1,87 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Handler returns the memory API, so users can see and delete what is
+// remembered about them. Mount it under a prefix with http.StripPrefix and
+// put it behind your own authentication, making sure users can only reach
+// their own ID:
+//
+//	GET    /{user}        lists the user's facts
+//	POST   /{user}        {"text": "..."} adds a fact
+//	DELETE /{user}/{id}   deletes one fact
+//	DELETE /{user}        deletes all of the user's facts
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{user}", m.handleList)
+	mux.HandleFunc("POST /{user}", m.handleAdd)
+	mux.HandleFunc("DELETE /{user}/{id}", m.handleDelete)
+	mux.HandleFunc("DELETE /{user}", m.handleDeleteUser)
+	return mux
+}
+
+func (m *Manager) handleList(w http.ResponseWriter, r *http.Request) {
+	facts, err := m.Facts(r.Context(), r.PathValue("user"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"facts": facts})
+}
+
+func (m *Manager) handleAdd(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON request")
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "Text cannot be empty")
+		return
+	}
+
+	fact, err := m.Add(r.Context(), r.PathValue("user"), req.Text)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, fact)
+}
+
+func (m *Manager) handleDelete(w http.ResponseWriter, r *http.Request) {
+	err := m.Forget(r.Context(), r.PathValue("user"), r.PathValue("id"))
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Manager) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := m.ForgetUser(r.Context(), r.PathValue("user")); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,265 @@
+// Package profile remembers durable facts about users across conversations.
+//
+// A Manager extracts facts such as "prefers metric units" or "is a
+// vegetarian" from user messages with an Extractor, stores them per user,
+// and recalls the ones relevant to a new message so the chatbot can take
+// them into account. Users can view and delete what is remembered about
+// them through Manager.Handler.
+//
+//	manager := profile.NewManager(profile.NewMemoryStore(),
+//		profile.WithExtractor(profile.NewModelExtractor(smallModel)))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithMemory(manager))
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a fact does not exist.
+var ErrNotFound = errors.New("fact not found")
+
+// Fact is something durable known about a user.
+type Fact struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Text   string `json:"text"`
+	// ConversationID is the conversation the fact was learned in.
+	ConversationID string    `json:"conversation_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Store persists facts per user.
+type Store interface {
+	// Add stores a new fact.
+	Add(ctx context.Context, fact *Fact) error
+	// List returns a user's facts, oldest first.
+	List(ctx context.Context, userID string) ([]*Fact, error)
+	// Delete removes one of a user's facts, returning ErrNotFound if it
+	// does not exist.
+	Delete(ctx context.Context, userID, id string) error
+	// DeleteUser removes all of a user's facts.
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithExtractor sets the extractor used by Learn. Without one, Learn does
+// nothing and facts can only be added with Add.
+func WithExtractor(extractor Extractor) Option {
+	return func(m *Manager) {
+		m.extractor = extractor
+	}
+}
+
+// WithRecallLimit sets how many facts Recall returns (default 5).
+func WithRecallLimit(limit int) Option {
+	return func(m *Manager) {
+		m.recallLimit = limit
+	}
+}
+
+// WithMaxFacts sets how many facts are kept per user (default 50). The
+// oldest facts are forgotten first.
+func WithMaxFacts(max int) Option {
+	return func(m *Manager) {
+		m.maxFacts = max
+	}
+}
+
+// Manager learns and recalls facts about users.
+type Manager struct {
+	store       Store
+	extractor   Extractor
+	recallLimit int
+	maxFacts    int
+	now         func() time.Time
+}
+
+// NewManager creates a Manager that keeps facts in store.
+func NewManager(store Store, opts ...Option) *Manager {
+	m := &Manager{
+		store:       store,
+		recallLimit: 5,
+		maxFacts:    50,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Learn extracts durable facts from a user's message and stores the new
+// ones.
+func (m *Manager) Learn(ctx context.Context, userID, conversationID, message string) error {
+	if m.extractor == nil || userID == "" {
+		return nil
+	}
+
+	known, err := m.store.List(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load facts: %w", err)
+	}
+	texts := make([]string, len(known))
+	for i, fact := range known {
+		texts[i] = fact.Text
+	}
+
+	extracted, err := m.extractor.Extract(ctx, message, texts)
+	if err != nil {
+		return err
+	}
+	for _, text := range extracted {
+		if containsFact(texts, text) {
+			continue
+		}
+		if _, err := m.add(ctx, userID, conversationID, text); err != nil {
+			return err
+		}
+		texts = append(texts, text)
+	}
+	return m.trim(ctx, userID)
+}
+
+// Add stores a fact about a user, e.g. one they entered in their settings.
+func (m *Manager) Add(ctx context.Context, userID, text string) (*Fact, error) {
+	text = strings.TrimSpace(text)
+	if userID == "" || text == "" {
+		return nil, fmt.Errorf("user ID and fact text are required")
+	}
+	fact, err := m.add(ctx, userID, "", text)
+	if err != nil {
+		return nil, err
+	}
+	return fact, m.trim(ctx, userID)
+}
+
+func (m *Manager) add(ctx context.Context, userID, conversationID, text string) (*Fact, error) {
+	fact := &Fact{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		Text:           text,
+		ConversationID: conversationID,
+		CreatedAt:      m.now(),
+	}
+	if err := m.store.Add(ctx, fact); err != nil {
+		return nil, fmt.Errorf("failed to store fact: %w", err)
+	}
+	return fact, nil
+}
+
+// trim forgets the oldest facts beyond the per-user limit.
+func (m *Manager) trim(ctx context.Context, userID string) error {
+	if m.maxFacts <= 0 {
+		return nil
+	}
+	facts, err := m.store.List(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load facts: %w", err)
+	}
+	for i := 0; i < len(facts)-m.maxFacts; i++ {
+		if err := m.store.Delete(ctx, userID, facts[i].ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to forget fact: %w", err)
+		}
+	}
+	return nil
+}
+
+// Recall returns the facts about a user most relevant to a message: those
+// sharing the most words with it, then the most recent.
+func (m *Manager) Recall(ctx context.Context, userID, message string) ([]string, error) {
+	if userID == "" {
+		return nil, nil
+	}
+	facts, err := m.store.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load facts: %w", err)
+	}
+
+	words := wordSet(message)
+	scores := make(map[string]int, len(facts))
+	for _, fact := range facts {
+		for word := range wordSet(fact.Text) {
+			if words[word] {
+				scores[fact.ID]++
+			}
+		}
+	}
+	ranked := make([]*Fact, len(facts))
+	copy(ranked, facts)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if scores[ranked[i].ID] != scores[ranked[j].ID] {
+			return scores[ranked[i].ID] > scores[ranked[j].ID]
+		}
+		return ranked[i].CreatedAt.After(ranked[j].CreatedAt)
+	})
+
+	if m.recallLimit > 0 && len(ranked) > m.recallLimit {
+		ranked = ranked[:m.recallLimit]
+	}
+	texts := make([]string, len(ranked))
+	for i, fact := range ranked {
+		texts[i] = fact.Text
+	}
+	return texts, nil
+}
+
+// Facts returns everything remembered about a user, oldest first.
+func (m *Manager) Facts(ctx context.Context, userID string) ([]*Fact, error) {
+	return m.store.List(ctx, userID)
+}
+
+// Forget deletes one fact about a user.
+func (m *Manager) Forget(ctx context.Context, userID, id string) error {
+	return m.store.Delete(ctx, userID, id)
+}
+
+// ForgetUser deletes everything remembered about a user.
+func (m *Manager) ForgetUser(ctx context.Context, userID string) error {
+	return m.store.DeleteUser(ctx, userID)
+}
+
+// containsFact reports whether text repeats a known fact, ignoring case,
+// punctuation and spacing.
+func containsFact(known []string, text string) bool {
+	key := normalize(text)
+	for _, fact := range known {
+		if normalize(fact) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// stopWords are too common to make a fact relevant.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "with": true,
+	"you": true, "your": true, "user": true, "has": true, "have": true, "what": true,
+	"that": true, "this": true, "from": true, "can": true, "how": true, "who": true,
+}
+
+// wordSet returns the distinct meaningful words of text.
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(normalize(text)) {
+		if len(word) >= 3 && !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
@@ -0,0 +1,168 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replyModel returns a fixed reply and records the prompt it was sent.
+type replyModel struct {
+	reply  string
+	prompt string
+}
+
+func (m *replyModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.prompt, _ = context["prompt"].(string)
+	return m.reply, nil
+}
+
+func (m *replyModel) Name() string     { return "extractor" }
+func (m *replyModel) Provider() string { return "test" }
+
+// clock returns a time one minute later on every call.
+func clock() func() time.Time {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func TestManager_LearnAndRecall(t *testing.T) {
+	extracted := [][]string{
+		{"Is a vegetarian", "Prefers metric units"},
+		{"is a vegetarian.", "Lives in Sofia"},
+	}
+	manager := NewManager(NewMemoryStore(), WithRecallLimit(2),
+		WithExtractor(ExtractorFunc(func(ctx context.Context, message string, known []string) ([]string, error) {
+			facts := extracted[0]
+			extracted = extracted[1:]
+			return facts, nil
+		})))
+	manager.now = clock()
+	ctx := context.Background()
+
+	if err := manager.Learn(ctx, "u1", "c1", "I'm vegetarian and use metric"); err != nil {
+		t.Fatalf("failed to learn: %v", err)
+	}
+	if err := manager.Learn(ctx, "u1", "c2", "I live in Sofia"); err != nil {
+		t.Fatalf("failed to learn: %v", err)
+	}
+
+	facts, _ := manager.Facts(ctx, "u1")
+	if len(facts) != 3 || facts[2].Text != "Lives in Sofia" || facts[2].ConversationID != "c2" {
+		t.Fatalf("expected duplicates to be skipped, got %+v", facts)
+	}
+
+	recalled, err := manager.Recall(ctx, "u1", "Any vegetarian recipes?")
+	if err != nil {
+		t.Fatalf("failed to recall: %v", err)
+	}
+	if strings.Join(recalled, "|") != "Is a vegetarian|Lives in Sofia" {
+		t.Errorf("expected the relevant fact, then the newest, got %v", recalled)
+	}
+	if recalled, _ := manager.Recall(ctx, "u2", "hi"); len(recalled) != 0 {
+		t.Errorf("expected nothing for another user, got %v", recalled)
+	}
+}
+
+func TestManager_MaxFacts(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), WithMaxFacts(2))
+	manager.now = clock()
+	ctx := context.Background()
+
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := manager.Add(ctx, "u1", text); err != nil {
+			t.Fatalf("failed to add: %v", err)
+		}
+	}
+	facts, _ := manager.Facts(ctx, "u1")
+	if len(facts) != 2 || facts[0].Text != "two" {
+		t.Errorf("expected the oldest fact to be forgotten, got %+v", facts)
+	}
+}
+
+func TestManager_Forget(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+	ctx := context.Background()
+	fact, _ := manager.Add(ctx, "u1", "Prefers email")
+	_, _ = manager.Add(ctx, "u1", "Speaks German")
+
+	if err := manager.Forget(ctx, "u1", fact.ID); err != nil {
+		t.Fatalf("failed to forget: %v", err)
+	}
+	if err := manager.Forget(ctx, "u1", fact.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := manager.ForgetUser(ctx, "u1"); err != nil {
+		t.Fatalf("failed to forget user: %v", err)
+	}
+	if facts, _ := manager.Facts(ctx, "u1"); len(facts) != 0 {
+		t.Errorf("expected no facts, got %+v", facts)
+	}
+}
+
+func TestModelExtractor(t *testing.T) {
+	model := &replyModel{reply: "Sure:\n{\"facts\": [\" Prefers metric units \", \"\"]}"}
+	facts, err := NewModelExtractor(model).Extract(context.Background(), "Use metric please, always", []string{"Is a vegetarian"})
+	if err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+	if len(facts) != 1 || facts[0] != "Prefers metric units" {
+		t.Errorf("unexpected facts %v", facts)
+	}
+	if !strings.Contains(model.prompt, "- Is a vegetarian") {
+		t.Errorf("expected known facts in prompt, got %q", model.prompt)
+	}
+
+	model.reply = "nothing"
+	if _, err := NewModelExtractor(model).Extract(context.Background(), "hi", nil); err == nil {
+		t.Error("expected an error for a reply without JSON")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+	handler := manager.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/u1", strings.NewReader(`{"text": "Prefers tea"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var fact Fact
+	_ = json.Unmarshal(rec.Body.Bytes(), &fact)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/u1", nil))
+	var list struct {
+		Facts []Fact `json:"facts"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.Facts) != 1 || list.Facts[0].Text != "Prefers tea" {
+		t.Errorf("unexpected list response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/u1/"+fact.ID, nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/u1/"+fact.ID, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/u1", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+}
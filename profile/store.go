@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps facts in memory. Facts are lost when the process exits;
+// implement Store to keep them in a database.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	facts map[string][]*Fact
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{facts: make(map[string][]*Fact)}
+}
+
+// Add implements Store.
+func (s *MemoryStore) Add(ctx context.Context, fact *Fact) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored := *fact
+	s.facts[fact.UserID] = append(s.facts[fact.UserID], &stored)
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context, userID string) ([]*Fact, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	facts := make([]*Fact, len(s.facts[userID]))
+	for i, fact := range s.facts[userID] {
+		copied := *fact
+		facts[i] = &copied
+	}
+	return facts, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, userID, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	facts := s.facts[userID]
+	for i, fact := range facts {
+		if fact.ID == id {
+			s.facts[userID] = append(facts[:i:i], facts[i+1:]...)
+			if len(s.facts[userID]) == 0 {
+				delete(s.facts, userID)
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteUser implements Store.
+func (s *MemoryStore) DeleteUser(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.facts, userID)
+	return nil
+}
@@ -0,0 +1,155 @@
+package gochatbot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/google/uuid"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+// Logger receives the chatbot's log messages, such as recovered panics and
+// failures in background work. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets where the chatbot logs. The default is the standard
+// logger of the log package.
+func WithLogger(logger Logger) Option {
+	return func(c *Chatbot) {
+		c.logger = logger
+	}
+}
+
+// logf logs through the chatbot's logger. Ask and AskStream pass it on in
+// the request context, so the streams, guardrails, shadow models and rate
+// limiters they call log through it too.
+func (c *Chatbot) logf(format string, v ...interface{}) {
+	if c.logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+	c.logger.Printf(format, v...)
+}
+
+// PanicError is a panic recovered by the chatbot. Clients are only told the
+// reference, which identifies the logged panic and stack.
+type PanicError struct {
+	Reference string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("internal error (reference %s)", e.Reference)
+}
+
+// HandlePanic records a panic recovered from the chatbot's work: it logs the
+// value and stack, counts it in Panics and calls Hooks.OnPanic. Call it from
+// the deferred function that recovered the value. The adapters' recovery
+// middleware uses it.
+func (c *Chatbot) HandlePanic(value interface{}) *PanicError {
+	return c.handlePanic(value, debug.Stack())
+}
+
+// handlePanic records a panic recovered with the given stack.
+func (c *Chatbot) handlePanic(value interface{}, stack []byte) *PanicError {
+	err := &PanicError{Reference: uuid.New().String(), Value: value, Stack: stack}
+	c.panics.Add(1)
+	c.logf("panic recovered (reference %s): %v\n%s", err.Reference, value, stack)
+	if c.hooks.OnPanic != nil {
+		c.hooks.OnPanic(err)
+	}
+	return err
+}
+
+// Panics returns how many panics the chatbot has recovered, for metrics.
+func (c *Chatbot) Panics() uint64 {
+	return c.panics.Load()
+}
+
+// recoverBackground recovers a panic in a background goroutine so it does
+// not crash the process. It must be deferred directly.
+func (c *Chatbot) recoverBackground() {
+	if value := recover(); value != nil {
+		c.handlePanic(value, debug.Stack())
+	}
+}
+
+// Recover returns middleware that turns a panic in next into a 500 JSON
+// error carrying the panic's reference, encoded like the chat responses.
+// HandleHTTP and HandleStreamHTTP recover on their own.
+func (c *Chatbot) Recover(next http.Handler) http.Handler {
+	handler := NewHTTPHandler(c)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &recoveryWriter{ResponseWriter: w}
+		defer handler.recoverPanic(writer)
+		next.ServeHTTP(writer, r)
+	})
+}
+
+// recoverPanic answers a request whose handler panicked. A response that
+// has already begun, like a stream, cannot be replaced and just ends. It
+// must be deferred directly.
+func (h *HTTPHandler) recoverPanic(w *recoveryWriter) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	if value == http.ErrAbortHandler {
+		// Deliberate aborts are left to net/http
+		panic(value)
+	}
+
+	err := h.chatbot.HandlePanic(value)
+	if w.wroteHeader {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	h.writeResponse(w, http.StatusInternalServerError, &ChatResponse{
+		Error:     "Internal server error",
+		Code:      chaterrors.CodeInternal,
+		Reference: err.Reference,
+	})
+}
+
+// recoveryWriter notes whether the response has begun.
+type recoveryWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoveryWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recoveryWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush supports streaming responses.
+func (w *recoveryWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError flushes, reporting a failure to http.ResponseController so
+// streams notice clients that went away.
+func (w *recoveryWriter) FlushError() error {
+	err := http.NewResponseController(w.ResponseWriter).Flush()
+	if !errors.Is(err, http.ErrNotSupported) {
+		w.wroteHeader = true
+	}
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *recoveryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
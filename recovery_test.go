@@ -0,0 +1,155 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/streaming"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// panickingModel panics while answering, or in its stream goroutine.
+type panickingModel struct{}
+
+func (m *panickingModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	panic("model failure")
+}
+
+func (m *panickingModel) AskStream(ctx context.Context, message string, context map[string]interface{}) (<-chan string, error) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		defer streaming.Recover(ctx)
+		ch <- "Hello"
+		panic("stream failure")
+	}()
+	return ch, nil
+}
+
+func (m *panickingModel) Name() string     { return "panicking" }
+func (m *panickingModel) Provider() string { return "test" }
+
+// recordingLogger keeps the messages logged.
+type recordingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func newPanickingBot(t *testing.T) (*Chatbot, *recordingLogger, *[]*PanicError) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	logger := &recordingLogger{}
+	var mutex sync.Mutex
+	var recovered []*PanicError
+	bot, err := New(cfg, WithModel(&panickingModel{}), WithLogger(logger), WithHooks(Hooks{
+		OnPanic: func(err *PanicError) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			recovered = append(recovered, err)
+		},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	return bot, logger, &recovered
+}
+
+func TestRecovery_HandleHTTP(t *testing.T) {
+	bot, logger, recovered := newPanickingBot(t)
+
+	w := httptest.NewRecorder()
+	bot.HandleHTTP(w, httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "Hi"}`)))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+	var response ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Error != "Internal server error" || response.Reference == "" {
+		t.Errorf("unexpected response: %+v", response)
+	}
+
+	if bot.Panics() != 1 || len(*recovered) != 1 || (*recovered)[0].Reference != response.Reference {
+		t.Errorf("expected the panic to be counted and reported, got %d", bot.Panics())
+	}
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], response.Reference) ||
+		!strings.Contains(logger.messages[0], "model failure") || !strings.Contains(logger.messages[0], "goroutine") {
+		t.Errorf("expected the panic and stack to be logged, got %v", logger.messages)
+	}
+}
+
+func TestRecovery_Middleware(t *testing.T) {
+	bot, _, _ := newPanickingBot(t)
+
+	handler := bot.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failure")
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"reference"`) {
+		t.Errorf("expected a 500 with a reference, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A response already begun is left as it is
+	handler = bot.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late failure")
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 || bot.Panics() != 2 {
+		t.Errorf("expected the response to end, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRecovery_StreamGoroutine(t *testing.T) {
+	bot, _, _ := newPanickingBot(t)
+
+	w := httptest.NewRecorder()
+	bot.HandleStreamHTTP(w, httptest.NewRequest("POST", "/chat/stream", strings.NewReader(`{"message": "Hi"}`)))
+	if !strings.Contains(w.Body.String(), "Hello") {
+		t.Errorf("expected the streamed chunk, got %s", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"code":"internal_error"`) {
+		t.Errorf("expected the stream to end with an error chunk, got %s", w.Body.String())
+	}
+	if bot.Panics() != 1 {
+		t.Errorf("expected the stream panic to be recovered, got %d", bot.Panics())
+	}
+}
+
+func TestRecovery_RunAgent(t *testing.T) {
+	model := &scriptedModel{replies: []string{`{"thought":"use it","action":"broken","action_input":{}}`}}
+	broken := tools.New("broken", "Always panics", nil, func(ctx context.Context, args json.RawMessage) (string, error) {
+		panic("tool failure")
+	})
+	bot, err := New(config.Default(), WithModel(model), WithLogger(&recordingLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = bot.RunAgent(ctx, "Do it", tools.NewRegistry(broken))
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "tool failure" {
+		t.Errorf("expected a PanicError, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package gochatbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+// ErrBotExists is returned when adding a chatbot under a name that is
+// already taken in a Registry.
+var ErrBotExists = errors.New("chatbot already registered")
+
+// botName is what a chatbot can be named in a Registry: a path segment.
+var botName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// WithName names the chatbot. The name is the persona of rate limits when
+// the request names none, so a shared limiter can have overrides per
+// chatbot, and the "bot" property of analytics events. Registry.Add sets
+// it.
+func WithName(name string) Option {
+	return func(c *Chatbot) {
+		c.name = name
+	}
+}
+
+// Name returns the chatbot's name, "" if it has none.
+func (c *Chatbot) Name() string {
+	return c.name
+}
+
+// Registry hosts several named chatbots in one process, such as a support
+// bot and a sales bot with their own models, prompts and stores. The
+// options given to NewRegistry are applied to every chatbot it creates,
+// before the chatbot's own, so they share infrastructure such as a rate
+// limiter, analytics exporter, event publisher, logger or hooks.
+//
+// A shared rate limiter counts a client's requests to every chatbot
+// together; give chatbots their own limits with overrides for their
+// names, which are the default persona. Its settings are changed with its
+// UpdateConfig: Reload rejects changes to the rate limits of a chatbot
+// using it, which would change them for every chatbot.
+type Registry struct {
+	shared []Option
+
+	mu   sync.RWMutex
+	bots map[string]*Chatbot
+}
+
+// NewRegistry creates an empty registry whose chatbots are created with
+// shared.
+func NewRegistry(shared ...Option) *Registry {
+	return &Registry{
+		shared: shared,
+		bots:   make(map[string]*Chatbot),
+	}
+}
+
+// Add creates a chatbot from cfg, with the shared options and then opts,
+// and registers it under name. Names are path segments of letters,
+// digits, '.', '_' and '-'.
+func (r *Registry) Add(name string, cfg *config.Config, opts ...Option) (*Chatbot, error) {
+	if err := r.checkName(name); err != nil {
+		return nil, err
+	}
+	options := append(append(append([]Option(nil), r.shared...), opts...), WithName(name))
+	if limiter := r.sharedRateLimit(); limiter != nil {
+		options = append(options, withSharedRateLimit(limiter))
+	}
+	bot, err := New(cfg, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chatbot %q: %w", name, err)
+	}
+	if err := r.Register(name, bot); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// Register adds a chatbot created elsewhere under name, without the
+// shared options. The chatbot is not changed, as it may already be
+// serving requests: create it with WithName(name) for its rate limits and
+// analytics to use the name.
+func (r *Registry) Register(name string, bot *Chatbot) error {
+	if bot == nil {
+		return errors.New("chatbot cannot be nil")
+	}
+	if err := r.checkName(name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bots[name]; ok {
+		return fmt.Errorf("%w: %s", ErrBotExists, name)
+	}
+	r.bots[name] = bot
+	return nil
+}
+
+// sharedRateLimit returns the rate limiter set by the shared options, if
+// any.
+func (r *Registry) sharedRateLimit() middleware.Limiter {
+	probe := &Chatbot{}
+	for _, opt := range r.shared {
+		opt(probe)
+	}
+	return probe.rateLimit
+}
+
+// withSharedRateLimit marks the chatbot's rate limiter as shared with other
+// chatbots if it is limiter, so Reload leaves it alone.
+func withSharedRateLimit(limiter middleware.Limiter) Option {
+	return func(c *Chatbot) {
+		c.sharedRateLimit = c.rateLimit == limiter
+	}
+}
+
+// checkName fails if name cannot name a chatbot or is taken.
+func (r *Registry) checkName(name string) error {
+	if !botName.MatchString(name) {
+		return fmt.Errorf("invalid chatbot name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.bots[name]; ok {
+		return fmt.Errorf("%w: %s", ErrBotExists, name)
+	}
+	return nil
+}
+
+// Get returns the chatbot registered under name.
+func (r *Registry) Get(name string) (*Chatbot, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	bot, ok := r.bots[name]
+	return bot, ok
+}
+
+// Remove unregisters the chatbot under name, reporting whether there was
+// one. Requests it is answering finish.
+func (r *Registry) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.bots[name]
+	delete(r.bots, name)
+	return ok
+}
+
+// Names returns the names of the registered chatbots in order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.bots))
+	for name := range r.bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handler returns a handler serving every registered chatbot under
+// prefix, including those added later:
+//
+//	prefix                  names of the chatbots, as {"bots": [...]} (GET)
+//	prefix/name/chat        JSON chat endpoint of the chatbot (HandleHTTP)
+//	prefix/name/chat/stream streaming chat endpoint (HandleStreamHTTP)
+//	prefix/name/health      health check (HTTPHandler.Health)
+//
+// Mount it with a trailing slash:
+//
+//	http.Handle("/bots/", registry.Handler("/bots/"))
+func (r *Registry) Handler(prefix string) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix != "/" {
+		prefix += "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"{$}", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string][]string{"bots": r.Names()})
+	})
+	mux.HandleFunc(prefix+"{bot}/chat", r.serve(func(bot *Chatbot, w http.ResponseWriter, req *http.Request) {
+		bot.HandleHTTP(w, req)
+	}))
+	mux.HandleFunc(prefix+"{bot}/chat/stream", r.serve(func(bot *Chatbot, w http.ResponseWriter, req *http.Request) {
+		bot.HandleStreamHTTP(w, req)
+	}))
+	mux.HandleFunc(prefix+"{bot}/health", r.serve(func(bot *Chatbot, w http.ResponseWriter, req *http.Request) {
+		NewHTTPHandler(bot).Health(w, req)
+	}))
+	return mux
+}
+
+// serve returns a handler calling handle with the chatbot named in the
+// path, answering 404 if there is none.
+func (r *Registry) serve(handle func(bot *Chatbot, w http.ResponseWriter, req *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		bot, ok := r.Get(req.PathValue("bot"))
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			(&HTTPHandler{}).writeErrorResponse(w, http.StatusNotFound, "Chatbot not found")
+			return
+		}
+		handle(bot, w, req)
+	}
+}
@@ -0,0 +1,161 @@
+package gochatbot
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.rumenx.com/chatbot/analytics"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+func TestRegistry(t *testing.T) {
+	var mu sync.Mutex
+	bots := make(map[string]string)
+	limiter := middleware.NewRateLimiter(config.RateLimitConfig{
+		RequestsPerMinute: 100,
+		Overrides:         []config.RateLimitOverride{{Persona: "sales", RequestsPerMinute: 1}},
+	})
+	registry := NewRegistry(WithRateLimit(limiter), WithHooks(Hooks{OnAnalytics: func(event analytics.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		bots[event.Name], _ = event.Properties["bot"].(string)
+	}}))
+
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	support, sales := &contextModel{}, &contextModel{}
+	if _, err := registry.Add("support", cfg, WithModel(support)); err != nil {
+		t.Fatalf("failed to add chatbot: %v", err)
+	}
+	if _, err := registry.Add("sales", cfg, WithModel(sales)); err != nil {
+		t.Fatalf("failed to add chatbot: %v", err)
+	}
+	if _, err := registry.Add("sales", cfg, WithModel(sales)); !errors.Is(err, ErrBotExists) {
+		t.Errorf("expected ErrBotExists, got %v", err)
+	}
+	if _, err := registry.Add("a/b", cfg); err == nil {
+		t.Error("expected an error for a name that is not a path segment")
+	}
+	if bot, ok := registry.Get("sales"); !ok || bot.Name() != "sales" {
+		t.Errorf("expected the sales chatbot, got %v", bot)
+	}
+
+	handler := registry.Handler("/bots")
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(`{"message":"Hi"}`)))
+		return w
+	}
+
+	if w := post("/bots/support/chat"); w.Code != http.StatusOK || support.context == nil || sales.context != nil {
+		t.Fatalf("expected the support chatbot to answer, got %d: %s", w.Code, w.Body.String())
+	}
+	if bots[analytics.ChatStarted] != "support" {
+		t.Errorf("expected the chatbot's name in the shared analytics, got %q", bots[analytics.ChatStarted])
+	}
+
+	// The shared limiter limits each chatbot by its name
+	if w := post("/bots/sales/chat"); w.Code != http.StatusOK || sales.context == nil {
+		t.Fatalf("expected the sales chatbot to answer, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/bots/sales/chat"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the sales override to apply, got %d", w.Code)
+	}
+	if w := post("/bots/support/chat"); w.Code != http.StatusOK {
+		t.Errorf("expected the support chatbot unaffected, got %d", w.Code)
+	}
+
+	if w := post("/bots/billing/chat"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown chatbot, got %d", w.Code)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/bots/", nil))
+	var listing struct{ Bots []string }
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil || strings.Join(listing.Bots, ",") != "sales,support" {
+		t.Errorf("expected the chatbots listed, got %s", w.Body.String())
+	}
+
+	// Removed chatbots stop being served
+	if !registry.Remove("sales") || registry.Remove("sales") {
+		t.Error("expected the chatbot removed once")
+	}
+	if w := post("/bots/sales/chat"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a removed chatbot, got %d", w.Code)
+	}
+	if names := registry.Names(); len(names) != 1 || names[0] != "support" {
+		t.Errorf("expected only the support chatbot, got %v", names)
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	cfg := config.Default()
+	bot, err := New(cfg, WithModel(&contextModel{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	registry := NewRegistry()
+	if err := registry.Register("support", bot); err != nil {
+		t.Fatalf("failed to register chatbot: %v", err)
+	}
+	// The chatbot may already be serving, so it keeps its own name
+	if got, ok := registry.Get("support"); !ok || got != bot || bot.Name() != "" {
+		t.Errorf("expected the chatbot registered unchanged, got %v named %q", got, bot.Name())
+	}
+}
+
+func TestRegistry_ReloadSharedRateLimit(t *testing.T) {
+	limiter := middleware.NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 1})
+	registry := NewRegistry(WithRateLimit(limiter))
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	cfg.RateLimit = config.RateLimitConfig{RequestsPerMinute: 1}
+	support, err := registry.Add("support", cfg, WithModel(&contextModel{}))
+	if err != nil {
+		t.Fatalf("failed to add chatbot: %v", err)
+	}
+	if _, err := registry.Add("sales", cfg, WithModel(&contextModel{})); err != nil {
+		t.Fatalf("failed to add chatbot: %v", err)
+	}
+	own, err := registry.Add("billing", cfg, WithModel(&contextModel{}),
+		WithRateLimit(middleware.NewRateLimiter(cfg.RateLimit)))
+	if err != nil {
+		t.Fatalf("failed to add chatbot: %v", err)
+	}
+
+	// Reloading one chatbot's rate limits would change every chatbot's
+	raised := *cfg
+	raised.RateLimit = config.RateLimitConfig{RequestsPerMinute: 100}
+	if _, err := support.Reload(&raised); !errors.Is(err, ErrSharedRateLimit) {
+		t.Fatalf("expected ErrSharedRateLimit, got %v", err)
+	}
+	handler := registry.Handler("/bots")
+	post := func(path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(`{"message":"Hi"}`)))
+		return w.Code
+	}
+	if post("/bots/sales/chat") != http.StatusOK || post("/bots/sales/chat") != http.StatusTooManyRequests {
+		t.Error("expected the shared limits unchanged")
+	}
+
+	// Other settings still reload, and so do the limits of an own limiter
+	retoned := *cfg
+	retoned.Tone = "formal"
+	if changes, err := support.Reload(&retoned); err != nil || len(changes) != 1 {
+		t.Errorf("expected the tone to reload, got %v, %v", changes, err)
+	}
+	if _, err := own.Reload(&raised); err != nil {
+		t.Errorf("expected the own limiter to reload, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if code := post("/bots/billing/chat"); code != http.StatusOK {
+			t.Fatalf("expected the raised limit to apply, got %d", code)
+		}
+	}
+}
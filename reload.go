@@ -0,0 +1,109 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+// ErrSharedRateLimit is returned by Reload for a configuration changing the
+// rate limits of a chatbot whose rate limiter a Registry shares with other
+// chatbots. Change the limiter's settings with its UpdateConfig instead.
+var ErrSharedRateLimit = errors.New("rate limits of a shared rate limiter cannot be reloaded per chatbot")
+
+// Reload applies the safe-to-change settings from cfg (prompt, language,
+// tone, generation parameters, feature flags, filter lists, rate limits and
+// maintenance mode) to the running chatbot. Model selection and provider
+// credentials are left untouched. The new settings become visible to
+// subsequent requests at once; requests already in flight finish with the
+// settings they started with. It returns the list of settings that changed.
+// An invalid cfg is rejected with the error and nothing is applied, as is
+// one changing the rate limits of a chatbot whose limiter a Registry shares
+// with other chatbots.
+func (c *Chatbot) Reload(cfg *config.Config) ([]config.Change, error) {
+	if cfg == nil {
+		return nil, errors.New("config cannot be nil")
+	}
+
+	// Check everything that can fail before swapping anything in, so an
+	// invalid file leaves the previous settings in effect
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := middleware.ValidateFilterConfig(cfg.MessageFiltering); err != nil {
+		return nil, err
+	}
+	if _, err := parseMaintenanceMessage(cfg.Maintenance.Message); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	current := c.config
+	if current == nil {
+		current = &config.Config{}
+	}
+	changes := config.Diff(current, cfg)
+	if len(changes) == 0 {
+		c.mutex.Unlock()
+		return nil, nil
+	}
+	if c.sharedRateLimit && changed(changes, "rate_limit") {
+		c.mutex.Unlock()
+		return nil, ErrSharedRateLimit
+	}
+	merged := current.MergeReloadable(cfg)
+	c.config = merged
+	c.mutex.Unlock()
+
+	if c.filter != nil {
+		c.filter.UpdateConfig(merged.MessageFiltering)
+	}
+	if c.rateLimit != nil {
+		c.rateLimit.UpdateConfig(merged.RateLimit)
+	}
+	c.enableLexicon(merged)
+
+	if changed(changes, "maintenance") {
+		// The message was checked above, so this cannot fail
+		_ = c.SetMaintenance(merged.Maintenance)
+	}
+
+	if c.hooks.OnConfigReload != nil {
+		c.hooks.OnConfigReload(changes)
+	}
+
+	return changes, nil
+}
+
+// changed reports whether changes include the setting field.
+func changed(changes []config.Change, field string) bool {
+	for _, change := range changes {
+		if change.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchConfig watches a configuration file and reloads the chatbot whenever
+// the file changes or the process receives SIGHUP. It blocks until the
+// context is cancelled. Failures are reported through Hooks.OnConfigError.
+func (c *Chatbot) WatchConfig(ctx context.Context, path string, interval time.Duration) {
+	watcher := config.NewWatcher(path, interval, func(cfg *config.Config) {
+		if _, err := c.Reload(cfg); err != nil {
+			c.reportConfigError(err)
+		}
+	}).OnError(c.reportConfigError)
+
+	watcher.Start(ctx)
+}
+
+// reportConfigError forwards a configuration error to the hooks.
+func (c *Chatbot) reportConfigError(err error) {
+	if c.hooks.OnConfigError != nil {
+		c.hooks.OnConfigError(err)
+	}
+}
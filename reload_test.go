@@ -0,0 +1,125 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestReloadAppliesSafeSettings(t *testing.T) {
+	var reported []config.Change
+	chatbot, err := New(config.Default(), WithHooks(Hooks{
+		OnConfigReload: func(changes []config.Change) {
+			reported = changes
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	next := config.Default()
+	next.Prompt = "You are a pirate."
+	next.Model = "openai"
+	next.OpenAI.APIKey = "key"
+	next.MessageFiltering.Profanities = []string{"darn"}
+
+	changes, err := chatbot.Reload(next)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(changes) != 2 || len(reported) != 2 {
+		t.Fatalf("Expected 2 changes to be returned and reported, got %v and %v", changes, reported)
+	}
+
+	cfg := chatbot.GetConfig()
+	if cfg.Prompt != "You are a pirate." {
+		t.Errorf("Expected prompt to be reloaded, got %q", cfg.Prompt)
+	}
+	if cfg.Model != "free" {
+		t.Errorf("Expected model to stay unchanged, got %q", cfg.Model)
+	}
+
+	filtered, err := chatbot.filter.Handle(context.Background(), "oh darn")
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if filtered.Message != "oh ***" {
+		t.Errorf("Expected reloaded profanity list to apply, got %q", filtered.Message)
+	}
+}
+
+func TestReloadRejectsInvalidSettings(t *testing.T) {
+	chatbot, err := New(config.Default())
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	next := config.Default()
+	next.Temperature = 5
+
+	if _, err := chatbot.Reload(next); err == nil {
+		t.Error("Expected error for out-of-range temperature")
+	}
+	if chatbot.GetConfig().Temperature != 0.7 {
+		t.Error("Expected previous temperature to remain in effect")
+	}
+
+	// Patterns that do not compile are rejected instead of panicking
+	next = config.Default()
+	next.MessageFiltering.LinkPattern = "https?://("
+	if _, err := chatbot.Reload(next); !errors.Is(err, config.ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern for the link pattern, got %v", err)
+	}
+	next = config.Default()
+	next.MessageFiltering.Profanities = []string{"darn", "[oops"}
+	if _, err := chatbot.Reload(next); !errors.Is(err, config.ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern for the profanity list, got %v", err)
+	}
+	if cfg := chatbot.GetConfig(); len(cfg.MessageFiltering.Profanities) != 0 || cfg.MessageFiltering.LinkPattern != config.Default().MessageFiltering.LinkPattern {
+		t.Errorf("Expected previous filter settings to remain in effect, got %+v", cfg.MessageFiltering)
+	}
+
+	if _, err := chatbot.Reload(nil); err == nil {
+		t.Error("Expected error for nil config")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chatbot.yaml")
+	if err := os.WriteFile(path, []byte("tone: neutral\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan []config.Change, 1)
+	chatbot, err := New(config.Default(), WithHooks(Hooks{
+		OnConfigReload: func(changes []config.Change) {
+			reloaded <- changes
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create chatbot: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go chatbot.WatchConfig(ctx, path, 10*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("tone: playful and upbeat\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case changes := <-reloaded:
+		if len(changes) != 1 || changes[0].Field != "tone" {
+			t.Errorf("Expected a single tone change, got %v", changes)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Chatbot was not reloaded")
+	}
+}
@@ -0,0 +1,564 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	rulePattern      = regexp.MustCompile(`^ {0,3}([-*_])(?:[ \t]*[-*_]){2,}[ \t]*$`)
+	listItemPattern  = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +|$)(.*)$`)
+	quotePattern     = regexp.MustCompile(`^ {0,3}> ?`)
+	fencePattern     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	separatorPattern = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	languagePattern  = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+)
+
+// markdownToHTML renders Markdown. The output only contains tags the
+// renderer generates itself; all input text is escaped.
+func markdownToHTML(markdown string) string {
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+	lines := strings.Split(markdown, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	var b strings.Builder
+	renderBlocks(&b, lines, false)
+	return strings.TrimSpace(b.String())
+}
+
+// expandTabs replaces leading tabs with four spaces so indentation can be
+// measured in spaces.
+func expandTabs(line string) string {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if !strings.Contains(line[:i], "\t") {
+		return line
+	}
+	return strings.ReplaceAll(line[:i], "\t", "    ") + line[i:]
+}
+
+// renderBlocks renders block elements. In tight lists paragraphs are written
+// without <p> tags.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fencePattern.MatchString(line):
+			i = renderFence(b, lines, i)
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+		case rulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case quotePattern.MatchString(line):
+			i = renderQuote(b, lines, i)
+		case isTableStart(lines, i):
+			i = renderTable(b, lines, i)
+		case listItemPattern.MatchString(line) && !isEmptyItem(line):
+			i = renderList(b, lines, i)
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+// startsBlock reports whether line interrupts a paragraph.
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	return fencePattern.MatchString(line) || headingPattern.MatchString(line) ||
+		rulePattern.MatchString(line) || quotePattern.MatchString(line) ||
+		isTableStart(lines, i) || (listItemPattern.MatchString(line) && !isEmptyItem(line))
+}
+
+// isEmptyItem reports whether line is a bare list marker, which is treated as
+// text so a lone "1." or "-" does not start a list.
+func isEmptyItem(line string) bool {
+	m := listItemPattern.FindStringSubmatch(line)
+	return m != nil && strings.TrimSpace(m[4]) == ""
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var parts []string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		if len(parts) > 0 && startsBlock(lines, i) {
+			break
+		}
+		parts = append(parts, renderInline(strings.TrimSpace(lines[i])))
+	}
+
+	text := strings.Join(parts, "<br>\n")
+	if tight {
+		b.WriteString(text + "\n")
+	} else {
+		b.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	indent, fence := len(m[1]), m[2]
+	language := strings.Fields(m[3] + " ")
+	i++
+
+	var code []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t") == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+
+	b.WriteString("<pre><code")
+	if len(language) > 0 && languagePattern.MatchString(language[0]) {
+		b.WriteString(` class="language-` + html.EscapeString(strings.ToLower(language[0])) + `"`)
+	}
+	b.WriteString(">")
+	if len(code) > 0 {
+		b.WriteString(html.EscapeString(strings.Join(code, "\n")) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+		inner = append(inner, quotePattern.ReplaceAllString(lines[i], ""))
+	}
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, inner, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// isTableStart reports whether a GitHub-style table starts at line i: a row
+// with pipes followed by a separator row such as |---|:--:|.
+func isTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.Contains(lines[i], "|") &&
+		strings.Contains(lines[i+1], "|") && separatorPattern.MatchString(lines[i+1])
+}
+
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	header := splitRow(lines[i])
+	var aligns []string
+	for _, cell := range splitRow(lines[i+1]) {
+		switch left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":"); {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	i += 2
+
+	writeRow := func(tag string, cells []string) {
+		b.WriteString("<tr>")
+		for n := range header {
+			cell := ""
+			if n < len(cells) {
+				cell = cells[n]
+			}
+			b.WriteString("<" + tag)
+			if n < len(aligns) && aligns[n] != "" {
+				b.WriteString(` align="` + aligns[n] + `"`)
+			}
+			b.WriteString(">" + renderInline(cell) + "</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	writeRow("th", header)
+	b.WriteString("</thead>\n")
+	body := false
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		if !body {
+			b.WriteString("<tbody>\n")
+			body = true
+		}
+		writeRow("td", splitRow(lines[i]))
+	}
+	if body {
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return i
+}
+
+// splitRow splits a table row on unescaped pipes.
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// listItem is a parsed list item's lines, with its indentation removed.
+type listItem struct {
+	lines []string
+}
+
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first := listItemPattern.FindStringSubmatch(lines[i])
+	baseIndent := len(first[1])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	delimiter := first[2][len(first[2])-1]
+
+	var items []*listItem
+	var item *listItem
+	contentIndent := 0
+	loose := false
+	blank := false
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		m := listItemPattern.FindStringSubmatch(line)
+		if m != nil && !isEmptyItem(line) && (item == nil || indent < contentIndent) && indent <= baseIndent+3 {
+			isOrdered := m[2][0] >= '0' && m[2][0] <= '9'
+			if isOrdered != ordered || m[2][len(m[2])-1] != delimiter {
+				if item == nil || indent <= baseIndent {
+					break
+				}
+				// A different marker indented under the item starts a nested list
+				item.lines = append(item.lines, line[indent:])
+				blank = false
+				continue
+			}
+			if blank && item != nil {
+				loose = true
+			}
+			item = &listItem{lines: []string{m[4]}}
+			items = append(items, item)
+			contentIndent = indent + len(m[2]) + len(m[3])
+			blank = false
+			continue
+		}
+
+		switch {
+		case indent >= contentIndent:
+			if blank {
+				item.lines = append(item.lines, "")
+				if !listItemPattern.MatchString(line[contentIndent:]) {
+					loose = true
+				}
+			}
+			item.lines = append(item.lines, line[contentIndent:])
+		case !blank && !startsBlock(lines, i):
+			// Lazy continuation of the item's paragraph
+			item.lines = append(item.lines, strings.TrimSpace(line))
+		default:
+			return finishList(b, items, ordered, first[2], loose, i)
+		}
+		blank = false
+	}
+	return finishList(b, items, ordered, first[2], loose, i)
+}
+
+func finishList(b *strings.Builder, items []*listItem, ordered bool, marker string, loose bool, i int) int {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered {
+		if start, _ := strconv.Atoi(marker[:len(marker)-1]); start != 1 {
+			b.WriteString(` start="` + strconv.Itoa(start) + `"`)
+		}
+	}
+	b.WriteString(">\n")
+	for _, item := range items {
+		var inner strings.Builder
+		renderBlocks(&inner, item.lines, !loose)
+		b.WriteString("<li>" + strings.TrimSuffix(inner.String(), "\n") + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderInline renders emphasis, code spans, links and images, escaping
+// everything else.
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>\"'", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if n := codeSpan(&b, s, i); n > 0 {
+				i += n
+				continue
+			}
+		case c == '!' && strings.HasPrefix(s[i:], "!["):
+			if text, url, n := parseLink(s[i+1:]); n > 0 {
+				if safe, ok := safeURL(url); ok {
+					b.WriteString(`<img src="` + html.EscapeString(safe) + `" alt="` + html.EscapeString(text) + `">`)
+				} else {
+					b.WriteString(html.EscapeString(text))
+				}
+				i += n + 1
+				continue
+			}
+		case c == '[':
+			if text, url, n := parseLink(s[i:]); n > 0 {
+				if safe, ok := safeURL(url); ok {
+					b.WriteString(`<a href="` + html.EscapeString(safe) + `">` + renderInline(text) + `</a>`)
+				} else {
+					b.WriteString(renderInline(text))
+				}
+				i += n
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				url := s[i+1 : i+end]
+				if isAutolink(url) {
+					text := url
+					if strings.HasPrefix(strings.ToLower(text), "mailto:") {
+						text = text[len("mailto:"):]
+					}
+					b.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(text) + `</a>`)
+					i += end + 1
+					continue
+				}
+			}
+		case c == 'h' && (i == 0 || !isWordByte(s[i-1])) && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")):
+			url := bareURL(s[i:])
+			b.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(url) + `</a>`)
+			i += len(url)
+			continue
+		case c == '*' || c == '_' || c == '~':
+			if n := emphasis(&b, s, i); n > 0 {
+				i += n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// codeSpan renders a code span starting at s[i] and returns its length, or
+// 0 if the backticks are not closed.
+func codeSpan(b *strings.Builder, s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	fence := s[i : i+n]
+	for j := i + n; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			return 0
+		}
+		end := j + k
+		// The closing run must be exactly as long as the opening one
+		if end+n < len(s) && s[end+n] == '`' {
+			for j = end; j < len(s) && s[j] == '`'; j++ {
+			}
+			continue
+		}
+		code := s[i+n : end]
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+			code = code[1 : len(code)-1]
+		}
+		b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return end + n - i
+	}
+	return 0
+}
+
+// emphasis renders **strong**, *em*, __strong__, _em_ or ~~del~~ starting
+// at s[i] and returns its length, or 0 if the delimiter is not closed.
+func emphasis(b *strings.Builder, s string, i int) int {
+	c := s[i]
+	n := 1
+	if i+1 < len(s) && s[i+1] == c {
+		n = 2
+	}
+	if c == '~' && n != 2 {
+		return 0
+	}
+	// Intraword underscores, as in snake_case, are not emphasis
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0
+	}
+	start := i + n
+	if start >= len(s) || s[start] == ' ' {
+		return 0
+	}
+
+	delimiter := s[i : i+n]
+	for j := start; j < len(s); {
+		k := strings.Index(s[j:], delimiter)
+		if k < 0 {
+			return 0
+		}
+		end := j + k
+		closes := end > start && s[end-1] != ' ' &&
+			(n == 2 || end+1 >= len(s) || s[end+1] != c) &&
+			(c != '_' || end+n >= len(s) || !isWordByte(s[end+n]))
+		if !closes {
+			j = end + 1
+			continue
+		}
+
+		tag := "em"
+		switch {
+		case c == '~':
+			tag = "del"
+		case n == 2:
+			tag = "strong"
+		}
+		b.WriteString("<" + tag + ">" + renderInline(s[start:end]) + "</" + tag + ">")
+		return end + n - i
+	}
+	return 0
+}
+
+// parseLink parses [text](url "title") at the start of s and returns the
+// text, the URL and the length consumed, or 0 if s does not start a link.
+func parseLink(s string) (text, url string, n int) {
+	depth := 0
+	closing := -1
+	for i := 0; i < len(s) && closing < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = i
+			}
+		}
+	}
+	if closing < 0 || closing+1 >= len(s) || s[closing+1] != '(' {
+		return "", "", 0
+	}
+
+	// Parentheses inside the URL must be balanced
+	end, depth := -1, 0
+	for i := closing + 2; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				end = i - closing - 2
+			}
+			depth--
+		}
+	}
+	if end < 0 {
+		return "", "", 0
+	}
+	target := strings.TrimSpace(s[closing+2 : closing+2+end])
+	// Drop an optional title
+	if space := strings.IndexAny(target, " \t"); space >= 0 {
+		target = target[:space]
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+	return s[1:closing], target, closing + 3 + end
+}
+
+// safeURL reports whether a link target is allowed: http, https and mailto
+// URLs and relative references. Control characters and whitespace, which
+// browsers ignore inside schemes, are removed first.
+func safeURL(url string) (string, bool) {
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+	if url == "" {
+		return "", false
+	}
+
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return url, true
+	}
+	switch strings.ToLower(url[:colon]) {
+	case "http", "https", "mailto":
+		return url, true
+	}
+	return "", false
+}
+
+func isAutolink(url string) bool {
+	lower := strings.ToLower(url)
+	return (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")) &&
+		!strings.ContainsAny(url, " <>\"")
+}
+
+// bareURL returns the URL at the start of s, without trailing punctuation.
+func bareURL(s string) string {
+	end := strings.IndexAny(s, " \t\n<>\"")
+	if end < 0 {
+		end = len(s)
+	}
+	url := s[:end]
+	for len(url) > 0 && strings.IndexByte(".,;:!?)*_~'", url[len(url)-1]) >= 0 {
+		if url[len(url)-1] == ')' && strings.Count(url, "(") >= strings.Count(url, ")") {
+			break
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,31 @@
+// Package render turns model replies written in Markdown into HTML that is
+// safe to insert into a web page, or into plain text for channels that
+// cannot display HTML.
+//
+//	html := render.HTML(reply) // <p>Use <code>go test</code>:</p><pre><code class="language-sh">...
+//	text := render.Text(reply) // SMS, email subjects, push notifications
+//
+// Raw HTML in the Markdown is escaped, and the generated HTML is passed
+// through an allow-list sanitizer, so a reply cannot inject scripts, event
+// handlers or javascript: links.
+package render
+
+// HTML converts Markdown to sanitized HTML using DefaultPolicy. Fenced code
+// blocks get a "language-*" class for client-side syntax highlighters such
+// as highlight.js or Prism. Single line breaks inside a paragraph become
+// <br> elements, matching how chat messages are usually written.
+func HTML(markdown string) string {
+	return DefaultPolicy.Sanitize(markdownToHTML(markdown))
+}
+
+// Text converts Markdown to plain text. Formatting markers are removed,
+// lists and tables are laid out with spaces and dashes, and link targets are
+// kept in parentheses after the link text.
+func Text(markdown string) string {
+	return htmlToText(markdownToHTML(markdown))
+}
+
+// Sanitize removes everything from html that DefaultPolicy does not allow.
+func Sanitize(html string) string {
+	return DefaultPolicy.Sanitize(html)
+}
@@ -0,0 +1,135 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	nethtml "golang.org/x/net/html"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"paragraph", "Hello **world** and _you_", "<p>Hello <strong>world</strong> and <em>you</em></p>"},
+		{"line breaks", "one\ntwo", "<p>one<br>\ntwo</p>"},
+		{"heading", "## Setup ##", "<h2>Setup</h2>"},
+		{"code span", "Run `go test <pkg>`", "<p>Run <code>go test &lt;pkg&gt;</code></p>"},
+		{"snake case", "use snake_case_names", "<p>use snake_case_names</p>"},
+		{"strikethrough", "~~old~~ new", "<p><del>old</del> new</p>"},
+		{"escape", `\*not emphasis\*`, "<p>*not emphasis*</p>"},
+		{
+			"fenced code",
+			"```Go\nfmt.Println(\"<hi>\")\n```",
+			"<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>",
+		},
+		{"unclosed fence", "```\ncode", "<pre><code>code\n</code></pre>"},
+		{
+			"link",
+			`[docs](https://go.dev/doc "Go docs")`,
+			`<p><a href="https://go.dev/doc" rel="nofollow noopener noreferrer">docs</a></p>`,
+		},
+		{
+			"bare URL",
+			"See https://example.com/a_b.",
+			`<p>See <a href="https://example.com/a_b" rel="nofollow noopener noreferrer">https://example.com/a_b</a>.</p>`,
+		},
+		{
+			"tight list",
+			"- one\n- two\n  - nested",
+			"<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul></li>\n</ul>",
+		},
+		{
+			"loose ordered list",
+			"3. first\n\n4. second",
+			"<ol start=\"3\">\n<li><p>first</p></li>\n<li><p>second</p></li>\n</ol>",
+		},
+		{"quote", "> quoted\n> text", "<blockquote>\n<p>quoted<br>\ntext</p>\n</blockquote>"},
+		{
+			"table",
+			"| Plan | Price |\n|:---|---:|\n| Pro | $10 |",
+			"<table>\n<thead>\n<tr><th align=\"left\">Plan</th><th align=\"right\">Price</th></tr>\n</thead>\n" +
+				"<tbody>\n<tr><td align=\"left\">Pro</td><td align=\"right\">$10</td></tr>\n</tbody>\n</table>",
+		},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.markdown); got != tt.want {
+				t.Errorf("HTML(%q)\n got: %q\nwant: %q", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLBlocksXSS(t *testing.T) {
+	attacks := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"[click](JaVaScRiPt:alert(1))",
+		"[click](java\tscript:alert(1))",
+		"![x](data:text/html;base64,PHNjcmlwdD4=)",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		"[x](https://example.com\" onmouseover=\"alert(1))",
+		"```\"><script>alert(1)</script>\ncode\n```",
+	}
+	for _, attack := range attacks {
+		got := HTML(attack)
+		tokenizer := nethtml.NewTokenizer(strings.NewReader(got))
+		for tokenizer.Next() != nethtml.ErrorToken {
+			token := tokenizer.Token()
+			if token.Data == "script" {
+				t.Errorf("HTML(%q) contains a script element: %q", attack, got)
+			}
+			for _, attr := range token.Attr {
+				value := strings.ToLower(attr.Val)
+				if strings.HasPrefix(attr.Key, "on") || strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "data:") {
+					t.Errorf("HTML(%q) has unsafe attribute %s=%q", attack, attr.Key, attr.Val)
+				}
+			}
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`<p onclick="x()">hi</p>`, `<p>hi</p>`},
+		{`<b>a<script>alert(1)</script>b</b>`, `<b>ab</b>`},
+		{`<div><em>text</em></div>`, `<em>text</em>`},
+		{`<a href="JaVaScRiPt:alert(1)" title="t">x</a>`, `<a title="t" rel="nofollow noopener noreferrer">x</a>`},
+		{`<code class="language-go evil">x</code>`, `<code>x</code>`},
+		{`<p><strong>unclosed`, `<p><strong>unclosed</strong></p>`},
+		{`stray</p> &amp; <`, `stray &amp; &lt;`},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.input); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	strict := &Policy{Elements: map[string][]string{"p": nil}}
+	if got := strict.Sanitize(`<p><a href="https://x">link</a></p>`); got != `<p>link</p>` {
+		t.Errorf("unexpected strict output %q", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	markdown := "# Order status\n\nYour order **#123** has shipped.\nTrack it [here](https://track.example.com/123) or mail <mailto:help@example.com>.\n\n" +
+		"1. Open the app\n2. Tap *Orders*\n   - then *Track*\n\n```\nnpm install\n  --save\n```\n\n| Item | Qty |\n|---|---|\n| Mug | 2 |"
+	want := "Order status\n\nYour order #123 has shipped.\nTrack it here (https://track.example.com/123) or mail help@example.com.\n\n" +
+		"1. Open the app\n2. Tap Orders\n  - then Track\n\nnpm install\n  --save\n\nItem | Qty\nMug | 2"
+	if got := Text(markdown); got != want {
+		t.Errorf("Text()\n got: %q\nwant: %q", got, want)
+	}
+
+	if got := Text("<b>raw</b> & text"); got != "<b>raw</b> & text" {
+		t.Errorf("expected raw HTML to be kept as text, got %q", got)
+	}
+}
@@ -0,0 +1,178 @@
+package render
+
+import (
+	"html"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Policy is an allow-list of HTML elements and attributes. Everything else is
+// removed: disallowed elements are dropped but their text is kept, except for
+// elements such as <script> whose content is dropped too.
+type Policy struct {
+	// Elements maps allowed element names to their allowed attributes.
+	Elements map[string][]string
+	// URLAttributes are attributes holding URLs, which must use one of the
+	// allowed schemes (http, https, mailto) or be relative.
+	URLAttributes []string
+	// LinkRel is set as the rel attribute of links, e.g. "nofollow noopener".
+	LinkRel string
+	// ClassPrefixes restricts class attributes to values with these prefixes.
+	ClassPrefixes []string
+}
+
+// DefaultPolicy allows the elements the Markdown renderer produces.
+var DefaultPolicy = &Policy{
+	Elements: map[string][]string{
+		"p": nil, "br": nil, "hr": nil,
+		"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+		"strong": nil, "b": nil, "em": nil, "i": nil, "del": nil, "s": nil,
+		"sub": nil, "sup": nil,
+		"code": {"class"}, "pre": nil, "blockquote": nil,
+		"ul": nil, "ol": {"start"}, "li": nil,
+		"a":     {"href", "title"},
+		"img":   {"src", "alt", "title"},
+		"table": nil, "thead": nil, "tbody": nil, "tr": nil,
+		"th": {"align"}, "td": {"align"},
+	},
+	URLAttributes: []string{"href", "src"},
+	LinkRel:       "nofollow noopener noreferrer",
+	ClassPrefixes: []string{"language-"},
+}
+
+// droppedContent are elements removed together with their content.
+var droppedContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"template": true, "noscript": true, "textarea": true, "title": true,
+	"svg": true, "math": true, "select": true, "frameset": true, "noembed": true,
+}
+
+// voidElements have no end tag.
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+// Sanitize removes everything from html that the policy does not allow. The
+// output is well formed: unclosed allowed elements are closed and stray end
+// tags are dropped.
+func (p *Policy) Sanitize(input string) string {
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(input))
+	var b strings.Builder
+	var open []string
+	skip := ""
+	skipDepth := 0
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == nethtml.ErrorToken {
+			// io.EOF or malformed input; either way there is nothing more
+			break
+		}
+		token := tokenizer.Token()
+
+		if skip != "" {
+			switch {
+			case tokenType == nethtml.StartTagToken && token.Data == skip:
+				skipDepth++
+			case tokenType == nethtml.EndTagToken && token.Data == skip:
+				skipDepth--
+				if skipDepth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+
+		switch tokenType {
+		case nethtml.TextToken:
+			b.WriteString(html.EscapeString(token.Data))
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if droppedContent[token.Data] && tokenType == nethtml.StartTagToken {
+				skip, skipDepth = token.Data, 1
+				continue
+			}
+			attrs, ok := p.Elements[token.Data]
+			if !ok {
+				continue
+			}
+			b.WriteString("<" + token.Data + p.attributes(token, attrs) + ">")
+			if !voidElements[token.Data] {
+				if tokenType == nethtml.SelfClosingTagToken {
+					b.WriteString("</" + token.Data + ">")
+				} else {
+					open = append(open, token.Data)
+				}
+			}
+		case nethtml.EndTagToken:
+			// Close the element and anything left open inside it
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != token.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+// attributes renders the token's allowed attributes.
+func (p *Policy) attributes(token nethtml.Token, allowed []string) string {
+	var b strings.Builder
+	for _, attr := range token.Attr {
+		if attr.Namespace != "" || !contains(allowed, attr.Key) {
+			continue
+		}
+		value := attr.Val
+		if contains(p.URLAttributes, attr.Key) {
+			safe, ok := safeURL(value)
+			if !ok {
+				continue
+			}
+			value = safe
+		}
+		if attr.Key == "class" && !p.allowedClass(value) {
+			continue
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+	}
+	if token.Data == "a" && p.LinkRel != "" {
+		b.WriteString(` rel="` + html.EscapeString(p.LinkRel) + `"`)
+	}
+	return b.String()
+}
+
+func (p *Policy) allowedClass(value string) bool {
+	if len(p.ClassPrefixes) == 0 {
+		return true
+	}
+	for _, class := range strings.Fields(value) {
+		allowed := false
+		for _, prefix := range p.ClassPrefixes {
+			if strings.HasPrefix(class, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// blockElements start on a new paragraph in plain text.
+var blockElements = map[string]bool{
+	"p": true, "pre": true, "blockquote": true, "table": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// textList tracks a list being written as text.
+type textList struct {
+	ordered bool
+	next    int
+}
+
+// textLink is a link being written as text.
+type textLink struct {
+	href  string
+	start int // offset of the link text
+}
+
+// htmlToText lays out the renderer's HTML as plain text.
+func htmlToText(input string) string {
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(input))
+	var b strings.Builder
+	var lists []*textList
+	var links []textLink
+	pre := 0
+	firstCell := false
+
+	paragraph := func() {
+		text := strings.TrimRight(b.String(), " ")
+		if text != "" && !strings.HasSuffix(text, "\n\n") {
+			b.Reset()
+			b.WriteString(strings.TrimRight(text, "\n") + "\n\n")
+		}
+	}
+	newline := func() {
+		text := strings.TrimRight(b.String(), " ")
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			b.Reset()
+			b.WriteString(text + "\n")
+		}
+	}
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == nethtml.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case nethtml.TextToken:
+			text := token.Data
+			if pre == 0 {
+				text = strings.Join(strings.Fields(text), " ")
+				if text == "" {
+					continue
+				}
+				if strings.HasSuffix(b.String(), "\n") || b.Len() == 0 {
+					text = strings.TrimLeft(text, " ")
+				} else if strings.HasPrefix(token.Data, " ") || strings.HasPrefix(token.Data, "\n") {
+					text = " " + text
+				}
+				if strings.HasSuffix(token.Data, " ") {
+					text += " "
+				}
+			}
+			b.WriteString(text)
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			switch name := token.Data; {
+			case blockElements[name]:
+				paragraph()
+				if name == "pre" {
+					pre++
+				}
+				if name == "hr" {
+					b.WriteString("---\n\n")
+				}
+			case name == "ul" || name == "ol":
+				if len(lists) == 0 {
+					paragraph()
+				}
+				list := &textList{ordered: name == "ol", next: 1}
+				if start, err := strconv.Atoi(attr(token, "start")); err == nil {
+					list.next = start
+				}
+				lists = append(lists, list)
+			case name == "li":
+				newline()
+				if len(lists) == 0 {
+					b.WriteString("- ")
+					continue
+				}
+				list := lists[len(lists)-1]
+				b.WriteString(strings.Repeat("  ", len(lists)-1))
+				if list.ordered {
+					b.WriteString(strconv.Itoa(list.next) + ". ")
+					list.next++
+				} else {
+					b.WriteString("- ")
+				}
+			case name == "tr":
+				newline()
+				firstCell = true
+			case name == "th" || name == "td":
+				if !firstCell {
+					b.WriteString(" | ")
+				}
+				firstCell = false
+			case name == "br":
+				newline()
+			case name == "img":
+				b.WriteString(attr(token, "alt"))
+			case name == "a":
+				links = append(links, textLink{href: attr(token, "href"), start: b.Len()})
+			}
+
+		case nethtml.EndTagToken:
+			switch name := token.Data; {
+			case blockElements[name]:
+				if name == "pre" && pre > 0 {
+					pre--
+				}
+				paragraph()
+			case name == "ul" || name == "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				if len(lists) == 0 {
+					paragraph()
+				}
+			case name == "a" && len(links) > 0:
+				link := links[len(links)-1]
+				links = links[:len(links)-1]
+
+				text := ""
+				if link.start <= b.Len() {
+					text = b.String()[link.start:]
+				}
+				target := strings.TrimPrefix(link.href, "mailto:")
+				if link.href != "" && strings.TrimSpace(text) != target {
+					b.WriteString(" (" + target + ")")
+				}
+			}
+		}
+	}
+
+	text := blankLines.ReplaceAllString(b.String(), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+func attr(token nethtml.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
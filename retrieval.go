@@ -0,0 +1,396 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.rumenx.com/chatbot/budget"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// Retriever finds knowledge relevant to a message.
+type Retriever interface {
+	// Retrieve returns up to limit passages, most relevant first.
+	Retrieve(ctx context.Context, query string, limit int) ([]string, error)
+}
+
+// RetrieverFunc adapts a function to the Retriever interface.
+type RetrieverFunc func(ctx context.Context, query string, limit int) ([]string, error)
+
+// Retrieve implements Retriever.
+func (f RetrieverFunc) Retrieve(ctx context.Context, query string, limit int) ([]string, error) {
+	return f(ctx, query, limit)
+}
+
+// Passage is a retrieved piece of knowledge with where it came from.
+type Passage struct {
+	// ID identifies the chunk in the knowledge base, and Source the
+	// document it was taken from.
+	ID     string
+	Source string
+	Text   string
+	// Score is the retriever's relevance score, like the similarity.
+	Score float64
+	// Stale is set when the passage may no longer match its source, like
+	// the chunks knowledge.Refresher could not recheck.
+	Stale bool
+}
+
+// PassageRetriever is implemented by retrievers that report the ID and
+// score of each passage, which are then recorded with the reply. Retrievers
+// implementing only Retriever are recorded by rank.
+type PassageRetriever interface {
+	RetrievePassages(ctx context.Context, query string, limit int) ([]Passage, error)
+}
+
+// KnowledgeRetriever retrieves from a knowledge index built with
+// "chatbot ingest" or kept up to date by knowledge.Refresher, using the
+// "text" metadata of each result. Passages are identified by their "id"
+// metadata, or their position in the index.
+func KnowledgeRetriever(store *embeddings.VectorStore, opts ...KnowledgeOption) Retriever {
+	return knowledgeRetriever{store: store, options: newKnowledgeOptions(opts)}
+}
+
+// KnowledgeOption configures KnowledgeRetriever and CollectionRetriever.
+type KnowledgeOption func(*knowledgeOptions)
+
+type knowledgeOptions struct {
+	mmr    bool
+	lambda float64
+}
+
+func newKnowledgeOptions(opts []KnowledgeOption) knowledgeOptions {
+	var o knowledgeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMMR selects passages by maximal marginal relevance, trading some
+// relevance for diversity so the prompt is not filled with near-duplicate
+// chunks, which helps broad questions. lambda weighs relevance against
+// diversity, from 1 (relevance only) to 0; embeddings.DefaultMMRLambda
+// balances them evenly. See embeddings.VectorStore.SearchMMR.
+func WithMMR(lambda float64) KnowledgeOption {
+	return func(o *knowledgeOptions) {
+		o.mmr = true
+		o.lambda = lambda
+	}
+}
+
+type knowledgeRetriever struct {
+	store   *embeddings.VectorStore
+	options knowledgeOptions
+}
+
+// Retrieve implements Retriever.
+func (r knowledgeRetriever) Retrieve(ctx context.Context, query string, limit int) ([]string, error) {
+	passages, err := r.RetrievePassages(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(passages))
+	for i, passage := range passages {
+		texts[i] = passage.Text
+	}
+	return texts, nil
+}
+
+// Version implements VersionedRetriever.
+func (r knowledgeRetriever) Version(ctx context.Context) string {
+	return strconv.FormatUint(r.store.Version(), 10)
+}
+
+// RetrievePassages implements PassageRetriever.
+func (r knowledgeRetriever) RetrievePassages(ctx context.Context, query string, limit int) ([]Passage, error) {
+	var results []embeddings.SearchResult
+	var err error
+	if r.options.mmr {
+		results, err = r.store.SearchMMR(ctx, query, limit, r.options.lambda)
+	} else {
+		results, err = r.store.Search(ctx, query, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	passages := make([]Passage, 0, len(results))
+	for _, result := range results {
+		text, _ := result.Metadata["text"].(string)
+		if text == "" {
+			continue
+		}
+		id, _ := result.Metadata["id"].(string)
+		if id == "" {
+			id = strconv.Itoa(result.Index)
+		}
+		source, _ := result.Metadata["source"].(string)
+		stale, _ := result.Metadata["stale"].(bool)
+		passages = append(passages, Passage{ID: id, Source: source, Text: text, Score: result.Similarity, Stale: stale})
+	}
+	return passages, nil
+}
+
+// CollectionRetriever retrieves from the collection named by the request,
+// so each tenant or product searches only its own knowledge base. The
+// collection is the one set with WithCollection, or with
+// embeddings.WithCollection on the request's context, for example by
+// middleware that knows the tenant; requests naming none use fallback,
+// and with an empty fallback retrieve nothing. Collections that do not
+// exist or are empty yield no passages, never another collection's.
+func CollectionRetriever(collections *embeddings.Collections, fallback string, opts ...KnowledgeOption) Retriever {
+	return collectionRetriever{collections: collections, fallback: fallback, options: newKnowledgeOptions(opts)}
+}
+
+type collectionRetriever struct {
+	collections *embeddings.Collections
+	fallback    string
+	options     knowledgeOptions
+}
+
+// Retrieve implements Retriever.
+func (r collectionRetriever) Retrieve(ctx context.Context, query string, limit int) ([]string, error) {
+	store := r.store(ctx)
+	if store == nil {
+		return nil, nil
+	}
+	return knowledgeRetriever{store: store, options: r.options}.Retrieve(ctx, query, limit)
+}
+
+// RetrievePassages implements PassageRetriever.
+func (r collectionRetriever) RetrievePassages(ctx context.Context, query string, limit int) ([]Passage, error) {
+	store := r.store(ctx)
+	if store == nil {
+		return nil, nil
+	}
+	return knowledgeRetriever{store: store, options: r.options}.RetrievePassages(ctx, query, limit)
+}
+
+// Version implements VersionedRetriever.
+func (r collectionRetriever) Version(ctx context.Context) string {
+	name := r.name(ctx)
+	store, err := r.collections.Get(name)
+	if err != nil {
+		return name
+	}
+	return name + "@" + strconv.FormatUint(store.Version(), 10)
+}
+
+// store returns the non-empty store of the request's collection, or nil.
+func (r collectionRetriever) store(ctx context.Context) *embeddings.VectorStore {
+	name := r.name(ctx)
+	if name == "" {
+		return nil
+	}
+	store, err := r.collections.Get(name)
+	if err != nil || store.Count() == 0 {
+		return nil
+	}
+	return store
+}
+
+// name returns the request's collection.
+func (r collectionRetriever) name(ctx context.Context) string {
+	if name := embeddings.CollectionFromContext(ctx); name != "" {
+		return name
+	}
+	return r.fallback
+}
+
+// WithCollection sets the knowledge collection a single request retrieves
+// from with CollectionRetriever. Set it from trusted code, like the
+// tenant of the authenticated user, never from the request body.
+func WithCollection(name string) AskOption {
+	return WithContext(collectionKey, name)
+}
+
+// collectionKey holds the request's collection in its context.
+const collectionKey = "collection"
+
+// WithRetriever adds up to depth passages relevant to each message to the
+// system prompt. With WithBudget, fewer passages are retrieved when the
+// request is running late, and a retrieval that runs out of time is skipped.
+func WithRetriever(retriever Retriever, depth int) Option {
+	return func(c *Chatbot) {
+		c.retriever = retriever
+		c.depth = depth
+	}
+}
+
+// retrieve extends the request's system prompt with relevant passages, and
+// keeps them in the request context to be recorded with the reply. With
+// WithContextWindow, the passages and history are fitted into the window.
+func (c *Chatbot) retrieve(ctx context.Context, b *budget.Budget, message string, askContext map[string]interface{}) error {
+	passages, err := c.findPassages(ctx, b, message, askContext)
+	if err != nil {
+		return err
+	}
+	passages = c.pack(message, passages, askContext)
+	if len(passages) == 0 {
+		return nil
+	}
+	askContext[retrievalKey] = &retrieval{query: message, passages: passages}
+
+	var prompt strings.Builder
+	if existing, _ := askContext["prompt"].(string); existing != "" {
+		prompt.WriteString(existing)
+		prompt.WriteString("\n\n")
+	}
+	prompt.WriteString("Use the following context when it is relevant:\n")
+	for _, passage := range passages {
+		prompt.WriteString("\n")
+		prompt.WriteString(passage.Text)
+		prompt.WriteString("\n")
+	}
+	askContext["prompt"] = prompt.String()
+	return nil
+}
+
+// findPassages returns the passages relevant to message, none without a
+// retriever.
+func (c *Chatbot) findPassages(ctx context.Context, b *budget.Budget, message string, askContext map[string]interface{}) ([]Passage, error) {
+	if c.retriever == nil {
+		return nil, nil
+	}
+
+	depth := c.depth
+	if b != nil {
+		depth = b.Depth(budget.StageRetrieval, depth)
+	}
+	if collection, _ := askContext[collectionKey].(string); collection != "" {
+		ctx = embeddings.WithCollection(ctx, collection)
+	}
+	stageCtx, done := stage(ctx, b, budget.StageRetrieval)
+	passages, err := retrievePassages(stageCtx, c.retriever, message, depth)
+	done()
+	if err != nil {
+		// Answer without knowledge rather than miss the overall deadline
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("retrieval failed: %w", err)
+	}
+	return passages, nil
+}
+
+// retrievePassages retrieves with retriever, numbering the passages of
+// retrievers that do not identify them by rank.
+func retrievePassages(ctx context.Context, retriever Retriever, query string, limit int) ([]Passage, error) {
+	if r, ok := retriever.(PassageRetriever); ok {
+		return r.RetrievePassages(ctx, query, limit)
+	}
+	texts, err := retriever.Retrieve(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	passages := make([]Passage, len(texts))
+	for i, text := range texts {
+		passages[i] = Passage{ID: strconv.Itoa(i + 1), Text: text}
+	}
+	return passages, nil
+}
+
+// retrievalKey holds a request's retrieved passages in its context.
+const retrievalKey = "retrieval"
+
+// citationWords is how many consecutive words of a passage a reply must
+// repeat for the passage to count as cited.
+const citationWords = 6
+
+// RetrievedChunk records a passage retrieved for a reply. Replies store
+// them in their message metadata under "retrieved_chunks".
+type RetrievedChunk struct {
+	ID     string  `json:"id"`
+	Source string  `json:"source,omitempty"`
+	Score  float64 `json:"score"`
+	// Rank is the passage's position in the results, from 1.
+	Rank int `json:"rank"`
+	// Cited reports whether the reply names the passage's source or
+	// repeats a run of its words, an estimate of whether the answer
+	// drew on it.
+	Cited bool `json:"cited"`
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Retrieval is what was retrieved for a reply, passed to Hooks.OnRetrieval.
+type Retrieval struct {
+	ConversationID string
+	UserID         string
+	// Query is the message as it was sent to the retriever.
+	Query  string
+	Reply  string
+	Chunks []RetrievedChunk
+}
+
+// retrieval is the passages retrieved for a request.
+type retrieval struct {
+	query    string
+	passages []Passage
+	chunks   []RetrievedChunk // set once the reply is known
+}
+
+// retrievedChunks records the request's retrieved passages against its
+// reply, calling the retrieval hook. It returns nil if nothing was
+// retrieved.
+func (c *Chatbot) retrievedChunks(reply string, askContext map[string]interface{}) []RetrievedChunk {
+	r, _ := askContext[retrievalKey].(*retrieval)
+	if r == nil {
+		return nil
+	}
+	replyWords := " " + strings.Join(words(reply), " ") + " "
+	chunks := make([]RetrievedChunk, len(r.passages))
+	for i, passage := range r.passages {
+		chunks[i] = RetrievedChunk{
+			ID:     passage.ID,
+			Source: passage.Source,
+			Score:  passage.Score,
+			Rank:   i + 1,
+			Cited:  cited(passage, reply, replyWords),
+			Stale:  passage.Stale,
+		}
+	}
+	r.chunks = chunks
+
+	if c.hooks.OnRetrieval != nil {
+		record := Retrieval{Query: r.query, Reply: reply, Chunks: chunks}
+		record.ConversationID, _ = askContext["conversation_id"].(string)
+		record.UserID, _ = askContext["user_id"].(string)
+		c.hooks.OnRetrieval(record)
+	}
+	return chunks
+}
+
+// retrievalMetadata returns the retrieved chunks recorded for the
+// request's reply, or nil.
+func retrievalMetadata(askContext map[string]interface{}) []RetrievedChunk {
+	if r, _ := askContext[retrievalKey].(*retrieval); r != nil {
+		return r.chunks
+	}
+	return nil
+}
+
+// cited reports whether reply, whose words are replyWords, names the
+// passage's source or repeats citationWords of its words in a row.
+func cited(passage Passage, reply, replyWords string) bool {
+	if passage.Source != "" && strings.Contains(reply, passage.Source) {
+		return true
+	}
+	passageWords := words(passage.Text)
+	for i := 0; i+citationWords <= len(passageWords); i++ {
+		if strings.Contains(replyWords, " "+strings.Join(passageWords[i:i+citationWords], " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// words returns the lowercase words of text, without punctuation.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,167 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/embeddings"
+)
+
+// passageRetriever returns fixed passages.
+type passageRetriever []Passage
+
+func (r passageRetriever) Retrieve(ctx context.Context, query string, limit int) ([]string, error) {
+	return nil, nil
+}
+
+func (r passageRetriever) RetrievePassages(ctx context.Context, query string, limit int) ([]Passage, error) {
+	return r, nil
+}
+
+func TestRetrievalRecorded(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &scriptedModel{replies: []string{"Our store opens at nine in the morning on weekdays, see hours.md."}}
+	conversations := &metadataConversations{fakeConversations: newFakeConversations()}
+	retriever := passageRetriever{
+		{ID: "hours-1", Source: "docs/hours.md", Text: "The store opens at nine in the morning on weekdays.", Score: 0.91},
+		{ID: "returns-3", Source: "docs/returns.md", Text: "Returns are accepted within thirty days.", Score: 0.52},
+		{ID: "hours-2", Source: "hours.md", Text: "Closed on holidays.", Score: 0.5},
+	}
+	var hooked []Retrieval
+	bot, err := New(cfg, WithModel(model), WithRetriever(retriever, 3), WithConversations(conversations, 0),
+		WithHooks(Hooks{OnRetrieval: func(retrieval Retrieval) {
+			hooked = append(hooked, retrieval)
+		}}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "When do you open?", WithContext("conversation_id", "c1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hooked) != 1 || hooked[0].ConversationID != "c1" || hooked[0].Query != "When do you open?" {
+		t.Fatalf("unexpected retrieval records: %+v", hooked)
+	}
+	want := []RetrievedChunk{
+		{ID: "hours-1", Source: "docs/hours.md", Score: 0.91, Rank: 1, Cited: true},
+		{ID: "returns-3", Source: "docs/returns.md", Score: 0.52, Rank: 2},
+		{ID: "hours-2", Source: "hours.md", Score: 0.5, Rank: 3, Cited: true},
+	}
+	for i, chunk := range hooked[0].Chunks {
+		if chunk != want[i] {
+			t.Errorf("chunk %d: expected %+v, got %+v", i, want[i], chunk)
+		}
+	}
+
+	replyMetadata := conversations.metadata[1]
+	if chunks, _ := replyMetadata["retrieved_chunks"].([]RetrievedChunk); len(chunks) != 3 || chunks[0].ID != "hours-1" {
+		t.Errorf("expected the chunks in the reply metadata, got %v", replyMetadata)
+	}
+}
+
+func TestRetrievalRankedForPlainRetrievers(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	retriever := RetrieverFunc(func(ctx context.Context, query string, n int) ([]string, error) {
+		return []string{"We open at nine.", "We close at five."}, nil
+	})
+	var chunks []RetrievedChunk
+	bot, err := New(cfg, WithModel(&echoModel{}), WithRetriever(retriever, 2),
+		WithHooks(Hooks{OnRetrieval: func(retrieval Retrieval) { chunks = retrieval.Chunks }}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	if _, err := bot.Ask(context.Background(), "Hours?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].ID != "1" || chunks[1].Rank != 2 || chunks[0].Cited {
+		t.Errorf("unexpected chunks: %+v", chunks)
+	}
+}
+
+func TestKnowledgeRetrieverPassages(t *testing.T) {
+	store := embeddings.NewVectorStore(refundEmbeddings{})
+	store.SetThreshold(-1)
+	err := store.AddVectors([]embeddings.Vector{{0, 1}, {1, 0}}, []map[string]interface{}{
+		{"source": "refunds.md", "text": "Refunds take five days.", "id": "refunds"},
+		{"source": "hours.md", "text": "We open at nine.", "stale": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	passages, err := KnowledgeRetriever(store).(PassageRetriever).RetrievePassages(context.Background(), "refund please", 2)
+	if err != nil || len(passages) != 2 {
+		t.Fatalf("unexpected passages %+v, %v", passages, err)
+	}
+	if passages[0].ID != "refunds" || passages[0].Source != "refunds.md" || passages[0].Score < 0.99 || passages[1].ID != "1" ||
+		passages[0].Stale || !passages[1].Stale {
+		t.Errorf("unexpected passages: %+v", passages)
+	}
+}
+
+func TestCollectionRetriever(t *testing.T) {
+	collections := embeddings.NewCollections(refundEmbeddings{})
+	for name, text := range map[string]string{"acme": "Acme refunds take five days.", "globex": "Globex refunds take a month."} {
+		store, err := collections.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.AddVectors([]embeddings.Vector{{0, 1}}, []map[string]interface{}{{"text": text}})
+	}
+	collections.Create("empty")
+
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	bot, err := New(cfg, WithModel(model), WithRetriever(CollectionRetriever(collections, "acme"), 3))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	prompt := func() string {
+		p, _ := model.context["prompt"].(string)
+		return p
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		ctx     context.Context
+		options []AskOption
+		want    string
+	}{
+		{ctx, nil, "Acme refunds"},
+		{ctx, []AskOption{WithCollection("globex")}, "Globex refunds"},
+		{embeddings.WithCollection(ctx, "globex"), nil, "Globex refunds"},
+		{ctx, []AskOption{WithCollection("empty")}, ""},
+		{ctx, []AskOption{WithCollection("missing")}, ""},
+	}
+	for i, tt := range tests {
+		if _, err := bot.Ask(tt.ctx, "How do refunds work?", tt.options...); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if got := prompt(); (tt.want == "" && strings.Contains(got, "refunds take")) || !strings.Contains(got, tt.want) {
+			t.Errorf("%d: expected %q in the prompt, got %q", i, tt.want, got)
+		}
+	}
+}
+
+func TestKnowledgeRetrieverMMR(t *testing.T) {
+	store := embeddings.NewVectorStore(refundEmbeddings{})
+	store.SetThreshold(0)
+	store.AddVectors([]embeddings.Vector{{0.5, 1}, {0.52, 1}, {-0.6, 1}}, []map[string]interface{}{
+		{"text": "Refunds take five days."},
+		{"text": "Refunds take five business days."},
+		{"text": "Refunds go to the original card."},
+	})
+
+	plain, err := KnowledgeRetriever(store).Retrieve(context.Background(), "refunds?", 2)
+	if err != nil || len(plain) != 2 || plain[1] != "Refunds take five business days." {
+		t.Fatalf("unexpected passages %q, %v", plain, err)
+	}
+	diverse, err := KnowledgeRetriever(store, WithMMR(embeddings.DefaultMMRLambda)).Retrieve(context.Background(), "refunds?", 2)
+	if err != nil || len(diverse) != 2 || diverse[1] != "Refunds go to the original card." {
+		t.Errorf("expected MMR to pick the diverse passage, got %q, %v", diverse, err)
+	}
+}
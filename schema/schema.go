@@ -0,0 +1,320 @@
+// Package schema validates model output against JSON Schemas, so
+// structured replies and tool arguments can be checked and, when invalid,
+// repaired by sending the problems back to the model.
+//
+// It supports the part of JSON Schema used to describe model output: type,
+// properties, required, additionalProperties, items, enum, const, numeric
+// and length bounds, pattern, and allOf, anyOf and oneOf. Other keywords,
+// such as description and format, are ignored.
+//
+//	err := schema.ValidateJSON(tool.Parameters(), args)
+//	var invalid *schema.ValidationError
+//	if errors.As(err, &invalid) {
+//		log.Println(invalid.Problems)
+//	}
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalid is matched by the errors of values not matching their schema.
+var ErrInvalid = errors.New("does not match the schema")
+
+// ValidationError lists where a value does not match its schema.
+type ValidationError struct {
+	// Problems describe each mismatch with its JSON path, such as
+	// "$.city: is required".
+	Problems []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return ErrInvalid.Error() + ": " + strings.Join(e.Problems, "; ")
+}
+
+// Unwrap returns ErrInvalid.
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalid
+}
+
+// Validate checks a decoded JSON value against schema. It returns a
+// *ValidationError listing every mismatch, or nil.
+func Validate(schema map[string]interface{}, value interface{}) error {
+	var problems []string
+	validate(schema, value, "$", &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ValidateJSON decodes data and checks it against schema. Data that is not
+// JSON is reported as a *ValidationError too.
+func ValidateJSON(schema map[string]interface{}, data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &ValidationError{Problems: []string{"$: invalid JSON: " + err.Error()}}
+	}
+	return Validate(schema, value)
+}
+
+// ExtractJSON returns the outermost JSON object or array in a model reply,
+// which models often wrap in prose or code fences. It returns the reply
+// trimmed if it has none.
+func ExtractJSON(reply string) string {
+	start := strings.IndexAny(reply, "{[")
+	if start < 0 {
+		return strings.TrimSpace(reply)
+	}
+	closing := "}"
+	if reply[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(reply, closing)
+	if end < start {
+		return strings.TrimSpace(reply)
+	}
+	return reply[start : end+1]
+}
+
+// validate adds the mismatches of value at path to problems.
+func validate(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	add := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if types := stringList(schema["type"]); len(types) > 0 && !hasType(value, types) {
+		add("must be %s, not %s", strings.Join(types, " or "), typeOf(value))
+		return
+	}
+	if enum, ok := schema["enum"]; ok && !contains(list(enum), value) {
+		encoded, _ := json.Marshal(enum)
+		add("must be one of %s", encoded)
+	}
+	if constant, ok := schema["const"]; ok && !equal(constant, value) {
+		encoded, _ := json.Marshal(constant)
+		add("must be %s", encoded)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, problems)
+	case []interface{}:
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			add("must have at least %v items", n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			add("must have at most %v items", n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(schema["minLength"]); ok && length < n {
+			add("must be at least %v characters long", n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && length > n {
+			add("must be at most %v characters long", n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				add("must match %q", pattern)
+			}
+		}
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && v < n {
+			add("must be at least %v", n)
+		}
+		if n, ok := number(schema["maximum"]); ok && v > n {
+			add("must be at most %v", n)
+		}
+		if n, ok := number(schema["exclusiveMinimum"]); ok && v <= n {
+			add("must be greater than %v", n)
+		}
+		if n, ok := number(schema["exclusiveMaximum"]); ok && v >= n {
+			add("must be less than %v", n)
+		}
+	}
+
+	for _, sub := range schemaList(schema["allOf"]) {
+		validate(sub, value, path, problems)
+	}
+	if anyOf := schemaList(schema["anyOf"]); len(anyOf) > 0 && matching(anyOf, value) == 0 {
+		add("must match at least one of the anyOf schemas")
+	}
+	if oneOf := schemaList(schema["oneOf"]); len(oneOf) > 0 && matching(oneOf, value) != 1 {
+		add("must match exactly one of the oneOf schemas")
+	}
+}
+
+// validateObject adds the mismatches of an object's properties.
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string, problems *[]string) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := object[name]; !ok {
+			*problems = append(*problems, path+"."+name+": is required")
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			validate(property, object[name], path+"."+name, problems)
+			continue
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*problems = append(*problems, path+"."+name+": is not allowed")
+			}
+		case map[string]interface{}:
+			validate(additional, object[name], path+"."+name, problems)
+		}
+	}
+}
+
+// matching returns how many of schemas value matches.
+func matching(schemas []map[string]interface{}, value interface{}) int {
+	count := 0
+	for _, sub := range schemas {
+		var problems []string
+		validate(sub, value, "$", &problems)
+		if len(problems) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// hasType reports whether value is of one of the JSON types.
+func hasType(value interface{}, types []string) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON type of a decoded value; numbers without a
+// fraction are integers.
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// contains reports whether values holds value.
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares a schema value, which may be written in Go, with a
+// decoded JSON value.
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
+
+// number reads a schema or JSON number.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// list reads a schema list, written in Go or decoded from JSON.
+func list(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case []interface{}:
+		return l
+	case []string:
+		values := make([]interface{}, len(l))
+		for i, s := range l {
+			values[i] = s
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// stringList reads a string or list of strings, like the type keyword.
+func stringList(v interface{}) []string {
+	if s, ok := v.(string); ok {
+		return []string{s}
+	}
+	var strs []string
+	for _, item := range list(v) {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// schemaList reads a list of schemas, like the anyOf keyword.
+func schemaList(v interface{}) []map[string]interface{} {
+	if schemas, ok := v.([]map[string]interface{}); ok {
+		return schemas
+	}
+	var schemas []map[string]interface{}
+	for _, item := range list(v) {
+		if s, ok := item.(map[string]interface{}); ok {
+			schemas = append(schemas, s)
+		}
+	}
+	return schemas
+}
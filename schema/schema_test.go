@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	weather := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"city":  map[string]interface{}{"type": "string", "minLength": 2},
+			"days":  map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 7},
+			"units": map[string]interface{}{"enum": []string{"metric", "imperial"}},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": 2},
+		},
+		"required":             []string{"city"},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		data     string
+		problems []string
+	}{
+		{`{"city":"Sofia","days":3,"units":"metric","tags":["a"]}`, nil},
+		{`{"days":3.5}`, []string{"$.city: is required", "$.days: must be integer, not number"}},
+		{`{"city":"S","days":9,"units":"kelvin"}`, []string{
+			"$.city: must be at least 2 characters long",
+			"$.days: must be at most 7",
+			`$.units: must be one of ["metric","imperial"]`,
+		}},
+		{`{"city":"Sofia","tags":["a",1,"c"],"extra":true}`, []string{
+			"$.extra: is not allowed",
+			"$.tags: must have at most 2 items",
+			"$.tags[1]: must be string, not integer",
+		}},
+		{`["Sofia"]`, []string{"$: must be object, not array"}},
+	}
+	for _, tt := range tests {
+		err := ValidateJSON(weather, []byte(tt.data))
+		var invalid *ValidationError
+		switch {
+		case tt.problems == nil:
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.data, err)
+			}
+		case !errors.As(err, &invalid) || !reflect.DeepEqual(invalid.Problems, tt.problems):
+			t.Errorf("%s: expected problems %q, got %v", tt.data, tt.problems, err)
+		}
+	}
+
+	if err := ValidateJSON(weather, []byte(`{"city":`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected invalid JSON to be reported, got %v", err)
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	var s map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"oneOf": [{"type": "string", "pattern": "^[0-9]+$"}, {"type": "integer"}],
+		"anyOf": [{"const": "42"}, {"exclusiveMinimum": 0}]
+	}`), &s)
+
+	for value, valid := range map[interface{}]bool{"42": true, 7.0: true, "abc": false, -1.0: false, 1.5: false} {
+		if err := Validate(s, value); (err == nil) != valid {
+			t.Errorf("%v: expected valid=%v, got %v", value, valid, err)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"a":1}`: `{"a":1}`,
+		"Here you go:\n```json\n{\"a\":{\"b\":2}}\n```": `{"a":{"b":2}}`,
+		"The list: [1, 2] as asked.":                    `[1, 2]`,
+		" no json ":                                     "no json",
+	}
+	for reply, want := range tests {
+		if got := ExtractJSON(reply); got != want {
+			t.Errorf("ExtractJSON(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	var counter Counter
+	counter.Record(0, true)
+	counter.Record(2, true)
+	counter.Record(1, false)
+	counter.Record(0, true)
+
+	stats := counter.Stats()
+	if stats != (Stats{Outputs: 4, Repairs: 3, Repaired: 1, Failed: 1}) || stats.RepairRate() != 0.5 {
+		t.Errorf("unexpected stats %+v, rate %v", stats, stats.RepairRate())
+	}
+	if (Stats{}).RepairRate() != 0 {
+		t.Error("expected no rate without outputs")
+	}
+}
@@ -0,0 +1,52 @@
+package schema
+
+import "sync/atomic"
+
+// Stats counts validated model outputs and their repairs.
+type Stats struct {
+	// Outputs is how many outputs were validated, and Repairs how many
+	// times the model was asked to fix one.
+	Outputs uint64 `json:"outputs"`
+	Repairs uint64 `json:"repairs"`
+	// Repaired counts outputs that were valid after one or more repairs,
+	// and Failed those still invalid when the repairs ran out.
+	Repaired uint64 `json:"repaired"`
+	Failed   uint64 `json:"failed"`
+}
+
+// RepairRate returns the share of outputs that were invalid at first.
+func (s Stats) RepairRate() float64 {
+	if s.Outputs == 0 {
+		return 0
+	}
+	return float64(s.Repaired+s.Failed) / float64(s.Outputs)
+}
+
+// Counter records Stats, safely for concurrent use. The zero value is
+// ready to use.
+type Counter struct {
+	outputs, repairs, repaired, failed atomic.Uint64
+}
+
+// Record counts an output that took repairs to fix, or could not be fixed
+// if valid is false.
+func (c *Counter) Record(repairs int, valid bool) {
+	c.outputs.Add(1)
+	c.repairs.Add(uint64(repairs))
+	switch {
+	case !valid:
+		c.failed.Add(1)
+	case repairs > 0:
+		c.repaired.Add(1)
+	}
+}
+
+// Stats returns the counts so far.
+func (c *Counter) Stats() Stats {
+	return Stats{
+		Outputs:  c.outputs.Load(),
+		Repairs:  c.repairs.Load(),
+		Repaired: c.repaired.Load(),
+		Failed:   c.failed.Load(),
+	}
+}
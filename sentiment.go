@@ -0,0 +1,82 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+// deescalationThreshold is the sentiment score at or below which replies are
+// de-escalated.
+const deescalationThreshold = -0.5
+
+// deescalationPrompt is appended to the system prompt for upset users.
+const deescalationPrompt = "The user seems upset. Stay calm and empathetic, acknowledge their frustration without arguing, and focus on resolving their issue."
+
+// WithSentimentAnalyzer scores the sentiment of every message. The result is
+// added to the request context as "sentiment" (label), "sentiment_score" and
+// "emotions", where de-escalation, handoff triggers and chat events pick it
+// up. When de-escalation is enabled, a middleware.LexiconAnalyzer is used by
+// default; pass middleware.NewModelAnalyzer for LLM scoring.
+func WithSentimentAnalyzer(analyzer middleware.SentimentAnalyzer) Option {
+	return func(c *Chatbot) {
+		c.sentiment = analyzer
+	}
+}
+
+// enableLexicon creates the lexicon analyzer when cfg enables de-escalation
+// and the chatbot has no analyzer: de-escalation needs a sentiment signal,
+// and the lexicon analyzer is free.
+func (c *Chatbot) enableLexicon(cfg *config.Config) {
+	if c.sentiment == nil && cfg.Deescalate && c.lexicon.Load() == nil {
+		c.lexicon.CompareAndSwap(nil, middleware.NewLexiconAnalyzer(nil))
+	}
+}
+
+// analyzer returns the sentiment analyzer of the chatbot, nil if it has
+// none.
+func (c *Chatbot) analyzer() middleware.SentimentAnalyzer {
+	if c.sentiment != nil {
+		return c.sentiment
+	}
+	if lexicon := c.lexicon.Load(); lexicon != nil {
+		return lexicon
+	}
+	return nil
+}
+
+// assessSentiment analyzes the message and, when de-escalation is enabled
+// and the user is aggressive or upset, asks the model to de-escalate.
+func (c *Chatbot) assessSentiment(ctx context.Context, message string, askContext map[string]interface{}) {
+	analyzer := c.analyzer()
+	if _, scored := askContext["sentiment_score"]; !scored && analyzer != nil {
+		// Sentiment is best effort and never fails the request
+		if result, err := analyzer.Analyze(ctx, message); err == nil {
+			askContext["sentiment"] = result.Label
+			askContext["sentiment_score"] = result.Score
+			if len(result.Emotions) > 0 {
+				askContext["emotions"] = result.Emotions
+			}
+		}
+	}
+
+	cfg := c.GetConfig()
+	if cfg == nil || !cfg.Deescalate {
+		return
+	}
+	aggressive, _ := askContext["aggression_detected"].(bool)
+	score, scored := askContext["sentiment_score"].(float64)
+	if !aggressive && (!scored || score > deescalationThreshold) {
+		return
+	}
+
+	prompt, _ := askContext["prompt"].(string)
+	if strings.TrimSpace(prompt) == "" {
+		askContext["prompt"] = deescalationPrompt
+	} else {
+		askContext["prompt"] = prompt + "\n\n" + deescalationPrompt
+	}
+	askContext["deescalate"] = true
+}
@@ -0,0 +1,74 @@
+package gochatbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+// contextModel records the context of the last request.
+type contextModel struct {
+	context map[string]interface{}
+}
+
+func (m *contextModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.context = context
+	return "ok", nil
+}
+
+func (m *contextModel) Name() string     { return "context" }
+func (m *contextModel) Provider() string { return "test" }
+
+func TestSentimentDeescalation(t *testing.T) {
+	cfg := config.Default()
+	cfg.Prompt = "You are a support assistant."
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	bot, err := New(cfg, WithModel(model))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	if _, err := bot.Ask(context.Background(), "Thanks, this works great!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["sentiment"] != middleware.SentimentPositive || model.context["deescalate"] != nil {
+		t.Errorf("unexpected context for a happy user: %v", model.context)
+	}
+
+	if _, err := bot.Ask(context.Background(), "This is terrible and useless, I hate it!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prompt, _ := model.context["prompt"].(string)
+	if model.context["deescalate"] != true || !strings.HasPrefix(prompt, cfg.Prompt) || !strings.Contains(prompt, deescalationPrompt) {
+		t.Errorf("expected de-escalation prompt, got %q", prompt)
+	}
+	if score, _ := model.context["sentiment_score"].(float64); score > deescalationThreshold {
+		t.Errorf("expected a negative score, got %v", score)
+	}
+
+	cfg.Deescalate = false
+	quiet, _ := New(cfg, WithModel(model))
+	if _, err := quiet.Ask(context.Background(), "This is terrible and useless, I hate it!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, analyzed := model.context["sentiment_score"]; analyzed || model.context["prompt"] != cfg.Prompt {
+		t.Errorf("expected no analysis without de-escalation, got %v", model.context)
+	}
+
+	// Turning de-escalation on by reload starts analyzing sentiment
+	enabled := *cfg
+	enabled.Deescalate = true
+	if _, err := quiet.Reload(&enabled); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if _, err := quiet.Ask(context.Background(), "This is terrible and useless, I hate it!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["deescalate"] != true || model.context["sentiment"] != middleware.SentimentNegative {
+		t.Errorf("expected de-escalation after the reload, got %v", model.context)
+	}
+}
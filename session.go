@@ -0,0 +1,128 @@
+package gochatbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Defaults of anonymous session cookies.
+const (
+	defaultSessionCookieName = "chatbot_session"
+	defaultSessionMaxAge     = 30 * 24 * time.Hour
+)
+
+// sessionUserPrefix marks the user IDs of anonymous sessions, so they never
+// collide with the IDs of authenticated users.
+const sessionUserPrefix = "session:"
+
+// SessionCookieOptions configures the anonymous session cookies issued with
+// WithSessionCookies. The cookie is always HttpOnly.
+type SessionCookieOptions struct {
+	// Name is the cookie name; the default is "chatbot_session".
+	Name string
+	// Domain and Path scope the cookie; Path defaults to "/".
+	Domain string
+	Path   string
+	// Secure sends the cookie over HTTPS only. Set it in production.
+	Secure bool
+	// SameSite defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// MaxAge is how long a session lasts without requests; the default is
+	// 30 days. Every request renews it.
+	MaxAge time.Duration
+}
+
+// WithSessionCookies makes HandleHTTP and HandleStreamHTTP identify clients
+// that are not authenticated by an anonymous session cookie, issuing one on
+// their first request. A session the client sent back is the user for rate
+// limiting; requests issued a new one are limited by client IP, so dropping
+// the cookie does not reset the limit. The session owns
+// the conversations it starts, which other clients cannot continue when the
+// WithConversations store is an OwnedConversations. Its user ID is
+// "session:" followed by a hash of the cookie's random value, so stored and
+// logged IDs do not reveal the cookie. Users set with NewUserContext, such as by authentication
+// middleware, are identified as themselves and get no cookie.
+func WithSessionCookies(options SessionCookieOptions) Option {
+	return func(c *Chatbot) {
+		if options.Name == "" {
+			options.Name = defaultSessionCookieName
+		}
+		if options.Path == "" {
+			options.Path = "/"
+		}
+		if options.SameSite == 0 {
+			options.SameSite = http.SameSiteLaxMode
+		}
+		if options.MaxAge <= 0 {
+			options.MaxAge = defaultSessionMaxAge
+		}
+		c.sessions = &options
+	}
+}
+
+// IsSessionUser reports whether userID is the ID of an anonymous session
+// rather than of an authenticated user.
+func IsSessionUser(userID string) bool {
+	return strings.HasPrefix(userID, sessionUserPrefix)
+}
+
+// identifySession returns ctx carrying the user of the request's anonymous
+// session, issuing a session cookie on w if the request has no valid one.
+// It must be called before the response is written. Requests of
+// authenticated users, and chatbots without session cookies, are left as
+// they are.
+func (c *Chatbot) identifySession(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	options := c.sessions
+	if options == nil {
+		return ctx
+	}
+	if _, ok := UserFromContext(ctx); ok {
+		return ctx
+	}
+
+	// Only IDs the chatbot could have issued are accepted
+	var sessionID string
+	if cookie, err := r.Cookie(options.Name); err == nil {
+		if id, err := uuid.Parse(cookie.Value); err == nil {
+			sessionID = id.String()
+		}
+	}
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+		ctx = context.WithValue(ctx, newSessionKey{}, sessionUserID(sessionID))
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     options.Name,
+		Value:    sessionID,
+		Domain:   options.Domain,
+		Path:     options.Path,
+		MaxAge:   int(options.MaxAge / time.Second),
+		Secure:   options.Secure,
+		HttpOnly: true,
+		SameSite: options.SameSite,
+	})
+	return NewUserContext(ctx, User{ID: sessionUserID(sessionID)})
+}
+
+type newSessionKey struct{}
+
+// isNewSession reports whether userID is the session issued to the request
+// of ctx, which the client has not shown to hold yet.
+func isNewSession(ctx context.Context, userID string) bool {
+	issued, _ := ctx.Value(newSessionKey{}).(string)
+	return issued != "" && issued == userID
+}
+
+// sessionUserID returns the user ID of the session with the given cookie
+// value.
+func sessionUserID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return sessionUserPrefix + hex.EncodeToString(sum[:16])
+}
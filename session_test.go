@@ -0,0 +1,161 @@
+package gochatbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+func TestWithSessionCookies(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	conversations := newFakeConversations()
+	bot, err := New(cfg, WithModel(model), WithConversations(conversations, 0),
+		WithSessionCookies(SessionCookieOptions{Domain: "example.com", Secure: true}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	chat := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "Hi"}`))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		bot.HandleHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	// The first request is issued a session, which is its user
+	cookies := chat(nil).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a session cookie, got %v", cookies)
+	}
+	cookie := cookies[0]
+	if cookie.Name != "chatbot_session" || cookie.Domain != "example.com" || !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("unexpected cookie: %+v", cookie)
+	}
+	userID, _ := model.context["user_id"].(string)
+	if !IsSessionUser(userID) || strings.Contains(userID, cookie.Value) {
+		t.Errorf("expected a session user not revealing the cookie, got %q", userID)
+	}
+	if conversations.users["conv-1"] != userID {
+		t.Errorf("expected the session to own the conversation, got %q", conversations.users["conv-1"])
+	}
+
+	// Sending it back keeps the session
+	chat(&http.Cookie{Name: "chatbot_session", Value: cookie.Value})
+	if model.context["user_id"] != userID {
+		t.Errorf("expected the same session, got %v", model.context["user_id"])
+	}
+
+	// Values the chatbot could not have issued are replaced
+	chat(&http.Cookie{Name: "chatbot_session", Value: "admin"})
+	if model.context["user_id"] == userID || model.context["user_id"] == sessionUserPrefix+"admin" {
+		t.Errorf("expected a new session, got %v", model.context["user_id"])
+	}
+
+	// Authenticated users are not given a session
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "Hi"}`))
+	req = req.WithContext(NewUserContext(req.Context(), User{ID: "u1"}))
+	w := httptest.NewRecorder()
+	bot.HandleHTTP(w, req)
+	if len(w.Result().Cookies()) != 0 || model.context["user_id"] != "u1" {
+		t.Errorf("expected the authenticated user, got %v and %v", w.Result().Cookies(), model.context["user_id"])
+	}
+}
+
+func TestWithSessionCookies_ConversationOwnership(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	conversations := ownedConversations{newFakeConversations()}
+	bot, err := New(cfg, WithModel(model), WithConversations(conversations, 0), WithSessionCookies(SessionCookieOptions{}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	chat := func(handler http.HandlerFunc, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/chat", strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// A session continues the conversations it started
+	w := chat(bot.HandleHTTP, `{"message": "Hi"}`, nil)
+	owner := w.Result().Cookies()[0]
+	if w := chat(bot.HandleHTTP, `{"message": "Again", "conversation_id": "conv-1"}`, owner); w.Code != http.StatusOK {
+		t.Fatalf("expected the owner to continue, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Other sessions cannot read or add to them, over either endpoint
+	for _, handler := range []http.HandlerFunc{bot.HandleHTTP, bot.HandleStreamHTTP} {
+		w := chat(handler, `{"message": "Hi", "conversation_id": "conv-1"}`, nil)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"forbidden"`) {
+			t.Errorf("expected 403 for another session, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if len(conversations.messages["conv-1"]) != 4 {
+		t.Errorf("expected only the owner's exchanges, got %v", conversations.messages["conv-1"])
+	}
+
+	// Unknown conversations are not created
+	w = chat(bot.HandleHTTP, `{"message": "Hi", "conversation_id": "made-up"}`, owner)
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `"not_found"`) {
+		t.Errorf("expected 404 for an unknown conversation, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := conversations.messages["made-up"]; ok {
+		t.Error("expected no exchange to be stored for an unknown conversation")
+	}
+}
+
+func TestWithSessionCookies_RateLimitsPerSession(t *testing.T) {
+	limiter := middleware.NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute})
+	bot, err := New(&config.Config{Model: "free"}, WithRateLimit(limiter), WithSessionCookies(SessionCookieOptions{Name: "sid"}))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	stream := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/chat/stream", strings.NewReader(`{"message": "Hi"}`))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		bot.HandleStreamHTTP(w, req)
+		return w
+	}
+
+	first := stream(nil)
+	cookies := first.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" {
+		t.Fatalf("expected a session cookie, got %v", cookies)
+	}
+	// Dropping the cookie does not reset the limit of the client
+	if w := stream(nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a new session to be limited by client IP, got %d", w.Code)
+	}
+
+	// A session sent back is limited on its own
+	if w := stream(cookies[0]); w.Code == http.StatusTooManyRequests {
+		t.Errorf("expected the session to have its own limit")
+	}
+	if w := stream(cookies[0]); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the session to be rate limited, got %d", w.Code)
+	}
+}
@@ -0,0 +1,18 @@
+package gochatbot
+
+import (
+	"go.rumenx.com/chatbot/experiments"
+	"go.rumenx.com/chatbot/models"
+)
+
+// WithShadowModel sends a copy of every request to shadow in the background
+// and logs both replies and their diff, without ever returning the shadow's
+// reply. Use it to validate a new model on real traffic before switching.
+// See experiments.NewShadow for the options.
+func WithShadowModel(shadow models.Model, opts ...experiments.ShadowOption) Option {
+	return func(c *Chatbot) {
+		c.shadow = func(primary models.Model) models.Model {
+			return experiments.NewShadow(primary, shadow, opts...)
+		}
+	}
+}
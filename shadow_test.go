@@ -0,0 +1,39 @@
+package gochatbot
+
+import (
+	"context"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/experiments"
+)
+
+func TestShadowModel(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	primary, candidate := &contextModel{}, &echoModel{}
+	results := make(chan experiments.ShadowResult, 1)
+	bot, err := New(cfg, WithModel(primary), WithShadowModel(candidate,
+		experiments.WithShadowLogger(experiments.ShadowLoggerFunc(func(ctx context.Context, result experiments.ShadowResult) {
+			results <- result
+		}))))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	reply, err := bot.Ask(context.Background(), "hello", WithContext("conversation_id", "c1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "ok" {
+		t.Errorf("expected the primary reply, got %q", reply)
+	}
+
+	result := <-results
+	if result.ConversationID != "c1" || result.Primary != "ok" || result.Shadow != "You said: hello" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if bot.GetModel().Name() != "context" {
+		t.Errorf("expected the primary model's name, got %q", bot.GetModel().Name())
+	}
+}
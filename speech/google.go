@@ -0,0 +1,90 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// googleEncodings maps audio formats to Google Cloud Text-to-Speech encodings.
+var googleEncodings = map[string]string{
+	"mp3":  "MP3",
+	"opus": "OGG_OPUS",
+	"wav":  "LINEAR16",
+}
+
+// GoogleSynthesizer speaks text with Google Cloud Text-to-Speech.
+type GoogleSynthesizer struct {
+	apiKey string
+	opts   options
+}
+
+// NewGoogleSynthesizer creates a synthesizer authenticated with a Google
+// Cloud API key. The default language is "en-US"; without WithVoice Google
+// picks a voice for the language.
+func NewGoogleSynthesizer(apiKey string, opts ...Option) *GoogleSynthesizer {
+	o := newOptions(opts)
+	if o.language == "" {
+		o.language = "en-US"
+	}
+	if o.endpoint == "" {
+		o.endpoint = "https://texttospeech.googleapis.com/v1/text:synthesize"
+	}
+	return &GoogleSynthesizer{apiKey: apiKey, opts: o}
+}
+
+// Synthesize implements Synthesizer.
+func (s *GoogleSynthesizer) Synthesize(ctx context.Context, text string) (*Audio, error) {
+	encoding, ok := googleEncodings[strings.ToLower(s.opts.format)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio format %q for Google voices", s.opts.format)
+	}
+
+	voice := map[string]string{"languageCode": s.opts.language}
+	if s.opts.voice != "" {
+		voice["name"] = s.opts.voice
+	}
+	request := map[string]interface{}{
+		"input":       map[string]string{"text": text},
+		"voice":       voice,
+		"audioConfig": map[string]string{"audioEncoding": encoding},
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := s.opts.endpoint + "?key=" + url.QueryEscape(s.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.opts.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "Google Text-to-Speech"); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		AudioContent string `json:"audioContent"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(result.AudioContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	return &Audio{Data: data, ContentType: ContentType(s.opts.format)}, nil
+}
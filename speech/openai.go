@@ -0,0 +1,158 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// WhisperTranscriber transcribes audio with the OpenAI transcription API.
+type WhisperTranscriber struct {
+	apiKey string
+	opts   options
+}
+
+// NewWhisperTranscriber creates a transcriber using the OpenAI API key from
+// cfg. The default model is "whisper-1".
+func NewWhisperTranscriber(cfg config.OpenAIConfig, opts ...Option) *WhisperTranscriber {
+	o := newOptions(opts)
+	if o.model == "" {
+		o.model = "whisper-1"
+	}
+	if o.endpoint == "" {
+		o.endpoint = "https://api.openai.com/v1/audio/transcriptions"
+	}
+	return &WhisperTranscriber{apiKey: cfg.APIKey, opts: o}
+}
+
+// Transcribe implements Transcriber.
+func (t *WhisperTranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	if len(audio) == 0 {
+		return "", fmt.Errorf("audio is empty")
+	}
+	if filename == "" {
+		filename = "audio.webm"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	_ = form.WriteField("model", t.opts.model)
+	_ = form.WriteField("response_format", "json")
+	if t.opts.language != "" {
+		_ = form.WriteField("language", t.opts.language)
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.opts.endpoint, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	resp, err := t.opts.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "OpenAI"); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.Text, nil
+}
+
+// OpenAISynthesizer speaks text with the OpenAI speech API.
+type OpenAISynthesizer struct {
+	apiKey string
+	opts   options
+}
+
+// NewOpenAISynthesizer creates a synthesizer using the OpenAI API key from
+// cfg. The defaults are the "tts-1" model and the "alloy" voice.
+func NewOpenAISynthesizer(cfg config.OpenAIConfig, opts ...Option) *OpenAISynthesizer {
+	o := newOptions(opts)
+	if o.model == "" {
+		o.model = "tts-1"
+	}
+	if o.voice == "" {
+		o.voice = "alloy"
+	}
+	if o.endpoint == "" {
+		o.endpoint = "https://api.openai.com/v1/audio/speech"
+	}
+	return &OpenAISynthesizer{apiKey: cfg.APIKey, opts: o}
+}
+
+// Synthesize implements Synthesizer.
+func (s *OpenAISynthesizer) Synthesize(ctx context.Context, text string) (*Audio, error) {
+	request := map[string]string{
+		"model":           s.opts.model,
+		"input":           text,
+		"voice":           s.opts.voice,
+		"response_format": s.opts.format,
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.opts.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "OpenAI"); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &Audio{Data: data, ContentType: ContentType(s.opts.format)}, nil
+}
+
+// errorMessage extracts the message from an OpenAI or Google error body.
+func errorMessage(body []byte) string {
+	var apiError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiError) != nil {
+		return ""
+	}
+	return apiError.Error.Message
+}
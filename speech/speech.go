@@ -0,0 +1,141 @@
+// Package speech provides speech-to-text and text-to-speech components for
+// building voice bots on top of the chatbot: a Whisper transcriber and
+// OpenAI and Google Cloud voices.
+//
+//	stt := speech.NewWhisperTranscriber(cfg.OpenAI)
+//	tts := speech.NewOpenAISynthesizer(cfg.OpenAI, speech.WithVoice("nova"))
+//	bot, err := gochatbot.New(cfg, gochatbot.WithSpeech(stt, tts))
+//	http.HandleFunc("/audio", bot.HandleAudio)
+package speech
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxAudioSize is the largest upload accepted for transcription, matching
+// the Whisper API limit.
+const MaxAudioSize = 25 << 20
+
+// Audio is an encoded audio clip.
+type Audio struct {
+	Data []byte
+	// ContentType is the MIME type, e.g. "audio/mpeg".
+	ContentType string
+}
+
+// Transcriber converts speech to text.
+type Transcriber interface {
+	// Transcribe returns the text spoken in audio. The filename's extension
+	// tells the service the audio format.
+	Transcribe(ctx context.Context, audio []byte, filename string) (string, error)
+}
+
+// Synthesizer converts text to speech.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (*Audio, error)
+}
+
+// Option configures a transcriber or synthesizer. Options a component does
+// not use are ignored.
+type Option func(*options)
+
+type options struct {
+	model      string
+	endpoint   string
+	voice      string
+	language   string
+	format     string
+	httpClient *http.Client
+}
+
+// WithModel overrides the default model, e.g. "gpt-4o-transcribe" or "tts-1-hd".
+func WithModel(model string) Option {
+	return func(o *options) {
+		o.model = model
+	}
+}
+
+// WithEndpoint overrides the API endpoint.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithVoice selects the synthesizer voice, e.g. "alloy" for OpenAI or
+// "en-US-Neural2-C" for Google.
+func WithVoice(voice string) Option {
+	return func(o *options) {
+		o.voice = voice
+	}
+}
+
+// WithLanguage sets the spoken language as an ISO-639-1 code for
+// transcription ("en") or a BCP-47 tag for Google voices ("en-US").
+func WithLanguage(language string) Option {
+	return func(o *options) {
+		o.language = language
+	}
+}
+
+// WithFormat sets the synthesized audio format: "mp3" (default), "opus",
+// "aac", "flac" or "wav". Google voices support "mp3", "opus" and "wav".
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for API calls.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		format:     "mp3",
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// contentTypes maps audio formats to MIME types.
+var contentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/pcm",
+}
+
+// ContentType returns the MIME type of an audio format.
+func ContentType(format string) string {
+	if contentType, ok := contentTypes[strings.ToLower(format)]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// checkResponse turns a non-2xx response into an error with the API's message.
+func checkResponse(resp *http.Response, service string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(body))
+	if apiMessage := errorMessage(body); apiMessage != "" {
+		message = apiMessage
+	}
+	return fmt.Errorf("%s API error (status %d): %s", service, resp.StatusCode, message)
+}
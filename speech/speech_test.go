@@ -0,0 +1,102 @@
+package speech
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+func TestWhisperTranscriber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("missing file: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "clip.mp3" || string(data) != "audio" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" {
+			t.Errorf("unexpected upload %s %q %v", header.Filename, data, r.MultipartForm.Value)
+		}
+		_, _ = w.Write([]byte(`{"text":"hello there"}`))
+	}))
+	defer server.Close()
+
+	stt := NewWhisperTranscriber(config.OpenAIConfig{APIKey: "key"}, WithEndpoint(server.URL), WithLanguage("en"))
+	text, err := stt.Transcribe(context.Background(), []byte("audio"), "/tmp/clip.mp3")
+	if err != nil || text != "hello there" {
+		t.Errorf("unexpected transcript %q, %v", text, err)
+	}
+
+	if _, err := stt.Transcribe(context.Background(), nil, "clip.mp3"); err == nil {
+		t.Error("expected error for empty audio")
+	}
+}
+
+func TestOpenAISynthesizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["voice"] != "nova" || req["input"] != "Hi" || req["model"] != "tts-1" || req["response_format"] != "opus" {
+			t.Errorf("unexpected request %v", req)
+		}
+		_, _ = w.Write([]byte("OggS"))
+	}))
+	defer server.Close()
+
+	tts := NewOpenAISynthesizer(config.OpenAIConfig{APIKey: "key"},
+		WithEndpoint(server.URL), WithVoice("nova"), WithFormat("opus"))
+	audio, err := tts.Synthesize(context.Background(), "Hi")
+	if err != nil || string(audio.Data) != "OggS" || audio.ContentType != "audio/ogg" {
+		t.Errorf("unexpected audio %+v, %v", audio, err)
+	}
+}
+
+func TestGoogleSynthesizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "gkey" {
+			t.Errorf("unexpected key %q", r.URL.Query().Get("key"))
+		}
+		var req struct {
+			Voice       map[string]string `json:"voice"`
+			AudioConfig map[string]string `json:"audioConfig"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Voice["name"] != "en-GB-Neural2-A" || req.Voice["languageCode"] != "en-GB" || req.AudioConfig["audioEncoding"] != "MP3" {
+			t.Errorf("unexpected request %+v", req)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"audioContent": base64.StdEncoding.EncodeToString([]byte("ID3"))})
+	}))
+	defer server.Close()
+
+	tts := NewGoogleSynthesizer("gkey", WithEndpoint(server.URL), WithLanguage("en-GB"), WithVoice("en-GB-Neural2-A"))
+	audio, err := tts.Synthesize(context.Background(), "Hello")
+	if err != nil || string(audio.Data) != "ID3" || audio.ContentType != "audio/mpeg" {
+		t.Errorf("unexpected audio %+v, %v", audio, err)
+	}
+
+	if _, err := NewGoogleSynthesizer("gkey", WithFormat("flac")).Synthesize(context.Background(), "Hello"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAISynthesizer(config.OpenAIConfig{}, WithEndpoint(server.URL)).Synthesize(context.Background(), "Hi")
+	if err == nil || !strings.Contains(err.Error(), "Incorrect API key provided") || !strings.Contains(err.Error(), "401") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
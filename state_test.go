@@ -0,0 +1,71 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/tools"
+)
+
+func TestRunAgentConversationState(t *testing.T) {
+	addToCart := tools.New("add_to_cart", "Adds an item to the cart",
+		tools.Object(map[string]interface{}{"item": tools.String("Item name")}, "item"),
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				Item string `json:"item"`
+			}
+			json.Unmarshal(args, &in)
+			state, ok := tools.StateFromContext(ctx)
+			if !ok {
+				return "", nil
+			}
+			var cart []string
+			if _, err := state.Get("cart", &cart); err != nil {
+				return "", err
+			}
+			cart = append(cart, in.Item)
+			return "added", state.Set("cart", cart)
+		})
+	registry := tools.NewRegistry(addToCart)
+
+	manager := database.NewConversationManager(chatbottest.NewStore())
+	bot, err := New(config.Default(), WithModel(&scriptedModel{}), WithConversations(manager, 0), WithStateLimit(40))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	id, err := manager.StartConversation(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("failed to start conversation: %v", err)
+	}
+	ctx := tools.WithCaller(context.Background(), tools.Caller{ConversationID: id})
+
+	// Each turn adds to the cart kept in the conversation
+	add := func(item string) *scriptedModel {
+		return &scriptedModel{replies: []string{
+			`{"action":"add_to_cart","action_input":{"item":"` + item + `"}}`,
+			`{"final_answer":"Done."}`,
+		}}
+	}
+	for _, item := range []string{"book", "pen"} {
+		bot.model = add(item)
+		if _, err := bot.RunAgent(ctx, "Add a "+item, registry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	state, err := manager.LoadState(context.Background(), id)
+	if err != nil || string(state["cart"]) != `["book","pen"]` {
+		t.Errorf("expected the cart to persist across turns, got %s, %v", state["cart"], err)
+	}
+
+	// The size limit is reported to the model as a tool error
+	bot.model = add("a very long item name")
+	result, err := bot.RunAgent(ctx, "Add a long item", registry)
+	if err != nil || !strings.HasPrefix(result.Steps[0].Observation, "Error: conversation state too large") {
+		t.Errorf("expected the limit to be reported, got %+v, %v", result, err)
+	}
+}
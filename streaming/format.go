@@ -0,0 +1,70 @@
+package streaming
+
+import (
+	"time"
+)
+
+// OpenAIFormat writes the stream in the shape of OpenAI's chat completion
+// stream ("chat.completion.chunk" objects followed by "data: [DONE]"), so
+// clients written against the OpenAI API work unchanged. model is reported
+// when the provider did not name its model.
+func OpenAIFormat(model string) Format {
+	created := time.Now().Unix()
+	return Format{Transform: func(chunk StreamResponse) ([]Event, error) {
+		if chunk.Error != "" {
+			apiError := map[string]interface{}{
+				"message": chunk.Error,
+				"type":    "server_error",
+			}
+			if chunk.Code != "" {
+				apiError["code"] = chunk.Code
+			}
+			return []Event{{Data: map[string]interface{}{"error": apiError}}}, nil
+		}
+
+		name := model
+		if chunk.Model != "" {
+			name = chunk.Model
+		}
+		completion := map[string]interface{}{
+			"id":      "chatcmpl-" + chunk.ID,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   name,
+		}
+
+		if chunk.Thinking != "" {
+			// Reasoning follows the reasoning_content convention of
+			// OpenAI-compatible reasoning APIs
+			completion["choices"] = []map[string]interface{}{{
+				"index":         0,
+				"delta":         map[string]interface{}{"reasoning_content": chunk.Thinking},
+				"finish_reason": nil,
+			}}
+			return []Event{{Data: completion}}, nil
+		}
+
+		if !chunk.Done {
+			completion["choices"] = []map[string]interface{}{{
+				"index":         0,
+				"delta":         map[string]interface{}{"content": chunk.Content},
+				"finish_reason": nil,
+			}}
+			return []Event{{Data: completion}}, nil
+		}
+
+		finishReason := chunk.FinishReason
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+		completion["choices"] = []map[string]interface{}{{
+			"index":         0,
+			"delta":         map[string]interface{}{},
+			"finish_reason": finishReason,
+		}}
+		if chunk.Usage != nil {
+			completion["usage"] = chunk.Usage
+		}
+		return []Event{{Data: completion}, {Data: "[DONE]"}}, nil
+	}}
+}
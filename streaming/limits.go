@@ -0,0 +1,145 @@
+package streaming
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// bytesPerToken estimates the length of a token, as the reply events do.
+const bytesPerToken = 4
+
+// Limits end a stream early, whether or not the provider supports them, so
+// a runaway generation cannot stream on.
+type Limits struct {
+	// Stop lists sequences that end the stream; the content before the
+	// first one is sent, and the finish reason is "stop".
+	Stop []string
+	// MaxTokens caps the content, estimated at four bytes per token; the
+	// finish reason of a stream reaching it is "length". 0 means no cap.
+	MaxTokens int
+}
+
+// Apply returns text cut before its first stop sequence or at the max
+// tokens, with the finish reason if it was cut.
+func (l Limits) Apply(text string) (string, string) {
+	lim := l.newLimiter()
+	out, reason := lim.write(text)
+	if reason != "" {
+		return out, reason
+	}
+	rest, reason := lim.flush()
+	return out + rest, reason
+}
+
+// limiter enforces Limits on content arriving in chunks.
+type limiter struct {
+	stop     []string
+	maxBytes int
+	// held is text that may start a stop sequence, and sent how much was
+	// passed on
+	held string
+	sent int
+}
+
+func (l Limits) newLimiter() *limiter {
+	lim := &limiter{maxBytes: l.MaxTokens * bytesPerToken}
+	for _, stop := range l.Stop {
+		if stop != "" {
+			lim.stop = append(lim.stop, stop)
+		}
+	}
+	return lim
+}
+
+// active reports whether there is anything to enforce.
+func (l *limiter) active() bool {
+	return len(l.stop) > 0 || l.maxBytes > 0
+}
+
+// write returns the part of chunk to send on, and the finish reason if the
+// stream is to end after it.
+func (l *limiter) write(chunk string) (string, string) {
+	text := l.held + chunk
+	l.held = ""
+
+	reason := ""
+	if i := l.firstStop(text); i >= 0 {
+		text, reason = text[:i], "stop"
+	} else {
+		// Hold back the end of text while it could start a stop sequence
+		for n := min(len(text), l.longestStop()-1); n > 0; n-- {
+			if l.startsStop(text[len(text)-n:]) {
+				text, l.held = text[:len(text)-n], text[len(text)-n:]
+				break
+			}
+		}
+	}
+	if l.overflow(text) != "" {
+		text, reason, l.held = l.cut(text), "length", ""
+	}
+	l.sent += len(text)
+	return text, reason
+}
+
+// flush returns the text held back at the end of the stream, and "length"
+// if it reaches the max tokens.
+func (l *limiter) flush() (string, string) {
+	text, reason := l.held, ""
+	l.held = ""
+	if l.overflow(text) != "" {
+		text, reason = l.cut(text), "length"
+	}
+	l.sent += len(text)
+	return text, reason
+}
+
+// overflow returns "length" if sending text would pass the max tokens.
+func (l *limiter) overflow(text string) string {
+	if l.maxBytes > 0 && l.sent+len(text) > l.maxBytes {
+		return "length"
+	}
+	return ""
+}
+
+// cut returns the start of text that still fits in the max tokens, ending
+// on a character boundary.
+func (l *limiter) cut(text string) string {
+	if l.maxBytes <= 0 {
+		return text
+	}
+	end := max(0, min(len(text), l.maxBytes-l.sent))
+	for end > 0 && end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+// firstStop returns the index of the first stop sequence in text, or -1.
+func (l *limiter) firstStop(text string) int {
+	first := -1
+	for _, stop := range l.stop {
+		if i := strings.Index(text, stop); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
+
+// startsStop reports whether text is the start of a stop sequence.
+func (l *limiter) startsStop(text string) bool {
+	for _, stop := range l.stop {
+		if strings.HasPrefix(stop, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// longestStop returns the length of the longest stop sequence.
+func (l *limiter) longestStop() int {
+	longest := 0
+	for _, stop := range l.stop {
+		longest = max(longest, len(stop))
+	}
+	return longest
+}
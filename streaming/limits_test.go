@@ -0,0 +1,151 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitsApply(t *testing.T) {
+	tests := []struct {
+		limits     Limits
+		text, want string
+		reason     string
+	}{
+		{Limits{}, "Hello world", "Hello world", ""},
+		{Limits{Stop: []string{"\nUser:", "END"}}, "Hi\nUser: more", "Hi", "stop"},
+		{Limits{Stop: []string{"D", "END"}}, "The END", "The ", "stop"},
+		{Limits{Stop: []string{"END"}}, "Almost EN", "Almost EN", ""},
+		{Limits{MaxTokens: 2}, "12345678", "12345678", ""},
+		{Limits{MaxTokens: 2}, "123456789", "12345678", "length"},
+		// Characters are not cut in half
+		{Limits{MaxTokens: 1}, "abcé", "abc", "length"},
+		{Limits{Stop: []string{"END"}, MaxTokens: 1}, "abcdefEND", "abcd", "length"},
+	}
+	for _, tt := range tests {
+		got, reason := tt.limits.Apply(tt.text)
+		if got != tt.want || reason != tt.reason {
+			t.Errorf("%+v.Apply(%q) = %q, %q, want %q, %q", tt.limits, tt.text, got, reason, tt.want, tt.reason)
+		}
+	}
+}
+
+// streamedContent returns the content chunks and the done chunk of a stream.
+func streamedContent(t *testing.T, body string) ([]string, StreamResponse) {
+	t.Helper()
+	var contents []string
+	var done StreamResponse
+	for _, line := range strings.Split(body, "\n") {
+		var chunk StreamResponse
+		if data, ok := strings.CutPrefix(line, "data: "); ok && json.Unmarshal([]byte(data), &chunk) == nil {
+			if chunk.Done {
+				done = chunk
+			} else if chunk.Content != "" {
+				contents = append(contents, chunk.Content)
+			}
+		}
+	}
+	return contents, done
+}
+
+func TestStreamProcessor_ProcessChannelStop(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+
+	// A model that would generate forever, and fails once stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	meta := &Metadata{Model: "runaway"}
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, chunk := range []string{"Sure.", " E", "ND ignored"} {
+			ch <- chunk
+		}
+		for {
+			select {
+			case ch <- "more ":
+			case <-ctx.Done():
+				meta.Fail(ctx.Err())
+				return
+			}
+		}
+	}()
+
+	processor := NewStreamProcessor("req", handler)
+	processor.SetMetadata(meta)
+	processor.SetLimits(Limits{Stop: []string{"END"}})
+	processor.SetCancel(cancel)
+	if err := processor.ProcessChannel(context.Background(), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	contents, done := streamedContent(t, w.Body.String())
+	if strings.Join(contents, "") != "Sure. " {
+		t.Errorf("expected the content before the stop sequence, got %q", contents)
+	}
+	if done.FinishReason != "stop" || done.Model != "runaway" || done.Error != "" {
+		t.Errorf("unexpected done chunk: %+v", done)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the generation to be cancelled")
+	}
+}
+
+func TestStreamProcessor_ProcessChannelFlushesHeldText(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+
+	ch := make(chan string, 2)
+	ch <- "Hello E"
+	ch <- "N"
+	close(ch)
+	processor := NewStreamProcessor("req", handler)
+	processor.SetLimits(Limits{Stop: []string{"END"}})
+	if err := processor.ProcessChannel(context.Background(), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	contents, done := streamedContent(t, w.Body.String())
+	if strings.Join(contents, "") != "Hello EN" || done.FinishReason != "" {
+		t.Errorf("expected the whole text, got %q and %+v", contents, done)
+	}
+}
+
+func TestStreamProcessor_ProviderMaxTokens(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+
+	body := `data: {"model":"gpt-4o","choices":[{"delta":{"content":"1234"}}]}
+
+data: {"model":"gpt-4o","choices":[{"delta":{"content":"5678"}}]}
+
+data: {"model":"gpt-4o","choices":[{"delta":{"content":"9"}}]}
+
+data: [DONE]
+`
+	response := &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}
+	processor := NewStreamProcessor("req", handler)
+	processor.SetLimits(Limits{MaxTokens: 1})
+	if err := processor.ProcessOpenAIStream(context.Background(), response); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	contents, done := streamedContent(t, w.Body.String())
+	if strings.Join(contents, "") != "1234" || done.FinishReason != "length" || done.Model != "gpt-4o" {
+		t.Errorf("expected the stream to end at the max tokens, got %q and %+v", contents, done)
+	}
+}
@@ -0,0 +1,52 @@
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+// DefaultMaxLineSize is the longest line of a provider's SSE stream read by
+// default. Lines are usually small, but one event may carry the whole
+// arguments of a large tool call.
+const DefaultMaxLineSize = 1 << 20
+
+// initialLineBuffer is the buffer a line scanner starts with; it grows as
+// long lines need it.
+const initialLineBuffer = 64 << 10
+
+// NewLineScanner returns a scanner of the lines of r that accepts lines of
+// up to maxLineSize bytes, or DefaultMaxLineSize when it is 0.
+func NewLineScanner(r io.Reader, maxLineSize int) *bufio.Scanner {
+	maxLineSize = lineLimit(maxLineSize)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, min(initialLineBuffer, maxLineSize)), maxLineSize)
+	return scanner
+}
+
+// ScanError describes why a line scanner of a provider's stream stopped. A
+// line over maxLineSize is reported with the limit, so it can be raised;
+// any other error is the connection failing, which marks the provider as
+// unavailable unless the request was cancelled.
+func ScanError(err error, maxLineSize int) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("stream line longer than %d bytes: %w", lineLimit(maxLineSize), err)
+	}
+	err = fmt.Errorf("failed to read stream: %w", err)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return chaterrors.Mark(err, chaterrors.ErrProviderUnavailable)
+}
+
+// lineLimit returns maxLineSize, or DefaultMaxLineSize when it is not set.
+func lineLimit(maxLineSize int) int {
+	if maxLineSize <= 0 {
+		return DefaultMaxLineSize
+	}
+	return maxLineSize
+}
@@ -0,0 +1,63 @@
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+func TestNewLineScanner(t *testing.T) {
+	long := strings.Repeat("a", 100<<10)
+	scanner := NewLineScanner(strings.NewReader(long+"\nshort\n"), 0)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != long || lines[1] != "short" {
+		t.Errorf("expected both lines, got %d", len(lines))
+	}
+
+	scanner = NewLineScanner(strings.NewReader(long+"\n"), 1024)
+	if scanner.Scan() {
+		t.Fatal("expected a line over the limit to stop the scanner")
+	}
+	if !errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		t.Errorf("expected bufio.ErrTooLong, got %v", scanner.Err())
+	}
+}
+
+func TestScanError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		message     string
+		unavailable bool
+	}{
+		{name: "line too long", err: bufio.ErrTooLong, message: "stream line longer than 1048576 bytes"},
+		{name: "connection lost", err: errors.New("unexpected EOF"), message: "failed to read stream: unexpected EOF", unavailable: true},
+		{name: "cancelled", err: fmt.Errorf("read: %w", context.Canceled), message: "failed to read stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ScanError(tt.err, 0)
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected %q, got %q", tt.message, err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the scanner's error to be wrapped")
+			}
+			if errors.Is(err, chaterrors.ErrProviderUnavailable) != tt.unavailable {
+				t.Errorf("expected unavailable %v, got %v", tt.unavailable, err)
+			}
+		})
+	}
+}
@@ -2,14 +2,18 @@
 package streaming
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"runtime/debug"
 	"strings"
 	"time"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/internal/logging"
 )
 
 // StreamResponse represents a streaming response chunk.
@@ -18,19 +22,188 @@ type StreamResponse struct {
 	Content string `json:"content"`
 	Done    bool   `json:"done"`
 	Error   string `json:"error,omitempty"`
+	// Code identifies the kind of error, such as "rate_limited".
+	Code string `json:"code,omitempty"`
+
+	// Thinking is set instead of Content on chunks carrying the model's
+	// reasoning, for models that stream it separately from the answer.
+	Thinking string `json:"thinking,omitempty"`
+
+	// FinishReason, Usage and Model are only set on the done chunk, when
+	// the provider reported them.
+	FinishReason string `json:"finish_reason,omitempty"`
+	Usage        *Usage `json:"usage,omitempty"`
+	Model        string `json:"model,omitempty"`
+	// ConversationID is set on the done chunk of a request in a
+	// conversation, for the client to send with its next message.
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// Usage reports the tokens used by a request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Metadata describes a finished stream: why generation stopped, the tokens
+// used and the model that answered.
+type Metadata struct {
+	FinishReason string
+	Usage        *Usage
+	Model        string
+	// ConversationID is the conversation the stream's exchange belongs to.
+	ConversationID string
+	// MessageID is the ID of the stored reply, once the exchange is stored
+	// in a conversation.
+	MessageID string
+	// Err is why the stream failed partway, such as a dropped provider
+	// connection; the content sent before it is real. A failed stream ends
+	// with an error chunk instead of the done chunk.
+	Err error
+}
+
+// Fail records that the stream failed with err. Streaming models call it
+// before closing their channel instead of sending the error as content.
+func (m *Metadata) Fail(err error) {
+	m.FinishReason = "error"
+	m.Err = err
+}
+
+// errStreamPanicked is the failure of a stream whose goroutine panicked.
+var errStreamPanicked = errors.New("internal error")
+
+type metadataKey struct{}
+
+// NewMetadataContext returns a context carrying meta. Streaming models fill
+// it in before closing their channel, so the caller can read it once the
+// stream has ended.
+func NewMetadataContext(ctx context.Context, meta *Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, meta)
+}
+
+// MetadataFromContext returns the metadata carried by ctx, if any.
+func MetadataFromContext(ctx context.Context) (*Metadata, bool) {
+	meta, ok := ctx.Value(metadataKey{}).(*Metadata)
+	return meta, ok
+}
+
+type thinkingKey struct{}
+
+// NewThinkingContext returns a context carrying ch. Streaming models that
+// reason before answering, such as Anthropic with extended thinking, send
+// their thinking deltas to it, before the answer is sent on their own
+// channel. Models that do not reason ignore it; ch is never closed.
+func NewThinkingContext(ctx context.Context, ch chan<- string) context.Context {
+	return context.WithValue(ctx, thinkingKey{}, ch)
+}
+
+// ThinkingFromContext returns the thinking channel carried by ctx, if any.
+func ThinkingFromContext(ctx context.Context) (chan<- string, bool) {
+	ch, ok := ctx.Value(thinkingKey{}).(chan<- string)
+	return ch, ok
+}
+
+type panicKey struct{}
+
+// PanicHandler receives a panic recovered in a stream goroutine, with the
+// goroutine's stack.
+type PanicHandler func(value interface{}, stack []byte)
+
+// NewPanicContext returns a context carrying handler, which Recover passes
+// the panics of stream goroutines to.
+func NewPanicContext(ctx context.Context, handler PanicHandler) context.Context {
+	return context.WithValue(ctx, panicKey{}, handler)
+}
+
+// Recover stops a panic in a goroutine feeding a stream from crashing the
+// process; the stream ends with an internal error. The panic
+// goes to the handler set with NewPanicContext, or is logged with the
+// chatbot's logger. Streaming models defer it directly in their goroutines.
+func Recover(ctx context.Context) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	stack := debug.Stack()
+	if meta, ok := MetadataFromContext(ctx); ok {
+		meta.Fail(errStreamPanicked)
+	}
+	if handler, ok := ctx.Value(panicKey{}).(PanicHandler); ok {
+		handler(value, stack)
+		return
+	}
+	logging.Printf(ctx, "streaming: panic recovered: %v\n%s", value, stack)
+}
+
+// Mode controls what the Content of each chunk carries.
+type Mode string
+
+// Stream modes.
+const (
+	// ModeDelta sends the text added since the previous chunk (default).
+	ModeDelta Mode = "delta"
+	// ModeCumulative sends the full text so far in every chunk, including
+	// the done chunk.
+	ModeCumulative Mode = "cumulative"
+)
+
+// ParseMode parses a stream mode. An empty string is ModeDelta.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case "", ModeDelta:
+		return ModeDelta, nil
+	case ModeCumulative:
+		return ModeCumulative, nil
+	default:
+		return "", fmt.Errorf("unknown stream mode %q", s)
+	}
+}
+
+// EventNames sets the SSE event type of each kind of chunk. Chunks with an
+// empty name are sent without an event line, which browsers deliver as
+// "message" events.
+type EventNames struct {
+	Chunk string `json:"chunk" yaml:"chunk"`
+	Done  string `json:"done" yaml:"done"`
+	Error string `json:"error" yaml:"error"`
+}
+
+// Event is one server-sent event. Data is marshaled to JSON unless it is a
+// string, which is sent as is.
+type Event struct {
+	Name string
+	Data interface{}
+}
+
+// Transform maps a chunk to the events sent for it, so the stream can match
+// an existing frontend contract. Events without a name get the configured
+// name for the chunk; returning no events skips the chunk.
+type Transform func(chunk StreamResponse) ([]Event, error)
+
+// Format customizes how chunks are written.
+type Format struct {
+	Events    EventNames
+	Transform Transform
 }
 
 // StreamHandler handles Server-Sent Events (SSE) streaming.
 type StreamHandler struct {
-	writer  http.ResponseWriter
-	flusher http.Flusher
-	done    chan bool
+	writer http.ResponseWriter
+	done   chan bool
+	mode   Mode
+	text   strings.Builder
+	format Format
+	err    error
 }
 
+// ErrClientDisconnected is returned by the writes of a StreamHandler once
+// writing to the client has failed, usually because it went away.
+var ErrClientDisconnected = errors.New("client disconnected")
+
 // NewStreamHandler creates a new streaming handler.
 func NewStreamHandler(w http.ResponseWriter) (*StreamHandler, error) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	if _, ok := w.(http.Flusher); !ok {
 		return nil, fmt.Errorf("streaming unsupported: ResponseWriter does not implement http.Flusher")
 	}
 
@@ -42,26 +215,100 @@ func NewStreamHandler(w http.ResponseWriter) (*StreamHandler, error) {
 	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
 	return &StreamHandler{
-		writer:  w,
-		flusher: flusher,
-		done:    make(chan bool),
+		writer: w,
+		done:   make(chan bool),
+		mode:   ModeDelta,
 	}, nil
 }
 
-// WriteChunk writes a streaming chunk to the response.
+// SetMode sets whether chunks carry deltas or the full text so far. Set it
+// before writing the first chunk.
+func (s *StreamHandler) SetMode(mode Mode) {
+	s.mode = mode
+}
+
+// SetFormat sets the event names and payload transform. Set it before
+// writing the first chunk.
+func (s *StreamHandler) SetFormat(format Format) {
+	s.format = format
+}
+
+// WriteChunk writes a streaming chunk to the response. Content is the delta;
+// in ModeCumulative it is replaced with the full text so far. Once a write
+// or flush fails, it and every later write return an error matching
+// ErrClientDisconnected without writing.
 func (s *StreamHandler) WriteChunk(chunk StreamResponse) error {
-	data, err := json.Marshal(chunk)
-	if err != nil {
-		return fmt.Errorf("failed to marshal chunk: %w", err)
+	if s.err != nil {
+		return s.err
+	}
+	if s.mode == ModeCumulative && chunk.Error == "" && chunk.Thinking == "" {
+		s.text.WriteString(chunk.Content)
+		chunk.Content = s.text.String()
 	}
 
-	// Write SSE format
-	_, err = fmt.Fprintf(s.writer, "data: %s\n\n", data)
-	if err != nil {
-		return fmt.Errorf("failed to write chunk: %w", err)
+	name := s.format.Events.Chunk
+	switch {
+	case chunk.Error != "":
+		name = s.format.Events.Error
+	case chunk.Done:
+		name = s.format.Events.Done
+	}
+
+	events := []Event{{Name: name, Data: chunk}}
+	if s.format.Transform != nil {
+		var err error
+		if events, err = s.format.Transform(chunk); err != nil {
+			return fmt.Errorf("failed to transform chunk: %w", err)
+		}
+	}
+
+	for _, event := range events {
+		if event.Name == "" {
+			event.Name = name
+		}
+		if err := s.writeEvent(event); err != nil {
+			return err
+		}
+	}
+
+	// The controller reports flush errors of writers that can tell
+	if err := http.NewResponseController(s.writer).Flush(); err != nil {
+		s.err = fmt.Errorf("%w: failed to flush chunk: %w", ErrClientDisconnected, err)
+		return s.err
+	}
+	return nil
+}
+
+// Err returns the error that ended writing to the client, if any.
+func (s *StreamHandler) Err() error {
+	return s.err
+}
+
+// writeEvent writes one event in SSE format.
+func (s *StreamHandler) writeEvent(event Event) error {
+	data, ok := event.Data.(string)
+	if !ok {
+		encoded, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chunk: %w", err)
+		}
+		data = string(encoded)
+	}
+
+	var out strings.Builder
+	if event.Name != "" {
+		fmt.Fprintf(&out, "event: %s\n", event.Name)
+	}
+	// Every line of the data needs its own field
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&out, "data: %s\n", line)
 	}
+	out.WriteString("\n")
 
-	s.flusher.Flush()
+	if _, err := io.WriteString(s.writer, out.String()); err != nil {
+		s.err = fmt.Errorf("%w: failed to write chunk: %w", ErrClientDisconnected, err)
+		return s.err
+	}
 	return nil
 }
 
@@ -74,6 +321,16 @@ func (s *StreamHandler) WriteError(id, errorMsg string) error {
 	})
 }
 
+// WriteErrorCode writes an error chunk with the code of the error's kind.
+func (s *StreamHandler) WriteErrorCode(id, code, errorMsg string) error {
+	return s.WriteChunk(StreamResponse{
+		ID:    id,
+		Error: errorMsg,
+		Code:  code,
+		Done:  true,
+	})
+}
+
 // WriteDone writes a completion chunk to the response.
 func (s *StreamHandler) WriteDone(id string) error {
 	return s.WriteChunk(StreamResponse{
@@ -82,6 +339,21 @@ func (s *StreamHandler) WriteDone(id string) error {
 	})
 }
 
+// WriteDoneMetadata writes a completion chunk carrying the stream metadata.
+func (s *StreamHandler) WriteDoneMetadata(id string, meta *Metadata) error {
+	chunk := StreamResponse{
+		ID:   id,
+		Done: true,
+	}
+	if meta != nil {
+		chunk.FinishReason = meta.FinishReason
+		chunk.Usage = meta.Usage
+		chunk.Model = meta.Model
+		chunk.ConversationID = meta.ConversationID
+	}
+	return s.WriteChunk(chunk)
+}
+
 // Close closes the stream.
 func (s *StreamHandler) Close() {
 	close(s.done)
@@ -89,8 +361,13 @@ func (s *StreamHandler) Close() {
 
 // StreamProcessor processes streaming data from various sources.
 type StreamProcessor struct {
-	requestID string
-	handler   *StreamHandler
+	requestID   string
+	handler     *StreamHandler
+	metadata    *Metadata
+	thinking    <-chan string
+	maxLineSize int
+	limits      *limiter
+	cancel      context.CancelFunc
 }
 
 // NewStreamProcessor creates a new stream processor.
@@ -101,10 +378,85 @@ func NewStreamProcessor(requestID string, handler *StreamHandler) *StreamProcess
 	}
 }
 
+// SetMetadata sets the metadata sent with the done chunk. ProcessChannel
+// reads it once the channel is closed, so the model may fill it in while
+// streaming; the provider stream processors fill it in themselves.
+func (sp *StreamProcessor) SetMetadata(meta *Metadata) {
+	sp.metadata = meta
+}
+
+// SetThinking sets the channel of thinking deltas that ProcessChannel
+// streams as thinking chunks alongside the answer, as passed to the model
+// with NewThinkingContext.
+func (sp *StreamProcessor) SetThinking(ch <-chan string) {
+	sp.thinking = ch
+}
+
+// SetMaxLineSize sets the longest line ProcessOpenAIStream and
+// ProcessAnthropicStream accept, in bytes; 0 means DefaultMaxLineSize. A
+// longer line ends the stream with an error.
+func (sp *StreamProcessor) SetMaxLineSize(size int) {
+	sp.maxLineSize = size
+}
+
+// SetLimits sets the stop sequences and max tokens that end the stream
+// early. The content after them is not sent, and the done chunk carries
+// the finish reason "stop" or "length".
+func (sp *StreamProcessor) SetLimits(limits Limits) {
+	sp.limits = limits.newLimiter()
+	if !sp.limits.active() {
+		sp.limits = nil
+	}
+}
+
+// SetCancel sets the function that stops the generation when the limits
+// end the stream early. ProcessChannel then waits for the channel to close,
+// so the done chunk gets the model's metadata; without it, the metadata is
+// dropped and the caller must stop the model once ProcessChannel returns.
+func (sp *StreamProcessor) SetCancel(cancel context.CancelFunc) {
+	sp.cancel = cancel
+}
+
+// limit applies the limits to content, returning what to send and the
+// finish reason if the stream is to end.
+func (sp *StreamProcessor) limit(content string) (string, string) {
+	if sp.limits == nil {
+		return content, ""
+	}
+	return sp.limits.write(content)
+}
+
+// writeContent writes a content chunk, unless it is empty.
+func (sp *StreamProcessor) writeContent(content string) error {
+	if content == "" {
+		return nil
+	}
+	err := sp.handler.WriteChunk(StreamResponse{
+		ID:      sp.requestID,
+		Content: content,
+		Done:    false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+	return nil
+}
+
+// writeDone writes the done chunk with any metadata, or the error chunk of
+// a stream that failed, described by chaterrors.Message so provider
+// responses are not passed on to clients.
+func (sp *StreamProcessor) writeDone() error {
+	if sp.metadata != nil && sp.metadata.Err != nil {
+		err := sp.metadata.Err
+		return sp.handler.WriteErrorCode(sp.requestID, chaterrors.Code(err), chaterrors.Message(err))
+	}
+	return sp.handler.WriteDoneMetadata(sp.requestID, sp.metadata)
+}
+
 // ProcessChannel processes a channel of strings and streams them.
 func (sp *StreamProcessor) ProcessChannel(ctx context.Context, ch <-chan string) error {
 	defer func() {
-		if err := sp.handler.WriteDone(sp.requestID); err != nil {
+		if err := sp.writeDone(); err != nil {
 			// Log the error but don't return it as it's in defer
 		}
 	}()
@@ -112,17 +464,27 @@ func (sp *StreamProcessor) ProcessChannel(ctx context.Context, ch <-chan string)
 	for {
 		select {
 		case <-ctx.Done():
+			// The model may still be writing the metadata
+			sp.metadata = nil
 			return sp.handler.WriteError(sp.requestID, "Request cancelled")
 		case content, ok := <-ch:
 			if !ok {
 				// Channel closed, we're done
-				return nil
+				return sp.flushLimits()
 			}
 
+			content, reason := sp.limit(content)
+			if err := sp.writeContent(content); err != nil {
+				return err
+			}
+			if reason != "" {
+				sp.stopChannel(ch, reason)
+				return nil
+			}
+		case thinking := <-sp.thinking:
 			err := sp.handler.WriteChunk(StreamResponse{
-				ID:      sp.requestID,
-				Content: content,
-				Done:    false,
+				ID:       sp.requestID,
+				Thinking: thinking,
 			})
 			if err != nil {
 				return fmt.Errorf("failed to write chunk: %w", err)
@@ -131,16 +493,57 @@ func (sp *StreamProcessor) ProcessChannel(ctx context.Context, ch <-chan string)
 	}
 }
 
+// flushLimits writes the content the limits held back, at the end of the
+// stream.
+func (sp *StreamProcessor) flushLimits() error {
+	if sp.limits == nil {
+		return nil
+	}
+	rest, reason := sp.limits.flush()
+	if reason != "" {
+		sp.metadata = finished(sp.metadata, reason)
+	}
+	return sp.writeContent(rest)
+}
+
+// stopChannel ends a channel's stream early for reason.
+func (sp *StreamProcessor) stopChannel(ch <-chan string, reason string) {
+	if sp.cancel == nil {
+		// The model may still be writing the metadata
+		sp.metadata = &Metadata{FinishReason: reason}
+		return
+	}
+	sp.cancel()
+	for range ch {
+	}
+	sp.metadata = finished(sp.metadata, reason)
+}
+
+// finished returns meta, or new metadata, recording that the limits ended
+// the stream for reason. A model failing because it was stopped does not
+// fail the stream.
+func finished(meta *Metadata, reason string) *Metadata {
+	if meta == nil {
+		meta = &Metadata{}
+	}
+	meta.FinishReason = reason
+	meta.Err = nil
+	return meta
+}
+
 // ProcessOpenAIStream processes OpenAI streaming response format.
 func (sp *StreamProcessor) ProcessOpenAIStream(ctx context.Context, response *http.Response) error {
+	if sp.metadata == nil {
+		sp.metadata = &Metadata{}
+	}
 	defer func() {
-		if err := sp.handler.WriteDone(sp.requestID); err != nil {
+		if err := sp.writeDone(); err != nil {
 			// Log the error but don't return it as it's in defer
 		}
 	}()
 	defer response.Body.Close()
 
-	scanner := bufio.NewScanner(response.Body)
+	scanner := NewLineScanner(response.Body, sp.maxLineSize)
 
 	for scanner.Scan() {
 		select {
@@ -162,7 +565,7 @@ func (sp *StreamProcessor) ProcessOpenAIStream(ctx context.Context, response *ht
 
 			// Check for end of stream
 			if data == "[DONE]" {
-				return nil
+				return sp.flushLimits()
 			}
 
 			// Parse JSON data
@@ -172,22 +575,24 @@ func (sp *StreamProcessor) ProcessOpenAIStream(ctx context.Context, response *ht
 			}
 
 			// Extract content from OpenAI format
-			content := extractOpenAIContent(chunk)
-			if content != "" {
-				err := sp.handler.WriteChunk(StreamResponse{
-					ID:      sp.requestID,
-					Content: content,
-					Done:    false,
-				})
-				if err != nil {
-					return fmt.Errorf("failed to write chunk: %w", err)
-				}
+			ExtractOpenAIMetadata(chunk, sp.metadata)
+			content, reason := sp.limit(extractOpenAIContent(chunk))
+			if err := sp.writeContent(content); err != nil {
+				return err
+			}
+			if reason != "" {
+				// Closing the body ends the generation
+				sp.metadata = finished(sp.metadata, reason)
+				return nil
 			}
 		}
 	}
 
+	if err := sp.flushLimits(); err != nil {
+		return err
+	}
 	if err := scanner.Err(); err != nil {
-		return sp.handler.WriteError(sp.requestID, fmt.Sprintf("Stream reading error: %v", err))
+		sp.metadata.Fail(ScanError(err, sp.maxLineSize))
 	}
 
 	return nil
@@ -195,14 +600,17 @@ func (sp *StreamProcessor) ProcessOpenAIStream(ctx context.Context, response *ht
 
 // ProcessAnthropicStream processes Anthropic streaming response format.
 func (sp *StreamProcessor) ProcessAnthropicStream(ctx context.Context, response *http.Response) error {
+	if sp.metadata == nil {
+		sp.metadata = &Metadata{}
+	}
 	defer func() {
-		if err := sp.handler.WriteDone(sp.requestID); err != nil {
+		if err := sp.writeDone(); err != nil {
 			// Log the error but don't return it as it's in defer
 		}
 	}()
 	defer response.Body.Close()
 
-	scanner := bufio.NewScanner(response.Body)
+	scanner := NewLineScanner(response.Body, sp.maxLineSize)
 
 	for scanner.Scan() {
 		select {
@@ -223,22 +631,24 @@ func (sp *StreamProcessor) ProcessAnthropicStream(ctx context.Context, response
 			}
 
 			// Extract content from Anthropic format
-			content := extractAnthropicContent(chunk)
-			if content != "" {
-				err := sp.handler.WriteChunk(StreamResponse{
-					ID:      sp.requestID,
-					Content: content,
-					Done:    false,
-				})
-				if err != nil {
-					return fmt.Errorf("failed to write chunk: %w", err)
-				}
+			ExtractAnthropicMetadata(chunk, sp.metadata)
+			content, reason := sp.limit(extractAnthropicContent(chunk))
+			if err := sp.writeContent(content); err != nil {
+				return err
+			}
+			if reason != "" {
+				// Closing the body ends the generation
+				sp.metadata = finished(sp.metadata, reason)
+				return nil
 			}
 		}
 	}
 
+	if err := sp.flushLimits(); err != nil {
+		return err
+	}
 	if err := scanner.Err(); err != nil {
-		return sp.handler.WriteError(sp.requestID, fmt.Sprintf("Stream reading error: %v", err))
+		sp.metadata.Fail(ScanError(err, sp.maxLineSize))
 	}
 
 	return nil
@@ -269,6 +679,78 @@ func extractOpenAIContent(chunk map[string]interface{}) string {
 	return content
 }
 
+// ExtractOpenAIMetadata records the finish reason, usage and model from an
+// OpenAI streaming chunk. Usage is only sent when the request sets
+// stream_options.include_usage.
+func ExtractOpenAIMetadata(chunk map[string]interface{}, meta *Metadata) {
+	if model, ok := chunk["model"].(string); ok && model != "" {
+		meta.Model = model
+	}
+	if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]interface{}); ok {
+			if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+				meta.FinishReason = reason
+			}
+		}
+	}
+	if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+		meta.Usage = &Usage{
+			PromptTokens:     intValue(usage["prompt_tokens"]),
+			CompletionTokens: intValue(usage["completion_tokens"]),
+			TotalTokens:      intValue(usage["total_tokens"]),
+		}
+	}
+}
+
+// ExtractAnthropicMetadata records the stop reason, usage and model from an
+// Anthropic streaming event. Input tokens arrive with message_start and
+// output tokens with message_delta.
+func ExtractAnthropicMetadata(chunk map[string]interface{}, meta *Metadata) {
+	addUsage := func(usage map[string]interface{}) {
+		if meta.Usage == nil {
+			meta.Usage = &Usage{}
+		}
+		if tokens, ok := usage["input_tokens"]; ok {
+			meta.Usage.PromptTokens = intValue(tokens)
+		}
+		if tokens, ok := usage["output_tokens"]; ok {
+			meta.Usage.CompletionTokens = intValue(tokens)
+		}
+		meta.Usage.TotalTokens = meta.Usage.PromptTokens + meta.Usage.CompletionTokens
+	}
+
+	switch chunk["type"] {
+	case "message_start":
+		message, ok := chunk["message"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		if model, ok := message["model"].(string); ok {
+			meta.Model = model
+		}
+		if usage, ok := message["usage"].(map[string]interface{}); ok {
+			addUsage(usage)
+		}
+	case "message_delta":
+		if delta, ok := chunk["delta"].(map[string]interface{}); ok {
+			if reason, ok := delta["stop_reason"].(string); ok && reason != "" {
+				meta.FinishReason = reason
+			}
+		}
+		if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+			addUsage(usage)
+		}
+	}
+}
+
+// intValue converts a decoded JSON number to an int.
+func intValue(v interface{}) int {
+	if n, ok := v.(float64); ok {
+		return int(n)
+	}
+	return 0
+}
+
 // extractAnthropicContent extracts content from Anthropic streaming format.
 func extractAnthropicContent(chunk map[string]interface{}) string {
 	eventType, ok := chunk["type"].(string)
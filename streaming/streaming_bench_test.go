@@ -0,0 +1,75 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// discardFlusher is an http.ResponseWriter and http.Flusher that drops output,
+// so benchmarks measure the stream pipeline rather than buffer growth.
+type discardFlusher struct {
+	header http.Header
+}
+
+func (d *discardFlusher) Header() http.Header {
+	if d.header == nil {
+		d.header = make(http.Header)
+	}
+	return d.header
+}
+
+func (d *discardFlusher) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardFlusher) WriteHeader(int)             {}
+func (d *discardFlusher) Flush()                      {}
+
+func BenchmarkStreamProcessor_ProcessChannel(b *testing.B) {
+	const chunks = 100
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(chunks * len("token ")))
+	for i := 0; i < b.N; i++ {
+		handler, err := NewStreamHandler(&discardFlusher{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		ch := make(chan string, chunks)
+		for j := 0; j < chunks; j++ {
+			ch <- "token "
+		}
+		close(ch)
+
+		if err := NewStreamProcessor("bench", handler).ProcessChannel(ctx, ch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamProcessor_ProcessOpenAIStream(b *testing.B) {
+	var body strings.Builder
+	for j := 0; j < 100; j++ {
+		fmt.Fprintf(&body, "data: {\"choices\":[{\"delta\":{\"content\":\"token %d \"}}]}\n\n", j)
+	}
+	body.WriteString("data: [DONE]\n\n")
+	payload := body.String()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	for i := 0; i < b.N; i++ {
+		handler, err := NewStreamHandler(&discardFlusher{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		response := &http.Response{Body: io.NopCloser(strings.NewReader(payload))}
+		if err := NewStreamProcessor("bench", handler).ProcessOpenAIStream(ctx, response); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
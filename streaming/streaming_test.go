@@ -2,12 +2,16 @@ package streaming
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"go.rumenx.com/chatbot/chaterrors"
 )
 
 func TestNewStreamHandler(t *testing.T) {
@@ -373,6 +377,47 @@ func (w *nonFlusherWriter) WriteHeader(statusCode int) {
 	// No-op for testing
 }
 
+// brokenWriter fails every write, like the connection of a client that went
+// away.
+type brokenWriter struct {
+	header http.Header
+	writes int
+}
+
+func (w *brokenWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *brokenWriter) Write(data []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func (w *brokenWriter) WriteHeader(statusCode int) {}
+
+func (w *brokenWriter) Flush() {}
+
+func TestStreamHandler_ClientDisconnected(t *testing.T) {
+	w := &brokenWriter{header: make(http.Header)}
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+
+	err = handler.WriteChunk(StreamResponse{ID: "req", Content: "Hi"})
+	if !errors.Is(err, ErrClientDisconnected) || !errors.Is(handler.Err(), ErrClientDisconnected) {
+		t.Fatalf("expected ErrClientDisconnected, got %v", err)
+	}
+
+	// Later writes fail without writing
+	if err := handler.WriteDone("req"); !errors.Is(err, ErrClientDisconnected) {
+		t.Errorf("expected ErrClientDisconnected, got %v", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected a single write, got %d", w.writes)
+	}
+}
+
 func TestExtractAnthropicContent(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -630,3 +675,288 @@ func TestStreamingClient_MakeStreamingRequest_WithValidRequest(t *testing.T) {
 		resp.Body.Close()
 	}
 }
+
+func TestStreamProcessor_ProcessChannelMetadata(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+
+	meta := &Metadata{Model: "test-model"}
+	ch := make(chan string)
+	go func() {
+		ch <- "Hi"
+		// Filled in by the model before it closes the channel
+		meta.FinishReason = "stop"
+		meta.Usage = &Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4}
+		close(ch)
+	}()
+
+	processor := NewStreamProcessor("req", handler)
+	processor.SetMetadata(meta)
+	if err := processor.ProcessChannel(context.Background(), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `data: {"id":"req","content":"","done":true,"finish_reason":"stop","usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4},"model":"test-model"}`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected the done chunk %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestStreamProcessor_ProcessChannelFailure(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+
+	meta := &Metadata{}
+	ch := make(chan string)
+	go func() {
+		ch <- "Hel"
+		meta.Fail(chaterrors.Mark(errors.New("connection reset"), chaterrors.ErrProviderUnavailable))
+		close(ch)
+	}()
+
+	processor := NewStreamProcessor("req", handler)
+	processor.SetMetadata(meta)
+	if err := processor.ProcessChannel(context.Background(), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, `"content":"Hel"`) {
+		t.Errorf("expected the content sent before the failure, got %s", body)
+	}
+	want := `data: {"id":"req","content":"","done":true,"error":"AI provider unavailable","code":"provider_unavailable"}`
+	if !strings.Contains(body, want) {
+		t.Errorf("expected the error chunk %s, got %s", want, body)
+	}
+	if strings.Count(body, `"done":true`) != 1 {
+		t.Errorf("expected the error chunk to end the stream, got %s", body)
+	}
+}
+
+func TestStreamProcessor_ProcessChannelThinking(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+	handler.SetMode(ModeCumulative)
+
+	thinking := make(chan string)
+	ch := make(chan string)
+	ctx := NewThinkingContext(context.Background(), thinking)
+	go func() {
+		// What a reasoning model does with the context it is given
+		out, ok := ThinkingFromContext(ctx)
+		if !ok {
+			t.Error("expected the thinking channel in the context")
+		}
+		out <- "Let me think."
+		ch <- "Answer"
+		close(ch)
+	}()
+
+	processor := NewStreamProcessor("req", handler)
+	processor.SetThinking(thinking)
+	if err := processor.ProcessChannel(context.Background(), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := w.Body.String()
+	thinkingChunk := `data: {"id":"req","content":"","done":false,"thinking":"Let me think."}`
+	answerChunk := `data: {"id":"req","content":"Answer","done":false}`
+	if !strings.Contains(body, thinkingChunk) || !strings.Contains(body, answerChunk) {
+		t.Fatalf("expected separate thinking and answer chunks, got %s", body)
+	}
+	if strings.Index(body, thinkingChunk) > strings.Index(body, answerChunk) {
+		t.Errorf("expected the thinking before the answer, got %s", body)
+	}
+}
+
+func TestStreamProcessor_ProviderMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		process func(*StreamProcessor, context.Context, *http.Response) error
+		want    string
+	}{
+		{
+			name: "openai",
+			body: `data: {"model":"gpt-4o","choices":[{"delta":{"content":"Hi"}}]}
+
+data: {"model":"gpt-4o","choices":[{"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}
+
+data: [DONE]
+`,
+			process: (*StreamProcessor).ProcessOpenAIStream,
+			want:    `"finish_reason":"stop","usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6},"model":"gpt-4o"`,
+		},
+		{
+			name: "anthropic",
+			body: `data: {"type":"message_start","message":{"model":"claude-sonnet-4-5","usage":{"input_tokens":10,"output_tokens":1}}}
+data: {"type":"content_block_delta","delta":{"text":"Hi"}}
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}
+`,
+			process: (*StreamProcessor).ProcessAnthropicStream,
+			want:    `"finish_reason":"end_turn","usage":{"prompt_tokens":10,"completion_tokens":7,"total_tokens":17},"model":"claude-sonnet-4-5"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler, err := NewStreamHandler(w)
+			if err != nil {
+				t.Fatalf("failed to create stream handler: %v", err)
+			}
+			response := &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(tt.body))}
+			if err := tt.process(NewStreamProcessor("req", handler), context.Background(), response); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %s in %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := map[string]Mode{"": ModeDelta, "delta": ModeDelta, " Cumulative ": ModeCumulative}
+	for input, want := range tests {
+		if got, err := ParseMode(input); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseMode("full"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestStreamHandler_CumulativeMode(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+	handler.SetMode(ModeCumulative)
+
+	for _, delta := range []string{"Once", " upon", " a time"} {
+		if err := handler.WriteChunk(StreamResponse{ID: "s", Content: delta}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := handler.WriteDone("s"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`"content":"Once upon","done":false`,
+		`"content":"Once upon a time","done":true`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in %s", want, body)
+		}
+	}
+}
+
+func TestStreamHandler_EventNames(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+	handler.SetFormat(Format{Events: EventNames{Chunk: "delta", Done: "done"}})
+
+	_ = handler.WriteChunk(StreamResponse{ID: "s", Content: "Hi"})
+	_ = handler.WriteError("s", "boom")
+	_ = handler.WriteDone("s")
+
+	want := "event: delta\ndata: {\"id\":\"s\",\"content\":\"Hi\",\"done\":false}\n\n" +
+		"data: {\"id\":\"s\",\"content\":\"\",\"done\":true,\"error\":\"boom\"}\n\n" +
+		"event: done\ndata: {\"id\":\"s\",\"content\":\"\",\"done\":true}\n\n"
+	if w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestStreamHandler_Transform(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+	handler.SetFormat(Format{
+		Events: EventNames{Chunk: "token"},
+		Transform: func(chunk StreamResponse) ([]Event, error) {
+			if chunk.Done {
+				return nil, nil
+			}
+			return []Event{{Data: chunk.Content}, {Name: "debug", Data: map[string]int{"length": len(chunk.Content)}}}, nil
+		},
+	})
+
+	_ = handler.WriteChunk(StreamResponse{ID: "s", Content: "two\nlines"})
+	_ = handler.WriteDone("s")
+
+	want := "event: token\ndata: two\ndata: lines\n\nevent: debug\ndata: {\"length\":9}\n\n"
+	if w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestOpenAIFormat(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler, err := NewStreamHandler(w)
+	if err != nil {
+		t.Fatalf("failed to create stream handler: %v", err)
+	}
+	handler.SetFormat(OpenAIFormat("gpt-4o"))
+
+	_ = handler.WriteChunk(StreamResponse{ID: "abc", Thinking: "Hmm"})
+	_ = handler.WriteChunk(StreamResponse{ID: "abc", Content: "Hi"})
+	_ = handler.WriteDoneMetadata("abc", &Metadata{FinishReason: "length", Usage: &Usage{TotalTokens: 5}})
+
+	var events []map[string]interface{}
+	var done bool
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 3 || !done {
+		t.Fatalf("expected three chunks and [DONE], got %s", w.Body.String())
+	}
+	reasoning := events[0]["choices"].([]interface{})[0].(map[string]interface{})
+	if reasoning["delta"].(map[string]interface{})["reasoning_content"] != "Hmm" {
+		t.Errorf("unexpected reasoning choice: %v", reasoning)
+	}
+	events = events[1:]
+	first := events[0]["choices"].([]interface{})[0].(map[string]interface{})
+	if events[0]["object"] != "chat.completion.chunk" || events[0]["model"] != "gpt-4o" || events[0]["id"] != "chatcmpl-abc" {
+		t.Errorf("unexpected chunk: %v", events[0])
+	}
+	if first["delta"].(map[string]interface{})["content"] != "Hi" || first["finish_reason"] != nil {
+		t.Errorf("unexpected choice: %v", first)
+	}
+	last := events[1]["choices"].([]interface{})[0].(map[string]interface{})
+	if last["finish_reason"] != "length" || events[1]["usage"].(map[string]interface{})["total_tokens"] != float64(5) {
+		t.Errorf("unexpected final chunk: %v", events[1])
+	}
+}
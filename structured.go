@@ -0,0 +1,150 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.rumenx.com/chatbot/chaterrors"
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/schema"
+)
+
+// defaultStructuredRepairs is how many times AskStructured asks the model
+// to fix an invalid reply by default.
+const defaultStructuredRepairs = 2
+
+// WithStructuredRepairs sets how many times AskStructured sends the
+// validation problems of a reply back to the model before failing (default
+// 2). A negative value disables repairs.
+func WithStructuredRepairs(repairs int) Option {
+	return func(c *Chatbot) {
+		c.structuredRepairs = &repairs
+	}
+}
+
+// RepairStats counts validated model outputs and their repairs.
+type RepairStats struct {
+	// Structured counts the replies of AskStructured, and ToolArguments
+	// the tool calls of RunAgent.
+	Structured    schema.Stats `json:"structured"`
+	ToolArguments schema.Stats `json:"tool_arguments"`
+}
+
+// RepairStats returns how often structured replies and tool arguments did
+// not match their schema and had to be repaired.
+func (c *Chatbot) RepairStats() RepairStats {
+	return RepairStats{
+		Structured:    c.structuredStats.Stats(),
+		ToolArguments: c.toolArgumentStats.Stats(),
+	}
+}
+
+// AskStructured asks the model for a JSON reply matching the JSON Schema
+// jsonSchema and decodes it into out. Replies that do not match are sent
+// back to the model with the validation problems, up to the number of
+// repairs set with WithStructuredRepairs; after that the error matches
+// schema.ErrInvalid. The message goes through rate limiting and message
+// filtering like Ask, but is not stored in the conversation.
+func (c *Chatbot) AskStructured(ctx context.Context, message string, jsonSchema map[string]interface{}, out interface{}, options ...AskOption) error {
+	if message == "" {
+		return fmt.Errorf("%w: message cannot be empty", chaterrors.ErrInvalidInput)
+	}
+	encodedSchema, err := json.Marshal(jsonSchema)
+	if err != nil {
+		return fmt.Errorf("%w: invalid schema: %v", chaterrors.ErrInvalidInput, err)
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// Turn new requests away during maintenance
+	if err := c.checkMaintenance(ctx); err != nil {
+		return err
+	}
+
+	// Parse options, identifying the user for rate limiting
+	askOpts := newAskOptions(options)
+	ctx = identify(ctx, askOpts.context)
+	ctx = c.scopeRateLimit(ctx, config.RateLimitRouteChat, askOpts.context)
+
+	// Apply rate limiting
+	if c.rateLimit != nil {
+		if err := c.rateLimit.Allow(ctx); err != nil {
+			return fmt.Errorf("rate limit exceeded: %w", err)
+		}
+	}
+
+	// Apply message filtering
+	filtered, err := c.filter.Handle(ctx, message)
+	if err != nil {
+		return fmt.Errorf("message filtering failed: %w", err)
+	}
+	askOpts.addFilterContext(filtered)
+	c.applyDefaults(askOpts)
+	if err := c.renderPrompt(askOpts); err != nil {
+		return err
+	}
+	c.publishReceived(ctx, message, filtered, askOpts.context)
+
+	prompt, _ := askOpts.context["prompt"].(string)
+	askOpts.context["prompt"] = structuredPrompt(prompt, encodedSchema)
+
+	maxRepairs := defaultStructuredRepairs
+	if c.structuredRepairs != nil {
+		maxRepairs = max(0, *c.structuredRepairs)
+	}
+	query := filtered.Message
+	for repairs := 0; ; repairs++ {
+		reply, err := c.model.Ask(ctx, query, askOpts.context)
+		if err != nil {
+			return fmt.Errorf("AI model request failed: %w", err)
+		}
+		data := []byte(schema.ExtractJSON(reply))
+		err = schema.ValidateJSON(jsonSchema, data)
+		if err == nil {
+			c.structuredStats.Record(repairs, true)
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("failed to decode structured reply: %w", err)
+			}
+			return nil
+		}
+		if repairs == maxRepairs {
+			c.structuredStats.Record(repairs, false)
+			return fmt.Errorf("structured reply after %d repairs: %w", repairs, err)
+		}
+		query = repairMessage(filtered.Message, reply, err)
+	}
+}
+
+// structuredPrompt adds the instructions for a JSON reply to the system
+// prompt.
+func structuredPrompt(prompt string, encodedSchema []byte) string {
+	instructions := "Reply only with JSON matching this JSON Schema, without any other text:\n" + string(encodedSchema)
+	if prompt == "" {
+		return instructions
+	}
+	return prompt + "\n\n" + instructions
+}
+
+// repairMessage asks the model to fix its reply to message.
+func repairMessage(message, reply string, err error) string {
+	problems := []string{err.Error()}
+	var invalid *schema.ValidationError
+	if errors.As(err, &invalid) {
+		problems = invalid.Problems
+	}
+	var b strings.Builder
+	b.WriteString(message)
+	b.WriteString("\n\nYour previous reply was:\n")
+	b.WriteString(reply)
+	b.WriteString("\n\nIt does not match the JSON Schema:\n- ")
+	b.WriteString(strings.Join(problems, "\n- "))
+	b.WriteString("\n\nReply again with only the corrected JSON.")
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package gochatbot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/schema"
+)
+
+// repairedModel returns its replies in order and records the messages.
+type repairedModel struct {
+	scriptedModel
+	messages []string
+}
+
+func (m *repairedModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.messages = append(m.messages, message)
+	return m.scriptedModel.Ask(ctx, message, context)
+}
+
+var ticketSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"category": map[string]interface{}{"enum": []string{"billing", "shipping"}},
+		"urgent":   map[string]interface{}{"type": "boolean"},
+	},
+	"required": []string{"category", "urgent"},
+}
+
+func TestAskStructured(t *testing.T) {
+	model := &repairedModel{scriptedModel: scriptedModel{replies: []string{
+		`Sure! {"category":"delivery"}`,
+		"```json\n{\"category\":\"shipping\",\"urgent\":true}\n```",
+	}}}
+	cfg := config.Default()
+	cfg.Prompt = "You triage support tickets."
+	bot, err := New(cfg, WithModel(model))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	var ticket struct {
+		Category string `json:"category"`
+		Urgent   bool   `json:"urgent"`
+	}
+	if err := bot.AskStructured(context.Background(), "My parcel is lost!", ticketSchema, &ticket); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ticket.Category != "shipping" || !ticket.Urgent {
+		t.Errorf("unexpected ticket %+v", ticket)
+	}
+
+	if !strings.HasPrefix(model.prompts[0], "You triage support tickets.") || !strings.Contains(model.prompts[0], `"required":["category","urgent"]`) {
+		t.Errorf("expected the schema in the system prompt: %q", model.prompts[0])
+	}
+	repair := model.messages[1]
+	if !strings.HasPrefix(repair, "My parcel is lost!") || !strings.Contains(repair, `Sure! {"category":"delivery"}`) ||
+		!strings.Contains(repair, "- $.category: must be one of") || !strings.Contains(repair, "- $.urgent: is required") {
+		t.Errorf("expected the problems in the repair message: %q", repair)
+	}
+	if stats := bot.RepairStats().Structured; stats != (schema.Stats{Outputs: 1, Repairs: 1, Repaired: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAskStructuredRepairsRunOut(t *testing.T) {
+	model := &repairedModel{scriptedModel: scriptedModel{replies: []string{`{}`, `{}`}}}
+	bot, err := New(config.Default(), WithModel(model), WithStructuredRepairs(1))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	var ticket map[string]interface{}
+	err = bot.AskStructured(context.Background(), "Refund me", ticketSchema, &ticket)
+	if !errors.Is(err, schema.ErrInvalid) || len(model.messages) != 2 || ticket != nil {
+		t.Errorf("expected failure after one repair, got %v after %d requests", err, len(model.messages))
+	}
+	if stats := bot.RepairStats().Structured; stats.Failed != 1 || stats.RepairRate() != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if err := bot.AskStructured(context.Background(), "", ticketSchema, &ticket); err == nil {
+		t.Error("expected error for empty message")
+	}
+}
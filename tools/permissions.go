@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+// ErrPermissionDenied is matched by the errors of tool calls the caller may
+// not make. It matches chaterrors.ErrForbidden.
+var ErrPermissionDenied = chaterrors.Mark(errors.New("permission denied"), chaterrors.ErrForbidden)
+
+// Caller identifies who a tool call is made for.
+type Caller struct {
+	// Persona is the persona the chatbot answers as.
+	Persona string
+	// Role is the role of the user, such as "agent" or "admin".
+	Role string
+	// APIKey identifies the API key of the client; use a key ID rather
+	// than the secret.
+	APIKey string
+	// UserID and ConversationID are recorded with denials.
+	UserID         string
+	ConversationID string
+}
+
+// String describes the caller in denials.
+func (c Caller) String() string {
+	var parts []string
+	for _, part := range [][2]string{{"persona", c.Persona}, {"role", c.Role}, {"API key", c.APIKey}, {"user", c.UserID}} {
+		if part[1] != "" {
+			parts = append(parts, fmt.Sprintf("%s %q", part[0], part[1]))
+		}
+	}
+	if len(parts) == 0 {
+		return "anonymous caller"
+	}
+	return strings.Join(parts, ", ")
+}
+
+type callerContextKey struct{}
+
+// WithCaller returns a context whose tool calls are made for caller, for
+// authentication middleware to set the role or API key of a request.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, caller)
+}
+
+// CallerFromContext returns the caller set with WithCaller.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerContextKey{}).(Caller)
+	return caller, ok
+}
+
+// Authorizer decides which tools a caller may call.
+type Authorizer interface {
+	// Authorize returns nil if caller may call the tool, or an error
+	// matching ErrPermissionDenied.
+	Authorize(ctx context.Context, caller Caller, tool string) error
+}
+
+// AuthorizerFunc adapts a function to Authorizer.
+type AuthorizerFunc func(ctx context.Context, caller Caller, tool string) error
+
+// Authorize implements Authorizer.
+func (f AuthorizerFunc) Authorize(ctx context.Context, caller Caller, tool string) error {
+	return f(ctx, caller, tool)
+}
+
+// DenialHandler is told about denied tool calls, for example to write them
+// to an audit log.
+type DenialHandler func(ctx context.Context, caller Caller, tool string, err error)
+
+// Permissions grants tools by persona, role and API key. Each lists the
+// tool names allowed, or "*" for all. A caller matching entries of several
+// kinds may only call the tools all of them allow; a caller matching none
+// may call the Default tools. The zero value denies everything.
+//
+//	registry.SetAuthorizer(tools.Permissions{
+//		Personas: map[string][]string{"sales": {"product_search", "order_status"}},
+//		Roles:    map[string][]string{"customer": {"order_status"}, "staff": {"*"}},
+//	}, nil)
+type Permissions struct {
+	Personas map[string][]string `json:"personas" yaml:"personas"`
+	Roles    map[string][]string `json:"roles" yaml:"roles"`
+	APIKeys  map[string][]string `json:"api_keys" yaml:"api_keys"`
+	Default  []string            `json:"default" yaml:"default"`
+}
+
+// Authorize implements Authorizer.
+func (p Permissions) Authorize(ctx context.Context, caller Caller, tool string) error {
+	matched := false
+	for _, grant := range []struct {
+		kind    string
+		grants  map[string][]string
+		subject string
+	}{
+		{"persona", p.Personas, caller.Persona},
+		{"role", p.Roles, caller.Role},
+		{"API key", p.APIKeys, caller.APIKey},
+	} {
+		if grant.subject == "" {
+			continue
+		}
+		allowed, ok := grant.grants[grant.subject]
+		if !ok {
+			continue
+		}
+		matched = true
+		if !grants(allowed, tool) {
+			return fmt.Errorf("%w: %s %q may not call tool %q", ErrPermissionDenied, grant.kind, grant.subject, tool)
+		}
+	}
+	if !matched && !grants(p.Default, tool) {
+		return fmt.Errorf("%w: %s may not call tool %q", ErrPermissionDenied, caller, tool)
+	}
+	return nil
+}
+
+// grants reports whether allowed names tool or holds "*".
+func grants(allowed []string, tool string) bool {
+	for _, name := range allowed {
+		if name == tool || name == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizedTool checks calls of a registry tool with the registry's
+// authorizer.
+type authorizedTool struct {
+	Tool
+	authorizer Authorizer
+	denied     DenialHandler
+}
+
+func (t *authorizedTool) Call(ctx context.Context, args json.RawMessage) (string, error) {
+	caller, _ := CallerFromContext(ctx)
+	if err := t.authorizer.Authorize(ctx, caller, t.Name()); err != nil {
+		if t.denied != nil {
+			t.denied(ctx, caller, t.Name(), err)
+		}
+		return "", err
+	}
+	return t.Tool.Call(ctx, args)
+}
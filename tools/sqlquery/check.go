@@ -0,0 +1,169 @@
+package sqlquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrNotReadOnly is returned for queries that are not a single SELECT
+// statement.
+var ErrNotReadOnly = errors.New("only a single read-only SELECT statement is allowed")
+
+// forbidden are keywords and functions that write, change settings or
+// reach outside the database. They are refused anywhere in a query, even
+// where they would be harmless, such as FOR UPDATE.
+var forbidden = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true, "REPLACE": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "RENAME": true, "COMMENT": true,
+	"GRANT": true, "REVOKE": true, "INTO": true, "COPY": true, "CALL": true, "EXEC": true, "EXECUTE": true,
+	"DO": true, "SET": true, "RESET": true, "LOCK": true, "VACUUM": true, "ANALYZE": true, "REINDEX": true,
+	"ATTACH": true, "DETACH": true, "PRAGMA": true, "LOAD": true, "HANDLER": true, "LISTEN": true, "NOTIFY": true,
+	"BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SAVEPOINT": true, "PREPARE": true, "DEALLOCATE": true,
+	// Functions with side effects or file access
+	"PG_SLEEP": true, "PG_READ_FILE": true, "PG_READ_BINARY_FILE": true, "PG_LS_DIR": true, "PG_STAT_FILE": true,
+	"PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true, "PG_RELOAD_CONF": true, "SET_CONFIG": true,
+	"LO_IMPORT": true, "LO_EXPORT": true, "DBLINK": true, "DBLINK_EXEC": true, "NEXTVAL": true, "SETVAL": true,
+	"LOAD_EXTENSION": true, "READFILE": true, "WRITEFILE": true, "FTS3_TOKENIZER": true,
+	"SLEEP": true, "BENCHMARK": true, "LOAD_FILE": true, "OUTFILE": true, "DUMPFILE": true,
+}
+
+// Check returns an error matching ErrNotReadOnly unless query is a single
+// SELECT statement (or a WITH query selecting rows) without keywords or
+// functions that could write or reach outside the database.
+func Check(query string) error {
+	words, err := keywords(query)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("%w: the query is empty", ErrNotReadOnly)
+	}
+	if words[0] != "SELECT" && words[0] != "WITH" {
+		return fmt.Errorf("%w: the query starts with %s", ErrNotReadOnly, words[0])
+	}
+	for _, word := range words {
+		if forbidden[word] {
+			return fmt.Errorf("%w: %s is not allowed", ErrNotReadOnly, word)
+		}
+	}
+	return nil
+}
+
+// keywords returns the bare words of query in upper case, skipping string
+// literals, quoted identifiers and comments. It fails on a second
+// statement, an unterminated literal, and anything databases read
+// differently, such as backslashes in strings, so nothing can hide from
+// the check.
+func keywords(query string) ([]string, error) {
+	var words []string
+	ended := false // a semicolon ended the statement
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '-' && isComment(query[i:]):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return words, nil
+			}
+			i += end
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if strings.HasPrefix(query[i:], "/*!") || strings.HasPrefix(query[i:], "/*+") {
+				// MySQL runs the contents of these comments
+				return nil, fmt.Errorf("%w: executable comments are not allowed", ErrNotReadOnly)
+			}
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated comment", ErrNotReadOnly)
+			}
+			i += end + 4
+			continue
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		}
+
+		if ended {
+			return nil, fmt.Errorf("%w: found more than one statement", ErrNotReadOnly)
+		}
+		switch {
+		case c == ';':
+			ended = true
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			end, err := closing(query, i, c)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '#':
+			// MySQL reads the rest of the line as a comment, PostgreSQL
+			// as an operator, so it cannot be read either way safely
+			return nil, fmt.Errorf("%w: # is not allowed outside quotes", ErrNotReadOnly)
+		case c == '$' && dollarTag(query[i:]) != "":
+			// PostgreSQL dollar-quoted string
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string", ErrNotReadOnly)
+			}
+			i += len(tag) + end + len(tag)
+		case isWordStart(c):
+			start := i
+			for i < len(query) && isWordPart(query[i]) {
+				i++
+			}
+			words = append(words, strings.ToUpper(query[start:i]))
+		default:
+			i++
+		}
+	}
+	return words, nil
+}
+
+// closing returns the index after the literal or identifier quoted with
+// quote that starts at start. Doubled quotes are escapes.
+func closing(query string, start int, quote byte) (int, error) {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			return 0, fmt.Errorf("%w: backslashes in quotes are not allowed", ErrNotReadOnly)
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated string", ErrNotReadOnly)
+}
+
+// isComment reports whether s starts with a -- comment. MySQL needs white
+// space after the dashes, so anything else is read as code.
+func isComment(s string) bool {
+	return strings.HasPrefix(s, "-- ") || strings.HasPrefix(s, "--\t") || strings.HasPrefix(s, "--\n") || s == "--"
+}
+
+// dollarTag returns the $tag$ that starts s, or "".
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isWordPart(s[i]) {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
@@ -0,0 +1,77 @@
+package sqlquery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// introspect describes the tables of db, one per line with their columns,
+// such as "orders(id integer, total numeric)". Only tables are listed if
+// any are given.
+func introspect(ctx context.Context, db *sql.DB, driver string, tables []string) (string, error) {
+	var query string
+	switch driver {
+	case "postgres", "pgx":
+		query = `
+			SELECT CASE WHEN table_schema = 'public' THEN table_name ELSE table_schema || '.' || table_name END,
+				column_name, data_type
+			FROM information_schema.columns
+			WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+			ORDER BY table_schema, table_name, ordinal_position`
+	case "sqlite3", "sqlite":
+		query = `
+			SELECT m.name, p.name, p.type
+			FROM sqlite_master m JOIN pragma_table_info(m.name) p
+			WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%'
+			ORDER BY m.name, p.cid`
+	case "mysql":
+		query = `
+			SELECT table_name, column_name, data_type
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE()
+			ORDER BY table_name, ordinal_position`
+	default:
+		return "", fmt.Errorf("cannot introspect %q databases, describe them with WithSchema", driver)
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("failed to introspect schema: %w", err)
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		wanted[strings.ToLower(table)] = true
+	}
+
+	var order []string
+	columns := make(map[string][]string)
+	for rows.Next() {
+		var table, column, dataType string
+		if err := rows.Scan(&table, &column, &dataType); err != nil {
+			return "", fmt.Errorf("failed to read schema: %w", err)
+		}
+		if len(wanted) > 0 && !wanted[strings.ToLower(table)] {
+			continue
+		}
+		if _, ok := columns[table]; !ok {
+			order = append(order, table)
+		}
+		columns[table] = append(columns[table], strings.TrimSpace(column+" "+strings.ToLower(dataType)))
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+	if len(order) == 0 {
+		return "", fmt.Errorf("found no tables to describe")
+	}
+
+	var b strings.Builder
+	for _, table := range order {
+		fmt.Fprintf(&b, "%s(%s)\n", table, strings.Join(columns[table], ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,227 @@
+// Package sqlquery provides a tool that lets a model query a database for
+// "chat with your data" scenarios. The tool describes the database schema
+// to the model, only runs single SELECT statements, in a read-only
+// transaction, and limits the rows returned and the time a query may take.
+//
+// The checks are a second line of defence: connect with a database user
+// that only has read access to the tables the model may see.
+//
+//	db, err := sql.Open("postgres", os.Getenv("ANALYTICS_DSN"))
+//	query, err := sqlquery.New(ctx, db, "postgres", sqlquery.WithTables("orders", "customers"))
+//	result, err := bot.RunAgent(ctx, "How many orders shipped last week?", tools.NewRegistry(query))
+package sqlquery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.rumenx.com/chatbot/tools"
+)
+
+// maxCellLength is how many characters of a value are shown.
+const maxCellLength = 200
+
+// Option configures the tool.
+type Option func(*options)
+
+type options struct {
+	name    string
+	tables  []string
+	schema  string
+	maxRows int
+	timeout time.Duration
+}
+
+// WithName sets the tool name (default "sql_query"), for example to offer
+// several databases.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithTables limits the schema described to the model to the given tables.
+// It does not stop queries on other tables; database grants do.
+func WithTables(tables ...string) Option {
+	return func(o *options) {
+		o.tables = tables
+	}
+}
+
+// WithSchema describes the database to the model instead of introspecting
+// it, for example to explain what the columns mean.
+func WithSchema(schema string) Option {
+	return func(o *options) {
+		o.schema = schema
+	}
+}
+
+// WithMaxRows sets how many rows a query returns at most (default 50).
+func WithMaxRows(n int) Option {
+	return func(o *options) {
+		o.maxRows = n
+	}
+}
+
+// WithTimeout sets how long a query may run (default 5s).
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// New creates the SQL query tool for db. The driver is "postgres",
+// "sqlite3" or "mysql", like the database package's stores, and selects
+// the SQL used to introspect the schema, which is described to the model
+// in the tool description.
+func New(ctx context.Context, db *sql.DB, driver string, opts ...Option) (tools.Tool, error) {
+	o := options{name: "sql_query", maxRows: 50, timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if db == nil {
+		return nil, errors.New("database cannot be nil")
+	}
+
+	schema := o.schema
+	if schema == "" {
+		introspectCtx, cancel := context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+		var err error
+		if schema, err = introspect(introspectCtx, db, driver, o.tables); err != nil {
+			return nil, err
+		}
+	}
+
+	description := fmt.Sprintf("Runs one read-only SQL SELECT query on the %s database and returns up to %d rows. "+
+		"Only use the tables and columns below.\n%s", dialect(driver), o.maxRows, schema)
+	parameters := tools.Object(map[string]interface{}{
+		"query": tools.String("A single SELECT statement"),
+	}, "query")
+
+	return tools.New(o.name, description, parameters, func(ctx context.Context, args json.RawMessage) (string, error) {
+		var in struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(args, &in); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if err := Check(in.Query); err != nil {
+			return "", err
+		}
+		return run(ctx, db, in.Query, o)
+	}), nil
+}
+
+// dialect names the SQL dialect of driver for the model.
+func dialect(driver string) string {
+	switch driver {
+	case "postgres", "pgx":
+		return "PostgreSQL"
+	case "sqlite3", "sqlite":
+		return "SQLite"
+	case "mysql":
+		return "MySQL"
+	default:
+		return driver
+	}
+}
+
+// run executes query in a read-only transaction that is always rolled
+// back, and formats the rows as a table.
+func run(ctx context.Context, db *sql.DB, query string, o options) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, strings.TrimRight(strings.TrimSpace(query), ";"))
+	if err != nil {
+		return "", queryError(ctx, err, o.timeout)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(columns, " | "))
+	count, truncated := 0, false
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if count == o.maxRows {
+			truncated = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return "", fmt.Errorf("failed to read row: %w", err)
+		}
+		cells := make([]string, len(values))
+		for i, value := range values {
+			cells[i] = formatValue(value)
+		}
+		b.WriteString("\n")
+		b.WriteString(strings.Join(cells, " | "))
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", queryError(ctx, err, o.timeout)
+	}
+
+	switch {
+	case truncated:
+		fmt.Fprintf(&b, "\n(first %d rows shown; aggregate or add a LIMIT for the rest)", o.maxRows)
+	case count == 0:
+		b.WriteString("\n(no rows)")
+	default:
+		fmt.Fprintf(&b, "\n(%d rows)", count)
+	}
+	return b.String(), nil
+}
+
+// queryError reports a failed query, naming the time limit if it ran out.
+func queryError(ctx context.Context, err error, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query took longer than %s: %w", timeout, ctx.Err())
+	}
+	return fmt.Errorf("query failed: %w", err)
+}
+
+// formatValue formats a scanned value for a table cell.
+func formatValue(value interface{}) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if !utf8.Valid(v) {
+			return fmt.Sprintf("(%d bytes)", len(v))
+		}
+		text = string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		text = fmt.Sprint(v)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) > maxCellLength {
+		text = string([]rune(text)[:maxCellLength]) + "…"
+	}
+	return text
+}
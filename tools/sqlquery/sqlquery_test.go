@@ -0,0 +1,197 @@
+package sqlquery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver answers queries from a table of rows and records them with
+// the transaction options.
+type fakeDriver struct {
+	mutex    sync.Mutex
+	queries  []string
+	readOnly []bool
+}
+
+var fake = &fakeDriver{}
+
+func init() {
+	sql.Register("sqlquery_fake", fake)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+func (d *fakeDriver) record(query string, readOnly bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.queries = append(d.queries, query)
+	d.readOnly = append(d.readOnly, readOnly)
+}
+
+type fakeConn struct {
+	readOnly bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { c.readOnly = false; return nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.readOnly = opts.ReadOnly
+	return c, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	fake.record(query, c.readOnly)
+	switch {
+	case strings.Contains(query, "information_schema.columns"):
+		return &fakeRows{columns: []string{"table", "column", "type"}, rows: [][]driver.Value{
+			{"customers", "id", "integer"}, {"customers", "name", "text"},
+			{"orders", "id", "integer"}, {"orders", "total", "NUMERIC"},
+		}}, nil
+	case strings.Contains(query, "slow"):
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case strings.Contains(query, "missing"):
+		return nil, errors.New(`relation "missing" does not exist`)
+	}
+	rows := &fakeRows{columns: []string{"id", "name", "note", "created"}}
+	for i := 1; i <= 3; i++ {
+		rows.rows = append(rows.rows, []driver.Value{int64(i), []byte("Ada"), nil, time.Date(2026, 1, i, 0, 0, 0, 0, time.UTC)})
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newTool(t *testing.T, opts ...Option) (func(query string) (string, error), string) {
+	t.Helper()
+	db, err := sql.Open("sqlquery_fake", "")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tool, err := New(context.Background(), db, "postgres", opts...)
+	if err != nil {
+		t.Fatalf("failed to create tool: %v", err)
+	}
+	return func(query string) (string, error) {
+		args, _ := json.Marshal(map[string]string{"query": query})
+		return tool.Call(context.Background(), args)
+	}, tool.Description()
+}
+
+func TestTool(t *testing.T) {
+	query, description := newTool(t, WithTables("orders"), WithMaxRows(2), WithTimeout(50*time.Millisecond))
+	if !strings.Contains(description, "PostgreSQL database and returns up to 2 rows") ||
+		!strings.HasSuffix(description, "\norders(id integer, total numeric)") {
+		t.Errorf("unexpected description %q", description)
+	}
+
+	output, err := query("SELECT id, name, note, created FROM customers;")
+	want := "id | name | note | created\n1 | Ada | NULL | 2026-01-01T00:00:00Z\n2 | Ada | NULL | 2026-01-02T00:00:00Z\n" +
+		"(first 2 rows shown; aggregate or add a LIMIT for the rest)"
+	if err != nil || output != want {
+		t.Errorf("unexpected output %q, %v", output, err)
+	}
+	fake.mutex.Lock()
+	last, readOnly := fake.queries[len(fake.queries)-1], fake.readOnly[len(fake.readOnly)-1]
+	fake.mutex.Unlock()
+	if last != "SELECT id, name, note, created FROM customers" || !readOnly {
+		t.Errorf("expected the query to run read-only without the semicolon, got %q, %v", last, readOnly)
+	}
+
+	if _, err := query("DELETE FROM orders"); !errors.Is(err, ErrNotReadOnly) {
+		t.Errorf("expected writes to be refused, got %v", err)
+	}
+	if _, err := query("SELECT * FROM slow"); err == nil || !strings.Contains(err.Error(), "longer than 50ms") {
+		t.Errorf("expected the time limit, got %v", err)
+	}
+	if _, err := query("SELECT * FROM missing"); err == nil || !strings.Contains(err.Error(), `relation "missing" does not exist`) {
+		t.Errorf("expected the database error for the model, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, description := newTool(t, WithSchema("sales(region text, amount numeric) -- amounts in EUR"), WithName("sales"))
+	if !strings.HasSuffix(description, "\nsales(region text, amount numeric) -- amounts in EUR") {
+		t.Errorf("expected the given schema, got %q", description)
+	}
+
+	db, _ := sql.Open("sqlquery_fake", "")
+	defer db.Close()
+	if _, err := New(context.Background(), db, "oracle"); err == nil {
+		t.Error("expected an error for a driver that cannot be introspected")
+	}
+	if _, err := New(context.Background(), db, "postgres", WithTables("unknown")); err == nil {
+		t.Error("expected an error when no tables are found")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	allowed := []string{
+		"SELECT * FROM orders",
+		"select count(*) from orders where status = 'delete me';",
+		"WITH recent AS (SELECT * FROM orders) SELECT * FROM recent -- latest orders",
+		`SELECT "update" FROM t /* insert */`,
+		"SELECT $$DROP TABLE x$$, 'it''s'",
+		"SELECT 1--1",
+		"SELECT * FROM tags WHERE name = '#go'",
+	}
+	for _, query := range allowed {
+		if err := Check(query); err != nil {
+			t.Errorf("%q: unexpected error: %v", query, err)
+		}
+	}
+
+	refused := []string{
+		"",
+		"-- nothing",
+		"DELETE FROM orders",
+		"SELECT 1; DROP TABLE orders",
+		"SELECT * FROM orders FOR UPDATE",
+		"SELECT * INTO copy FROM orders",
+		"WITH gone AS (DELETE FROM orders RETURNING *) SELECT * FROM gone",
+		"SELECT pg_sleep(10)",
+		"SELECT pg_read_file('/etc/passwd')",
+		`SELECT 'a\' , (SELECT 1); DROP TABLE t; --'`,
+		"SELECT 1 /*! ; DROP TABLE t */",
+		"SELECT 'unterminated",
+		"EXPLAIN ANALYZE DELETE FROM orders",
+		"PRAGMA writable_schema = 1",
+		"SELECT * FROM users #'\nINTO OUTFILE '/tmp/x' -- '",
+	}
+	for _, query := range refused {
+		if err := Check(query); !errors.Is(err, ErrNotReadOnly) {
+			t.Errorf("%q: expected ErrNotReadOnly, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrStateTooLarge is returned by State.Set when the state would exceed
+// its size limit.
+var ErrStateTooLarge = errors.New("conversation state too large")
+
+// State is a conversation's key-value state, which tools read and write
+// across turns, such as a shopping cart or the progress of a form. Values
+// are stored as JSON. It is safe for concurrent use.
+//
+//	state, ok := tools.StateFromContext(ctx)
+//	var cart Cart
+//	if _, err := state.Get("cart", &cart); err != nil { ... }
+//	cart.Items = append(cart.Items, item)
+//	if err := state.Set("cart", cart); err != nil { ... }
+type State struct {
+	mutex   sync.Mutex
+	values  map[string]json.RawMessage
+	limit   int
+	changed bool
+}
+
+// NewState returns a state holding values, which may be nil, that may grow
+// to limit bytes of JSON; 0 means no limit.
+func NewState(values map[string]json.RawMessage, limit int) *State {
+	copied := make(map[string]json.RawMessage, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return &State{values: copied, limit: limit}
+}
+
+// Get decodes the value of key into v and reports whether it was set.
+func (s *State) Get(key string, v interface{}) (bool, error) {
+	s.mutex.Lock()
+	value, ok := s.values[key]
+	s.mutex.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return true, fmt.Errorf("failed to decode state %q: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores v as the value of key. It fails with ErrStateTooLarge,
+// leaving the state unchanged, if the state would exceed its limit.
+func (s *State) Set(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode state %q: %w", key, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	old, had := s.values[key]
+	s.values[key] = value
+	if size := s.size(); s.limit > 0 && size > s.limit {
+		if had {
+			s.values[key] = old
+		} else {
+			delete(s.values, key)
+		}
+		return fmt.Errorf("%w: setting %q would take %d bytes, limit %d", ErrStateTooLarge, key, size, s.limit)
+	}
+	s.changed = true
+	return nil
+}
+
+// Delete removes key.
+func (s *State) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Keys returns the keys set, sorted.
+func (s *State) Keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns a copy of the values, for storing the state.
+func (s *State) Values() map[string]json.RawMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	values := make(map[string]json.RawMessage, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values
+}
+
+// Changed reports whether a value was set or deleted since the state was
+// created.
+func (s *State) Changed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.changed
+}
+
+// size returns the size of the state encoded as a JSON object. The mutex
+// must be held.
+func (s *State) size() int {
+	size := 2 // {}
+	for key, value := range s.values {
+		encodedKey, _ := json.Marshal(key)
+		size += len(encodedKey) + 1 + len(value) + 1 // "key":value,
+	}
+	return size
+}
+
+type stateContextKey struct{}
+
+// WithState returns a context whose tool calls read and write state.
+func WithState(ctx context.Context, state *State) context.Context {
+	return context.WithValue(ctx, stateContextKey{}, state)
+}
+
+// StateFromContext returns the state set with WithState, which RunAgent
+// sets for the conversation of the caller.
+func StateFromContext(ctx context.Context) (*State, bool) {
+	state, ok := ctx.Value(stateContextKey{}).(*State)
+	return state, ok
+}
@@ -0,0 +1,191 @@
+// Package tools defines the tools a model can call, such as searching a
+// knowledge base or looking up an order, and a registry to hold them.
+//
+//	weather := tools.New("weather", "Current weather for a city",
+//		tools.Object(map[string]interface{}{"city": tools.String("City name")}, "city"),
+//		func(ctx context.Context, args json.RawMessage) (string, error) { ... })
+//	registry := tools.NewRegistry(weather)
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Tool is a capability a model can invoke.
+type Tool interface {
+	// Name is the identifier the model uses to call the tool.
+	Name() string
+
+	// Description tells the model what the tool does and when to use it.
+	Description() string
+
+	// Parameters is the JSON Schema of the tool's arguments.
+	Parameters() map[string]interface{}
+
+	// Call runs the tool with JSON arguments and returns its result as text.
+	Call(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Func is a function called with a tool's JSON arguments.
+type Func func(ctx context.Context, args json.RawMessage) (string, error)
+
+// funcTool is a Tool backed by a Func.
+type funcTool struct {
+	name        string
+	description string
+	parameters  map[string]interface{}
+	fn          Func
+}
+
+// New creates a tool from a function. A nil parameters schema means the
+// tool takes no arguments.
+func New(name, description string, parameters map[string]interface{}, fn Func) Tool {
+	if parameters == nil {
+		parameters = Object(nil)
+	}
+	return &funcTool{name: name, description: description, parameters: parameters, fn: fn}
+}
+
+func (t *funcTool) Name() string                       { return t.name }
+func (t *funcTool) Description() string                { return t.description }
+func (t *funcTool) Parameters() map[string]interface{} { return t.parameters }
+
+func (t *funcTool) Call(ctx context.Context, args json.RawMessage) (string, error) {
+	return t.fn(ctx, args)
+}
+
+// Object returns a JSON Schema object with the given properties.
+func Object(properties map[string]interface{}, required ...string) map[string]interface{} {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// String returns a JSON Schema string property.
+func String(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+// Number returns a JSON Schema number property.
+func Number(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "number", "description": description}
+}
+
+// Registry holds tools by name. It is safe for concurrent use.
+type Registry struct {
+	mutex      sync.RWMutex
+	tools      map[string]Tool
+	authorizer Authorizer
+	denied     DenialHandler
+}
+
+// NewRegistry creates a registry with the given tools. It panics on
+// duplicate names, like Register.
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{tools: make(map[string]Tool)}
+	for _, tool := range tools {
+		if err := r.Register(tool); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a tool. Names must be unique.
+func (r *Registry) Register(tool Tool) error {
+	if tool == nil || tool.Name() == "" {
+		return fmt.Errorf("tool must have a name")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.tools[tool.Name()]; exists {
+		return fmt.Errorf("tool %q is already registered", tool.Name())
+	}
+	r.tools[tool.Name()] = tool
+	return nil
+}
+
+// SetAuthorizer checks every call of the registry's tools with authorizer,
+// for the caller set on the call's context with WithCaller. Denied calls
+// fail with an error matching ErrPermissionDenied and are passed to denied,
+// if it is not nil. A nil authorizer allows every call again.
+func (r *Registry) SetAuthorizer(authorizer Authorizer, denied DenialHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.authorizer = authorizer
+	r.denied = denied
+}
+
+// Authorize returns the error a call of the named tool by the caller of ctx
+// would fail with, or nil if it is allowed. It does not report denials.
+func (r *Registry) Authorize(ctx context.Context, name string) error {
+	r.mutex.RLock()
+	authorizer := r.authorizer
+	r.mutex.RUnlock()
+
+	if authorizer == nil {
+		return nil
+	}
+	caller, _ := CallerFromContext(ctx)
+	return authorizer.Authorize(ctx, caller, name)
+}
+
+// Get returns the tool with the given name. With an authorizer set, its
+// calls are checked.
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tool, ok := r.tools[name]
+	if !ok {
+		return nil, false
+	}
+	return r.wrap(tool), true
+}
+
+// List returns all tools sorted by name. With an authorizer set, their
+// calls are checked.
+func (r *Registry) List() []Tool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		list = append(list, r.wrap(tool))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Permitted returns the tools the caller of ctx may call, sorted by name,
+// so they alone are offered to the model.
+func (r *Registry) Permitted(ctx context.Context) []Tool {
+	var permitted []Tool
+	for _, tool := range r.List() {
+		if r.Authorize(ctx, tool.Name()) == nil {
+			permitted = append(permitted, tool)
+		}
+	}
+	return permitted
+}
+
+// wrap returns tool checked by the registry's authorizer, if any. The
+// mutex must be held.
+func (r *Registry) wrap(tool Tool) Tool {
+	if r.authorizer == nil {
+		return tool
+	}
+	return &authorizedTool{Tool: tool, authorizer: r.authorizer, denied: r.denied}
+}
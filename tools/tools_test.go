@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chaterrors"
+)
+
+func echo() Tool {
+	return New("echo", "Repeats the text", Object(map[string]interface{}{"text": String("Text to repeat")}, "text"),
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", err
+			}
+			return in.Text, nil
+		})
+}
+
+func TestNew(t *testing.T) {
+	tool := echo()
+
+	out, err := tool.Call(context.Background(), json.RawMessage(`{"text":"hi"}`))
+	if err != nil || out != "hi" {
+		t.Errorf("unexpected call result %q, %v", out, err)
+	}
+	if tool.Parameters()["required"].([]string)[0] != "text" {
+		t.Errorf("unexpected schema: %v", tool.Parameters())
+	}
+
+	noArgs := New("now", "Current time", nil, nil)
+	if noArgs.Parameters()["type"] != "object" {
+		t.Errorf("expected empty object schema, got %v", noArgs.Parameters())
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(echo(), New("add", "Adds numbers", nil, nil))
+
+	if err := registry.Register(echo()); err == nil {
+		t.Error("expected duplicate registration to fail")
+	}
+	if _, ok := registry.Get("echo"); !ok {
+		t.Error("expected echo to be registered")
+	}
+	if _, ok := registry.Get("missing"); ok {
+		t.Error("expected missing tool not to be found")
+	}
+
+	list := registry.List()
+	if len(list) != 2 || list[0].Name() != "add" || list[1].Name() != "echo" {
+		t.Errorf("expected tools sorted by name, got %v", list)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected NewRegistry to panic on duplicates")
+		}
+	}()
+	NewRegistry(echo(), echo())
+}
+
+func TestPermissions(t *testing.T) {
+	permissions := Permissions{
+		Personas: map[string][]string{"sales": {"echo", "search"}},
+		Roles:    map[string][]string{"customer": {"echo"}, "staff": {"*"}},
+		APIKeys:  map[string][]string{"partner": {"search"}},
+		Default:  []string{"search"},
+	}
+	tests := []struct {
+		caller  Caller
+		tool    string
+		allowed bool
+	}{
+		{Caller{Role: "staff"}, "refund", true},
+		{Caller{Role: "customer"}, "echo", true},
+		{Caller{Role: "customer"}, "search", false},
+		// Every matching kind must allow the tool
+		{Caller{Persona: "sales", Role: "staff"}, "refund", false},
+		{Caller{Persona: "sales", Role: "customer"}, "echo", true},
+		{Caller{Role: "staff", APIKey: "partner"}, "echo", false},
+		// Callers no entry names get the default tools
+		{Caller{Role: "guest"}, "search", true},
+		{Caller{}, "echo", false},
+	}
+	for _, tt := range tests {
+		err := permissions.Authorize(context.Background(), tt.caller, tt.tool)
+		if (err == nil) != tt.allowed || (err != nil && !errors.Is(err, ErrPermissionDenied)) {
+			t.Errorf("%v calling %q: expected allowed=%v, got %v", tt.caller, tt.tool, tt.allowed, err)
+		}
+	}
+	if !errors.Is(permissions.Authorize(context.Background(), Caller{}, "echo"), chaterrors.ErrForbidden) {
+		t.Error("expected denials to match chaterrors.ErrForbidden")
+	}
+}
+
+func TestRegistryAuthorizer(t *testing.T) {
+	registry := NewRegistry(echo(), New("refund", "Refunds an order", nil,
+		func(ctx context.Context, args json.RawMessage) (string, error) { return "refunded", nil }))
+
+	var denials []string
+	registry.SetAuthorizer(Permissions{Roles: map[string][]string{"customer": {"echo"}}},
+		func(ctx context.Context, caller Caller, tool string, err error) {
+			denials = append(denials, caller.Role+":"+tool)
+		})
+
+	ctx := WithCaller(context.Background(), Caller{Role: "customer"})
+	if permitted := registry.Permitted(ctx); len(permitted) != 1 || permitted[0].Name() != "echo" {
+		t.Errorf("expected only echo to be permitted, got %v", permitted)
+	}
+	echoTool, _ := registry.Get("echo")
+	if out, err := echoTool.Call(ctx, json.RawMessage(`{"text":"hi"}`)); err != nil || out != "hi" {
+		t.Errorf("unexpected result %q, %v", out, err)
+	}
+	refund, _ := registry.Get("refund")
+	if _, err := refund.Call(ctx, nil); !errors.Is(err, ErrPermissionDenied) || !strings.Contains(err.Error(), `role "customer" may not call tool "refund"`) {
+		t.Errorf("expected the call to be denied, got %v", err)
+	}
+	if len(denials) != 1 || denials[0] != "customer:refund" {
+		t.Errorf("expected the denial to be reported, got %v", denials)
+	}
+
+	registry.SetAuthorizer(nil, nil)
+	refund, _ = registry.Get("refund")
+	if _, err := refund.Call(ctx, nil); err != nil || len(registry.Permitted(ctx)) != 2 {
+		t.Errorf("expected every call to be allowed without an authorizer, got %v", err)
+	}
+}
+
+func TestState(t *testing.T) {
+	state := NewState(map[string]json.RawMessage{"step": json.RawMessage(`2`)}, 40)
+	var step int
+	if ok, err := state.Get("step", &step); !ok || err != nil || step != 2 {
+		t.Errorf("unexpected step %d, %v, %v", step, ok, err)
+	}
+	if ok, _ := state.Get("cart", &step); ok || state.Changed() {
+		t.Error("expected no cart and no changes")
+	}
+
+	if err := state.Set("cart", []string{"book"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := state.Set("cart", []string{"book", "a very long item name"}); !errors.Is(err, ErrStateTooLarge) {
+		t.Errorf("expected the limit to be enforced, got %v", err)
+	}
+	var cart []string
+	if ok, err := state.Get("cart", &cart); !ok || err != nil || len(cart) != 1 {
+		t.Errorf("expected the cart to be unchanged, got %v, %v", cart, err)
+	}
+	if !state.Changed() || strings.Join(state.Keys(), ",") != "cart,step" {
+		t.Errorf("unexpected state %v", state.Values())
+	}
+
+	state.Delete("step")
+	if values := state.Values(); len(values) != 1 || string(values["cart"]) != `["book"]` {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	ctx := WithState(context.Background(), state)
+	if got, ok := StateFromContext(ctx); !ok || got != state {
+		t.Error("expected the state from the context")
+	}
+}
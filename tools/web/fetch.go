@@ -0,0 +1,231 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+
+	"go.rumenx.com/chatbot/tools"
+)
+
+// summarizePrompt asks the summarizer for a summary of a page.
+const summarizePrompt = "Summarize the web page the user sends in a few short paragraphs, keeping facts, figures and dates. " +
+	"If a question is given, focus on what the page says about it, and say so if it says nothing. Reply with the summary only."
+
+// skippedElements hold no readable page text.
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "head": true, "nav": true, "footer": true,
+}
+
+// lineElements start a new line in page text.
+var lineElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "blockquote": true, "table": true, "ul": true, "ol": true, "main": true,
+}
+
+// NewFetchTool creates the fetch_url tool, which downloads a page and
+// returns its title and text, or a summary with WithSummarizer. It only
+// fetches http and https URLs that robots.txt allows, downloads at most
+// WithMaxBytes and returns at most WithMaxLength characters.
+func NewFetchTool(opts ...Option) tools.Tool {
+	o := newOptions(opts)
+	cache := &robotsCache{}
+
+	// Check page redirects against robots.txt too
+	client := *o.httpClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		return cache.check(req.Context(), o, req.URL)
+	}
+	pageOpts := o
+	pageOpts.httpClient = &client
+
+	parameters := tools.Object(map[string]interface{}{
+		"url":      tools.String("The http or https URL of the page"),
+		"question": tools.String("What to look for on the page, if anything"),
+	}, "url")
+	description := "Fetches a web page and returns its text."
+	if o.summarizer != nil {
+		description = "Fetches a web page and returns a summary, focused on the question if one is given."
+	}
+
+	return tools.New("fetch_url", description, parameters,
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				URL      string `json:"url"`
+				Question string `json:"question"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			u, err := url.Parse(in.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "", fmt.Errorf("invalid URL %q: only http and https URLs can be fetched", in.URL)
+			}
+			if err := cache.check(ctx, o, u); err != nil {
+				return "", err
+			}
+
+			title, text, err := fetchPage(ctx, pageOpts, u)
+			if err != nil {
+				return "", err
+			}
+			if o.summarizer != nil {
+				if text, err = summarize(ctx, o, title, text, in.Question); err != nil {
+					return "", err
+				}
+			} else {
+				text = truncate(text, o.maxLength)
+			}
+
+			var b strings.Builder
+			if title != "" {
+				fmt.Fprintf(&b, "Title: %s\n", title)
+			}
+			fmt.Fprintf(&b, "URL: %s\n\n%s", u, text)
+			return b.String(), nil
+		})
+}
+
+// fetchPage downloads a page and returns its title and text. HTML is
+// reduced to its readable text; other text types are returned as is.
+func fetchPage(ctx context.Context, o options, u *url.URL) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", o.userAgent)
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9, */*;q=0.5")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", "", err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, o.maxBytes))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", u, err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		title, text := PageText(string(body))
+		return title, text, nil
+	case mediaType == "" || strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if !utf8.Valid(body) {
+			return "", "", fmt.Errorf("%s is not text", u)
+		}
+		return "", strings.TrimSpace(string(body)), nil
+	default:
+		return "", "", fmt.Errorf("%s is %s, not a web page", u, mediaType)
+	}
+}
+
+// PageText returns the title and readable text of an HTML page, skipping
+// scripts, styles and navigation.
+func PageText(page string) (string, string) {
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(page))
+	var title, text strings.Builder
+	skip := 0
+	inTitle := false
+	space := false // whether the last text ended with white space
+
+	newline := func() {
+		if text.Len() > 0 && !strings.HasSuffix(text.String(), "\n") {
+			text.WriteString("\n")
+		}
+	}
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == nethtml.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if token.Data == "title" {
+				inTitle = tokenType == nethtml.StartTagToken
+			} else if skippedElements[token.Data] && tokenType == nethtml.StartTagToken {
+				skip++
+			} else if lineElements[token.Data] {
+				newline()
+			}
+		case nethtml.EndTagToken:
+			if token.Data == "title" {
+				inTitle = false
+			} else if skippedElements[token.Data] && skip > 0 {
+				skip--
+			} else if lineElements[token.Data] {
+				newline()
+			}
+		case nethtml.TextToken:
+			words := strings.Join(strings.Fields(token.Data), " ")
+			switch {
+			case words == "":
+				space = true
+				continue
+			case inTitle:
+				title.WriteString(words)
+			case skip == 0:
+				separated := space || strings.TrimLeft(token.Data, " \t\r\n") != token.Data
+				if separated && text.Len() > 0 && !strings.HasSuffix(text.String(), "\n") {
+					text.WriteString(" ")
+				}
+				text.WriteString(words)
+			}
+			space = strings.TrimRight(token.Data, " \t\r\n") != token.Data
+		}
+	}
+	return strings.TrimSpace(title.String()), strings.TrimSpace(text.String())
+}
+
+// summarize asks the summarizer for a summary of a page's text.
+func summarize(ctx context.Context, o options, title, text, question string) (string, error) {
+	var message strings.Builder
+	if question != "" {
+		fmt.Fprintf(&message, "Question: %s\n\n", question)
+	}
+	if title != "" {
+		fmt.Fprintf(&message, "Title: %s\n\n", title)
+	}
+	// Leave room for the summary in the model's context
+	message.WriteString(truncate(text, 4*o.maxLength))
+
+	summary, err := o.summarizer.Ask(ctx, message.String(), map[string]interface{}{
+		"prompt":      summarizePrompt,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize page: %w", err)
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// truncate cuts text to at most n characters.
+func truncate(text string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n]) + "\n[truncated]"
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsTTL is how long a site's robots.txt is cached.
+const robotsTTL = time.Hour
+
+// robotsRule allows or disallows paths matching a pattern, which may use
+// the * wildcard and end with $.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robots holds the robots.txt rules that apply to the tools.
+type robots struct {
+	rules   []robotsRule
+	fetched time.Time
+}
+
+// parseRobots returns the rules of the groups for agent, or of the *
+// group if none names it.
+func parseRobots(body io.Reader, agent string) *robots {
+	agent = strings.ToLower(agent)
+	var own, all []robotsRule
+	var agents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, name := range agents {
+				switch {
+				case name == "*":
+					all = append(all, rule)
+				case strings.Contains(agent, name):
+					own = append(own, rule)
+				}
+			}
+		}
+	}
+
+	if own != nil {
+		return &robots{rules: own}
+	}
+	return &robots{rules: all}
+}
+
+// allowed reports whether path may be fetched. The longest matching rule
+// wins, and allow wins a tie.
+func (r *robots) allowed(path string) bool {
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allow, longest = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// matchRobots reports whether path starts with pattern, where * matches
+// any characters and a trailing $ anchors the end.
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(path)
+}
+
+// robotsCache fetches and caches robots.txt per site.
+type robotsCache struct {
+	mutex sync.Mutex
+	sites map[string]*robots
+}
+
+// check returns ErrDisallowed if robots.txt does not allow fetching u.
+func (c *robotsCache) check(ctx context.Context, o options, u *url.URL) error {
+	site := u.Scheme + "://" + u.Host
+	c.mutex.Lock()
+	rules, ok := c.sites[site]
+	c.mutex.Unlock()
+
+	if !ok || time.Since(rules.fetched) > robotsTTL {
+		var err error
+		if rules, err = fetchRobots(ctx, o, site); err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		if c.sites == nil {
+			c.sites = make(map[string]*robots)
+		}
+		c.sites[site] = rules
+		c.mutex.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !rules.allowed(path) {
+		return fmt.Errorf("%s: %w", u, ErrDisallowed)
+	}
+	return nil
+}
+
+// fetchRobots downloads the robots.txt of site. A missing file allows
+// everything; a server error disallows everything, as RFC 9309 asks.
+func fetchRobots(ctx context.Context, o options, site string) (*robots, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", o.userAgent)
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var rules *robots
+	switch {
+	case resp.StatusCode >= 500:
+		rules = &robots{rules: []robotsRule{{allow: false, pattern: "/"}}}
+	case resp.StatusCode >= 400:
+		rules = &robots{}
+	default:
+		rules = parseRobots(io.LimitReader(resp.Body, 500<<10), robotsAgent)
+	}
+	rules.fetched = time.Now()
+	return rules, nil
+}
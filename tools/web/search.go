@@ -0,0 +1,209 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.rumenx.com/chatbot/tools"
+)
+
+// Result is a web search result.
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchProvider searches the web.
+type SearchProvider interface {
+	// Search returns up to count results for query.
+	Search(ctx context.Context, query string, count int) ([]Result, error)
+}
+
+// SearchFunc adapts a function to a SearchProvider.
+type SearchFunc func(ctx context.Context, query string, count int) ([]Result, error)
+
+// Search implements SearchProvider.
+func (f SearchFunc) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	return f(ctx, query, count)
+}
+
+// NewSearchTool creates the web_search tool, which returns the title, URL
+// and snippet of the top results. Pair it with NewFetchTool so the model
+// can read the pages it finds.
+func NewSearchTool(provider SearchProvider, opts ...Option) tools.Tool {
+	o := newOptions(opts)
+	parameters := tools.Object(map[string]interface{}{
+		"query": tools.String("The search query"),
+		"count": map[string]interface{}{
+			"type":        "integer",
+			"description": fmt.Sprintf("How many results to return, at most %d", o.maxResults),
+			"minimum":     1,
+		},
+	}, "query")
+
+	return tools.New("web_search", "Searches the web for current information. Returns titles, URLs and snippets.", parameters,
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				Query string `json:"query"`
+				Count int    `json:"count"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if strings.TrimSpace(in.Query) == "" {
+				return "", fmt.Errorf("query cannot be empty")
+			}
+			count := o.maxResults
+			if in.Count > 0 {
+				count = min(in.Count, o.maxResults)
+			}
+
+			results, err := provider.Search(ctx, in.Query, count)
+			if err != nil {
+				return "", fmt.Errorf("search failed: %w", err)
+			}
+			return formatResults(results[:min(len(results), count)]), nil
+		})
+}
+
+// formatResults lists results for the model.
+func formatResults(results []Result) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+	var b strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, result.Title, result.URL)
+		if result.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", result.Snippet)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Bing searches with the Bing Web Search API.
+type Bing struct {
+	apiKey string
+	opts   options
+}
+
+// NewBing creates a Bing search provider authenticated with a subscription
+// key.
+func NewBing(apiKey string, opts ...Option) *Bing {
+	o := newOptions(opts)
+	if o.endpoint == "" {
+		o.endpoint = "https://api.bing.microsoft.com/v7.0/search"
+	}
+	return &Bing{apiKey: apiKey, opts: o}
+}
+
+// Search implements SearchProvider.
+func (b *Bing) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(count)}, "textFormat": {"Raw"}}
+	var response struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	header := http.Header{"Ocp-Apim-Subscription-Key": {b.apiKey}}
+	if err := getJSON(ctx, b.opts, b.opts.endpoint+"?"+params.Encode(), header, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(response.WebPages.Value))
+	for _, page := range response.WebPages.Value {
+		results = append(results, Result{Title: page.Name, URL: page.URL, Snippet: page.Snippet})
+	}
+	return results, nil
+}
+
+// Brave searches with the Brave Search API.
+type Brave struct {
+	apiKey string
+	opts   options
+}
+
+// NewBrave creates a Brave search provider authenticated with an API key.
+func NewBrave(apiKey string, opts ...Option) *Brave {
+	o := newOptions(opts)
+	if o.endpoint == "" {
+		o.endpoint = "https://api.search.brave.com/res/v1/web/search"
+	}
+	return &Brave{apiKey: apiKey, opts: o}
+}
+
+// Search implements SearchProvider.
+func (b *Brave) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(count)}, "text_decorations": {"false"}}
+	var response struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	header := http.Header{"X-Subscription-Token": {b.apiKey}}
+	if err := getJSON(ctx, b.opts, b.opts.endpoint+"?"+params.Encode(), header, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(response.Web.Results))
+	for _, result := range response.Web.Results {
+		results = append(results, Result{Title: result.Title, URL: result.URL, Snippet: result.Description})
+	}
+	return results, nil
+}
+
+// SearxNG searches with a SearxNG instance, which must have the JSON
+// format enabled in its settings.
+type SearxNG struct {
+	opts options
+}
+
+// NewSearxNG creates a SearxNG search provider for the instance at
+// baseURL, such as "https://searx.example.com". A self-hosted instance on
+// a private network needs WithPrivateNetworks(true).
+func NewSearxNG(baseURL string, opts ...Option) *SearxNG {
+	o := newOptions(opts)
+	if o.endpoint == "" {
+		o.endpoint = strings.TrimRight(baseURL, "/") + "/search"
+	}
+	return &SearxNG{opts: o}
+}
+
+// Search implements SearchProvider. SearxNG does not take a result count,
+// so the first count results are returned.
+func (s *SearxNG) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"q": {query}, "format": {"json"}}
+	var response struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, s.opts, s.opts.endpoint+"?"+params.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, min(len(response.Results), count))
+	for _, result := range response.Results[:min(len(response.Results), count)] {
+		results = append(results, Result{Title: result.Title, URL: result.URL, Snippet: result.Content})
+	}
+	return results, nil
+}
@@ -0,0 +1,201 @@
+// Package web provides optional tools that let agents ground answers in
+// live data: a web search tool backed by a pluggable SearchProvider (Bing,
+// Brave or SearxNG) and a URL fetch tool that respects robots.txt, limits
+// how much it downloads and can summarize pages with a model.
+//
+//	registry := tools.NewRegistry(
+//		web.NewSearchTool(web.NewBrave(os.Getenv("BRAVE_API_KEY"))),
+//		web.NewFetchTool(web.WithSummarizer(model)),
+//	)
+//	result, err := bot.RunAgent(ctx, "What changed in Go 1.25?", registry)
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// UserAgent identifies the tools to web servers and robots.txt.
+const UserAgent = "go-chatbot/1.0 (+https://go.rumenx.com/chatbot)"
+
+// robotsAgent is the product token matched against robots.txt groups.
+const robotsAgent = "go-chatbot"
+
+var (
+	// ErrDisallowed is returned for URLs robots.txt does not allow to fetch.
+	ErrDisallowed = errors.New("disallowed by robots.txt")
+
+	// ErrPrivateNetwork is returned for URLs on loopback, private or
+	// link-local addresses, unless WithPrivateNetworks allows them.
+	ErrPrivateNetwork = errors.New("address is not public")
+)
+
+// Option configures a search provider or tool. Options one does not use
+// are ignored.
+type Option func(*options)
+
+type options struct {
+	endpoint   string
+	httpClient *http.Client
+	userAgent  string
+	maxResults int
+	maxBytes   int64
+	maxLength  int
+	summarizer models.Model
+	private    bool
+}
+
+// WithEndpoint overrides the API endpoint of a search provider.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the HTTP client. Unlike the default client, a custom
+// one is used as is, without refusing private addresses.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithUserAgent sets the User-Agent header (default UserAgent).
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithMaxResults sets how many results the search tool returns at most
+// (default 5).
+func WithMaxResults(n int) Option {
+	return func(o *options) {
+		o.maxResults = n
+	}
+}
+
+// WithMaxBytes sets how much of a page the fetch tool downloads (default
+// 1 MiB). Longer pages are cut.
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithMaxLength sets how many characters of page text the fetch tool
+// returns (default 8000), to keep the model's context small.
+func WithMaxLength(n int) Option {
+	return func(o *options) {
+		o.maxLength = n
+	}
+}
+
+// WithSummarizer makes the fetch tool return a summary of the page written
+// by model instead of its text.
+func WithSummarizer(model models.Model) Option {
+	return func(o *options) {
+		o.summarizer = model
+	}
+}
+
+// WithPrivateNetworks lets the default client connect to loopback, private
+// and link-local addresses, for intranet pages or a self-hosted SearxNG.
+// They are refused by default, so a model cannot be steered into internal
+// services.
+func WithPrivateNetworks(allow bool) Option {
+	return func(o *options) {
+		o.private = allow
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		userAgent:  UserAgent,
+		maxResults: 5,
+		maxBytes:   1 << 20,
+		maxLength:  8000,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.httpClient == nil {
+		o.httpClient = defaultClient(o.private)
+	}
+	return o
+}
+
+// defaultClient returns an HTTP client that refuses private addresses
+// unless they are allowed. Proxies are not used, as they would connect on
+// the client's behalf.
+func defaultClient(private bool) *http.Client {
+	if private {
+		return &http.Client{Timeout: 30 * time.Second}
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{Timeout: 30 * time.Second, Transport: transport}
+}
+
+// publicOnly refuses connections to addresses that are not public. It runs
+// after name resolution, so DNS cannot point around it.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%s: %w", host, ErrPrivateNetwork)
+	}
+	return nil
+}
+
+// getJSON sends a GET request and decodes the JSON response into result.
+func getJSON(ctx context.Context, o options, endpoint string, header http.Header, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", o.userAgent)
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// checkResponse turns a non-2xx response into an error with the body.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s returned status %d: %s", resp.Request.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/chatbottest"
+)
+
+func TestSearchProviders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		if r.URL.Query().Get("q") != "go release" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/bing":
+			w.Write([]byte(`{"webPages":{"value":[{"name":"Go 1.25","url":"https://go.dev/doc/go1.25","snippet":"Release notes"}]}}`))
+		case "/brave":
+			w.Write([]byte(`{"web":{"results":[{"title":"Go 1.25","url":"https://go.dev/doc/go1.25","description":"Release notes"}]}}`))
+		case "/search":
+			w.Write([]byte(`{"results":[{"title":"Go 1.25","url":"https://go.dev/doc/go1.25","content":"Release notes"},{"title":"Other","url":"https://example.com"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	want := []Result{{Title: "Go 1.25", URL: "https://go.dev/doc/go1.25", Snippet: "Release notes"}}
+	providers := map[string]struct {
+		provider SearchProvider
+		header   string
+	}{
+		"bing":    {NewBing("bing-key", WithEndpoint(server.URL+"/bing"), WithPrivateNetworks(true)), "Ocp-Apim-Subscription-Key"},
+		"brave":   {NewBrave("brave-key", WithEndpoint(server.URL+"/brave"), WithPrivateNetworks(true)), "X-Subscription-Token"},
+		"searxng": {NewSearxNG(server.URL+"/", WithPrivateNetworks(true)), ""},
+	}
+	for name, p := range providers {
+		results, err := p.provider.Search(context.Background(), "go release", 1)
+		if err != nil || len(results) != 1 || results[0] != want[0] {
+			t.Errorf("%s: unexpected results %+v, %v", name, results, err)
+		}
+		if p.header != "" && header.Get(p.header) != name+"-key" {
+			t.Errorf("%s: expected the API key in %s", name, p.header)
+		}
+		if header.Get("User-Agent") != UserAgent {
+			t.Errorf("%s: unexpected user agent %q", name, header.Get("User-Agent"))
+		}
+	}
+
+	if _, err := NewBrave("key", WithEndpoint(server.URL+"/brave"), WithPrivateNetworks(true)).Search(context.Background(), "other", 1); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+	if _, err := NewBrave("key", WithEndpoint(server.URL+"/brave")).Search(context.Background(), "go release", 1); !errors.Is(err, ErrPrivateNetwork) {
+		t.Errorf("expected private addresses to be refused, got %v", err)
+	}
+}
+
+func TestSearchTool(t *testing.T) {
+	var counts []int
+	provider := SearchFunc(func(ctx context.Context, query string, count int) ([]Result, error) {
+		counts = append(counts, count)
+		return []Result{
+			{Title: "Go", URL: "https://go.dev", Snippet: "The Go language"},
+			{Title: "Tour", URL: "https://go.dev/tour"},
+			{Title: "Blog", URL: "https://go.dev/blog"},
+		}, nil
+	})
+	tool := NewSearchTool(provider, WithMaxResults(2))
+
+	output, err := tool.Call(context.Background(), json.RawMessage(`{"query":"golang","count":10}`))
+	want := "1. Go\n   https://go.dev\n   The Go language\n\n2. Tour\n   https://go.dev/tour"
+	if err != nil || output != want {
+		t.Errorf("unexpected output %q, %v", output, err)
+	}
+	if len(counts) != 1 || counts[0] != 2 {
+		t.Errorf("expected the count to be capped, got %v", counts)
+	}
+	if _, err := tool.Call(context.Background(), json.RawMessage(`{"query":" "}`)); err == nil {
+		t.Error("expected an error for an empty query")
+	}
+}
+
+func TestFetchTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\nAllow: /private/open$\n\nUser-agent: OtherBot\nDisallow: /\n"))
+		case "/article", "/private/open":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Release notes</title><style>p{}</style></head><body>
+				<nav>Home | Docs</nav><h1>Go 1.25</h1><p>Released in <b>August</b>.</p><script>track()</script>
+				<ul><li>Faster</li><li>Safer</li></ul><footer>Copyright</footer></body></html>`))
+		case "/moved":
+			http.Redirect(w, r, "/private/secret", http.StatusFound)
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		}
+	}))
+	defer server.Close()
+
+	tool := NewFetchTool(WithPrivateNetworks(true))
+	fetch := func(url string) (string, error) {
+		args, _ := json.Marshal(map[string]string{"url": url})
+		return tool.Call(context.Background(), args)
+	}
+
+	output, err := fetch(server.URL + "/article")
+	want := "Title: Release notes\nURL: " + server.URL + "/article\n\nGo 1.25\nReleased in August.\nFaster\nSafer"
+	if err != nil || output != want {
+		t.Errorf("unexpected output %q, %v", output, err)
+	}
+	if _, err := fetch(server.URL + "/private/open"); err != nil {
+		t.Errorf("expected the allowed path to be fetched, got %v", err)
+	}
+	for _, path := range []string{"/private/secret", "/moved"} {
+		if _, err := fetch(server.URL + path); !errors.Is(err, ErrDisallowed) {
+			t.Errorf("%s: expected robots.txt to disallow it, got %v", path, err)
+		}
+	}
+	if _, err := fetch(server.URL + "/image.png"); err == nil || !strings.Contains(err.Error(), "not a web page") {
+		t.Errorf("expected binary content to be refused, got %v", err)
+	}
+	if _, err := fetch("file:///etc/passwd"); err == nil {
+		t.Error("expected non-HTTP URLs to be refused")
+	}
+	if _, err := NewFetchTool().Call(context.Background(), json.RawMessage(`{"url":"`+server.URL+`/article"}`)); !errors.Is(err, ErrPrivateNetwork) {
+		t.Errorf("expected private addresses to be refused by default, got %v", err)
+	}
+
+	short, err := NewFetchTool(WithPrivateNetworks(true), WithMaxLength(7)).Call(context.Background(), json.RawMessage(`{"url":"`+server.URL+`/article"}`))
+	if err != nil || !strings.HasSuffix(short, "\n\nGo 1.25\n[truncated]") {
+		t.Errorf("expected the text to be truncated, got %q, %v", short, err)
+	}
+}
+
+func TestFetchToolSummarizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("Go 1.25 was released in August 2025."))
+	}))
+	defer server.Close()
+
+	model := chatbottest.NewMockModel("Go 1.25 came out in August 2025.")
+	tool := NewFetchTool(WithPrivateNetworks(true), WithSummarizer(model))
+	output, err := tool.Call(context.Background(), json.RawMessage(`{"url":"`+server.URL+`/notes.txt","question":"When?"}`))
+	if err != nil || !strings.HasSuffix(output, "\n\nGo 1.25 came out in August 2025.") {
+		t.Errorf("unexpected output %q, %v", output, err)
+	}
+	call, _ := model.LastCall()
+	if !strings.HasPrefix(call.Message, "Question: When?\n\nGo 1.25 was released") || call.Context["prompt"] != summarizePrompt {
+		t.Errorf("unexpected summarizer call %+v", call)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: *
+Disallow: /
+
+User-agent: go-chatbot
+User-agent: other
+Disallow: /*.pdf$
+Disallow: /search # no searches
+Allow: /search/help
+`), robotsAgent)
+
+	tests := map[string]bool{
+		"/":                true,
+		"/docs/a.pdf":      false,
+		"/docs/a.pdf?page": true,
+		"/search?q=go":     false,
+		"/search/help":     true,
+	}
+	for path, want := range tests {
+		if got := rules.allowed(path); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), robotsAgent).allowed("/any") != true {
+		t.Error("expected an empty disallow to allow everything")
+	}
+}
@@ -0,0 +1,104 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+)
+
+// Google translates and detects languages with the Google Cloud
+// Translation API (v2).
+type Google struct {
+	apiKey string
+	opts   options
+}
+
+var (
+	_ Translator = (*Google)(nil)
+	_ Detector   = (*Google)(nil)
+)
+
+// NewGoogle creates a translator authenticated with a Google Cloud API key.
+func NewGoogle(apiKey string, opts ...Option) *Google {
+	o := newOptions(opts)
+	if o.endpoint == "" {
+		o.endpoint = "https://translation.googleapis.com/language/translate/v2"
+	}
+	return &Google{apiKey: apiKey, opts: o}
+}
+
+// Translate implements Translator. An empty from lets Google detect the
+// source language.
+func (g *Google) Translate(ctx context.Context, text, from, to string) (string, error) {
+	request := map[string]interface{}{"q": text, "target": to, "format": "text"}
+	if from != "" {
+		request["source"] = from
+	}
+	var result struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := g.post(ctx, "", request, &result); err != nil {
+		return "", err
+	}
+	if len(result.Data.Translations) == 0 {
+		return "", fmt.Errorf("google translation returned no translation")
+	}
+	// Plain text is returned as is, but be safe with older API versions
+	return html.UnescapeString(result.Data.Translations[0].TranslatedText), nil
+}
+
+// Detect implements Detector.
+func (g *Google) Detect(ctx context.Context, text string) (string, error) {
+	var result struct {
+		Data struct {
+			Detections [][]struct {
+				Language string `json:"language"`
+			} `json:"detections"`
+		} `json:"data"`
+	}
+	if err := g.post(ctx, "/detect", map[string]interface{}{"q": text}, &result); err != nil {
+		return "", err
+	}
+	if len(result.Data.Detections) == 0 || len(result.Data.Detections[0]) == 0 {
+		return "", fmt.Errorf("google translation detected no language")
+	}
+	return result.Data.Detections[0][0].Language, nil
+}
+
+// post sends request to the endpoint with path appended and decodes the
+// response into result.
+func (g *Google) post(ctx context.Context, path string, request, result interface{}) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := g.opts.endpoint + path + "?key=" + url.QueryEscape(g.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.opts.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "Google Translation"); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
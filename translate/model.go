@@ -0,0 +1,64 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.rumenx.com/chatbot/models"
+)
+
+// translatePrompt instructs a model to translate the message it is sent.
+const translatePrompt = `Translate the user's message %sinto the language with the code %q. Reply with the translation only: do not answer or comment on the message, and keep its formatting, code, URLs and names unchanged.`
+
+// detectPrompt instructs a model to name the language of the message.
+const detectPrompt = `Identify the language the user's message is written in. Reply with its ISO 639-1 code only, like "en", without answering the message.`
+
+// ModelTranslator translates and detects languages by prompting a model,
+// for deployments without a translation service. A small, fast model is
+// usually enough.
+type ModelTranslator struct {
+	model models.Model
+}
+
+var (
+	_ Translator = (*ModelTranslator)(nil)
+	_ Detector   = (*ModelTranslator)(nil)
+)
+
+// NewModelTranslator creates a translator prompting model.
+func NewModelTranslator(model models.Model) *ModelTranslator {
+	return &ModelTranslator{model: model}
+}
+
+// Translate implements Translator.
+func (t *ModelTranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+	source := ""
+	if from != "" {
+		source = fmt.Sprintf("from the language with the code %q ", from)
+	}
+	reply, err := t.model.Ask(ctx, text, map[string]interface{}{
+		"prompt":      fmt.Sprintf(translatePrompt, source, to),
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to translate: %w", err)
+	}
+	return strings.TrimSpace(reply), nil
+}
+
+// Detect implements Detector.
+func (t *ModelTranslator) Detect(ctx context.Context, text string) (string, error) {
+	reply, err := t.model.Ask(ctx, text, map[string]interface{}{
+		"prompt":      detectPrompt,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to detect language: %w", err)
+	}
+	code := strings.ToLower(strings.Trim(strings.TrimSpace(reply), `."'`))
+	if len(code) < 2 || len(code) > 3 || strings.Trim(code, "abcdefghijklmnopqrstuvwxyz") != "" {
+		return "", fmt.Errorf("model replied with no language code: %q", reply)
+	}
+	return code, nil
+}
@@ -0,0 +1,91 @@
+// Package translate translates messages and replies, so users can chat in
+// their own language with a bot whose knowledge base is in another: the
+// Google Cloud Translation API, or any model prompted to translate.
+//
+//	translator := translate.NewGoogle(apiKey)
+//	bot, err := gochatbot.New(cfg, gochatbot.WithTranslation(translator, "en"))
+package translate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Translator translates text between languages, given as ISO 639-1 codes
+// like "en" or BCP 47 tags like "pt-BR".
+type Translator interface {
+	Translate(ctx context.Context, text, from, to string) (string, error)
+}
+
+// Detector identifies the language of text.
+type Detector interface {
+	// Detect returns the language of text as an ISO 639-1 code.
+	Detect(ctx context.Context, text string) (string, error)
+}
+
+// TranslatorFunc adapts a function to the Translator interface.
+type TranslatorFunc func(ctx context.Context, text, from, to string) (string, error)
+
+// Translate implements Translator.
+func (f TranslatorFunc) Translate(ctx context.Context, text, from, to string) (string, error) {
+	return f(ctx, text, from, to)
+}
+
+// Same reports whether two language codes name the same language, ignoring
+// case and regions, so "en-GB" and "EN" are the same.
+func Same(a, b string) bool {
+	return base(a) == base(b)
+}
+
+// base returns the primary language subtag of code.
+func base(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// Option configures a translator. Options a translator does not use are
+// ignored.
+type Option func(*options)
+
+type options struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// WithEndpoint overrides the API endpoint.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for API calls.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{httpClient: &http.Client{Timeout: 30 * time.Second}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// checkResponse turns a non-2xx response into an error with the body.
+func checkResponse(resp *http.Response, service string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s API error (status %d): %s", service, resp.StatusCode, strings.TrimSpace(string(body)))
+}
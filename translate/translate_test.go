@@ -0,0 +1,87 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGoogle(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-key" {
+			http.Error(w, `{"error":{"message":"API key not valid"}}`, http.StatusBadRequest)
+			return
+		}
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		if strings.HasSuffix(r.URL.Path, "/detect") {
+			w.Write([]byte(`{"data":{"detections":[[{"language":"de","confidence":0.98}]]}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"translations":[{"translatedText":"Where is my order?"}]}}`))
+	}))
+	defer server.Close()
+
+	google := NewGoogle("test-key", WithEndpoint(server.URL+"/language/translate/v2"))
+	language, err := google.Detect(context.Background(), "Wo ist meine Bestellung?")
+	if err != nil || language != "de" {
+		t.Errorf("expected German, got %q, %v", language, err)
+	}
+	translated, err := google.Translate(context.Background(), "Wo ist meine Bestellung?", "de", "en")
+	if err != nil || translated != "Where is my order?" {
+		t.Errorf("unexpected translation %q, %v", translated, err)
+	}
+	if len(requests) != 2 || requests[1]["source"] != "de" || requests[1]["target"] != "en" || requests[1]["format"] != "text" {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+
+	_, err = NewGoogle("bad-key", WithEndpoint(server.URL)).Translate(context.Background(), "Hallo", "de", "en")
+	if err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+}
+
+// replyModel replies with a fixed text and records the system prompt.
+type replyModel struct {
+	reply  string
+	prompt string
+}
+
+func (m *replyModel) Ask(ctx context.Context, message string, context map[string]interface{}) (string, error) {
+	m.prompt, _ = context["prompt"].(string)
+	return m.reply, nil
+}
+
+func (m *replyModel) Name() string     { return "reply" }
+func (m *replyModel) Provider() string { return "test" }
+
+func TestModelTranslator(t *testing.T) {
+	model := &replyModel{reply: " Where is my order?\n"}
+	translator := NewModelTranslator(model)
+	translated, err := translator.Translate(context.Background(), "Wo ist meine Bestellung?", "de", "en")
+	if err != nil || translated != "Where is my order?" {
+		t.Errorf("unexpected translation %q, %v", translated, err)
+	}
+	if !strings.Contains(model.prompt, `from the language with the code "de" into the language with the code "en"`) {
+		t.Errorf("unexpected prompt: %s", model.prompt)
+	}
+
+	for reply, want := range map[string]string{"de": "de", " FR.\n": "fr", "The language is German.": ""} {
+		model.reply = reply
+		language, err := translator.Detect(context.Background(), "text")
+		if language != want || (want == "") != (err != nil) {
+			t.Errorf("%q: expected %q, got %q, %v", reply, want, language, err)
+		}
+	}
+}
+
+func TestSame(t *testing.T) {
+	if !Same("en-GB", "EN") || !Same("pt_BR", "pt") || Same("de", "en") {
+		t.Error("expected languages to be compared by their primary subtag")
+	}
+}
@@ -0,0 +1,143 @@
+package gochatbot
+
+import (
+	"context"
+
+	"go.rumenx.com/chatbot/translate"
+)
+
+// translationKey holds a request's translation in its context.
+const translationKey = "translation"
+
+// WithTranslation lets users chat in their own language with a bot whose
+// prompt, FAQ and knowledge base are in knowledgeLanguage, or the
+// configured language if it is empty. Messages in other languages are
+// translated into it before FAQ matching, retrieval and the model, and
+// replies are translated back.
+//
+// The user's language is the request's "language" context value if set,
+// and otherwise detected when translator is also a translate.Detector.
+// Translated replies are sent as one chunk when streaming. Translation
+// failures are logged and leave the text untranslated.
+func WithTranslation(translator translate.Translator, knowledgeLanguage string) Option {
+	return func(c *Chatbot) {
+		c.translator = translator
+		c.knowledgeLanguage = knowledgeLanguage
+	}
+}
+
+// translation is the original and translated text of a translated
+// exchange, stored as message metadata.
+type translation struct {
+	// language is the user's and knowledge the knowledge base's
+	language, knowledge        string
+	message, translatedMessage string
+	reply, translatedReply     string
+}
+
+// metadata returns the message metadata of a message translated between
+// languages.
+func metadata(original, originalLanguage, translated, translatedLanguage string) map[string]interface{} {
+	return map[string]interface{}{
+		"original_text":       original,
+		"original_language":   originalLanguage,
+		"translated_text":     translated,
+		"translated_language": translatedLanguage,
+	}
+}
+
+// knowledgeLang returns the language of the knowledge base.
+func (c *Chatbot) knowledgeLang() string {
+	if c.knowledgeLanguage != "" {
+		return c.knowledgeLanguage
+	}
+	if cfg := c.GetConfig(); cfg != nil && cfg.Language != "" {
+		return cfg.Language
+	}
+	return "en"
+}
+
+// userLanguage returns the language of message, detecting it if the
+// request does not say. It is empty if it cannot be told.
+func (c *Chatbot) userLanguage(ctx context.Context, message string, askContext map[string]interface{}) string {
+	if language, _ := askContext["language"].(string); language != "" {
+		return language
+	}
+	detector, ok := c.translator.(translate.Detector)
+	if !ok {
+		return ""
+	}
+	language, err := detector.Detect(ctx, message)
+	if err != nil {
+		c.logf("translation: %v", err)
+		return ""
+	}
+	askContext["language"] = language
+	return language
+}
+
+// translateIn returns message in the knowledge language, recording the
+// translation in the request context.
+func (c *Chatbot) translateIn(ctx context.Context, message string, askContext map[string]interface{}) string {
+	if c.translator == nil {
+		return message
+	}
+	language, knowledge := c.userLanguage(ctx, message, askContext), c.knowledgeLang()
+	if language == "" || translate.Same(language, knowledge) {
+		return message
+	}
+	translated, err := c.translator.Translate(ctx, message, language, knowledge)
+	if err != nil {
+		c.logf("translation: failed to translate message: %v", err)
+		return message
+	}
+	askContext[translationKey] = &translation{language: language, knowledge: knowledge, message: message, translatedMessage: translated}
+	return translated
+}
+
+// translateOut returns reply in the language of message, recording the
+// translation in the request context.
+func (c *Chatbot) translateOut(ctx context.Context, message, reply string, askContext map[string]interface{}) string {
+	if c.translator == nil {
+		return reply
+	}
+	t, _ := askContext[translationKey].(*translation)
+	if t == nil {
+		// Refusals come before the message is translated
+		language, knowledge := c.userLanguage(ctx, message, askContext), c.knowledgeLang()
+		if language == "" || translate.Same(language, knowledge) {
+			return reply
+		}
+		t = &translation{language: language, knowledge: knowledge}
+		askContext[translationKey] = t
+	}
+	translated, err := c.translator.Translate(ctx, reply, t.knowledge, t.language)
+	if err != nil {
+		c.logf("translation: failed to translate reply: %v", err)
+		return reply
+	}
+	t.reply, t.translatedReply = reply, translated
+	return translated
+}
+
+// translating reports whether the request's reply is to be translated.
+func translating(askContext map[string]interface{}) bool {
+	t, _ := askContext[translationKey].(*translation)
+	return t != nil
+}
+
+// translationMetadata returns the metadata of the message and reply of a
+// translated exchange, which is nil for text that was not translated.
+func translationMetadata(askContext map[string]interface{}) (messageMetadata, replyMetadata map[string]interface{}) {
+	t, _ := askContext[translationKey].(*translation)
+	if t == nil {
+		return nil, nil
+	}
+	if t.translatedMessage != "" {
+		messageMetadata = metadata(t.message, t.language, t.translatedMessage, t.knowledge)
+	}
+	if t.translatedReply != "" {
+		replyMetadata = metadata(t.reply, t.knowledge, t.translatedReply, t.language)
+	}
+	return messageMetadata, replyMetadata
+}
@@ -0,0 +1,106 @@
+package gochatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+)
+
+// tagTranslator "translates" by tagging text with the languages, and tells
+// German by its greeting.
+type tagTranslator struct {
+	detected int
+}
+
+func (t *tagTranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+	return "[" + from + ">" + to + "] " + text, nil
+}
+
+func (t *tagTranslator) Detect(ctx context.Context, text string) (string, error) {
+	t.detected++
+	if strings.Contains(text, "Hallo") {
+		return "de", nil
+	}
+	return "en", nil
+}
+
+// metadataConversations keeps the metadata of stored exchanges.
+type metadataConversations struct {
+	*fakeConversations
+	metadata []map[string]interface{}
+}
+
+func (m *metadataConversations) AppendExchangeWithMetadata(ctx context.Context, conversationID, userID, message, reply string, messageMetadata, replyMetadata map[string]interface{}) (string, error) {
+	m.metadata = append(m.metadata, messageMetadata, replyMetadata)
+	return m.AppendExchange(ctx, conversationID, userID, message, reply)
+}
+
+func TestTranslation(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &echoModel{}
+	conversations := &metadataConversations{fakeConversations: newFakeConversations()}
+	translator := &tagTranslator{}
+	bot, err := New(cfg, WithModel(model), WithTranslation(translator, ""), WithConversations(conversations, 0))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	reply, err := bot.Ask(context.Background(), "Hallo", WithContext("conversation_id", "c1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.messages[0] != "[de>en] Hallo" || reply != "[en>de] You said: [de>en] Hallo" {
+		t.Errorf("expected the message and reply to be translated, got %q and %q", model.messages[0], reply)
+	}
+	stored := conversations.messages["c1"]
+	if stored[0]["content"] != "Hallo" || stored[1]["content"] != reply {
+		t.Errorf("expected the text the user saw to be stored, got %v", stored)
+	}
+	if len(conversations.metadata) != 2 || conversations.metadata[0]["translated_text"] != "[de>en] Hallo" ||
+		conversations.metadata[1]["original_text"] != "You said: [de>en] Hallo" || conversations.metadata[1]["translated_language"] != "de" {
+		t.Errorf("unexpected metadata: %v", conversations.metadata)
+	}
+
+	// Messages in the knowledge language are left alone
+	if reply, err := bot.Ask(context.Background(), "Hello"); err != nil || reply != "You said: Hello" {
+		t.Errorf("expected no translation, got %q, %v", reply, err)
+	}
+
+	// A language given by the request is not detected
+	detected := translator.detected
+	if reply, err := bot.Ask(context.Background(), "Bonjour", WithContext("language", "fr")); err != nil || reply != "[en>fr] You said: [fr>en] Bonjour" {
+		t.Errorf("expected a French translation, got %q, %v", reply, err)
+	}
+	if translator.detected != detected {
+		t.Error("expected the request's language to be used")
+	}
+}
+
+func TestTranslationStream(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	bot, err := New(cfg, WithModel(echoStreamModel{}), WithTranslation(&tagTranslator{}, "en"))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := bot.AskStream(context.Background(), w, "Hallo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var chunks []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		var chunk struct{ Content string }
+		if data, ok := strings.CutPrefix(line, "data: "); ok && json.Unmarshal([]byte(data), &chunk) == nil && chunk.Content != "" {
+			chunks = append(chunks, chunk.Content)
+		}
+	}
+	if len(chunks) != 1 || chunks[0] != "[en>de] [de>en] Hallo" {
+		t.Errorf("expected the translated reply in one chunk, got %q", chunks)
+	}
+}
@@ -0,0 +1,334 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Period is how often a budget starts over.
+type Period string
+
+// Budget periods, in UTC.
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+// start returns the start of t's period.
+func (p Period) start(t time.Time) time.Time {
+	if p == Monthly {
+		year, month, _ := t.UTC().Date()
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return startOfDay(t)
+}
+
+// format formats the period starting at start.
+func (p Period) format(start time.Time) string {
+	if p == Monthly {
+		return start.Format(monthFormat)
+	}
+	return start.Format(database.DayFormat)
+}
+
+// DefaultThresholds are the fractions of a budget alerted on by default.
+var DefaultThresholds = []float64{0.8, 1}
+
+// Budget limits the cost or tokens spent per period, overall or per user,
+// on all models or one.
+type Budget struct {
+	// Name identifies the budget in alerts.
+	Name   string `json:"name" yaml:"name"`
+	Period Period `json:"period" yaml:"period"`
+	// MaxCost and MaxTokens are the limits; at least one is required. With
+	// both, the one closer to being reached counts.
+	MaxCost   float64 `json:"max_cost,omitempty" yaml:"max_cost"`
+	MaxTokens int     `json:"max_tokens,omitempty" yaml:"max_tokens"`
+	// PerUser gives every user the budget, instead of sharing it.
+	PerUser bool `json:"per_user,omitempty" yaml:"per_user"`
+	// User and Model, if set, limit the budget to one user or model.
+	User  string `json:"user,omitempty" yaml:"user"`
+	Model string `json:"model,omitempty" yaml:"model"`
+	// Thresholds are the fractions of the limits alerted on, each once per
+	// period (default DefaultThresholds). Values above 1 alert on
+	// overspending.
+	Thresholds []float64 `json:"thresholds,omitempty" yaml:"thresholds"`
+}
+
+func (b *Budget) validate() error {
+	switch {
+	case b.Name == "":
+		return fmt.Errorf("budget name is required")
+	case b.Period != Daily && b.Period != Monthly:
+		return fmt.Errorf("budget %q: period must be %q or %q", b.Name, Daily, Monthly)
+	case b.MaxCost < 0 || b.MaxTokens < 0 || (b.MaxCost == 0 && b.MaxTokens == 0):
+		return fmt.Errorf("budget %q: max cost or max tokens is required", b.Name)
+	}
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = DefaultThresholds
+	}
+	for _, threshold := range b.Thresholds {
+		if threshold <= 0 {
+			return fmt.Errorf("budget %q: thresholds must be positive", b.Name)
+		}
+	}
+	return nil
+}
+
+// fraction returns how much of the budget usage spends.
+func (b *Budget) fraction(usage Usage) float64 {
+	var fraction float64
+	if b.MaxCost > 0 {
+		fraction = usage.Cost / b.MaxCost
+	}
+	if b.MaxTokens > 0 {
+		fraction = max(fraction, float64(usage.TotalTokens)/float64(b.MaxTokens))
+	}
+	return fraction
+}
+
+// budgetPeriod is the spending of a budget in its current period, by user
+// for per-user budgets.
+type budgetPeriod struct {
+	start  time.Time
+	scopes map[string]*budgetSpend
+}
+
+// budgetSpend is a budget's usage, and the highest threshold alerted on.
+type budgetSpend struct {
+	usage   Usage
+	alerted float64
+}
+
+// spend adds a reply to the budgets it counts against and returns the
+// alerts of the thresholds it crossed. Only the highest threshold crossed
+// at once is alerted on. The caller must hold the mutex.
+func (t *Tracker) spend(at time.Time, userID, model string, reply Usage) []Alert {
+	var alerts []Alert
+	for i := range t.budgets {
+		budget := &t.budgets[i]
+		if (budget.User != "" && budget.User != userID) || (budget.Model != "" && budget.Model != model) {
+			continue
+		}
+		scope := budget.User
+		if budget.PerUser {
+			scope = userID
+		}
+
+		period := &t.spent[i]
+		start := budget.Period.start(at)
+		switch {
+		case period.start.Before(start):
+			period.start = start
+			period.scopes = make(map[string]*budgetSpend)
+		case start.Before(period.start):
+			// A late reply of a past period
+			continue
+		}
+		spend := period.scopes[scope]
+		if spend == nil {
+			spend = &budgetSpend{}
+			period.scopes[scope] = spend
+		}
+		spend.usage.add(reply)
+
+		fraction, crossed := budget.fraction(spend.usage), 0.0
+		for _, threshold := range budget.Thresholds {
+			if fraction >= threshold && threshold > spend.alerted {
+				crossed = max(crossed, threshold)
+			}
+		}
+		if crossed == 0 {
+			continue
+		}
+		spend.alerted = crossed
+		alerts = append(alerts, Alert{
+			Budget:    budget.Name,
+			Period:    budget.Period.format(start),
+			User:      scope,
+			Model:     budget.Model,
+			Threshold: crossed,
+			Cost:      spend.usage.Cost,
+			MaxCost:   budget.MaxCost,
+			Tokens:    spend.usage.TotalTokens,
+			MaxTokens: budget.MaxTokens,
+			Time:      t.now().UTC(),
+		})
+	}
+	return alerts
+}
+
+// Alert reports a budget crossing a threshold.
+type Alert struct {
+	Budget string `json:"budget"`
+	// Period is the day (YYYY-MM-DD) or month (YYYY-MM) of the spending.
+	Period string `json:"period"`
+	// User is set for per-user budgets and Model for budgets of a model.
+	User      string    `json:"user,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Threshold float64   `json:"threshold"`
+	Cost      float64   `json:"cost"`
+	MaxCost   float64   `json:"max_cost,omitempty"`
+	Tokens    int       `json:"tokens"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Message describes the alert in a sentence.
+func (a Alert) Message() string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "Budget %q", a.Budget)
+	if a.User != "" {
+		fmt.Fprintf(&message, " of user %s", a.User)
+	}
+	if a.Model != "" {
+		fmt.Fprintf(&message, " for %s", a.Model)
+	}
+	fmt.Fprintf(&message, " reached %.0f%% in %s:", a.Threshold*100, a.Period)
+	if a.MaxCost > 0 {
+		fmt.Fprintf(&message, " cost %.2f of %.2f", a.Cost, a.MaxCost)
+	}
+	if a.MaxTokens > 0 {
+		fmt.Fprintf(&message, " %d of %d tokens", a.Tokens, a.MaxTokens)
+	}
+	return message.String()
+}
+
+// Alerter sends budget alerts.
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// AlerterFunc adapts a function to the Alerter interface.
+type AlerterFunc func(ctx context.Context, alert Alert) error
+
+// Alert implements Alerter.
+func (f AlerterFunc) Alert(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// WebhookConfig configures a WebhookAlerter.
+type WebhookConfig struct {
+	// URL receives a POST with each Alert as JSON.
+	URL string
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+	// HTTPClient overrides the default client.
+	HTTPClient *http.Client
+}
+
+// WebhookAlerter posts alerts to an HTTP endpoint, such as a chat
+// incoming webhook or an incident management system.
+type WebhookAlerter struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookAlerter creates a webhook alerter.
+func NewWebhookAlerter(cfg WebhookConfig) (*WebhookAlerter, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &WebhookAlerter{config: cfg, client: client}, nil
+}
+
+// Alert implements Alerter.
+func (w *WebhookAlerter) Alert(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(struct {
+		Alert
+		Message string `json:"message"`
+	}{alert, alert.Message()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range w.config.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer sending from from through the SMTP server
+// at addr ("host:port"), authenticating with auth if it is not nil, e.g.
+// smtp.PlainAuth.
+func NewSMTPMailer(addr, from string, auth smtp.Auth) *SMTPMailer {
+	return &SMTPMailer{addr: addr, from: from, auth: auth}
+}
+
+// Send implements Mailer. The SMTP exchange cannot be cancelled, so ctx
+// is only checked before it starts.
+func (m *SMTPMailer) Send(ctx context.Context, to []string, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", m.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if err := smtp.SendMail(m.addr, m.auth, m.from, to, []byte(message.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// EmailAlerter emails alerts.
+type EmailAlerter struct {
+	mailer Mailer
+	to     []string
+}
+
+// NewEmailAlerter creates an alerter emailing alerts to the addresses in
+// to with mailer.
+func NewEmailAlerter(mailer Mailer, to ...string) *EmailAlerter {
+	return &EmailAlerter{mailer: mailer, to: to}
+}
+
+// Alert implements Alerter.
+func (e *EmailAlerter) Alert(ctx context.Context, alert Alert) error {
+	subject := fmt.Sprintf("Chatbot budget %q reached %.0f%%", alert.Budget, alert.Threshold*100)
+	return e.mailer.Send(ctx, e.to, subject, alert.Message()+".\n")
+}
@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+)
+
+// Handler returns the usage reports API. Mount it under a prefix with
+// http.StripPrefix and put it behind your own authentication:
+//
+//	GET /usage                   today
+//	GET /usage?date=2025-01-15   a day
+//	GET /usage?month=2025-01     a month
+func (t *Tracker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /usage", t.handleUsage)
+	return mux
+}
+
+func (t *Tracker) handleUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if value := query.Get("month"); value != "" {
+		month, err := time.Parse(monthFormat, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid month, expected YYYY-MM")
+			return
+		}
+		writeJSON(w, http.StatusOK, t.Monthly(month))
+		return
+	}
+
+	day := t.now()
+	if value := query.Get("date"); value != "" {
+		parsed, err := time.Parse(database.DayFormat, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	writeJSON(w, http.StatusOK, t.Daily(day))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
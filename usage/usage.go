@@ -0,0 +1,322 @@
+// Package usage reports token usage and cost per user and model by day and
+// by month, and alerts when spending crosses budget thresholds.
+//
+// A Tracker counts the reply generated events of the chatbot, pricing them
+// with the configured per-model prices. Register it as the chatbot's event
+// publisher, alongside others with events.Multi, and serve its reports with
+// Handler:
+//
+//	tracker, _ := usage.NewTracker(
+//		usage.WithPricing(usage.Pricing{"gpt-4o": {Prompt: 2.50, Completion: 10}}),
+//		usage.WithBudgets(usage.Budget{Name: "monthly", Period: usage.Monthly, MaxCost: 500}),
+//		usage.WithAlerter(usage.NewEmailAlerter(usage.NewSMTPMailer("smtp.example.com:587", "bot@example.com", auth), "ops@example.com")),
+//	)
+//	bot, _ := gochatbot.New(cfg, gochatbot.WithEventPublisher(events.Multi(collector, tracker)))
+//	http.Handle("/admin/", http.StripPrefix("/admin", requireAdmin(tracker.Handler())))
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.rumenx.com/chatbot/database"
+	"go.rumenx.com/chatbot/events"
+)
+
+// DefaultRetention is how many days a Tracker keeps by default, enough for
+// a year of monthly reports.
+const DefaultRetention = 400
+
+// monthFormat formats the months of monthly reports.
+const monthFormat = "2006-01"
+
+// Price is what a model costs, in any currency, per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt" yaml:"prompt"`
+	Completion float64 `json:"completion" yaml:"completion"`
+}
+
+// Pricing holds the prices of models by name. Replies of models without a
+// price are counted at no cost.
+type Pricing map[string]Price
+
+// Cost returns the cost of a reply of model.
+func (p Pricing) Cost(model string, promptTokens, completionTokens int) float64 {
+	price := p[model]
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+// Usage is the token usage and cost of some replies. Token counts are the
+// estimates of the reply generated events.
+type Usage struct {
+	Replies          int     `json:"replies"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add adds other to u.
+func (u *Usage) add(other Usage) {
+	u.Replies += other.Replies
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+}
+
+// UserUsage is a user's usage, in total and by model.
+type UserUsage struct {
+	Usage
+	Models map[string]Usage `json:"models"`
+}
+
+// DayUsage is the usage of one day.
+type DayUsage struct {
+	Date string `json:"date"`
+	Usage
+}
+
+// Report holds the usage of a range of days.
+type Report struct {
+	// From and To are the first and last day covered, as YYYY-MM-DD.
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	Total Usage `json:"total"`
+	// Users holds the usage by user ID. Replies to unidentified users are
+	// under the empty ID.
+	Users map[string]UserUsage `json:"users"`
+	// Models holds the usage by model name.
+	Models map[string]Usage `json:"models"`
+	// Days holds the usage of each day with replies, oldest first.
+	Days []DayUsage `json:"days"`
+}
+
+// Option configures a Tracker.
+type Option func(*Tracker)
+
+// WithPricing sets the prices of the models.
+func WithPricing(pricing Pricing) Option {
+	return func(t *Tracker) {
+		t.pricing = pricing
+	}
+}
+
+// WithBudgets sets the budgets alerted on.
+func WithBudgets(budgets ...Budget) Option {
+	return func(t *Tracker) {
+		t.budgets = append(t.budgets, budgets...)
+	}
+}
+
+// WithAlerter sets where budget alerts are sent. Without it, alerts are
+// only reported by Publish's error.
+func WithAlerter(alerter Alerter) Option {
+	return func(t *Tracker) {
+		t.alerter = alerter
+	}
+}
+
+// WithRetention sets how many days of usage a Tracker keeps.
+func WithRetention(days int) Option {
+	return func(t *Tracker) {
+		if days > 0 {
+			t.retention = days
+		}
+	}
+}
+
+// Tracker aggregates token usage and cost per UTC day, user and model, and
+// alerts when budgets cross their thresholds. It is an events.Publisher
+// counting reply generated events.
+//
+// Usage is kept in memory, so it starts from zero when the process starts
+// and covers only this instance.
+type Tracker struct {
+	pricing   Pricing
+	budgets   []Budget
+	alerter   Alerter
+	retention int
+	now       func() time.Time
+
+	mutex sync.Mutex
+	// days holds the usage by day, user and model
+	days map[string]map[string]map[string]Usage
+	// spent holds the budgets' usage in their current periods
+	spent []budgetPeriod
+}
+
+var _ events.Publisher = (*Tracker)(nil)
+
+// NewTracker creates a tracker. It returns an error if a budget is invalid.
+func NewTracker(opts ...Option) (*Tracker, error) {
+	t := &Tracker{
+		retention: DefaultRetention,
+		now:       time.Now,
+		days:      make(map[string]map[string]map[string]Usage),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	for i := range t.budgets {
+		if err := t.budgets[i].validate(); err != nil {
+			return nil, err
+		}
+		t.spent = append(t.spent, budgetPeriod{})
+	}
+	return t, nil
+}
+
+// Publish implements events.Publisher by counting reply generated events
+// and sending the alerts of budgets they push over a threshold.
+func (t *Tracker) Publish(ctx context.Context, event events.Event) error {
+	if event.Type != events.ReplyGenerated {
+		return nil
+	}
+	at := event.Time
+	if at.IsZero() {
+		at = t.now()
+	}
+	model, _ := event.Data["model"].(string)
+	prompt, completion := int(number(event.Data["prompt_tokens"])), int(number(event.Data["completion_tokens"]))
+	reply := Usage{
+		Replies:          1,
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+		Cost:             t.pricing.Cost(model, prompt, completion),
+	}
+
+	t.mutex.Lock()
+	users := t.day(at)
+	if users[event.UserID] == nil {
+		users[event.UserID] = make(map[string]Usage)
+	}
+	usage := users[event.UserID][model]
+	usage.add(reply)
+	users[event.UserID][model] = usage
+	alerts := t.spend(at, event.UserID, model, reply)
+	t.mutex.Unlock()
+
+	var errs []error
+	for _, alert := range alerts {
+		if t.alerter == nil {
+			errs = append(errs, fmt.Errorf("budget alert: %s", alert.Message()))
+			continue
+		}
+		if err := t.alerter.Alert(ctx, alert); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send budget alert: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements events.Publisher.
+func (t *Tracker) Close() error {
+	return nil
+}
+
+// day returns the usage of at's day, creating it and dropping days past
+// the retention if needed. The caller must hold the mutex.
+func (t *Tracker) day(at time.Time) map[string]map[string]Usage {
+	date := at.UTC().Format(database.DayFormat)
+	users, ok := t.days[date]
+	if ok {
+		return users
+	}
+
+	users = make(map[string]map[string]Usage)
+	t.days[date] = users
+	oldest := startOfDay(t.now()).AddDate(0, 0, 1-t.retention).Format(database.DayFormat)
+	for date := range t.days {
+		if date < oldest {
+			delete(t.days, date)
+		}
+	}
+	return users
+}
+
+// Report returns the usage of the UTC days from from through to.
+func (t *Tracker) Report(from, to time.Time) (*Report, error) {
+	from, to = startOfDay(from), startOfDay(to)
+	if to.Before(from) {
+		return nil, errors.New("the range ends before it starts")
+	}
+
+	report := &Report{
+		From:   from.Format(database.DayFormat),
+		To:     to.Format(database.DayFormat),
+		Users:  make(map[string]UserUsage),
+		Models: make(map[string]Usage),
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for date, users := range t.days {
+		if date < report.From || date > report.To {
+			continue
+		}
+		day := DayUsage{Date: date}
+		for userID, models := range users {
+			user, ok := report.Users[userID]
+			if !ok {
+				user.Models = make(map[string]Usage)
+			}
+			for model, usage := range models {
+				day.add(usage)
+				user.add(usage)
+				byUser := user.Models[model]
+				byUser.add(usage)
+				user.Models[model] = byUser
+				byModel := report.Models[model]
+				byModel.add(usage)
+				report.Models[model] = byModel
+			}
+			report.Users[userID] = user
+		}
+		report.Total.add(day.Usage)
+		report.Days = append(report.Days, day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Date < report.Days[j].Date
+	})
+	return report, nil
+}
+
+// Daily returns the usage of day's UTC day.
+func (t *Tracker) Daily(day time.Time) *Report {
+	report, _ := t.Report(day, day)
+	return report
+}
+
+// Monthly returns the usage of month's UTC month.
+func (t *Tracker) Monthly(month time.Time) *Report {
+	start := Monthly.start(month)
+	report, _ := t.Report(start, start.AddDate(0, 1, -1))
+	return report
+}
+
+// startOfDay returns the start of t's UTC day.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// number reads an event value that may have been decoded from JSON.
+func number(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,209 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/events"
+)
+
+var today = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+var testPricing = Pricing{"gpt-4o": {Prompt: 2, Completion: 10}}
+
+// reply publishes a reply generated event.
+func reply(t *testing.T, tracker *Tracker, at time.Time, userID, model string, prompt, completion int) error {
+	t.Helper()
+	event := events.New(events.ReplyGenerated, map[string]interface{}{"model": model, "prompt_tokens": prompt, "completion_tokens": completion})
+	event.Time = at
+	event.UserID = userID
+	return tracker.Publish(context.Background(), event)
+}
+
+func newTestTracker(t *testing.T, opts ...Option) *Tracker {
+	t.Helper()
+	tracker, err := NewTracker(append([]Option{WithPricing(testPricing)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+	tracker.now = func() time.Time { return today }
+	return tracker
+}
+
+func TestReports(t *testing.T) {
+	tracker := newTestTracker(t)
+	reply(t, tracker, today.AddDate(0, 0, -10), "u1", "gpt-4o", 1000, 1000) // last month
+	reply(t, tracker, today.AddDate(0, 0, -1), "u1", "gpt-4o", 500_000, 100_000)
+	reply(t, tracker, today, "u1", "gpt-4o", 500_000, 100_000)
+	reply(t, tracker, today, "u2", "llama3", 10, 20)
+	// Decoded from JSON, e.g. replayed from a broker
+	tracker.Publish(context.Background(), events.Event{Type: events.ReplyGenerated, Time: today, Data: map[string]interface{}{"model": "gpt-4o", "prompt_tokens": float64(1e6)}})
+	tracker.Publish(context.Background(), events.Event{Type: events.MessageReceived, Time: today, UserID: "u3"})
+
+	daily := tracker.Daily(today)
+	if daily.From != "2026-03-10" || daily.Total.Replies != 3 || daily.Total.Cost != 4 {
+		t.Errorf("unexpected daily report: %+v", daily)
+	}
+	if user := daily.Users["u1"]; user.Cost != 2 || user.Models["gpt-4o"].TotalTokens != 600_000 {
+		t.Errorf("unexpected usage of u1: %+v", user)
+	}
+	if llama := daily.Models["llama3"]; llama.Replies != 1 || llama.TotalTokens != 30 || llama.Cost != 0 {
+		t.Errorf("expected models without a price to cost nothing, got %+v", llama)
+	}
+	if anonymous := daily.Users[""]; anonymous.Cost != 2 {
+		t.Errorf("expected unidentified users under the empty ID, got %+v", anonymous)
+	}
+
+	monthly := tracker.Monthly(today)
+	if monthly.From != "2026-03-01" || monthly.To != "2026-03-31" || monthly.Total.Replies != 4 || monthly.Total.Cost != 6 {
+		t.Errorf("unexpected monthly report: %+v", monthly)
+	}
+	if len(monthly.Days) != 2 || monthly.Days[0].Date != "2026-03-09" || monthly.Days[1].Replies != 3 {
+		t.Errorf("unexpected days: %+v", monthly.Days)
+	}
+
+	if _, err := tracker.Report(today, today.AddDate(0, 0, -1)); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+}
+
+func TestBudgetAlerts(t *testing.T) {
+	var alerts []Alert
+	tracker := newTestTracker(t,
+		WithBudgets(
+			Budget{Name: "daily", Period: Daily, MaxCost: 10},
+			Budget{Name: "per-user", Period: Monthly, MaxTokens: 1000, PerUser: true, Thresholds: []float64{0.5, 1, 2}},
+		),
+		WithAlerter(AlerterFunc(func(ctx context.Context, alert Alert) error {
+			alerts = append(alerts, alert)
+			return nil
+		})),
+	)
+
+	// 8.0 of 10: the daily budget reaches 80%
+	reply(t, tracker, today, "u1", "gpt-4o", 1_000_000, 600_000)
+	if len(alerts) != 2 || alerts[0].Budget != "daily" || alerts[0].Threshold != 0.8 || alerts[0].Period != "2026-03-10" {
+		t.Fatalf("unexpected alerts: %+v", alerts)
+	}
+	// 1.6M tokens are over twice u1's budget; only the highest threshold is alerted on
+	if alerts[1].Budget != "per-user" || alerts[1].User != "u1" || alerts[1].Threshold != 2 || alerts[1].Period != "2026-03" {
+		t.Errorf("unexpected per-user alert: %+v", alerts[1])
+	}
+
+	// Each threshold alerts once per period
+	alerts = nil
+	reply(t, tracker, today, "u1", "llama3", 10, 10)
+	reply(t, tracker, today, "u2", "llama3", 300, 300)
+	if len(alerts) != 1 || alerts[0].User != "u2" || alerts[0].Threshold != 0.5 {
+		t.Errorf("expected only u2's first alert, got %+v", alerts)
+	}
+
+	alerts = nil
+	reply(t, tracker, today, "u2", "gpt-4o", 1_000_000, 0)
+	if len(alerts) != 2 || alerts[0].Threshold != 1 || !strings.Contains(alerts[0].Message(), "cost 10.00 of 10.00") {
+		t.Errorf("expected the daily budget to be reached, got %+v", alerts)
+	}
+
+	// Budgets start over with the period
+	alerts = nil
+	reply(t, tracker, today.AddDate(0, 0, 1), "u3", "gpt-4o", 1_000_000, 600_000)
+	if len(alerts) != 2 || alerts[0].Period != "2026-03-11" || alerts[0].Cost != 8 {
+		t.Errorf("expected the next day's budget to alert, got %+v", alerts)
+	}
+}
+
+func TestBudgetAlertsWithoutAlerter(t *testing.T) {
+	tracker := newTestTracker(t, WithBudgets(Budget{Name: "tiny", Period: Daily, MaxTokens: 1}))
+	err := reply(t, tracker, today, "u1", "gpt-4o", 1, 1)
+	if err == nil || !strings.Contains(err.Error(), `Budget "tiny" reached 100% in 2026-03-10: 2 of 1 tokens`) {
+		t.Errorf("expected the alert to be reported, got %v", err)
+	}
+}
+
+func TestInvalidBudgets(t *testing.T) {
+	for name, budget := range map[string]Budget{
+		"no name":       {Period: Daily, MaxCost: 1},
+		"no period":     {Name: "b", MaxCost: 1},
+		"no limit":      {Name: "b", Period: Daily},
+		"bad threshold": {Name: "b", Period: Daily, MaxCost: 1, Thresholds: []float64{0}},
+	} {
+		if _, err := NewTracker(WithBudgets(budget)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWebhookAlerter(t *testing.T) {
+	var body map[string]interface{}
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	alerter, err := NewWebhookAlerter(WebhookConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	if err := alerter.Alert(context.Background(), Alert{Budget: "daily", Period: "2026-03-10", Threshold: 1, Cost: 10, MaxCost: 10}); err != nil {
+		t.Fatalf("failed to alert: %v", err)
+	}
+	if authorization != "Bearer token" || body["budget"] != "daily" || body["message"] != `Budget "daily" reached 100% in 2026-03-10: cost 10.00 of 10.00` {
+		t.Errorf("unexpected request: %s %v", authorization, body)
+	}
+}
+
+// recordingMailer collects sent emails.
+type recordingMailer struct {
+	to      []string
+	subject string
+	body    string
+}
+
+func (m *recordingMailer) Send(ctx context.Context, to []string, subject, body string) error {
+	m.to, m.subject, m.body = to, subject, body
+	return nil
+}
+
+func TestEmailAlerter(t *testing.T) {
+	mailer := &recordingMailer{}
+	alerter := NewEmailAlerter(mailer, "ops@example.com")
+	if err := alerter.Alert(context.Background(), Alert{Budget: "monthly", Period: "2026-03", User: "u1", Threshold: 0.8, Tokens: 800, MaxTokens: 1000}); err != nil {
+		t.Fatalf("failed to alert: %v", err)
+	}
+	if len(mailer.to) != 1 || mailer.subject != `Chatbot budget "monthly" reached 80%` || !strings.Contains(mailer.body, "of user u1 reached 80% in 2026-03: 800 of 1000 tokens") {
+		t.Errorf("unexpected email to %v: %s\n%s", mailer.to, mailer.subject, mailer.body)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tracker := newTestTracker(t)
+	reply(t, tracker, today, "u1", "gpt-4o", 1000, 1000)
+	handler := tracker.Handler()
+
+	for target, want := range map[string]string{
+		"/usage":                 "2026-03-10",
+		"/usage?date=2026-03-09": "2026-03-09",
+		"/usage?month=2026-03":   "2026-03-01",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var report Report
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		if rec.Code != http.StatusOK || report.From != want {
+			t.Errorf("%s: expected a report from %s, got %d: %s", target, want, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/usage?month=March", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid month, got %d", rec.Code)
+	}
+}
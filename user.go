@@ -0,0 +1,124 @@
+package gochatbot
+
+import (
+	"context"
+
+	"go.rumenx.com/chatbot/middleware"
+	"go.rumenx.com/chatbot/tools"
+)
+
+// User identifies who is asking. The ID is recorded as "user_id" in the
+// request context, where rate limiting, memory, events, guardrails audit
+// entries, handoff conversations and experiments all read it, so one user is
+// the same user everywhere. Only WithUser and NewUserContext set it: a
+// "user_id" or "user_attributes" passed with WithContext is dropped, so
+// clients cannot pose as another user.
+type User struct {
+	ID string
+	// Attributes describe the user, such as a plan or locale. They are
+	// passed to the model and tools as "user_attributes".
+	Attributes map[string]interface{}
+}
+
+type userContextKey struct{}
+
+// NewUserContext returns a context carrying user, for authentication
+// middleware to identify the user to every Ask, AskStream and RunAgent call
+// made with it, including those made by HTTPHandler.
+func NewUserContext(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the user set with NewUserContext.
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(User)
+	return user, ok && user.ID != ""
+}
+
+// WithUser identifies the user asking, with optional attributes. It takes
+// precedence over a user set with NewUserContext.
+func WithUser(id string, attrs map[string]interface{}) AskOption {
+	return func(opts *askOptions) {
+		opts.user = &User{ID: id, Attributes: attrs}
+	}
+}
+
+// WithUserID identifies the user asking.
+func WithUserID(id string) AskOption {
+	return WithUser(id, nil)
+}
+
+// setUser records user in the request context.
+func setUser(askContext map[string]interface{}, user User) {
+	askContext["user_id"] = user.ID
+	if user.Attributes != nil {
+		askContext["user_attributes"] = user.Attributes
+	}
+}
+
+// identify fills in the user from ctx if no option named one, and returns a
+// context that counts rate limits against the user rather than the client.
+// Newly issued sessions are still counted against the client.
+func identify(ctx context.Context, askContext map[string]interface{}) context.Context {
+	userID, _ := askContext["user_id"].(string)
+	if userID == "" {
+		user, ok := UserFromContext(ctx)
+		if !ok {
+			return ctx
+		}
+		setUser(askContext, user)
+		userID = user.ID
+	}
+	noteRequestUser(ctx, userID)
+	if isNewSession(ctx, userID) {
+		return ctx
+	}
+	return middleware.WithClientID(ctx, userID)
+}
+
+// identifyCaller returns a context whose tool calls are made for the user
+// asking. Parts of the caller not set with tools.WithCaller are filled in:
+// the persona from the "persona" context value, the role from the user's
+// "role" attribute, and the user and conversation IDs.
+func identifyCaller(ctx context.Context, askContext map[string]interface{}) context.Context {
+	caller, _ := tools.CallerFromContext(ctx)
+	if caller.Persona == "" {
+		caller.Persona, _ = askContext["persona"].(string)
+	}
+	if caller.Role == "" {
+		if attrs, ok := askContext["user_attributes"].(map[string]interface{}); ok {
+			caller.Role, _ = attrs["role"].(string)
+		}
+	}
+	if caller.UserID == "" {
+		caller.UserID, _ = askContext["user_id"].(string)
+	}
+	if caller.ConversationID == "" {
+		caller.ConversationID, _ = askContext["conversation_id"].(string)
+	}
+	return tools.WithCaller(ctx, caller)
+}
+
+// scopeRateLimit returns a context whose requests to route are limited by
+// the matching rate limit override. Parts of the scope not set with
+// middleware.WithRateLimitScope are filled in: the persona from the
+// "persona" context value or else the chatbot's name, and the tier from
+// the user's "tier" attribute.
+func (c *Chatbot) scopeRateLimit(ctx context.Context, route string, askContext map[string]interface{}) context.Context {
+	scope, _ := middleware.RateLimitScopeFromContext(ctx)
+	if scope.Route == "" {
+		scope.Route = route
+	}
+	if scope.Persona == "" {
+		scope.Persona, _ = askContext["persona"].(string)
+	}
+	if scope.Persona == "" {
+		scope.Persona = c.name
+	}
+	if scope.Tier == "" {
+		if attrs, ok := askContext["user_attributes"].(map[string]interface{}); ok {
+			scope.Tier, _ = attrs["tier"].(string)
+		}
+	}
+	return middleware.WithRateLimitScope(ctx, scope)
+}
@@ -0,0 +1,83 @@
+package gochatbot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/middleware"
+)
+
+func TestWithUser(t *testing.T) {
+	cfg := config.Default()
+	cfg.MessageFiltering.Enabled = false
+	model := &contextModel{}
+	publisher := &recordingPublisher{}
+	bot, err := New(cfg, WithModel(model), WithEventPublisher(publisher))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+
+	attrs := map[string]interface{}{"plan": "pro"}
+	if _, err := bot.Ask(context.Background(), "Hello", WithUser("u1", attrs)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["user_id"] != "u1" || model.context["user_attributes"] == nil {
+		t.Errorf("expected the user in the request context, got %v", model.context)
+	}
+	for _, event := range publisher.events {
+		if event.UserID != "u1" {
+			t.Errorf("expected %s event for u1, got %q", event.Type, event.UserID)
+		}
+	}
+
+	// The option takes precedence over the context
+	ctx := NewUserContext(context.Background(), User{ID: "u2"})
+	if _, err := bot.Ask(ctx, "Hello", WithUserID("u3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["user_id"] != "u3" {
+		t.Errorf("expected u3, got %v", model.context["user_id"])
+	}
+	if _, err := bot.Ask(ctx, "Hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["user_id"] != "u2" {
+		t.Errorf("expected u2 from the context, got %v", model.context["user_id"])
+	}
+
+	// Clients passing context values cannot pose as another user
+	spoofed := []AskOption{WithContext("user_id", "bob"), WithContext("user_attributes", map[string]interface{}{"role": "admin"})}
+	if _, err := bot.Ask(ctx, "Hello", spoofed...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.context["user_id"] != "u2" || model.context["user_attributes"] != nil {
+		t.Errorf("expected the authenticated user u2 only, got %v", model.context)
+	}
+	if _, err := bot.Ask(context.Background(), "Hello", spoofed...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := model.context["user_id"]; ok {
+		t.Errorf("expected no user without authentication, got %v", model.context["user_id"])
+	}
+}
+
+func TestWithUser_RateLimitsPerUser(t *testing.T) {
+	limiter := middleware.NewRateLimiter(config.RateLimitConfig{RequestsPerMinute: 1, Window: time.Minute})
+	bot, err := New(&config.Config{Model: "free"}, WithRateLimit(limiter))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := bot.Ask(ctx, "Hello", WithUserID("u1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := bot.Ask(ctx, "Hello", WithUserID("u2")); err != nil {
+		t.Errorf("expected u2 to have its own limit, got %v", err)
+	}
+	if _, err := bot.Ask(ctx, "Hello", WithUserID("u1")); err == nil {
+		t.Error("expected u1 to be rate limited")
+	}
+}
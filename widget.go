@@ -0,0 +1,43 @@
+package gochatbot
+
+import (
+	"net/http"
+	"strings"
+
+	"go.rumenx.com/chatbot/widget"
+)
+
+// ServeWidget returns a handler that serves the embedded chat widget and the
+// chat endpoints it talks to, all under prefix:
+//
+//	prefix             standalone page hosting the widget
+//	prefix/widget.js   widget script; include it on any page
+//	prefix/widget.css  widget styles (loaded by the script)
+//	prefix/chat        JSON chat endpoint (HandleHTTP)
+//	prefix/chat/stream streaming chat endpoint (HandleStreamHTTP)
+//
+// Mount it with a trailing slash:
+//
+//	http.Handle("/chatbot/", bot.ServeWidget("/chatbot/", widget.Options{}))
+func (c *Chatbot) ServeWidget(prefix string, opts widget.Options) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix != "/" {
+		prefix += "/"
+	}
+
+	// With CSRF protection, loading the widget issues the token it sends
+	if c.csrf != nil {
+		if opts.CSRFCookie == "" {
+			opts.CSRFCookie = c.csrf.CookieName
+		}
+		if opts.CSRFHeader == "" {
+			opts.CSRFHeader = c.csrf.HeaderName
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"chat", c.HandleHTTP)
+	mux.HandleFunc(prefix+"chat/stream", c.HandleStreamHTTP)
+	mux.Handle(prefix, c.CSRF(widget.Handler(opts)))
+	return mux
+}
@@ -0,0 +1,218 @@
+/**
+ * Go Chatbot - Embedded Widget
+ * Served by the widget package; initialized with GoChatbotWidget.init(options).
+ */
+(function () {
+  'use strict';
+
+  var script = document.currentScript;
+  var baseURL = script ? script.src : window.location.href;
+
+  function el(tag, className, text) {
+    var node = document.createElement(tag);
+    if (className) node.className = className;
+    if (text) node.textContent = text;
+    return node;
+  }
+
+  function resolve(endpoint) {
+    return new URL(endpoint, baseURL).toString();
+  }
+
+  function cookie(name) {
+    var cookies = document.cookie ? document.cookie.split('; ') : [];
+    for (var i = 0; i < cookies.length; i++) {
+      var separator = cookies[i].indexOf('=');
+      if (cookies[i].slice(0, separator) === name) {
+        return decodeURIComponent(cookies[i].slice(separator + 1));
+      }
+    }
+    return '';
+  }
+
+  function Widget(options) {
+    this.options = options;
+    this.busy = false;
+    this.build();
+  }
+
+  Widget.prototype.build = function () {
+    var o = this.options;
+    var theme = o.theme || {};
+
+    this.root = el('div', 'go-chatbot-widget go-chatbot-' + (o.position || 'bottom-right'));
+    var vars = {
+      '--go-chatbot-primary': theme.primaryColor,
+      '--go-chatbot-background': theme.backgroundColor,
+      '--go-chatbot-text': theme.textColor,
+      '--go-chatbot-font': theme.fontFamily,
+      '--go-chatbot-radius': theme.borderRadius
+    };
+    for (var name in vars) {
+      if (vars[name]) this.root.style.setProperty(name, vars[name]);
+    }
+
+    this.button = el('button', 'go-chatbot-toggle', '💬');
+    this.button.setAttribute('aria-label', 'Open chat');
+
+    this.panel = el('div', 'go-chatbot-panel');
+    this.panel.setAttribute('role', 'dialog');
+    this.panel.setAttribute('aria-label', o.title);
+
+    var header = el('div', 'go-chatbot-header');
+    header.appendChild(el('span', 'go-chatbot-title', o.title));
+    var close = el('button', 'go-chatbot-close', '×');
+    close.setAttribute('aria-label', 'Close chat');
+    header.appendChild(close);
+
+    this.log = el('div', 'go-chatbot-messages');
+    this.log.setAttribute('aria-live', 'polite');
+
+    this.form = el('form', 'go-chatbot-form');
+    this.input = el('input', 'go-chatbot-input');
+    this.input.type = 'text';
+    this.input.placeholder = o.placeholder;
+    this.input.setAttribute('aria-label', o.placeholder);
+    this.send = el('button', 'go-chatbot-send', 'Send');
+    this.send.type = 'submit';
+    this.form.appendChild(this.input);
+    this.form.appendChild(this.send);
+
+    this.panel.appendChild(header);
+    this.panel.appendChild(this.log);
+    this.panel.appendChild(this.form);
+    this.root.appendChild(this.panel);
+    this.root.appendChild(this.button);
+    document.body.appendChild(this.root);
+
+    var self = this;
+    this.button.addEventListener('click', function () { self.toggle(true); });
+    close.addEventListener('click', function () { self.toggle(false); });
+    this.form.addEventListener('submit', function (event) {
+      event.preventDefault();
+      self.submit();
+    });
+
+    if (o.greeting) this.add('assistant', o.greeting);
+    this.toggle(!!o.open);
+  };
+
+  Widget.prototype.toggle = function (open) {
+    this.root.classList.toggle('go-chatbot-open', open);
+    if (open) this.input.focus();
+  };
+
+  Widget.prototype.add = function (role, text) {
+    var message = el('div', 'go-chatbot-message go-chatbot-' + role, text);
+    this.log.appendChild(message);
+    this.log.scrollTop = this.log.scrollHeight;
+    return message;
+  };
+
+  Widget.prototype.setBusy = function (busy) {
+    this.busy = busy;
+    this.input.disabled = busy;
+    this.send.disabled = busy;
+    if (!busy) this.input.focus();
+  };
+
+  Widget.prototype.submit = function () {
+    var text = this.input.value.trim();
+    if (!text || this.busy) return;
+
+    this.input.value = '';
+    this.add('user', text);
+    var reply = this.add('assistant', '');
+    reply.classList.add('go-chatbot-typing');
+    this.setBusy(true);
+
+    var self = this;
+    var request = this.options.stream ? this.stream(text, reply) : this.ask(text, reply);
+    request.catch(function (error) {
+      reply.textContent = error.message || 'Something went wrong. Please try again.';
+      reply.classList.add('go-chatbot-error');
+    }).then(function () {
+      reply.classList.remove('go-chatbot-typing');
+      self.setBusy(false);
+    });
+  };
+
+  Widget.prototype.post = function (endpoint, text) {
+    var headers = { 'Content-Type': 'application/json' };
+    if (this.options.csrfCookie && this.options.csrfHeader) {
+      headers[this.options.csrfHeader] = cookie(this.options.csrfCookie);
+    }
+    return fetch(resolve(endpoint), {
+      method: 'POST',
+      headers: headers,
+      body: JSON.stringify({ message: text })
+    });
+  };
+
+  Widget.prototype.ask = function (text, reply) {
+    return this.post(this.options.endpoint, text)
+      .then(function (response) { return response.json(); })
+      .then(function (data) {
+        if (data.error) throw new Error(data.error);
+        reply.textContent = data.reply;
+      });
+  };
+
+  Widget.prototype.stream = function (text, reply) {
+    var self = this;
+    return this.post(this.options.streamEndpoint, text).then(function (response) {
+      if (!response.ok || !response.body) {
+        return response.json().then(function (data) {
+          throw new Error(data.error || 'Request failed');
+        });
+      }
+
+      var reader = response.body.getReader();
+      var decoder = new TextDecoder();
+      var buffer = '';
+
+      function read() {
+        return reader.read().then(function (result) {
+          if (result.done) return;
+          buffer += decoder.decode(result.value, { stream: true });
+
+          var events = buffer.split('\n\n');
+          buffer = events.pop();
+          for (var i = 0; i < events.length; i++) {
+            var line = events[i].trim();
+            if (line.indexOf('data: ') !== 0) continue;
+            var chunk = JSON.parse(line.slice(6));
+            if (chunk.error) throw new Error(chunk.error);
+            if (chunk.content) {
+              reply.textContent += chunk.content;
+              self.log.scrollTop = self.log.scrollHeight;
+            }
+          }
+          return read();
+        });
+      }
+      return read();
+    });
+  };
+
+  function injectStyles() {
+    if (document.querySelector('link[data-go-chatbot]')) return;
+    var link = el('link');
+    link.rel = 'stylesheet';
+    link.href = resolve('widget.css');
+    link.setAttribute('data-go-chatbot', '');
+    document.head.appendChild(link);
+  }
+
+  window.GoChatbotWidget = {
+    init: function (options) {
+      injectStyles();
+      var start = function () { return new Widget(options); };
+      if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', start);
+        return null;
+      }
+      return start();
+    }
+  };
+})();
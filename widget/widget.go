@@ -0,0 +1,186 @@
+// Package widget serves an embedded JavaScript chat widget for the go-chatbot
+// package. The widget talks to the chatbot's JSON and streaming endpoints and
+// needs no build step or external assets.
+//
+// Most applications use Chatbot.ServeWidget, which mounts the widget together
+// with the chat endpoints:
+//
+//	http.Handle("/chatbot/", bot.ServeWidget("/chatbot/", widget.Options{}))
+//
+// and add it to any page with:
+//
+//	<script src="/chatbot/widget.js" defer></script>
+package widget
+
+import (
+	"bytes"
+	_ "embed" // Widget assets are compiled into the binary
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed assets/widget.js
+var widgetScript []byte
+
+//go:embed assets/widget.css
+var widgetStyles []byte
+
+//go:embed assets/index.html
+var indexHTML string
+
+var indexTemplate = template.Must(template.New("index").Parse(indexHTML))
+
+// Theme customizes the widget's appearance. Empty fields keep the defaults.
+type Theme struct {
+	PrimaryColor    string `json:"primaryColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	TextColor       string `json:"textColor,omitempty"`
+	FontFamily      string `json:"fontFamily,omitempty"`
+	BorderRadius    string `json:"borderRadius,omitempty"`
+}
+
+// Options configures the widget.
+type Options struct {
+	// Title is shown in the widget header (default "Chat").
+	Title string
+	// Greeting is an optional first assistant message.
+	Greeting string
+	// Placeholder is the input placeholder (default "Type your message...").
+	Placeholder string
+	// Endpoint is the JSON chat endpoint, relative to the widget (default "chat").
+	Endpoint string
+	// StreamEndpoint is the streaming chat endpoint, relative to the widget
+	// (default "chat/stream").
+	StreamEndpoint string
+	// DisableStreaming makes the widget use Endpoint instead of StreamEndpoint.
+	DisableStreaming bool
+	// Position is "bottom-right" (default) or "bottom-left".
+	Position string
+	// Open shows the chat panel on page load.
+	Open bool
+	// Theme customizes colors, font and corner radius.
+	Theme Theme
+	// CSRFCookie and CSRFHeader make the widget repeat the value of the
+	// CSRFCookie cookie in the CSRFHeader header of its requests, for chat
+	// endpoints with CSRF protection. Chatbot.ServeWidget sets them.
+	CSRFCookie string
+	CSRFHeader string
+}
+
+// clientOptions is the JSON passed to GoChatbotWidget.init.
+type clientOptions struct {
+	Title          string `json:"title"`
+	Greeting       string `json:"greeting,omitempty"`
+	Placeholder    string `json:"placeholder"`
+	Endpoint       string `json:"endpoint"`
+	StreamEndpoint string `json:"streamEndpoint"`
+	Stream         bool   `json:"stream"`
+	Position       string `json:"position"`
+	Open           bool   `json:"open"`
+	Theme          Theme  `json:"theme"`
+	CSRFCookie     string `json:"csrfCookie,omitempty"`
+	CSRFHeader     string `json:"csrfHeader,omitempty"`
+}
+
+// withDefaults fills in unset options.
+func (o Options) withDefaults() Options {
+	if o.Title == "" {
+		o.Title = "Chat"
+	}
+	if o.Placeholder == "" {
+		o.Placeholder = "Type your message..."
+	}
+	if o.Endpoint == "" {
+		o.Endpoint = "chat"
+	}
+	if o.StreamEndpoint == "" {
+		o.StreamEndpoint = "chat/stream"
+	}
+	if o.Position != "bottom-left" {
+		o.Position = "bottom-right"
+	}
+	return o
+}
+
+// Handler returns an http.Handler serving the widget. It answers requests
+// whose last path element is widget.js, widget.css or empty (a standalone
+// page hosting the widget), so it can be mounted under any prefix.
+func Handler(opts Options) http.Handler {
+	opts = opts.withDefaults()
+
+	// Marshaling plain strings and bools cannot fail
+	config, _ := json.Marshal(clientOptions{
+		Title:          opts.Title,
+		Greeting:       opts.Greeting,
+		Placeholder:    opts.Placeholder,
+		Endpoint:       opts.Endpoint,
+		StreamEndpoint: opts.StreamEndpoint,
+		Stream:         !opts.DisableStreaming,
+		Position:       opts.Position,
+		Open:           opts.Open,
+		Theme:          opts.Theme,
+		CSRFCookie:     opts.CSRFCookie,
+		CSRFHeader:     opts.CSRFHeader,
+	})
+
+	script := make([]byte, 0, len(widgetScript)+len(config)+32)
+	script = append(script, widgetScript...)
+	script = append(script, fmt.Sprintf("\nGoChatbotWidget.init(%s);\n", config)...)
+
+	var index bytes.Buffer
+	if err := indexTemplate.Execute(&index, opts); err != nil {
+		panic(fmt.Sprintf("widget: failed to render page: %v", err))
+	}
+
+	page := asset{contentType: "text/html; charset=utf-8", data: index.Bytes()}
+	return &handler{
+		files: map[string]asset{
+			"widget.js":  {contentType: "application/javascript; charset=utf-8", data: script},
+			"widget.css": {contentType: "text/css; charset=utf-8", data: widgetStyles},
+			"":           page,
+			"index.html": page,
+		},
+		modTime: time.Now(),
+	}
+}
+
+// asset is a pre-rendered widget file.
+type asset struct {
+	contentType string
+	data        []byte
+}
+
+// handler serves the pre-rendered widget files.
+type handler struct {
+	files   map[string]asset
+	modTime time.Time
+}
+
+// ServeHTTP implements http.Handler.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := ""
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Base(r.URL.Path)
+	}
+
+	file, ok := h.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, h.modTime, bytes.NewReader(file.data))
+}
@@ -0,0 +1,73 @@
+package widget
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_ServesAssets(t *testing.T) {
+	handler := Handler(Options{
+		Title:    "Support </script>",
+		Greeting: "Hi!",
+		Theme:    Theme{PrimaryColor: "#ff0000"},
+	})
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/chatbot/widget.js", "application/javascript", `"primaryColor":"#ff0000"`},
+		{"/chatbot/widget.css", "text/css", ".go-chatbot-widget"},
+		{"/chatbot/", "text/html", `<script src="widget.js" defer></script>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Get("Content-Type"), tt.contentType)
+			assert.Contains(t, rec.Body.String(), tt.contains)
+		})
+	}
+}
+
+func TestHandler_EscapesOptions(t *testing.T) {
+	handler := Handler(Options{Title: "</script><script>alert(1)</script>"})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widget.js", nil))
+	assert.NotContains(t, rec.Body.String(), "</script><script>")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotContains(t, rec.Body.String(), "<script>alert(1)")
+}
+
+func TestHandler_Defaults(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(Options{DisableStreaming: true}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widget.js", nil))
+
+	init := rec.Body.String()[strings.LastIndex(rec.Body.String(), "GoChatbotWidget.init("):]
+	assert.Contains(t, init, `"endpoint":"chat"`)
+	assert.Contains(t, init, `"stream":false`)
+	assert.Contains(t, init, `"position":"bottom-right"`)
+}
+
+func TestHandler_RejectsUnknownPathsAndMethods(t *testing.T) {
+	handler := Handler(Options{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/secret.txt", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/widget.js", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,40 @@
+package gochatbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.rumenx.com/chatbot/config"
+	"go.rumenx.com/chatbot/models"
+	"go.rumenx.com/chatbot/widget"
+)
+
+func TestServeWidget(t *testing.T) {
+	bot, err := New(config.Default(), WithModel(models.NewFreeModel()))
+	if err != nil {
+		t.Fatalf("failed to create chatbot: %v", err)
+	}
+	handler := bot.ServeWidget("chatbot", widget.Options{Title: "Help"})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/chatbot/widget.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"title":"Help"`) {
+		t.Errorf("expected widget script, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/chatbot/chat", strings.NewReader(`{"message":"Hello"}`))
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reply"`) {
+		t.Errorf("expected chat reply, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/chatbot/chat/stream", strings.NewReader(`{"message":"Hello"}`))
+	handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "data: ") {
+		t.Errorf("expected streamed events, got %q", rec.Body.String())
+	}
+}